@@ -15,11 +15,16 @@
 package configuration
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
 	"os"
 	"path"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/xyephy/rosetta-whive/whive"
@@ -27,6 +32,8 @@ import (
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/coinbase/rosetta-sdk-go/storage/encoder"
 	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"go.uber.org/zap/zapcore"
 )
 
 // Mode is the setting that determines if
@@ -48,25 +55,53 @@ const (
 	// Testnet is Whive Testnet.
 	Testnet string = "TESTNET"
 
-	// mainnetConfigPath is the path of the Whive
+	// Regtest is a local Whive regression
+	// test network.
+	Regtest string = "REGTEST"
+
+	// mainnetConfigFile is the name of the Whive
 	// configuration file for mainnet.
-	mainnetConfigPath = "/app/whive-mainnet.conf"
+	mainnetConfigFile = "whive-mainnet.conf"
 
-	// testnetConfigPath is the path of the Whive
+	// testnetConfigFile is the name of the Whive
 	// configuration file for testnet.
-	testnetConfigPath = "/app/whive-testnet.conf"
+	testnetConfigFile = "whive-testnet.conf"
+
+	// regtestConfigFile is the name of the Whive
+	// configuration file for regtest.
+	regtestConfigFile = "whive-regtest.conf"
 
 	// Zstandard compression dictionaries
 	transactionNamespace         = "transaction"
-	testnetTransactionDictionary = "/app/testnet-transaction.zstd"
-	mainnetTransactionDictionary = "/app/mainnet-transaction.zstd"
+	testnetTransactionDictionary = "testnet-transaction.zstd"
+	mainnetTransactionDictionary = "mainnet-transaction.zstd"
+
+	// whivedBinary is the name of the whived
+	// executable in the app directory.
+	whivedBinary = "whived"
+
+	// dictionaryExtension is the extension of dictionaries
+	// in the directory specified by DictionaryDirectoryEnv.
+	dictionaryExtension = ".zstd"
 
 	mainnetRPCPort = 1867
 	testnetRPCPort = 18867
+	regtestRPCPort = 18443
+
+	// ports below maxPrivilegedPort can only be
+	// bound with elevated privileges (or the
+	// CAP_NET_BIND_SERVICE capability).
+	maxPrivilegedPort = 1024
+
+	// privilegedPortWarning is returned (formatted with
+	// the port) when PORT is below maxPrivilegedPort.
+	privilegedPortWarning = "PORT %d is privileged: binding to it requires running as " +
+		"root or the CAP_NET_BIND_SERVICE capability"
 
 	// min prune depth is 2160:
 	// https://github.com/xyephy/whive/blob/098b0a1e43f57cbc54c8efa558567152bef5c9f5/src/validation.h#L84
-	pruneDepth = int64(10000) //nolint
+	pruneDepth    = int64(10000) //nolint
+	minPruneDepth = int64(2160)  //nolint
 
 	// min prune height (on mainnet):
 	// https://github.com/xyephy/whive/blob/0cdc55c1d34d351014caf95d6448c4d8c6f7043a/src/chainparams.cpp#146
@@ -75,11 +110,69 @@ const (
 	// attempt to prune once an hour
 	pruneFrequency = 60 * time.Minute
 
+	// never prune the blocks we may need to
+	// re-fetch during a reorg (the syncer can't
+	// handle reorgs deeper than its past block
+	// limit of 100 blocks)
+	pruneReorgDepth = int64(100) //nolint
+
+	// gzipMinSize is the default minimum size (in bytes)
+	// of a response before it is compressed.
+	gzipMinSize = 1400
+
+	// maxBufferedBlocks is the default number of blocks
+	// the indexer may fetch ahead of the last block written
+	// to storage.
+	maxBufferedBlocks = int64(256)
+
+	// blockRetryLimit is the default number of times
+	// the indexer retries a transient error fetching
+	// a block from whived.
+	blockRetryLimit = 5
+
+	// blockRetryDelay is the default time the indexer
+	// waits between retries of fetching a block.
+	blockRetryDelay = 10 * time.Second
+
+	// httpReadTimeout is the default maximum duration for
+	// reading an entire request, including the body.
+	httpReadTimeout = 5 * time.Second
+
+	// httpWriteTimeout is the default maximum duration
+	// before timing out writes of a response.
+	httpWriteTimeout = 15 * time.Second
+
+	// httpIdleTimeout is the default maximum amount of time
+	// to wait for the next request when keep-alives are enabled.
+	httpIdleTimeout = 30 * time.Second
+
+	// readyBlocksBehind is the default number of blocks
+	// the indexer may be behind whived's tip and still
+	// be reported as ready by the readiness check.
+	readyBlocksBehind = int64(2)
+
+	// finalityDepth is the default number of confirmations
+	// after which a transaction is considered final.
+	finalityDepth = int64(6)
+
+	// maxConfirmationTarget is the largest confirmation
+	// target (in blocks) whived estimates fees for.
+	maxConfirmationTarget = int64(1008)
+
+	// bytesInMB is the number of bytes in
+	// a megabyte.
+	bytesInMB = uint64(1024 * 1024)
+
 	// DataDirectory is the default location for all
 	// persistent data.
 	DataDirectory = "/data"
 
-	whivedPath    = "whived"
+	// AppDirectory is the default location of the
+	// whived executable, the whived configuration
+	// files, and the compression dictionaries.
+	AppDirectory = "/app"
+
+	whivedPath  = "whived"
 	indexerPath = "indexer"
 
 	// allFilePermissions specifies anyone can do anything
@@ -98,40 +191,421 @@ const (
 	// read to determine the port for the Rosetta
 	// implementation.
 	PortEnv = "PORT"
+
+	// DataDirectoryEnv is the environment variable
+	// read to override the directory where all
+	// persistent data is stored (DataDirectory).
+	DataDirectoryEnv = "DATA_DIR"
+
+	// AppDirectoryEnv is the environment variable
+	// read to override the directory containing the
+	// whived executable, configuration files, and
+	// compression dictionaries (AppDirectory).
+	AppDirectoryEnv = "APP_DIR"
+
+	// DictionaryDirectoryEnv is the environment variable
+	// read to determine a directory of trained zstd
+	// dictionaries. Each file named "<namespace>.zstd" is
+	// used to compress that storage namespace (replacing
+	// any default dictionary for the namespace).
+	DictionaryDirectoryEnv = "DICTIONARY_DIR"
+
+	// StrictPortEnv is the environment variable
+	// read to determine if a privileged PORT (below
+	// 1024) should be rejected unless running as root.
+	// If not set, a warning is logged instead.
+	StrictPortEnv = "STRICT_PORT"
+
+	// RPCPortEnv is the environment variable
+	// read to override the port used to connect
+	// to whived's RPC server (when whived is run
+	// with a non-default rpcport). If not set, the
+	// default port of the network is used.
+	RPCPortEnv = "RPC_PORT"
+
+	// MaxBufferedBlocksEnv is the environment variable
+	// read to determine how many fetched blocks may be
+	// buffered before they are written to storage.
+	MaxBufferedBlocksEnv = "MAX_BUFFERED_BLOCKS"
+
+	// SyncConcurrencyEnv is the environment variable
+	// read to determine the maximum number of blocks the
+	// indexer fetches from whived concurrently. Blocks are
+	// still added to storage in order. If not set, the
+	// syncer's default maximum is used.
+	SyncConcurrencyEnv = "SYNC_CONCURRENCY"
+
+	// MinFreeDiskEnv is the environment variable
+	// read to determine the minimum free disk space
+	// (in MB) required on the data directory to continue
+	// indexing. If not set, free disk space is not monitored.
+	MinFreeDiskEnv = "MIN_FREE_DISK"
+
+	// BlockRetryLimitEnv is the environment variable
+	// read to determine how many times a transient error
+	// fetching a block from whived is retried.
+	BlockRetryLimitEnv = "BLOCK_RETRY_LIMIT"
+
+	// BlockRetryDelayEnv is the environment variable
+	// read to determine how long to wait between retries
+	// of fetching a block (e.g. "500ms").
+	BlockRetryDelayEnv = "BLOCK_RETRY_DELAY"
+
+	// MaxTxOutputsEnv is the environment variable
+	// read to determine the maximum number of outputs
+	// in a constructed transaction. If not set, the
+	// number of outputs is only limited by transaction weight.
+	MaxTxOutputsEnv = "MAX_TX_OUTPUTS"
+
+	// ValidateNetworkEnv is the environment variable
+	// read to determine if the indexer should halt when
+	// whived is not running on the configured network.
+	// Network validation is enabled by default.
+	ValidateNetworkEnv = "VALIDATE_NETWORK"
+
+	// NodeWaitTimeoutEnv is the environment variable
+	// read to determine how long to wait for whived to
+	// become ready (e.g. while it rebuilds its block index)
+	// before giving up (e.g. "6h"). By default, we wait
+	// indefinitely.
+	NodeWaitTimeoutEnv = "NODE_WAIT_TIMEOUT"
+
+	// MinPeersAtStartupEnv is the environment variable
+	// read to determine how many peers whived must have
+	// before we start indexing. Waiting for peers is
+	// subject to NODE_WAIT_TIMEOUT. By default, we don't
+	// wait for peers.
+	MinPeersAtStartupEnv = "MIN_PEERS_AT_STARTUP"
+
+	// IncludeMempoolEnv is the environment variable
+	// read to determine if /account/balance should include
+	// the net unconfirmed balance of an account from
+	// transactions in the mempool.
+	IncludeMempoolEnv = "INCLUDE_MEMPOOL"
+
+	// DustRelayFeeEnv is the environment variable
+	// read to determine the dust relay fee (in satoshis
+	// per kvB) used to report the portion of a balance that
+	// is dust in /account/balance metadata (and the dust
+	// threshold of each output type in /network/options).
+	// whived uses 3000 by default (-dustrelayfee). If not
+	// set, dust is not reported.
+	DustRelayFeeEnv = "DUST_RELAY_FEE"
+
+	// RPCBatchWindowEnv is the environment variable
+	// read to determine how long to wait for concurrent
+	// read requests to whived to combine into a single
+	// JSON-RPC batch (e.g. "5ms"). Batching is disabled
+	// by default.
+	RPCBatchWindowEnv = "RPC_BATCH_WINDOW"
+
+	// RPCMaxConcurrencyEnv is the environment variable
+	// read to determine the maximum number of requests made
+	// to whived concurrently. This should be set below whived's
+	// -rpcworkqueue (16 by default). Requests rejected because
+	// the work queue is full are retried regardless. By default,
+	// concurrent requests are not limited.
+	RPCMaxConcurrencyEnv = "RPC_MAX_CONCURRENCY"
+
+	// RPCMaxResponseBytesEnv is the environment variable
+	// read to determine the maximum size (in bytes) of a
+	// response from whived. If not set, responses are
+	// limited to 256 MB.
+	RPCMaxResponseBytesEnv = "RPC_MAX_RESPONSE_BYTES"
+
+	// RPCCookiePathEnv is the environment variable read to
+	// determine the path of the cookie file whived writes when
+	// rpcuser and rpcpassword are not configured. If set, requests
+	// are authenticated with the cookie instead of the static
+	// credentials in the provided whived configuration files.
+	RPCCookiePathEnv = "RPC_COOKIE_PATH"
+
+	// RPCSocketEnv is the environment variable read to
+	// determine the Unix domain socket whived's RPC server
+	// listens on (e.g. "unix:///run/whived/rpc.sock"). If
+	// set, we connect to whived over the socket instead of
+	// over TCP (and RPC_PORT is ignored).
+	RPCSocketEnv = "RPC_SOCKET"
+
+	// LogLevelEnv is the environment variable read to
+	// determine the minimum level of logs that are written
+	// ("debug", "info", "warn", or "error"). If not set,
+	// logs are written at the info level and above.
+	LogLevelEnv = "LOG_LEVEL"
+
+	// TipReorgCheckEnv is the environment variable
+	// read to determine if the indexer should check that
+	// whived's best chain still includes the indexed tip
+	// and orphan blocks until it does. Without this check,
+	// reorgs are only detected once whived's best chain
+	// is longer than the indexed chain.
+	TipReorgCheckEnv = "TIP_REORG_CHECK"
+
+	// BlockOperationTypesEnv is the environment variable
+	// read to determine which operation types (comma-separated,
+	// e.g. "OUTPUT") are returned by /block and /block/transaction.
+	// Operation indices are not changed by filtering. Clients
+	// that compute balances from /block (like rosetta-cli) must
+	// not be used when this is set, as balance changes from the
+	// omitted operations will be missing. All operations are
+	// returned by default.
+	BlockOperationTypesEnv = "BLOCK_OPERATION_TYPES"
+
+	// BlockInlineTransactionsEnv is the environment variable
+	// read to determine how many transactions of a block are
+	// returned inline by /block. The identifiers of any
+	// remaining transactions are returned in other_transactions
+	// (to be fetched with /block/transaction). If not set, up
+	// to 100 transactions are returned inline.
+	BlockInlineTransactionsEnv = "BLOCK_INLINE_TRANSACTIONS"
+
+	// MaxConcurrentConstructionEnv is the environment
+	// variable read to determine the maximum number of
+	// /construction/* requests served concurrently. Requests
+	// beyond this limit are rejected with a retriable error
+	// so that signing load cannot starve data endpoints.
+	// By default, construction requests are not limited.
+	MaxConcurrentConstructionEnv = "MAX_CONCURRENT_CONSTRUCTION"
+
+	// FinalityDepthEnv is the environment variable
+	// read to determine how many confirmations a
+	// transaction must have before it is considered
+	// final by the transaction_finality /call method.
+	FinalityDepthEnv = "FINALITY_DEPTH"
+
+	// StorageShardsEnv is the environment variable
+	// read to determine how many key spaces coin and
+	// balance storage is split into (accounts are assigned
+	// to a shard by a hash of their address). This cannot
+	// be changed once indexing has started. By default,
+	// storage is not sharded.
+	StorageShardsEnv = "STORAGE_SHARDS"
+
+	// MaxIndexHeightEnv is the environment variable
+	// read to determine the height at which the indexer
+	// stops indexing (to serve a point-in-time snapshot).
+	// Blocks after this height are ignored. If not set,
+	// we index whived's best chain indefinitely.
+	MaxIndexHeightEnv = "MAX_INDEX_HEIGHT"
+
+	// PruneReorgDepthEnv is the environment variable
+	// read to determine how many blocks below our head
+	// are never pruned, so that they can be re-fetched
+	// from whived during a reorg. Pruning is also paused
+	// while a reorg is in progress.
+	PruneReorgDepthEnv = "PRUNE_REORG_DEPTH"
+
+	// PruningDisabledEnv is the environment variable
+	// read to determine if whived should never be
+	// pruned (for operators with large disks).
+	PruningDisabledEnv = "PRUNING_DISABLED"
+
+	// PruningDepthEnv is the environment variable
+	// read to determine how many blocks below our head
+	// are kept by whived. It must be at least 2160.
+	PruningDepthEnv = "PRUNING_DEPTH"
+
+	// PruningFrequencyEnv is the environment variable
+	// read to determine how often we attempt to prune
+	// whived (as a Go duration, e.g. "30m").
+	PruningFrequencyEnv = "PRUNING_FREQUENCY"
+
+	// BalanceCoalesceEnv is the environment variable
+	// read to determine if concurrent balance lookups
+	// (e.g. during a wallet rescan) should be grouped
+	// into a single storage read. This adds a short
+	// delay to each /account/balance request.
+	BalanceCoalesceEnv = "BALANCE_COALESCE"
+
+	// WarmCacheEnv is the environment variable
+	// read to determine how many of the most recently
+	// created unspent coins are loaded into the indexer's
+	// coin cache on startup (so that the first blocks
+	// synced after a restart are processed quickly). If
+	// not set, the coin cache is not warmed.
+	WarmCacheEnv = "WARM_CACHE"
+
+	// StrictOperationsEnv is the environment variable
+	// read to determine if /construction/payloads should
+	// reject operations that are not INPUT or OUTPUT
+	// operations. If not set, these operations are ignored.
+	StrictOperationsEnv = "STRICT_OPERATIONS"
+
+	// MetricsEnabledEnv is the environment variable
+	// read to determine if Prometheus metrics should
+	// be served on /metrics. If not set, metrics are
+	// not served.
+	MetricsEnabledEnv = "METRICS_ENABLED"
+
+	// MaxMetadataAgeEnv is the environment variable read
+	// to determine how old (e.g. "5m") the metadata returned
+	// by /construction/metadata may be when it is provided to
+	// /construction/payloads before its fee is re-estimated.
+	// If not set, the age of metadata is not checked.
+	MaxMetadataAgeEnv = "MAX_METADATA_AGE"
+
+	// ConfirmationTargetEnv is the environment variable
+	// read to determine the number of blocks (between 1 and
+	// 1008) a transaction should be included within when
+	// estimating fees in /construction/metadata. If not set,
+	// transactions target inclusion within 2 blocks.
+	ConfirmationTargetEnv = "CONFIRMATION_TARGET"
+
+	// MinFeeRateEnv is the environment variable read to
+	// determine the minimum fee rate (in coins per kB, like
+	// whived's -minrelaytxfee) suggested by /construction/metadata.
+	// This is also used when whived has no fee estimate (which
+	// is common on testnet). If not set, 0.00001 is used.
+	MinFeeRateEnv = "MIN_FEE_RATE"
+
+	// DegradeToOfflineEnv is the environment variable read
+	// to determine the number of consecutive requests to whived
+	// that must fail before only offline endpoints are served
+	// (until a request to whived succeeds). If not set, online
+	// endpoints are always served.
+	DegradeToOfflineEnv = "DEGRADE_TO_OFFLINE"
+
+	// HashCheckIntervalEnv is the environment variable read to
+	// determine how often (e.g. "10m") the hashes of a sample of
+	// recently indexed blocks are compared with the hashes of the
+	// blocks at the same heights on whived's best chain. If not
+	// set, block hashes are not checked.
+	HashCheckIntervalEnv = "HASH_CHECK_INTERVAL"
+
+	// HTTPReadTimeoutEnv is the environment variable read to
+	// determine the maximum duration (e.g. "10s") for reading
+	// an entire request, including the body.
+	HTTPReadTimeoutEnv = "HTTP_READ_TIMEOUT"
+
+	// HTTPWriteTimeoutEnv is the environment variable read to
+	// determine the maximum duration before timing out writes
+	// of a response. Slow endpoints (e.g. /block of a large
+	// block) may need more time than the default.
+	HTTPWriteTimeoutEnv = "HTTP_WRITE_TIMEOUT"
+
+	// HTTPIdleTimeoutEnv is the environment variable read to
+	// determine the maximum amount of time to wait for the next
+	// request when keep-alives are enabled.
+	HTTPIdleTimeoutEnv = "HTTP_IDLE_TIMEOUT"
+
+	// ScriptTypesEnv is the environment variable read
+	// to determine if the metadata of each OUTPUT operation
+	// should include the type of its locking script (e.g.
+	// "p2wpkh" or "op_return"). If not set, script types
+	// are not included.
+	ScriptTypesEnv = "SCRIPT_TYPES"
+
+	// ReadyBlocksBehindEnv is the environment variable
+	// read to determine how many blocks the indexer may
+	// be behind whived's tip and still be reported as
+	// ready by /health/ready.
+	ReadyBlocksBehindEnv = "READY_BLOCKS_BEHIND"
+
+	// GzipEnv is the environment variable read
+	// to determine if HTTP responses should be
+	// gzip compressed.
+	GzipEnv = "HTTP_GZIP"
+
+	// GzipMinSizeEnv is the environment variable
+	// read to determine the minimum size (in bytes)
+	// of a response before it is compressed.
+	GzipMinSizeEnv = "HTTP_GZIP_MIN_SIZE"
 )
 
 // PruningConfiguration is the configuration to
 // use for pruning in the indexer.
 type PruningConfiguration struct {
-	Frequency time.Duration
-	Depth     int64
-	MinHeight int64
+	Frequency  time.Duration
+	Depth      int64
+	MinHeight  int64
+	ReorgDepth int64
+}
+
+// CompressionConfiguration is the configuration to
+// use for compressing HTTP responses.
+type CompressionConfiguration struct {
+	MinSize int
 }
 
 // Configuration determines how
 type Configuration struct {
-	Mode                   Mode
-	Network                *types.NetworkIdentifier
-	Params                 *chaincfg.Params
-	Currency               *types.Currency
-	GenesisBlockIdentifier *types.BlockIdentifier
-	Port                   int
-	RPCPort                int
-	ConfigPath             string
-	Pruning                *PruningConfiguration
-	IndexerPath            string
-	WhivedPath               string
-	Compressors            []*encoder.CompressorEntry
+	Mode                    Mode
+	Network                 *types.NetworkIdentifier
+	Params                  *chaincfg.Params
+	Currency                *types.Currency
+	GenesisBlockIdentifier  *types.BlockIdentifier
+	Port                    int
+	StrictPort              bool
+	RPCPort                 int
+	ConfigPath              string
+	Pruning                 *PruningConfiguration
+	IndexerPath             string
+	WhivedPath              string
+	WhivedBinaryPath        string
+	Compressors             []*encoder.CompressorEntry
+	MaxBufferedBlocks       int64
+	SyncConcurrency         int64
+	MinFreeDisk             uint64
+	BlockRetryLimit         int
+	BlockRetryDelay         time.Duration
+	MaxTxOutputs            int
+	ValidateNetwork         bool
+	NodeWaitTimeout         time.Duration
+	MinPeersAtStartup       int
+	IncludeMempool          bool
+	DustRelayFee            int64
+	RPCBatchWindow          time.Duration
+	RPCConcurrency          int64
+	RPCMaxResponseBytes     int64
+	RPCCookiePath           string
+	RPCSocket               string
+	LogLevel                zapcore.Level
+	TipReorgCheck           bool
+	BlockOperationTypes     []string
+	BlockInlineTransactions int
+	ConstructionLimit       int64
+	FinalityDepth           int64
+	StorageShards           int
+	MaxIndexHeight          int64
+	BalanceCoalesce         bool
+	WarmCacheSize           int
+	StrictOperations        bool
+	MetricsEnabled          bool
+	MaxMetadataAge          time.Duration
+	ConfirmationTarget      int64
+	MinFeeRate              float64
+	DegradeThreshold        int
+	HashCheckInterval       time.Duration
+	HTTPReadTimeout         time.Duration
+	HTTPWriteTimeout        time.Duration
+	HTTPIdleTimeout         time.Duration
+	ScriptTypes             bool
+	ReadyBlocksBehind       int64
+	Compression             *CompressionConfiguration
+
+	// Warnings are logged once logging is configured.
+	Warnings []string
 }
 
 // LoadConfiguration attempts to create a new Configuration
-// using the ENVs in the environment.
+// using the ENVs in the environment. Persistent data is stored
+// in baseDirectory unless DATA_DIR is set.
 func LoadConfiguration(baseDirectory string) (*Configuration, error) {
+	if dataDirectory := os.Getenv(DataDirectoryEnv); len(dataDirectory) > 0 {
+		baseDirectory = dataDirectory
+	}
+
+	appDirectory := AppDirectory
+	if appDirectoryValue := os.Getenv(AppDirectoryEnv); len(appDirectoryValue) > 0 {
+		appDirectory = appDirectoryValue
+	}
+
 	config := &Configuration{}
 	config.Pruning = &PruningConfiguration{
-		Frequency: pruneFrequency,
-		Depth:     pruneDepth,
-		MinHeight: minPruneHeight,
+		Frequency:  pruneFrequency,
+		Depth:      pruneDepth,
+		MinHeight:  minPruneHeight,
+		ReorgDepth: pruneReorgDepth,
 	}
 
 	modeValue := Mode(os.Getenv(ModeEnv))
@@ -147,6 +621,8 @@ func LoadConfiguration(baseDirectory string) (*Configuration, error) {
 		if err := ensurePathExists(config.WhivedPath); err != nil {
 			return nil, fmt.Errorf("%w: unable to create whived path", err)
 		}
+
+		config.WhivedBinaryPath = path.Join(appDirectory, whivedBinary)
 	case Offline:
 		config.Mode = Offline
 	case "":
@@ -165,12 +641,12 @@ func LoadConfiguration(baseDirectory string) (*Configuration, error) {
 		config.GenesisBlockIdentifier = whive.MainnetGenesisBlockIdentifier
 		config.Params = whive.MainnetParams
 		config.Currency = whive.MainnetCurrency
-		config.ConfigPath = mainnetConfigPath
+		config.ConfigPath = path.Join(appDirectory, mainnetConfigFile)
 		config.RPCPort = mainnetRPCPort
 		config.Compressors = []*encoder.CompressorEntry{
 			{
 				Namespace:      transactionNamespace,
-				DictionaryPath: mainnetTransactionDictionary,
+				DictionaryPath: path.Join(appDirectory, mainnetTransactionDictionary),
 			},
 		}
 	case Testnet:
@@ -181,12 +657,31 @@ func LoadConfiguration(baseDirectory string) (*Configuration, error) {
 		config.GenesisBlockIdentifier = whive.TestnetGenesisBlockIdentifier
 		config.Params = whive.TestnetParams
 		config.Currency = whive.TestnetCurrency
-		config.ConfigPath = testnetConfigPath
+		config.ConfigPath = path.Join(appDirectory, testnetConfigFile)
 		config.RPCPort = testnetRPCPort
 		config.Compressors = []*encoder.CompressorEntry{
 			{
 				Namespace:      transactionNamespace,
-				DictionaryPath: testnetTransactionDictionary,
+				DictionaryPath: path.Join(appDirectory, testnetTransactionDictionary),
+			},
+		}
+	case Regtest:
+		config.Network = &types.NetworkIdentifier{
+			Blockchain: whive.Blockchain,
+			Network:    whive.RegtestNetwork,
+		}
+		config.GenesisBlockIdentifier = whive.RegtestGenesisBlockIdentifier
+		config.Params = whive.RegtestParams
+		config.Currency = whive.RegtestCurrency
+		config.ConfigPath = path.Join(appDirectory, regtestConfigFile)
+		config.RPCPort = regtestRPCPort
+
+		// Regtest transactions are similar enough to
+		// testnet transactions to share a dictionary.
+		config.Compressors = []*encoder.CompressorEntry{
+			{
+				Namespace:      transactionNamespace,
+				DictionaryPath: path.Join(appDirectory, testnetTransactionDictionary),
 			},
 		}
 	case "":
@@ -195,6 +690,14 @@ func LoadConfiguration(baseDirectory string) (*Configuration, error) {
 		return nil, fmt.Errorf("%s is not a valid network", networkValue)
 	}
 
+	// whived is only launched in online mode, so we
+	// don't need its configuration file offline.
+	if config.Mode == Online {
+		if err := configFileChecker(config.ConfigPath); err != nil {
+			return nil, err
+		}
+	}
+
 	portValue := os.Getenv(PortEnv)
 	if len(portValue) == 0 {
 		return nil, errors.New("PORT must be populated")
@@ -206,9 +709,812 @@ func LoadConfiguration(baseDirectory string) (*Configuration, error) {
 	}
 	config.Port = port
 
+	strictPortValue := os.Getenv(StrictPortEnv)
+	if len(strictPortValue) > 0 {
+		strictPort, err := strconv.ParseBool(strictPortValue)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse strict port %s", err, strictPortValue)
+		}
+		config.StrictPort = strictPort
+	}
+
+	portWarning, err := checkPrivilegedPort(config.Port, config.StrictPort)
+	if err != nil {
+		return nil, err
+	}
+	if len(portWarning) > 0 {
+		config.Warnings = append(config.Warnings, portWarning)
+	}
+
+	rpcPortValue := os.Getenv(RPCPortEnv)
+	if len(rpcPortValue) > 0 {
+		rpcPort, err := strconv.Atoi(rpcPortValue)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse RPC port %s", err, rpcPortValue)
+		}
+
+		if rpcPort <= 0 {
+			return nil, fmt.Errorf("RPC port %d must be positive", rpcPort)
+		}
+		config.RPCPort = rpcPort
+	}
+
+	config.MaxBufferedBlocks = maxBufferedBlocks
+	maxBufferedBlocksValue := os.Getenv(MaxBufferedBlocksEnv)
+	if len(maxBufferedBlocksValue) > 0 {
+		maxBuffered, err := strconv.ParseInt(maxBufferedBlocksValue, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"%w: unable to parse max buffered blocks %s",
+				err,
+				maxBufferedBlocksValue,
+			)
+		}
+
+		if maxBuffered < 0 {
+			return nil, fmt.Errorf("max buffered blocks %d must not be negative", maxBuffered)
+		}
+		config.MaxBufferedBlocks = maxBuffered
+	}
+
+	syncConcurrencyValue := os.Getenv(SyncConcurrencyEnv)
+	if len(syncConcurrencyValue) > 0 {
+		syncConcurrency, err := strconv.ParseInt(syncConcurrencyValue, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse sync concurrency %s", err, syncConcurrencyValue)
+		}
+
+		if syncConcurrency <= 0 {
+			return nil, fmt.Errorf("sync concurrency %d must be positive", syncConcurrency)
+		}
+		config.SyncConcurrency = syncConcurrency
+	}
+
+	minFreeDiskValue := os.Getenv(MinFreeDiskEnv)
+	if len(minFreeDiskValue) > 0 {
+		minFreeDisk, err := strconv.ParseUint(minFreeDiskValue, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse min free disk %s", err, minFreeDiskValue)
+		}
+		config.MinFreeDisk = minFreeDisk * bytesInMB
+	}
+
+	config.BlockRetryLimit = blockRetryLimit
+	blockRetryLimitValue := os.Getenv(BlockRetryLimitEnv)
+	if len(blockRetryLimitValue) > 0 {
+		retryLimit, err := strconv.Atoi(blockRetryLimitValue)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse block retry limit %s", err, blockRetryLimitValue)
+		}
+
+		if retryLimit < 0 {
+			return nil, fmt.Errorf("block retry limit %d must not be negative", retryLimit)
+		}
+		config.BlockRetryLimit = retryLimit
+	}
+
+	config.BlockRetryDelay = blockRetryDelay
+	blockRetryDelayValue := os.Getenv(BlockRetryDelayEnv)
+	if len(blockRetryDelayValue) > 0 {
+		retryDelay, err := time.ParseDuration(blockRetryDelayValue)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse block retry delay %s", err, blockRetryDelayValue)
+		}
+
+		if retryDelay < 0 {
+			return nil, fmt.Errorf("block retry delay %s must not be negative", retryDelay)
+		}
+		config.BlockRetryDelay = retryDelay
+	}
+
+	maxTxOutputsValue := os.Getenv(MaxTxOutputsEnv)
+	if len(maxTxOutputsValue) > 0 {
+		maxTxOutputs, err := strconv.Atoi(maxTxOutputsValue)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse max tx outputs %s", err, maxTxOutputsValue)
+		}
+
+		if maxTxOutputs < 0 {
+			return nil, fmt.Errorf("max tx outputs %d must not be negative", maxTxOutputs)
+		}
+		config.MaxTxOutputs = maxTxOutputs
+	}
+
+	config.ValidateNetwork = true
+	validateNetworkValue := os.Getenv(ValidateNetworkEnv)
+	if len(validateNetworkValue) > 0 {
+		validateNetwork, err := strconv.ParseBool(validateNetworkValue)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse validate network %s", err, validateNetworkValue)
+		}
+		config.ValidateNetwork = validateNetwork
+	}
+
+	nodeWaitTimeoutValue := os.Getenv(NodeWaitTimeoutEnv)
+	if len(nodeWaitTimeoutValue) > 0 {
+		timeout, err := time.ParseDuration(nodeWaitTimeoutValue)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"%w: unable to parse node wait timeout %s",
+				err,
+				nodeWaitTimeoutValue,
+			)
+		}
+
+		if timeout < 0 {
+			return nil, fmt.Errorf("node wait timeout %s must not be negative", timeout)
+		}
+		config.NodeWaitTimeout = timeout
+	}
+
+	minPeersAtStartupValue := os.Getenv(MinPeersAtStartupEnv)
+	if len(minPeersAtStartupValue) > 0 {
+		minPeers, err := strconv.Atoi(minPeersAtStartupValue)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"%w: unable to parse min peers at startup %s",
+				err,
+				minPeersAtStartupValue,
+			)
+		}
+
+		if minPeers < 0 {
+			return nil, fmt.Errorf("min peers at startup %d must not be negative", minPeers)
+		}
+		config.MinPeersAtStartup = minPeers
+	}
+
+	includeMempoolValue := os.Getenv(IncludeMempoolEnv)
+	if len(includeMempoolValue) > 0 {
+		includeMempool, err := strconv.ParseBool(includeMempoolValue)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse include mempool %s", err, includeMempoolValue)
+		}
+		config.IncludeMempool = includeMempool
+	}
+
+	dustRelayFeeValue := os.Getenv(DustRelayFeeEnv)
+	if len(dustRelayFeeValue) > 0 {
+		dustRelayFee, err := strconv.ParseInt(dustRelayFeeValue, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse dust relay fee %s", err, dustRelayFeeValue)
+		}
+
+		if dustRelayFee < 0 {
+			return nil, fmt.Errorf("dust relay fee %d must not be negative", dustRelayFee)
+		}
+		config.DustRelayFee = dustRelayFee
+	}
+
+	rpcBatchWindowValue := os.Getenv(RPCBatchWindowEnv)
+	if len(rpcBatchWindowValue) > 0 {
+		window, err := time.ParseDuration(rpcBatchWindowValue)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"%w: unable to parse RPC batch window %s",
+				err,
+				rpcBatchWindowValue,
+			)
+		}
+
+		if window < 0 {
+			return nil, fmt.Errorf("RPC batch window %s must not be negative", window)
+		}
+		config.RPCBatchWindow = window
+	}
+
+	rpcMaxConcurrencyValue := os.Getenv(RPCMaxConcurrencyEnv)
+	if len(rpcMaxConcurrencyValue) > 0 {
+		concurrency, err := strconv.ParseInt(rpcMaxConcurrencyValue, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"%w: unable to parse RPC max concurrency %s",
+				err,
+				rpcMaxConcurrencyValue,
+			)
+		}
+
+		if concurrency < 0 {
+			return nil, fmt.Errorf("RPC max concurrency %d must not be negative", concurrency)
+		}
+		config.RPCConcurrency = concurrency
+	}
+
+	rpcMaxResponseBytesValue := os.Getenv(RPCMaxResponseBytesEnv)
+	if len(rpcMaxResponseBytesValue) > 0 {
+		maxResponseBytes, err := strconv.ParseInt(rpcMaxResponseBytesValue, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"%w: unable to parse RPC max response bytes %s",
+				err,
+				rpcMaxResponseBytesValue,
+			)
+		}
+
+		if maxResponseBytes <= 0 {
+			return nil, fmt.Errorf("RPC max response bytes %d must be positive", maxResponseBytes)
+		}
+		config.RPCMaxResponseBytes = maxResponseBytes
+	}
+
+	// The cookie file is written by whived when it starts,
+	// so it may not exist yet.
+	config.RPCCookiePath = os.Getenv(RPCCookiePathEnv)
+
+	rpcSocketValue := os.Getenv(RPCSocketEnv)
+	if len(rpcSocketValue) > 0 {
+		rpcSocket, err := url.Parse(rpcSocketValue)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse RPC socket %s", err, rpcSocketValue)
+		}
+
+		if rpcSocket.Scheme != "unix" || len(rpcSocket.Host) > 0 || !path.IsAbs(rpcSocket.Path) {
+			return nil, fmt.Errorf(
+				"RPC socket %s must be a unix:// URL with an absolute path",
+				rpcSocketValue,
+			)
+		}
+		config.RPCSocket = rpcSocket.Path
+	}
+
+	tipReorgCheckValue := os.Getenv(TipReorgCheckEnv)
+	if len(tipReorgCheckValue) > 0 {
+		tipReorgCheck, err := strconv.ParseBool(tipReorgCheckValue)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse tip reorg check %s", err, tipReorgCheckValue)
+		}
+		config.TipReorgCheck = tipReorgCheck
+	}
+
+	blockOperationTypesValue := os.Getenv(BlockOperationTypesEnv)
+	if len(blockOperationTypesValue) > 0 {
+		for _, opType := range strings.Split(blockOperationTypesValue, ",") {
+			opType = strings.TrimSpace(opType)
+			if !utils.ContainsString(whive.OperationTypes, opType) {
+				return nil, fmt.Errorf("operation type %s is not supported", opType)
+			}
+			config.BlockOperationTypes = append(config.BlockOperationTypes, opType)
+		}
+	}
+
+	blockInlineTransactionsValue := os.Getenv(BlockInlineTransactionsEnv)
+	if len(blockInlineTransactionsValue) > 0 {
+		inlineTransactions, err := strconv.Atoi(blockInlineTransactionsValue)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"%w: unable to parse block inline transactions %s",
+				err,
+				blockInlineTransactionsValue,
+			)
+		}
+
+		if inlineTransactions <= 0 {
+			return nil, fmt.Errorf(
+				"block inline transactions %d must be positive",
+				inlineTransactions,
+			)
+		}
+		config.BlockInlineTransactions = inlineTransactions
+	}
+
+	maxConcurrentConstructionValue := os.Getenv(MaxConcurrentConstructionEnv)
+	if len(maxConcurrentConstructionValue) > 0 {
+		maxConcurrent, err := strconv.ParseInt(maxConcurrentConstructionValue, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"%w: unable to parse max concurrent construction %s",
+				err,
+				maxConcurrentConstructionValue,
+			)
+		}
+
+		if maxConcurrent < 0 {
+			return nil, fmt.Errorf(
+				"max concurrent construction %d must not be negative",
+				maxConcurrent,
+			)
+		}
+		config.ConstructionLimit = maxConcurrent
+	}
+
+	config.FinalityDepth = finalityDepth
+	finalityDepthValue := os.Getenv(FinalityDepthEnv)
+	if len(finalityDepthValue) > 0 {
+		depth, err := strconv.ParseInt(finalityDepthValue, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse finality depth %s", err, finalityDepthValue)
+		}
+
+		if depth <= 0 {
+			return nil, fmt.Errorf("finality depth %d must be positive", depth)
+		}
+		config.FinalityDepth = depth
+	}
+
+	storageShardsValue := os.Getenv(StorageShardsEnv)
+	if len(storageShardsValue) > 0 {
+		shards, err := strconv.Atoi(storageShardsValue)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse storage shards %s", err, storageShardsValue)
+		}
+
+		if shards <= 0 {
+			return nil, fmt.Errorf("storage shards %d must be positive", shards)
+		}
+		config.StorageShards = shards
+	}
+
+	maxIndexHeightValue := os.Getenv(MaxIndexHeightEnv)
+	if len(maxIndexHeightValue) > 0 {
+		maxIndexHeight, err := strconv.ParseInt(maxIndexHeightValue, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse max index height %s", err, maxIndexHeightValue)
+		}
+
+		if maxIndexHeight <= 0 {
+			return nil, fmt.Errorf("max index height %d must be positive", maxIndexHeight)
+		}
+		config.MaxIndexHeight = maxIndexHeight
+	}
+
+	pruningDepthValue := os.Getenv(PruningDepthEnv)
+	if len(pruningDepthValue) > 0 {
+		depth, err := strconv.ParseInt(pruningDepthValue, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse pruning depth %s", err, pruningDepthValue)
+		}
+
+		if depth < minPruneDepth {
+			return nil, fmt.Errorf("pruning depth %d must be at least %d", depth, minPruneDepth)
+		}
+		config.Pruning.Depth = depth
+	}
+
+	pruningFrequencyValue := os.Getenv(PruningFrequencyEnv)
+	if len(pruningFrequencyValue) > 0 {
+		frequency, err := time.ParseDuration(pruningFrequencyValue)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse pruning frequency %s", err, pruningFrequencyValue)
+		}
+
+		if frequency <= 0 {
+			return nil, fmt.Errorf("pruning frequency %s must be positive", frequency)
+		}
+		config.Pruning.Frequency = frequency
+	}
+
+	pruneReorgDepthValue := os.Getenv(PruneReorgDepthEnv)
+	if len(pruneReorgDepthValue) > 0 {
+		reorgDepth, err := strconv.ParseInt(pruneReorgDepthValue, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse prune reorg depth %s", err, pruneReorgDepthValue)
+		}
+
+		if reorgDepth < 0 {
+			return nil, fmt.Errorf("prune reorg depth %d must not be negative", reorgDepth)
+		}
+		config.Pruning.ReorgDepth = reorgDepth
+	}
+
+	pruningDisabledValue := os.Getenv(PruningDisabledEnv)
+	if len(pruningDisabledValue) > 0 {
+		pruningDisabled, err := strconv.ParseBool(pruningDisabledValue)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse pruning disabled %s", err, pruningDisabledValue)
+		}
+
+		if pruningDisabled {
+			config.Pruning = nil
+		}
+	}
+
+	balanceCoalesceValue := os.Getenv(BalanceCoalesceEnv)
+	if len(balanceCoalesceValue) > 0 {
+		balanceCoalesce, err := strconv.ParseBool(balanceCoalesceValue)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"%w: unable to parse balance coalesce %s",
+				err,
+				balanceCoalesceValue,
+			)
+		}
+		config.BalanceCoalesce = balanceCoalesce
+	}
+
+	warmCacheValue := os.Getenv(WarmCacheEnv)
+	if len(warmCacheValue) > 0 {
+		warmCacheSize, err := strconv.Atoi(warmCacheValue)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse warm cache %s", err, warmCacheValue)
+		}
+
+		if warmCacheSize <= 0 {
+			return nil, fmt.Errorf("warm cache %d must be positive", warmCacheSize)
+		}
+		config.WarmCacheSize = warmCacheSize
+	}
+
+	strictOperationsValue := os.Getenv(StrictOperationsEnv)
+	if len(strictOperationsValue) > 0 {
+		strictOperations, err := strconv.ParseBool(strictOperationsValue)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"%w: unable to parse strict operations %s",
+				err,
+				strictOperationsValue,
+			)
+		}
+		config.StrictOperations = strictOperations
+	}
+
+	metricsEnabledValue := os.Getenv(MetricsEnabledEnv)
+	if len(metricsEnabledValue) > 0 {
+		metricsEnabled, err := strconv.ParseBool(metricsEnabledValue)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"%w: unable to parse metrics enabled %s",
+				err,
+				metricsEnabledValue,
+			)
+		}
+		config.MetricsEnabled = metricsEnabled
+	}
+
+	maxMetadataAgeValue := os.Getenv(MaxMetadataAgeEnv)
+	if len(maxMetadataAgeValue) > 0 {
+		maxMetadataAge, err := time.ParseDuration(maxMetadataAgeValue)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"%w: unable to parse max metadata age %s",
+				err,
+				maxMetadataAgeValue,
+			)
+		}
+
+		if maxMetadataAge <= 0 {
+			return nil, fmt.Errorf("max metadata age %s must be positive", maxMetadataAge)
+		}
+		config.MaxMetadataAge = maxMetadataAge
+	}
+
+	confirmationTargetValue := os.Getenv(ConfirmationTargetEnv)
+	if len(confirmationTargetValue) > 0 {
+		confirmationTarget, err := strconv.ParseInt(confirmationTargetValue, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"%w: unable to parse confirmation target %s",
+				err,
+				confirmationTargetValue,
+			)
+		}
+
+		if confirmationTarget < 1 || confirmationTarget > maxConfirmationTarget {
+			return nil, fmt.Errorf(
+				"confirmation target %d must be between 1 and %d",
+				confirmationTarget,
+				maxConfirmationTarget,
+			)
+		}
+		config.ConfirmationTarget = confirmationTarget
+	}
+
+	minFeeRateValue := os.Getenv(MinFeeRateEnv)
+	if len(minFeeRateValue) > 0 {
+		minFeeRate, err := strconv.ParseFloat(minFeeRateValue, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse min fee rate %s", err, minFeeRateValue)
+		}
+
+		if minFeeRate <= 0 {
+			return nil, fmt.Errorf("min fee rate %s must be positive", minFeeRateValue)
+		}
+		config.MinFeeRate = minFeeRate
+	}
+
+	degradeToOfflineValue := os.Getenv(DegradeToOfflineEnv)
+	if len(degradeToOfflineValue) > 0 {
+		threshold, err := strconv.Atoi(degradeToOfflineValue)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"%w: unable to parse degrade to offline threshold %s",
+				err,
+				degradeToOfflineValue,
+			)
+		}
+
+		if threshold <= 0 {
+			return nil, fmt.Errorf("degrade to offline threshold %d must be positive", threshold)
+		}
+		config.DegradeThreshold = threshold
+	}
+
+	hashCheckIntervalValue := os.Getenv(HashCheckIntervalEnv)
+	if len(hashCheckIntervalValue) > 0 {
+		hashCheckInterval, err := time.ParseDuration(hashCheckIntervalValue)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"%w: unable to parse hash check interval %s",
+				err,
+				hashCheckIntervalValue,
+			)
+		}
+
+		if hashCheckInterval <= 0 {
+			return nil, fmt.Errorf("hash check interval %s must be positive", hashCheckInterval)
+		}
+		config.HashCheckInterval = hashCheckInterval
+	}
+
+	config.HTTPReadTimeout = httpReadTimeout
+	httpReadTimeoutValue := os.Getenv(HTTPReadTimeoutEnv)
+	if len(httpReadTimeoutValue) > 0 {
+		timeout, err := time.ParseDuration(httpReadTimeoutValue)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse HTTP read timeout %s", err, httpReadTimeoutValue)
+		}
+
+		if timeout <= 0 {
+			return nil, fmt.Errorf("HTTP read timeout %s must be positive", timeout)
+		}
+		config.HTTPReadTimeout = timeout
+	}
+
+	config.HTTPWriteTimeout = httpWriteTimeout
+	httpWriteTimeoutValue := os.Getenv(HTTPWriteTimeoutEnv)
+	if len(httpWriteTimeoutValue) > 0 {
+		timeout, err := time.ParseDuration(httpWriteTimeoutValue)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse HTTP write timeout %s", err, httpWriteTimeoutValue)
+		}
+
+		if timeout <= 0 {
+			return nil, fmt.Errorf("HTTP write timeout %s must be positive", timeout)
+		}
+		config.HTTPWriteTimeout = timeout
+	}
+
+	config.HTTPIdleTimeout = httpIdleTimeout
+	httpIdleTimeoutValue := os.Getenv(HTTPIdleTimeoutEnv)
+	if len(httpIdleTimeoutValue) > 0 {
+		timeout, err := time.ParseDuration(httpIdleTimeoutValue)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse HTTP idle timeout %s", err, httpIdleTimeoutValue)
+		}
+
+		if timeout <= 0 {
+			return nil, fmt.Errorf("HTTP idle timeout %s must be positive", timeout)
+		}
+		config.HTTPIdleTimeout = timeout
+	}
+
+	scriptTypesValue := os.Getenv(ScriptTypesEnv)
+	if len(scriptTypesValue) > 0 {
+		scriptTypes, err := strconv.ParseBool(scriptTypesValue)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse script types %s", err, scriptTypesValue)
+		}
+		config.ScriptTypes = scriptTypes
+	}
+
+	config.ReadyBlocksBehind = readyBlocksBehind
+	readyBlocksBehindValue := os.Getenv(ReadyBlocksBehindEnv)
+	if len(readyBlocksBehindValue) > 0 {
+		blocksBehind, err := strconv.ParseInt(readyBlocksBehindValue, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"%w: unable to parse ready blocks behind %s",
+				err,
+				readyBlocksBehindValue,
+			)
+		}
+
+		if blocksBehind < 0 {
+			return nil, fmt.Errorf("ready blocks behind %d must not be negative", blocksBehind)
+		}
+		config.ReadyBlocksBehind = blocksBehind
+	}
+
+	logLevelValue := os.Getenv(LogLevelEnv)
+	if len(logLevelValue) > 0 {
+		logLevel, err := parseLogLevel(logLevelValue)
+		if err != nil {
+			return nil, err
+		}
+		config.LogLevel = logLevel
+	}
+
+	dictionaryDirectoryValue := os.Getenv(DictionaryDirectoryEnv)
+	if len(dictionaryDirectoryValue) > 0 {
+		compressors, err := loadDictionaryDirectory(dictionaryDirectoryValue, config.Compressors)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to load dictionaries from %s", err, dictionaryDirectoryValue)
+		}
+		config.Compressors = compressors
+	}
+
+	compression, err := loadCompressionConfiguration()
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to load compression configuration", err)
+	}
+	config.Compression = compression
+
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: invalid configuration", err)
+	}
+
 	return config, nil
 }
 
+// networkParams returns the *chaincfg.Params
+// of the Whive network with name network.
+func networkParams(network string) (*chaincfg.Params, bool) {
+	switch network {
+	case whive.MainnetNetwork:
+		return whive.MainnetParams, true
+	case whive.TestnetNetwork:
+		return whive.TestnetParams, true
+	case whive.RegtestNetwork:
+		return whive.RegtestParams, true
+	default:
+		return nil, false
+	}
+}
+
+// Validate returns an error if the Configuration is
+// not usable. LoadConfiguration always returns a valid
+// Configuration, so this is only needed when a
+// Configuration is constructed directly.
+func (c *Configuration) Validate() error {
+	switch c.Mode {
+	case Online:
+		if len(c.IndexerPath) == 0 {
+			return errors.New("indexer path must be populated in online mode")
+		}
+
+		if len(c.WhivedPath) == 0 {
+			return errors.New("whived path must be populated in online mode")
+		}
+	case Offline:
+	case "":
+		return errors.New("mode must be populated")
+	default:
+		return fmt.Errorf("%s is not a valid mode", c.Mode)
+	}
+
+	if c.Network == nil {
+		return errors.New("network must be populated")
+	}
+
+	params, ok := networkParams(c.Network.Network)
+	if !ok {
+		return fmt.Errorf("%s is not a valid network", c.Network.Network)
+	}
+
+	if c.Params != params {
+		return fmt.Errorf("params do not match network %s", c.Network.Network)
+	}
+
+	if c.Port <= 0 {
+		return fmt.Errorf("port %d must be positive", c.Port)
+	}
+
+	return nil
+}
+
+// loadCompressionConfiguration returns the *CompressionConfiguration
+// specified by the environment. If compression is not enabled, nil
+// is returned.
+func loadCompressionConfiguration() (*CompressionConfiguration, error) {
+	gzipValue := os.Getenv(GzipEnv)
+	if len(gzipValue) == 0 {
+		return nil, nil
+	}
+
+	enabled, err := strconv.ParseBool(gzipValue)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to parse %s %s", err, GzipEnv, gzipValue)
+	}
+
+	if !enabled {
+		return nil, nil
+	}
+
+	compression := &CompressionConfiguration{
+		MinSize: gzipMinSize,
+	}
+
+	minSizeValue := os.Getenv(GzipMinSizeEnv)
+	if len(minSizeValue) == 0 {
+		return compression, nil
+	}
+
+	minSize, err := strconv.Atoi(minSizeValue)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to parse %s %s", err, GzipMinSizeEnv, minSizeValue)
+	}
+
+	if minSize < 0 {
+		return nil, fmt.Errorf("%s %d must not be negative", GzipMinSizeEnv, minSize)
+	}
+	compression.MinSize = minSize
+
+	return compression, nil
+}
+
+// zstdDictionaryMagic is the magic number (little
+// endian) that begins every zstd dictionary.
+var zstdDictionaryMagic = []byte{0x37, 0xa4, 0x30, 0xec}
+
+// loadDictionaryDirectory adds a *encoder.CompressorEntry to
+// compressors for each "<namespace>.zstd" dictionary in dir.
+// Entries in compressors for the same namespace are replaced.
+func loadDictionaryDirectory(
+	dir string,
+	compressors []*encoder.CompressorEntry,
+) ([]*encoder.CompressorEntry, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to read dictionary directory", err)
+	}
+
+	entries := map[string]*encoder.CompressorEntry{}
+	for _, entry := range compressors {
+		entries[entry.Namespace] = entry
+	}
+
+	namespaces := []string{}
+	for _, file := range files {
+		if file.IsDir() || path.Ext(file.Name()) != dictionaryExtension {
+			continue
+		}
+
+		dictionaryPath := path.Join(dir, file.Name())
+		if err := checkDictionary(dictionaryPath); err != nil {
+			return nil, err
+		}
+
+		namespace := strings.TrimSuffix(file.Name(), dictionaryExtension)
+		entries[namespace] = &encoder.CompressorEntry{
+			Namespace:      namespace,
+			DictionaryPath: dictionaryPath,
+		}
+		namespaces = append(namespaces, namespace)
+	}
+
+	// Existing entries keep their position so that
+	// the order of compressors is deterministic.
+	loaded := []*encoder.CompressorEntry{}
+	for _, entry := range compressors {
+		loaded = append(loaded, entries[entry.Namespace])
+		delete(entries, entry.Namespace)
+	}
+
+	for _, namespace := range namespaces {
+		if entry, ok := entries[namespace]; ok {
+			loaded = append(loaded, entry)
+		}
+	}
+
+	return loaded, nil
+}
+
+// checkDictionary returns an error if the file
+// at dictionaryPath is not a zstd dictionary.
+func checkDictionary(dictionaryPath string) error {
+	f, err := os.Open(dictionaryPath) // #nosec G304
+	if err != nil {
+		return fmt.Errorf("%w: unable to open dictionary %s", err, dictionaryPath)
+	}
+	defer f.Close()
+
+	magic := make([]byte, len(zstdDictionaryMagic))
+	if _, err := io.ReadFull(f, magic); err != nil || !bytes.Equal(magic, zstdDictionaryMagic) {
+		return fmt.Errorf("%s is not a zstd dictionary", dictionaryPath)
+	}
+
+	return nil
+}
+
 // ensurePathsExist directories along
 // a path if they do not exist.
 func ensurePathExists(path string) error {
@@ -218,3 +1524,65 @@ func ensurePathExists(path string) error {
 
 	return nil
 }
+
+// configFileChecker is overridden in tests (where
+// the whived configuration files don't exist).
+var configFileChecker = checkConfigFile
+
+// checkConfigFile returns a descriptive error if the
+// whived configuration file at configPath doesn't exist
+// or can't be read.
+func checkConfigFile(configPath string) error {
+	info, err := os.Stat(configPath)
+	if err != nil {
+		return fmt.Errorf("%w: unable to find whived configuration file %s", err, configPath)
+	}
+
+	if info.IsDir() {
+		return fmt.Errorf("whived configuration file %s is a directory", configPath)
+	}
+
+	f, err := os.Open(configPath) // #nosec G304
+	if err != nil {
+		return fmt.Errorf("%w: unable to read whived configuration file %s", err, configPath)
+	}
+
+	return f.Close()
+}
+
+// parseLogLevel returns the zapcore.Level of value
+// (which must be debug, info, warn, or error).
+func parseLogLevel(value string) (zapcore.Level, error) {
+	var level zapcore.Level
+	if err := level.UnmarshalText([]byte(value)); err != nil {
+		return level, fmt.Errorf("%w: unable to parse log level %s", err, value)
+	}
+
+	if level < zapcore.DebugLevel || level > zapcore.ErrorLevel {
+		return level, fmt.Errorf("log level %s must be debug, info, warn, or error", value)
+	}
+
+	return level, nil
+}
+
+// geteuid is overridden in tests.
+var geteuid = os.Geteuid
+
+// checkPrivilegedPort returns a warning if port can only be
+// bound with elevated privileges. If strict is true, an error
+// is returned unless we are running as root.
+func checkPrivilegedPort(port int, strict bool) (string, error) {
+	if port >= maxPrivilegedPort {
+		return "", nil
+	}
+
+	if strict && geteuid() != 0 {
+		return "", fmt.Errorf(
+			"PORT %d is privileged and requires running as root (or set %s=false)",
+			port,
+			StrictPortEnv,
+		)
+	}
+
+	return fmt.Sprintf(privilegedPortWarning, port), nil
+}
@@ -16,9 +16,12 @@ package configuration
 
 import (
 	"errors"
+	"fmt"
+	"io/ioutil"
 	"os"
 	"path"
 	"testing"
+	"time"
 
 	"github.com/xyephy/rosetta-whive/whive"
 
@@ -26,13 +29,70 @@ import (
 	"github.com/coinbase/rosetta-sdk-go/types"
 	"github.com/coinbase/rosetta-sdk-go/utils"
 	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zapcore"
 )
 
 func TestLoadConfiguration(t *testing.T) {
 	tests := map[string]struct {
-		Mode    string
-		Network string
-		Port    string
+		Mode                      string
+		Network                   string
+		Port                      string
+		StrictPort                string
+		RPCPort                   string
+		DataDir                   string
+		AppDir                    string
+		DictionaryDir             string
+		MaxBufferedBlocks         string
+		SyncConcurrency           string
+		MinFreeDisk               string
+		BlockRetryLimit           string
+		BlockRetryDelay           string
+		MaxTxOutputs              string
+		ValidateNetwork           string
+		NodeWaitTimeout           string
+		MinPeersAtStartup         string
+		IncludeMempool            string
+		DustRelayFee              string
+		MaxIndexHeight            string
+		PruneReorgDepth           string
+		PruningDisabled           string
+		PruningDepth              string
+		PruningFrequency          string
+		BalanceCoalesce           string
+		WarmCache                 string
+		StrictOperations          string
+		MetricsEnabled            string
+		MaxMetadataAge            string
+		ConfirmationTarget        string
+		MinFeeRate                string
+		DegradeToOffline          string
+		HashCheckInterval         string
+		HTTPReadTimeout           string
+		HTTPWriteTimeout          string
+		ReadyBlocksBehind         string
+		ScriptTypes               string
+		HTTPIdleTimeout           string
+		RPCMaxResponseBytes       string
+		RPCBatchWindow            string
+		RPCMaxConcurrency         string
+		RPCCookiePath             string
+		RPCSocket                 string
+		LogLevel                  string
+		TipReorgCheck             string
+		BlockOperationTypes       string
+		BlockInlineTransactions   string
+		MaxConcurrentConstruction string
+		FinalityDepth             string
+		StorageShards             string
+		Gzip                      string
+		GzipMinSize               string
+
+		// euid defaults to root.
+		euid int
+
+		// The whived configuration file is only
+		// checked when checkConfigFile is true.
+		checkConfigFile bool
 
 		cfg *Configuration
 		err error
@@ -40,19 +100,1604 @@ func TestLoadConfiguration(t *testing.T) {
 		"no envs set": {
 			err: errors.New("MODE must be populated"),
 		},
-		"only mode set": {
-			Mode: string(Online),
-			err:  errors.New("NETWORK must be populated"),
+		"only mode set": {
+			Mode: string(Online),
+			err:  errors.New("NETWORK must be populated"),
+		},
+		"only mode and network set": {
+			Mode:    string(Online),
+			Network: Mainnet,
+			err:     errors.New("PORT must be populated"),
+		},
+		"all set (mainnet)": {
+			Mode:    string(Online),
+			Network: Mainnet,
+			Port:    "1000",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    whive.MainnetNetwork,
+					Blockchain: whive.Blockchain,
+				},
+				Params:                 whive.MainnetParams,
+				Currency:               whive.MainnetCurrency,
+				GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+				Port:                   1000,
+				RPCPort:                mainnetRPCPort,
+				ConfigPath:             path.Join(AppDirectory, mainnetConfigFile),
+				Pruning: &PruningConfiguration{
+					Frequency:  pruneFrequency,
+					Depth:      pruneDepth,
+					MinHeight:  minPruneHeight,
+					ReorgDepth: pruneReorgDepth,
+				},
+				Compressors: []*encoder.CompressorEntry{
+					{
+						Namespace:      transactionNamespace,
+						DictionaryPath: path.Join(AppDirectory, mainnetTransactionDictionary),
+					},
+				},
+				MaxBufferedBlocks: maxBufferedBlocks,
+				BlockRetryLimit:   blockRetryLimit,
+				BlockRetryDelay:   blockRetryDelay,
+				FinalityDepth:     finalityDepth,
+				HTTPReadTimeout:   httpReadTimeout,
+				HTTPWriteTimeout:  httpWriteTimeout,
+				HTTPIdleTimeout:   httpIdleTimeout,
+				ReadyBlocksBehind: readyBlocksBehind,
+				ValidateNetwork:   true,
+			},
+		},
+		"all set (testnet)": {
+			Mode:    string(Online),
+			Network: Testnet,
+			Port:    "1000",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    whive.TestnetNetwork,
+					Blockchain: whive.Blockchain,
+				},
+				Params:                 whive.TestnetParams,
+				Currency:               whive.TestnetCurrency,
+				GenesisBlockIdentifier: whive.TestnetGenesisBlockIdentifier,
+				Port:                   1000,
+				RPCPort:                testnetRPCPort,
+				ConfigPath:             path.Join(AppDirectory, testnetConfigFile),
+				Pruning: &PruningConfiguration{
+					Frequency:  pruneFrequency,
+					Depth:      pruneDepth,
+					MinHeight:  minPruneHeight,
+					ReorgDepth: pruneReorgDepth,
+				},
+				Compressors: []*encoder.CompressorEntry{
+					{
+						Namespace:      transactionNamespace,
+						DictionaryPath: path.Join(AppDirectory, testnetTransactionDictionary),
+					},
+				},
+				MaxBufferedBlocks: maxBufferedBlocks,
+				BlockRetryLimit:   blockRetryLimit,
+				BlockRetryDelay:   blockRetryDelay,
+				FinalityDepth:     finalityDepth,
+				HTTPReadTimeout:   httpReadTimeout,
+				HTTPWriteTimeout:  httpWriteTimeout,
+				HTTPIdleTimeout:   httpIdleTimeout,
+				ReadyBlocksBehind: readyBlocksBehind,
+				ValidateNetwork:   true,
+			},
+		},
+		"all set (regtest)": {
+			Mode:    string(Online),
+			Network: Regtest,
+			Port:    "1000",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    whive.RegtestNetwork,
+					Blockchain: whive.Blockchain,
+				},
+				Params:                 whive.RegtestParams,
+				Currency:               whive.RegtestCurrency,
+				GenesisBlockIdentifier: whive.RegtestGenesisBlockIdentifier,
+				Port:                   1000,
+				RPCPort:                regtestRPCPort,
+				ConfigPath:             path.Join(AppDirectory, regtestConfigFile),
+				Pruning: &PruningConfiguration{
+					Frequency:  pruneFrequency,
+					Depth:      pruneDepth,
+					MinHeight:  minPruneHeight,
+					ReorgDepth: pruneReorgDepth,
+				},
+				Compressors: []*encoder.CompressorEntry{
+					{
+						Namespace:      transactionNamespace,
+						DictionaryPath: path.Join(AppDirectory, testnetTransactionDictionary),
+					},
+				},
+				MaxBufferedBlocks: maxBufferedBlocks,
+				BlockRetryLimit:   blockRetryLimit,
+				BlockRetryDelay:   blockRetryDelay,
+				FinalityDepth:     finalityDepth,
+				HTTPReadTimeout:   httpReadTimeout,
+				HTTPWriteTimeout:  httpWriteTimeout,
+				HTTPIdleTimeout:   httpIdleTimeout,
+				ReadyBlocksBehind: readyBlocksBehind,
+				ValidateNetwork:   true,
+			},
+		},
+		"all set (max buffered blocks)": {
+			Mode:              string(Online),
+			Network:           Testnet,
+			Port:              "1000",
+			MaxBufferedBlocks: "10",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    whive.TestnetNetwork,
+					Blockchain: whive.Blockchain,
+				},
+				Params:                 whive.TestnetParams,
+				Currency:               whive.TestnetCurrency,
+				GenesisBlockIdentifier: whive.TestnetGenesisBlockIdentifier,
+				Port:                   1000,
+				RPCPort:                testnetRPCPort,
+				ConfigPath:             path.Join(AppDirectory, testnetConfigFile),
+				Pruning: &PruningConfiguration{
+					Frequency:  pruneFrequency,
+					Depth:      pruneDepth,
+					MinHeight:  minPruneHeight,
+					ReorgDepth: pruneReorgDepth,
+				},
+				Compressors: []*encoder.CompressorEntry{
+					{
+						Namespace:      transactionNamespace,
+						DictionaryPath: path.Join(AppDirectory, testnetTransactionDictionary),
+					},
+				},
+				MaxBufferedBlocks: 10,
+				BlockRetryLimit:   blockRetryLimit,
+				BlockRetryDelay:   blockRetryDelay,
+				FinalityDepth:     finalityDepth,
+				HTTPReadTimeout:   httpReadTimeout,
+				HTTPWriteTimeout:  httpWriteTimeout,
+				HTTPIdleTimeout:   httpIdleTimeout,
+				ReadyBlocksBehind: readyBlocksBehind,
+				ValidateNetwork:   true,
+			},
+		},
+		"all set (sync concurrency)": {
+			Mode:            string(Online),
+			Network:         Testnet,
+			Port:            "1000",
+			SyncConcurrency: "32",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    whive.TestnetNetwork,
+					Blockchain: whive.Blockchain,
+				},
+				Params:                 whive.TestnetParams,
+				Currency:               whive.TestnetCurrency,
+				GenesisBlockIdentifier: whive.TestnetGenesisBlockIdentifier,
+				Port:                   1000,
+				RPCPort:                testnetRPCPort,
+				ConfigPath:             path.Join(AppDirectory, testnetConfigFile),
+				Pruning: &PruningConfiguration{
+					Frequency:  pruneFrequency,
+					Depth:      pruneDepth,
+					MinHeight:  minPruneHeight,
+					ReorgDepth: pruneReorgDepth,
+				},
+				Compressors: []*encoder.CompressorEntry{
+					{
+						Namespace:      transactionNamespace,
+						DictionaryPath: path.Join(AppDirectory, testnetTransactionDictionary),
+					},
+				},
+				MaxBufferedBlocks: maxBufferedBlocks,
+				SyncConcurrency:   32,
+				BlockRetryLimit:   blockRetryLimit,
+				BlockRetryDelay:   blockRetryDelay,
+				FinalityDepth:     finalityDepth,
+				HTTPReadTimeout:   httpReadTimeout,
+				HTTPWriteTimeout:  httpWriteTimeout,
+				HTTPIdleTimeout:   httpIdleTimeout,
+				ReadyBlocksBehind: readyBlocksBehind,
+				ValidateNetwork:   true,
+			},
+		},
+		"all set (min free disk)": {
+			Mode:        string(Online),
+			Network:     Testnet,
+			Port:        "1000",
+			MinFreeDisk: "512",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    whive.TestnetNetwork,
+					Blockchain: whive.Blockchain,
+				},
+				Params:                 whive.TestnetParams,
+				Currency:               whive.TestnetCurrency,
+				GenesisBlockIdentifier: whive.TestnetGenesisBlockIdentifier,
+				Port:                   1000,
+				RPCPort:                testnetRPCPort,
+				ConfigPath:             path.Join(AppDirectory, testnetConfigFile),
+				Pruning: &PruningConfiguration{
+					Frequency:  pruneFrequency,
+					Depth:      pruneDepth,
+					MinHeight:  minPruneHeight,
+					ReorgDepth: pruneReorgDepth,
+				},
+				Compressors: []*encoder.CompressorEntry{
+					{
+						Namespace:      transactionNamespace,
+						DictionaryPath: path.Join(AppDirectory, testnetTransactionDictionary),
+					},
+				},
+				MaxBufferedBlocks: maxBufferedBlocks,
+				MinFreeDisk:       512 * bytesInMB,
+				BlockRetryLimit:   blockRetryLimit,
+				BlockRetryDelay:   blockRetryDelay,
+				FinalityDepth:     finalityDepth,
+				HTTPReadTimeout:   httpReadTimeout,
+				HTTPWriteTimeout:  httpWriteTimeout,
+				HTTPIdleTimeout:   httpIdleTimeout,
+				ReadyBlocksBehind: readyBlocksBehind,
+				ValidateNetwork:   true,
+			},
+		},
+		"all set (block retries and max tx outputs)": {
+			Mode:            string(Online),
+			Network:         Testnet,
+			Port:            "1000",
+			BlockRetryLimit: "2",
+			BlockRetryDelay: "500ms",
+			MaxTxOutputs:    "100",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    whive.TestnetNetwork,
+					Blockchain: whive.Blockchain,
+				},
+				Params:                 whive.TestnetParams,
+				Currency:               whive.TestnetCurrency,
+				GenesisBlockIdentifier: whive.TestnetGenesisBlockIdentifier,
+				Port:                   1000,
+				RPCPort:                testnetRPCPort,
+				ConfigPath:             path.Join(AppDirectory, testnetConfigFile),
+				Pruning: &PruningConfiguration{
+					Frequency:  pruneFrequency,
+					Depth:      pruneDepth,
+					MinHeight:  minPruneHeight,
+					ReorgDepth: pruneReorgDepth,
+				},
+				Compressors: []*encoder.CompressorEntry{
+					{
+						Namespace:      transactionNamespace,
+						DictionaryPath: path.Join(AppDirectory, testnetTransactionDictionary),
+					},
+				},
+				MaxBufferedBlocks: maxBufferedBlocks,
+				BlockRetryLimit:   2,
+				BlockRetryDelay:   500 * time.Millisecond,
+				FinalityDepth:     finalityDepth,
+				MaxTxOutputs:      100,
+				HTTPReadTimeout:   httpReadTimeout,
+				HTTPWriteTimeout:  httpWriteTimeout,
+				HTTPIdleTimeout:   httpIdleTimeout,
+				ReadyBlocksBehind: readyBlocksBehind,
+				ValidateNetwork:   true,
+			},
+		},
+		"all set (network validation)": {
+			Mode:            string(Online),
+			Network:         Mainnet,
+			Port:            "1000",
+			ValidateNetwork: "false",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    whive.MainnetNetwork,
+					Blockchain: whive.Blockchain,
+				},
+				Params:                 whive.MainnetParams,
+				Currency:               whive.MainnetCurrency,
+				GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+				Port:                   1000,
+				RPCPort:                mainnetRPCPort,
+				ConfigPath:             path.Join(AppDirectory, mainnetConfigFile),
+				Pruning: &PruningConfiguration{
+					Frequency:  pruneFrequency,
+					Depth:      pruneDepth,
+					MinHeight:  minPruneHeight,
+					ReorgDepth: pruneReorgDepth,
+				},
+				Compressors: []*encoder.CompressorEntry{
+					{
+						Namespace:      transactionNamespace,
+						DictionaryPath: path.Join(AppDirectory, mainnetTransactionDictionary),
+					},
+				},
+				MaxBufferedBlocks: maxBufferedBlocks,
+				BlockRetryLimit:   blockRetryLimit,
+				BlockRetryDelay:   blockRetryDelay,
+				FinalityDepth:     finalityDepth,
+				HTTPReadTimeout:   httpReadTimeout,
+				HTTPWriteTimeout:  httpWriteTimeout,
+				HTTPIdleTimeout:   httpIdleTimeout,
+				ReadyBlocksBehind: readyBlocksBehind,
+			},
+		},
+		"all set (node wait timeout)": {
+			Mode:            string(Online),
+			Network:         Mainnet,
+			Port:            "1000",
+			NodeWaitTimeout: "6h",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    whive.MainnetNetwork,
+					Blockchain: whive.Blockchain,
+				},
+				Params:                 whive.MainnetParams,
+				Currency:               whive.MainnetCurrency,
+				GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+				Port:                   1000,
+				RPCPort:                mainnetRPCPort,
+				ConfigPath:             path.Join(AppDirectory, mainnetConfigFile),
+				Pruning: &PruningConfiguration{
+					Frequency:  pruneFrequency,
+					Depth:      pruneDepth,
+					MinHeight:  minPruneHeight,
+					ReorgDepth: pruneReorgDepth,
+				},
+				Compressors: []*encoder.CompressorEntry{
+					{
+						Namespace:      transactionNamespace,
+						DictionaryPath: path.Join(AppDirectory, mainnetTransactionDictionary),
+					},
+				},
+				MaxBufferedBlocks: maxBufferedBlocks,
+				BlockRetryLimit:   blockRetryLimit,
+				BlockRetryDelay:   blockRetryDelay,
+				FinalityDepth:     finalityDepth,
+				ValidateNetwork:   true,
+				HTTPReadTimeout:   httpReadTimeout,
+				HTTPWriteTimeout:  httpWriteTimeout,
+				HTTPIdleTimeout:   httpIdleTimeout,
+				ReadyBlocksBehind: readyBlocksBehind,
+				NodeWaitTimeout:   6 * time.Hour,
+			},
+		},
+		"all set (min peers at startup)": {
+			Mode:              string(Online),
+			Network:           Mainnet,
+			Port:              "1000",
+			MinPeersAtStartup: "8",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    whive.MainnetNetwork,
+					Blockchain: whive.Blockchain,
+				},
+				Params:                 whive.MainnetParams,
+				Currency:               whive.MainnetCurrency,
+				GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+				Port:                   1000,
+				RPCPort:                mainnetRPCPort,
+				ConfigPath:             path.Join(AppDirectory, mainnetConfigFile),
+				Pruning: &PruningConfiguration{
+					Frequency:  pruneFrequency,
+					Depth:      pruneDepth,
+					MinHeight:  minPruneHeight,
+					ReorgDepth: pruneReorgDepth,
+				},
+				Compressors: []*encoder.CompressorEntry{
+					{
+						Namespace:      transactionNamespace,
+						DictionaryPath: path.Join(AppDirectory, mainnetTransactionDictionary),
+					},
+				},
+				MaxBufferedBlocks: maxBufferedBlocks,
+				BlockRetryLimit:   blockRetryLimit,
+				BlockRetryDelay:   blockRetryDelay,
+				FinalityDepth:     finalityDepth,
+				ValidateNetwork:   true,
+				HTTPReadTimeout:   httpReadTimeout,
+				HTTPWriteTimeout:  httpWriteTimeout,
+				HTTPIdleTimeout:   httpIdleTimeout,
+				ReadyBlocksBehind: readyBlocksBehind,
+				MinPeersAtStartup: 8,
+			},
+		},
+		"all set (include mempool)": {
+			Mode:           string(Online),
+			Network:        Mainnet,
+			Port:           "1000",
+			IncludeMempool: "true",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    whive.MainnetNetwork,
+					Blockchain: whive.Blockchain,
+				},
+				Params:                 whive.MainnetParams,
+				Currency:               whive.MainnetCurrency,
+				GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+				Port:                   1000,
+				RPCPort:                mainnetRPCPort,
+				ConfigPath:             path.Join(AppDirectory, mainnetConfigFile),
+				Pruning: &PruningConfiguration{
+					Frequency:  pruneFrequency,
+					Depth:      pruneDepth,
+					MinHeight:  minPruneHeight,
+					ReorgDepth: pruneReorgDepth,
+				},
+				Compressors: []*encoder.CompressorEntry{
+					{
+						Namespace:      transactionNamespace,
+						DictionaryPath: path.Join(AppDirectory, mainnetTransactionDictionary),
+					},
+				},
+				MaxBufferedBlocks: maxBufferedBlocks,
+				BlockRetryLimit:   blockRetryLimit,
+				BlockRetryDelay:   blockRetryDelay,
+				FinalityDepth:     finalityDepth,
+				ValidateNetwork:   true,
+				HTTPReadTimeout:   httpReadTimeout,
+				HTTPWriteTimeout:  httpWriteTimeout,
+				HTTPIdleTimeout:   httpIdleTimeout,
+				ReadyBlocksBehind: readyBlocksBehind,
+				IncludeMempool:    true,
+			},
+		},
+		"all set (tip reorg check)": {
+			Mode:          string(Online),
+			Network:       Mainnet,
+			Port:          "1000",
+			TipReorgCheck: "true",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    whive.MainnetNetwork,
+					Blockchain: whive.Blockchain,
+				},
+				Params:                 whive.MainnetParams,
+				Currency:               whive.MainnetCurrency,
+				GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+				Port:                   1000,
+				RPCPort:                mainnetRPCPort,
+				ConfigPath:             path.Join(AppDirectory, mainnetConfigFile),
+				Pruning: &PruningConfiguration{
+					Frequency:  pruneFrequency,
+					Depth:      pruneDepth,
+					MinHeight:  minPruneHeight,
+					ReorgDepth: pruneReorgDepth,
+				},
+				Compressors: []*encoder.CompressorEntry{
+					{
+						Namespace:      transactionNamespace,
+						DictionaryPath: path.Join(AppDirectory, mainnetTransactionDictionary),
+					},
+				},
+				MaxBufferedBlocks: maxBufferedBlocks,
+				BlockRetryLimit:   blockRetryLimit,
+				BlockRetryDelay:   blockRetryDelay,
+				FinalityDepth:     finalityDepth,
+				ValidateNetwork:   true,
+				HTTPReadTimeout:   httpReadTimeout,
+				HTTPWriteTimeout:  httpWriteTimeout,
+				HTTPIdleTimeout:   httpIdleTimeout,
+				ReadyBlocksBehind: readyBlocksBehind,
+				TipReorgCheck:     true,
+			},
+		},
+		"all set (max concurrent construction)": {
+			Mode:                      string(Online),
+			Network:                   Mainnet,
+			Port:                      "1000",
+			MaxConcurrentConstruction: "4",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    whive.MainnetNetwork,
+					Blockchain: whive.Blockchain,
+				},
+				Params:                 whive.MainnetParams,
+				Currency:               whive.MainnetCurrency,
+				GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+				Port:                   1000,
+				RPCPort:                mainnetRPCPort,
+				ConfigPath:             path.Join(AppDirectory, mainnetConfigFile),
+				Pruning: &PruningConfiguration{
+					Frequency:  pruneFrequency,
+					Depth:      pruneDepth,
+					MinHeight:  minPruneHeight,
+					ReorgDepth: pruneReorgDepth,
+				},
+				Compressors: []*encoder.CompressorEntry{
+					{
+						Namespace:      transactionNamespace,
+						DictionaryPath: path.Join(AppDirectory, mainnetTransactionDictionary),
+					},
+				},
+				MaxBufferedBlocks: maxBufferedBlocks,
+				BlockRetryLimit:   blockRetryLimit,
+				BlockRetryDelay:   blockRetryDelay,
+				FinalityDepth:     finalityDepth,
+				ValidateNetwork:   true,
+				HTTPReadTimeout:   httpReadTimeout,
+				HTTPWriteTimeout:  httpWriteTimeout,
+				HTTPIdleTimeout:   httpIdleTimeout,
+				ReadyBlocksBehind: readyBlocksBehind,
+				ConstructionLimit: 4,
+			},
+		},
+		"all set (finality depth)": {
+			Mode:          string(Online),
+			Network:       Mainnet,
+			Port:          "1000",
+			FinalityDepth: "100",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    whive.MainnetNetwork,
+					Blockchain: whive.Blockchain,
+				},
+				Params:                 whive.MainnetParams,
+				Currency:               whive.MainnetCurrency,
+				GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+				Port:                   1000,
+				RPCPort:                mainnetRPCPort,
+				ConfigPath:             path.Join(AppDirectory, mainnetConfigFile),
+				Pruning: &PruningConfiguration{
+					Frequency:  pruneFrequency,
+					Depth:      pruneDepth,
+					MinHeight:  minPruneHeight,
+					ReorgDepth: pruneReorgDepth,
+				},
+				Compressors: []*encoder.CompressorEntry{
+					{
+						Namespace:      transactionNamespace,
+						DictionaryPath: path.Join(AppDirectory, mainnetTransactionDictionary),
+					},
+				},
+				MaxBufferedBlocks: maxBufferedBlocks,
+				BlockRetryLimit:   blockRetryLimit,
+				BlockRetryDelay:   blockRetryDelay,
+				FinalityDepth:     100,
+				ValidateNetwork:   true,
+				HTTPReadTimeout:   httpReadTimeout,
+				HTTPWriteTimeout:  httpWriteTimeout,
+				HTTPIdleTimeout:   httpIdleTimeout,
+				ReadyBlocksBehind: readyBlocksBehind,
+			},
+		},
+		"all set (dust relay fee)": {
+			Mode:         string(Online),
+			Network:      Mainnet,
+			Port:         "1000",
+			DustRelayFee: "3000",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    whive.MainnetNetwork,
+					Blockchain: whive.Blockchain,
+				},
+				Params:                 whive.MainnetParams,
+				Currency:               whive.MainnetCurrency,
+				GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+				Port:                   1000,
+				RPCPort:                mainnetRPCPort,
+				ConfigPath:             path.Join(AppDirectory, mainnetConfigFile),
+				Pruning: &PruningConfiguration{
+					Frequency:  pruneFrequency,
+					Depth:      pruneDepth,
+					MinHeight:  minPruneHeight,
+					ReorgDepth: pruneReorgDepth,
+				},
+				Compressors: []*encoder.CompressorEntry{
+					{
+						Namespace:      transactionNamespace,
+						DictionaryPath: path.Join(AppDirectory, mainnetTransactionDictionary),
+					},
+				},
+				MaxBufferedBlocks: maxBufferedBlocks,
+				BlockRetryLimit:   blockRetryLimit,
+				BlockRetryDelay:   blockRetryDelay,
+				FinalityDepth:     finalityDepth,
+				DustRelayFee:      3000,
+				ValidateNetwork:   true,
+				HTTPReadTimeout:   httpReadTimeout,
+				HTTPWriteTimeout:  httpWriteTimeout,
+				HTTPIdleTimeout:   httpIdleTimeout,
+				ReadyBlocksBehind: readyBlocksBehind,
+			},
+		},
+		"all set (max index height)": {
+			Mode:           string(Online),
+			Network:        Mainnet,
+			Port:           "1000",
+			MaxIndexHeight: "100000",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    whive.MainnetNetwork,
+					Blockchain: whive.Blockchain,
+				},
+				Params:                 whive.MainnetParams,
+				Currency:               whive.MainnetCurrency,
+				GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+				Port:                   1000,
+				RPCPort:                mainnetRPCPort,
+				ConfigPath:             path.Join(AppDirectory, mainnetConfigFile),
+				Pruning: &PruningConfiguration{
+					Frequency:  pruneFrequency,
+					Depth:      pruneDepth,
+					MinHeight:  minPruneHeight,
+					ReorgDepth: pruneReorgDepth,
+				},
+				Compressors: []*encoder.CompressorEntry{
+					{
+						Namespace:      transactionNamespace,
+						DictionaryPath: path.Join(AppDirectory, mainnetTransactionDictionary),
+					},
+				},
+				MaxBufferedBlocks: maxBufferedBlocks,
+				BlockRetryLimit:   blockRetryLimit,
+				BlockRetryDelay:   blockRetryDelay,
+				FinalityDepth:     finalityDepth,
+				MaxIndexHeight:    100000,
+				ValidateNetwork:   true,
+				HTTPReadTimeout:   httpReadTimeout,
+				HTTPWriteTimeout:  httpWriteTimeout,
+				HTTPIdleTimeout:   httpIdleTimeout,
+				ReadyBlocksBehind: readyBlocksBehind,
+			},
+		},
+		"all set (RPC max response bytes)": {
+			Mode:                string(Online),
+			Network:             Mainnet,
+			Port:                "1000",
+			RPCMaxResponseBytes: "1048576",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    whive.MainnetNetwork,
+					Blockchain: whive.Blockchain,
+				},
+				Params:                 whive.MainnetParams,
+				Currency:               whive.MainnetCurrency,
+				GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+				Port:                   1000,
+				RPCPort:                mainnetRPCPort,
+				ConfigPath:             path.Join(AppDirectory, mainnetConfigFile),
+				Pruning: &PruningConfiguration{
+					Frequency:  pruneFrequency,
+					Depth:      pruneDepth,
+					MinHeight:  minPruneHeight,
+					ReorgDepth: pruneReorgDepth,
+				},
+				Compressors: []*encoder.CompressorEntry{
+					{
+						Namespace:      transactionNamespace,
+						DictionaryPath: path.Join(AppDirectory, mainnetTransactionDictionary),
+					},
+				},
+				MaxBufferedBlocks:   maxBufferedBlocks,
+				BlockRetryLimit:     blockRetryLimit,
+				BlockRetryDelay:     blockRetryDelay,
+				FinalityDepth:       finalityDepth,
+				RPCMaxResponseBytes: 1048576,
+				ValidateNetwork:     true,
+				HTTPReadTimeout:     httpReadTimeout,
+				HTTPWriteTimeout:    httpWriteTimeout,
+				HTTPIdleTimeout:     httpIdleTimeout,
+				ReadyBlocksBehind:   readyBlocksBehind,
+			},
+		},
+		"all set (prune reorg depth)": {
+			Mode:            string(Online),
+			Network:         Mainnet,
+			Port:            "1000",
+			PruneReorgDepth: "500",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    whive.MainnetNetwork,
+					Blockchain: whive.Blockchain,
+				},
+				Params:                 whive.MainnetParams,
+				Currency:               whive.MainnetCurrency,
+				GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+				Port:                   1000,
+				RPCPort:                mainnetRPCPort,
+				ConfigPath:             path.Join(AppDirectory, mainnetConfigFile),
+				Pruning: &PruningConfiguration{
+					Frequency:  pruneFrequency,
+					Depth:      pruneDepth,
+					MinHeight:  minPruneHeight,
+					ReorgDepth: 500,
+				},
+				Compressors: []*encoder.CompressorEntry{
+					{
+						Namespace:      transactionNamespace,
+						DictionaryPath: path.Join(AppDirectory, mainnetTransactionDictionary),
+					},
+				},
+				MaxBufferedBlocks: maxBufferedBlocks,
+				BlockRetryLimit:   blockRetryLimit,
+				BlockRetryDelay:   blockRetryDelay,
+				FinalityDepth:     finalityDepth,
+				ValidateNetwork:   true,
+				HTTPReadTimeout:   httpReadTimeout,
+				HTTPWriteTimeout:  httpWriteTimeout,
+				HTTPIdleTimeout:   httpIdleTimeout,
+				ReadyBlocksBehind: readyBlocksBehind,
+			},
+		},
+		"all set (pruning depth and frequency)": {
+			Mode:             string(Online),
+			Network:          Mainnet,
+			Port:             "1000",
+			PruningDepth:     "5000",
+			PruningFrequency: "30m",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    whive.MainnetNetwork,
+					Blockchain: whive.Blockchain,
+				},
+				Params:                 whive.MainnetParams,
+				Currency:               whive.MainnetCurrency,
+				GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+				Port:                   1000,
+				RPCPort:                mainnetRPCPort,
+				ConfigPath:             path.Join(AppDirectory, mainnetConfigFile),
+				Pruning: &PruningConfiguration{
+					Frequency:  30 * time.Minute,
+					Depth:      5000,
+					MinHeight:  minPruneHeight,
+					ReorgDepth: pruneReorgDepth,
+				},
+				Compressors: []*encoder.CompressorEntry{
+					{
+						Namespace:      transactionNamespace,
+						DictionaryPath: path.Join(AppDirectory, mainnetTransactionDictionary),
+					},
+				},
+				MaxBufferedBlocks: maxBufferedBlocks,
+				BlockRetryLimit:   blockRetryLimit,
+				BlockRetryDelay:   blockRetryDelay,
+				FinalityDepth:     finalityDepth,
+				ValidateNetwork:   true,
+				HTTPReadTimeout:   httpReadTimeout,
+				HTTPWriteTimeout:  httpWriteTimeout,
+				HTTPIdleTimeout:   httpIdleTimeout,
+				ReadyBlocksBehind: readyBlocksBehind,
+			},
+		},
+		"all set (pruning disabled)": {
+			Mode:            string(Online),
+			Network:         Mainnet,
+			Port:            "1000",
+			PruneReorgDepth: "500",
+			PruningDisabled: "true",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    whive.MainnetNetwork,
+					Blockchain: whive.Blockchain,
+				},
+				Params:                 whive.MainnetParams,
+				Currency:               whive.MainnetCurrency,
+				GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+				Port:                   1000,
+				RPCPort:                mainnetRPCPort,
+				ConfigPath:             path.Join(AppDirectory, mainnetConfigFile),
+				Compressors: []*encoder.CompressorEntry{
+					{
+						Namespace:      transactionNamespace,
+						DictionaryPath: path.Join(AppDirectory, mainnetTransactionDictionary),
+					},
+				},
+				MaxBufferedBlocks: maxBufferedBlocks,
+				BlockRetryLimit:   blockRetryLimit,
+				BlockRetryDelay:   blockRetryDelay,
+				FinalityDepth:     finalityDepth,
+				ValidateNetwork:   true,
+				HTTPReadTimeout:   httpReadTimeout,
+				HTTPWriteTimeout:  httpWriteTimeout,
+				HTTPIdleTimeout:   httpIdleTimeout,
+				ReadyBlocksBehind: readyBlocksBehind,
+			},
+		},
+		"all set (balance coalesce)": {
+			Mode:            string(Online),
+			Network:         Mainnet,
+			Port:            "1000",
+			BalanceCoalesce: "true",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    whive.MainnetNetwork,
+					Blockchain: whive.Blockchain,
+				},
+				Params:                 whive.MainnetParams,
+				Currency:               whive.MainnetCurrency,
+				GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+				Port:                   1000,
+				RPCPort:                mainnetRPCPort,
+				ConfigPath:             path.Join(AppDirectory, mainnetConfigFile),
+				Pruning: &PruningConfiguration{
+					Frequency:  pruneFrequency,
+					Depth:      pruneDepth,
+					MinHeight:  minPruneHeight,
+					ReorgDepth: pruneReorgDepth,
+				},
+				Compressors: []*encoder.CompressorEntry{
+					{
+						Namespace:      transactionNamespace,
+						DictionaryPath: path.Join(AppDirectory, mainnetTransactionDictionary),
+					},
+				},
+				MaxBufferedBlocks: maxBufferedBlocks,
+				BlockRetryLimit:   blockRetryLimit,
+				BlockRetryDelay:   blockRetryDelay,
+				FinalityDepth:     finalityDepth,
+				ValidateNetwork:   true,
+				HTTPReadTimeout:   httpReadTimeout,
+				HTTPWriteTimeout:  httpWriteTimeout,
+				HTTPIdleTimeout:   httpIdleTimeout,
+				ReadyBlocksBehind: readyBlocksBehind,
+				BalanceCoalesce:   true,
+			},
+		},
+		"all set (warm cache)": {
+			Mode:      string(Online),
+			Network:   Mainnet,
+			Port:      "1000",
+			WarmCache: "5000",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    whive.MainnetNetwork,
+					Blockchain: whive.Blockchain,
+				},
+				Params:                 whive.MainnetParams,
+				Currency:               whive.MainnetCurrency,
+				GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+				Port:                   1000,
+				RPCPort:                mainnetRPCPort,
+				ConfigPath:             path.Join(AppDirectory, mainnetConfigFile),
+				Pruning: &PruningConfiguration{
+					Frequency:  pruneFrequency,
+					Depth:      pruneDepth,
+					MinHeight:  minPruneHeight,
+					ReorgDepth: pruneReorgDepth,
+				},
+				Compressors: []*encoder.CompressorEntry{
+					{
+						Namespace:      transactionNamespace,
+						DictionaryPath: path.Join(AppDirectory, mainnetTransactionDictionary),
+					},
+				},
+				MaxBufferedBlocks: maxBufferedBlocks,
+				BlockRetryLimit:   blockRetryLimit,
+				BlockRetryDelay:   blockRetryDelay,
+				FinalityDepth:     finalityDepth,
+				ValidateNetwork:   true,
+				HTTPReadTimeout:   httpReadTimeout,
+				HTTPWriteTimeout:  httpWriteTimeout,
+				HTTPIdleTimeout:   httpIdleTimeout,
+				ReadyBlocksBehind: readyBlocksBehind,
+				WarmCacheSize:     5000,
+			},
+		},
+		"all set (strict operations)": {
+			Mode:             string(Online),
+			Network:          Mainnet,
+			Port:             "1000",
+			StrictOperations: "true",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    whive.MainnetNetwork,
+					Blockchain: whive.Blockchain,
+				},
+				Params:                 whive.MainnetParams,
+				Currency:               whive.MainnetCurrency,
+				GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+				Port:                   1000,
+				RPCPort:                mainnetRPCPort,
+				ConfigPath:             path.Join(AppDirectory, mainnetConfigFile),
+				Pruning: &PruningConfiguration{
+					Frequency:  pruneFrequency,
+					Depth:      pruneDepth,
+					MinHeight:  minPruneHeight,
+					ReorgDepth: pruneReorgDepth,
+				},
+				Compressors: []*encoder.CompressorEntry{
+					{
+						Namespace:      transactionNamespace,
+						DictionaryPath: path.Join(AppDirectory, mainnetTransactionDictionary),
+					},
+				},
+				MaxBufferedBlocks: maxBufferedBlocks,
+				BlockRetryLimit:   blockRetryLimit,
+				BlockRetryDelay:   blockRetryDelay,
+				FinalityDepth:     finalityDepth,
+				ValidateNetwork:   true,
+				HTTPReadTimeout:   httpReadTimeout,
+				HTTPWriteTimeout:  httpWriteTimeout,
+				HTTPIdleTimeout:   httpIdleTimeout,
+				ReadyBlocksBehind: readyBlocksBehind,
+				StrictOperations:  true,
+			},
+		},
+		"all set (metrics enabled)": {
+			Mode:           string(Online),
+			Network:        Mainnet,
+			Port:           "1000",
+			MetricsEnabled: "true",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    whive.MainnetNetwork,
+					Blockchain: whive.Blockchain,
+				},
+				Params:                 whive.MainnetParams,
+				Currency:               whive.MainnetCurrency,
+				GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+				Port:                   1000,
+				RPCPort:                mainnetRPCPort,
+				ConfigPath:             path.Join(AppDirectory, mainnetConfigFile),
+				Pruning: &PruningConfiguration{
+					Frequency:  pruneFrequency,
+					Depth:      pruneDepth,
+					MinHeight:  minPruneHeight,
+					ReorgDepth: pruneReorgDepth,
+				},
+				Compressors: []*encoder.CompressorEntry{
+					{
+						Namespace:      transactionNamespace,
+						DictionaryPath: path.Join(AppDirectory, mainnetTransactionDictionary),
+					},
+				},
+				MaxBufferedBlocks: maxBufferedBlocks,
+				BlockRetryLimit:   blockRetryLimit,
+				BlockRetryDelay:   blockRetryDelay,
+				FinalityDepth:     finalityDepth,
+				ValidateNetwork:   true,
+				HTTPReadTimeout:   httpReadTimeout,
+				HTTPWriteTimeout:  httpWriteTimeout,
+				HTTPIdleTimeout:   httpIdleTimeout,
+				ReadyBlocksBehind: readyBlocksBehind,
+				MetricsEnabled:    true,
+			},
+		},
+		"all set (max metadata age)": {
+			Mode:           string(Online),
+			Network:        Mainnet,
+			Port:           "1000",
+			MaxMetadataAge: "5m",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    whive.MainnetNetwork,
+					Blockchain: whive.Blockchain,
+				},
+				Params:                 whive.MainnetParams,
+				Currency:               whive.MainnetCurrency,
+				GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+				Port:                   1000,
+				RPCPort:                mainnetRPCPort,
+				ConfigPath:             path.Join(AppDirectory, mainnetConfigFile),
+				Pruning: &PruningConfiguration{
+					Frequency:  pruneFrequency,
+					Depth:      pruneDepth,
+					MinHeight:  minPruneHeight,
+					ReorgDepth: pruneReorgDepth,
+				},
+				Compressors: []*encoder.CompressorEntry{
+					{
+						Namespace:      transactionNamespace,
+						DictionaryPath: path.Join(AppDirectory, mainnetTransactionDictionary),
+					},
+				},
+				MaxBufferedBlocks: maxBufferedBlocks,
+				BlockRetryLimit:   blockRetryLimit,
+				BlockRetryDelay:   blockRetryDelay,
+				FinalityDepth:     finalityDepth,
+				ValidateNetwork:   true,
+				HTTPReadTimeout:   httpReadTimeout,
+				HTTPWriteTimeout:  httpWriteTimeout,
+				HTTPIdleTimeout:   httpIdleTimeout,
+				ReadyBlocksBehind: readyBlocksBehind,
+				MaxMetadataAge:    5 * time.Minute,
+			},
+		},
+		"all set (fee estimation)": {
+			Mode:               string(Online),
+			Network:            Mainnet,
+			Port:               "1000",
+			ConfirmationTarget: "6",
+			MinFeeRate:         "0.00002",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    whive.MainnetNetwork,
+					Blockchain: whive.Blockchain,
+				},
+				Params:                 whive.MainnetParams,
+				Currency:               whive.MainnetCurrency,
+				GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+				Port:                   1000,
+				RPCPort:                mainnetRPCPort,
+				ConfigPath:             path.Join(AppDirectory, mainnetConfigFile),
+				Pruning: &PruningConfiguration{
+					Frequency:  pruneFrequency,
+					Depth:      pruneDepth,
+					MinHeight:  minPruneHeight,
+					ReorgDepth: pruneReorgDepth,
+				},
+				Compressors: []*encoder.CompressorEntry{
+					{
+						Namespace:      transactionNamespace,
+						DictionaryPath: path.Join(AppDirectory, mainnetTransactionDictionary),
+					},
+				},
+				MaxBufferedBlocks:  maxBufferedBlocks,
+				BlockRetryLimit:    blockRetryLimit,
+				BlockRetryDelay:    blockRetryDelay,
+				FinalityDepth:      finalityDepth,
+				ValidateNetwork:    true,
+				HTTPReadTimeout:    httpReadTimeout,
+				HTTPWriteTimeout:   httpWriteTimeout,
+				HTTPIdleTimeout:    httpIdleTimeout,
+				ReadyBlocksBehind:  readyBlocksBehind,
+				ConfirmationTarget: 6,
+				MinFeeRate:         0.00002,
+			},
+		},
+		"all set (degrade to offline)": {
+			Mode:             string(Online),
+			Network:          Mainnet,
+			Port:             "1000",
+			DegradeToOffline: "5",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    whive.MainnetNetwork,
+					Blockchain: whive.Blockchain,
+				},
+				Params:                 whive.MainnetParams,
+				Currency:               whive.MainnetCurrency,
+				GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+				Port:                   1000,
+				RPCPort:                mainnetRPCPort,
+				ConfigPath:             path.Join(AppDirectory, mainnetConfigFile),
+				Pruning: &PruningConfiguration{
+					Frequency:  pruneFrequency,
+					Depth:      pruneDepth,
+					MinHeight:  minPruneHeight,
+					ReorgDepth: pruneReorgDepth,
+				},
+				Compressors: []*encoder.CompressorEntry{
+					{
+						Namespace:      transactionNamespace,
+						DictionaryPath: path.Join(AppDirectory, mainnetTransactionDictionary),
+					},
+				},
+				MaxBufferedBlocks: maxBufferedBlocks,
+				BlockRetryLimit:   blockRetryLimit,
+				BlockRetryDelay:   blockRetryDelay,
+				FinalityDepth:     finalityDepth,
+				ValidateNetwork:   true,
+				HTTPReadTimeout:   httpReadTimeout,
+				HTTPWriteTimeout:  httpWriteTimeout,
+				HTTPIdleTimeout:   httpIdleTimeout,
+				ReadyBlocksBehind: readyBlocksBehind,
+				DegradeThreshold:  5,
+			},
+		},
+		"all set (hash check)": {
+			Mode:              string(Online),
+			Network:           Mainnet,
+			Port:              "1000",
+			HashCheckInterval: "10m",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    whive.MainnetNetwork,
+					Blockchain: whive.Blockchain,
+				},
+				Params:                 whive.MainnetParams,
+				Currency:               whive.MainnetCurrency,
+				GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+				Port:                   1000,
+				RPCPort:                mainnetRPCPort,
+				ConfigPath:             path.Join(AppDirectory, mainnetConfigFile),
+				Pruning: &PruningConfiguration{
+					Frequency:  pruneFrequency,
+					Depth:      pruneDepth,
+					MinHeight:  minPruneHeight,
+					ReorgDepth: pruneReorgDepth,
+				},
+				Compressors: []*encoder.CompressorEntry{
+					{
+						Namespace:      transactionNamespace,
+						DictionaryPath: path.Join(AppDirectory, mainnetTransactionDictionary),
+					},
+				},
+				MaxBufferedBlocks: maxBufferedBlocks,
+				BlockRetryLimit:   blockRetryLimit,
+				BlockRetryDelay:   blockRetryDelay,
+				FinalityDepth:     finalityDepth,
+				ValidateNetwork:   true,
+				HTTPReadTimeout:   httpReadTimeout,
+				HTTPWriteTimeout:  httpWriteTimeout,
+				HTTPIdleTimeout:   httpIdleTimeout,
+				ReadyBlocksBehind: readyBlocksBehind,
+				HashCheckInterval: 10 * time.Minute,
+			},
+		},
+		"all set (http timeouts)": {
+			Mode:             string(Online),
+			Network:          Mainnet,
+			Port:             "1000",
+			HTTPReadTimeout:  "10s",
+			HTTPWriteTimeout: "2m",
+			HTTPIdleTimeout:  "1m",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    whive.MainnetNetwork,
+					Blockchain: whive.Blockchain,
+				},
+				Params:                 whive.MainnetParams,
+				Currency:               whive.MainnetCurrency,
+				GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+				Port:                   1000,
+				RPCPort:                mainnetRPCPort,
+				ConfigPath:             path.Join(AppDirectory, mainnetConfigFile),
+				Pruning: &PruningConfiguration{
+					Frequency:  pruneFrequency,
+					Depth:      pruneDepth,
+					MinHeight:  minPruneHeight,
+					ReorgDepth: pruneReorgDepth,
+				},
+				Compressors: []*encoder.CompressorEntry{
+					{
+						Namespace:      transactionNamespace,
+						DictionaryPath: path.Join(AppDirectory, mainnetTransactionDictionary),
+					},
+				},
+				MaxBufferedBlocks: maxBufferedBlocks,
+				BlockRetryLimit:   blockRetryLimit,
+				BlockRetryDelay:   blockRetryDelay,
+				FinalityDepth:     finalityDepth,
+				ValidateNetwork:   true,
+				HTTPReadTimeout:   10 * time.Second,
+				HTTPWriteTimeout:  2 * time.Minute,
+				HTTPIdleTimeout:   time.Minute,
+				ReadyBlocksBehind: readyBlocksBehind,
+			},
+		},
+		"all set (script types)": {
+			Mode:        string(Online),
+			Network:     Mainnet,
+			Port:        "1000",
+			ScriptTypes: "true",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    whive.MainnetNetwork,
+					Blockchain: whive.Blockchain,
+				},
+				Params:                 whive.MainnetParams,
+				Currency:               whive.MainnetCurrency,
+				GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+				Port:                   1000,
+				RPCPort:                mainnetRPCPort,
+				ConfigPath:             path.Join(AppDirectory, mainnetConfigFile),
+				Pruning: &PruningConfiguration{
+					Frequency:  pruneFrequency,
+					Depth:      pruneDepth,
+					MinHeight:  minPruneHeight,
+					ReorgDepth: pruneReorgDepth,
+				},
+				Compressors: []*encoder.CompressorEntry{
+					{
+						Namespace:      transactionNamespace,
+						DictionaryPath: path.Join(AppDirectory, mainnetTransactionDictionary),
+					},
+				},
+				MaxBufferedBlocks: maxBufferedBlocks,
+				BlockRetryLimit:   blockRetryLimit,
+				BlockRetryDelay:   blockRetryDelay,
+				FinalityDepth:     finalityDepth,
+				ValidateNetwork:   true,
+				HTTPReadTimeout:   httpReadTimeout,
+				HTTPWriteTimeout:  httpWriteTimeout,
+				HTTPIdleTimeout:   httpIdleTimeout,
+				ReadyBlocksBehind: readyBlocksBehind,
+				ScriptTypes:       true,
+			},
+		},
+		"all set (ready blocks behind)": {
+			Mode:              string(Online),
+			Network:           Mainnet,
+			Port:              "1000",
+			ReadyBlocksBehind: "10",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    whive.MainnetNetwork,
+					Blockchain: whive.Blockchain,
+				},
+				Params:                 whive.MainnetParams,
+				Currency:               whive.MainnetCurrency,
+				GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+				Port:                   1000,
+				RPCPort:                mainnetRPCPort,
+				ConfigPath:             path.Join(AppDirectory, mainnetConfigFile),
+				Pruning: &PruningConfiguration{
+					Frequency:  pruneFrequency,
+					Depth:      pruneDepth,
+					MinHeight:  minPruneHeight,
+					ReorgDepth: pruneReorgDepth,
+				},
+				Compressors: []*encoder.CompressorEntry{
+					{
+						Namespace:      transactionNamespace,
+						DictionaryPath: path.Join(AppDirectory, mainnetTransactionDictionary),
+					},
+				},
+				MaxBufferedBlocks: maxBufferedBlocks,
+				BlockRetryLimit:   blockRetryLimit,
+				BlockRetryDelay:   blockRetryDelay,
+				FinalityDepth:     finalityDepth,
+				ValidateNetwork:   true,
+				HTTPReadTimeout:   httpReadTimeout,
+				HTTPWriteTimeout:  httpWriteTimeout,
+				HTTPIdleTimeout:   httpIdleTimeout,
+				ReadyBlocksBehind: 10,
+			},
+		},
+		"all set (privileged port, strict, root)": {
+			Mode:       string(Online),
+			Network:    Mainnet,
+			Port:       "443",
+			StrictPort: "true",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    whive.MainnetNetwork,
+					Blockchain: whive.Blockchain,
+				},
+				Params:                 whive.MainnetParams,
+				Currency:               whive.MainnetCurrency,
+				GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+				Port:                   443,
+				StrictPort:             true,
+				RPCPort:                mainnetRPCPort,
+				ConfigPath:             path.Join(AppDirectory, mainnetConfigFile),
+				Pruning: &PruningConfiguration{
+					Frequency:  pruneFrequency,
+					Depth:      pruneDepth,
+					MinHeight:  minPruneHeight,
+					ReorgDepth: pruneReorgDepth,
+				},
+				Compressors: []*encoder.CompressorEntry{
+					{
+						Namespace:      transactionNamespace,
+						DictionaryPath: path.Join(AppDirectory, mainnetTransactionDictionary),
+					},
+				},
+				MaxBufferedBlocks: maxBufferedBlocks,
+				BlockRetryLimit:   blockRetryLimit,
+				BlockRetryDelay:   blockRetryDelay,
+				FinalityDepth:     finalityDepth,
+				HTTPReadTimeout:   httpReadTimeout,
+				HTTPWriteTimeout:  httpWriteTimeout,
+				HTTPIdleTimeout:   httpIdleTimeout,
+				ReadyBlocksBehind: readyBlocksBehind,
+				ValidateNetwork:   true,
+			},
+		},
+		"all set (privileged port, not root)": {
+			Mode:    string(Online),
+			Network: Mainnet,
+			Port:    "443",
+			euid:    1000,
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    whive.MainnetNetwork,
+					Blockchain: whive.Blockchain,
+				},
+				Params:                 whive.MainnetParams,
+				Currency:               whive.MainnetCurrency,
+				GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+				Port:                   443,
+				RPCPort:                mainnetRPCPort,
+				ConfigPath:             path.Join(AppDirectory, mainnetConfigFile),
+				Pruning: &PruningConfiguration{
+					Frequency:  pruneFrequency,
+					Depth:      pruneDepth,
+					MinHeight:  minPruneHeight,
+					ReorgDepth: pruneReorgDepth,
+				},
+				Compressors: []*encoder.CompressorEntry{
+					{
+						Namespace:      transactionNamespace,
+						DictionaryPath: path.Join(AppDirectory, mainnetTransactionDictionary),
+					},
+				},
+				MaxBufferedBlocks: maxBufferedBlocks,
+				BlockRetryLimit:   blockRetryLimit,
+				BlockRetryDelay:   blockRetryDelay,
+				FinalityDepth:     finalityDepth,
+				HTTPReadTimeout:   httpReadTimeout,
+				HTTPWriteTimeout:  httpWriteTimeout,
+				HTTPIdleTimeout:   httpIdleTimeout,
+				ReadyBlocksBehind: readyBlocksBehind,
+				ValidateNetwork:   true,
+			},
+		},
+		"all set (RPC port)": {
+			Mode:    string(Online),
+			Network: Testnet,
+			Port:    "1000",
+			RPCPort: "18999",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    whive.TestnetNetwork,
+					Blockchain: whive.Blockchain,
+				},
+				Params:                 whive.TestnetParams,
+				Currency:               whive.TestnetCurrency,
+				GenesisBlockIdentifier: whive.TestnetGenesisBlockIdentifier,
+				Port:                   1000,
+				RPCPort:                18999,
+				ConfigPath:             path.Join(AppDirectory, testnetConfigFile),
+				Pruning: &PruningConfiguration{
+					Frequency:  pruneFrequency,
+					Depth:      pruneDepth,
+					MinHeight:  minPruneHeight,
+					ReorgDepth: pruneReorgDepth,
+				},
+				Compressors: []*encoder.CompressorEntry{
+					{
+						Namespace:      transactionNamespace,
+						DictionaryPath: path.Join(AppDirectory, testnetTransactionDictionary),
+					},
+				},
+				MaxBufferedBlocks: maxBufferedBlocks,
+				BlockRetryLimit:   blockRetryLimit,
+				BlockRetryDelay:   blockRetryDelay,
+				FinalityDepth:     finalityDepth,
+				HTTPReadTimeout:   httpReadTimeout,
+				HTTPWriteTimeout:  httpWriteTimeout,
+				HTTPIdleTimeout:   httpIdleTimeout,
+				ReadyBlocksBehind: readyBlocksBehind,
+				ValidateNetwork:   true,
+			},
+		},
+		"all set (directories)": {
+			Mode:    string(Online),
+			Network: Testnet,
+			Port:    "1000",
+			DataDir: "custom",
+			AppDir:  "/opt/whive",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    whive.TestnetNetwork,
+					Blockchain: whive.Blockchain,
+				},
+				Params:                 whive.TestnetParams,
+				Currency:               whive.TestnetCurrency,
+				GenesisBlockIdentifier: whive.TestnetGenesisBlockIdentifier,
+				Port:                   1000,
+				RPCPort:                testnetRPCPort,
+				ConfigPath:             path.Join("/opt/whive", testnetConfigFile),
+				Pruning: &PruningConfiguration{
+					Frequency:  pruneFrequency,
+					Depth:      pruneDepth,
+					MinHeight:  minPruneHeight,
+					ReorgDepth: pruneReorgDepth,
+				},
+				Compressors: []*encoder.CompressorEntry{
+					{
+						Namespace:      transactionNamespace,
+						DictionaryPath: path.Join("/opt/whive", testnetTransactionDictionary),
+					},
+				},
+				MaxBufferedBlocks: maxBufferedBlocks,
+				BlockRetryLimit:   blockRetryLimit,
+				BlockRetryDelay:   blockRetryDelay,
+				FinalityDepth:     finalityDepth,
+				HTTPReadTimeout:   httpReadTimeout,
+				HTTPWriteTimeout:  httpWriteTimeout,
+				HTTPIdleTimeout:   httpIdleTimeout,
+				ReadyBlocksBehind: readyBlocksBehind,
+				ValidateNetwork:   true,
+			},
+		},
+		"all set (storage shards)": {
+			Mode:          string(Online),
+			Network:       Mainnet,
+			Port:          "1000",
+			StorageShards: "4",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    whive.MainnetNetwork,
+					Blockchain: whive.Blockchain,
+				},
+				Params:                 whive.MainnetParams,
+				Currency:               whive.MainnetCurrency,
+				GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+				Port:                   1000,
+				RPCPort:                mainnetRPCPort,
+				ConfigPath:             path.Join(AppDirectory, mainnetConfigFile),
+				Pruning: &PruningConfiguration{
+					Frequency:  pruneFrequency,
+					Depth:      pruneDepth,
+					MinHeight:  minPruneHeight,
+					ReorgDepth: pruneReorgDepth,
+				},
+				Compressors: []*encoder.CompressorEntry{
+					{
+						Namespace:      transactionNamespace,
+						DictionaryPath: path.Join(AppDirectory, mainnetTransactionDictionary),
+					},
+				},
+				MaxBufferedBlocks: maxBufferedBlocks,
+				BlockRetryLimit:   blockRetryLimit,
+				BlockRetryDelay:   blockRetryDelay,
+				FinalityDepth:     finalityDepth,
+				StorageShards:     4,
+				ValidateNetwork:   true,
+				HTTPReadTimeout:   httpReadTimeout,
+				HTTPWriteTimeout:  httpWriteTimeout,
+				HTTPIdleTimeout:   httpIdleTimeout,
+				ReadyBlocksBehind: readyBlocksBehind,
+			},
+		},
+		"all set (block operation types)": {
+			Mode:                string(Online),
+			Network:             Mainnet,
+			Port:                "1000",
+			BlockOperationTypes: "OUTPUT, INPUT",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    whive.MainnetNetwork,
+					Blockchain: whive.Blockchain,
+				},
+				Params:                 whive.MainnetParams,
+				Currency:               whive.MainnetCurrency,
+				GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+				Port:                   1000,
+				RPCPort:                mainnetRPCPort,
+				ConfigPath:             path.Join(AppDirectory, mainnetConfigFile),
+				Pruning: &PruningConfiguration{
+					Frequency:  pruneFrequency,
+					Depth:      pruneDepth,
+					MinHeight:  minPruneHeight,
+					ReorgDepth: pruneReorgDepth,
+				},
+				Compressors: []*encoder.CompressorEntry{
+					{
+						Namespace:      transactionNamespace,
+						DictionaryPath: path.Join(AppDirectory, mainnetTransactionDictionary),
+					},
+				},
+				MaxBufferedBlocks:   maxBufferedBlocks,
+				BlockRetryLimit:     blockRetryLimit,
+				BlockRetryDelay:     blockRetryDelay,
+				FinalityDepth:       finalityDepth,
+				ValidateNetwork:     true,
+				HTTPReadTimeout:     httpReadTimeout,
+				HTTPWriteTimeout:    httpWriteTimeout,
+				HTTPIdleTimeout:     httpIdleTimeout,
+				ReadyBlocksBehind:   readyBlocksBehind,
+				BlockOperationTypes: []string{whive.OutputOpType, whive.InputOpType},
+			},
+		},
+		"all set (block inline transactions)": {
+			Mode:                    string(Online),
+			Network:                 Mainnet,
+			Port:                    "1000",
+			BlockInlineTransactions: "25",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    whive.MainnetNetwork,
+					Blockchain: whive.Blockchain,
+				},
+				Params:                 whive.MainnetParams,
+				Currency:               whive.MainnetCurrency,
+				GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+				Port:                   1000,
+				RPCPort:                mainnetRPCPort,
+				ConfigPath:             path.Join(AppDirectory, mainnetConfigFile),
+				Pruning: &PruningConfiguration{
+					Frequency:  pruneFrequency,
+					Depth:      pruneDepth,
+					MinHeight:  minPruneHeight,
+					ReorgDepth: pruneReorgDepth,
+				},
+				Compressors: []*encoder.CompressorEntry{
+					{
+						Namespace:      transactionNamespace,
+						DictionaryPath: path.Join(AppDirectory, mainnetTransactionDictionary),
+					},
+				},
+				MaxBufferedBlocks:       maxBufferedBlocks,
+				BlockRetryLimit:         blockRetryLimit,
+				BlockRetryDelay:         blockRetryDelay,
+				FinalityDepth:           finalityDepth,
+				ValidateNetwork:         true,
+				HTTPReadTimeout:         httpReadTimeout,
+				HTTPWriteTimeout:        httpWriteTimeout,
+				HTTPIdleTimeout:         httpIdleTimeout,
+				ReadyBlocksBehind:       readyBlocksBehind,
+				BlockInlineTransactions: 25,
+			},
 		},
-		"only mode and network set": {
-			Mode:    string(Online),
-			Network: Mainnet,
-			err:     errors.New("PORT must be populated"),
+		"all set (rpc batch window)": {
+			Mode:           string(Online),
+			Network:        Mainnet,
+			Port:           "1000",
+			RPCBatchWindow: "5ms",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    whive.MainnetNetwork,
+					Blockchain: whive.Blockchain,
+				},
+				Params:                 whive.MainnetParams,
+				Currency:               whive.MainnetCurrency,
+				GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+				Port:                   1000,
+				RPCPort:                mainnetRPCPort,
+				ConfigPath:             path.Join(AppDirectory, mainnetConfigFile),
+				Pruning: &PruningConfiguration{
+					Frequency:  pruneFrequency,
+					Depth:      pruneDepth,
+					MinHeight:  minPruneHeight,
+					ReorgDepth: pruneReorgDepth,
+				},
+				Compressors: []*encoder.CompressorEntry{
+					{
+						Namespace:      transactionNamespace,
+						DictionaryPath: path.Join(AppDirectory, mainnetTransactionDictionary),
+					},
+				},
+				MaxBufferedBlocks: maxBufferedBlocks,
+				BlockRetryLimit:   blockRetryLimit,
+				BlockRetryDelay:   blockRetryDelay,
+				FinalityDepth:     finalityDepth,
+				ValidateNetwork:   true,
+				HTTPReadTimeout:   httpReadTimeout,
+				HTTPWriteTimeout:  httpWriteTimeout,
+				HTTPIdleTimeout:   httpIdleTimeout,
+				ReadyBlocksBehind: readyBlocksBehind,
+				RPCBatchWindow:    5 * time.Millisecond,
+			},
 		},
-		"all set (mainnet)": {
-			Mode:    string(Online),
-			Network: Mainnet,
-			Port:    "1000",
+		"all set (rpc max concurrency)": {
+			Mode:              string(Online),
+			Network:           Mainnet,
+			Port:              "1000",
+			RPCMaxConcurrency: "8",
 			cfg: &Configuration{
 				Mode: Online,
 				Network: &types.NetworkIdentifier{
@@ -64,47 +1709,196 @@ func TestLoadConfiguration(t *testing.T) {
 				GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
 				Port:                   1000,
 				RPCPort:                mainnetRPCPort,
-				ConfigPath:             mainnetConfigPath,
+				ConfigPath:             path.Join(AppDirectory, mainnetConfigFile),
 				Pruning: &PruningConfiguration{
-					Frequency: pruneFrequency,
-					Depth:     pruneDepth,
-					MinHeight: minPruneHeight,
+					Frequency:  pruneFrequency,
+					Depth:      pruneDepth,
+					MinHeight:  minPruneHeight,
+					ReorgDepth: pruneReorgDepth,
 				},
 				Compressors: []*encoder.CompressorEntry{
 					{
 						Namespace:      transactionNamespace,
-						DictionaryPath: mainnetTransactionDictionary,
+						DictionaryPath: path.Join(AppDirectory, mainnetTransactionDictionary),
 					},
 				},
+				MaxBufferedBlocks: maxBufferedBlocks,
+				BlockRetryLimit:   blockRetryLimit,
+				BlockRetryDelay:   blockRetryDelay,
+				FinalityDepth:     finalityDepth,
+				ValidateNetwork:   true,
+				HTTPReadTimeout:   httpReadTimeout,
+				HTTPWriteTimeout:  httpWriteTimeout,
+				HTTPIdleTimeout:   httpIdleTimeout,
+				ReadyBlocksBehind: readyBlocksBehind,
+				RPCConcurrency:    8,
 			},
 		},
-		"all set (testnet)": {
-			Mode:    string(Online),
-			Network: Testnet,
-			Port:    "1000",
+		"all set (rpc cookie path)": {
+			Mode:          string(Online),
+			Network:       Mainnet,
+			Port:          "1000",
+			RPCCookiePath: "/data/whived/.cookie",
 			cfg: &Configuration{
 				Mode: Online,
 				Network: &types.NetworkIdentifier{
-					Network:    whive.TestnetNetwork,
+					Network:    whive.MainnetNetwork,
 					Blockchain: whive.Blockchain,
 				},
-				Params:                 whive.TestnetParams,
-				Currency:               whive.TestnetCurrency,
-				GenesisBlockIdentifier: whive.TestnetGenesisBlockIdentifier,
+				Params:                 whive.MainnetParams,
+				Currency:               whive.MainnetCurrency,
+				GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
 				Port:                   1000,
-				RPCPort:                testnetRPCPort,
-				ConfigPath:             testnetConfigPath,
+				RPCPort:                mainnetRPCPort,
+				ConfigPath:             path.Join(AppDirectory, mainnetConfigFile),
+				Pruning: &PruningConfiguration{
+					Frequency:  pruneFrequency,
+					Depth:      pruneDepth,
+					MinHeight:  minPruneHeight,
+					ReorgDepth: pruneReorgDepth,
+				},
+				Compressors: []*encoder.CompressorEntry{
+					{
+						Namespace:      transactionNamespace,
+						DictionaryPath: path.Join(AppDirectory, mainnetTransactionDictionary),
+					},
+				},
+				MaxBufferedBlocks: maxBufferedBlocks,
+				BlockRetryLimit:   blockRetryLimit,
+				BlockRetryDelay:   blockRetryDelay,
+				FinalityDepth:     finalityDepth,
+				ValidateNetwork:   true,
+				HTTPReadTimeout:   httpReadTimeout,
+				HTTPWriteTimeout:  httpWriteTimeout,
+				HTTPIdleTimeout:   httpIdleTimeout,
+				ReadyBlocksBehind: readyBlocksBehind,
+				RPCCookiePath:     "/data/whived/.cookie",
+			},
+		},
+		"all set (rpc socket)": {
+			Mode:      string(Online),
+			Network:   Mainnet,
+			Port:      "1000",
+			RPCSocket: "unix:///run/whived/rpc.sock",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    whive.MainnetNetwork,
+					Blockchain: whive.Blockchain,
+				},
+				Params:                 whive.MainnetParams,
+				Currency:               whive.MainnetCurrency,
+				GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+				Port:                   1000,
+				RPCPort:                mainnetRPCPort,
+				ConfigPath:             path.Join(AppDirectory, mainnetConfigFile),
+				Pruning: &PruningConfiguration{
+					Frequency:  pruneFrequency,
+					Depth:      pruneDepth,
+					MinHeight:  minPruneHeight,
+					ReorgDepth: pruneReorgDepth,
+				},
+				Compressors: []*encoder.CompressorEntry{
+					{
+						Namespace:      transactionNamespace,
+						DictionaryPath: path.Join(AppDirectory, mainnetTransactionDictionary),
+					},
+				},
+				MaxBufferedBlocks: maxBufferedBlocks,
+				BlockRetryLimit:   blockRetryLimit,
+				BlockRetryDelay:   blockRetryDelay,
+				FinalityDepth:     finalityDepth,
+				ValidateNetwork:   true,
+				HTTPReadTimeout:   httpReadTimeout,
+				HTTPWriteTimeout:  httpWriteTimeout,
+				HTTPIdleTimeout:   httpIdleTimeout,
+				ReadyBlocksBehind: readyBlocksBehind,
+				RPCSocket:         "/run/whived/rpc.sock",
+			},
+		},
+		"all set (log level)": {
+			Mode:     string(Online),
+			Network:  Mainnet,
+			Port:     "1000",
+			LogLevel: "debug",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    whive.MainnetNetwork,
+					Blockchain: whive.Blockchain,
+				},
+				Params:                 whive.MainnetParams,
+				Currency:               whive.MainnetCurrency,
+				GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+				Port:                   1000,
+				RPCPort:                mainnetRPCPort,
+				ConfigPath:             path.Join(AppDirectory, mainnetConfigFile),
+				Pruning: &PruningConfiguration{
+					Frequency:  pruneFrequency,
+					Depth:      pruneDepth,
+					MinHeight:  minPruneHeight,
+					ReorgDepth: pruneReorgDepth,
+				},
+				Compressors: []*encoder.CompressorEntry{
+					{
+						Namespace:      transactionNamespace,
+						DictionaryPath: path.Join(AppDirectory, mainnetTransactionDictionary),
+					},
+				},
+				MaxBufferedBlocks: maxBufferedBlocks,
+				BlockRetryLimit:   blockRetryLimit,
+				BlockRetryDelay:   blockRetryDelay,
+				FinalityDepth:     finalityDepth,
+				ValidateNetwork:   true,
+				HTTPReadTimeout:   httpReadTimeout,
+				HTTPWriteTimeout:  httpWriteTimeout,
+				HTTPIdleTimeout:   httpIdleTimeout,
+				ReadyBlocksBehind: readyBlocksBehind,
+				LogLevel:          zapcore.DebugLevel,
+			},
+		},
+		"all set (gzip)": {
+			Mode:        string(Online),
+			Network:     Mainnet,
+			Port:        "1000",
+			Gzip:        "true",
+			GzipMinSize: "10",
+			cfg: &Configuration{
+				Mode: Online,
+				Network: &types.NetworkIdentifier{
+					Network:    whive.MainnetNetwork,
+					Blockchain: whive.Blockchain,
+				},
+				Params:                 whive.MainnetParams,
+				Currency:               whive.MainnetCurrency,
+				GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+				Port:                   1000,
+				RPCPort:                mainnetRPCPort,
+				ConfigPath:             path.Join(AppDirectory, mainnetConfigFile),
 				Pruning: &PruningConfiguration{
-					Frequency: pruneFrequency,
-					Depth:     pruneDepth,
-					MinHeight: minPruneHeight,
+					Frequency:  pruneFrequency,
+					Depth:      pruneDepth,
+					MinHeight:  minPruneHeight,
+					ReorgDepth: pruneReorgDepth,
 				},
 				Compressors: []*encoder.CompressorEntry{
 					{
 						Namespace:      transactionNamespace,
-						DictionaryPath: testnetTransactionDictionary,
+						DictionaryPath: path.Join(AppDirectory, mainnetTransactionDictionary),
 					},
 				},
+				MaxBufferedBlocks: maxBufferedBlocks,
+				BlockRetryLimit:   blockRetryLimit,
+				BlockRetryDelay:   blockRetryDelay,
+				FinalityDepth:     finalityDepth,
+				HTTPReadTimeout:   httpReadTimeout,
+				HTTPWriteTimeout:  httpWriteTimeout,
+				HTTPIdleTimeout:   httpIdleTimeout,
+				ReadyBlocksBehind: readyBlocksBehind,
+				ValidateNetwork:   true,
+				Compression: &CompressionConfiguration{
+					MinSize: 10,
+				},
 			},
 		},
 		"invalid mode": {
@@ -125,6 +1919,435 @@ func TestLoadConfiguration(t *testing.T) {
 			Port:    "bad port",
 			err:     errors.New("unable to parse port bad port"),
 		},
+		"invalid max buffered blocks": {
+			Mode:              string(Offline),
+			Network:           Testnet,
+			Port:              "1000",
+			MaxBufferedBlocks: "-1",
+			err:               errors.New("max buffered blocks -1 must not be negative"),
+		},
+		"invalid sync concurrency": {
+			Mode:            string(Online),
+			Network:         Testnet,
+			Port:            "1000",
+			SyncConcurrency: "many",
+			err:             errors.New("unable to parse sync concurrency many"),
+		},
+		"non-positive sync concurrency": {
+			Mode:            string(Online),
+			Network:         Testnet,
+			Port:            "1000",
+			SyncConcurrency: "0",
+			err:             errors.New("sync concurrency 0 must be positive"),
+		},
+		"invalid min free disk": {
+			Mode:        string(Offline),
+			Network:     Testnet,
+			Port:        "1000",
+			MinFreeDisk: "-1",
+			err:         errors.New("unable to parse min free disk -1"),
+		},
+		"invalid block retry limit": {
+			Mode:            string(Offline),
+			Network:         Testnet,
+			Port:            "1000",
+			BlockRetryLimit: "-1",
+			err:             errors.New("block retry limit -1 must not be negative"),
+		},
+		"invalid block retry delay": {
+			Mode:            string(Offline),
+			Network:         Testnet,
+			Port:            "1000",
+			BlockRetryDelay: "soon",
+			err:             errors.New("unable to parse block retry delay soon"),
+		},
+		"invalid max tx outputs": {
+			Mode:         string(Offline),
+			Network:      Testnet,
+			Port:         "1000",
+			MaxTxOutputs: "-1",
+			err:          errors.New("max tx outputs -1 must not be negative"),
+		},
+		"invalid validate network": {
+			Mode:            string(Offline),
+			Network:         Testnet,
+			Port:            "1000",
+			ValidateNetwork: "maybe",
+			err:             errors.New("unable to parse validate network maybe"),
+		},
+		"invalid node wait timeout": {
+			Mode:            string(Offline),
+			Network:         Testnet,
+			Port:            "1000",
+			NodeWaitTimeout: "forever",
+			err:             errors.New("unable to parse node wait timeout forever"),
+		},
+		"invalid min peers at startup": {
+			Mode:              string(Offline),
+			Network:           Testnet,
+			Port:              "1000",
+			MinPeersAtStartup: "-1",
+			err:               errors.New("min peers at startup -1 must not be negative"),
+		},
+		"invalid include mempool": {
+			Mode:           string(Offline),
+			Network:        Testnet,
+			Port:           "1000",
+			IncludeMempool: "sometimes",
+			err:            errors.New("unable to parse include mempool sometimes"),
+		},
+		"invalid rpc batch window": {
+			Mode:           string(Offline),
+			Network:        Testnet,
+			Port:           "1000",
+			RPCBatchWindow: "-5ms",
+			err:            errors.New("RPC batch window -5ms must not be negative"),
+		},
+		"invalid rpc max concurrency": {
+			Mode:              string(Offline),
+			Network:           Testnet,
+			Port:              "1000",
+			RPCMaxConcurrency: "-1",
+			err:               errors.New("RPC max concurrency -1 must not be negative"),
+		},
+		"invalid tip reorg check": {
+			Mode:          string(Offline),
+			Network:       Testnet,
+			Port:          "1000",
+			TipReorgCheck: "sometimes",
+			err:           errors.New("unable to parse tip reorg check sometimes"),
+		},
+		"invalid block operation types": {
+			Mode:                string(Offline),
+			Network:             Testnet,
+			Port:                "1000",
+			BlockOperationTypes: "OUTPUT,FEE",
+			err:                 errors.New("operation type FEE is not supported"),
+		},
+		"invalid max concurrent construction": {
+			Mode:                      string(Offline),
+			Network:                   Testnet,
+			Port:                      "1000",
+			MaxConcurrentConstruction: "-1",
+			err:                       errors.New("max concurrent construction -1 must not be negative"),
+		},
+		"invalid finality depth": {
+			Mode:          string(Offline),
+			Network:       Testnet,
+			Port:          "1000",
+			FinalityDepth: "0",
+			err:           errors.New("finality depth 0 must be positive"),
+		},
+		"invalid dust relay fee": {
+			Mode:         string(Offline),
+			Network:      Testnet,
+			Port:         "1000",
+			DustRelayFee: "-1",
+			err:          errors.New("dust relay fee -1 must not be negative"),
+		},
+		"invalid max index height": {
+			Mode:           string(Offline),
+			Network:        Testnet,
+			Port:           "1000",
+			MaxIndexHeight: "0",
+			err:            errors.New("max index height 0 must be positive"),
+		},
+		"invalid prune reorg depth": {
+			Mode:            string(Offline),
+			Network:         Testnet,
+			Port:            "1000",
+			PruneReorgDepth: "-1",
+			err:             errors.New("prune reorg depth -1 must not be negative"),
+		},
+		"invalid balance coalesce": {
+			Mode:            string(Offline),
+			Network:         Testnet,
+			Port:            "1000",
+			BalanceCoalesce: "sometimes",
+			err:             errors.New("unable to parse balance coalesce sometimes"),
+		},
+		"invalid warm cache": {
+			Mode:      string(Offline),
+			Network:   Testnet,
+			Port:      "1000",
+			WarmCache: "0",
+			err:       errors.New("warm cache 0 must be positive"),
+		},
+		"invalid strict operations": {
+			Mode:             string(Offline),
+			Network:          Testnet,
+			Port:             "1000",
+			StrictOperations: "sometimes",
+			err:              errors.New("unable to parse strict operations sometimes"),
+		},
+		"invalid metrics enabled": {
+			Mode:           string(Offline),
+			Network:        Testnet,
+			Port:           "1000",
+			MetricsEnabled: "sometimes",
+			err:            errors.New("unable to parse metrics enabled sometimes"),
+		},
+		"invalid max metadata age": {
+			Mode:           string(Offline),
+			Network:        Testnet,
+			Port:           "1000",
+			MaxMetadataAge: "soon",
+			err:            errors.New("unable to parse max metadata age soon"),
+		},
+		"non-positive max metadata age": {
+			Mode:           string(Offline),
+			Network:        Testnet,
+			Port:           "1000",
+			MaxMetadataAge: "0s",
+			err:            errors.New("max metadata age 0s must be positive"),
+		},
+		"invalid confirmation target": {
+			Mode:               string(Offline),
+			Network:            Testnet,
+			Port:               "1000",
+			ConfirmationTarget: "soon",
+			err:                errors.New("unable to parse confirmation target soon"),
+		},
+		"out of range confirmation target": {
+			Mode:               string(Offline),
+			Network:            Testnet,
+			Port:               "1000",
+			ConfirmationTarget: "1009",
+			err:                errors.New("confirmation target 1009 must be between 1 and 1008"),
+		},
+		"invalid min fee rate": {
+			Mode:       string(Offline),
+			Network:    Testnet,
+			Port:       "1000",
+			MinFeeRate: "cheap",
+			err:        errors.New("unable to parse min fee rate cheap"),
+		},
+		"non-positive min fee rate": {
+			Mode:       string(Offline),
+			Network:    Testnet,
+			Port:       "1000",
+			MinFeeRate: "0",
+			err:        errors.New("min fee rate 0 must be positive"),
+		},
+		"invalid degrade to offline threshold": {
+			Mode:             string(Online),
+			Network:          Mainnet,
+			Port:             "1000",
+			DegradeToOffline: "often",
+			err:              errors.New("unable to parse degrade to offline threshold often"),
+		},
+		"non-positive degrade to offline threshold": {
+			Mode:             string(Online),
+			Network:          Mainnet,
+			Port:             "1000",
+			DegradeToOffline: "0",
+			err:              errors.New("degrade to offline threshold 0 must be positive"),
+		},
+		"invalid hash check interval": {
+			Mode:              string(Online),
+			Network:           Mainnet,
+			Port:              "1000",
+			HashCheckInterval: "often",
+			err:               errors.New("unable to parse hash check interval often"),
+		},
+		"non-positive hash check interval": {
+			Mode:              string(Online),
+			Network:           Mainnet,
+			Port:              "1000",
+			HashCheckInterval: "-1m",
+			err:               errors.New("hash check interval -1m0s must be positive"),
+		},
+		"invalid http read timeout": {
+			Mode:            string(Online),
+			Network:         Mainnet,
+			Port:            "1000",
+			HTTPReadTimeout: "slow",
+			err:             errors.New("unable to parse HTTP read timeout slow"),
+		},
+		"non-positive http write timeout": {
+			Mode:             string(Online),
+			Network:          Mainnet,
+			Port:             "1000",
+			HTTPWriteTimeout: "0s",
+			err:              errors.New("HTTP write timeout 0s must be positive"),
+		},
+		"non-positive http idle timeout": {
+			Mode:            string(Online),
+			Network:         Mainnet,
+			Port:            "1000",
+			HTTPIdleTimeout: "-1s",
+			err:             errors.New("HTTP idle timeout -1s must be positive"),
+		},
+		"invalid script types": {
+			Mode:        string(Online),
+			Network:     Mainnet,
+			Port:        "1000",
+			ScriptTypes: "sometimes",
+			err:         errors.New("unable to parse script types sometimes"),
+		},
+		"invalid ready blocks behind": {
+			Mode:              string(Online),
+			Network:           Mainnet,
+			Port:              "1000",
+			ReadyBlocksBehind: "some",
+			err:               errors.New("unable to parse ready blocks behind some"),
+		},
+		"negative ready blocks behind": {
+			Mode:              string(Online),
+			Network:           Mainnet,
+			Port:              "1000",
+			ReadyBlocksBehind: "-1",
+			err:               errors.New("ready blocks behind -1 must not be negative"),
+		},
+		"invalid rpc socket (tcp)": {
+			Mode:      string(Online),
+			Network:   Mainnet,
+			Port:      "1000",
+			RPCSocket: "tcp://localhost:8372",
+			err:       errors.New("RPC socket tcp://localhost:8372 must be a unix:// URL with an absolute path"),
+		},
+		"invalid rpc socket (relative)": {
+			Mode:      string(Online),
+			Network:   Mainnet,
+			Port:      "1000",
+			RPCSocket: "unix://rpc.sock",
+			err:       errors.New("RPC socket unix://rpc.sock must be a unix:// URL with an absolute path"),
+		},
+		"invalid log level": {
+			Mode:     string(Online),
+			Network:  Mainnet,
+			Port:     "1000",
+			LogLevel: "verbose",
+			err:      errors.New("unable to parse log level verbose"),
+		},
+		"unsupported log level": {
+			Mode:     string(Online),
+			Network:  Mainnet,
+			Port:     "1000",
+			LogLevel: "panic",
+			err:      errors.New("log level panic must be debug, info, warn, or error"),
+		},
+		"invalid block inline transactions": {
+			Mode:                    string(Online),
+			Network:                 Mainnet,
+			Port:                    "1000",
+			BlockInlineTransactions: "all",
+			err:                     errors.New("unable to parse block inline transactions all"),
+		},
+		"non-positive block inline transactions": {
+			Mode:                    string(Online),
+			Network:                 Mainnet,
+			Port:                    "1000",
+			BlockInlineTransactions: "0",
+			err:                     errors.New("block inline transactions 0 must be positive"),
+		},
+		"privileged port (strict, not root)": {
+			Mode:       string(Offline),
+			Network:    Testnet,
+			Port:       "443",
+			StrictPort: "true",
+			euid:       1000,
+			err:        errors.New("PORT 443 is privileged and requires running as root"),
+		},
+		"invalid strict port": {
+			Mode:       string(Offline),
+			Network:    Testnet,
+			Port:       "1000",
+			StrictPort: "yes please",
+			err:        errors.New("unable to parse strict port yes please"),
+		},
+		"invalid RPC port": {
+			Mode:    string(Offline),
+			Network: Testnet,
+			Port:    "1000",
+			RPCPort: "abc",
+			err:     errors.New("unable to parse RPC port abc"),
+		},
+		"non-positive RPC port": {
+			Mode:    string(Offline),
+			Network: Testnet,
+			Port:    "1000",
+			RPCPort: "-1",
+			err:     errors.New("RPC port -1 must be positive"),
+		},
+		"invalid pruning depth": {
+			Mode:         string(Offline),
+			Network:      Testnet,
+			Port:         "1000",
+			PruningDepth: "100",
+			err:          errors.New("pruning depth 100 must be at least 2160"),
+		},
+		"invalid pruning frequency": {
+			Mode:             string(Offline),
+			Network:          Testnet,
+			Port:             "1000",
+			PruningFrequency: "hourly",
+			err:              errors.New("unable to parse pruning frequency hourly"),
+		},
+		"invalid pruning disabled": {
+			Mode:            string(Offline),
+			Network:         Testnet,
+			Port:            "1000",
+			PruningDisabled: "nope",
+			err:             errors.New("unable to parse pruning disabled nope"),
+		},
+		"missing dictionary directory": {
+			Mode:          string(Offline),
+			Network:       Testnet,
+			Port:          "1000",
+			DictionaryDir: "/does/not/exist",
+			err:           errors.New("unable to load dictionaries from /does/not/exist"),
+		},
+		"missing config file": {
+			Mode:            string(Online),
+			Network:         Testnet,
+			Port:            "1000",
+			AppDir:          "/does/not/exist",
+			checkConfigFile: true,
+			err: errors.New(
+				"unable to find whived configuration file /does/not/exist/whive-testnet.conf",
+			),
+		},
+		// The config file isn't checked offline,
+		// so we only fail on the dictionary directory.
+		"missing config file (offline)": {
+			Mode:            string(Offline),
+			Network:         Testnet,
+			Port:            "1000",
+			AppDir:          "/does/not/exist",
+			checkConfigFile: true,
+			DictionaryDir:   "/does/not/exist",
+			err:             errors.New("unable to load dictionaries from /does/not/exist"),
+		},
+		"invalid RPC max response bytes": {
+			Mode:                string(Offline),
+			Network:             Testnet,
+			Port:                "1000",
+			RPCMaxResponseBytes: "0",
+			err:                 errors.New("RPC max response bytes 0 must be positive"),
+		},
+		"invalid storage shards": {
+			Mode:          string(Offline),
+			Network:       Testnet,
+			Port:          "1000",
+			StorageShards: "0",
+			err:           errors.New("storage shards 0 must be positive"),
+		},
+		"invalid gzip": {
+			Mode:    string(Offline),
+			Network: Testnet,
+			Port:    "1000",
+			Gzip:    "sometimes",
+			err:     errors.New("unable to parse HTTP_GZIP sometimes"),
+		},
+		"invalid gzip min size": {
+			Mode:        string(Offline),
+			Network:     Testnet,
+			Port:        "1000",
+			Gzip:        "true",
+			GzipMinSize: "-5",
+			err:         errors.New("HTTP_GZIP_MIN_SIZE -5 must not be negative"),
+		},
 	}
 
 	for name, test := range tests {
@@ -136,17 +2359,229 @@ func TestLoadConfiguration(t *testing.T) {
 			os.Setenv(ModeEnv, test.Mode)
 			os.Setenv(NetworkEnv, test.Network)
 			os.Setenv(PortEnv, test.Port)
+			os.Setenv(StrictPortEnv, test.StrictPort)
+			os.Setenv(RPCPortEnv, test.RPCPort)
+
+			dataDir := newDir
+			if len(test.DataDir) > 0 {
+				dataDir = path.Join(newDir, test.DataDir)
+				os.Setenv(DataDirectoryEnv, dataDir)
+			} else {
+				os.Setenv(DataDirectoryEnv, "")
+			}
+
+			appDir := AppDirectory
+			if len(test.AppDir) > 0 {
+				appDir = test.AppDir
+			}
+			os.Setenv(AppDirectoryEnv, test.AppDir)
+			os.Setenv(DictionaryDirectoryEnv, test.DictionaryDir)
+			os.Setenv(MaxBufferedBlocksEnv, test.MaxBufferedBlocks)
+			os.Setenv(SyncConcurrencyEnv, test.SyncConcurrency)
+			os.Setenv(MinFreeDiskEnv, test.MinFreeDisk)
+			os.Setenv(BlockRetryLimitEnv, test.BlockRetryLimit)
+			os.Setenv(BlockRetryDelayEnv, test.BlockRetryDelay)
+			os.Setenv(MaxTxOutputsEnv, test.MaxTxOutputs)
+			os.Setenv(ValidateNetworkEnv, test.ValidateNetwork)
+			os.Setenv(NodeWaitTimeoutEnv, test.NodeWaitTimeout)
+			os.Setenv(MinPeersAtStartupEnv, test.MinPeersAtStartup)
+			os.Setenv(IncludeMempoolEnv, test.IncludeMempool)
+			os.Setenv(DustRelayFeeEnv, test.DustRelayFee)
+			os.Setenv(MaxIndexHeightEnv, test.MaxIndexHeight)
+			os.Setenv(PruneReorgDepthEnv, test.PruneReorgDepth)
+			os.Setenv(PruningDisabledEnv, test.PruningDisabled)
+			os.Setenv(PruningDepthEnv, test.PruningDepth)
+			os.Setenv(PruningFrequencyEnv, test.PruningFrequency)
+			os.Setenv(BalanceCoalesceEnv, test.BalanceCoalesce)
+			os.Setenv(WarmCacheEnv, test.WarmCache)
+			os.Setenv(StrictOperationsEnv, test.StrictOperations)
+			os.Setenv(MetricsEnabledEnv, test.MetricsEnabled)
+			os.Setenv(MaxMetadataAgeEnv, test.MaxMetadataAge)
+			os.Setenv(ConfirmationTargetEnv, test.ConfirmationTarget)
+			os.Setenv(MinFeeRateEnv, test.MinFeeRate)
+			os.Setenv(DegradeToOfflineEnv, test.DegradeToOffline)
+			os.Setenv(HashCheckIntervalEnv, test.HashCheckInterval)
+			os.Setenv(HTTPReadTimeoutEnv, test.HTTPReadTimeout)
+			os.Setenv(HTTPWriteTimeoutEnv, test.HTTPWriteTimeout)
+			os.Setenv(HTTPIdleTimeoutEnv, test.HTTPIdleTimeout)
+			os.Setenv(ScriptTypesEnv, test.ScriptTypes)
+			os.Setenv(ReadyBlocksBehindEnv, test.ReadyBlocksBehind)
+			os.Setenv(RPCMaxResponseBytesEnv, test.RPCMaxResponseBytes)
+			os.Setenv(RPCBatchWindowEnv, test.RPCBatchWindow)
+			os.Setenv(RPCMaxConcurrencyEnv, test.RPCMaxConcurrency)
+			os.Setenv(RPCCookiePathEnv, test.RPCCookiePath)
+			os.Setenv(RPCSocketEnv, test.RPCSocket)
+			os.Setenv(LogLevelEnv, test.LogLevel)
+			os.Setenv(TipReorgCheckEnv, test.TipReorgCheck)
+			os.Setenv(BlockOperationTypesEnv, test.BlockOperationTypes)
+			os.Setenv(BlockInlineTransactionsEnv, test.BlockInlineTransactions)
+			os.Setenv(MaxConcurrentConstructionEnv, test.MaxConcurrentConstruction)
+			os.Setenv(FinalityDepthEnv, test.FinalityDepth)
+			os.Setenv(StorageShardsEnv, test.StorageShards)
+			os.Setenv(GzipEnv, test.Gzip)
+			os.Setenv(GzipMinSizeEnv, test.GzipMinSize)
+
+			geteuid = func() int { return test.euid }
+			defer func() { geteuid = os.Geteuid }()
+
+			if !test.checkConfigFile {
+				configFileChecker = func(string) error { return nil }
+				defer func() { configFileChecker = checkConfigFile }()
+			}
 
 			cfg, err := LoadConfiguration(newDir)
 			if test.err != nil {
 				assert.Nil(t, cfg)
 				assert.Contains(t, err.Error(), test.err.Error())
 			} else {
-				test.cfg.IndexerPath = path.Join(newDir, "indexer")
-				test.cfg.WhivedPath = path.Join(newDir, "whived")
+				test.cfg.IndexerPath = path.Join(dataDir, "indexer")
+				test.cfg.WhivedPath = path.Join(dataDir, "whived")
+				test.cfg.WhivedBinaryPath = path.Join(appDir, "whived")
+
+				// We warn about binding to privileged ports.
+				if test.cfg.Port < maxPrivilegedPort {
+					test.cfg.Warnings = []string{fmt.Sprintf(privilegedPortWarning, test.cfg.Port)}
+				}
 				assert.Equal(t, test.cfg, cfg)
 				assert.NoError(t, err)
 			}
 		})
 	}
 }
+
+func TestLoadDictionaryDirectory(t *testing.T) {
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	dictionary := append([]byte{}, zstdDictionaryMagic...)
+	dictionary = append(dictionary, []byte("trained")...)
+	assert.NoError(t, ioutil.WriteFile(path.Join(newDir, "block.zstd"), dictionary, 0600))
+	assert.NoError(t, ioutil.WriteFile(path.Join(newDir, "transaction.zstd"), dictionary, 0600))
+
+	// Files without the dictionary extension
+	// and directories are ignored.
+	assert.NoError(t, ioutil.WriteFile(path.Join(newDir, "README"), []byte("hello"), 0600))
+	assert.NoError(t, os.Mkdir(path.Join(newDir, "coin.zstd"), 0700))
+
+	defaults := []*encoder.CompressorEntry{
+		{
+			Namespace:      transactionNamespace,
+			DictionaryPath: path.Join(AppDirectory, testnetTransactionDictionary),
+		},
+	}
+	compressors, err := loadDictionaryDirectory(newDir, defaults)
+	assert.NoError(t, err)
+	assert.Equal(t, []*encoder.CompressorEntry{
+		{
+			Namespace:      transactionNamespace,
+			DictionaryPath: path.Join(newDir, "transaction.zstd"),
+		},
+		{
+			Namespace:      "block",
+			DictionaryPath: path.Join(newDir, "block.zstd"),
+		},
+	}, compressors)
+
+	// Files that are not zstd dictionaries are rejected.
+	assert.NoError(t, ioutil.WriteFile(path.Join(newDir, "balance.zstd"), []byte("hello"), 0600))
+	compressors, err = loadDictionaryDirectory(newDir, defaults)
+	assert.Nil(t, compressors)
+	assert.Contains(t, err.Error(), "balance.zstd is not a zstd dictionary")
+}
+
+func TestCheckConfigFile(t *testing.T) {
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	configPath := path.Join(newDir, testnetConfigFile)
+	assert.NoError(t, ioutil.WriteFile(configPath, []byte("testnet=1"), 0600))
+	assert.NoError(t, checkConfigFile(configPath))
+
+	err = checkConfigFile(path.Join(newDir, mainnetConfigFile))
+	assert.Contains(t, err.Error(), "unable to find whived configuration file "+
+		path.Join(newDir, mainnetConfigFile))
+
+	err = checkConfigFile(newDir)
+	assert.Contains(t, err.Error(), newDir+" is a directory")
+}
+
+func TestValidate(t *testing.T) {
+	valid := func() *Configuration {
+		return &Configuration{
+			Mode: Online,
+			Network: &types.NetworkIdentifier{
+				Blockchain: whive.Blockchain,
+				Network:    whive.TestnetNetwork,
+			},
+			Params:      whive.TestnetParams,
+			Port:        1000,
+			IndexerPath: "/data/indexer",
+			WhivedPath:  "/data/whived",
+		}
+	}
+
+	tests := map[string]struct {
+		modify func(*Configuration)
+
+		err error
+	}{
+		"valid (online)": {
+			modify: func(c *Configuration) {},
+		},
+		"valid (offline)": {
+			modify: func(c *Configuration) {
+				c.Mode = Offline
+				c.IndexerPath = ""
+				c.WhivedPath = ""
+			},
+		},
+		"missing mode": {
+			modify: func(c *Configuration) { c.Mode = "" },
+			err:    errors.New("mode must be populated"),
+		},
+		"invalid mode": {
+			modify: func(c *Configuration) { c.Mode = "bad mode" },
+			err:    errors.New("bad mode is not a valid mode"),
+		},
+		"missing network": {
+			modify: func(c *Configuration) { c.Network = nil },
+			err:    errors.New("network must be populated"),
+		},
+		"invalid network": {
+			modify: func(c *Configuration) { c.Network.Network = "bad network" },
+			err:    errors.New("bad network is not a valid network"),
+		},
+		"mismatched params": {
+			modify: func(c *Configuration) { c.Params = whive.MainnetParams },
+			err:    errors.New("params do not match network Testnet3"),
+		},
+		"invalid port": {
+			modify: func(c *Configuration) { c.Port = 0 },
+			err:    errors.New("port 0 must be positive"),
+		},
+		"missing indexer path": {
+			modify: func(c *Configuration) { c.IndexerPath = "" },
+			err:    errors.New("indexer path must be populated in online mode"),
+		},
+		"missing whived path": {
+			modify: func(c *Configuration) { c.WhivedPath = "" },
+			err:    errors.New("whived path must be populated in online mode"),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			config := valid()
+			test.modify(config)
+
+			err := config.Validate()
+			if test.err != nil {
+				assert.Contains(t, err.Error(), test.err.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -10,6 +10,9 @@ require (
 	github.com/coinbase/rosetta-sdk-go/types v1.0.0
 	github.com/dgraph-io/badger/v2 v2.2007.4
 	github.com/grpc-ecosystem/go-grpc-middleware v1.4.0
+	github.com/neilotoole/errgroup v0.1.6
+	github.com/prometheus/client_golang v1.11.1
+	github.com/prometheus/client_model v0.2.0
 	github.com/stretchr/testify v1.8.4
 	go.uber.org/zap v1.24.0
 	golang.org/x/sync v0.3.0
@@ -0,0 +1,133 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+const (
+	// balanceCoalesceWindow is how long we wait for
+	// concurrent balance lookups to combine into a
+	// single storage read.
+	balanceCoalesceWindow = 2 * time.Millisecond
+)
+
+// balanceRequest is a single balance lookup. The
+// amount (or err) is populated by getBalances.
+type balanceRequest struct {
+	account  *types.AccountIdentifier
+	currency *types.Currency
+
+	amount *types.Amount
+	err    error
+}
+
+// balanceBatch is a group of balance lookups at the
+// same block that are served by a single storage read.
+type balanceBatch struct {
+	blockIdentifier *types.PartialBlockIdentifier
+	requests        []*balanceRequest
+
+	// block and err are populated before done is closed.
+	done  chan struct{}
+	block *types.BlockIdentifier
+	err   error
+}
+
+// balanceCoalescer groups balance lookups that arrive
+// within window of each other (at the same block) so
+// that bursts of /account/balance requests (e.g. a wallet
+// rescan) share a storage read.
+type balanceCoalescer struct {
+	window time.Duration
+	fetch  func(
+		context.Context,
+		*types.PartialBlockIdentifier,
+		[]*balanceRequest,
+	) (*types.BlockIdentifier, error)
+
+	batches map[string]*balanceBatch
+	mutex   sync.Mutex
+}
+
+// newBalanceCoalescer returns a new *balanceCoalescer
+// that serves each batch with fetch.
+func newBalanceCoalescer(
+	window time.Duration,
+	fetch func(
+		context.Context,
+		*types.PartialBlockIdentifier,
+		[]*balanceRequest,
+	) (*types.BlockIdentifier, error),
+) *balanceCoalescer {
+	return &balanceCoalescer{
+		window:  window,
+		fetch:   fetch,
+		batches: map[string]*balanceBatch{},
+	}
+}
+
+// get adds request to the pending batch at blockIdentifier
+// (creating it if it doesn't exist) and returns once the
+// batch has been fetched.
+func (c *balanceCoalescer) get(
+	ctx context.Context,
+	blockIdentifier *types.PartialBlockIdentifier,
+	request *balanceRequest,
+) (*types.BlockIdentifier, error) {
+	key := types.Hash(blockIdentifier)
+
+	c.mutex.Lock()
+	batch, ok := c.batches[key]
+	if !ok {
+		batch = &balanceBatch{
+			blockIdentifier: blockIdentifier,
+			done:            make(chan struct{}),
+		}
+		c.batches[key] = batch
+
+		// The batch is fetched with a background context
+		// so that the first caller canceling its request
+		// does not fail the rest of the batch.
+		time.AfterFunc(c.window, func() {
+			c.flush(context.Background(), key, batch)
+		})
+	}
+	batch.requests = append(batch.requests, request)
+	c.mutex.Unlock()
+
+	select {
+	case <-batch.done:
+		return batch.block, batch.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// flush removes batch from the pending batches
+// and fetches all of its balances.
+func (c *balanceCoalescer) flush(ctx context.Context, key string, batch *balanceBatch) {
+	c.mutex.Lock()
+	delete(c.batches, key)
+	c.mutex.Unlock()
+
+	batch.block, batch.err = c.fetch(ctx, batch.blockIdentifier, batch.requests)
+	close(batch.done)
+}
@@ -35,5 +35,7 @@ func (h *CoinStorageHelper) CurrentBlockIdentifier(
 	ctx context.Context,
 	transaction database.Transaction,
 ) (*types.BlockIdentifier, error) {
-	return h.b.GetHeadBlockIdentifierTransactional(ctx, transaction)
+	// CoinStorage may be provided a shard transaction,
+	// which cannot access the head block.
+	return h.b.GetHeadBlockIdentifierTransactional(ctx, unwrapShardTransaction(transaction))
 }
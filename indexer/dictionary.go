@@ -0,0 +1,90 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/xyephy/rosetta-whive/configuration"
+
+	"github.com/coinbase/rosetta-sdk-go/storage/database"
+)
+
+var (
+	// errNoDictionary is returned when training a dictionary
+	// for a namespace that has no configured compressor.
+	errNoDictionary = errors.New("no dictionary configured for namespace")
+)
+
+// dictionaryPath returns the path of the dictionary
+// configured for namespace.
+func dictionaryPath(config *configuration.Configuration, namespace string) (string, error) {
+	for _, entry := range config.Compressors {
+		if entry.Namespace == namespace {
+			return entry.DictionaryPath, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w %s", errNoDictionary, namespace)
+}
+
+// TrainDictionary trains a new Zstandard dictionary for
+// namespace using up to samples entries from the indexer's
+// storage and writes it to the namespace's configured
+// DictionaryPath. The dictionary is trained to a temporary
+// file first so that a failed training run does not
+// overwrite the existing dictionary.
+//
+// The indexer must not be running when this is called
+// because storage is opened directly. Entries already in
+// storage were compressed with the previous dictionary,
+// so the new dictionary can only be used with new storage.
+func TrainDictionary(
+	ctx context.Context,
+	config *configuration.Configuration,
+	namespace string,
+	samples int,
+) (string, error) {
+	output, err := dictionaryPath(config, namespace)
+	if err != nil {
+		return "", err
+	}
+
+	if samples <= 0 {
+		return "", fmt.Errorf("sample count %d must be positive", samples)
+	}
+
+	tmpOutput := fmt.Sprintf("%s.tmp", output)
+	if _, _, err := database.BadgerTrain(
+		ctx,
+		namespace,
+		config.IndexerPath,
+		tmpOutput,
+		samples,
+		config.Compressors,
+	); err != nil {
+		_ = os.Remove(tmpOutput)
+		return "", fmt.Errorf("%w: unable to train dictionary for %s", err, namespace)
+	}
+
+	if err := os.Rename(tmpOutput, output); err != nil {
+		return "", fmt.Errorf("%w: unable to write dictionary to %s", err, output)
+	}
+
+	return output, nil
+}
@@ -0,0 +1,205 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/coinbase/rosetta-sdk-go/storage/database"
+	"github.com/coinbase/rosetta-sdk-go/storage/modules"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/neilotoole/errgroup"
+)
+
+const (
+	// firstSeenNamespace is prepended to the address of
+	// each account to store the first block in which
+	// the account received a coin.
+	firstSeenNamespace = "first_seen"
+
+	// firstSeenStartKey is the key used to store the index
+	// of the first block indexed while tracking first seen
+	// blocks. Storage populated before first seen blocks were
+	// tracked starts tracking at the next block added.
+	firstSeenStartKey = "first_seen_start"
+)
+
+func getFirstSeenKey(account *types.AccountIdentifier) []byte {
+	return []byte(fmt.Sprintf("%s/%s", firstSeenNamespace, account.Address))
+}
+
+var _ modules.BlockWorker = (*firstSeenWorker)(nil)
+
+// firstSeenWorker records the first block in which
+// each account received a coin.
+type firstSeenWorker struct{}
+
+// createdAccounts returns the accounts that
+// receive a coin in block (without duplicates).
+func createdAccounts(block *types.Block) []*types.AccountIdentifier {
+	seen := map[string]struct{}{}
+	accounts := []*types.AccountIdentifier{}
+	for _, tx := range block.Transactions {
+		for _, op := range tx.Operations {
+			if op.Account == nil || op.CoinChange == nil {
+				continue
+			}
+
+			if op.CoinChange.CoinAction != types.CoinCreated {
+				continue
+			}
+
+			if _, ok := seen[op.Account.Address]; ok {
+				continue
+			}
+
+			seen[op.Account.Address] = struct{}{}
+			accounts = append(accounts, op.Account)
+		}
+	}
+
+	return accounts
+}
+
+// getFirstSeen returns the first block in which account
+// received a coin (or nil if it has not received one).
+func getFirstSeen(
+	ctx context.Context,
+	dbTx database.Transaction,
+	account *types.AccountIdentifier,
+) (*types.BlockIdentifier, error) {
+	exists, value, err := dbTx.Get(ctx, getFirstSeenKey(account))
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to get first seen block of %s", err, account.Address)
+	}
+
+	if !exists {
+		return nil, nil
+	}
+
+	var block types.BlockIdentifier
+	if err := json.Unmarshal(value, &block); err != nil {
+		return nil, fmt.Errorf("%w: unable to parse first seen block of %s", err, account.Address)
+	}
+
+	return &block, nil
+}
+
+// AddingBlock is called by BlockStorage when adding a block.
+func (w *firstSeenWorker) AddingBlock(
+	ctx context.Context,
+	g *errgroup.Group,
+	block *types.Block,
+	transaction database.Transaction,
+) (database.CommitWorker, error) {
+	exists, _, err := transaction.Get(ctx, []byte(firstSeenStartKey))
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to get first seen start", err)
+	}
+
+	if !exists {
+		start := []byte(strconv.FormatInt(block.BlockIdentifier.Index, 10))
+		if err := transaction.Set(ctx, []byte(firstSeenStartKey), start, true); err != nil {
+			return nil, fmt.Errorf("%w: unable to set first seen start", err)
+		}
+	}
+
+	value, err := json.Marshal(block.BlockIdentifier)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to marshal block identifier", err)
+	}
+
+	for _, account := range createdAccounts(block) {
+		firstSeen, err := getFirstSeen(ctx, transaction, account)
+		if err != nil {
+			return nil, err
+		}
+
+		if firstSeen != nil {
+			continue
+		}
+
+		if err := transaction.Set(ctx, getFirstSeenKey(account), value, true); err != nil {
+			return nil, fmt.Errorf("%w: unable to set first seen block of %s", err, account.Address)
+		}
+	}
+
+	return nil, nil
+}
+
+// RemovingBlock is called by BlockStorage when removing a block.
+func (w *firstSeenWorker) RemovingBlock(
+	ctx context.Context,
+	g *errgroup.Group,
+	block *types.Block,
+	transaction database.Transaction,
+) (database.CommitWorker, error) {
+	for _, account := range createdAccounts(block) {
+		firstSeen, err := getFirstSeen(ctx, transaction, account)
+		if err != nil {
+			return nil, err
+		}
+
+		if firstSeen == nil || types.Hash(firstSeen) != types.Hash(block.BlockIdentifier) {
+			continue
+		}
+
+		if err := transaction.Delete(ctx, getFirstSeenKey(account)); err != nil {
+			return nil, fmt.Errorf(
+				"%w: unable to delete first seen block of %s",
+				err,
+				account.Address,
+			)
+		}
+	}
+
+	return nil, nil
+}
+
+// GetFirstSeen returns the first block in which account
+// received a coin and the index of the first block that
+// was tracked. If the account was only funded before
+// tracking began (in storage populated by an older version),
+// the first seen block is nil or later than the true one.
+func (i *Indexer) GetFirstSeen(
+	ctx context.Context,
+	account *types.AccountIdentifier,
+) (*types.BlockIdentifier, int64, error) {
+	dbTx := i.database.ReadTransaction(ctx)
+	defer dbTx.Discard(ctx)
+
+	exists, value, err := dbTx.Get(ctx, []byte(firstSeenStartKey))
+	if err != nil {
+		return nil, -1, fmt.Errorf("%w: unable to get first seen start", err)
+	}
+
+	var start int64
+	if exists {
+		start, err = strconv.ParseInt(string(value), 10, 64)
+		if err != nil {
+			return nil, -1, fmt.Errorf("%w: unable to parse first seen start %s", err, string(value))
+		}
+	}
+
+	firstSeen, err := getFirstSeen(ctx, dbTx, account)
+	if err != nil {
+		return nil, -1, err
+	}
+
+	return firstSeen, start, nil
+}
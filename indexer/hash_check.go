@@ -0,0 +1,148 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/xyephy/rosetta-whive/metrics"
+	"github.com/xyephy/rosetta-whive/utils"
+
+	storageErrs "github.com/coinbase/rosetta-sdk-go/storage/errors"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+const (
+	// hashCheckSamples is the number of indexed heights
+	// whose block hashes are compared with whived's in
+	// each hash check.
+	hashCheckSamples = 3
+
+	// hashCheckWindow is the number of recently
+	// indexed heights we sample from.
+	hashCheckWindow = 1000
+
+	// hashCheckMinDepth is the number of blocks below
+	// our head that are not sampled, so that a reorg
+	// the syncer hasn't processed yet isn't reported
+	// as a mismatch.
+	hashCheckMinDepth = 6
+)
+
+// MonitorBlockHashes compares the hashes of a sample of
+// recently indexed blocks with the hashes of the blocks at
+// the same heights on whived's best chain every
+// hashCheckInterval. Mismatches are logged and counted in
+// metrics.IndexerHashMismatches.
+func (i *Indexer) MonitorBlockHashes(ctx context.Context) error {
+	logger := utils.ExtractLogger(ctx, "hash checker")
+
+	tc := time.NewTicker(i.hashCheckInterval)
+	defer tc.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Warnw("exiting hash checker")
+			return ctx.Err()
+		case <-tc.C:
+		}
+
+		if _, err := i.checkBlockHashes(ctx); err != nil {
+			logger.Warnw("unable to check block hashes", "error", err)
+		}
+	}
+}
+
+// sampleHeights returns up to count distinct heights
+// between lowest and highest (inclusive) in order.
+func sampleHeights(lowest int64, highest int64, count int) []int64 {
+	sampled := map[int64]struct{}{}
+	heights := []int64{}
+	for len(heights) < count && int64(len(heights)) <= highest-lowest {
+		height := lowest + rand.Int63n(highest-lowest+1)
+		if _, ok := sampled[height]; ok {
+			continue
+		}
+
+		sampled[height] = struct{}{}
+		heights = append(heights, height)
+	}
+	sort.Slice(heights, func(a, b int) bool { return heights[a] < heights[b] })
+
+	return heights
+}
+
+// checkBlockHashes compares the hashes of a sample of recently
+// indexed blocks with whived's and returns the heights where
+// they differ.
+func (i *Indexer) checkBlockHashes(ctx context.Context) ([]int64, error) {
+	// Blocks are expected to differ from whived's
+	// until a reorg is complete.
+	if i.reorgInProgress() {
+		return nil, nil
+	}
+
+	head, err := i.blockStorage.GetHeadBlockIdentifier(ctx)
+	if errors.Is(err, storageErrs.ErrHeadBlockNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to get head block identifier", err)
+	}
+
+	highest := head.Index - hashCheckMinDepth
+	if highest < 0 {
+		return nil, nil
+	}
+
+	lowest := highest - hashCheckWindow + 1
+	if lowest < 0 {
+		lowest = 0
+	}
+
+	logger := utils.ExtractLogger(ctx, "hash checker")
+	mismatches := []int64{}
+	for _, height := range sampleHeights(lowest, highest, hashCheckSamples) {
+		index := height
+		block, err := i.blockStorage.GetBlockLazy(ctx, &types.PartialBlockIdentifier{Index: &index})
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to get block %d", err, height)
+		}
+
+		hash, err := i.client.BlockHash(ctx, height)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to get hash of block %d from whived", err, height)
+		}
+
+		if hash != block.Block.BlockIdentifier.Hash {
+			logger.Errorw(
+				"indexed block hash does not match whived",
+				"index", height,
+				"indexed hash", block.Block.BlockIdentifier.Hash,
+				"whived hash", hash,
+			)
+			metrics.IndexerHashMismatches.Inc()
+			mismatches = append(mismatches, height)
+		}
+	}
+
+	return mismatches, nil
+}
@@ -23,10 +23,12 @@ import (
 	"time"
 
 	"github.com/xyephy/rosetta-whive/configuration"
-	"github.com/xyephy/rosetta-whive/whive"
+	"github.com/xyephy/rosetta-whive/metrics"
 	"github.com/xyephy/rosetta-whive/services"
 	"github.com/xyephy/rosetta-whive/utils"
+	"github.com/xyephy/rosetta-whive/whive"
 
+	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/coinbase/rosetta-sdk-go/asserter"
 	"github.com/coinbase/rosetta-sdk-go/storage/database"
 	storageErrs "github.com/coinbase/rosetta-sdk-go/storage/errors"
@@ -47,12 +49,32 @@ const (
 	// a particular height).
 	indexPlaceholder = -1
 
-	retryDelay = 10 * time.Second
-	retryLimit = 5
-
 	nodeWaitSleep           = 3 * time.Second
 	missingTransactionDelay = 200 * time.Millisecond
 
+	// tipReorgSleep is how long we sleep between checks
+	// of whether whived has a block past our head after
+	// orphaning blocks whived no longer considers canonical.
+	tipReorgSleep = time.Second
+
+	// backpressureDelay is how long we sleep between
+	// checks of whether storage has caught up with
+	// fetched blocks.
+	backpressureDelay = 50 * time.Millisecond
+
+	// diskSpaceFrequency is how often we check
+	// free disk space on the data directory.
+	diskSpaceFrequency = 10 * time.Second
+
+	// diskSpaceDelay is how long we sleep between
+	// checks of whether indexing may resume after
+	// free disk space fell below the minimum.
+	diskSpaceDelay = 1 * time.Second
+
+	// storageSizeFrequency is how often we compute
+	// the size of the indexer storage directory.
+	storageSizeFrequency = 1 * time.Minute
+
 	// sizeMultiplier is used to multiply the memory
 	// estimate for pre-fetching blocks. In other words,
 	// this is the estimated memory overhead for each
@@ -74,12 +96,20 @@ const (
 
 var (
 	errMissingTransaction = errors.New("missing transaction")
+	errDiskSpaceLow       = errors.New("free disk space is below minimum")
+	errNodeWaitTimeout    = errors.New("timed out waiting for whived")
+	errNotEnoughPeers     = errors.New("whived does not have enough peers")
+	errIndexerStopped     = errors.New("indexer is stopped")
 )
 
 // Client is used by the indexer to sync blocks.
 type Client interface {
+	BlockHash(context.Context, int64) (string, error)
+	BlockchainInfo(context.Context) (*whive.BlockchainInfo, error)
 	NetworkStatus(context.Context) (*types.NetworkStatusResponse, error)
 	PruneBlockchain(context.Context, int64) (int64, error)
+	ValidateNetwork(context.Context, *chaincfg.Params) error
+	TxIndexEnabled(context.Context) (bool, error)
 	GetRawBlock(context.Context, *types.PartialBlockIdentifier) (*whive.Block, []string, error)
 	ParseBlock(
 		context.Context,
@@ -99,23 +129,75 @@ type Indexer struct {
 	network       *types.NetworkIdentifier
 	pruningConfig *configuration.PruningConfiguration
 
-	client Client
-
-	asserter       *asserter.Asserter
-	database       database.Database
-	blockStorage   *modules.BlockStorage
-	balanceStorage *modules.BalanceStorage
-	coinStorage    *modules.CoinStorage
-	workers        []modules.BlockWorker
+	// When validateNetwork is true, we halt if whived
+	// is not running on the network specified by params.
+	params          *chaincfg.Params
+	validateNetwork bool
+
+	// If nodeWaitTimeout is non-zero, we stop waiting
+	// for whived to become ready after nodeWaitTimeout.
+	nodeWaitTimeout time.Duration
+
+	// We don't start indexing until whived
+	// has at least minPeers peers.
+	minPeers int
+
+	// The syncer only detects a reorg when it fetches a
+	// block whose parent is not our head. When tipReorgCheck
+	// is true and whived's best chain no longer includes our
+	// head, we set orphanIndex to the index after our head
+	// so that the syncer orphans blocks until it reaches
+	// whived's best chain.
+	tipReorgCheck bool
+	orphanIndex   int64
+	orphanMutex   sync.Mutex
+
+	// While a reorg is in progress (reorgHead is
+	// the index of our head before the reorg), we
+	// don't prune so that whived doesn't discard
+	// blocks the syncer may need to fetch. reorgDepth
+	// is the number of blocks orphaned by the reorg.
+	reorgHead  int64
+	reorgDepth int64
+	reorgStart time.Time
+	reorgMutex sync.Mutex
+
+	// If maxIndexHeight is non-zero, we stop
+	// syncing once we have indexed the block at
+	// maxIndexHeight.
+	maxIndexHeight int64
+
+	client          Client
+	blockRetryLimit int
+	blockRetryDelay time.Duration
+
+	asserter     *asserter.Asserter
+	database     database.Database
+	blockStorage *modules.BlockStorage
+	shards       []*storageShard
+	workers      []modules.BlockWorker
 
 	waiter *waitTable
 
+	// When balanceCoalescer is not nil, concurrent
+	// balance lookups are grouped into a single
+	// storage read.
+	balanceCoalescer *balanceCoalescer
+
 	// Store coins created in pre-store before persisted
 	// in add block so we can optimistically populate
 	// blocks before committed.
 	coinCache      map[string]*types.AccountCoin
 	coinCacheMutex *sdkUtils.PriorityMutex
 
+	// When warmCacheSize is non-zero, we load up to
+	// warmCacheSize of the most recently created unspent
+	// coins into warmCoins on startup. warmCoins is checked
+	// before storage when looking up the coins spent by a
+	// block (and is protected by coinCacheMutex).
+	warmCacheSize int
+	warmCoins     map[string]*types.AccountCoin
+
 	// When populating blocks using pre-stored blocks,
 	// we should retry if a new block was seen (similar
 	// to trying again if head block changes).
@@ -123,11 +205,75 @@ type Indexer struct {
 	seenMutex sync.Mutex
 
 	seenSemaphore *semaphore.Weighted
+
+	// To prevent the syncer from buffering an unbounded
+	// number of blocks when storage writes slow down, we
+	// only fetch blocks within maxBufferedBlocks of the
+	// last block added to storage.
+	maxBufferedBlocks int64
+	lastAdded         int64
+	lastAddedMutex    sync.Mutex
+
+	// syncConcurrency is the maximum number of blocks
+	// the syncer fetches concurrently (if non-zero).
+	syncConcurrency int64
+
+	// Block timestamps are not required to be monotonic,
+	// so we only warn when a block's timestamp is before the
+	// timestamp of its parent (lastTimestamp). lastTimestamp
+	// is 0 when the parent timestamp is not known.
+	lastTimestamp int64
+
+	// To avoid corrupting storage when the disk fills
+	// up, we pause indexing while free disk space on
+	// indexerPath is below minFreeDisk.
+	indexerPath  string
+	minFreeDisk  uint64
+	diskFree     func(string) (uint64, error)
+	diskLow      bool
+	diskLowMutex sync.Mutex
+
+	// If hashCheckInterval is non-zero, MonitorBlockHashes
+	// compares a sample of indexed block hashes with
+	// whived's every hashCheckInterval.
+	hashCheckInterval time.Duration
+
+	// Walking indexerPath is expensive, so we cache
+	// the size of storage and update it periodically.
+	dirSize          func(string) (uint64, error)
+	storageSize      uint64
+	storageSizeMutex sync.Mutex
+
+	// blocksBehind is updated each time the
+	// syncer polls whived's status (and is
+	// unknown until it first does).
+	blocksBehind      int64
+	blocksBehindKnown bool
+	blocksBehindMutex sync.Mutex
+
+	// txIndexEnabled is determined when we start
+	// syncing. Until then, we assume -txindex is
+	// enabled and let whived reject lookups.
+	txIndexEnabled      bool
+	txIndexEnabledMutex sync.Mutex
+
+	// writeMutex is held while a block is added to or
+	// removed from storage so that we can wait for the
+	// current write to finish before closing the database.
+	// Once stopped, no more blocks are written.
+	writeMutex sync.Mutex
+	stopped    bool
 }
 
 // CloseDatabase closes a storage.Database. This should be called
-// before exiting.
+// before exiting. To avoid leaving storage partially written,
+// it waits for the block currently being added to or removed
+// from storage (if any) and no more blocks are written after.
 func (i *Indexer) CloseDatabase(ctx context.Context) {
+	i.writeMutex.Lock()
+	defer i.writeMutex.Unlock()
+	i.stopped = true
+
 	logger := utils.ExtractLogger(ctx, "")
 	err := i.database.Close(ctx)
 	if err != nil {
@@ -137,6 +283,28 @@ func (i *Indexer) CloseDatabase(ctx context.Context) {
 	logger.Infow("database closed successfully")
 }
 
+// StopWrites waits for the block currently being added to
+// or removed from storage (if any) to be written and
+// prevents any more blocks from being written. This should
+// be called before closing the database. If ctx is done
+// before the write finishes, ctx.Err() is returned.
+func (i *Indexer) StopWrites(ctx context.Context) error {
+	stopped := make(chan struct{})
+	go func() {
+		i.writeMutex.Lock()
+		i.stopped = true
+		i.writeMutex.Unlock()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // defaultBadgerOptions returns a set of badger.Options optimized
 // for running a Rosetta implementation.
 func defaultBadgerOptions(
@@ -212,6 +380,9 @@ func Initialize(
 		whive.OperationStatuses,
 		services.Errors,
 		nil,
+		&asserter.Validations{
+			Enabled: false,
+		},
 	)
 	if err != nil {
 		return nil, fmt.Errorf("%w: unable to initialize asserter", err)
@@ -229,38 +400,105 @@ func Initialize(
 		coinCache:      map[string]*types.AccountCoin{},
 		coinCacheMutex: new(sdkUtils.PriorityMutex),
 		seenSemaphore:  semaphore.NewWeighted(int64(runtime.NumCPU())),
+		warmCacheSize:  config.WarmCacheSize,
+
+		blockRetryLimit: config.BlockRetryLimit,
+		blockRetryDelay: config.BlockRetryDelay,
+
+		params:          config.Params,
+		validateNetwork: config.ValidateNetwork,
+		nodeWaitTimeout: config.NodeWaitTimeout,
+		minPeers:        config.MinPeersAtStartup,
+
+		tipReorgCheck: config.TipReorgCheck,
+		orphanIndex:   indexPlaceholder,
+		reorgHead:     indexPlaceholder,
+
+		maxIndexHeight: config.MaxIndexHeight,
+
+		maxBufferedBlocks: config.MaxBufferedBlocks,
+		syncConcurrency:   config.SyncConcurrency,
+		lastAdded:         indexPlaceholder,
+
+		indexerPath: config.IndexerPath,
+		minFreeDisk: config.MinFreeDisk,
+		diskFree:    utils.DiskFree,
+
+		dirSize: utils.DirSize,
+
+		hashCheckInterval: config.HashCheckInterval,
+
+		txIndexEnabled: true,
 	}
 
-	coinStorage := modules.NewCoinStorage(
+	if err := checkStorageShards(ctx, localStore, blockStorage, config.StorageShards); err != nil {
+		return nil, err
+	}
+	i.shards, i.workers = newStorageShards(
 		localStore,
-		&CoinStorageHelper{blockStorage},
+		blockStorage,
 		asserter,
+		config.StorageShards,
 	)
-	i.coinStorage = coinStorage
+	i.workers = append(i.workers, &firstSeenWorker{})
 
-	balanceStorage := modules.NewBalanceStorage(localStore)
-	balanceStorage.Initialize(
-		&BalanceStorageHelper{asserter},
-		&BalanceStorageHandler{},
-	)
-	i.balanceStorage = balanceStorage
-
-	i.workers = []modules.BlockWorker{coinStorage, balanceStorage}
+	if config.BalanceCoalesce {
+		i.balanceCoalescer = newBalanceCoalescer(balanceCoalesceWindow, i.getBalances)
+	}
 
 	return i, nil
 }
 
 // waitForNode returns once bitcoind is ready to serve
-// block queries.
+// block queries and has at least minPeers peers. If whived
+// is loading or rebuilding its block index, we log its
+// progress while waiting.
 func (i *Indexer) waitForNode(ctx context.Context) error {
 	logger := utils.ExtractLogger(ctx, "indexer")
+	start := time.Now()
 	for {
-		_, err := i.client.NetworkStatus(ctx)
+		status, err := i.client.NetworkStatus(ctx)
 		if err == nil {
-			return nil
+			if len(status.Peers) >= i.minPeers {
+				return nil
+			}
+
+			err = fmt.Errorf(
+				"%w: %d of %d peers",
+				errNotEnoughPeers,
+				len(status.Peers),
+				i.minPeers,
+			)
+		}
+
+		elapsed := time.Since(start)
+		if i.nodeWaitTimeout > 0 && elapsed >= i.nodeWaitTimeout {
+			return fmt.Errorf(
+				"%w: whived not ready after %s: %s",
+				errNodeWaitTimeout,
+				elapsed,
+				err.Error(),
+			)
+		}
+
+		switch {
+		case errors.Is(err, whive.ErrNodeWarmingUp):
+			logger.Infow(
+				"waiting for whived to load block index...",
+				"status", err.Error(),
+				"elapsed", elapsed.String(),
+			)
+		case errors.Is(err, errNotEnoughPeers):
+			logger.Infow(
+				"waiting for whived peers...",
+				"peers", len(status.Peers),
+				"min peers", i.minPeers,
+				"elapsed", elapsed.String(),
+			)
+		default:
+			logger.Infow("waiting for whived...")
 		}
 
-		logger.Infow("waiting for whived...")
 		if err := sdkUtils.ContextSleep(ctx, nodeWaitSleep); err != nil {
 			return err
 		}
@@ -268,18 +506,66 @@ func (i *Indexer) waitForNode(ctx context.Context) error {
 }
 
 // Sync attempts to index Bitcoin blocks using
-// the whive.Client until stopped.
+// the whive.Client until stopped (or until the
+// block at maxIndexHeight is indexed).
 func (i *Indexer) Sync(ctx context.Context) error {
-	if err := i.waitForNode(ctx); err != nil {
-		return fmt.Errorf("%w: failed to wait for node", err)
+	if err := i.prepareSync(ctx); err != nil {
+		return err
 	}
 
-	i.blockStorage.Initialize(i.workers)
-
 	startIndex := int64(indexPlaceholder)
 	head, err := i.blockStorage.GetHeadBlockIdentifier(ctx)
 	if err == nil {
 		startIndex = head.Index + 1
+		i.setLastAdded(head.Index, 0)
+	}
+
+	endIndex := int64(indexPlaceholder)
+	if i.maxIndexHeight > 0 {
+		endIndex = i.maxIndexHeight
+	}
+
+	if err := i.runSyncer(ctx, startIndex, endIndex); err != nil {
+		return err
+	}
+
+	// The syncer only returns without an error
+	// once it has synced to endIndex.
+	logger := utils.ExtractLogger(ctx, "indexer")
+	logger.Infow("reached max index height, no longer syncing", "max index height", endIndex)
+
+	return nil
+}
+
+// prepareSync waits for whived to be ready and
+// initializes block storage before we add or
+// remove any blocks.
+func (i *Indexer) prepareSync(ctx context.Context) error {
+	if err := i.waitForNode(ctx); err != nil {
+		return fmt.Errorf("%w: failed to wait for node", err)
+	}
+
+	if err := i.checkNetwork(ctx); err != nil {
+		return err
+	}
+
+	i.checkTxIndex(ctx)
+
+	i.blockStorage.Initialize(i.workers)
+
+	return nil
+}
+
+// runSyncer syncs blocks from startIndex to endIndex
+// (indefinitely if endIndex is indexPlaceholder).
+func (i *Indexer) runSyncer(ctx context.Context, startIndex int64, endIndex int64) error {
+	// A cold coin cache only slows down syncing,
+	// so we don't halt if we can't warm it.
+	if i.warmCacheSize > 0 {
+		if err := i.warmCoinCache(ctx); err != nil {
+			logger := utils.ExtractLogger(ctx, "indexer")
+			logger.Warnw("unable to warm coin cache", "error", err)
+		}
 	}
 
 	// Load in previous blocks into syncer cache to handle reorgs.
@@ -288,24 +574,40 @@ func (i *Indexer) Sync(ctx context.Context) error {
 	// a reorg if the cache is empty).
 	pastBlocks := i.blockStorage.CreateBlockCache(ctx, syncer.DefaultPastBlockLimit)
 
+	// The syncer fetches blocks concurrently (adjusting its
+	// concurrency to fit fetched blocks in its cache) and
+	// adds them to storage in order.
+	options := []syncer.Option{
+		syncer.WithCacheSize(syncer.DefaultCacheSize),
+		syncer.WithSizeMultiplier(sizeMultiplier),
+		syncer.WithPastBlocks(pastBlocks),
+	}
+	if i.syncConcurrency > 0 {
+		options = append(options, syncer.WithMaxConcurrency(i.syncConcurrency))
+	}
+
 	syncer := syncer.New(
 		i.network,
 		i,
 		i,
 		i.cancel,
-		syncer.WithCacheSize(syncer.DefaultCacheSize),
-		syncer.WithSizeMultiplier(sizeMultiplier),
-		syncer.WithPastBlocks(pastBlocks),
+		options...,
 	)
 
-	return syncer.Sync(ctx, startIndex, indexPlaceholder)
+	return syncer.Sync(ctx, startIndex, endIndex)
 }
 
 // Prune attempts to prune blocks in bitcoind every
-// pruneFrequency.
+// pruneFrequency. If pruning is disabled (the pruning
+// configuration is nil), we never prune.
 func (i *Indexer) Prune(ctx context.Context) error {
 	logger := utils.ExtractLogger(ctx, "pruner")
 
+	if i.pruningConfig == nil {
+		logger.Infow("pruning is disabled")
+		return nil
+	}
+
 	tc := time.NewTicker(i.pruningConfig.Frequency)
 	defer tc.Stop()
 
@@ -320,35 +622,221 @@ func (i *Indexer) Prune(ctx context.Context) error {
 				continue
 			}
 
+			if i.reorgInProgress() {
+				logger.Infow("waiting for reorg to finish before pruning")
+				continue
+			}
+
 			// Must meet pruning conditions in whive core
 			// Source:
 			// https://github.com/bitcoin/bitcoin/blob/a63a26f042134fa80356860c109edb25ac567552/src/rpc/blockchain.cpp#L953-L960
-			pruneHeight := head.Index - i.pruningConfig.Depth
+			pruneDepth := i.pruningConfig.Depth
+			if i.pruningConfig.ReorgDepth > pruneDepth {
+				pruneDepth = i.pruningConfig.ReorgDepth
+			}
+			pruneHeight := head.Index - pruneDepth
 			if pruneHeight <= i.pruningConfig.MinHeight {
 				logger.Infow("waiting to prune", "min prune height", i.pruningConfig.MinHeight)
 				continue
 			}
 
 			logger.Infow("attempting to prune bitcoind", "prune height", pruneHeight)
+			start := time.Now()
 			prunedHeight, err := i.client.PruneBlockchain(ctx, pruneHeight)
 			if err != nil {
 				logger.Warnw(
 					"unable to prune bitcoind",
 					"prune height", pruneHeight,
+					"duration", time.Since(start),
 					"error", err,
 				)
+				metrics.IndexerPrunes.WithLabelValues(metrics.PruneOutcomeFailed).Inc()
 			} else {
-				logger.Infow("pruned bitcoind", "prune height", prunedHeight)
+				logger.Infow(
+					"pruned bitcoind",
+					"prune height", prunedHeight,
+					"duration", time.Since(start),
+				)
+				metrics.IndexerPrunes.WithLabelValues(metrics.PruneOutcomePruned).Inc()
 			}
 		}
 	}
 }
 
+// MonitorDiskSpace periodically checks free disk space
+// on the data directory and pauses indexing while it is
+// below the configured minimum.
+func (i *Indexer) MonitorDiskSpace(ctx context.Context) error {
+	logger := utils.ExtractLogger(ctx, "disk")
+
+	tc := time.NewTicker(diskSpaceFrequency)
+	defer tc.Stop()
+
+	for {
+		i.checkDiskSpace(ctx)
+
+		select {
+		case <-ctx.Done():
+			logger.Warnw("exiting disk monitor")
+			return ctx.Err()
+		case <-tc.C:
+		}
+	}
+}
+
+// MonitorStorageSize periodically computes the
+// number of bytes used by the indexer storage directory.
+func (i *Indexer) MonitorStorageSize(ctx context.Context) error {
+	logger := utils.ExtractLogger(ctx, "disk")
+
+	tc := time.NewTicker(storageSizeFrequency)
+	defer tc.Stop()
+
+	for {
+		i.updateStorageSize(ctx)
+
+		select {
+		case <-ctx.Done():
+			logger.Warnw("exiting storage size monitor")
+			return ctx.Err()
+		case <-tc.C:
+		}
+	}
+}
+
+// updateStorageSize updates the cached size
+// of the indexer storage directory.
+func (i *Indexer) updateStorageSize(ctx context.Context) {
+	logger := utils.ExtractLogger(ctx, "disk")
+
+	size, err := i.dirSize(i.indexerPath)
+	if err != nil {
+		logger.Warnw("unable to compute storage size", "path", i.indexerPath, "error", err)
+		return
+	}
+
+	i.storageSizeMutex.Lock()
+	i.storageSize = size
+	i.storageSizeMutex.Unlock()
+
+	metrics.IndexerStorageBytes.Set(float64(size))
+}
+
+// StorageSize returns the number of bytes used by the
+// indexer storage directory when it was last computed
+// by MonitorStorageSize.
+func (i *Indexer) StorageSize() uint64 {
+	i.storageSizeMutex.Lock()
+	defer i.storageSizeMutex.Unlock()
+
+	return i.storageSize
+}
+
+// checkDiskSpace updates whether free disk space
+// is below the configured minimum.
+func (i *Indexer) checkDiskSpace(ctx context.Context) {
+	logger := utils.ExtractLogger(ctx, "disk")
+
+	free, err := i.diskFree(i.indexerPath)
+	if err != nil {
+		logger.Warnw("unable to check free disk space", "path", i.indexerPath, "error", err)
+		return
+	}
+
+	low := free < i.minFreeDisk
+
+	i.diskLowMutex.Lock()
+	wasLow := i.diskLow
+	i.diskLow = low
+	i.diskLowMutex.Unlock()
+
+	switch {
+	case low && !wasLow:
+		logger.Errorw(
+			"free disk space below minimum, pausing indexing",
+			"path", i.indexerPath,
+			"free (bytes)", free,
+			"min free (bytes)", i.minFreeDisk,
+		)
+	case low:
+		logger.Warnw(
+			"indexing paused until free disk space recovers",
+			"path", i.indexerPath,
+			"free (bytes)", free,
+			"min free (bytes)", i.minFreeDisk,
+		)
+	case wasLow:
+		logger.Infow(
+			"free disk space recovered, resuming indexing",
+			"path", i.indexerPath,
+			"free (bytes)", free,
+		)
+	}
+}
+
+// waitForDiskSpace returns once free disk space
+// is not below the configured minimum.
+func (i *Indexer) waitForDiskSpace(ctx context.Context) error {
+	for i.Ready() != nil {
+		if err := sdkUtils.ContextSleep(ctx, diskSpaceDelay); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Ready returns an error if the indexer is
+// unable to continue indexing.
+func (i *Indexer) Ready() error {
+	i.diskLowMutex.Lock()
+	defer i.diskLowMutex.Unlock()
+
+	if i.diskLow {
+		return errDiskSpaceLow
+	}
+
+	return nil
+}
+
 // BlockAdded is called by the syncer when a block is added.
 func (i *Indexer) BlockAdded(ctx context.Context, block *types.Block) error {
 	logger := utils.ExtractLogger(ctx, "indexer")
 
-	err := i.blockStorage.AddBlock(ctx, block)
+	// Writing blocks while the disk is full
+	// can corrupt storage.
+	if err := i.waitForDiskSpace(ctx); err != nil {
+		return err
+	}
+
+	i.writeMutex.Lock()
+	defer i.writeMutex.Unlock()
+	if i.stopped {
+		return errIndexerStopped
+	}
+
+	start := time.Now()
+
+	// A duplicate notification of the current head
+	// must not apply the block's coins again.
+	head, err := i.blockStorage.GetHeadBlockIdentifier(ctx)
+	switch {
+	case errors.Is(err, storageErrs.ErrHeadBlockNotFound):
+	case err != nil:
+		return fmt.Errorf("%w: unable to get head block identifier", err)
+	case types.Hash(head) == types.Hash(block.BlockIdentifier):
+		logger.Debugw(
+			"skipping duplicate block",
+			"hash", block.BlockIdentifier.Hash,
+			"index", block.BlockIdentifier.Index,
+		)
+
+		return nil
+	}
+
+	i.checkTimestamp(ctx, block)
+
+	err = i.blockStorage.AddBlock(ctx, block)
 	if err != nil {
 		return fmt.Errorf(
 			"%w: unable to add block to storage %s:%d",
@@ -358,6 +846,9 @@ func (i *Indexer) BlockAdded(ctx context.Context, block *types.Block) error {
 		)
 	}
 
+	i.setLastAdded(block.BlockIdentifier.Index, block.Timestamp)
+	i.finishReorg(ctx, block.BlockIdentifier)
+
 	ops := 0
 	for _, transaction := range block.Transactions {
 		ops += len(transaction.Operations)
@@ -379,6 +870,7 @@ func (i *Indexer) BlockAdded(ctx context.Context, block *types.Block) error {
 		}
 	}
 	i.coinCacheMutex.Unlock()
+	i.evictWarmCoins(block)
 
 	// Look for all remaining waiting transactions associated
 	// with the next block that have not yet been closed. We should
@@ -405,6 +897,7 @@ func (i *Indexer) BlockAdded(ctx context.Context, block *types.Block) error {
 		"index", block.BlockIdentifier.Index,
 		"transactions", len(block.Transactions),
 		"ops", ops,
+		"duration", time.Since(start),
 	)
 
 	return nil
@@ -506,6 +999,13 @@ func (i *Indexer) BlockRemoved(
 		"hash", blockIdentifier.Hash,
 		"index", blockIdentifier.Index,
 	)
+
+	i.writeMutex.Lock()
+	defer i.writeMutex.Unlock()
+	if i.stopped {
+		return errIndexerStopped
+	}
+
 	err := i.blockStorage.RemoveBlock(ctx, blockIdentifier)
 	if err != nil {
 		return fmt.Errorf(
@@ -516,16 +1016,411 @@ func (i *Indexer) BlockRemoved(
 		)
 	}
 
+	i.setLastAdded(blockIdentifier.Index-1, 0)
+	i.startReorg(ctx, blockIdentifier.Index)
+	i.clearWarmCoins()
+	metrics.IndexerOrphanedBlocks.Inc()
+
 	return nil
 }
 
+// startReorg records that a reorg is in progress
+// when the block at index (our head) is removed.
+func (i *Indexer) startReorg(ctx context.Context, index int64) {
+	i.reorgMutex.Lock()
+	defer i.reorgMutex.Unlock()
+
+	if i.reorgHead == indexPlaceholder {
+		logger := utils.ExtractLogger(ctx, "indexer")
+		logger.Warnw("reorg detected, orphaning blocks", "head", index)
+
+		i.reorgHead = index
+		i.reorgDepth = 0
+		i.reorgStart = time.Now()
+	}
+	i.reorgDepth++
+}
+
+// finishReorg records that a reorg is complete once
+// we have added a block at (or past) the index of
+// our head before the reorg.
+func (i *Indexer) finishReorg(ctx context.Context, blockIdentifier *types.BlockIdentifier) {
+	i.reorgMutex.Lock()
+	defer i.reorgMutex.Unlock()
+
+	if i.reorgHead == indexPlaceholder || blockIdentifier.Index < i.reorgHead {
+		return
+	}
+
+	logger := utils.ExtractLogger(ctx, "indexer")
+	logger.Infow(
+		"reorg complete",
+		"depth", i.reorgDepth,
+		"hash", blockIdentifier.Hash,
+		"index", blockIdentifier.Index,
+		"duration", time.Since(i.reorgStart),
+	)
+
+	i.reorgHead = indexPlaceholder
+}
+
+// reorgInProgress returns true if blocks have been
+// removed and not yet replaced.
+func (i *Indexer) reorgInProgress() bool {
+	i.reorgMutex.Lock()
+	defer i.reorgMutex.Unlock()
+
+	return i.reorgHead != indexPlaceholder
+}
+
+// setLastAdded records the index and timestamp
+// of the last block in storage.
+func (i *Indexer) setLastAdded(index int64, timestamp int64) {
+	i.lastAddedMutex.Lock()
+	defer i.lastAddedMutex.Unlock()
+
+	i.lastAdded = index
+	i.lastTimestamp = timestamp
+
+	metrics.IndexerHeadIndex.Set(float64(index))
+}
+
+// checkTimestamp warns if the timestamp of block is before
+// the timestamp of its parent. whived only accepts blocks with
+// a timestamp after the median of the previous 11 blocks, so we
+// never reject a block it has accepted.
+func (i *Indexer) checkTimestamp(ctx context.Context, block *types.Block) {
+	i.lastAddedMutex.Lock()
+	parentIndex := i.lastAdded
+	parentTimestamp := i.lastTimestamp
+	i.lastAddedMutex.Unlock()
+
+	if parentTimestamp == 0 || parentIndex != block.ParentBlockIdentifier.Index {
+		return
+	}
+
+	if block.Timestamp >= parentTimestamp {
+		return
+	}
+
+	logger := utils.ExtractLogger(ctx, "indexer")
+	logger.Warnw(
+		"block timestamp is before parent",
+		"hash", block.BlockIdentifier.Hash,
+		"index", block.BlockIdentifier.Index,
+		"drift", time.Duration(parentTimestamp-block.Timestamp)*time.Millisecond,
+	)
+}
+
+// waitForStorage returns once the block at index is
+// within maxBufferedBlocks of the last block added to
+// storage. This applies backpressure to the syncer
+// when storage writes fall behind block fetching.
+func (i *Indexer) waitForStorage(ctx context.Context, index int64) error {
+	if i.maxBufferedBlocks <= 0 {
+		return nil
+	}
+
+	logger := utils.ExtractLogger(ctx, "indexer")
+	logged := false
+	for {
+		i.lastAddedMutex.Lock()
+		lastAdded := i.lastAdded
+		i.lastAddedMutex.Unlock()
+
+		if index <= lastAdded+i.maxBufferedBlocks {
+			return nil
+		}
+
+		if !logged {
+			logger.Debugw(
+				"waiting for storage to catch up",
+				"index", index,
+				"last added", lastAdded,
+				"max buffered blocks", i.maxBufferedBlocks,
+			)
+			logged = true
+		}
+
+		if err := sdkUtils.ContextSleep(ctx, backpressureDelay); err != nil {
+			return err
+		}
+	}
+}
+
 // NetworkStatus is called by the syncer to get the current
 // network status.
 func (i *Indexer) NetworkStatus(
 	ctx context.Context,
 	network *types.NetworkIdentifier,
 ) (*types.NetworkStatusResponse, error) {
-	return i.client.NetworkStatus(ctx)
+	// whived could be swapped for a node on another
+	// network while we are syncing.
+	if err := i.checkNetwork(ctx); err != nil {
+		return nil, err
+	}
+
+	status, err := i.client.NetworkStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	i.updateBlocksBehind(ctx, status.CurrentBlockIdentifier)
+
+	if !i.tipReorgCheck {
+		return status, nil
+	}
+
+	return i.checkTipReorg(ctx, status)
+}
+
+// updateBlocksBehind updates the number of blocks between
+// whived's tip and our head. It is never negative (we may
+// briefly be ahead of whived during a reorg).
+func (i *Indexer) updateBlocksBehind(ctx context.Context, tip *types.BlockIdentifier) {
+	if tip == nil {
+		return
+	}
+
+	headIndex := int64(-1)
+	head, err := i.blockStorage.GetHeadBlockIdentifier(ctx)
+	switch {
+	case err == nil:
+		headIndex = head.Index
+	case !errors.Is(err, storageErrs.ErrHeadBlockNotFound):
+		return
+	}
+
+	behind := tip.Index - headIndex
+	if behind < 0 {
+		behind = 0
+	}
+
+	i.blocksBehindMutex.Lock()
+	i.blocksBehind = behind
+	i.blocksBehindKnown = true
+	i.blocksBehindMutex.Unlock()
+
+	metrics.IndexerBlocksBehind.Set(float64(behind))
+}
+
+// BlocksBehind returns the number of blocks between
+// whived's tip and our head when whived was last polled.
+func (i *Indexer) BlocksBehind() int64 {
+	i.blocksBehindMutex.Lock()
+	defer i.blocksBehindMutex.Unlock()
+
+	return i.blocksBehind
+}
+
+// SyncLag returns the number of blocks between whived's
+// tip and our head when whived was last polled and false
+// if whived has not been polled yet. Unlike NetworkStatus,
+// it doesn't make any requests to whived.
+func (i *Indexer) SyncLag() (int64, bool) {
+	i.blocksBehindMutex.Lock()
+	defer i.blocksBehindMutex.Unlock()
+
+	return i.blocksBehind, i.blocksBehindKnown
+}
+
+// checkTipReorg determines if whived's best chain still
+// includes our head. If whived is ahead of us, the syncer will
+// detect any reorg when it fetches the next block, so we only
+// need to check when we are at (or past) whived's tip. If
+// whived's best chain no longer includes our head, we report
+// a tip one block past our head so that the syncer requests
+// that block (see waitForOrphan).
+func (i *Indexer) checkTipReorg(
+	ctx context.Context,
+	status *types.NetworkStatusResponse,
+) (*types.NetworkStatusResponse, error) {
+	i.setOrphanIndex(indexPlaceholder)
+
+	head, err := i.blockStorage.GetHeadBlockIdentifier(ctx)
+	if errors.Is(err, storageErrs.ErrHeadBlockNotFound) {
+		return status, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to get head block", err)
+	}
+
+	current := status.CurrentBlockIdentifier
+	if head.Index < current.Index {
+		return status, nil
+	}
+
+	orphaned, err := i.tipOrphaned(ctx, current)
+	if err != nil {
+		return nil, err
+	}
+
+	if !orphaned {
+		return status, nil
+	}
+
+	logger := utils.ExtractLogger(ctx, "indexer")
+	logger.Warnw(
+		"whived best chain does not include head, orphaning",
+		"head", types.PrintStruct(head),
+		"whived tip", types.PrintStruct(current),
+	)
+
+	i.setOrphanIndex(head.Index + 1)
+	orphanStatus := *status
+	orphanStatus.CurrentBlockIdentifier = &types.BlockIdentifier{
+		Index: head.Index + 1,
+		Hash:  current.Hash,
+	}
+
+	return &orphanStatus, nil
+}
+
+// tipOrphaned returns true if the block we stored at the
+// index of whived's tip is not whived's tip. This must only
+// be called when our head is at or past whived's tip.
+func (i *Indexer) tipOrphaned(
+	ctx context.Context,
+	current *types.BlockIdentifier,
+) (bool, error) {
+	block, err := i.blockStorage.GetBlockLazy(
+		ctx,
+		&types.PartialBlockIdentifier{Index: &current.Index},
+	)
+	if err != nil {
+		return false, fmt.Errorf("%w: unable to get block %d", err, current.Index)
+	}
+
+	return block.Block.BlockIdentifier.Hash != current.Hash, nil
+}
+
+// waitForOrphan is called when the syncer requests the block
+// after our head while whived's best chain did not include our
+// head. While this is still the case, we return
+// syncer.ErrOrphanHead so that the syncer removes our head. Once
+// our head is on whived's best chain, we wait for whived to
+// have the requested block (it may be shorter than our old chain).
+func (i *Indexer) waitForOrphan(ctx context.Context, index int64) error {
+	for {
+		status, err := i.client.NetworkStatus(ctx)
+		if err != nil {
+			return fmt.Errorf("%w: unable to get network status", err)
+		}
+
+		// If whived has a block at index, the syncer detects
+		// any remaining reorg from the block's parent hash.
+		current := status.CurrentBlockIdentifier
+		if current.Index >= index {
+			i.setOrphanIndex(indexPlaceholder)
+			return nil
+		}
+
+		orphaned, err := i.tipOrphaned(ctx, current)
+		if err != nil {
+			return err
+		}
+
+		if orphaned {
+			return syncer.ErrOrphanHead
+		}
+
+		if err := sdkUtils.ContextSleep(ctx, tipReorgSleep); err != nil {
+			return err
+		}
+	}
+}
+
+// setOrphanIndex records the index of the block
+// that should be handled by waitForOrphan.
+func (i *Indexer) setOrphanIndex(index int64) {
+	i.orphanMutex.Lock()
+	defer i.orphanMutex.Unlock()
+
+	i.orphanIndex = index
+}
+
+// shouldOrphan returns true if the block at index
+// should be handled by waitForOrphan.
+func (i *Indexer) shouldOrphan(index int64) bool {
+	i.orphanMutex.Lock()
+	defer i.orphanMutex.Unlock()
+
+	return i.orphanIndex != indexPlaceholder && i.orphanIndex == index
+}
+
+// checkNetwork returns an error if network validation
+// is enabled and whived is not running on the configured
+// network.
+func (i *Indexer) checkNetwork(ctx context.Context) error {
+	if !i.validateNetwork {
+		return nil
+	}
+
+	if err := i.client.ValidateNetwork(ctx, i.params); err != nil {
+		return fmt.Errorf("%w: unable to validate whived network", err)
+	}
+
+	return nil
+}
+
+// checkTxIndex determines if whived is running with
+// -txindex. Features that require it are disabled if not.
+func (i *Indexer) checkTxIndex(ctx context.Context) {
+	logger := utils.ExtractLogger(ctx, "indexer")
+
+	enabled, err := i.client.TxIndexEnabled(ctx)
+	if err != nil {
+		logger.Warnw("unable to determine if -txindex is enabled", "error", err)
+		return
+	}
+
+	if !enabled {
+		logger.Warnw("whived is running without -txindex, transaction lookups are disabled")
+	}
+
+	i.txIndexEnabledMutex.Lock()
+	i.txIndexEnabled = enabled
+	i.txIndexEnabledMutex.Unlock()
+}
+
+// TxIndexEnabled returns false if whived was found to be
+// running without -txindex when we started syncing.
+func (i *Indexer) TxIndexEnabled() bool {
+	i.txIndexEnabledMutex.Lock()
+	defer i.txIndexEnabledMutex.Unlock()
+
+	return i.txIndexEnabled
+}
+
+// shard returns the *storageShard holding the
+// coins and balances of account.
+func (i *Indexer) shard(account *types.AccountIdentifier) *storageShard {
+	return i.shards[shardIndex(account, len(i.shards))]
+}
+
+// getCoinTransactional looks up a coin in all shards,
+// as the owner of a coin is not known until it is found.
+func (i *Indexer) getCoinTransactional(
+	ctx context.Context,
+	dbTx database.Transaction,
+	coinIdentifier string,
+) (*types.Coin, *types.AccountIdentifier, error) {
+	for _, shard := range i.shards {
+		coin, owner, err := shard.coinStorage.GetCoinTransactional(
+			ctx,
+			shard.transaction(dbTx),
+			&types.CoinIdentifier{
+				Identifier: coinIdentifier,
+			},
+		)
+		if errors.Is(err, storageErrs.ErrCoinNotFound) {
+			continue
+		}
+
+		return coin, owner, err
+	}
+
+	return nil, nil, storageErrs.ErrCoinNotFound
 }
 
 func (i *Indexer) findCoin(
@@ -533,6 +1428,10 @@ func (i *Indexer) findCoin(
 	btcBlock *whive.Block,
 	coinIdentifier string,
 ) (*types.Coin, *types.AccountIdentifier, error) {
+	if coin, owner, ok := i.warmCoin(coinIdentifier); ok {
+		return coin, owner, nil
+	}
+
 	for ctx.Err() == nil {
 		startSeen := i.seen
 		databaseTransaction := i.database.ReadTransaction(ctx)
@@ -557,13 +1456,7 @@ func (i *Indexer) findCoin(
 		}
 
 		// Attempt to find coin
-		coin, owner, err := i.coinStorage.GetCoinTransactional(
-			ctx,
-			databaseTransaction,
-			&types.CoinIdentifier{
-				Identifier: coinIdentifier,
-			},
-		)
+		coin, owner, err := i.getCoinTransactional(ctx, databaseTransaction, coinIdentifier)
 		if err == nil {
 			return coin, owner, nil
 		}
@@ -745,6 +1638,28 @@ func (i *Indexer) Block(
 	network *types.NetworkIdentifier,
 	blockIdentifier *types.PartialBlockIdentifier,
 ) (*types.Block, error) {
+	logger := utils.ExtractLogger(ctx, "indexer")
+
+	// don't fetch more blocks while indexing is
+	// paused for low disk space
+	if err := i.waitForDiskSpace(ctx); err != nil {
+		return nil, err
+	}
+
+	// wait for storage to catch up before fetching
+	// more blocks
+	if blockIdentifier != nil && blockIdentifier.Index != nil {
+		if i.shouldOrphan(*blockIdentifier.Index) {
+			if err := i.waitForOrphan(ctx, *blockIdentifier.Index); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := i.waitForStorage(ctx, *blockIdentifier.Index); err != nil {
+			return nil, err
+		}
+	}
+
 	// get raw block
 	var btcBlock *whive.Block
 	var coins []string
@@ -757,12 +1672,24 @@ func (i *Indexer) Block(
 			break
 		}
 
+		// Retrying a block that whived does not have
+		// (or has pruned) will not succeed.
+		if errors.Is(err, whive.ErrBlockNotFound) || errors.Is(err, whive.ErrBlockPruned) {
+			return nil, fmt.Errorf("%w: unable to get raw block %+v", err, blockIdentifier)
+		}
+
 		retries++
-		if retries > retryLimit {
+		if retries > i.blockRetryLimit {
 			return nil, fmt.Errorf("%w: unable to get raw block %+v", err, blockIdentifier)
 		}
 
-		if err := sdkUtils.ContextSleep(ctx, retryDelay); err != nil {
+		logger.Debugw(
+			"retrying transient error getting raw block",
+			"block", types.PrintStruct(blockIdentifier),
+			"retries", retries,
+			"error", err,
+		)
+		if err := sdkUtils.ContextSleep(ctx, i.blockRetryDelay); err != nil {
 			return nil, err
 		}
 	}
@@ -897,7 +1824,62 @@ func (i *Indexer) GetCoins(
 	ctx context.Context,
 	accountIdentifier *types.AccountIdentifier,
 ) ([]*types.Coin, *types.BlockIdentifier, error) {
-	return i.coinStorage.GetCoins(ctx, accountIdentifier)
+	return i.shard(accountIdentifier).coinStorage.GetCoins(ctx, accountIdentifier)
+}
+
+// GetCoin returns the unspent coin with coinIdentifier
+// and the account that owns it. storageErrs.ErrCoinNotFound
+// is returned if the coin is spent or has not been indexed.
+func (i *Indexer) GetCoin(
+	ctx context.Context,
+	coinIdentifier *types.CoinIdentifier,
+) (*types.Coin, *types.AccountIdentifier, error) {
+	databaseTransaction := i.database.ReadTransaction(ctx)
+	defer databaseTransaction.Discard(ctx)
+
+	return i.getCoinTransactional(ctx, databaseTransaction, coinIdentifier.Identifier)
+}
+
+// GetCoinBlocks returns the *types.BlockIdentifier of the
+// block that created each coin, keyed by coin identifier.
+// Coins created by transactions that are not yet in a block
+// (i.e. in the mempool) are omitted from the result.
+func (i *Indexer) GetCoinBlocks(
+	ctx context.Context,
+	coins []*types.Coin,
+) (map[string]*types.BlockIdentifier, error) {
+	databaseTransaction := i.database.ReadTransaction(ctx)
+	defer databaseTransaction.Discard(ctx)
+
+	blocks := map[string]*types.BlockIdentifier{}
+	for _, coin := range coins {
+		coinIdentifier := coin.CoinIdentifier
+		transactionHash, _, err := whive.ParseCoinIdentifier(coinIdentifier)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse coin identifier", err)
+		}
+
+		block, _, err := i.blockStorage.FindTransaction(
+			ctx,
+			&types.TransactionIdentifier{Hash: transactionHash.String()},
+			databaseTransaction,
+		)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"%w: unable to find transaction %s",
+				err,
+				transactionHash.String(),
+			)
+		}
+
+		if block == nil {
+			continue
+		}
+
+		blocks[coinIdentifier.Identifier] = block
+	}
+
+	return blocks, nil
 }
 
 // GetBalance returns the balance of an account
@@ -908,6 +1890,39 @@ func (i *Indexer) GetBalance(
 	currency *types.Currency,
 	blockIdentifier *types.PartialBlockIdentifier,
 ) (*types.Amount, *types.BlockIdentifier, error) {
+	request := &balanceRequest{
+		account:  accountIdentifier,
+		currency: currency,
+	}
+
+	var (
+		block *types.BlockIdentifier
+		err   error
+	)
+	if i.balanceCoalescer != nil {
+		block, err = i.balanceCoalescer.get(ctx, blockIdentifier, request)
+	} else {
+		block, err = i.getBalances(ctx, blockIdentifier, []*balanceRequest{request})
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if request.err != nil {
+		return nil, nil, request.err
+	}
+
+	return request.amount, block, nil
+}
+
+// getBalances populates the amount (or error) of each
+// balanceRequest at blockIdentifier using a single
+// database transaction.
+func (i *Indexer) getBalances(
+	ctx context.Context,
+	blockIdentifier *types.PartialBlockIdentifier,
+	requests []*balanceRequest,
+) (*types.BlockIdentifier, error) {
 	dbTx := i.database.ReadTransaction(ctx)
 	defer dbTx.Discard(ctx)
 
@@ -917,25 +1932,40 @@ func (i *Indexer) GetBalance(
 		dbTx,
 	)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
-	amount, err := i.balanceStorage.GetBalanceTransactional(
-		ctx,
-		dbTx,
-		accountIdentifier,
-		currency,
-		blockResponse.Block.BlockIdentifier.Index,
-	)
-	if errors.Is(err, storageErrs.ErrAccountMissing) {
-		return &types.Amount{
-			Value:    zeroValue,
-			Currency: currency,
-		}, blockResponse.Block.BlockIdentifier, nil
-	}
-	if err != nil {
-		return nil, nil, err
+	// Requests for the same balance are
+	// only looked up once.
+	lookedUp := map[string]*balanceRequest{}
+	for _, request := range requests {
+		key := types.Hash(request.account) + types.Hash(request.currency)
+		if existing, ok := lookedUp[key]; ok {
+			request.amount, request.err = existing.amount, existing.err
+			continue
+		}
+		lookedUp[key] = request
+
+		shard := i.shard(request.account)
+		amount, err := shard.balanceStorage.GetBalanceTransactional(
+			ctx,
+			shard.transaction(dbTx),
+			request.account,
+			request.currency,
+			blockResponse.Block.BlockIdentifier.Index,
+		)
+		switch {
+		case errors.Is(err, storageErrs.ErrAccountMissing):
+			request.amount = &types.Amount{
+				Value:    zeroValue,
+				Currency: request.currency,
+			}
+		case err != nil:
+			request.err = err
+		default:
+			request.amount = amount
+		}
 	}
 
-	return amount, blockResponse.Block.BlockIdentifier, nil
+	return blockResponse.Block.BlockIdentifier, nil
 }
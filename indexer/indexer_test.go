@@ -19,16 +19,31 @@ import (
 	"crypto/sha256"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/xyephy/rosetta-whive/configuration"
-	"github.com/xyephy/rosetta-whive/whive"
+	"github.com/xyephy/rosetta-whive/metrics"
 	mocks "github.com/xyephy/rosetta-whive/mocks/indexer"
+	"github.com/xyephy/rosetta-whive/services"
+	"github.com/xyephy/rosetta-whive/whive"
 
+	"github.com/coinbase/rosetta-sdk-go/storage/database"
+	"github.com/coinbase/rosetta-sdk-go/storage/encoder"
+	storageErrs "github.com/coinbase/rosetta-sdk-go/storage/errors"
 	"github.com/coinbase/rosetta-sdk-go/types"
 	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -86,6 +101,10 @@ func TestIndexer_Pruning(t *testing.T) {
 		GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
 	}, nil)
 
+	failedPrunes := testutil.ToFloat64(
+		metrics.IndexerPrunes.WithLabelValues(metrics.PruneOutcomeFailed),
+	)
+
 	// Timeout on first request
 	mockClient.On(
 		"PruneBlockchain",
@@ -176,6 +195,7 @@ func TestIndexer_Pruning(t *testing.T) {
 		}
 	}
 
+	mockClient.On("TxIndexEnabled", ctx).Return(true, nil).Once()
 	go func() {
 		err := i.Sync(ctx)
 		assert.True(t, errors.Is(err, context.Canceled))
@@ -210,6 +230,133 @@ func TestIndexer_Pruning(t *testing.T) {
 
 	<-waitForFinish
 	mockClient.AssertExpectations(t)
+	assert.Equal(
+		t,
+		failedPrunes+1,
+		testutil.ToFloat64(metrics.IndexerPrunes.WithLabelValues(metrics.PruneOutcomeFailed)),
+	)
+}
+
+func TestIndexer_PruningDisabled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	mockClient := &mocks.Client{}
+	cfg := &configuration.Configuration{
+		Network: &types.NetworkIdentifier{
+			Network:    whive.MainnetNetwork,
+			Blockchain: whive.Blockchain,
+		},
+		GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+		IndexerPath:            newDir,
+	}
+
+	i, err := Initialize(ctx, cancel, cfg, mockClient)
+	assert.NoError(t, err)
+	defer i.CloseDatabase(ctx)
+
+	// Without a pruning configuration, we return
+	// immediately (without calling PruneBlockchain).
+	assert.NoError(t, i.Prune(ctx))
+	mockClient.AssertExpectations(t)
+}
+
+func TestIndexer_PruningReorg(t *testing.T) {
+	// Create Indexer
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	mockClient := &mocks.Client{}
+	cfg := &configuration.Configuration{
+		Network: &types.NetworkIdentifier{
+			Network:    whive.MainnetNetwork,
+			Blockchain: whive.Blockchain,
+		},
+		GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+		Pruning: &configuration.PruningConfiguration{
+			Frequency:  50 * time.Millisecond,
+			Depth:      10,
+			MinHeight:  0,
+			ReorgDepth: 20,
+		},
+		IndexerPath: newDir,
+	}
+
+	i, err := Initialize(ctx, cancel, cfg, mockClient)
+	assert.NoError(t, err)
+	i.blockStorage.Initialize(i.workers)
+
+	block := func(index int64, hash string) *types.Block {
+		parentIndex := index - 1
+		if parentIndex < 0 {
+			parentIndex = 0
+		}
+
+		return &types.Block{
+			BlockIdentifier: &types.BlockIdentifier{
+				Hash:  hash,
+				Index: index,
+			},
+			ParentBlockIdentifier: &types.BlockIdentifier{
+				Hash:  getBlockHash(parentIndex),
+				Index: parentIndex,
+			},
+			Timestamp: 1599002115110,
+		}
+	}
+
+	for j := int64(0); j <= 50; j++ {
+		b := block(j, getBlockHash(j))
+		assert.NoError(t, i.BlockSeen(ctx, b))
+		assert.NoError(t, i.BlockAdded(ctx, b))
+	}
+
+	// Our head is orphaned, so pruning is
+	// paused until it is replaced.
+	assert.NoError(t, i.BlockRemoved(ctx, block(50, getBlockHash(50)).BlockIdentifier))
+	assert.True(t, i.reorgInProgress())
+
+	pruned := make(chan int64, 1)
+	mockClient.On(
+		"PruneBlockchain",
+		mock.Anything,
+		mock.Anything,
+	).Return(
+		int64(30),
+		nil,
+	).Run(func(args mock.Arguments) {
+		select {
+		case pruned <- args.Get(1).(int64):
+		default:
+		}
+	})
+
+	go func() {
+		err := i.Prune(ctx)
+		assert.True(t, errors.Is(err, context.Canceled))
+	}()
+
+	time.Sleep(250 * time.Millisecond)
+	mockClient.AssertNotCalled(t, "PruneBlockchain", mock.Anything, mock.Anything)
+
+	// Once the reorg completes, we don't prune
+	// blocks within the reorg depth of our head
+	// (even though the prune depth is smaller).
+	replacement := block(50, "replacement")
+	assert.NoError(t, i.BlockSeen(ctx, replacement))
+	assert.NoError(t, i.BlockAdded(ctx, replacement))
+	assert.False(t, i.reorgInProgress())
+
+	assert.Equal(t, int64(30), <-pruned)
 }
 
 func TestIndexer_Transactions(t *testing.T) {
@@ -248,6 +395,7 @@ func TestIndexer_Transactions(t *testing.T) {
 		Script  *whive.ScriptPubKey
 		Coin    *types.Coin
 		Account *types.AccountIdentifier
+		Block   *types.BlockIdentifier
 	}
 
 	coinBank := map[string]*coinBankEntry{}
@@ -317,6 +465,7 @@ func TestIndexer_Transactions(t *testing.T) {
 				Account: &types.AccountIdentifier{
 					Address: rawHash,
 				},
+				Block: identifier,
 			}
 
 			transactions = append(transactions, tx)
@@ -380,6 +529,7 @@ func TestIndexer_Transactions(t *testing.T) {
 		}
 	}
 
+	mockClient.On("TxIndexEnabled", ctx).Return(true, nil).Once()
 	go func() {
 		err := i.Sync(ctx)
 		assert.True(t, errors.Is(err, context.Canceled))
@@ -417,6 +567,14 @@ func TestIndexer_Transactions(t *testing.T) {
 				assert.NoError(t, err)
 				assert.Equal(t, expectedPubKeys, pubKeys)
 
+				// Ensure coins report the block they were created in.
+				coinBlocks, err := i.GetCoinBlocks(ctx, allCoins)
+				assert.NoError(t, err)
+				assert.Len(t, coinBlocks, len(allCoins))
+				for k, v := range coinBank {
+					assert.Equal(t, v.Block, coinBlocks[k])
+				}
+
 				cancel()
 				close(waitForFinish)
 				return
@@ -638,6 +796,7 @@ func TestIndexer_Reorg(t *testing.T) {
 		}
 	}
 
+	mockClient.On("TxIndexEnabled", ctx).Return(true, nil).Once()
 	go func() {
 		err := i.Sync(ctx)
 		assert.True(t, errors.Is(err, context.Canceled))
@@ -803,6 +962,7 @@ func TestIndexer_HeaderReorg(t *testing.T) {
 		}
 	}
 
+	mockClient.On("TxIndexEnabled", ctx).Return(true, nil).Once()
 	go func() {
 		err := i.Sync(ctx)
 		assert.True(t, errors.Is(err, context.Canceled))
@@ -836,3 +996,2565 @@ func TestIndexer_HeaderReorg(t *testing.T) {
 	assert.Len(t, i.waiter.table, 0)
 	mockClient.AssertExpectations(t)
 }
+
+func TestIndexer_TipReorg(t *testing.T) {
+	// Create Indexer
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	mockClient := &mocks.Client{}
+	cfg := &configuration.Configuration{
+		Network: &types.NetworkIdentifier{
+			Network:    whive.MainnetNetwork,
+			Blockchain: whive.Blockchain,
+		},
+		GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+		IndexerPath:            newDir,
+		TipReorgCheck:          true,
+	}
+
+	i, err := Initialize(ctx, cancel, cfg, mockClient)
+	assert.NoError(t, err)
+
+	// whived switches from a chain ending at "block 10" to a
+	// competing chain forking after block 7 ending at "fork 10".
+	// Because the competing chain is not longer than the indexed
+	// chain, the syncer would never fetch a block that conflicts
+	// with our head.
+	forkIndex := int64(8)
+	tipIndex := int64(10)
+	getForkHash := func(index int64) string {
+		if index < forkIndex {
+			return getBlockHash(index)
+		}
+
+		return fmt.Sprintf("fork %d", index)
+	}
+
+	var reorged bool
+	var reorgedMutex sync.Mutex
+	mockClient.On("NetworkStatus", mock.Anything).Return(
+		func(context.Context) *types.NetworkStatusResponse {
+			reorgedMutex.Lock()
+			defer reorgedMutex.Unlock()
+
+			hash := getBlockHash(tipIndex)
+			if reorged {
+				hash = getForkHash(tipIndex)
+			}
+
+			return &types.NetworkStatusResponse{
+				CurrentBlockIdentifier: &types.BlockIdentifier{
+					Index: tipIndex,
+					Hash:  hash,
+				},
+				GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+			}
+		},
+		nil,
+	)
+
+	addBlock := func(index int64, getHash func(int64) string, once bool) {
+		identifier := &types.BlockIdentifier{
+			Hash:  getHash(index),
+			Index: index,
+		}
+		parentIdentifier := &types.BlockIdentifier{
+			Hash:  getHash(index - 1),
+			Index: index - 1,
+		}
+		if parentIdentifier.Index < 0 {
+			parentIdentifier.Index = 0
+			parentIdentifier.Hash = getHash(0)
+		}
+
+		block := &whive.Block{
+			Hash:              identifier.Hash,
+			Height:            identifier.Index,
+			PreviousBlockHash: parentIdentifier.Hash,
+		}
+		rawCall := mockClient.On(
+			"GetRawBlock",
+			mock.Anything,
+			&types.PartialBlockIdentifier{Index: &identifier.Index},
+		).Return(
+			block,
+			[]string{},
+			nil,
+		)
+
+		parseCall := mockClient.On(
+			"ParseBlock",
+			mock.Anything,
+			block,
+			map[string]*types.AccountCoin{},
+		).Return(
+			&types.Block{
+				BlockIdentifier:       identifier,
+				ParentBlockIdentifier: parentIdentifier,
+				Timestamp:             1599002115110,
+			},
+			nil,
+		)
+
+		if once {
+			rawCall.Once()
+			parseCall.Once()
+		}
+	}
+
+	// The original chain is only returned once. Blocks on
+	// the competing chain may be fetched multiple times while
+	// the syncer walks back to the common ancestor.
+	for index := int64(0); index <= tipIndex; index++ {
+		addBlock(index, getBlockHash, true)
+	}
+	for index := forkIndex; index <= tipIndex; index++ {
+		addBlock(index, getForkHash, false)
+	}
+
+	mockClient.On("TxIndexEnabled", ctx).Return(true, nil).Once()
+	go func() {
+		err := i.Sync(ctx)
+		assert.True(t, errors.Is(err, context.Canceled))
+	}()
+
+	waitForHead := func(hash string) {
+		for {
+			head, err := i.blockStorage.GetHeadBlockIdentifier(ctx)
+			if err == nil && head.Hash == hash {
+				return
+			}
+
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+
+	waitForHead(getBlockHash(tipIndex))
+
+	reorgedMutex.Lock()
+	reorged = true
+	reorgedMutex.Unlock()
+
+	waitForHead(getForkHash(tipIndex))
+	cancel()
+
+	for index := int64(0); index <= tipIndex; index++ {
+		blockResponse, err := i.GetBlockLazy(
+			context.Background(),
+			&types.PartialBlockIdentifier{Index: &index},
+		)
+		assert.NoError(t, err)
+		assert.Equal(t, getForkHash(index), blockResponse.Block.BlockIdentifier.Hash)
+	}
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestIndexer_Backpressure(t *testing.T) {
+	// Create Indexer
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	mockClient := &mocks.Client{}
+	maxBufferedBlocks := int64(3)
+	cfg := &configuration.Configuration{
+		Network: &types.NetworkIdentifier{
+			Network:    whive.MainnetNetwork,
+			Blockchain: whive.Blockchain,
+		},
+		GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+		IndexerPath:            newDir,
+		MaxBufferedBlocks:      maxBufferedBlocks,
+	}
+
+	i, err := Initialize(ctx, cancel, cfg, mockClient)
+	assert.NoError(t, err)
+	i.blockStorage.Initialize(i.workers)
+
+	var fetchedMutex sync.Mutex
+	fetched := int64(0)
+	added := int64(0)
+	maxOutstanding := int64(0)
+
+	blocks := int64(20)
+	for k := int64(0); k < blocks; k++ {
+		identifier := &types.BlockIdentifier{
+			Hash:  getBlockHash(k),
+			Index: k,
+		}
+		parentIdentifier := &types.BlockIdentifier{
+			Hash:  getBlockHash(k - 1),
+			Index: k - 1,
+		}
+		if parentIdentifier.Index < 0 {
+			parentIdentifier.Index = 0
+			parentIdentifier.Hash = getBlockHash(0)
+		}
+
+		block := &whive.Block{
+			Hash:              identifier.Hash,
+			Height:            identifier.Index,
+			PreviousBlockHash: parentIdentifier.Hash,
+		}
+		mockClient.On(
+			"GetRawBlock",
+			mock.Anything,
+			&types.PartialBlockIdentifier{Index: &identifier.Index},
+		).Return(
+			block,
+			[]string{},
+			nil,
+		).Once()
+
+		mockClient.On(
+			"ParseBlock",
+			mock.Anything,
+			block,
+			map[string]*types.AccountCoin{},
+		).Return(
+			&types.Block{
+				BlockIdentifier:       identifier,
+				ParentBlockIdentifier: parentIdentifier,
+				Timestamp:             1599002115110,
+			},
+			nil,
+		).Run(func(args mock.Arguments) {
+			fetchedMutex.Lock()
+			defer fetchedMutex.Unlock()
+
+			fetched++
+			if fetched-added > maxOutstanding {
+				maxOutstanding = fetched - added
+			}
+		}).Once()
+	}
+
+	// Fetch all blocks concurrently (as the syncer would).
+	fetchedBlocks := make([]chan *types.Block, blocks)
+	for k := int64(0); k < blocks; k++ {
+		fetchedBlocks[k] = make(chan *types.Block, 1)
+		go func(index int64) {
+			block, err := i.Block(ctx, cfg.Network, &types.PartialBlockIdentifier{Index: &index})
+			assert.NoError(t, err)
+			fetchedBlocks[index] <- block
+		}(k)
+	}
+
+	// Slowly write blocks to storage in order.
+	for k := int64(0); k < blocks; k++ {
+		block := <-fetchedBlocks[k]
+		time.Sleep(20 * time.Millisecond)
+
+		assert.NoError(t, i.BlockSeen(ctx, block))
+		assert.NoError(t, i.BlockAdded(ctx, block))
+
+		fetchedMutex.Lock()
+		added++
+		fetchedMutex.Unlock()
+	}
+
+	assert.Equal(t, blocks, fetched)
+	assert.True(t, maxOutstanding <= maxBufferedBlocks)
+	mockClient.AssertExpectations(t)
+}
+
+func TestIndexer_GenesisCoinbase(t *testing.T) {
+	// Create Indexer
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	mockClient := &mocks.Client{}
+	cfg := &configuration.Configuration{
+		Network: &types.NetworkIdentifier{
+			Network:    whive.MainnetNetwork,
+			Blockchain: whive.Blockchain,
+		},
+		GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+		IndexerPath:            newDir,
+	}
+
+	i, err := Initialize(ctx, cancel, cfg, mockClient)
+	assert.NoError(t, err)
+	i.blockStorage.Initialize(i.workers)
+
+	// The client marks all operations in the genesis
+	// coinbase as skipped.
+	account := &types.AccountIdentifier{Address: "genesis"}
+	genesisHash := whive.MainnetGenesisBlockIdentifier.Hash
+	block := &types.Block{
+		BlockIdentifier:       whive.MainnetGenesisBlockIdentifier,
+		ParentBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+		Timestamp:             1231006505000,
+		Transactions: []*types.Transaction{
+			{
+				TransactionIdentifier: &types.TransactionIdentifier{
+					Hash: genesisHash,
+				},
+				Operations: []*types.Operation{
+					{
+						OperationIdentifier: &types.OperationIdentifier{
+							Index:        0,
+							NetworkIndex: &index0,
+						},
+						Status:  types.String(whive.SkippedStatus),
+						Type:    whive.OutputOpType,
+						Account: account,
+						Amount: &types.Amount{
+							Value:    "5000000000",
+							Currency: whive.MainnetCurrency,
+						},
+						CoinChange: &types.CoinChange{
+							CoinAction: types.CoinCreated,
+							CoinIdentifier: &types.CoinIdentifier{
+								Identifier: fmt.Sprintf("%s:0", genesisHash),
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	assert.NoError(t, i.BlockSeen(ctx, block))
+	assert.NoError(t, i.BlockAdded(ctx, block))
+
+	coins, headBlock, err := i.GetCoins(ctx, account)
+	assert.NoError(t, err)
+	assert.Len(t, coins, 0)
+	assert.Equal(t, whive.MainnetGenesisBlockIdentifier, headBlock)
+
+	balance, _, err := i.GetBalance(ctx, account, whive.MainnetCurrency, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "0", balance.Value)
+
+	// The genesis block can be fetched by index or
+	// by hash, including its coinbase transaction.
+	for _, identifier := range []*types.PartialBlockIdentifier{
+		{Index: &index0},
+		{Hash: &genesisHash},
+	} {
+		blockResponse, err := i.GetBlockLazy(ctx, identifier)
+		assert.NoError(t, err)
+		assert.Equal(t, whive.MainnetGenesisBlockIdentifier, blockResponse.Block.BlockIdentifier)
+		assert.Equal(t, whive.MainnetGenesisBlockIdentifier, blockResponse.Block.ParentBlockIdentifier)
+		assert.Equal(t, []*types.TransactionIdentifier{
+			block.Transactions[0].TransactionIdentifier,
+		}, blockResponse.OtherTransactions)
+
+		transaction, err := i.GetBlockTransaction(
+			ctx,
+			blockResponse.Block.BlockIdentifier,
+			blockResponse.OtherTransactions[0],
+		)
+		assert.NoError(t, err)
+		assert.Equal(t, block.Transactions[0], transaction)
+	}
+}
+
+func TestIndexer_DiskSpace(t *testing.T) {
+	// Create Indexer
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	mockClient := &mocks.Client{}
+	cfg := &configuration.Configuration{
+		Network: &types.NetworkIdentifier{
+			Network:    whive.MainnetNetwork,
+			Blockchain: whive.Blockchain,
+		},
+		GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+		IndexerPath:            newDir,
+		MinFreeDisk:            100,
+	}
+
+	i, err := Initialize(ctx, cancel, cfg, mockClient)
+	assert.NoError(t, err)
+	i.blockStorage.Initialize(i.workers)
+
+	var freeMutex sync.Mutex
+	free := uint64(50)
+	i.diskFree = func(path string) (uint64, error) {
+		assert.Equal(t, newDir, path)
+
+		freeMutex.Lock()
+		defer freeMutex.Unlock()
+
+		return free, nil
+	}
+
+	readiness := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		services.ReadinessHandler(2, nil, i.Ready, nil).ServeHTTP(
+			rec,
+			httptest.NewRequest(http.MethodGet, services.ReadinessRoute, nil),
+		)
+
+		return rec
+	}
+
+	// Indexing pauses when free disk space is low
+	assert.NoError(t, i.Ready())
+	i.checkDiskSpace(ctx)
+	assert.Error(t, i.Ready())
+
+	// and we are not ready to serve requests.
+	rec := readiness()
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Contains(t, rec.Body.String(), errDiskSpaceLow.Error())
+
+	block := &types.Block{
+		BlockIdentifier: &types.BlockIdentifier{
+			Hash:  getBlockHash(0),
+			Index: 0,
+		},
+		ParentBlockIdentifier: &types.BlockIdentifier{
+			Hash:  getBlockHash(0),
+			Index: 0,
+		},
+		Timestamp: 1231006505000,
+	}
+	assert.NoError(t, i.BlockSeen(ctx, block))
+
+	added := make(chan error)
+	go func() {
+		added <- i.BlockAdded(ctx, block)
+	}()
+
+	select {
+	case <-added:
+		assert.Fail(t, "block added while free disk space is low")
+	case <-time.After(2 * diskSpaceDelay):
+	}
+
+	_, err = i.blockStorage.GetHeadBlockIdentifier(ctx)
+	assert.Error(t, err)
+
+	// Indexing resumes once free disk space recovers
+	freeMutex.Lock()
+	free = 200
+	freeMutex.Unlock()
+
+	i.checkDiskSpace(ctx)
+	assert.NoError(t, i.Ready())
+	assert.Equal(t, http.StatusOK, readiness().Code)
+	assert.NoError(t, <-added)
+
+	head, err := i.blockStorage.GetHeadBlockIdentifier(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, block.BlockIdentifier, head)
+}
+
+func TestIndexer_BlockRetry(t *testing.T) {
+	// Create Indexer
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	mockClient := &mocks.Client{}
+	cfg := &configuration.Configuration{
+		Network: &types.NetworkIdentifier{
+			Network:    whive.MainnetNetwork,
+			Blockchain: whive.Blockchain,
+		},
+		GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+		IndexerPath:            newDir,
+		BlockRetryLimit:        2,
+		BlockRetryDelay:        10 * time.Millisecond,
+	}
+
+	i, err := Initialize(ctx, cancel, cfg, mockClient)
+	assert.NoError(t, err)
+
+	identifier := &types.BlockIdentifier{
+		Hash:  getBlockHash(1),
+		Index: 1,
+	}
+	partialIdentifier := &types.PartialBlockIdentifier{Index: &identifier.Index}
+	block := &whive.Block{
+		Hash:              identifier.Hash,
+		Height:            identifier.Index,
+		PreviousBlockHash: getBlockHash(0),
+	}
+	rosettaBlock := &types.Block{
+		BlockIdentifier: identifier,
+		ParentBlockIdentifier: &types.BlockIdentifier{
+			Hash:  getBlockHash(0),
+			Index: 0,
+		},
+		Timestamp: 1599002115110,
+	}
+
+	// Transient error followed by success
+	mockClient.On(
+		"GetRawBlock",
+		mock.Anything,
+		partialIdentifier,
+	).Return(
+		nil,
+		nil,
+		errors.New("transient error"),
+	).Once()
+	mockClient.On(
+		"GetRawBlock",
+		mock.Anything,
+		partialIdentifier,
+	).Return(
+		block,
+		[]string{},
+		nil,
+	).Once()
+	mockClient.On(
+		"ParseBlock",
+		mock.Anything,
+		block,
+		map[string]*types.AccountCoin{},
+	).Return(
+		rosettaBlock,
+		nil,
+	).Once()
+
+	fetched, err := i.Block(ctx, cfg.Network, partialIdentifier)
+	assert.NoError(t, err)
+	assert.Equal(t, rosettaBlock, fetched)
+
+	// Block not found is not retried
+	mockClient.On(
+		"GetRawBlock",
+		mock.Anything,
+		partialIdentifier,
+	).Return(
+		nil,
+		nil,
+		fmt.Errorf("%w: error fetching block by hash", whive.ErrBlockNotFound),
+	).Once()
+
+	fetched, err = i.Block(ctx, cfg.Network, partialIdentifier)
+	assert.Nil(t, fetched)
+	assert.True(t, errors.Is(err, whive.ErrBlockNotFound))
+
+	// Retries are bounded
+	mockClient.On(
+		"GetRawBlock",
+		mock.Anything,
+		partialIdentifier,
+	).Return(
+		nil,
+		nil,
+		errors.New("transient error"),
+	).Times(cfg.BlockRetryLimit + 1)
+
+	fetched, err = i.Block(ctx, cfg.Network, partialIdentifier)
+	assert.Nil(t, fetched)
+	assert.Contains(t, err.Error(), "transient error")
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestIndexer_NetworkMismatch(t *testing.T) {
+	// Create Indexer
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	mockClient := &mocks.Client{}
+	cfg := &configuration.Configuration{
+		Network: &types.NetworkIdentifier{
+			Network:    whive.MainnetNetwork,
+			Blockchain: whive.Blockchain,
+		},
+		GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+		Params:                 whive.MainnetParams,
+		IndexerPath:            newDir,
+		ValidateNetwork:        true,
+	}
+
+	i, err := Initialize(ctx, cancel, cfg, mockClient)
+	assert.NoError(t, err)
+
+	// Sync halts when whived is on another network
+	mismatchErr := fmt.Errorf(
+		"%w: whived is running on test but main (magic MainNet) is configured",
+		whive.ErrNetworkMismatch,
+	)
+	mockClient.On("NetworkStatus", ctx).Return(&types.NetworkStatusResponse{}, nil).Once()
+	mockClient.On("ValidateNetwork", ctx, whive.MainnetParams).Return(mismatchErr).Once()
+
+	err = i.Sync(ctx)
+	assert.True(t, errors.Is(err, whive.ErrNetworkMismatch))
+
+	// The syncer halts if whived is swapped mid-run
+	mockClient.On("ValidateNetwork", ctx, whive.MainnetParams).Return(mismatchErr).Once()
+
+	status, err := i.NetworkStatus(ctx, cfg.Network)
+	assert.Nil(t, status)
+	assert.True(t, errors.Is(err, whive.ErrNetworkMismatch))
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestIndexer_TimestampBeforeParent(t *testing.T) {
+	// Create Indexer
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	mockClient := &mocks.Client{}
+	cfg := &configuration.Configuration{
+		Network: &types.NetworkIdentifier{
+			Network:    whive.MainnetNetwork,
+			Blockchain: whive.Blockchain,
+		},
+		GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+		IndexerPath:            newDir,
+	}
+
+	i, err := Initialize(ctx, cancel, cfg, mockClient)
+	assert.NoError(t, err)
+	i.blockStorage.Initialize(i.workers)
+
+	block := func(index int64, timestamp int64) *types.Block {
+		parentIndex := index - 1
+		if parentIndex < 0 {
+			parentIndex = 0
+		}
+
+		return &types.Block{
+			BlockIdentifier: &types.BlockIdentifier{
+				Hash:  getBlockHash(index),
+				Index: index,
+			},
+			ParentBlockIdentifier: &types.BlockIdentifier{
+				Hash:  getBlockHash(parentIndex),
+				Index: parentIndex,
+			},
+			Timestamp: timestamp,
+		}
+	}
+
+	parentTimestamp := int64(1599002115110)
+	block0 := block(0, parentTimestamp)
+	assert.NoError(t, i.BlockSeen(ctx, block0))
+	assert.NoError(t, i.BlockAdded(ctx, block0))
+
+	// Earlier than parent
+	block1 := block(1, parentTimestamp-time.Hour.Milliseconds())
+	assert.NoError(t, i.BlockSeen(ctx, block1))
+	assert.NoError(t, i.BlockAdded(ctx, block1))
+
+	head, err := i.blockStorage.GetHeadBlockIdentifier(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, block1.BlockIdentifier, head)
+
+	// Much earlier than parent (whived accepted
+	// it, so we still index it)
+	block2 := block(2, block1.Timestamp-3*time.Hour.Milliseconds())
+	assert.NoError(t, i.BlockSeen(ctx, block2))
+	assert.NoError(t, i.BlockAdded(ctx, block2))
+
+	head, err = i.blockStorage.GetHeadBlockIdentifier(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, block2.BlockIdentifier, head)
+}
+
+func TestIndexer_NodeWarmingUp(t *testing.T) {
+	// Create Indexer
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	mockClient := &mocks.Client{}
+	cfg := &configuration.Configuration{
+		Network: &types.NetworkIdentifier{
+			Network:    whive.MainnetNetwork,
+			Blockchain: whive.Blockchain,
+		},
+		GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+		IndexerPath:            newDir,
+	}
+
+	i, err := Initialize(ctx, cancel, cfg, mockClient)
+	assert.NoError(t, err)
+
+	// Wait while whived rebuilds its block index
+	warmupErr := fmt.Errorf(
+		"%w: unable to get current block",
+		fmt.Errorf("%w: Verifying blocks...", whive.ErrNodeWarmingUp),
+	)
+	mockClient.On("NetworkStatus", ctx).Return(nil, warmupErr).Once()
+	mockClient.On("NetworkStatus", ctx).Return(&types.NetworkStatusResponse{}, nil).Once()
+	assert.NoError(t, i.waitForNode(ctx))
+
+	// Give up once nodeWaitTimeout has elapsed
+	i.nodeWaitTimeout = time.Nanosecond
+	mockClient.On("NetworkStatus", ctx).Return(nil, warmupErr).Once()
+	err = i.waitForNode(ctx)
+	assert.True(t, errors.Is(err, errNodeWaitTimeout))
+	assert.Contains(t, err.Error(), "Verifying blocks...")
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestIndexer_MinPeersAtStartup(t *testing.T) {
+	// Create Indexer
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	mockClient := &mocks.Client{}
+	cfg := &configuration.Configuration{
+		Network: &types.NetworkIdentifier{
+			Network:    whive.MainnetNetwork,
+			Blockchain: whive.Blockchain,
+		},
+		GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+		IndexerPath:            newDir,
+		MinPeersAtStartup:      3,
+	}
+
+	i, err := Initialize(ctx, cancel, cfg, mockClient)
+	assert.NoError(t, err)
+
+	statusWithPeers := func(count int) *types.NetworkStatusResponse {
+		peers := []*types.Peer{}
+		for j := 0; j < count; j++ {
+			peers = append(peers, &types.Peer{PeerID: fmt.Sprintf("peer %d", j)})
+		}
+
+		return &types.NetworkStatusResponse{Peers: peers}
+	}
+
+	// Wait until whived has enough peers
+	mockClient.On("NetworkStatus", ctx).Return(statusWithPeers(2), nil).Once()
+	mockClient.On("NetworkStatus", ctx).Return(statusWithPeers(3), nil).Once()
+	assert.NoError(t, i.waitForNode(ctx))
+
+	// Give up once nodeWaitTimeout has elapsed
+	i.nodeWaitTimeout = time.Nanosecond
+	mockClient.On("NetworkStatus", ctx).Return(statusWithPeers(1), nil).Once()
+	err = i.waitForNode(ctx)
+	assert.True(t, errors.Is(err, errNodeWaitTimeout))
+	assert.Contains(t, err.Error(), "1 of 3 peers")
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestIndexer_TxIndexDisabled(t *testing.T) {
+	// Create Indexer
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	mockClient := &mocks.Client{}
+	cfg := &configuration.Configuration{
+		Network: &types.NetworkIdentifier{
+			Network:    whive.MainnetNetwork,
+			Blockchain: whive.Blockchain,
+		},
+		GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+		IndexerPath:            newDir,
+	}
+
+	i, err := Initialize(ctx, cancel, cfg, mockClient)
+	assert.NoError(t, err)
+
+	// -txindex is assumed until we check
+	assert.True(t, i.TxIndexEnabled())
+
+	// An error checking leaves the assumption in place
+	mockClient.On("TxIndexEnabled", ctx).Return(false, errors.New("connection refused")).Once()
+	i.checkTxIndex(ctx)
+	assert.True(t, i.TxIndexEnabled())
+
+	mockClient.On("TxIndexEnabled", ctx).Return(false, nil).Once()
+	i.checkTxIndex(ctx)
+	assert.False(t, i.TxIndexEnabled())
+
+	mockClient.AssertExpectations(t)
+}
+
+// storageShardsState returns the balance and coins
+// of each account, keyed by address.
+func storageShardsState(
+	ctx context.Context,
+	t *testing.T,
+	i *Indexer,
+	addresses []string,
+) map[string]interface{} {
+	state := map[string]interface{}{}
+	for _, address := range addresses {
+		account := &types.AccountIdentifier{Address: address}
+		balance, _, err := i.GetBalance(ctx, account, whive.MainnetCurrency, nil)
+		assert.NoError(t, err)
+
+		coins, _, err := i.GetCoins(ctx, account)
+		assert.NoError(t, err)
+		sort.Slice(coins, func(a, b int) bool {
+			return coins[a].CoinIdentifier.Identifier < coins[b].CoinIdentifier.Identifier
+		})
+
+		state[address] = []interface{}{balance.Value, coins}
+	}
+
+	return state
+}
+
+func TestIndexer_StorageShards(t *testing.T) {
+	ctx := context.Background()
+	addresses := []string{}
+	for j := 0; j < 8; j++ {
+		addresses = append(addresses, fmt.Sprintf("address %d", j))
+	}
+
+	output := func(index int64, address string, coin string, value string) *types.Operation {
+		return &types.Operation{
+			OperationIdentifier: &types.OperationIdentifier{
+				Index:        index,
+				NetworkIndex: &index,
+			},
+			Type:    whive.OutputOpType,
+			Status:  types.String(whive.SuccessStatus),
+			Account: &types.AccountIdentifier{Address: address},
+			Amount: &types.Amount{
+				Value:    value,
+				Currency: whive.MainnetCurrency,
+			},
+			CoinChange: &types.CoinChange{
+				CoinIdentifier: &types.CoinIdentifier{Identifier: coin},
+				CoinAction:     types.CoinCreated,
+			},
+		}
+	}
+
+	// Block 0 pays each address and block 1
+	// moves coins between addresses.
+	block0 := &types.Block{
+		BlockIdentifier:       &types.BlockIdentifier{Hash: getBlockHash(0), Index: 0},
+		ParentBlockIdentifier: &types.BlockIdentifier{Hash: getBlockHash(0), Index: 0},
+		Transactions: []*types.Transaction{
+			{
+				TransactionIdentifier: &types.TransactionIdentifier{Hash: "tx 0"},
+			},
+		},
+	}
+	for j, address := range addresses {
+		block0.Transactions[0].Operations = append(
+			block0.Transactions[0].Operations,
+			output(int64(j), address, fmt.Sprintf("tx 0:%d", j), fmt.Sprintf("%d", (j+1)*1000)),
+		)
+	}
+
+	block1 := &types.Block{
+		BlockIdentifier:       &types.BlockIdentifier{Hash: getBlockHash(1), Index: 1},
+		ParentBlockIdentifier: block0.BlockIdentifier,
+		Transactions: []*types.Transaction{
+			{
+				TransactionIdentifier: &types.TransactionIdentifier{Hash: "tx 1"},
+				Operations: []*types.Operation{
+					{
+						OperationIdentifier: &types.OperationIdentifier{Index: 0},
+						Type:                whive.InputOpType,
+						Status:              types.String(whive.SuccessStatus),
+						Account:             &types.AccountIdentifier{Address: addresses[0]},
+						Amount: &types.Amount{
+							Value:    "-1000",
+							Currency: whive.MainnetCurrency,
+						},
+						CoinChange: &types.CoinChange{
+							CoinIdentifier: &types.CoinIdentifier{Identifier: "tx 0:0"},
+							CoinAction:     types.CoinSpent,
+						},
+					},
+					output(1, addresses[3], "tx 1:0", "600"),
+					output(2, addresses[5], "tx 1:1", "400"),
+				},
+			},
+		},
+	}
+
+	// run indexes block 0 and block 1 (and then
+	// orphans block 1) with count storage shards.
+	run := func(count int) []map[string]interface{} {
+		newDir, err := utils.CreateTempDir()
+		assert.NoError(t, err)
+		defer utils.RemoveTempDir(newDir)
+
+		cfg := &configuration.Configuration{
+			Network: &types.NetworkIdentifier{
+				Network:    whive.MainnetNetwork,
+				Blockchain: whive.Blockchain,
+			},
+			GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+			IndexerPath:            newDir,
+			StorageShards:          count,
+		}
+
+		i, err := Initialize(ctx, func() {}, cfg, &mocks.Client{})
+		assert.NoError(t, err)
+		assert.Len(t, i.shards, count)
+		i.blockStorage.Initialize(i.workers)
+
+		states := []map[string]interface{}{}
+		assert.NoError(t, i.blockStorage.SeeBlock(ctx, block0))
+		assert.NoError(t, i.blockStorage.AddBlock(ctx, block0))
+		states = append(states, storageShardsState(ctx, t, i, addresses))
+
+		assert.NoError(t, i.blockStorage.SeeBlock(ctx, block1))
+		assert.NoError(t, i.blockStorage.AddBlock(ctx, block1))
+		states = append(states, storageShardsState(ctx, t, i, addresses))
+
+		coin, owner, err := i.getCoinTransactional(ctx, i.database.ReadTransaction(ctx), "tx 1:1")
+		assert.NoError(t, err)
+		assert.Equal(t, "400", coin.Amount.Value)
+		assert.Equal(t, addresses[5], owner.Address)
+
+		assert.NoError(t, i.blockStorage.RemoveBlock(ctx, block1.BlockIdentifier))
+		states = append(states, storageShardsState(ctx, t, i, addresses))
+
+		i.CloseDatabase(ctx)
+
+		// Storage cannot be reopened with a different
+		// number of shards.
+		cfg.StorageShards = count + 1
+		_, err = Initialize(ctx, func() {}, cfg, &mocks.Client{})
+		assert.True(t, errors.Is(err, errStorageShardsMismatch))
+
+		return states
+	}
+
+	unsharded := run(1)
+	assert.Equal(t, "3000", unsharded[0][addresses[2]].([]interface{})[0])
+	assert.Equal(t, "0", unsharded[1][addresses[0]].([]interface{})[0])
+	assert.Equal(t, "4600", unsharded[1][addresses[3]].([]interface{})[0])
+	assert.Equal(t, "1000", unsharded[2][addresses[0]].([]interface{})[0])
+	assert.Equal(t, "4000", unsharded[2][addresses[3]].([]interface{})[0])
+
+	// Accounts are spread across shards
+	used := map[int]struct{}{}
+	for _, address := range addresses {
+		used[shardIndex(&types.AccountIdentifier{Address: address}, 4)] = struct{}{}
+	}
+	assert.Greater(t, len(used), 1)
+
+	assert.Equal(t, unsharded, run(4))
+}
+
+// readCountingDatabase counts the read
+// transactions opened on a database.Database.
+type readCountingDatabase struct {
+	database.Database
+
+	reads int64
+}
+
+func (d *readCountingDatabase) ReadTransaction(ctx context.Context) database.Transaction {
+	atomic.AddInt64(&d.reads, 1)
+	return d.Database.ReadTransaction(ctx)
+}
+
+func TestIndexer_BalanceCoalesce(t *testing.T) {
+	ctx := context.Background()
+	addresses := []string{}
+	block := &types.Block{
+		BlockIdentifier:       &types.BlockIdentifier{Hash: getBlockHash(0), Index: 0},
+		ParentBlockIdentifier: &types.BlockIdentifier{Hash: getBlockHash(0), Index: 0},
+		Transactions: []*types.Transaction{
+			{
+				TransactionIdentifier: &types.TransactionIdentifier{Hash: "tx 0"},
+			},
+		},
+	}
+	for j := 0; j < 8; j++ {
+		address := fmt.Sprintf("address %d", j)
+		addresses = append(addresses, address)
+
+		index := int64(j)
+		block.Transactions[0].Operations = append(
+			block.Transactions[0].Operations,
+			&types.Operation{
+				OperationIdentifier: &types.OperationIdentifier{
+					Index:        index,
+					NetworkIndex: &index,
+				},
+				Type:    whive.OutputOpType,
+				Status:  types.String(whive.SuccessStatus),
+				Account: &types.AccountIdentifier{Address: address},
+				Amount: &types.Amount{
+					Value:    fmt.Sprintf("%d", (j+1)*1000),
+					Currency: whive.MainnetCurrency,
+				},
+				CoinChange: &types.CoinChange{
+					CoinIdentifier: &types.CoinIdentifier{Identifier: fmt.Sprintf("tx 0:%d", j)},
+					CoinAction:     types.CoinCreated,
+				},
+			},
+		)
+	}
+
+	// run performs lookups balance lookups concurrently
+	// and returns the number of storage reads.
+	const lookups = 100
+	run := func(coalesce bool) int64 {
+		newDir, err := utils.CreateTempDir()
+		assert.NoError(t, err)
+		defer utils.RemoveTempDir(newDir)
+
+		cfg := &configuration.Configuration{
+			Network: &types.NetworkIdentifier{
+				Network:    whive.MainnetNetwork,
+				Blockchain: whive.Blockchain,
+			},
+			GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+			IndexerPath:            newDir,
+			BalanceCoalesce:        coalesce,
+		}
+
+		i, err := Initialize(ctx, func() {}, cfg, &mocks.Client{})
+		assert.NoError(t, err)
+		assert.Equal(t, coalesce, i.balanceCoalescer != nil)
+		i.blockStorage.Initialize(i.workers)
+		defer i.CloseDatabase(ctx)
+
+		assert.NoError(t, i.blockStorage.SeeBlock(ctx, block))
+		assert.NoError(t, i.blockStorage.AddBlock(ctx, block))
+
+		db := &readCountingDatabase{Database: i.database}
+		i.database = db
+
+		var wg sync.WaitGroup
+		for j := 0; j < lookups; j++ {
+			wg.Add(1)
+			go func(j int) {
+				defer wg.Done()
+
+				account := &types.AccountIdentifier{Address: addresses[j%len(addresses)]}
+				balance, headBlock, err := i.GetBalance(ctx, account, whive.MainnetCurrency, nil)
+				assert.NoError(t, err)
+				assert.Equal(t, fmt.Sprintf("%d", (j%len(addresses)+1)*1000), balance.Value)
+				assert.Equal(t, block.BlockIdentifier, headBlock)
+			}(j)
+		}
+		wg.Wait()
+
+		// Accounts without a balance are still
+		// returned a zero balance.
+		balance, _, err := i.GetBalance(
+			ctx,
+			&types.AccountIdentifier{Address: "unknown"},
+			whive.MainnetCurrency,
+			nil,
+		)
+		assert.NoError(t, err)
+		assert.Equal(t, "0", balance.Value)
+
+		return atomic.LoadInt64(&db.reads) - 1
+	}
+
+	assert.Equal(t, int64(lookups), run(false))
+
+	coalescedReads := run(true)
+	t.Logf("%d lookups served by %d storage reads", lookups, coalescedReads)
+	assert.Less(t, coalescedReads, int64(lookups/2))
+}
+
+func TestIndexer_WarmCache(t *testing.T) {
+	ctx := context.Background()
+
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	cfg := &configuration.Configuration{
+		Network: &types.NetworkIdentifier{
+			Network:    whive.MainnetNetwork,
+			Blockchain: whive.Blockchain,
+		},
+		GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+		IndexerPath:            newDir,
+		WarmCacheSize:          3,
+	}
+
+	i, err := Initialize(ctx, func() {}, cfg, &mocks.Client{})
+	assert.NoError(t, err)
+	i.blockStorage.Initialize(i.workers)
+	defer i.CloseDatabase(ctx)
+
+	// The coin cache is not warmed without a head block.
+	assert.NoError(t, i.warmCoinCache(ctx))
+	assert.Len(t, i.warmCoins, 0)
+
+	account := &types.AccountIdentifier{Address: "address"}
+	coinOp := func(index int64, coin string, action types.CoinAction) *types.Operation {
+		opType, value := whive.OutputOpType, "1000"
+		if action == types.CoinSpent {
+			opType, value = whive.InputOpType, "-1000"
+		}
+
+		return &types.Operation{
+			OperationIdentifier: &types.OperationIdentifier{Index: index},
+			Type:                opType,
+			Status:              types.String(whive.SuccessStatus),
+			Account:             account,
+			Amount: &types.Amount{
+				Value:    value,
+				Currency: whive.MainnetCurrency,
+			},
+			CoinChange: &types.CoinChange{
+				CoinIdentifier: &types.CoinIdentifier{Identifier: coin},
+				CoinAction:     action,
+			},
+		}
+	}
+	newBlock := func(index int64, ops ...*types.Operation) *types.Block {
+		parentIndex := index - 1
+		if parentIndex < 0 {
+			parentIndex = 0
+		}
+
+		return &types.Block{
+			BlockIdentifier: &types.BlockIdentifier{Hash: getBlockHash(index), Index: index},
+			ParentBlockIdentifier: &types.BlockIdentifier{
+				Hash:  getBlockHash(parentIndex),
+				Index: parentIndex,
+			},
+			Transactions: []*types.Transaction{
+				{
+					TransactionIdentifier: &types.TransactionIdentifier{
+						Hash: fmt.Sprintf("tx %d", index),
+					},
+					Operations: ops,
+				},
+			},
+		}
+	}
+
+	// Block 2 spends one of the coins created in block 1.
+	blocks := []*types.Block{
+		newBlock(
+			0,
+			coinOp(0, "tx 0:0", types.CoinCreated),
+			coinOp(1, "tx 0:1", types.CoinCreated),
+			coinOp(2, "tx 0:2", types.CoinCreated),
+		),
+		newBlock(
+			1,
+			coinOp(0, "tx 1:0", types.CoinCreated),
+			coinOp(1, "tx 1:1", types.CoinCreated),
+		),
+		newBlock(
+			2,
+			coinOp(0, "tx 1:0", types.CoinSpent),
+			coinOp(1, "tx 2:0", types.CoinCreated),
+		),
+	}
+	for _, block := range blocks {
+		assert.NoError(t, i.BlockSeen(ctx, block))
+		assert.NoError(t, i.BlockAdded(ctx, block))
+	}
+
+	// The most recently created unspent
+	// coins are loaded.
+	assert.NoError(t, i.warmCoinCache(ctx))
+	warmed := []string{}
+	for identifier := range i.warmCoins {
+		warmed = append(warmed, identifier)
+	}
+	sort.Strings(warmed)
+	assert.Equal(t, []string{"tx 0:0", "tx 1:1", "tx 2:0"}, warmed)
+
+	coin, owner, ok := i.warmCoin("tx 1:1")
+	assert.True(t, ok)
+	assert.Equal(t, "1000", coin.Amount.Value)
+	assert.Equal(t, account, owner)
+
+	// Warmed coins are found without a storage lookup.
+	coin, owner, err = i.findCoin(ctx, &whive.Block{Height: 3}, "tx 2:0")
+	assert.NoError(t, err)
+	assert.Equal(t, "tx 2:0", coin.CoinIdentifier.Identifier)
+	assert.Equal(t, account, owner)
+
+	// Spent coins are evicted.
+	block3 := newBlock(3, coinOp(0, "tx 2:0", types.CoinSpent))
+	assert.NoError(t, i.BlockSeen(ctx, block3))
+	assert.NoError(t, i.BlockAdded(ctx, block3))
+	_, _, ok = i.warmCoin("tx 2:0")
+	assert.False(t, ok)
+	assert.Len(t, i.warmCoins, 2)
+
+	// The cache is cleared during a reorg.
+	assert.NoError(t, i.BlockRemoved(ctx, block3.BlockIdentifier))
+	assert.Len(t, i.warmCoins, 0)
+}
+
+func TestIndexer_BlocksBehind(t *testing.T) {
+	ctx := context.Background()
+
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	mockClient := &mocks.Client{}
+	cfg := &configuration.Configuration{
+		Network: &types.NetworkIdentifier{
+			Network:    whive.MainnetNetwork,
+			Blockchain: whive.Blockchain,
+		},
+		GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+		IndexerPath:            newDir,
+	}
+
+	i, err := Initialize(ctx, func() {}, cfg, mockClient)
+	assert.NoError(t, err)
+	i.blockStorage.Initialize(i.workers)
+	defer i.CloseDatabase(ctx)
+
+	// The lag is unknown until whived is polled
+	_, ok := i.SyncLag()
+	assert.False(t, ok)
+
+	for j := int64(0); j < 10; j++ {
+		parentIndex := j - 1
+		if parentIndex < 0 {
+			parentIndex = 0
+		}
+
+		block := &types.Block{
+			BlockIdentifier: &types.BlockIdentifier{
+				Hash:  getBlockHash(j),
+				Index: j,
+			},
+			ParentBlockIdentifier: &types.BlockIdentifier{
+				Hash:  getBlockHash(parentIndex),
+				Index: parentIndex,
+			},
+		}
+		assert.NoError(t, i.BlockSeen(ctx, block))
+		assert.NoError(t, i.BlockAdded(ctx, block))
+	}
+
+	tests := []struct {
+		tip      int64
+		expected int64
+	}{
+		{tip: 100, expected: 91},
+		{tip: 9, expected: 0},
+
+		// We may be ahead of whived during a reorg.
+		{tip: 5, expected: 0},
+	}
+
+	for _, test := range tests {
+		mockClient.On("NetworkStatus", ctx).Return(&types.NetworkStatusResponse{
+			CurrentBlockIdentifier: &types.BlockIdentifier{
+				Hash:  getBlockHash(test.tip),
+				Index: test.tip,
+			},
+		}, nil).Once()
+
+		_, err := i.NetworkStatus(ctx, cfg.Network)
+		assert.NoError(t, err)
+		assert.Equal(t, test.expected, i.BlocksBehind())
+		assert.Equal(
+			t,
+			float64(test.expected),
+			testutil.ToFloat64(metrics.IndexerBlocksBehind),
+		)
+
+		lag, ok := i.SyncLag()
+		assert.True(t, ok)
+		assert.Equal(t, test.expected, lag)
+	}
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestIndexer_MaxIndexHeight(t *testing.T) {
+	// Create Indexer
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	mockClient := &mocks.Client{}
+	maxIndexHeight := int64(10)
+	cfg := &configuration.Configuration{
+		Network: &types.NetworkIdentifier{
+			Network:    whive.MainnetNetwork,
+			Blockchain: whive.Blockchain,
+		},
+		GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+		IndexerPath:            newDir,
+		MaxIndexHeight:         maxIndexHeight,
+	}
+
+	i, err := Initialize(ctx, cancel, cfg, mockClient)
+	assert.NoError(t, err)
+
+	// whived is past the max index height
+	mockClient.On("NetworkStatus", ctx).Return(&types.NetworkStatusResponse{
+		CurrentBlockIdentifier: &types.BlockIdentifier{
+			Index: 20,
+		},
+		GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+	}, nil)
+	mockClient.On("TxIndexEnabled", ctx).Return(true, nil).Twice()
+
+	// Only blocks up to the max index height are fetched
+	for i := int64(0); i <= maxIndexHeight; i++ {
+		identifier := &types.BlockIdentifier{
+			Hash:  getBlockHash(i),
+			Index: i,
+		}
+		parentIdentifier := &types.BlockIdentifier{
+			Hash:  getBlockHash(i - 1),
+			Index: i - 1,
+		}
+		if parentIdentifier.Index < 0 {
+			parentIdentifier.Index = 0
+			parentIdentifier.Hash = getBlockHash(0)
+		}
+
+		block := &whive.Block{
+			Hash:              identifier.Hash,
+			Height:            identifier.Index,
+			PreviousBlockHash: parentIdentifier.Hash,
+		}
+		mockClient.On(
+			"GetRawBlock",
+			mock.Anything,
+			&types.PartialBlockIdentifier{Index: &identifier.Index},
+		).Return(
+			block,
+			[]string{},
+			nil,
+		).Once()
+		mockClient.On(
+			"ParseBlock",
+			mock.Anything,
+			block,
+			map[string]*types.AccountCoin{},
+		).Return(
+			&types.Block{
+				BlockIdentifier:       identifier,
+				ParentBlockIdentifier: parentIdentifier,
+				Timestamp:             1599002115110,
+			},
+			nil,
+		).Once()
+	}
+
+	// Sync returns once the max index height is indexed
+	assert.NoError(t, i.Sync(ctx))
+
+	head, err := i.GetBlockLazy(ctx, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, maxIndexHeight, head.Block.BlockIdentifier.Index)
+
+	// Restarting at the max index height
+	// does not index any more blocks.
+	assert.NoError(t, i.Sync(ctx))
+
+	head, err = i.GetBlockLazy(ctx, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, maxIndexHeight, head.Block.BlockIdentifier.Index)
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestIndexer_SyncConcurrency(t *testing.T) {
+	// Create Indexer
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	mockClient := &mocks.Client{}
+	maxIndexHeight := int64(20)
+	syncConcurrency := int64(2)
+	cfg := &configuration.Configuration{
+		Network: &types.NetworkIdentifier{
+			Network:    whive.MainnetNetwork,
+			Blockchain: whive.Blockchain,
+		},
+		GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+		IndexerPath:            newDir,
+		MaxIndexHeight:         maxIndexHeight,
+		SyncConcurrency:        syncConcurrency,
+	}
+
+	i, err := Initialize(ctx, cancel, cfg, mockClient)
+	assert.NoError(t, err)
+
+	mockClient.On("NetworkStatus", ctx).Return(&types.NetworkStatusResponse{
+		CurrentBlockIdentifier: &types.BlockIdentifier{
+			Index: maxIndexHeight,
+		},
+		GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+	}, nil)
+	mockClient.On("TxIndexEnabled", ctx).Return(true, nil).Once()
+
+	var fetchingMutex sync.Mutex
+	fetching := int64(0)
+	maxFetching := int64(0)
+	for k := int64(0); k <= maxIndexHeight; k++ {
+		identifier := &types.BlockIdentifier{
+			Hash:  getBlockHash(k),
+			Index: k,
+		}
+		parentIdentifier := &types.BlockIdentifier{
+			Hash:  getBlockHash(k - 1),
+			Index: k - 1,
+		}
+		if parentIdentifier.Index < 0 {
+			parentIdentifier.Index = 0
+			parentIdentifier.Hash = getBlockHash(0)
+		}
+
+		block := &whive.Block{
+			Hash:              identifier.Hash,
+			Height:            identifier.Index,
+			PreviousBlockHash: parentIdentifier.Hash,
+		}
+		mockClient.On(
+			"GetRawBlock",
+			mock.Anything,
+			&types.PartialBlockIdentifier{Index: &identifier.Index},
+		).Return(
+			block,
+			[]string{},
+			nil,
+		).Run(func(args mock.Arguments) {
+			fetchingMutex.Lock()
+			fetching++
+			if fetching > maxFetching {
+				maxFetching = fetching
+			}
+			fetchingMutex.Unlock()
+
+			time.Sleep(10 * time.Millisecond)
+
+			fetchingMutex.Lock()
+			fetching--
+			fetchingMutex.Unlock()
+		}).Once()
+		mockClient.On(
+			"ParseBlock",
+			mock.Anything,
+			block,
+			map[string]*types.AccountCoin{},
+		).Return(
+			&types.Block{
+				BlockIdentifier:       identifier,
+				ParentBlockIdentifier: parentIdentifier,
+				Timestamp:             1599002115110,
+			},
+			nil,
+		).Once()
+	}
+
+	assert.NoError(t, i.Sync(ctx))
+
+	// Blocks are fetched at most syncConcurrency at a
+	// time and every block is added to storage.
+	assert.True(t, maxFetching <= syncConcurrency)
+	for k := int64(0); k <= maxIndexHeight; k++ {
+		block, err := i.GetBlockLazy(ctx, &types.PartialBlockIdentifier{Index: &k})
+		assert.NoError(t, err)
+		assert.Equal(t, getBlockHash(k), block.Block.BlockIdentifier.Hash)
+	}
+
+	head, err := i.GetBlockLazy(ctx, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, maxIndexHeight, head.Block.BlockIdentifier.Index)
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestIndexer_StorageSize(t *testing.T) {
+	// Create Indexer
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	mockClient := &mocks.Client{}
+	cfg := &configuration.Configuration{
+		Network: &types.NetworkIdentifier{
+			Network:    whive.MainnetNetwork,
+			Blockchain: whive.Blockchain,
+		},
+		GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+		IndexerPath:            newDir,
+	}
+
+	i, err := Initialize(ctx, cancel, cfg, mockClient)
+	assert.NoError(t, err)
+	i.blockStorage.Initialize(i.workers)
+
+	// Size is not known until it is computed
+	assert.Equal(t, uint64(0), i.StorageSize())
+
+	// Index a few blocks
+	for k := int64(0); k < 10; k++ {
+		parentIndex := k - 1
+		if parentIndex < 0 {
+			parentIndex = 0
+		}
+
+		hash := fmt.Sprintf("%x", sha256.Sum256([]byte(getBlockHash(k))))
+		block := &types.Block{
+			BlockIdentifier: &types.BlockIdentifier{
+				Hash:  getBlockHash(k),
+				Index: k,
+			},
+			ParentBlockIdentifier: &types.BlockIdentifier{
+				Hash:  getBlockHash(parentIndex),
+				Index: parentIndex,
+			},
+			Timestamp: 1231006505000 + k*600000,
+			Transactions: []*types.Transaction{
+				{
+					TransactionIdentifier: &types.TransactionIdentifier{
+						Hash: hash,
+					},
+					Operations: []*types.Operation{
+						{
+							OperationIdentifier: &types.OperationIdentifier{
+								Index:        0,
+								NetworkIndex: &index0,
+							},
+							Status: types.String(whive.SuccessStatus),
+							Type:   whive.OutputOpType,
+							Account: &types.AccountIdentifier{
+								Address: "address",
+							},
+							Amount: &types.Amount{
+								Value:    "100",
+								Currency: whive.MainnetCurrency,
+							},
+							CoinChange: &types.CoinChange{
+								CoinAction: types.CoinCreated,
+								CoinIdentifier: &types.CoinIdentifier{
+									Identifier: fmt.Sprintf("%s:%d", hash, index0),
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+		assert.NoError(t, i.BlockSeen(ctx, block))
+		assert.NoError(t, i.BlockAdded(ctx, block))
+	}
+
+	i.updateStorageSize(ctx)
+	size := i.StorageSize()
+	assert.True(t, size > 0)
+	assert.Equal(t, float64(size), testutil.ToFloat64(metrics.IndexerStorageBytes))
+}
+
+func TestIndexer_DuplicateBlock(t *testing.T) {
+	ctx := context.Background()
+
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	cfg := &configuration.Configuration{
+		Network: &types.NetworkIdentifier{
+			Network:    whive.MainnetNetwork,
+			Blockchain: whive.Blockchain,
+		},
+		GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+		IndexerPath:            newDir,
+	}
+
+	i, err := Initialize(ctx, func() {}, cfg, &mocks.Client{})
+	assert.NoError(t, err)
+	i.blockStorage.Initialize(i.workers)
+	defer i.CloseDatabase(ctx)
+
+	account := &types.AccountIdentifier{Address: "address"}
+	newBlock := func(index int64) *types.Block {
+		parentIndex := index - 1
+		if parentIndex < 0 {
+			parentIndex = 0
+		}
+
+		return &types.Block{
+			BlockIdentifier: &types.BlockIdentifier{Hash: getBlockHash(index), Index: index},
+			ParentBlockIdentifier: &types.BlockIdentifier{
+				Hash:  getBlockHash(parentIndex),
+				Index: parentIndex,
+			},
+			Transactions: []*types.Transaction{
+				{
+					TransactionIdentifier: &types.TransactionIdentifier{
+						Hash: fmt.Sprintf("tx %d", index),
+					},
+					Operations: []*types.Operation{
+						{
+							OperationIdentifier: &types.OperationIdentifier{Index: 0},
+							Type:                whive.OutputOpType,
+							Status:              types.String(whive.SuccessStatus),
+							Account:             account,
+							Amount: &types.Amount{
+								Value:    "1000",
+								Currency: whive.MainnetCurrency,
+							},
+							CoinChange: &types.CoinChange{
+								CoinIdentifier: &types.CoinIdentifier{
+									Identifier: fmt.Sprintf("tx %d:0", index),
+								},
+								CoinAction: types.CoinCreated,
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	// Block 1 is received twice.
+	for _, block := range []*types.Block{newBlock(0), newBlock(1), newBlock(1)} {
+		assert.NoError(t, i.BlockSeen(ctx, block))
+		assert.NoError(t, i.BlockAdded(ctx, block))
+	}
+
+	head, err := i.blockStorage.GetHeadBlockIdentifier(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, newBlock(1).BlockIdentifier, head)
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.IndexerHeadIndex))
+
+	amount, _, err := i.GetBalance(ctx, account, whive.MainnetCurrency, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "2000", amount.Value)
+
+	coins, _, err := i.GetCoins(ctx, account)
+	assert.NoError(t, err)
+	assert.Len(t, coins, 2)
+
+	coin, owner, err := i.GetCoin(ctx, &types.CoinIdentifier{Identifier: "tx 1:0"})
+	assert.NoError(t, err)
+	assert.Equal(t, "tx 1:0", coin.CoinIdentifier.Identifier)
+	assert.Equal(t, account, owner)
+
+	_, _, err = i.GetCoin(ctx, &types.CoinIdentifier{Identifier: "tx 2:0"})
+	assert.True(t, errors.Is(err, storageErrs.ErrCoinNotFound))
+}
+
+func TestIndexer_StopWrites(t *testing.T) {
+	ctx := context.Background()
+
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	cfg := &configuration.Configuration{
+		Network: &types.NetworkIdentifier{
+			Network:    whive.MainnetNetwork,
+			Blockchain: whive.Blockchain,
+		},
+		GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+		IndexerPath:            newDir,
+	}
+
+	i, err := Initialize(ctx, func() {}, cfg, &mocks.Client{})
+	assert.NoError(t, err)
+	i.blockStorage.Initialize(i.workers)
+	defer i.CloseDatabase(ctx)
+
+	newBlock := func(index int64) *types.Block {
+		parentIndex := index - 1
+		if parentIndex < 0 {
+			parentIndex = 0
+		}
+
+		return &types.Block{
+			BlockIdentifier: &types.BlockIdentifier{Hash: getBlockHash(index), Index: index},
+			ParentBlockIdentifier: &types.BlockIdentifier{
+				Hash:  getBlockHash(parentIndex),
+				Index: parentIndex,
+			},
+		}
+	}
+
+	assert.NoError(t, i.BlockSeen(ctx, newBlock(0)))
+	assert.NoError(t, i.BlockAdded(ctx, newBlock(0)))
+
+	// We wait for a write in progress to finish
+	i.writeMutex.Lock()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	assert.True(t, errors.Is(i.StopWrites(timeoutCtx), context.DeadlineExceeded))
+	i.writeMutex.Unlock()
+	assert.NoError(t, i.StopWrites(ctx))
+
+	// No more blocks are written once stopped
+	assert.NoError(t, i.BlockSeen(ctx, newBlock(1)))
+	assert.True(t, errors.Is(i.BlockAdded(ctx, newBlock(1)), errIndexerStopped))
+	assert.True(
+		t,
+		errors.Is(i.BlockRemoved(ctx, newBlock(0).BlockIdentifier), errIndexerStopped),
+	)
+
+	head, err := i.blockStorage.GetHeadBlockIdentifier(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, newBlock(0).BlockIdentifier, head)
+}
+
+func TestIndexer_CloseDatabaseWaitsForWrite(t *testing.T) {
+	ctx := context.Background()
+
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	cfg := &configuration.Configuration{
+		Network: &types.NetworkIdentifier{
+			Network:    whive.MainnetNetwork,
+			Blockchain: whive.Blockchain,
+		},
+		GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+		IndexerPath:            newDir,
+	}
+
+	i, err := Initialize(ctx, func() {}, cfg, &mocks.Client{})
+	assert.NoError(t, err)
+	i.blockStorage.Initialize(i.workers)
+
+	// The database isn't closed while a write is in progress
+	i.writeMutex.Lock()
+	closed := make(chan struct{})
+	go func() {
+		i.CloseDatabase(ctx)
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+		assert.Fail(t, "database closed while a write is in progress")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	i.writeMutex.Unlock()
+	<-closed
+
+	// No more blocks are written once closed
+	block := &types.Block{
+		BlockIdentifier:       &types.BlockIdentifier{Hash: getBlockHash(0), Index: 0},
+		ParentBlockIdentifier: &types.BlockIdentifier{Hash: getBlockHash(0), Index: 0},
+	}
+	assert.True(t, errors.Is(i.BlockAdded(ctx, block), errIndexerStopped))
+}
+
+func TestIndexer_HistoricalBalance(t *testing.T) {
+	ctx := context.Background()
+
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	cfg := &configuration.Configuration{
+		Network: &types.NetworkIdentifier{
+			Network:    whive.MainnetNetwork,
+			Blockchain: whive.Blockchain,
+		},
+		GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+		IndexerPath:            newDir,
+	}
+
+	i, err := Initialize(ctx, func() {}, cfg, &mocks.Client{})
+	assert.NoError(t, err)
+	i.blockStorage.Initialize(i.workers)
+	defer i.CloseDatabase(ctx)
+
+	// Each block creates a coin, except block 2
+	// which spends the coin created in block 0.
+	account := &types.AccountIdentifier{Address: "address"}
+	coinOp := func(coin string, action types.CoinAction) *types.Operation {
+		opType, value := whive.OutputOpType, "1000"
+		if action == types.CoinSpent {
+			opType, value = whive.InputOpType, "-1000"
+		}
+
+		return &types.Operation{
+			OperationIdentifier: &types.OperationIdentifier{Index: 0},
+			Type:                opType,
+			Status:              types.String(whive.SuccessStatus),
+			Account:             account,
+			Amount: &types.Amount{
+				Value:    value,
+				Currency: whive.MainnetCurrency,
+			},
+			CoinChange: &types.CoinChange{
+				CoinIdentifier: &types.CoinIdentifier{Identifier: coin},
+				CoinAction:     action,
+			},
+		}
+	}
+	ops := []*types.Operation{
+		coinOp("tx 0:0", types.CoinCreated),
+		coinOp("tx 1:0", types.CoinCreated),
+		coinOp("tx 0:0", types.CoinSpent),
+	}
+	blocks := make([]*types.Block, len(ops))
+	for index, op := range ops {
+		parentIndex := int64(index) - 1
+		if parentIndex < 0 {
+			parentIndex = 0
+		}
+
+		blocks[index] = &types.Block{
+			BlockIdentifier: &types.BlockIdentifier{
+				Hash:  getBlockHash(int64(index)),
+				Index: int64(index),
+			},
+			ParentBlockIdentifier: &types.BlockIdentifier{
+				Hash:  getBlockHash(parentIndex),
+				Index: parentIndex,
+			},
+			Transactions: []*types.Transaction{
+				{
+					TransactionIdentifier: &types.TransactionIdentifier{
+						Hash: fmt.Sprintf("tx %d", index),
+					},
+					Operations: []*types.Operation{op},
+				},
+			},
+		}
+		assert.NoError(t, i.BlockSeen(ctx, blocks[index]))
+		assert.NoError(t, i.BlockAdded(ctx, blocks[index]))
+	}
+
+	tests := map[string]struct {
+		blockIdentifier *types.PartialBlockIdentifier
+
+		expectedBalance string
+		expectedBlock   *types.BlockIdentifier
+	}{
+		"current": {
+			expectedBalance: "1000",
+			expectedBlock:   blocks[2].BlockIdentifier,
+		},
+		"by index": {
+			blockIdentifier: &types.PartialBlockIdentifier{Index: types.Int64(0)},
+			expectedBalance: "1000",
+			expectedBlock:   blocks[0].BlockIdentifier,
+		},
+		"by hash": {
+			blockIdentifier: &types.PartialBlockIdentifier{Hash: types.String(getBlockHash(1))},
+			expectedBalance: "2000",
+			expectedBlock:   blocks[1].BlockIdentifier,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			amount, block, err := i.GetBalance(
+				ctx,
+				account,
+				whive.MainnetCurrency,
+				test.blockIdentifier,
+			)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedBalance, amount.Value)
+			assert.Equal(t, test.expectedBlock, block)
+		})
+	}
+
+	// Balances can't be looked up at
+	// blocks that have not been indexed.
+	_, _, err = i.GetBalance(
+		ctx,
+		account,
+		whive.MainnetCurrency,
+		&types.PartialBlockIdentifier{Index: types.Int64(3)},
+	)
+	assert.True(t, errors.Is(err, storageErrs.ErrBlockNotFound))
+}
+
+func TestIndexer_FirstSeen(t *testing.T) {
+	ctx := context.Background()
+
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	cfg := &configuration.Configuration{
+		Network: &types.NetworkIdentifier{
+			Network:    whive.MainnetNetwork,
+			Blockchain: whive.Blockchain,
+		},
+		GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+		IndexerPath:            newDir,
+	}
+
+	i, err := Initialize(ctx, func() {}, cfg, &mocks.Client{})
+	assert.NoError(t, err)
+	i.blockStorage.Initialize(i.workers)
+	defer i.CloseDatabase(ctx)
+
+	funded := &types.AccountIdentifier{Address: "funded"}
+	unfunded := &types.AccountIdentifier{Address: "unfunded"}
+
+	// Nothing is seen before any blocks are indexed.
+	firstSeen, start, err := i.GetFirstSeen(ctx, funded)
+	assert.NoError(t, err)
+	assert.Nil(t, firstSeen)
+	assert.Equal(t, int64(0), start)
+
+	// The funded account receives a coin in
+	// blocks 1 and 2 (twice in block 2).
+	newBlock := func(index int64, accounts ...*types.AccountIdentifier) *types.Block {
+		parentIndex := index - 1
+		if parentIndex < 0 {
+			parentIndex = 0
+		}
+
+		ops := make([]*types.Operation, len(accounts))
+		for j, account := range accounts {
+			ops[j] = &types.Operation{
+				OperationIdentifier: &types.OperationIdentifier{Index: int64(j)},
+				Type:                whive.OutputOpType,
+				Status:              types.String(whive.SuccessStatus),
+				Account:             account,
+				Amount: &types.Amount{
+					Value:    "1000",
+					Currency: whive.MainnetCurrency,
+				},
+				CoinChange: &types.CoinChange{
+					CoinIdentifier: &types.CoinIdentifier{
+						Identifier: fmt.Sprintf("tx %d:%d", index, j),
+					},
+					CoinAction: types.CoinCreated,
+				},
+			}
+		}
+
+		return &types.Block{
+			BlockIdentifier: &types.BlockIdentifier{Hash: getBlockHash(index), Index: index},
+			ParentBlockIdentifier: &types.BlockIdentifier{
+				Hash:  getBlockHash(parentIndex),
+				Index: parentIndex,
+			},
+			Transactions: []*types.Transaction{
+				{
+					TransactionIdentifier: &types.TransactionIdentifier{
+						Hash: fmt.Sprintf("tx %d", index),
+					},
+					Operations: ops,
+				},
+			},
+		}
+	}
+	blocks := []*types.Block{
+		newBlock(0),
+		newBlock(1, funded),
+		newBlock(2, funded, funded),
+	}
+	for _, block := range blocks {
+		assert.NoError(t, i.BlockSeen(ctx, block))
+		assert.NoError(t, i.BlockAdded(ctx, block))
+	}
+
+	firstSeen, start, err = i.GetFirstSeen(ctx, funded)
+	assert.NoError(t, err)
+	assert.Equal(t, blocks[1].BlockIdentifier, firstSeen)
+	assert.Equal(t, int64(0), start)
+
+	firstSeen, _, err = i.GetFirstSeen(ctx, unfunded)
+	assert.NoError(t, err)
+	assert.Nil(t, firstSeen)
+
+	// Removing a later block does not change
+	// the first seen block.
+	assert.NoError(t, i.BlockRemoved(ctx, blocks[2].BlockIdentifier))
+	firstSeen, _, err = i.GetFirstSeen(ctx, funded)
+	assert.NoError(t, err)
+	assert.Equal(t, blocks[1].BlockIdentifier, firstSeen)
+
+	// Removing the first seen block does.
+	assert.NoError(t, i.BlockRemoved(ctx, blocks[1].BlockIdentifier))
+	firstSeen, _, err = i.GetFirstSeen(ctx, funded)
+	assert.NoError(t, err)
+	assert.Nil(t, firstSeen)
+}
+
+func TestIndexer_TrainDictionary(t *testing.T) {
+	if _, err := exec.LookPath("zstd"); err != nil {
+		t.Skip("zstd is not installed")
+	}
+
+	ctx := context.Background()
+
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	dictionaryDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(dictionaryDir)
+
+	existing, err := ioutil.ReadFile("../assets/mainnet-transaction.zstd")
+	assert.NoError(t, err)
+	dictionary := path.Join(dictionaryDir, "mainnet-transaction.zstd")
+	assert.NoError(t, ioutil.WriteFile(dictionary, existing, 0600))
+
+	cfg := &configuration.Configuration{
+		IndexerPath: newDir,
+		Compressors: []*encoder.CompressorEntry{
+			{
+				Namespace:      "transaction",
+				DictionaryPath: dictionary,
+			},
+		},
+	}
+
+	// Populate storage with transactions to sample.
+	db, err := database.NewBadgerDatabase(
+		ctx,
+		newDir,
+		database.WithCompressorEntries(cfg.Compressors),
+	)
+	assert.NoError(t, err)
+
+	dbTx := db.Transaction(ctx)
+	for j := 0; j < 1000; j++ {
+		tx := &types.Transaction{
+			TransactionIdentifier: &types.TransactionIdentifier{
+				Hash: fmt.Sprintf("%x", sha256.Sum256([]byte(fmt.Sprintf("tx %d", j)))),
+			},
+			Operations: []*types.Operation{
+				{
+					OperationIdentifier: &types.OperationIdentifier{Index: 0},
+					Type:                whive.InputOpType,
+					Status:              types.String(whive.SuccessStatus),
+					Account:             &types.AccountIdentifier{Address: fmt.Sprintf("addr %d", j)},
+					Amount: &types.Amount{
+						Value:    fmt.Sprintf("-%d", rand.Int63()),
+						Currency: whive.MainnetCurrency,
+					},
+				},
+			},
+		}
+		value, err := db.Encoder().Encode("transaction", tx)
+		assert.NoError(t, err)
+		key := []byte(fmt.Sprintf("transaction/%s", tx.TransactionIdentifier.Hash))
+		assert.NoError(t, dbTx.Set(ctx, key, value, true))
+	}
+	assert.NoError(t, dbTx.Commit(ctx))
+	assert.NoError(t, db.Close(ctx))
+
+	// Namespaces without a dictionary can't be trained.
+	_, err = TrainDictionary(ctx, cfg, "block", 100)
+	assert.True(t, errors.Is(err, errNoDictionary))
+
+	// Sample counts must be positive.
+	_, err = TrainDictionary(ctx, cfg, "transaction", 0)
+	assert.Error(t, err)
+
+	output, err := TrainDictionary(ctx, cfg, "transaction", 500)
+	assert.NoError(t, err)
+	assert.Equal(t, dictionary, output)
+
+	trained, err := ioutil.ReadFile(dictionary)
+	assert.NoError(t, err)
+	assert.NotEqual(t, existing, trained)
+
+	_, err = os.Stat(fmt.Sprintf("%s.tmp", dictionary))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestIndexer_Reindex(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	mockClient := &mocks.Client{}
+	cfg := &configuration.Configuration{
+		Network: &types.NetworkIdentifier{
+			Network:    whive.MainnetNetwork,
+			Blockchain: whive.Blockchain,
+		},
+		GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+		IndexerPath:            newDir,
+	}
+
+	i, err := Initialize(ctx, cancel, cfg, mockClient)
+	assert.NoError(t, err)
+	i.blockStorage.Initialize(i.workers)
+	defer i.CloseDatabase(ctx)
+
+	account := &types.AccountIdentifier{Address: "address"}
+	newBlock := func(index int64, value string) *types.Block {
+		parentIndex := index - 1
+		if parentIndex < 0 {
+			parentIndex = 0
+		}
+
+		return &types.Block{
+			BlockIdentifier: &types.BlockIdentifier{Hash: getBlockHash(index), Index: index},
+			ParentBlockIdentifier: &types.BlockIdentifier{
+				Hash:  getBlockHash(parentIndex),
+				Index: parentIndex,
+			},
+			Timestamp: 1599002115110,
+			Transactions: []*types.Transaction{
+				{
+					TransactionIdentifier: &types.TransactionIdentifier{
+						Hash: fmt.Sprintf("tx %d", index),
+					},
+					Operations: []*types.Operation{
+						{
+							OperationIdentifier: &types.OperationIdentifier{Index: 0},
+							Type:                whive.OutputOpType,
+							Status:              types.String(whive.SuccessStatus),
+							Account:             account,
+							Amount: &types.Amount{
+								Value:    value,
+								Currency: whive.MainnetCurrency,
+							},
+							CoinChange: &types.CoinChange{
+								CoinIdentifier: &types.CoinIdentifier{
+									Identifier: fmt.Sprintf("tx %d:0", index),
+								},
+								CoinAction: types.CoinCreated,
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	// Blocks 0 to 5 were indexed with a
+	// (buggy) value of 1000 for each output.
+	for index := int64(0); index <= 5; index++ {
+		block := newBlock(index, "1000")
+		assert.NoError(t, i.BlockSeen(ctx, block))
+		assert.NoError(t, i.BlockAdded(ctx, block))
+	}
+
+	mockClient.On("NetworkStatus", ctx).Return(&types.NetworkStatusResponse{
+		CurrentBlockIdentifier: &types.BlockIdentifier{
+			Index: 5,
+		},
+		GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+	}, nil)
+	mockClient.On("TxIndexEnabled", ctx).Return(true, nil)
+
+	t.Run("invalid range", func(t *testing.T) {
+		err := i.Reindex(ctx, 4, 3)
+		assert.True(t, errors.Is(err, errInvalidReindexRange))
+
+		err = i.Reindex(ctx, 6, 8)
+		assert.True(t, errors.Is(err, errInvalidReindexRange))
+	})
+
+	t.Run("below prune height", func(t *testing.T) {
+		mockClient.On("BlockchainInfo", ctx).Return(&whive.BlockchainInfo{
+			Pruned:      true,
+			PruneHeight: 4,
+		}, nil).Once()
+
+		err := i.Reindex(ctx, 3, 5)
+		assert.True(t, errors.Is(err, errReindexPruned))
+
+		// Nothing is rolled back
+		head, err := i.blockStorage.GetHeadBlockIdentifier(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(5), head.Index)
+		assert.False(t, i.reorgInProgress())
+	})
+
+	t.Run("reindex", func(t *testing.T) {
+		mockClient.On("BlockchainInfo", ctx).Return(&whive.BlockchainInfo{
+			Pruned:      true,
+			PruneHeight: 3,
+		}, nil).Once()
+
+		// Blocks 3 to 5 are re-processed with a value of 2000
+		for index := int64(3); index <= 5; index++ {
+			blockIndex := index
+			rawBlock := &whive.Block{
+				Hash:              getBlockHash(index),
+				Height:            index,
+				PreviousBlockHash: getBlockHash(index - 1),
+			}
+			mockClient.On(
+				"GetRawBlock",
+				mock.Anything,
+				&types.PartialBlockIdentifier{Index: &blockIndex},
+			).Return(
+				rawBlock,
+				[]string{},
+				nil,
+			).Once()
+			mockClient.On(
+				"ParseBlock",
+				mock.Anything,
+				rawBlock,
+				map[string]*types.AccountCoin{},
+			).Return(
+				newBlock(index, "2000"),
+				nil,
+			).Once()
+		}
+
+		assert.NoError(t, i.Reindex(ctx, 3, 5))
+
+		head, err := i.blockStorage.GetHeadBlockIdentifier(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(5), head.Index)
+		assert.False(t, i.reorgInProgress())
+
+		amount, _, err := i.GetBalance(ctx, account, whive.MainnetCurrency, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "9000", amount.Value)
+
+		coins, _, err := i.GetCoins(ctx, account)
+		assert.NoError(t, err)
+		assert.Len(t, coins, 6)
+
+		coin, _, err := i.GetCoin(ctx, &types.CoinIdentifier{Identifier: "tx 2:0"})
+		assert.NoError(t, err)
+		assert.Equal(t, "1000", coin.Amount.Value)
+
+		coin, _, err = i.GetCoin(ctx, &types.CoinIdentifier{Identifier: "tx 4:0"})
+		assert.NoError(t, err)
+		assert.Equal(t, "2000", coin.Amount.Value)
+	})
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestIndexer_ReorgDepth(t *testing.T) {
+	tests := map[string]struct {
+		depth int64
+	}{
+		"1-block reorg": {
+			depth: 1,
+		},
+		"multi-block reorg": {
+			depth: 3,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			newDir, err := utils.CreateTempDir()
+			assert.NoError(t, err)
+			defer utils.RemoveTempDir(newDir)
+
+			// We sync chain a to oldHead before whived
+			// reorgs to chain b, which forks after
+			// oldHead-depth and extends to newHead.
+			oldHead := int64(10)
+			newHead := int64(12)
+			forkIndex := oldHead - test.depth
+
+			mockClient := &mocks.Client{}
+			cfg := &configuration.Configuration{
+				Network: &types.NetworkIdentifier{
+					Network:    whive.MainnetNetwork,
+					Blockchain: whive.Blockchain,
+				},
+				GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+				IndexerPath:            newDir,
+				MaxIndexHeight:         newHead,
+			}
+
+			i, err := Initialize(ctx, cancel, cfg, mockClient)
+			assert.NoError(t, err)
+			defer i.CloseDatabase(ctx)
+
+			mockClient.On("NetworkStatus", ctx).Return(&types.NetworkStatusResponse{
+				CurrentBlockIdentifier: &types.BlockIdentifier{
+					Index: newHead,
+				},
+				GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+			}, nil)
+			mockClient.On("TxIndexEnabled", ctx).Return(true, nil).Once()
+
+			account := &types.AccountIdentifier{Address: "address"}
+			hash := func(chain string, index int64) string {
+				if index <= forkIndex {
+					return getBlockHash(index)
+				}
+
+				return fmt.Sprintf("%s %s", getBlockHash(index), chain)
+			}
+			addBlock := func(chain string, index int64, value string) *mock.Call {
+				identifier := &types.BlockIdentifier{Hash: hash(chain, index), Index: index}
+				parentIdentifier := &types.BlockIdentifier{Hash: hash(chain, index-1), Index: index - 1}
+				if parentIdentifier.Index < 0 {
+					parentIdentifier = &types.BlockIdentifier{Hash: getBlockHash(0), Index: 0}
+				}
+
+				rawBlock := &whive.Block{
+					Hash:              identifier.Hash,
+					Height:            identifier.Index,
+					PreviousBlockHash: parentIdentifier.Hash,
+				}
+				mockClient.On(
+					"ParseBlock",
+					mock.Anything,
+					rawBlock,
+					map[string]*types.AccountCoin{},
+				).Return(
+					&types.Block{
+						BlockIdentifier:       identifier,
+						ParentBlockIdentifier: parentIdentifier,
+						Timestamp:             1599002115110,
+						Transactions: []*types.Transaction{
+							{
+								TransactionIdentifier: &types.TransactionIdentifier{
+									Hash: fmt.Sprintf("tx %s", identifier.Hash),
+								},
+								Operations: []*types.Operation{
+									{
+										OperationIdentifier: &types.OperationIdentifier{Index: 0},
+										Type:                whive.OutputOpType,
+										Status:              types.String(whive.SuccessStatus),
+										Account:             account,
+										Amount: &types.Amount{
+											Value:    value,
+											Currency: whive.MainnetCurrency,
+										},
+										CoinChange: &types.CoinChange{
+											CoinIdentifier: &types.CoinIdentifier{
+												Identifier: fmt.Sprintf("tx %s:0", identifier.Hash),
+											},
+											CoinAction: types.CoinCreated,
+										},
+									},
+								},
+							},
+						},
+					},
+					nil,
+				)
+
+				return mockClient.On(
+					"GetRawBlock",
+					mock.Anything,
+					&types.PartialBlockIdentifier{Index: &identifier.Index},
+				).Return(
+					rawBlock,
+					[]string{},
+					nil,
+				)
+			}
+
+			// Blocks on chain a pay 1000 and blocks
+			// on chain b pay 2000. whived returns chain a
+			// until it has been fetched through oldHead.
+			for index := int64(0); index <= oldHead; index++ {
+				if index <= forkIndex {
+					addBlock("a", index, "1000")
+					continue
+				}
+
+				addBlock("a", index, "1000").Once()
+			}
+			for index := forkIndex + 1; index <= newHead; index++ {
+				addBlock("b", index, "2000")
+			}
+
+			orphaned := testutil.ToFloat64(metrics.IndexerOrphanedBlocks)
+			assert.NoError(t, i.Sync(ctx))
+
+			// The orphaned blocks of chain a were removed
+			assert.Equal(
+				t,
+				float64(test.depth),
+				testutil.ToFloat64(metrics.IndexerOrphanedBlocks)-orphaned,
+			)
+			assert.False(t, i.reorgInProgress())
+
+			head, err := i.GetBlockLazy(ctx, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, &types.BlockIdentifier{
+				Hash:  hash("b", newHead),
+				Index: newHead,
+			}, head.Block.BlockIdentifier)
+
+			for index := forkIndex + 1; index <= oldHead; index++ {
+				_, err := i.GetBlockLazy(ctx, &types.PartialBlockIdentifier{
+					Hash: types.String(hash("a", index)),
+				})
+				assert.Error(t, err)
+			}
+
+			// The coins created on chain a were undone
+			for index := forkIndex + 1; index <= oldHead; index++ {
+				_, _, err := i.GetCoin(ctx, &types.CoinIdentifier{
+					Identifier: fmt.Sprintf("tx %s:0", hash("a", index)),
+				})
+				assert.True(t, errors.Is(err, storageErrs.ErrCoinNotFound))
+			}
+
+			coins, _, err := i.GetCoins(ctx, account)
+			assert.NoError(t, err)
+			assert.Len(t, coins, int(newHead+1))
+
+			amount, _, err := i.GetBalance(ctx, account, whive.MainnetCurrency, nil)
+			assert.NoError(t, err)
+			assert.Equal(
+				t,
+				fmt.Sprintf("%d", (forkIndex+1)*1000+(newHead-forkIndex)*2000),
+				amount.Value,
+			)
+
+			mockClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestIndexer_HashCheck(t *testing.T) {
+	ctx := context.Background()
+
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	mockClient := &mocks.Client{}
+	cfg := &configuration.Configuration{
+		Network: &types.NetworkIdentifier{
+			Network:    whive.MainnetNetwork,
+			Blockchain: whive.Blockchain,
+		},
+		GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+		IndexerPath:            newDir,
+		HashCheckInterval:      time.Minute,
+	}
+
+	i, err := Initialize(ctx, func() {}, cfg, mockClient)
+	assert.NoError(t, err)
+	i.blockStorage.Initialize(i.workers)
+	defer i.CloseDatabase(ctx)
+
+	// The block at index 2 is stored under the wrong hash
+	wrongHash := "block 2 (wrong)"
+	indexedHash := func(index int64) string {
+		if index == 2 {
+			return wrongHash
+		}
+
+		return getBlockHash(index)
+	}
+	addBlock := func(index int64) {
+		parentIndex := index - 1
+		if parentIndex < 0 {
+			parentIndex = 0
+		}
+
+		block := &types.Block{
+			BlockIdentifier: &types.BlockIdentifier{Hash: indexedHash(index), Index: index},
+			ParentBlockIdentifier: &types.BlockIdentifier{
+				Hash:  indexedHash(parentIndex),
+				Index: parentIndex,
+			},
+		}
+		assert.NoError(t, i.BlockSeen(ctx, block))
+		assert.NoError(t, i.BlockAdded(ctx, block))
+	}
+
+	// Nothing is checked until there are blocks
+	// at least hashCheckMinDepth below our head.
+	mismatches, err := i.checkBlockHashes(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, mismatches)
+
+	for index := int64(0); index < hashCheckMinDepth; index++ {
+		addBlock(index)
+	}
+	mismatches, err = i.checkBlockHashes(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, mismatches)
+
+	// Indexes 0 to 2 are sampled
+	for index := int64(hashCheckMinDepth); index <= hashCheckMinDepth+2; index++ {
+		addBlock(index)
+	}
+	for index := int64(0); index <= 2; index++ {
+		mockClient.On("BlockHash", ctx, index).Return(getBlockHash(index), nil).Once()
+	}
+
+	hashMismatches := testutil.ToFloat64(metrics.IndexerHashMismatches)
+	mismatches, err = i.checkBlockHashes(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{2}, mismatches)
+	assert.Equal(t, hashMismatches+1, testutil.ToFloat64(metrics.IndexerHashMismatches))
+
+	// Errors from whived are returned
+	mockClient.On("BlockHash", ctx, int64(0)).Return("", errors.New("whived error")).Once()
+	_, err = i.checkBlockHashes(ctx)
+	assert.Error(t, err)
+
+	// Nothing is checked during a reorg
+	i.startReorg(ctx, hashCheckMinDepth+2)
+	mismatches, err = i.checkBlockHashes(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, mismatches)
+
+	mockClient.AssertExpectations(t)
+}
@@ -0,0 +1,141 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/xyephy/rosetta-whive/utils"
+
+	storageErrs "github.com/coinbase/rosetta-sdk-go/storage/errors"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+var (
+	errInvalidReindexRange = errors.New("invalid reindex range")
+	errReindexPruned       = errors.New("reindex start height is below whived's prune height")
+)
+
+// Reindex rolls back storage (including the UTXO set and
+// balances) to the block before startHeight and then
+// re-processes blocks from startHeight to endHeight with
+// the syncer. This re-scans a range of blocks without
+// wiping storage (e.g. after fixing a parsing bug).
+//
+// Reindex must be called before Sync, which resumes
+// syncing after endHeight. We refuse to reindex below
+// whived's prune height because whived no longer has
+// the blocks needed to re-process the range.
+func (i *Indexer) Reindex(ctx context.Context, startHeight int64, endHeight int64) error {
+	if startHeight < 0 || endHeight < startHeight {
+		return fmt.Errorf(
+			"%w: start height %d, end height %d",
+			errInvalidReindexRange,
+			startHeight,
+			endHeight,
+		)
+	}
+
+	if err := i.prepareSync(ctx); err != nil {
+		return err
+	}
+
+	head, err := i.blockStorage.GetHeadBlockIdentifier(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: unable to get head block identifier", err)
+	}
+
+	if startHeight > head.Index {
+		return fmt.Errorf(
+			"%w: start height %d is after head %d",
+			errInvalidReindexRange,
+			startHeight,
+			head.Index,
+		)
+	}
+
+	if err := i.checkPruneHeight(ctx, startHeight); err != nil {
+		return err
+	}
+
+	logger := utils.ExtractLogger(ctx, "indexer")
+	logger.Infow("rolling back storage", "head", head.Index, "start height", startHeight)
+	if err := i.rollback(ctx, head, startHeight); err != nil {
+		return err
+	}
+
+	logger.Infow("reindexing blocks", "start height", startHeight, "end height", endHeight)
+	if err := i.runSyncer(ctx, startHeight, endHeight); err != nil {
+		return fmt.Errorf(
+			"%w: unable to reindex blocks %d to %d",
+			err,
+			startHeight,
+			endHeight,
+		)
+	}
+
+	logger.Infow("reindexed blocks", "start height", startHeight, "end height", endHeight)
+
+	return nil
+}
+
+// checkPruneHeight returns an error if whived
+// has pruned the block at startHeight.
+func (i *Indexer) checkPruneHeight(ctx context.Context, startHeight int64) error {
+	info, err := i.client.BlockchainInfo(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: unable to get blockchain info", err)
+	}
+
+	if info.Pruned && startHeight < info.PruneHeight {
+		return fmt.Errorf(
+			"%w: start height %d, prune height %d",
+			errReindexPruned,
+			startHeight,
+			info.PruneHeight,
+		)
+	}
+
+	return nil
+}
+
+// rollback removes blocks from head down to (and
+// including) the block at startHeight. Removing blocks
+// marks a reorg as in progress, so we don't prune until
+// the syncer has re-added a block at head's index.
+func (i *Indexer) rollback(
+	ctx context.Context,
+	head *types.BlockIdentifier,
+	startHeight int64,
+) error {
+	for head.Index >= startHeight {
+		if err := i.BlockRemoved(ctx, head); err != nil {
+			return err
+		}
+
+		var err error
+		head, err = i.blockStorage.GetHeadBlockIdentifier(ctx)
+		if errors.Is(err, storageErrs.ErrHeadBlockNotFound) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w: unable to get head block identifier", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,351 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+
+	"github.com/coinbase/rosetta-sdk-go/asserter"
+	"github.com/coinbase/rosetta-sdk-go/storage/database"
+	storageErrs "github.com/coinbase/rosetta-sdk-go/storage/errors"
+	"github.com/coinbase/rosetta-sdk-go/storage/modules"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/neilotoole/errgroup"
+)
+
+const (
+	// storageShardsKey is the key used to store the number
+	// of storage shards the indexer was created with.
+	storageShardsKey = "storage_shards"
+
+	// shardKeyPrefix is prepended (with the shard number)
+	// to all keys written by a shard's coin and balance storage.
+	shardKeyPrefix = "shard"
+)
+
+var (
+	// errStorageShardsMismatch is returned when the configured
+	// number of storage shards does not match the number of
+	// shards the indexer was created with.
+	errStorageShardsMismatch = errors.New("storage shards do not match existing storage")
+)
+
+// storageShard is a key space of the indexer database that holds
+// the coins and balances of a subset of accounts. All shards are
+// written in the same database transaction as the block that
+// updates them, so reorgs remain atomic across shards.
+type storageShard struct {
+	// prefix is nil when storage is not sharded, so that
+	// unsharded storage uses the same keys as before
+	// sharding was supported.
+	prefix []byte
+
+	coinStorage    *modules.CoinStorage
+	balanceStorage *modules.BalanceStorage
+}
+
+// newStorageShards creates count *storageShards and the
+// modules.BlockWorkers that populate them.
+func newStorageShards(
+	db database.Database,
+	blockStorage *modules.BlockStorage,
+	asserter *asserter.Asserter,
+	count int,
+) ([]*storageShard, []modules.BlockWorker) {
+	if count < 1 {
+		count = 1
+	}
+
+	shards := make([]*storageShard, count)
+	workers := []modules.BlockWorker{}
+	for j := range shards {
+		shard := &storageShard{}
+		shardDB := db
+		if count > 1 {
+			shard.prefix = []byte(fmt.Sprintf("%s/%d/", shardKeyPrefix, j))
+			shardDB = &shardDatabase{Database: db, prefix: shard.prefix}
+		}
+
+		shard.coinStorage = modules.NewCoinStorage(
+			shardDB,
+			&CoinStorageHelper{blockStorage},
+			asserter,
+		)
+
+		shard.balanceStorage = modules.NewBalanceStorage(shardDB)
+		shard.balanceStorage.Initialize(
+			&BalanceStorageHelper{asserter},
+			&BalanceStorageHandler{},
+		)
+
+		shards[j] = shard
+		if count == 1 {
+			workers = append(workers, shard.coinStorage, shard.balanceStorage)
+			continue
+		}
+
+		workers = append(
+			workers,
+			&shardWorker{index: j, count: count, shard: shard, worker: shard.coinStorage},
+			&shardWorker{index: j, count: count, shard: shard, worker: shard.balanceStorage},
+		)
+	}
+
+	return shards, workers
+}
+
+// checkStorageShards ensures the indexer is not opened with
+// a different number of storage shards than it was created
+// with (which would make existing coins and balances
+// unreachable).
+func checkStorageShards(
+	ctx context.Context,
+	db database.Database,
+	blockStorage *modules.BlockStorage,
+	count int,
+) error {
+	if count < 1 {
+		count = 1
+	}
+
+	dbTx := db.Transaction(ctx)
+	defer dbTx.Discard(ctx)
+
+	exists, value, err := dbTx.Get(ctx, []byte(storageShardsKey))
+	if err != nil {
+		return fmt.Errorf("%w: unable to get storage shards", err)
+	}
+
+	existing := count
+	switch {
+	case exists:
+		existing, err = strconv.Atoi(string(value))
+		if err != nil {
+			return fmt.Errorf("%w: unable to parse storage shards %s", err, string(value))
+		}
+	default:
+		// Storage populated before sharding was
+		// supported is not sharded.
+		_, err := blockStorage.GetHeadBlockIdentifierTransactional(ctx, dbTx)
+		switch {
+		case err == nil:
+			existing = 1
+		case !errors.Is(err, storageErrs.ErrHeadBlockNotFound):
+			return fmt.Errorf("%w: unable to get head block identifier", err)
+		}
+	}
+
+	if existing != count {
+		return fmt.Errorf(
+			"%w: storage was created with %d shards but %d are configured",
+			errStorageShardsMismatch,
+			existing,
+			count,
+		)
+	}
+
+	if exists {
+		return nil
+	}
+
+	if err := dbTx.Set(ctx, []byte(storageShardsKey), []byte(strconv.Itoa(count)), true); err != nil {
+		return fmt.Errorf("%w: unable to set storage shards", err)
+	}
+
+	return dbTx.Commit(ctx)
+}
+
+// shardIndex returns the index of the shard that
+// stores the coins and balances of account.
+func shardIndex(account *types.AccountIdentifier, count int) int {
+	if count <= 1 {
+		return 0
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(account.Address))
+
+	return int(h.Sum32() % uint32(count))
+}
+
+// transaction returns a database.Transaction that
+// reads and writes the keys of the shard.
+func (s *storageShard) transaction(dbTx database.Transaction) database.Transaction {
+	if s.prefix == nil {
+		return dbTx
+	}
+
+	return &shardTransaction{Transaction: dbTx, prefix: s.prefix}
+}
+
+var _ modules.BlockWorker = (*shardWorker)(nil)
+
+// shardWorker provides a modules.BlockWorker with only the
+// operations of a block that affect the accounts in its shard.
+type shardWorker struct {
+	index int
+	count int
+
+	shard  *storageShard
+	worker modules.BlockWorker
+}
+
+// filterBlock returns a copy of block with only the
+// operations that affect accounts in the shard.
+func (w *shardWorker) filterBlock(block *types.Block) *types.Block {
+	filtered := *block
+	filtered.Transactions = make([]*types.Transaction, len(block.Transactions))
+	for j, tx := range block.Transactions {
+		filteredTx := *tx
+		filteredTx.Operations = []*types.Operation{}
+		for _, op := range tx.Operations {
+			if op.Account == nil || shardIndex(op.Account, w.count) != w.index {
+				continue
+			}
+
+			filteredTx.Operations = append(filteredTx.Operations, op)
+		}
+
+		filtered.Transactions[j] = &filteredTx
+	}
+
+	return &filtered
+}
+
+// AddingBlock is called by BlockStorage when adding a block.
+func (w *shardWorker) AddingBlock(
+	ctx context.Context,
+	g *errgroup.Group,
+	block *types.Block,
+	transaction database.Transaction,
+) (database.CommitWorker, error) {
+	return w.worker.AddingBlock(ctx, g, w.filterBlock(block), w.shard.transaction(transaction))
+}
+
+// RemovingBlock is called by BlockStorage when removing a block.
+func (w *shardWorker) RemovingBlock(
+	ctx context.Context,
+	g *errgroup.Group,
+	block *types.Block,
+	transaction database.Transaction,
+) (database.CommitWorker, error) {
+	return w.worker.RemovingBlock(ctx, g, w.filterBlock(block), w.shard.transaction(transaction))
+}
+
+var _ database.Database = (*shardDatabase)(nil)
+
+// shardDatabase is a database.Database whose
+// transactions only access the keys of a shard.
+type shardDatabase struct {
+	database.Database
+
+	prefix []byte
+}
+
+// Transaction returns a database.Transaction for the shard.
+func (d *shardDatabase) Transaction(ctx context.Context) database.Transaction {
+	return &shardTransaction{Transaction: d.Database.Transaction(ctx), prefix: d.prefix}
+}
+
+// ReadTransaction returns a read-only database.Transaction for the shard.
+func (d *shardDatabase) ReadTransaction(ctx context.Context) database.Transaction {
+	return &shardTransaction{Transaction: d.Database.ReadTransaction(ctx), prefix: d.prefix}
+}
+
+// WriteTransaction returns a database.Transaction for the shard
+// holding the write lock for identifier.
+func (d *shardDatabase) WriteTransaction(
+	ctx context.Context,
+	identifier string,
+	priority bool,
+) database.Transaction {
+	return &shardTransaction{
+		Transaction: d.Database.WriteTransaction(ctx, identifier, priority),
+		prefix:      d.prefix,
+	}
+}
+
+var _ database.Transaction = (*shardTransaction)(nil)
+
+// shardTransaction prepends the shard prefix to all keys.
+type shardTransaction struct {
+	database.Transaction
+
+	prefix []byte
+}
+
+// unwrapShardTransaction returns the database.Transaction
+// underlying dbTx (to access keys outside of a shard, like
+// the head block).
+func unwrapShardTransaction(dbTx database.Transaction) database.Transaction {
+	if shardTx, ok := dbTx.(*shardTransaction); ok {
+		return shardTx.Transaction
+	}
+
+	return dbTx
+}
+
+func (t *shardTransaction) key(key []byte) []byte {
+	prefixed := make([]byte, 0, len(t.prefix)+len(key))
+	prefixed = append(prefixed, t.prefix...)
+
+	return append(prefixed, key...)
+}
+
+// Set stores value at key in the shard.
+func (t *shardTransaction) Set(
+	ctx context.Context,
+	key []byte,
+	value []byte,
+	reclaimValue bool,
+) error {
+	return t.Transaction.Set(ctx, t.key(key), value, reclaimValue)
+}
+
+// Get returns the value at key in the shard.
+func (t *shardTransaction) Get(ctx context.Context, key []byte) (bool, []byte, error) {
+	return t.Transaction.Get(ctx, t.key(key))
+}
+
+// Delete removes key from the shard.
+func (t *shardTransaction) Delete(ctx context.Context, key []byte) error {
+	return t.Transaction.Delete(ctx, t.key(key))
+}
+
+// Scan calls worker with all keys in the shard with prefix
+// (without the shard prefix).
+func (t *shardTransaction) Scan(
+	ctx context.Context,
+	prefix []byte,
+	seekStart []byte,
+	worker func([]byte, []byte) error,
+	logEntries bool,
+	reverse bool,
+) (int, error) {
+	return t.Transaction.Scan(
+		ctx,
+		t.key(prefix),
+		t.key(seekStart),
+		func(k []byte, v []byte) error {
+			return worker(k[len(t.prefix):], v)
+		},
+		logEntries,
+		reverse,
+	)
+}
@@ -0,0 +1,145 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/xyephy/rosetta-whive/utils"
+
+	storageErrs "github.com/coinbase/rosetta-sdk-go/storage/errors"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+const (
+	// maxWarmCacheBlocks is the maximum number of blocks
+	// (starting at our head) we look through for unspent
+	// coins when warming the coin cache.
+	maxWarmCacheBlocks = 1000
+)
+
+// warmCoinCache preloads up to warmCacheSize of the most
+// recently created unspent coins (which are the most likely
+// to be spent soon) so that the first blocks synced after
+// a restart don't need to look up each input in storage.
+func (i *Indexer) warmCoinCache(ctx context.Context) error {
+	head, err := i.blockStorage.GetHeadBlockIdentifier(ctx)
+	if errors.Is(err, storageErrs.ErrHeadBlockNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%w: unable to get head block identifier", err)
+	}
+
+	dbTx := i.database.ReadTransaction(ctx)
+	defer dbTx.Discard(ctx)
+
+	coins := map[string]*types.AccountCoin{}
+	lastIndex := head.Index - maxWarmCacheBlocks
+blocks:
+	for index := head.Index; index >= 0 && index > lastIndex; index-- {
+		blockIndex := index
+		block, err := i.blockStorage.GetBlockTransactional(
+			ctx,
+			dbTx,
+			&types.PartialBlockIdentifier{Index: &blockIndex},
+		)
+		if err != nil {
+			return fmt.Errorf("%w: unable to get block %d", err, index)
+		}
+
+		for j := len(block.Transactions) - 1; j >= 0; j-- {
+			for _, op := range block.Transactions[j].Operations {
+				if op.CoinChange == nil || op.CoinChange.CoinAction != types.CoinCreated {
+					continue
+				}
+
+				identifier := op.CoinChange.CoinIdentifier.Identifier
+				coin, owner, err := i.getCoinTransactional(ctx, dbTx, identifier)
+				if errors.Is(err, storageErrs.ErrCoinNotFound) {
+					continue
+				}
+				if err != nil {
+					return fmt.Errorf("%w: unable to get coin %s", err, identifier)
+				}
+
+				coins[identifier] = &types.AccountCoin{
+					Account: owner,
+					Coin:    coin,
+				}
+				if len(coins) >= i.warmCacheSize {
+					break blocks
+				}
+			}
+		}
+	}
+
+	i.coinCacheMutex.Lock(true)
+	i.warmCoins = coins
+	i.coinCacheMutex.Unlock()
+
+	logger := utils.ExtractLogger(ctx, "indexer")
+	logger.Infow("warmed coin cache", "coins", len(coins), "head", head.Index)
+
+	return nil
+}
+
+// warmCoin returns the coin with coinIdentifier
+// if it was loaded when warming the coin cache.
+func (i *Indexer) warmCoin(coinIdentifier string) (*types.Coin, *types.AccountIdentifier, bool) {
+	i.coinCacheMutex.Lock(false)
+	defer i.coinCacheMutex.Unlock()
+
+	accCoin, ok := i.warmCoins[coinIdentifier]
+	if !ok {
+		return nil, nil, false
+	}
+
+	return accCoin.Coin, accCoin.Account, true
+}
+
+// evictWarmCoins removes the coins spent
+// in block from the warmed coin cache.
+func (i *Indexer) evictWarmCoins(block *types.Block) {
+	i.coinCacheMutex.Lock(true)
+	defer i.coinCacheMutex.Unlock()
+
+	if len(i.warmCoins) == 0 {
+		return
+	}
+
+	for _, tx := range block.Transactions {
+		for _, op := range tx.Operations {
+			if op.CoinChange == nil || op.CoinChange.CoinAction != types.CoinSpent {
+				continue
+			}
+
+			delete(i.warmCoins, op.CoinChange.CoinIdentifier.Identifier)
+		}
+	}
+}
+
+// clearWarmCoins removes all coins from the warmed
+// coin cache. We do this during a reorg because
+// warmed coins may have been created in an
+// orphaned block.
+func (i *Indexer) clearWarmCoins() {
+	i.coinCacheMutex.Lock(true)
+	defer i.coinCacheMutex.Unlock()
+
+	i.warmCoins = nil
+}
@@ -16,6 +16,8 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
@@ -25,10 +27,11 @@ import (
 	"time"
 
 	"github.com/xyephy/rosetta-whive/configuration"
-	"github.com/xyephy/rosetta-whive/whive"
 	"github.com/xyephy/rosetta-whive/indexer"
+	"github.com/xyephy/rosetta-whive/metrics"
 	"github.com/xyephy/rosetta-whive/services"
 	"github.com/xyephy/rosetta-whive/utils"
+	"github.com/xyephy/rosetta-whive/whive"
 
 	"github.com/coinbase/rosetta-sdk-go/asserter"
 	"github.com/coinbase/rosetta-sdk-go/server"
@@ -39,18 +42,24 @@ import (
 )
 
 const (
-	// readTimeout is the maximum duration for reading the entire
-	// request, including the body.
-	readTimeout = 5 * time.Second
-
-	// writeTimeout is the maximum duration before timing out
-	// writes of the response. It is reset whenever a new
-	// request's header is read.
-	writeTimeout = 15 * time.Second
-
-	// idleTimeout is the maximum amount of time to wait for the
-	// next request when keep-alives are enabled.
-	idleTimeout = 30 * time.Second
+	// shutdownTimeout is the maximum amount of time we wait
+	// for each stage of shutdown (serving in-flight requests,
+	// stopping services, and writing the current block)
+	// before moving on.
+	shutdownTimeout = 30 * time.Second
+
+	// trainDictionaryCommand is the subcommand that trains a
+	// new Zstandard dictionary from the indexer's storage
+	// instead of starting the server.
+	trainDictionaryCommand = "train-dictionary"
+
+	// defaultTrainingSamples is the number of entries sampled
+	// when training a dictionary if no count is provided.
+	defaultTrainingSamples = 150000
+
+	// noReindex is the value of the reindex flags
+	// when no blocks should be reindexed on startup.
+	noReindex = -1
 )
 
 var (
@@ -74,20 +83,46 @@ func handleSignals(ctx context.Context, listeners []context.CancelFunc) {
 	}()
 }
 
+// startOnlineDependencies starts whived and the indexer.
+// If reindexStart is not noReindex, blocks from reindexStart
+// to reindexEnd are reindexed before the indexer starts
+// syncing.
 func startOnlineDependencies(
 	ctx context.Context,
 	cancel context.CancelFunc,
 	cfg *configuration.Configuration,
 	g *errgroup.Group,
+	reindexStart int64,
+	reindexEnd int64,
 ) (*whive.Client, *indexer.Indexer, error) {
+	options := []whive.ClientOption{
+		whive.WithBatchWindow(cfg.RPCBatchWindow),
+		whive.WithMaxConcurrentRequests(cfg.RPCConcurrency),
+		whive.WithParams(cfg.Params),
+		whive.WithMaxResponseBytes(cfg.RPCMaxResponseBytes),
+		whive.WithDegradeThreshold(cfg.DegradeThreshold),
+	}
+	if len(cfg.RPCCookiePath) > 0 {
+		options = append(options, whive.WithCookiePath(cfg.RPCCookiePath))
+	}
+	if cfg.ScriptTypes {
+		options = append(options, whive.WithScriptTypes())
+	}
+
+	rpcURL := whive.LocalhostURL(cfg.RPCPort)
+	if len(cfg.RPCSocket) > 0 {
+		rpcURL = whive.UnixSocketURL(cfg.RPCSocket)
+	}
+
 	client := whive.NewClient(
-		whive.LocalhostURL(cfg.RPCPort),
+		rpcURL,
 		cfg.GenesisBlockIdentifier,
 		cfg.Currency,
+		options...,
 	)
 
 	g.Go(func() error {
-		return whive.StartBitcoind(ctx, cfg.ConfigPath, g)
+		return whive.StartBitcoind(ctx, cfg.WhivedBinaryPath, cfg.ConfigPath, cfg.WhivedPath, g)
 	})
 
 	i, err := indexer.Initialize(
@@ -101,6 +136,12 @@ func startOnlineDependencies(
 	}
 
 	g.Go(func() error {
+		if reindexStart != noReindex {
+			if err := i.Reindex(ctx, reindexStart, reindexEnd); err != nil {
+				return fmt.Errorf("%w: unable to reindex", err)
+			}
+		}
+
 		return i.Sync(ctx)
 	})
 
@@ -108,11 +149,54 @@ func startOnlineDependencies(
 		return i.Prune(ctx)
 	})
 
+	g.Go(func() error {
+		return i.MonitorStorageSize(ctx)
+	})
+
+	if cfg.MinFreeDisk > 0 {
+		g.Go(func() error {
+			return i.MonitorDiskSpace(ctx)
+		})
+	}
+
+	if cfg.HashCheckInterval > 0 {
+		g.Go(func() error {
+			return i.MonitorBlockHashes(ctx)
+		})
+	}
+
 	return client, i, nil
 }
 
+// trainDictionary trains a new Zstandard dictionary
+// for the namespace provided in args and writes it
+// to the namespace's configured DictionaryPath.
+func trainDictionary(
+	ctx context.Context,
+	cfg *configuration.Configuration,
+	args []string,
+) (string, error) {
+	flags := flag.NewFlagSet(trainDictionaryCommand, flag.ContinueOnError)
+	namespace := flags.String("namespace", "transaction", "storage namespace to train on")
+	samples := flags.Int("samples", defaultTrainingSamples, "number of entries to sample")
+	if err := flags.Parse(args); err != nil {
+		return "", err
+	}
+
+	if cfg.Mode != configuration.Online {
+		return "", errors.New("dictionaries can only be trained in online mode")
+	}
+
+	return indexer.TrainDictionary(ctx, cfg, *namespace, *samples)
+}
+
 func main() {
-	loggerRaw, err := zap.NewDevelopment()
+	// The log level is set once the configuration
+	// is loaded (until then, we log at the info level).
+	logLevel := zap.NewAtomicLevelAt(zap.InfoLevel)
+	loggerConfig := zap.NewDevelopmentConfig()
+	loggerConfig.Level = logLevel
+	loggerRaw, err := loggerConfig.Build()
 	if err != nil {
 		log.Fatalf("can't initialize zap logger: %v", err)
 	}
@@ -128,12 +212,50 @@ func main() {
 
 	logger := loggerRaw.Sugar().Named("main")
 
+	reindexStart := flag.Int64(
+		"reindex-start",
+		noReindex,
+		"height of the first block to reindex on startup",
+	)
+	reindexEnd := flag.Int64(
+		"reindex-end",
+		noReindex,
+		"height of the last block to reindex on startup",
+	)
+	flag.Parse()
+
 	cfg, err := configuration.LoadConfiguration(configuration.DataDirectory)
 	if err != nil {
 		logger.Fatalw("unable to load configuration", "error", err)
 	}
 
-	logger.Infow("loaded configuration", "configuration", types.PrintStruct(cfg))
+	logLevel.SetLevel(cfg.LogLevel)
+	logger.Infow(
+		"loaded configuration",
+		"configuration", types.PrintStruct(cfg),
+		"log level", cfg.LogLevel,
+	)
+	for _, warning := range cfg.Warnings {
+		logger.Warnw(warning)
+	}
+
+	if flag.Arg(0) == trainDictionaryCommand {
+		output, err := trainDictionary(ctx, cfg, flag.Args()[1:])
+		if err != nil {
+			logger.Fatalw("unable to train dictionary", "error", err)
+		}
+
+		logger.Infow("trained dictionary", "path", output)
+		return
+	}
+
+	if (*reindexStart == noReindex) != (*reindexEnd == noReindex) {
+		logger.Fatalw("both -reindex-start and -reindex-end must be provided")
+	}
+
+	if *reindexStart != noReindex && cfg.Mode != configuration.Online {
+		logger.Fatalw("blocks can only be reindexed in online mode")
+	}
 
 	g, ctx := errgroup.WithContext(ctx)
 
@@ -144,7 +266,14 @@ func main() {
 	var i *indexer.Indexer
 	var client *whive.Client
 	if cfg.Mode == configuration.Online {
-		client, i, err = startOnlineDependencies(ctx, cancel, cfg, g)
+		client, i, err = startOnlineDependencies(
+			ctx,
+			cancel,
+			cfg,
+			g,
+			*reindexStart,
+			*reindexEnd,
+		)
 		if err != nil {
 			logger.Fatalw("unable to start online dependencies", "error", err)
 		}
@@ -156,22 +285,61 @@ func main() {
 		whive.OperationTypes,
 		services.HistoricalBalanceLookup,
 		[]*types.NetworkIdentifier{cfg.Network},
-		nil,
+		services.CallMethods,
 		services.MempoolCoins,
+		"",
 	)
 	if err != nil {
 		logger.Fatalw("unable to create new server asserter", "error", err)
 	}
 
-	router := services.NewBlockchainRouter(cfg, client, i, asserter)
+	var router http.Handler = services.NewBlockchainRouter(cfg, client, i, asserter)
+	if cfg.ConstructionLimit > 0 {
+		router = services.ConstructionLimiterMiddleware(cfg.ConstructionLimit, router)
+	}
+	if client != nil && cfg.DegradeThreshold > 0 {
+		router = services.DegradedMiddleware(client.Degraded, router)
+	}
 	loggedRouter := services.LoggerMiddleware(loggerRaw, router)
 	corsRouter := server.CorsMiddleware(loggedRouter)
+	var rosettaHandler http.Handler = corsRouter
+	if cfg.Compression != nil {
+		rosettaHandler = services.CompressionMiddleware(cfg.Compression.MinSize, corsRouter)
+	}
+
+	// Metrics (if enabled) are served alongside
+	// the Rosetta API but are not part of it.
+	handler := http.NewServeMux()
+	if cfg.MetricsEnabled {
+		rosettaHandler = services.MetricsMiddleware(rosettaHandler)
+		handler.Handle(metrics.Route, metrics.Handler())
+	}
+	handler.Handle("/", rosettaHandler)
+
+	// Health checks are served outside of the Rosetta
+	// API (for liveness and readiness probes).
+	var syncLag func() (int64, bool)
+	var ready func() error
+	if i != nil {
+		syncLag = i.SyncLag
+		ready = i.Ready
+	}
+	var degraded func() bool
+	if client != nil {
+		degraded = client.Degraded
+	}
+	handler.Handle(services.LivenessRoute, services.LivenessHandler())
+	handler.Handle(
+		services.ReadinessRoute,
+		services.ReadinessHandler(cfg.ReadyBlocksBehind, syncLag, ready, degraded),
+	)
+
 	server := &http.Server{
 		Addr:         fmt.Sprintf(":%d", cfg.Port),
-		Handler:      corsRouter,
-		ReadTimeout:  readTimeout,
-		WriteTimeout: writeTimeout,
-		IdleTimeout:  idleTimeout,
+		Handler:      handler,
+		ReadTimeout:  cfg.HTTPReadTimeout,
+		WriteTimeout: cfg.HTTPWriteTimeout,
+		IdleTimeout:  cfg.HTTPIdleTimeout,
 	}
 
 	g.Go(func() error {
@@ -185,15 +353,57 @@ func main() {
 		// take any context.
 		<-ctx.Done()
 
-		return server.Shutdown(ctx)
+		// ctx is already canceled, so we use a new context
+		// to give in-flight requests time to finish.
+		logger.Infow("shutting down server")
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer shutdownCancel()
+
+		return server.Shutdown(shutdownCtx)
 	})
 
-	err = g.Wait()
+	waitErr := make(chan error, 1)
+	go func() {
+		waitErr <- g.Wait()
+	}()
+
+	select {
+	case err = <-waitErr:
+	case <-ctx.Done():
+		logger.Infow("waiting for services to stop", "timeout", shutdownTimeout)
+		select {
+		case err = <-waitErr:
+			logger.Infow("services stopped")
+		case <-time.After(shutdownTimeout):
+			err = fmt.Errorf("timed out after %s waiting for services to stop", shutdownTimeout)
+			logger.Warnw("timed out waiting for services to stop")
+		}
+	}
 
 	// We always want to attempt to close the database, regardless of the error.
-	// We also want to do this after all indexer goroutines have stopped.
+	// We only do this after the indexer has finished writing the current block
+	// (if any), so that storage is not left partially written. If the write
+	// doesn't finish in time, we exit without closing the database (badger
+	// recovers from its value log on the next start).
 	if i != nil {
-		i.CloseDatabase(ctx)
+		logger.Infow("waiting for indexer to finish writing", "timeout", shutdownTimeout)
+		stopCtx, stopCancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		stopErr := i.StopWrites(stopCtx)
+		stopCancel()
+
+		if stopErr != nil {
+			logger.Errorw(
+				"timed out waiting for indexer to finish writing, not closing database",
+				"error", stopErr,
+			)
+			if err == nil {
+				err = fmt.Errorf("%w: unable to stop indexer writes", stopErr)
+			}
+		} else {
+			logger.Infow("indexer stopped writing")
+			logger.Infow("closing indexer database")
+			i.CloseDatabase(ctx)
+		}
 	}
 
 	if signalReceived {
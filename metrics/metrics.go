@@ -0,0 +1,323 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const (
+	// namespace is prepended to the name of
+	// all metrics exported by rosetta-whive.
+	namespace = "rosetta_whive"
+
+	// apiSubsystem is the subsystem of all
+	// metrics about requests to the Rosetta API.
+	apiSubsystem = "api"
+
+	// constructionSubsystem is the subsystem of
+	// all metrics about the Construction API.
+	constructionSubsystem = "construction"
+
+	// indexerSubsystem is the subsystem of
+	// all metrics about the indexer.
+	indexerSubsystem = "indexer"
+
+	// whivedSubsystem is the subsystem of all
+	// metrics about requests made to whived.
+	whivedSubsystem = "whived"
+
+	// Route is the path metrics are served on.
+	Route = "/metrics"
+)
+
+// Outcomes of a transaction in the Construction API.
+const (
+	OutcomeConstructed = "constructed"
+	OutcomeSubmitted   = "submitted"
+	OutcomeRejected    = "rejected"
+)
+
+// Outcomes of an attempt to prune whived.
+const (
+	PruneOutcomePruned = "pruned"
+	PruneOutcomeFailed = "failed"
+)
+
+// Directions of a connection between whived and a peer.
+const (
+	PeerDirectionInbound  = "inbound"
+	PeerDirectionOutbound = "outbound"
+)
+
+// UnknownEndpoint is the endpoint label of API requests
+// to paths that are not served, so that arbitrary paths
+// don't create new series.
+const UnknownEndpoint = "unknown"
+
+// Reasons a transaction submission is rejected.
+const (
+	RejectReasonInvalid        = "invalid"
+	RejectReasonVerify         = "verify"
+	RejectReasonPolicy         = "policy"
+	RejectReasonAlreadyInChain = "already_in_chain"
+	RejectReasonMempoolChain   = "mempool_chain"
+	RejectReasonWhived         = "whived"
+)
+
+var (
+	// APIRequestDuration observes the duration (in seconds)
+	// of requests to the Rosetta API by endpoint.
+	APIRequestDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: apiSubsystem,
+			Name:      "request_duration_seconds",
+			Help:      "Duration of requests to the Rosetta API by endpoint.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"endpoint"},
+	)
+
+	// ConstructionTransactions counts transactions
+	// handled by the Construction API by outcome.
+	ConstructionTransactions = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: constructionSubsystem,
+			Name:      "transactions_total",
+			Help:      "Transactions constructed, submitted, or rejected by the Construction API.",
+		},
+		[]string{"outcome"},
+	)
+
+	// ConstructionRejections counts transactions
+	// rejected on submission by reason.
+	ConstructionRejections = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: constructionSubsystem,
+			Name:      "rejections_total",
+			Help:      "Transactions rejected by /construction/submit by reason.",
+		},
+		[]string{"reason"},
+	)
+
+	// ConstructionFeeRate observes the fee rate (in
+	// satoshis per vByte) suggested by /construction/metadata.
+	ConstructionFeeRate = promauto.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: constructionSubsystem,
+			Name:      "fee_rate_satoshis_per_vbyte",
+			Help:      "Fee rates suggested by /construction/metadata.",
+			Buckets:   []float64{1, 2, 5, 10, 20, 50, 100, 200, 500, 1000},
+		},
+	)
+
+	// IndexerStorageBytes is the number of bytes
+	// used by the indexer storage directory.
+	IndexerStorageBytes = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: indexerSubsystem,
+			Name:      "storage_bytes",
+			Help:      "Bytes used by the indexer storage directory.",
+		},
+	)
+
+	// IndexerHeadIndex is the index of the
+	// last block added to the indexer.
+	IndexerHeadIndex = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: indexerSubsystem,
+			Name:      "head_index",
+			Help:      "Index of the last block added to the indexer.",
+		},
+	)
+
+	// IndexerPrunes counts attempts to
+	// prune whived by outcome.
+	IndexerPrunes = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: indexerSubsystem,
+			Name:      "prunes_total",
+			Help:      "Attempts to prune whived by outcome.",
+		},
+		[]string{"outcome"},
+	)
+
+	// IndexerOrphanedBlocks counts blocks removed
+	// from the indexer during reorgs.
+	IndexerOrphanedBlocks = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: indexerSubsystem,
+			Name:      "orphaned_blocks_total",
+			Help:      "Blocks removed from the indexer during reorgs.",
+		},
+	)
+
+	// IndexerHashMismatches counts sampled indexed
+	// blocks whose hash did not match whived's.
+	IndexerHashMismatches = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: indexerSubsystem,
+			Name:      "hash_mismatches_total",
+			Help:      "Sampled indexed blocks whose hash did not match whived's.",
+		},
+	)
+
+	// IndexerBlocksBehind is the number of blocks
+	// the indexer is behind whived's tip.
+	IndexerBlocksBehind = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: indexerSubsystem,
+			Name:      "blocks_behind",
+			Help:      "Blocks between whived's tip and the indexer's head.",
+		},
+	)
+
+	// WhivedRPCLatency observes the round-trip latency
+	// (in seconds) of JSON-RPC calls to whived by method.
+	WhivedRPCLatency = promauto.NewSummaryVec(
+		prometheus.SummaryOpts{
+			Namespace:  namespace,
+			Subsystem:  whivedSubsystem,
+			Name:       "rpc_latency_seconds",
+			Help:       "Round-trip latency of JSON-RPC calls to whived by method.",
+			Objectives: map[float64]float64{0.5: 0.05, 0.95: 0.01, 0.99: 0.001},
+		},
+		[]string{"method"},
+	)
+
+	// WhivedRPCErrors counts JSON-RPC calls
+	// to whived that failed by method.
+	WhivedRPCErrors = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: whivedSubsystem,
+			Name:      "rpc_errors_total",
+			Help:      "JSON-RPC calls to whived that failed by method.",
+		},
+		[]string{"method"},
+	)
+
+	// WhivedPeers is the number of peers
+	// whived is connected to by direction.
+	WhivedPeers = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: whivedSubsystem,
+			Name:      "peers",
+			Help:      "Peers whived is connected to by direction.",
+		},
+		[]string{"direction"},
+	)
+
+	// WhivedDegraded is 1 while whived is unreachable
+	// and only offline endpoints are served.
+	WhivedDegraded = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: whivedSubsystem,
+			Name:      "degraded",
+			Help:      "Whether only offline endpoints are served because whived is unreachable.",
+		},
+	)
+)
+
+// LatencySummary is the number of observed
+// calls and the p50/p95/p99 latencies (in seconds)
+// of a whived JSON-RPC method.
+type LatencySummary struct {
+	Count uint64  `json:"count"`
+	P50   float64 `json:"p50"`
+	P95   float64 `json:"p95"`
+	P99   float64 `json:"p99"`
+}
+
+// Handler returns an http.Handler that serves
+// all registered metrics.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// TransactionRejected records a transaction
+// rejected on submission for reason.
+func TransactionRejected(reason string) {
+	ConstructionTransactions.WithLabelValues(OutcomeRejected).Inc()
+	ConstructionRejections.WithLabelValues(reason).Inc()
+}
+
+// RPCLatencies returns a *LatencySummary of
+// WhivedRPCLatency for each observed method.
+func RPCLatencies() (map[string]*LatencySummary, error) {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		WhivedRPCLatency.Collect(ch)
+		close(ch)
+	}()
+
+	summaries := map[string]*LatencySummary{}
+	var err error
+	for metric := range ch {
+		// We continue to drain ch after an
+		// error so that Collect can return.
+		if err != nil {
+			continue
+		}
+
+		m := &dto.Metric{}
+		if err = metric.Write(m); err != nil {
+			continue
+		}
+
+		summary := &LatencySummary{
+			Count: m.GetSummary().GetSampleCount(),
+		}
+		for _, quantile := range m.GetSummary().GetQuantile() {
+			switch quantile.GetQuantile() {
+			case 0.5:
+				summary.P50 = quantile.GetValue()
+			case 0.95:
+				summary.P95 = quantile.GetValue()
+			case 0.99:
+				summary.P99 = quantile.GetValue()
+			}
+		}
+
+		for _, label := range m.GetLabel() {
+			if label.GetName() == "method" {
+				summaries[label.GetValue()] = summary
+			}
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return summaries, nil
+}
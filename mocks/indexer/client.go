@@ -7,6 +7,8 @@ import (
 
 	bitcoin "github.com/xyephy/rosetta-whive/whive"
 
+	chaincfg "github.com/btcsuite/btcd/chaincfg"
+
 	mock "github.com/stretchr/testify/mock"
 
 	types "github.com/coinbase/rosetta-sdk-go/types"
@@ -17,6 +19,50 @@ type Client struct {
 	mock.Mock
 }
 
+// BlockHash provides a mock function with given fields: _a0, _a1
+func (_m *Client) BlockHash(_a0 context.Context, _a1 int64) (string, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(context.Context, int64) string); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// BlockchainInfo provides a mock function with given fields: _a0
+func (_m *Client) BlockchainInfo(_a0 context.Context) (*bitcoin.BlockchainInfo, error) {
+	ret := _m.Called(_a0)
+
+	var r0 *bitcoin.BlockchainInfo
+	if rf, ok := ret.Get(0).(func(context.Context) *bitcoin.BlockchainInfo); ok {
+		r0 = rf(_a0)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*bitcoin.BlockchainInfo)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetRawBlock provides a mock function with given fields: _a0, _a1
 func (_m *Client) GetRawBlock(_a0 context.Context, _a1 *types.PartialBlockIdentifier) (*bitcoin.Block, []string, error) {
 	ret := _m.Called(_a0, _a1)
@@ -115,3 +161,38 @@ func (_m *Client) PruneBlockchain(_a0 context.Context, _a1 int64) (int64, error)
 
 	return r0, r1
 }
+
+// TxIndexEnabled provides a mock function with given fields: _a0
+func (_m *Client) TxIndexEnabled(_a0 context.Context) (bool, error) {
+	ret := _m.Called(_a0)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context) bool); ok {
+		r0 = rf(_a0)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ValidateNetwork provides a mock function with given fields: _a0, _a1
+func (_m *Client) ValidateNetwork(_a0 context.Context, _a1 *chaincfg.Params) error {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *chaincfg.Params) error); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
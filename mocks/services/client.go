@@ -5,6 +5,8 @@ package services
 import (
 	context "context"
 
+	bitcoin "github.com/xyephy/rosetta-whive/whive"
+
 	mock "github.com/stretchr/testify/mock"
 
 	types "github.com/coinbase/rosetta-sdk-go/types"
@@ -15,6 +17,52 @@ type Client struct {
 	mock.Mock
 }
 
+// BlockHeader provides a mock function with given fields: _a0, _a1
+func (_m *Client) BlockHeader(_a0 context.Context, _a1 int64) (*bitcoin.BlockHeader, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 *bitcoin.BlockHeader
+	if rf, ok := ret.Get(0).(func(context.Context, int64) *bitcoin.BlockHeader); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*bitcoin.BlockHeader)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// BlockchainInfo provides a mock function with given fields: _a0
+func (_m *Client) BlockchainInfo(_a0 context.Context) (*bitcoin.BlockchainInfo, error) {
+	ret := _m.Called(_a0)
+
+	var r0 *bitcoin.BlockchainInfo
+	if rf, ok := ret.Get(0).(func(context.Context) *bitcoin.BlockchainInfo); ok {
+		r0 = rf(_a0)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*bitcoin.BlockchainInfo)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetPeers provides a mock function with given fields: _a0
 func (_m *Client) GetPeers(_a0 context.Context) ([]*types.Peer, error) {
 	ret := _m.Called(_a0)
@@ -38,6 +86,96 @@ func (_m *Client) GetPeers(_a0 context.Context) ([]*types.Peer, error) {
 	return r0, r1
 }
 
+// MempoolBalance provides a mock function with given fields: _a0, _a1, _a2
+func (_m *Client) MempoolBalance(_a0 context.Context, _a1 *types.AccountIdentifier, _a2 []*types.Coin) (*types.Amount, error) {
+	ret := _m.Called(_a0, _a1, _a2)
+
+	var r0 *types.Amount
+	if rf, ok := ret.Get(0).(func(context.Context, *types.AccountIdentifier, []*types.Coin) *types.Amount); ok {
+		r0 = rf(_a0, _a1, _a2)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*types.Amount)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *types.AccountIdentifier, []*types.Coin) error); ok {
+		r1 = rf(_a0, _a1, _a2)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MempoolCoins provides a mock function with given fields: _a0, _a1, _a2
+func (_m *Client) MempoolCoins(_a0 context.Context, _a1 *types.AccountIdentifier, _a2 []*types.Coin) ([]*types.Coin, error) {
+	ret := _m.Called(_a0, _a1, _a2)
+
+	var r0 []*types.Coin
+	if rf, ok := ret.Get(0).(func(context.Context, *types.AccountIdentifier, []*types.Coin) []*types.Coin); ok {
+		r0 = rf(_a0, _a1, _a2)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*types.Coin)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *types.AccountIdentifier, []*types.Coin) error); ok {
+		r1 = rf(_a0, _a1, _a2)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MempoolMinFeeRate provides a mock function with given fields: _a0
+func (_m *Client) MempoolMinFeeRate(_a0 context.Context) (float64, error) {
+	ret := _m.Called(_a0)
+
+	var r0 float64
+	if rf, ok := ret.Get(0).(func(context.Context) float64); ok {
+		r0 = rf(_a0)
+	} else {
+		r0 = ret.Get(0).(float64)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MempoolTransaction provides a mock function with given fields: _a0, _a1
+func (_m *Client) MempoolTransaction(_a0 context.Context, _a1 string) (*types.Transaction, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 *types.Transaction
+	if rf, ok := ret.Get(0).(func(context.Context, string) *types.Transaction); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*types.Transaction)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // RawMempool provides a mock function with given fields: _a0
 func (_m *Client) RawMempool(_a0 context.Context) ([]string, error) {
 	ret := _m.Called(_a0)
@@ -102,3 +240,26 @@ func (_m *Client) SuggestedFeeRate(_a0 context.Context, _a1 int64) (float64, err
 
 	return r0, r1
 }
+
+// TransactionBlock provides a mock function with given fields: _a0, _a1
+func (_m *Client) TransactionBlock(_a0 context.Context, _a1 string) (*types.BlockIdentifier, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 *types.BlockIdentifier
+	if rf, ok := ret.Get(0).(func(context.Context, string) *types.BlockIdentifier); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*types.BlockIdentifier)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
@@ -95,6 +95,61 @@ func (_m *Indexer) GetBlockTransaction(_a0 context.Context, _a1 *types.BlockIden
 	return r0, r1
 }
 
+// GetCoin provides a mock function with given fields: _a0, _a1
+func (_m *Indexer) GetCoin(_a0 context.Context, _a1 *types.CoinIdentifier) (*types.Coin, *types.AccountIdentifier, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 *types.Coin
+	if rf, ok := ret.Get(0).(func(context.Context, *types.CoinIdentifier) *types.Coin); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*types.Coin)
+		}
+	}
+
+	var r1 *types.AccountIdentifier
+	if rf, ok := ret.Get(1).(func(context.Context, *types.CoinIdentifier) *types.AccountIdentifier); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(*types.AccountIdentifier)
+		}
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, *types.CoinIdentifier) error); ok {
+		r2 = rf(_a0, _a1)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// GetCoinBlocks provides a mock function with given fields: _a0, _a1
+func (_m *Indexer) GetCoinBlocks(_a0 context.Context, _a1 []*types.Coin) (map[string]*types.BlockIdentifier, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 map[string]*types.BlockIdentifier
+	if rf, ok := ret.Get(0).(func(context.Context, []*types.Coin) map[string]*types.BlockIdentifier); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]*types.BlockIdentifier)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, []*types.Coin) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetCoins provides a mock function with given fields: _a0, _a1
 func (_m *Indexer) GetCoins(_a0 context.Context, _a1 *types.AccountIdentifier) ([]*types.Coin, *types.BlockIdentifier, error) {
 	ret := _m.Called(_a0, _a1)
@@ -127,6 +182,36 @@ func (_m *Indexer) GetCoins(_a0 context.Context, _a1 *types.AccountIdentifier) (
 	return r0, r1, r2
 }
 
+// GetFirstSeen provides a mock function with given fields: _a0, _a1
+func (_m *Indexer) GetFirstSeen(_a0 context.Context, _a1 *types.AccountIdentifier) (*types.BlockIdentifier, int64, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 *types.BlockIdentifier
+	if rf, ok := ret.Get(0).(func(context.Context, *types.AccountIdentifier) *types.BlockIdentifier); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*types.BlockIdentifier)
+		}
+	}
+
+	var r1 int64
+	if rf, ok := ret.Get(1).(func(context.Context, *types.AccountIdentifier) int64); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Get(1).(int64)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, *types.AccountIdentifier) error); ok {
+		r2 = rf(_a0, _a1)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
 // GetScriptPubKeys provides a mock function with given fields: _a0, _a1
 func (_m *Indexer) GetScriptPubKeys(_a0 context.Context, _a1 []*types.Coin) ([]*bitcoin.ScriptPubKey, error) {
 	ret := _m.Called(_a0, _a1)
@@ -149,3 +234,66 @@ func (_m *Indexer) GetScriptPubKeys(_a0 context.Context, _a1 []*types.Coin) ([]*
 
 	return r0, r1
 }
+
+// Ready provides a mock function with given fields:
+func (_m *Indexer) Ready() error {
+	ret := _m.Called()
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func() error); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// StorageSize provides a mock function with given fields:
+func (_m *Indexer) StorageSize() uint64 {
+	ret := _m.Called()
+
+	var r0 uint64
+	if rf, ok := ret.Get(0).(func() uint64); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(uint64)
+	}
+
+	return r0
+}
+
+// SyncLag provides a mock function with given fields:
+func (_m *Indexer) SyncLag() (int64, bool) {
+	ret := _m.Called()
+
+	var r0 int64
+	if rf, ok := ret.Get(0).(func() int64); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	var r1 bool
+	if rf, ok := ret.Get(1).(func() bool); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	return r0, r1
+}
+
+// TxIndexEnabled provides a mock function with given fields:
+func (_m *Indexer) TxIndexEnabled() bool {
+	ret := _m.Called()
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func() bool); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
@@ -16,9 +16,15 @@ package services
 
 import (
 	"context"
+	"encoding/hex"
+	"fmt"
+	"strconv"
 
 	"github.com/xyephy/rosetta-whive/configuration"
+	"github.com/xyephy/rosetta-whive/whive"
 
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcutil"
 	"github.com/coinbase/rosetta-sdk-go/server"
 	"github.com/coinbase/rosetta-sdk-go/types"
 )
@@ -26,16 +32,19 @@ import (
 // AccountAPIService implements the server.AccountAPIServicer interface.
 type AccountAPIService struct {
 	config *configuration.Configuration
+	client Client
 	i      Indexer
 }
 
 // NewAccountAPIService returns a new *AccountAPIService.
 func NewAccountAPIService(
 	config *configuration.Configuration,
+	client Client,
 	i Indexer,
 ) server.AccountAPIServicer {
 	return &AccountAPIService{
 		config: config,
+		client: client,
 		i:      i,
 	}
 }
@@ -63,12 +72,109 @@ func (s *AccountAPIService) AccountBalance(
 		return nil, wrapErr(ErrUnableToGetBalance, err)
 	}
 
-	return &types.AccountBalanceResponse{
+	response := &types.AccountBalanceResponse{
 		BlockIdentifier: block,
 		Balances: []*types.Amount{
 			amount,
 		},
-	}, nil
+	}
+
+	// The mempool and dust only affect the current balance.
+	reportDust := s.config.DustRelayFee > 0
+	if (!s.config.IncludeMempool && !reportDust) || request.BlockIdentifier != nil {
+		return response, nil
+	}
+
+	coins, _, err := s.i.GetCoins(ctx, request.AccountIdentifier)
+	if err != nil {
+		return nil, wrapErr(ErrUnableToGetCoins, err)
+	}
+
+	balance := &balanceMetadata{}
+	if s.config.IncludeMempool {
+		balance.UnconfirmedBalance, err = s.client.MempoolBalance(
+			ctx,
+			request.AccountIdentifier,
+			coins,
+		)
+		if err != nil {
+			return nil, wrapErr(ErrWhived, err)
+		}
+	}
+
+	if reportDust {
+		dustBalance, dustOnly, dustErr := s.dustBalance(request.AccountIdentifier, coins)
+		if dustErr != nil {
+			return nil, dustErr
+		}
+
+		balance.DustBalance = dustBalance
+		balance.DustOnly = types.Bool(dustOnly)
+	}
+
+	metadata, err := types.MarshalMap(balance)
+	if err != nil {
+		return nil, wrapErr(ErrUnableToGetBalance, err)
+	}
+	response.Metadata = metadata
+
+	return response, nil
+}
+
+// accountScript returns the locking script of account. Outputs
+// without a standard address are keyed by the hex of their
+// script (see parseOutputAccount), so an address that does
+// not decode is interpreted as a raw script instead.
+func (s *AccountAPIService) accountScript(account *types.AccountIdentifier) ([]byte, error) {
+	addr, err := btcutil.DecodeAddress(account.Address, s.config.Params)
+	if err != nil {
+		script, hexErr := hex.DecodeString(account.Address)
+		if hexErr != nil {
+			return nil, fmt.Errorf("%w: unable to decode address %s", err, account.Address)
+		}
+
+		return script, nil
+	}
+
+	script, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to construct script for %s", err, account.Address)
+	}
+
+	return script, nil
+}
+
+// dustBalance returns the sum of coins that are below the
+// dust threshold of the account's script at the configured
+// dust relay fee and whether all coins are dust.
+func (s *AccountAPIService) dustBalance(
+	account *types.AccountIdentifier,
+	coins []*types.Coin,
+) (*types.Amount, bool, *types.Error) {
+	script, err := s.accountScript(account)
+	if err != nil {
+		return nil, false, wrapErr(ErrUnableToDecodeAddress, err)
+	}
+
+	threshold := whive.DustThreshold(script, s.config.DustRelayFee)
+	dust := int64(0)
+	dustCoins := 0
+	for _, coin := range coins {
+		value, err := types.AmountValue(coin.Amount)
+		if err != nil {
+			return nil, false, wrapErr(ErrUnableToGetBalance, err)
+		}
+
+		if value.Int64() < threshold {
+			dust += value.Int64()
+			dustCoins++
+		}
+	}
+
+	return &types.Amount{
+		Value:    strconv.FormatInt(dust, 10),
+		Currency: s.config.Currency,
+	}, len(coins) > 0 && dustCoins == len(coins), nil
 }
 
 // AccountCoins implements /account/coins.
@@ -82,19 +188,63 @@ func (s *AccountAPIService) AccountCoins(
 
 	// TODO: filter coins by request currencies
 
-	// TODO: support include_mempool query
-	// https://github.com/coinbase/rosetta-bitcoin/issues/36#issuecomment-724992022
-	// Once mempoolcoins are supported also change the bool service/types.go:MempoolCoins to true
-
 	coins, block, err := s.i.GetCoins(ctx, request.AccountIdentifier)
 	if err != nil {
 		return nil, wrapErr(ErrUnableToGetCoins, err)
 	}
 
+	// Coins created in the mempool are
+	// returned with 0 confirmations.
+	if request.IncludeMempool {
+		coins, err = s.client.MempoolCoins(ctx, request.AccountIdentifier, coins)
+		if err != nil {
+			return nil, wrapErr(ErrWhived, err)
+		}
+	}
+
+	metadata, err := s.coinsMetadata(ctx, coins, block)
+	if err != nil {
+		return nil, wrapErr(ErrUnableToGetCoins, err)
+	}
+
 	result := &types.AccountCoinsResponse{
 		BlockIdentifier: block,
 		Coins:           coins,
+		Metadata:        metadata,
 	}
 
 	return result, nil
 }
+
+// coinsMetadata returns the age and outpoint of each coin,
+// keyed by coin identifier. Coins that have not been included
+// in a block yet have 0 confirmations.
+func (s *AccountAPIService) coinsMetadata(
+	ctx context.Context,
+	coins []*types.Coin,
+	tip *types.BlockIdentifier,
+) (map[string]interface{}, error) {
+	blocks, err := s.i.GetCoinBlocks(ctx, coins)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := map[string]*coinMetadata{}
+	for _, coin := range coins {
+		m := &coinMetadata{}
+		if created, ok := blocks[coin.CoinIdentifier.Identifier]; ok {
+			m.CreatedHeight = &created.Index
+			m.Confirmations = tip.Index - created.Index + 1
+		}
+
+		if hash, index, err := whive.ParseCoinIdentifier(coin.CoinIdentifier); err == nil {
+			outputIndex := int64(index)
+			m.TransactionIdentifier = &types.TransactionIdentifier{Hash: hash.String()}
+			m.OutputIndex = &outputIndex
+		}
+
+		metadata[coin.CoinIdentifier.Identifier] = m
+	}
+
+	return types.MarshalMap(&accountCoinsMetadata{Coins: metadata})
+}
@@ -16,11 +16,13 @@ package services
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"testing"
 
 	"github.com/xyephy/rosetta-whive/configuration"
-	"github.com/xyephy/rosetta-whive/whive"
 	mocks "github.com/xyephy/rosetta-whive/mocks/services"
+	"github.com/xyephy/rosetta-whive/whive"
 
 	"github.com/coinbase/rosetta-sdk-go/types"
 	"github.com/stretchr/testify/assert"
@@ -30,8 +32,9 @@ func TestAccountBalance_Offline(t *testing.T) {
 	cfg := &configuration.Configuration{
 		Mode: configuration.Offline,
 	}
+	mockClient := &mocks.Client{}
 	mockIndexer := &mocks.Indexer{}
-	servicer := NewAccountAPIService(cfg, mockIndexer)
+	servicer := NewAccountAPIService(cfg, mockClient, mockIndexer)
 	ctx := context.Background()
 
 	bal, err := servicer.AccountBalance(ctx, &types.AccountBalanceRequest{})
@@ -50,8 +53,9 @@ func TestAccountBalance_Online_Current(t *testing.T) {
 		Mode:     configuration.Online,
 		Currency: whive.MainnetCurrency,
 	}
+	mockClient := &mocks.Client{}
 	mockIndexer := &mocks.Indexer{}
-	servicer := NewAccountAPIService(cfg, mockIndexer)
+	servicer := NewAccountAPIService(cfg, mockClient, mockIndexer)
 	ctx := context.Background()
 	account := &types.AccountIdentifier{
 		Address: "hello",
@@ -91,8 +95,9 @@ func TestAccountBalance_Online_Historical(t *testing.T) {
 		Mode:     configuration.Online,
 		Currency: whive.MainnetCurrency,
 	}
+	mockClient := &mocks.Client{}
 	mockIndexer := &mocks.Indexer{}
-	servicer := NewAccountAPIService(cfg, mockIndexer)
+	servicer := NewAccountAPIService(cfg, mockClient, mockIndexer)
 	ctx := context.Background()
 	account := &types.AccountIdentifier{
 		Address: "hello",
@@ -131,13 +136,236 @@ func TestAccountBalance_Online_Historical(t *testing.T) {
 	mockIndexer.AssertExpectations(t)
 }
 
+func TestAccountBalance_Online_IncludeMempool(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode:           configuration.Online,
+		Currency:       whive.MainnetCurrency,
+		IncludeMempool: true,
+	}
+	mockClient := &mocks.Client{}
+	mockIndexer := &mocks.Indexer{}
+	servicer := NewAccountAPIService(cfg, mockClient, mockIndexer)
+	ctx := context.Background()
+	account := &types.AccountIdentifier{
+		Address: "hello",
+	}
+	block := &types.BlockIdentifier{
+		Index: 1000,
+		Hash:  "block 1000",
+	}
+	amount := &types.Amount{
+		Value:    "25",
+		Currency: whive.MainnetCurrency,
+	}
+	coins := []*types.Coin{
+		{
+			Amount:         amount,
+			CoinIdentifier: &types.CoinIdentifier{Identifier: "coin 1"},
+		},
+	}
+
+	// A pending incoming transaction is reported
+	// separately from the confirmed balance.
+	unconfirmed := &types.Amount{
+		Value:    "10",
+		Currency: whive.MainnetCurrency,
+	}
+	mockIndexer.On(
+		"GetBalance",
+		ctx,
+		account,
+		whive.MainnetCurrency,
+		(*types.PartialBlockIdentifier)(nil),
+	).Return(amount, block, nil).Once()
+	mockIndexer.On("GetCoins", ctx, account).Return(coins, block, nil).Once()
+	mockClient.On("MempoolBalance", ctx, account, coins).Return(unconfirmed, nil).Once()
+	bal, err := servicer.AccountBalance(ctx, &types.AccountBalanceRequest{
+		AccountIdentifier: account,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, &types.AccountBalanceResponse{
+		BlockIdentifier: block,
+		Balances: []*types.Amount{
+			amount,
+		},
+		Metadata: forceMarshalMap(t, &balanceMetadata{UnconfirmedBalance: unconfirmed}),
+	}, bal)
+
+	// Historical balances ignore the mempool.
+	partialBlock := &types.PartialBlockIdentifier{
+		Index: &block.Index,
+	}
+	mockIndexer.On(
+		"GetBalance",
+		ctx,
+		account,
+		whive.MainnetCurrency,
+		partialBlock,
+	).Return(amount, block, nil).Once()
+	bal, err = servicer.AccountBalance(ctx, &types.AccountBalanceRequest{
+		AccountIdentifier: account,
+		BlockIdentifier:   partialBlock,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, &types.AccountBalanceResponse{
+		BlockIdentifier: block,
+		Balances: []*types.Amount{
+			amount,
+		},
+	}, bal)
+
+	mockClient.AssertExpectations(t)
+	mockIndexer.AssertExpectations(t)
+}
+
+func TestAccountBalance_Online_Dust(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode:         configuration.Online,
+		Params:       whive.TestnetParams,
+		Currency:     whive.TestnetCurrency,
+		DustRelayFee: 3000,
+	}
+	mockClient := &mocks.Client{}
+	mockIndexer := &mocks.Indexer{}
+	servicer := NewAccountAPIService(cfg, mockClient, mockIndexer)
+	ctx := context.Background()
+
+	// The dust threshold of a P2WPKH output at
+	// 3000 sat/kvB is (31 + 67) * 3 = 294.
+	account := &types.AccountIdentifier{
+		Address: "tb1qcqzmqzkswhfshzd8kedhmtvgnxax48z4fklhvm",
+	}
+
+	// Outputs without a standard address (here a compressed
+	// P2PK output) are keyed by their script hex. The threshold
+	// is (44 + 148) * 3 = 576.
+	scriptAccount := &types.AccountIdentifier{
+		Address: "2103" + strings.Repeat("11", 32) + "ac",
+	}
+	block := &types.BlockIdentifier{
+		Index: 1000,
+		Hash:  "block 1000",
+	}
+	coin := func(identifier string, value string) *types.Coin {
+		return &types.Coin{
+			Amount: &types.Amount{
+				Value:    value,
+				Currency: whive.TestnetCurrency,
+			},
+			CoinIdentifier: &types.CoinIdentifier{Identifier: identifier},
+		}
+	}
+
+	tests := map[string]struct {
+		account *types.AccountIdentifier
+		balance string
+		coins   []*types.Coin
+
+		expectedMetadata *balanceMetadata
+	}{
+		"mixed": {
+			balance: "5687",
+			coins: []*types.Coin{
+				coin("coin 1", "100"),
+				coin("coin 2", "293"),
+				coin("coin 3", "294"),
+				coin("coin 4", "5000"),
+			},
+			expectedMetadata: &balanceMetadata{
+				DustBalance: &types.Amount{
+					Value:    "393",
+					Currency: whive.TestnetCurrency,
+				},
+				DustOnly: types.Bool(false),
+			},
+		},
+		"dust only": {
+			balance: "393",
+			coins: []*types.Coin{
+				coin("coin 1", "100"),
+				coin("coin 2", "293"),
+			},
+			expectedMetadata: &balanceMetadata{
+				DustBalance: &types.Amount{
+					Value:    "393",
+					Currency: whive.TestnetCurrency,
+				},
+				DustOnly: types.Bool(true),
+			},
+		},
+		"script account": {
+			account: scriptAccount,
+			balance: "5575",
+			coins: []*types.Coin{
+				coin("coin 1", "575"),
+				coin("coin 2", "5000"),
+			},
+			expectedMetadata: &balanceMetadata{
+				DustBalance: &types.Amount{
+					Value:    "575",
+					Currency: whive.TestnetCurrency,
+				},
+				DustOnly: types.Bool(false),
+			},
+		},
+		"no coins": {
+			balance: "0",
+			coins:   []*types.Coin{},
+			expectedMetadata: &balanceMetadata{
+				DustBalance: &types.Amount{
+					Value:    "0",
+					Currency: whive.TestnetCurrency,
+				},
+				DustOnly: types.Bool(false),
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			account := account
+			if test.account != nil {
+				account = test.account
+			}
+
+			amount := &types.Amount{
+				Value:    test.balance,
+				Currency: whive.TestnetCurrency,
+			}
+			mockIndexer.On(
+				"GetBalance",
+				ctx,
+				account,
+				whive.TestnetCurrency,
+				(*types.PartialBlockIdentifier)(nil),
+			).Return(amount, block, nil).Once()
+			mockIndexer.On("GetCoins", ctx, account).Return(test.coins, block, nil).Once()
+			bal, err := servicer.AccountBalance(ctx, &types.AccountBalanceRequest{
+				AccountIdentifier: account,
+			})
+			assert.Nil(t, err)
+			assert.Equal(t, &types.AccountBalanceResponse{
+				BlockIdentifier: block,
+				Balances: []*types.Amount{
+					amount,
+				},
+				Metadata: forceMarshalMap(t, test.expectedMetadata),
+			}, bal)
+		})
+	}
+
+	mockClient.AssertExpectations(t)
+	mockIndexer.AssertExpectations(t)
+}
+
 func TestAccountCoins_Online(t *testing.T) {
 	cfg := &configuration.Configuration{
 		Mode:     configuration.Online,
 		Currency: whive.MainnetCurrency,
 	}
+	mockClient := &mocks.Client{}
 	mockIndexer := &mocks.Indexer{}
-	servicer := NewAccountAPIService(cfg, mockIndexer)
+	servicer := NewAccountAPIService(cfg, mockClient, mockIndexer)
 	ctx := context.Background()
 
 	account := &types.AccountIdentifier{
@@ -169,12 +397,31 @@ func TestAccountCoins_Online(t *testing.T) {
 				Identifier: "coin 3",
 			},
 		},
+		{
+			Amount: &types.Amount{
+				Value: "20",
+			},
+			CoinIdentifier: &types.CoinIdentifier{
+				Identifier: "4852fe372ff7534c16713b3146bbc1e86379c70bea4d5c02fb1fa0112980a081:1",
+			},
+		},
 	}
 	block := &types.BlockIdentifier{
 		Index: 1000,
 		Hash:  "block 1000",
 	}
 	mockIndexer.On("GetCoins", ctx, account).Return(coins, block, nil).Once()
+	mockIndexer.On("GetCoinBlocks", ctx, coins).Return(map[string]*types.BlockIdentifier{
+		"coin 1": {
+			Index: 995,
+			Hash:  "block 995",
+		},
+		"coin 2": block,
+		"4852fe372ff7534c16713b3146bbc1e86379c70bea4d5c02fb1fa0112980a081:1": {
+			Index: 990,
+			Hash:  "block 990",
+		},
+	}, nil).Once()
 
 	bal, err := servicer.AccountCoins(ctx, &types.AccountCoinsRequest{
 		AccountIdentifier: account,
@@ -184,7 +431,135 @@ func TestAccountCoins_Online(t *testing.T) {
 	assert.Equal(t, &types.AccountCoinsResponse{
 		BlockIdentifier: block,
 		Coins:           coins,
+		Metadata: map[string]interface{}{
+			"coins": map[string]*coinMetadata{
+				"coin 1": {
+					CreatedHeight: types.Int64(995),
+					Confirmations: 6,
+				},
+				"coin 2": {
+					CreatedHeight: types.Int64(1000),
+					Confirmations: 1,
+				},
+				"coin 3": {
+					Confirmations: 0,
+				},
+				"4852fe372ff7534c16713b3146bbc1e86379c70bea4d5c02fb1fa0112980a081:1": {
+					CreatedHeight: types.Int64(990),
+					Confirmations: 11,
+					TransactionIdentifier: &types.TransactionIdentifier{
+						Hash: "4852fe372ff7534c16713b3146bbc1e86379c70bea4d5c02fb1fa0112980a081",
+					},
+					OutputIndex: types.Int64(1),
+				},
+			},
+		},
 	}, bal)
 
 	mockIndexer.AssertExpectations(t)
 }
+
+func TestAccountCoins_Online_IncludeMempool(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode:     configuration.Online,
+		Currency: whive.MainnetCurrency,
+	}
+	mockClient := &mocks.Client{}
+	mockIndexer := &mocks.Indexer{}
+	servicer := NewAccountAPIService(cfg, mockClient, mockIndexer)
+	ctx := context.Background()
+
+	account := &types.AccountIdentifier{
+		Address: "hello",
+	}
+
+	spentCoin := &types.Coin{
+		Amount: &types.Amount{
+			Value: "10",
+		},
+		CoinIdentifier: &types.CoinIdentifier{
+			Identifier: "4852fe372ff7534c16713b3146bbc1e86379c70bea4d5c02fb1fa0112980a081:0",
+		},
+	}
+	confirmedCoin := &types.Coin{
+		Amount: &types.Amount{
+			Value: "20",
+		},
+		CoinIdentifier: &types.CoinIdentifier{
+			Identifier: "4852fe372ff7534c16713b3146bbc1e86379c70bea4d5c02fb1fa0112980a081:1",
+		},
+	}
+	mempoolCoin := &types.Coin{
+		Amount: &types.Amount{
+			Value: "5",
+		},
+		CoinIdentifier: &types.CoinIdentifier{
+			Identifier: "37b4fcc8e0b229412faeab8baad45d3eb8e4eec41840d6ac2103987163459e75:1",
+		},
+	}
+	coins := []*types.Coin{spentCoin, confirmedCoin}
+	mempoolCoins := []*types.Coin{confirmedCoin, mempoolCoin}
+	block := &types.BlockIdentifier{
+		Index: 1000,
+		Hash:  "block 1000",
+	}
+	mockIndexer.On("GetCoins", ctx, account).Return(coins, block, nil).Once()
+	mockClient.On("MempoolCoins", ctx, account, coins).Return(mempoolCoins, nil).Once()
+	mockIndexer.On("GetCoinBlocks", ctx, mempoolCoins).Return(map[string]*types.BlockIdentifier{
+		"4852fe372ff7534c16713b3146bbc1e86379c70bea4d5c02fb1fa0112980a081:1": {
+			Index: 990,
+			Hash:  "block 990",
+		},
+	}, nil).Once()
+
+	resp, err := servicer.AccountCoins(ctx, &types.AccountCoinsRequest{
+		AccountIdentifier: account,
+		IncludeMempool:    true,
+	})
+	assert.Nil(t, err)
+
+	assert.Equal(t, &types.AccountCoinsResponse{
+		BlockIdentifier: block,
+		Coins:           mempoolCoins,
+		Metadata: map[string]interface{}{
+			"coins": map[string]*coinMetadata{
+				"4852fe372ff7534c16713b3146bbc1e86379c70bea4d5c02fb1fa0112980a081:1": {
+					CreatedHeight: types.Int64(990),
+					Confirmations: 11,
+					TransactionIdentifier: &types.TransactionIdentifier{
+						Hash: "4852fe372ff7534c16713b3146bbc1e86379c70bea4d5c02fb1fa0112980a081",
+					},
+					OutputIndex: types.Int64(1),
+				},
+				"37b4fcc8e0b229412faeab8baad45d3eb8e4eec41840d6ac2103987163459e75:1": {
+					Confirmations: 0,
+					TransactionIdentifier: &types.TransactionIdentifier{
+						Hash: "37b4fcc8e0b229412faeab8baad45d3eb8e4eec41840d6ac2103987163459e75",
+					},
+					OutputIndex: types.Int64(1),
+				},
+			},
+		},
+	}, resp)
+
+	// whived errors are surfaced.
+	mockIndexer.On("GetCoins", ctx, account).Return(coins, block, nil).Once()
+	mockClient.On(
+		"MempoolCoins",
+		ctx,
+		account,
+		coins,
+	).Return(
+		nil,
+		errors.New("unable to get mempool"),
+	).Once()
+	resp, err = servicer.AccountCoins(ctx, &types.AccountCoinsRequest{
+		AccountIdentifier: account,
+		IncludeMempool:    true,
+	})
+	assert.Nil(t, resp)
+	assert.Equal(t, ErrWhived.Code, err.Code)
+
+	mockClient.AssertExpectations(t)
+	mockIndexer.AssertExpectations(t)
+}
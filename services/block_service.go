@@ -16,11 +16,14 @@ package services
 
 import (
 	"context"
+	"errors"
 
 	"github.com/xyephy/rosetta-whive/configuration"
+	"github.com/xyephy/rosetta-whive/whive"
 
 	"github.com/coinbase/rosetta-sdk-go/server"
 	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
 )
 
 // BlockAPIService implements the server.BlockAPIServicer interface.
@@ -51,17 +54,28 @@ func (s *BlockAPIService) Block(
 
 	blockResponse, err := s.i.GetBlockLazy(ctx, request.BlockIdentifier)
 	if err != nil {
+		var pruned *whive.PrunedBlockError
+		if errors.As(err, &pruned) {
+			rErr := wrapErr(ErrBlockPruned, err)
+			rErr.Details["min_available_height"] = pruned.MinAvailableHeight
+
+			return nil, rErr
+		}
+
 		return nil, wrapErr(ErrBlockNotFound, err)
 	}
 
-	// Direct client to fetch transactions individually if
-	// more than inlineFetchLimit.
-	if len(blockResponse.OtherTransactions) > inlineFetchLimit {
-		return blockResponse, nil
+	// Fetch the first transactions inline and direct
+	// client to fetch the rest individually (in order).
+	inline := blockResponse.OtherTransactions
+	var remaining []*types.TransactionIdentifier
+	if limit := s.inlineTransactions(); len(inline) > limit {
+		remaining = inline[limit:]
+		inline = inline[:limit]
 	}
 
-	txs := make([]*types.Transaction, len(blockResponse.OtherTransactions))
-	for i, otherTx := range blockResponse.OtherTransactions {
+	txs := make([]*types.Transaction, len(inline))
+	for i, otherTx := range inline {
 		transaction, err := s.i.GetBlockTransaction(
 			ctx,
 			blockResponse.Block.BlockIdentifier,
@@ -71,14 +85,24 @@ func (s *BlockAPIService) Block(
 			return nil, wrapErr(ErrTransactionNotFound, err)
 		}
 
-		txs[i] = transaction
+		txs[i] = s.filterOperations(transaction)
 	}
 	blockResponse.Block.Transactions = txs
 
-	blockResponse.OtherTransactions = nil
+	blockResponse.OtherTransactions = remaining
 	return blockResponse, nil
 }
 
+// inlineTransactions returns the maximum number
+// of transactions to return inline from /block.
+func (s *BlockAPIService) inlineTransactions() int {
+	if s.config.BlockInlineTransactions > 0 {
+		return s.config.BlockInlineTransactions
+	}
+
+	return inlineFetchLimit
+}
+
 // BlockTransaction implements the /block/transaction endpoint.
 func (s *BlockAPIService) BlockTransaction(
 	ctx context.Context,
@@ -98,6 +122,30 @@ func (s *BlockAPIService) BlockTransaction(
 	}
 
 	return &types.BlockTransactionResponse{
-		Transaction: transaction,
+		Transaction: s.filterOperations(transaction),
 	}, nil
 }
+
+// filterOperations returns a copy of transaction with only
+// the operations with a type in BlockOperationTypes. Operation
+// identifiers are not changed, so they remain consistent with
+// the full transaction.
+func (s *BlockAPIService) filterOperations(
+	transaction *types.Transaction,
+) *types.Transaction {
+	if len(s.config.BlockOperationTypes) == 0 {
+		return transaction
+	}
+
+	operations := []*types.Operation{}
+	for _, operation := range transaction.Operations {
+		if utils.ContainsString(s.config.BlockOperationTypes, operation.Type) {
+			operations = append(operations, operation)
+		}
+	}
+
+	filtered := *transaction
+	filtered.Operations = operations
+
+	return &filtered
+}
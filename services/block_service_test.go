@@ -16,11 +16,13 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"testing"
 
 	"github.com/xyephy/rosetta-whive/configuration"
 	mocks "github.com/xyephy/rosetta-whive/mocks/services"
+	"github.com/xyephy/rosetta-whive/whive"
 
 	"github.com/coinbase/rosetta-sdk-go/types"
 	"github.com/stretchr/testify/assert"
@@ -149,6 +151,108 @@ func TestBlockService_Online_Inline(t *testing.T) {
 }
 
 func TestBlockService_Online_External(t *testing.T) {
+	tests := map[string]struct {
+		inlineTransactions int
+
+		expectedInline int
+	}{
+		"default": {
+			expectedInline: inlineFetchLimit,
+		},
+		"configured": {
+			inlineTransactions: 150,
+			expectedInline:     150,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := &configuration.Configuration{
+				Mode:                    configuration.Online,
+				BlockInlineTransactions: test.inlineTransactions,
+			}
+			mockIndexer := &mocks.Indexer{}
+			servicer := NewBlockAPIService(cfg, mockIndexer)
+			ctx := context.Background()
+
+			blockIdentifier := &types.BlockIdentifier{
+				Index: 100,
+				Hash:  "block 100",
+			}
+			otherTxs := []*types.TransactionIdentifier{}
+			txs := []*types.Transaction{}
+			for i := 0; i < 200; i++ {
+				identifier := &types.TransactionIdentifier{
+					Hash: fmt.Sprintf("tx%d", i),
+				}
+				otherTxs = append(otherTxs, identifier)
+				txs = append(txs, &types.Transaction{
+					TransactionIdentifier: identifier,
+					Operations: []*types.Operation{
+						{OperationIdentifier: &types.OperationIdentifier{Index: 0}},
+						{OperationIdentifier: &types.OperationIdentifier{Index: 1}},
+					},
+				})
+			}
+
+			mockIndexer.On(
+				"GetBlockLazy",
+				ctx,
+				(*types.PartialBlockIdentifier)(nil),
+			).Return(
+				&types.BlockResponse{
+					Block:             &types.Block{BlockIdentifier: blockIdentifier},
+					OtherTransactions: otherTxs,
+				},
+				nil,
+			).Once()
+			for i := 0; i < test.expectedInline; i++ {
+				mockIndexer.On(
+					"GetBlockTransaction",
+					ctx,
+					blockIdentifier,
+					otherTxs[i],
+				).Return(
+					txs[i],
+					nil,
+				).Once()
+			}
+
+			// The first transactions are returned inline (in
+			// order) and the rest are fetched individually.
+			b, err := servicer.Block(ctx, &types.BlockRequest{})
+			assert.Nil(t, err)
+			assert.Equal(t, txs[:test.expectedInline], b.Block.Transactions)
+			assert.Equal(t, otherTxs[test.expectedInline:], b.OtherTransactions)
+
+			for i, otherTx := range b.OtherTransactions {
+				tx := txs[test.expectedInline+i]
+				mockIndexer.On(
+					"GetBlockTransaction",
+					ctx,
+					blockIdentifier,
+					otherTx,
+				).Return(
+					tx,
+					nil,
+				).Once()
+
+				bTx, err := servicer.BlockTransaction(ctx, &types.BlockTransactionRequest{
+					BlockIdentifier:       blockIdentifier,
+					TransactionIdentifier: otherTx,
+				})
+				assert.Nil(t, err)
+				assert.Equal(t, &types.BlockTransactionResponse{
+					Transaction: tx,
+				}, bTx)
+			}
+
+			mockIndexer.AssertExpectations(t)
+		})
+	}
+}
+
+func TestBlockService_Online_Pruned(t *testing.T) {
 	cfg := &configuration.Configuration{
 		Mode: configuration.Online,
 	}
@@ -156,58 +260,122 @@ func TestBlockService_Online_External(t *testing.T) {
 	servicer := NewBlockAPIService(cfg, mockIndexer)
 	ctx := context.Background()
 
-	blockResponse := &types.BlockResponse{
-		Block: &types.Block{
-			BlockIdentifier: &types.BlockIdentifier{
-				Index: 100,
-				Hash:  "block 100",
-			},
+	prunedErr := &whive.PrunedBlockError{
+		Hash:               "block 100",
+		MinAvailableHeight: 500,
+	}
+	mockIndexer.On(
+		"GetBlockLazy",
+		ctx,
+		(*types.PartialBlockIdentifier)(nil),
+	).Return(
+		nil,
+		fmt.Errorf("%w: unable to get raw block", prunedErr),
+	).Once()
+	b, err := servicer.Block(ctx, &types.BlockRequest{})
+	assert.Nil(t, b)
+	assert.Equal(t, ErrBlockPruned.Code, err.Code)
+	assert.Equal(t, ErrBlockPruned.Message, err.Message)
+	assert.Equal(t, int64(500), err.Details["min_available_height"])
+
+	mockIndexer.On(
+		"GetBlockLazy",
+		ctx,
+		(*types.PartialBlockIdentifier)(nil),
+	).Return(
+		nil,
+		errors.New("block not found"),
+	).Once()
+	b, err = servicer.Block(ctx, &types.BlockRequest{})
+	assert.Nil(t, b)
+	assert.Equal(t, ErrBlockNotFound.Code, err.Code)
+
+	mockIndexer.AssertExpectations(t)
+}
+
+func TestBlockService_Online_OperationTypes(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode:                configuration.Online,
+		BlockOperationTypes: []string{whive.OutputOpType},
+	}
+	mockIndexer := &mocks.Indexer{}
+	servicer := NewBlockAPIService(cfg, mockIndexer)
+	ctx := context.Background()
+
+	blockIdentifier := &types.BlockIdentifier{
+		Index: 100,
+		Hash:  "block 100",
+	}
+	input := &types.Operation{
+		OperationIdentifier: &types.OperationIdentifier{
+			Index: 0,
 		},
+		Type: whive.InputOpType,
+	}
+	output := &types.Operation{
+		OperationIdentifier: &types.OperationIdentifier{
+			Index: 1,
+		},
+		Type: whive.OutputOpType,
+	}
+	transaction := &types.Transaction{
+		TransactionIdentifier: &types.TransactionIdentifier{
+			Hash: "tx1",
+		},
+		Operations: []*types.Operation{input, output},
 	}
 
-	otherTxs := []*types.TransactionIdentifier{}
-	for i := 0; i < 200; i++ {
-		otherTxs = append(otherTxs, &types.TransactionIdentifier{
-			Hash: fmt.Sprintf("tx%d", i),
-		})
+	// Operation indices are preserved
+	filteredTransaction := &types.Transaction{
+		TransactionIdentifier: transaction.TransactionIdentifier,
+		Operations:            []*types.Operation{output},
 	}
-	blockResponse.OtherTransactions = otherTxs
 
 	mockIndexer.On(
 		"GetBlockLazy",
 		ctx,
 		(*types.PartialBlockIdentifier)(nil),
 	).Return(
-		blockResponse,
+		&types.BlockResponse{
+			Block: &types.Block{
+				BlockIdentifier: blockIdentifier,
+			},
+			OtherTransactions: []*types.TransactionIdentifier{
+				transaction.TransactionIdentifier,
+			},
+		},
 		nil,
 	).Once()
+	mockIndexer.On(
+		"GetBlockTransaction",
+		ctx,
+		blockIdentifier,
+		transaction.TransactionIdentifier,
+	).Return(
+		transaction,
+		nil,
+	).Twice()
+
 	b, err := servicer.Block(ctx, &types.BlockRequest{})
 	assert.Nil(t, err)
-	assert.Equal(t, blockResponse, b)
+	assert.Equal(t, &types.BlockResponse{
+		Block: &types.Block{
+			BlockIdentifier: blockIdentifier,
+			Transactions:    []*types.Transaction{filteredTransaction},
+		},
+	}, b)
 
-	for _, otherTx := range b.OtherTransactions {
-		tx := &types.Transaction{
-			TransactionIdentifier: otherTx,
-		}
-		mockIndexer.On(
-			"GetBlockTransaction",
-			ctx,
-			blockResponse.Block.BlockIdentifier,
-			otherTx,
-		).Return(
-			tx,
-			nil,
-		).Once()
+	bTx, err := servicer.BlockTransaction(ctx, &types.BlockTransactionRequest{
+		BlockIdentifier:       blockIdentifier,
+		TransactionIdentifier: transaction.TransactionIdentifier,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, &types.BlockTransactionResponse{
+		Transaction: filteredTransaction,
+	}, bTx)
 
-		bTx, err := servicer.BlockTransaction(ctx, &types.BlockTransactionRequest{
-			BlockIdentifier:       blockResponse.Block.BlockIdentifier,
-			TransactionIdentifier: otherTx,
-		})
-		assert.Nil(t, err)
-		assert.Equal(t, &types.BlockTransactionResponse{
-			Transaction: tx,
-		}, bTx)
-	}
+	// The stored transaction is not modified
+	assert.Len(t, transaction.Operations, 2)
 
 	mockIndexer.AssertExpectations(t)
 }
@@ -0,0 +1,754 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/xyephy/rosetta-whive/configuration"
+	"github.com/xyephy/rosetta-whive/metrics"
+	"github.com/xyephy/rosetta-whive/whive"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/coinbase/rosetta-sdk-go/server"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+)
+
+const (
+	// CallMethodTransactionBlock returns the *types.BlockIdentifier
+	// of the block that confirmed a transaction.
+	CallMethodTransactionBlock = "transaction_block"
+
+	// CallMethodStorageSize returns the number of bytes
+	// used by the indexer storage directory.
+	CallMethodStorageSize = "storage_size"
+
+	// CallMethodScriptBalance returns the balance of all
+	// unspent coins locked by a scriptPubKey.
+	CallMethodScriptBalance = "script_balance"
+
+	// CallMethodDifficultyHistory returns the block header
+	// (including difficulty) at each retarget point in
+	// a range of heights.
+	CallMethodDifficultyHistory = "difficulty_history"
+
+	// CallMethodTransactionFinality returns the number of
+	// confirmations of a transaction and whether it has
+	// reached FINALITY_DEPTH.
+	CallMethodTransactionFinality = "transaction_finality"
+
+	// CallMethodRPCLatency returns the number of calls
+	// and the p50/p95/p99 round-trip latency (in seconds)
+	// of each JSON-RPC method called on whived.
+	CallMethodRPCLatency = "rpc_latency"
+
+	// CallMethodFeeRateConfirmation returns the estimated
+	// number of blocks (and seconds) until a transaction
+	// paying a fee rate (in satoshis per vbyte) confirms.
+	CallMethodFeeRateConfirmation = "fee_rate_confirmation"
+
+	// CallMethodCheckpoint returns the latest checkpoint
+	// of the network. Blocks at or below the checkpoint
+	// are trusted rather than fully verified by whived.
+	CallMethodCheckpoint = "checkpoint"
+
+	// CallMethodFirstSeen returns the first block in
+	// which an account received a coin.
+	CallMethodFirstSeen = "first_seen"
+
+	// CallMethodMedianFeeRate returns the median fee rate
+	// (in satoshis per vbyte) paid by the transactions in
+	// the most recent blocks.
+	CallMethodMedianFeeRate = "median_fee_rate"
+
+	// defaultMedianFeeRateBlocks is the number of blocks
+	// CallMethodMedianFeeRate considers if none is provided.
+	defaultMedianFeeRateBlocks = 6
+
+	// maxMedianFeeRateBlocks is the maximum number of
+	// blocks CallMethodMedianFeeRate considers.
+	maxMedianFeeRateBlocks = 144
+
+	// maxDifficultyHistoryHeaders is the maximum number of
+	// block headers fetched by CallMethodDifficultyHistory.
+	maxDifficultyHistoryHeaders = 100
+
+	// Statuses returned by CallMethodTransactionBlock.
+	transactionConfirmed   = "confirmed"
+	transactionUnconfirmed = "unconfirmed"
+	transactionUnknown     = "unknown"
+
+	// transactionOrphaned is returned by CallMethodTransactionFinality
+	// when the block that whived reports as confirming a transaction
+	// is not part of the indexed chain.
+	transactionOrphaned = "orphaned"
+)
+
+// confirmationTargets are the confirmation targets (in blocks)
+// we request fee estimates for in CallMethodFeeRateConfirmation.
+// whived does not estimate fees for targets over 1008 blocks.
+var confirmationTargets = []int64{1, 2, 3, 4, 6, 12, 24, 48, 144, 504, 1008}
+
+// CallMethods are all methods supported by /call.
+var CallMethods = []string{
+	CallMethodTransactionBlock,
+	CallMethodStorageSize,
+	CallMethodScriptBalance,
+	CallMethodDifficultyHistory,
+	CallMethodTransactionFinality,
+	CallMethodRPCLatency,
+	CallMethodFeeRateConfirmation,
+	CallMethodCheckpoint,
+	CallMethodFirstSeen,
+	CallMethodMedianFeeRate,
+}
+
+// txIndexCallMethods are the CallMethods that are
+// unavailable when whived is running without -txindex.
+var txIndexCallMethods = []string{
+	CallMethodTransactionBlock,
+	CallMethodTransactionFinality,
+}
+
+// availableCallMethods returns the CallMethods that are
+// supported given whether whived is running with -txindex.
+func availableCallMethods(txIndexEnabled bool) []string {
+	if txIndexEnabled {
+		return CallMethods
+	}
+
+	methods := []string{}
+	for _, method := range CallMethods {
+		if !utils.ContainsString(txIndexCallMethods, method) {
+			methods = append(methods, method)
+		}
+	}
+
+	return methods
+}
+
+// CallAPIService implements the server.CallAPIServicer interface.
+type CallAPIService struct {
+	config   *configuration.Configuration
+	client   Client
+	i        Indexer
+	feeRates *blockFeeRates
+}
+
+// NewCallAPIService creates a new instance of a CallAPIService.
+func NewCallAPIService(
+	config *configuration.Configuration,
+	client Client,
+	i Indexer,
+) server.CallAPIServicer {
+	return &CallAPIService{
+		config:   config,
+		client:   client,
+		i:        i,
+		feeRates: newBlockFeeRates(),
+	}
+}
+
+// Call implements the /call endpoint.
+func (s *CallAPIService) Call(
+	ctx context.Context,
+	request *types.CallRequest,
+) (*types.CallResponse, *types.Error) {
+	if s.config.Mode != configuration.Online {
+		return nil, wrapErr(ErrUnavailableOffline, nil)
+	}
+
+	switch request.Method {
+	case CallMethodTransactionBlock:
+		return s.transactionBlock(ctx, request.Parameters)
+	case CallMethodStorageSize:
+		return s.storageSize()
+	case CallMethodScriptBalance:
+		return s.scriptBalance(ctx, request.Parameters)
+	case CallMethodDifficultyHistory:
+		return s.difficultyHistory(ctx, request.Parameters)
+	case CallMethodTransactionFinality:
+		return s.transactionFinality(ctx, request.Parameters)
+	case CallMethodRPCLatency:
+		return s.rpcLatency()
+	case CallMethodFeeRateConfirmation:
+		return s.feeRateConfirmation(ctx, request.Parameters)
+	case CallMethodCheckpoint:
+		return s.checkpoint()
+	case CallMethodFirstSeen:
+		return s.firstSeen(ctx, request.Parameters)
+	case CallMethodMedianFeeRate:
+		return s.medianFeeRate(ctx, request.Parameters)
+	default:
+		return nil, wrapErr(ErrCallMethodInvalid, fmt.Errorf("method %s is not supported", request.Method))
+	}
+}
+
+// transactionBlock looks up the block that confirmed
+// a transaction. The response is not idempotent because
+// the confirming block may change during a reorg.
+func (s *CallAPIService) transactionBlock(
+	ctx context.Context,
+	parameters map[string]interface{},
+) (*types.CallResponse, *types.Error) {
+	result, rErr := s.lookupTransactionBlock(ctx, parameters)
+	if rErr != nil {
+		return nil, rErr
+	}
+
+	resultMap, err := types.MarshalMap(result)
+	if err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+
+	return &types.CallResponse{
+		Result:     resultMap,
+		Idempotent: false,
+	}, nil
+}
+
+// lookupTransactionBlock parses a transactionBlockParameters
+// and asks whived for the block that confirmed the transaction.
+func (s *CallAPIService) lookupTransactionBlock(
+	ctx context.Context,
+	parameters map[string]interface{},
+) (*transactionBlockResult, *types.Error) {
+	var params transactionBlockParameters
+	if err := types.UnmarshalMap(parameters, &params); err != nil {
+		return nil, wrapErr(ErrCallParametersInvalid, err)
+	}
+
+	if params.TransactionIdentifier == nil || len(params.TransactionIdentifier.Hash) == 0 {
+		return nil, wrapErr(ErrCallParametersInvalid, errors.New("transaction_identifier is missing"))
+	}
+
+	if !s.i.TxIndexEnabled() {
+		return nil, wrapErr(ErrTxIndexDisabled, nil)
+	}
+
+	result := &transactionBlockResult{}
+	blockIdentifier, err := s.client.TransactionBlock(ctx, params.TransactionIdentifier.Hash)
+	switch {
+	case errors.Is(err, whive.ErrTxIndexDisabled):
+		return nil, wrapErr(ErrTxIndexDisabled, err)
+	case errors.Is(err, whive.ErrTransactionNotFound):
+		result.Status = transactionUnknown
+	case err != nil:
+		return nil, wrapErr(ErrWhived, err)
+	case blockIdentifier == nil:
+		result.Status = transactionUnconfirmed
+
+		// The transaction may have been confirmed (or evicted)
+		// since we looked it up.
+		signalsRBF, err := s.mempoolSignalsRBF(ctx, params.TransactionIdentifier.Hash)
+		switch {
+		case errors.Is(err, whive.ErrTransactionNotFound):
+		case err != nil:
+			return nil, wrapErr(ErrWhived, err)
+		default:
+			result.SignalsRBF = &signalsRBF
+		}
+	default:
+		result.Status = transactionConfirmed
+		result.BlockIdentifier = blockIdentifier
+	}
+
+	return result, nil
+}
+
+// mempoolSignalsRBF returns true if the unconfirmed
+// transaction with hash signals opt-in replaceability.
+func (s *CallAPIService) mempoolSignalsRBF(ctx context.Context, hash string) (bool, error) {
+	transaction, err := s.client.MempoolTransaction(ctx, hash)
+	if err != nil {
+		return false, err
+	}
+
+	var metadata whive.TransactionMetadata
+	if err := types.UnmarshalMap(transaction.Metadata, &metadata); err != nil {
+		return false, fmt.Errorf("%w: unable to parse transaction metadata", err)
+	}
+
+	return metadata.SignalsRBF, nil
+}
+
+// transactionFinality reports the number of confirmations
+// of a transaction and whether it has reached the configured
+// finality depth. Confirmations are counted against the
+// indexed chain (not whived's) so that the result is
+// consistent with /block.
+func (s *CallAPIService) transactionFinality(
+	ctx context.Context,
+	parameters map[string]interface{},
+) (*types.CallResponse, *types.Error) {
+	blockResult, rErr := s.lookupTransactionBlock(ctx, parameters)
+	if rErr != nil {
+		return nil, rErr
+	}
+
+	result := &transactionFinalityResult{
+		Status:          blockResult.Status,
+		BlockIdentifier: blockResult.BlockIdentifier,
+		SignalsRBF:      blockResult.SignalsRBF,
+		FinalityDepth:   s.config.FinalityDepth,
+	}
+
+	if result.Status == transactionConfirmed {
+		head, err := s.i.GetBlockLazy(ctx, nil)
+		if err != nil {
+			return nil, wrapErr(ErrNotReady, err)
+		}
+
+		// If the confirming block has not been indexed
+		// yet, the transaction has no confirmations.
+		if result.BlockIdentifier.Index <= head.Block.BlockIdentifier.Index {
+			block, err := s.i.GetBlockLazy(ctx, &types.PartialBlockIdentifier{
+				Index: &result.BlockIdentifier.Index,
+			})
+			if err != nil {
+				return nil, wrapErr(ErrBlockNotFound, err)
+			}
+
+			if block.Block.BlockIdentifier.Hash != result.BlockIdentifier.Hash {
+				result.Status = transactionOrphaned
+			} else {
+				result.Confirmations = head.Block.BlockIdentifier.Index -
+					result.BlockIdentifier.Index + 1
+			}
+		}
+	}
+	result.Final = result.Confirmations >= result.FinalityDepth
+
+	resultMap, err := types.MarshalMap(result)
+	if err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+
+	return &types.CallResponse{
+		Result:     resultMap,
+		Idempotent: false,
+	}, nil
+}
+
+// storageSize returns the number of bytes used by the
+// indexer storage directory. The size is computed
+// periodically, so it may lag behind recent writes.
+func (s *CallAPIService) storageSize() (*types.CallResponse, *types.Error) {
+	resultMap, err := types.MarshalMap(&storageSizeResult{
+		Bytes: s.i.StorageSize(),
+	})
+	if err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+
+	return &types.CallResponse{
+		Result:     resultMap,
+		Idempotent: false,
+	}, nil
+}
+
+// rpcLatency returns a summary of the round-trip latency
+// of JSON-RPC calls made to whived since startup (the same
+// quantiles are exported as Prometheus metrics).
+func (s *CallAPIService) rpcLatency() (*types.CallResponse, *types.Error) {
+	latencies, err := metrics.RPCLatencies()
+	if err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+
+	resultMap, err := types.MarshalMap(&rpcLatencyResult{
+		Methods: latencies,
+	})
+	if err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+
+	return &types.CallResponse{
+		Result:     resultMap,
+		Idempotent: false,
+	}, nil
+}
+
+// checkpoint returns the latest checkpoint in the
+// configured params. Networks without checkpoints
+// (like regtest) return an empty result.
+func (s *CallAPIService) checkpoint() (*types.CallResponse, *types.Error) {
+	result := &checkpointResult{}
+	for _, checkpoint := range s.config.Params.Checkpoints {
+		if result.BlockIdentifier != nil && int64(checkpoint.Height) <= result.BlockIdentifier.Index {
+			continue
+		}
+
+		result.BlockIdentifier = &types.BlockIdentifier{
+			Index: int64(checkpoint.Height),
+			Hash:  checkpoint.Hash.String(),
+		}
+	}
+
+	resultMap, err := types.MarshalMap(result)
+	if err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+
+	return &types.CallResponse{
+		Result:     resultMap,
+		Idempotent: true,
+	}, nil
+}
+
+// firstSeen returns the first block in which an account
+// received a coin. The response is not idempotent because
+// the block may be orphaned during a reorg.
+func (s *CallAPIService) firstSeen(
+	ctx context.Context,
+	parameters map[string]interface{},
+) (*types.CallResponse, *types.Error) {
+	var params firstSeenParameters
+	if err := types.UnmarshalMap(parameters, &params); err != nil {
+		return nil, wrapErr(ErrCallParametersInvalid, err)
+	}
+
+	if params.AccountIdentifier == nil || len(params.AccountIdentifier.Address) == 0 {
+		return nil, wrapErr(ErrCallParametersInvalid, errors.New("account_identifier is missing"))
+	}
+
+	block, trackedSince, err := s.i.GetFirstSeen(ctx, params.AccountIdentifier)
+	if err != nil {
+		return nil, wrapErr(ErrUnableToGetBalance, err)
+	}
+
+	resultMap, err := types.MarshalMap(&firstSeenResult{
+		BlockIdentifier:   block,
+		TrackedSinceIndex: trackedSince,
+	})
+	if err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+
+	return &types.CallResponse{
+		Result:     resultMap,
+		Idempotent: false,
+	}, nil
+}
+
+// scriptBalance returns the sum of all unspent coins locked
+// by a scriptPubKey. This is useful for nonstandard scripts,
+// which do not have a canonical address.
+func (s *CallAPIService) scriptBalance(
+	ctx context.Context,
+	parameters map[string]interface{},
+) (*types.CallResponse, *types.Error) {
+	var params scriptBalanceParameters
+	if err := types.UnmarshalMap(parameters, &params); err != nil {
+		return nil, wrapErr(ErrCallParametersInvalid, err)
+	}
+
+	script, err := hex.DecodeString(params.ScriptPubKey)
+	if err != nil || len(script) == 0 {
+		return nil, wrapErr(
+			ErrCallParametersInvalid,
+			fmt.Errorf("script_pub_key %s is not valid hex", params.ScriptPubKey),
+		)
+	}
+
+	account := scriptAccount(s.config.Params, script)
+	coins, block, err := s.i.GetCoins(ctx, account)
+	if err != nil {
+		return nil, wrapErr(ErrUnableToGetCoins, err)
+	}
+
+	total := "0"
+	for _, coin := range coins {
+		total, err = types.AddValues(total, coin.Amount.Value)
+		if err != nil {
+			return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+		}
+	}
+
+	resultMap, err := types.MarshalMap(&scriptBalanceResult{
+		AccountIdentifier: account,
+		Balance: &types.Amount{
+			Value:    total,
+			Currency: s.config.Currency,
+		},
+		BlockIdentifier: block,
+	})
+	if err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+
+	return &types.CallResponse{
+		Result:     resultMap,
+		Idempotent: false,
+	}, nil
+}
+
+// feeRateConfirmation finds the smallest confirmation target
+// whose fee estimate (from estimatesmartfee) is at most fee_rate.
+// If fee_rate is below the estimate of all targets, we return
+// the lowest estimate (the fee rate required to confirm within
+// the largest target) instead.
+func (s *CallAPIService) feeRateConfirmation(
+	ctx context.Context,
+	parameters map[string]interface{},
+) (*types.CallResponse, *types.Error) {
+	var params feeRateConfirmationParameters
+	if err := types.UnmarshalMap(parameters, &params); err != nil {
+		return nil, wrapErr(ErrCallParametersInvalid, err)
+	}
+
+	if params.FeeRate == nil || *params.FeeRate <= 0 {
+		return nil, wrapErr(ErrCallParametersInvalid, errors.New("fee_rate must be positive"))
+	}
+
+	var result *feeRateConfirmationResult
+	var lowestFeeRate float64
+	for _, target := range confirmationTargets {
+		feePerKB, err := s.client.SuggestedFeeRate(ctx, target)
+		if err != nil {
+			return nil, wrapErr(ErrCouldNotGetFeeRate, err)
+		}
+
+		// whived does not return an estimate for
+		// targets it doesn't have enough data for.
+		if feePerKB <= 0 {
+			continue
+		}
+
+		satoshisPerB := (feePerKB * float64(whive.SatoshisInBitcoin)) / bytesInKb
+		if satoshisPerB <= *params.FeeRate {
+			result = &feeRateConfirmationResult{
+				Confirmable:      true,
+				EstimatedBlocks:  target,
+				EstimatedSeconds: target * int64(s.config.Params.TargetTimePerBlock.Seconds()),
+			}
+			break
+		}
+
+		lowestFeeRate = satoshisPerB
+	}
+
+	if result == nil {
+		if lowestFeeRate == 0 {
+			return nil, wrapErr(ErrCouldNotGetFeeRate, errors.New("no fee estimates are available"))
+		}
+
+		result = &feeRateConfirmationResult{
+			MinimumFeeRate: lowestFeeRate,
+		}
+	}
+
+	resultMap, err := types.MarshalMap(result)
+	if err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+
+	return &types.CallResponse{
+		Result:     resultMap,
+		Idempotent: false,
+	}, nil
+}
+
+// medianFeeRate returns the median fee rate (in satoshis per
+// vbyte) of the transactions (excluding coinbase transactions)
+// in the most recent blocks indexed. This is observed from
+// confirmed transactions, so it is independent of whived's
+// fee estimator.
+func (s *CallAPIService) medianFeeRate(
+	ctx context.Context,
+	parameters map[string]interface{},
+) (*types.CallResponse, *types.Error) {
+	var params medianFeeRateParameters
+	if err := types.UnmarshalMap(parameters, &params); err != nil {
+		return nil, wrapErr(ErrCallParametersInvalid, err)
+	}
+
+	blocks := int64(defaultMedianFeeRateBlocks)
+	if params.Blocks != nil {
+		blocks = *params.Blocks
+	}
+
+	if blocks < 1 || blocks > maxMedianFeeRateBlocks {
+		return nil, wrapErr(
+			ErrCallParametersInvalid,
+			fmt.Errorf("blocks %d must be between 1 and %d", blocks, maxMedianFeeRateBlocks),
+		)
+	}
+
+	head, err := s.i.GetBlockLazy(ctx, nil)
+	if err != nil {
+		return nil, wrapErr(ErrNotReady, err)
+	}
+
+	headIdentifier := head.Block.BlockIdentifier
+	rates := []float64{}
+	for index := headIdentifier.Index; index > headIdentifier.Index-blocks && index >= 0; index-- {
+		blockRates, rErr := s.blockFeeRates(ctx, index)
+		if rErr != nil {
+			return nil, rErr
+		}
+
+		rates = append(rates, blockRates...)
+	}
+
+	if len(rates) == 0 {
+		return nil, wrapErr(
+			ErrCouldNotGetFeeRate,
+			fmt.Errorf("no transactions in the last %d blocks", blocks),
+		)
+	}
+
+	resultMap, err := types.MarshalMap(&medianFeeRateResult{
+		MedianFeeRate:   median(rates),
+		Blocks:          blocks,
+		Transactions:    len(rates),
+		BlockIdentifier: headIdentifier,
+	})
+	if err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+
+	return &types.CallResponse{
+		Result:     resultMap,
+		Idempotent: false,
+	}, nil
+}
+
+// blockFeeRates returns the fee rates of the transactions
+// in the indexed block at index (from the cache if the
+// block has been seen before).
+func (s *CallAPIService) blockFeeRates(
+	ctx context.Context,
+	index int64,
+) ([]float64, *types.Error) {
+	block, err := s.i.GetBlockLazy(ctx, &types.PartialBlockIdentifier{Index: &index})
+	if err != nil {
+		return nil, wrapErr(ErrBlockNotFound, err)
+	}
+
+	blockIdentifier := block.Block.BlockIdentifier
+	if rates, ok := s.feeRates.get(blockIdentifier.Hash); ok {
+		return rates, nil
+	}
+
+	txs := block.Block.Transactions
+	for _, otherTx := range block.OtherTransactions {
+		tx, err := s.i.GetBlockTransaction(ctx, blockIdentifier, otherTx)
+		if err != nil {
+			return nil, wrapErr(ErrTransactionNotFound, err)
+		}
+
+		txs = append(txs, tx)
+	}
+
+	rates := []float64{}
+	for _, tx := range txs {
+		rate, ok, err := transactionFeeRate(tx)
+		if err != nil {
+			return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+		}
+
+		if ok {
+			rates = append(rates, rate)
+		}
+	}
+	s.feeRates.set(blockIdentifier.Hash, rates)
+
+	return rates, nil
+}
+
+// difficultyHistory returns the block header at each retarget
+// point (a multiple of the retarget interval) between start_index
+// and end_index (inclusive). The difficulty of each header is
+// the difficulty of all blocks until the next retarget point.
+func (s *CallAPIService) difficultyHistory(
+	ctx context.Context,
+	parameters map[string]interface{},
+) (*types.CallResponse, *types.Error) {
+	var params difficultyHistoryParameters
+	if err := types.UnmarshalMap(parameters, &params); err != nil {
+		return nil, wrapErr(ErrCallParametersInvalid, err)
+	}
+
+	if params.StartIndex == nil || params.EndIndex == nil {
+		return nil, wrapErr(ErrCallParametersInvalid, errors.New("start_index and end_index are required"))
+	}
+
+	startIndex, endIndex := *params.StartIndex, *params.EndIndex
+	if startIndex < 0 || endIndex < startIndex {
+		return nil, wrapErr(
+			ErrCallParametersInvalid,
+			fmt.Errorf("range %d-%d is not valid", startIndex, endIndex),
+		)
+	}
+
+	interval := int64(s.config.Params.TargetTimespan / s.config.Params.TargetTimePerBlock)
+	firstIndex := (startIndex + interval - 1) / interval * interval
+	if firstIndex <= endIndex && (endIndex-firstIndex)/interval >= maxDifficultyHistoryHeaders {
+		return nil, wrapErr(
+			ErrCallParametersInvalid,
+			fmt.Errorf(
+				"range %d-%d includes more than %d retarget points",
+				startIndex,
+				endIndex,
+				maxDifficultyHistoryHeaders,
+			),
+		)
+	}
+
+	headers := []*whive.BlockHeader{}
+	for index := firstIndex; index <= endIndex; index += interval {
+		header, err := s.client.BlockHeader(ctx, index)
+		if err != nil {
+			return nil, wrapErr(ErrWhived, err)
+		}
+
+		headers = append(headers, header)
+	}
+
+	resultMap, err := types.MarshalMap(&difficultyHistoryResult{
+		RetargetInterval: interval,
+		Headers:          headers,
+	})
+	if err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+
+	// Headers may change during a reorg.
+	return &types.CallResponse{
+		Result:     resultMap,
+		Idempotent: false,
+	}, nil
+}
+
+// scriptAccount returns the *types.AccountIdentifier the block
+// parser assigns to outputs locked by script. Scripts that do not
+// resolve to exactly one address are keyed by their hex encoding.
+func scriptAccount(
+	params *chaincfg.Params,
+	script []byte,
+) *types.AccountIdentifier {
+	_, addresses, _, err := txscript.ExtractPkScriptAddrs(script, params)
+	if err != nil || len(addresses) != 1 {
+		return &types.AccountIdentifier{Address: hex.EncodeToString(script)}
+	}
+
+	return &types.AccountIdentifier{Address: addresses[0].EncodeAddress()}
+}
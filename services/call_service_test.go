@@ -0,0 +1,954 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/xyephy/rosetta-whive/configuration"
+	"github.com/xyephy/rosetta-whive/metrics"
+	mocks "github.com/xyephy/rosetta-whive/mocks/services"
+	"github.com/xyephy/rosetta-whive/whive"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCallEndpoints_Offline(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode: configuration.Offline,
+	}
+	mockClient := &mocks.Client{}
+	mockIndexer := &mocks.Indexer{}
+	servicer := NewCallAPIService(cfg, mockClient, mockIndexer)
+	ctx := context.Background()
+
+	resp, err := servicer.Call(ctx, &types.CallRequest{
+		Method: CallMethodTransactionBlock,
+	})
+	assert.Nil(t, resp)
+	assert.Equal(t, ErrUnavailableOffline.Code, err.Code)
+	assert.Equal(t, ErrUnavailableOffline.Message, err.Message)
+	mockClient.AssertExpectations(t)
+}
+
+func TestCallEndpoints_TransactionBlock(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode: configuration.Online,
+	}
+
+	mockClient := &mocks.Client{}
+	mockIndexer := &mocks.Indexer{}
+	servicer := NewCallAPIService(cfg, mockClient, mockIndexer)
+	ctx := context.Background()
+
+	parameters := func(hash string) map[string]interface{} {
+		return map[string]interface{}{
+			"transaction_identifier": map[string]interface{}{
+				"hash": hash,
+			},
+		}
+	}
+
+	mockIndexer.On("TxIndexEnabled").Return(true).Times(4)
+
+	// Confirmed
+	mockClient.On("TransactionBlock", ctx, "tx1").Return(&types.BlockIdentifier{
+		Hash:  "block 100",
+		Index: 100,
+	}, nil).Once()
+	resp, err := servicer.Call(ctx, &types.CallRequest{
+		Method:     CallMethodTransactionBlock,
+		Parameters: parameters("tx1"),
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, &types.CallResponse{
+		Result: map[string]interface{}{
+			"status": "confirmed",
+			"block_identifier": map[string]interface{}{
+				"hash":  "block 100",
+				"index": int64(100),
+			},
+		},
+		Idempotent: false,
+	}, resp)
+
+	// Unconfirmed
+	mockClient.On("TransactionBlock", ctx, "tx2").Return(nil, nil).Once()
+	mockClient.On("MempoolTransaction", ctx, "tx2").Return(&types.Transaction{
+		TransactionIdentifier: &types.TransactionIdentifier{Hash: "tx2"},
+		Metadata: map[string]interface{}{
+			"signals_rbf": true,
+		},
+	}, nil).Once()
+	resp, err = servicer.Call(ctx, &types.CallRequest{
+		Method:     CallMethodTransactionBlock,
+		Parameters: parameters("tx2"),
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, &types.CallResponse{
+		Result: map[string]interface{}{
+			"status":      "unconfirmed",
+			"signals_rbf": types.Bool(true),
+		},
+		Idempotent: false,
+	}, resp)
+
+	// Unknown
+	mockClient.On("TransactionBlock", ctx, "tx3").Return(
+		nil,
+		fmt.Errorf("%w: error getting raw transaction tx3", whive.ErrTransactionNotFound),
+	).Once()
+	resp, err = servicer.Call(ctx, &types.CallRequest{
+		Method:     CallMethodTransactionBlock,
+		Parameters: parameters("tx3"),
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, &types.CallResponse{
+		Result: map[string]interface{}{
+			"status": "unknown",
+		},
+		Idempotent: false,
+	}, resp)
+
+	// Transaction index disabled
+	mockClient.On("TransactionBlock", ctx, "tx4").Return(
+		nil,
+		fmt.Errorf("%w: error getting raw transaction tx4", whive.ErrTxIndexDisabled),
+	).Once()
+	resp, err = servicer.Call(ctx, &types.CallRequest{
+		Method:     CallMethodTransactionBlock,
+		Parameters: parameters("tx4"),
+	})
+	assert.Nil(t, resp)
+	assert.Equal(t, ErrTxIndexDisabled.Code, err.Code)
+
+	// Transaction index disabled at startup (whived is not queried)
+	mockIndexer.On("TxIndexEnabled").Return(false).Once()
+	resp, err = servicer.Call(ctx, &types.CallRequest{
+		Method:     CallMethodTransactionBlock,
+		Parameters: parameters("tx5"),
+	})
+	assert.Nil(t, resp)
+	assert.Equal(t, ErrTxIndexDisabled.Code, err.Code)
+
+	// Missing transaction identifier
+	resp, err = servicer.Call(ctx, &types.CallRequest{
+		Method: CallMethodTransactionBlock,
+	})
+	assert.Nil(t, resp)
+	assert.Equal(t, ErrCallParametersInvalid.Code, err.Code)
+
+	// Unsupported method
+	resp, err = servicer.Call(ctx, &types.CallRequest{
+		Method: "unsupported",
+	})
+	assert.Nil(t, resp)
+	assert.Equal(t, ErrCallMethodInvalid.Code, err.Code)
+
+	mockClient.AssertExpectations(t)
+	mockIndexer.AssertExpectations(t)
+}
+
+func TestCallEndpoints_TransactionFinality(t *testing.T) {
+	ctx := context.Background()
+	parameters := map[string]interface{}{
+		"transaction_identifier": map[string]interface{}{
+			"hash": "tx1",
+		},
+	}
+	confirmingBlock := &types.BlockIdentifier{
+		Hash:  "block 100",
+		Index: 100,
+	}
+	blockResponse := func(hash string, index int64) *types.BlockResponse {
+		return &types.BlockResponse{
+			Block: &types.Block{
+				BlockIdentifier: &types.BlockIdentifier{
+					Hash:  hash,
+					Index: index,
+				},
+			},
+		}
+	}
+
+	tests := map[string]struct {
+		depth           int64
+		confirmingBlock *types.BlockIdentifier
+		head            *types.BlockResponse
+		indexedBlock    *types.BlockResponse
+
+		expectedStatus        string
+		expectedConfirmations int64
+		expectedFinal         bool
+	}{
+		"final (depth 1)": {
+			depth:                 1,
+			confirmingBlock:       confirmingBlock,
+			head:                  blockResponse("block 105", 105),
+			indexedBlock:          blockResponse("block 100", 100),
+			expectedStatus:        "confirmed",
+			expectedConfirmations: 6,
+			expectedFinal:         true,
+		},
+		"final (depth 6)": {
+			depth:                 6,
+			confirmingBlock:       confirmingBlock,
+			head:                  blockResponse("block 105", 105),
+			indexedBlock:          blockResponse("block 100", 100),
+			expectedStatus:        "confirmed",
+			expectedConfirmations: 6,
+			expectedFinal:         true,
+		},
+		"not final (depth 7)": {
+			depth:                 7,
+			confirmingBlock:       confirmingBlock,
+			head:                  blockResponse("block 105", 105),
+			indexedBlock:          blockResponse("block 100", 100),
+			expectedStatus:        "confirmed",
+			expectedConfirmations: 6,
+			expectedFinal:         false,
+		},
+		"final (confirmed at head)": {
+			depth:                 1,
+			confirmingBlock:       confirmingBlock,
+			head:                  blockResponse("block 100", 100),
+			indexedBlock:          blockResponse("block 100", 100),
+			expectedStatus:        "confirmed",
+			expectedConfirmations: 1,
+			expectedFinal:         true,
+		},
+		"not indexed": {
+			depth:                 1,
+			confirmingBlock:       confirmingBlock,
+			head:                  blockResponse("block 99", 99),
+			expectedStatus:        "confirmed",
+			expectedConfirmations: 0,
+			expectedFinal:         false,
+		},
+		"orphaned": {
+			depth:                 1,
+			confirmingBlock:       confirmingBlock,
+			head:                  blockResponse("block 105", 105),
+			indexedBlock:          blockResponse("other block 100", 100),
+			expectedStatus:        "orphaned",
+			expectedConfirmations: 0,
+			expectedFinal:         false,
+		},
+		"mempool": {
+			depth:                 1,
+			expectedStatus:        "unconfirmed",
+			expectedConfirmations: 0,
+			expectedFinal:         false,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := &configuration.Configuration{
+				Mode:          configuration.Online,
+				FinalityDepth: test.depth,
+			}
+			mockClient := &mocks.Client{}
+			mockIndexer := &mocks.Indexer{}
+			servicer := NewCallAPIService(cfg, mockClient, mockIndexer)
+
+			mockIndexer.On("TxIndexEnabled").Return(true).Once()
+			mockClient.On("TransactionBlock", ctx, "tx1").Return(test.confirmingBlock, nil).Once()
+			if test.confirmingBlock == nil {
+				// The transaction was confirmed after
+				// we looked up its block.
+				mockClient.On("MempoolTransaction", ctx, "tx1").Return(
+					nil,
+					whive.ErrTransactionNotFound,
+				).Once()
+			}
+			if test.head != nil {
+				mockIndexer.On(
+					"GetBlockLazy",
+					ctx,
+					(*types.PartialBlockIdentifier)(nil),
+				).Return(test.head, nil).Once()
+			}
+			if test.indexedBlock != nil {
+				mockIndexer.On(
+					"GetBlockLazy",
+					ctx,
+					&types.PartialBlockIdentifier{Index: &confirmingBlock.Index},
+				).Return(test.indexedBlock, nil).Once()
+			}
+
+			resp, err := servicer.Call(ctx, &types.CallRequest{
+				Method:     CallMethodTransactionFinality,
+				Parameters: parameters,
+			})
+			assert.Nil(t, err)
+			assert.Equal(t, &types.CallResponse{
+				Result: forceMarshalMap(t, &transactionFinalityResult{
+					Status:          test.expectedStatus,
+					BlockIdentifier: test.confirmingBlock,
+					Confirmations:   test.expectedConfirmations,
+					FinalityDepth:   test.depth,
+					Final:           test.expectedFinal,
+				}),
+				Idempotent: false,
+			}, resp)
+
+			mockClient.AssertExpectations(t)
+			mockIndexer.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCallEndpoints_StorageSize(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode: configuration.Online,
+	}
+
+	mockClient := &mocks.Client{}
+	mockIndexer := &mocks.Indexer{}
+	servicer := NewCallAPIService(cfg, mockClient, mockIndexer)
+	ctx := context.Background()
+
+	mockIndexer.On("StorageSize").Return(uint64(123456789)).Once()
+	resp, err := servicer.Call(ctx, &types.CallRequest{
+		Method: CallMethodStorageSize,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, &types.CallResponse{
+		Result: map[string]interface{}{
+			"bytes": uint64(123456789),
+		},
+		Idempotent: false,
+	}, resp)
+
+	mockClient.AssertExpectations(t)
+	mockIndexer.AssertExpectations(t)
+}
+
+func TestCallEndpoints_Checkpoint(t *testing.T) {
+	mainnetCheckpoint := whive.MainnetParams.Checkpoints[len(whive.MainnetParams.Checkpoints)-1]
+
+	tests := map[string]struct {
+		params *chaincfg.Params
+
+		expected *types.BlockIdentifier
+	}{
+		"mainnet": {
+			params: whive.MainnetParams,
+			expected: &types.BlockIdentifier{
+				Index: int64(mainnetCheckpoint.Height),
+				Hash:  mainnetCheckpoint.Hash.String(),
+			},
+		},
+		"regtest (no checkpoints)": {
+			params: whive.RegtestParams,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := &configuration.Configuration{
+				Mode:   configuration.Online,
+				Params: test.params,
+			}
+
+			mockClient := &mocks.Client{}
+			mockIndexer := &mocks.Indexer{}
+			servicer := NewCallAPIService(cfg, mockClient, mockIndexer)
+			ctx := context.Background()
+
+			resp, err := servicer.Call(ctx, &types.CallRequest{
+				Method: CallMethodCheckpoint,
+			})
+			assert.Nil(t, err)
+			assert.True(t, resp.Idempotent)
+
+			var result checkpointResult
+			assert.NoError(t, types.UnmarshalMap(resp.Result, &result))
+			assert.Equal(t, test.expected, result.BlockIdentifier)
+
+			mockClient.AssertExpectations(t)
+			mockIndexer.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCallEndpoints_FirstSeen(t *testing.T) {
+	account := &types.AccountIdentifier{
+		Address: "mzBc4XEFSdzCDcTxAgf6EZXgsZWpztRhef",
+	}
+	block := &types.BlockIdentifier{
+		Index: 100,
+		Hash:  "block 100",
+	}
+
+	tests := map[string]struct {
+		parameters map[string]interface{}
+
+		firstSeen    *types.BlockIdentifier
+		trackedSince int64
+
+		expectedResult *firstSeenResult
+		expectedError  *types.Error
+	}{
+		"funded": {
+			parameters: map[string]interface{}{
+				"account_identifier": account,
+			},
+			firstSeen: block,
+			expectedResult: &firstSeenResult{
+				BlockIdentifier: block,
+			},
+		},
+		"funded before tracking": {
+			parameters: map[string]interface{}{
+				"account_identifier": account,
+			},
+			trackedSince: 50,
+			expectedResult: &firstSeenResult{
+				TrackedSinceIndex: 50,
+			},
+		},
+		"missing account": {
+			parameters:    map[string]interface{}{},
+			expectedError: ErrCallParametersInvalid,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := &configuration.Configuration{
+				Mode: configuration.Online,
+			}
+
+			mockClient := &mocks.Client{}
+			mockIndexer := &mocks.Indexer{}
+			servicer := NewCallAPIService(cfg, mockClient, mockIndexer)
+			ctx := context.Background()
+
+			if test.expectedError == nil {
+				mockIndexer.On(
+					"GetFirstSeen",
+					ctx,
+					account,
+				).Return(
+					test.firstSeen,
+					test.trackedSince,
+					nil,
+				).Once()
+			}
+
+			resp, err := servicer.Call(ctx, &types.CallRequest{
+				Method:     CallMethodFirstSeen,
+				Parameters: test.parameters,
+			})
+			if test.expectedError != nil {
+				assert.Nil(t, resp)
+				assert.Equal(t, test.expectedError.Code, err.Code)
+			} else {
+				assert.Nil(t, err)
+				assert.False(t, resp.Idempotent)
+
+				var result firstSeenResult
+				assert.NoError(t, types.UnmarshalMap(resp.Result, &result))
+				assert.Equal(t, test.expectedResult, &result)
+			}
+
+			mockClient.AssertExpectations(t)
+			mockIndexer.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCallEndpoints_RPCLatency(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode: configuration.Online,
+	}
+
+	mockClient := &mocks.Client{}
+	mockIndexer := &mocks.Indexer{}
+	servicer := NewCallAPIService(cfg, mockClient, mockIndexer)
+	ctx := context.Background()
+
+	for i := 1; i <= 100; i++ {
+		metrics.WhivedRPCLatency.WithLabelValues("getblock").Observe(float64(i) / 1000)
+	}
+
+	resp, err := servicer.Call(ctx, &types.CallRequest{
+		Method: CallMethodRPCLatency,
+	})
+	assert.Nil(t, err)
+	assert.False(t, resp.Idempotent)
+
+	var result rpcLatencyResult
+	assert.NoError(t, types.UnmarshalMap(resp.Result, &result))
+	assert.Equal(t, &metrics.LatencySummary{
+		Count: 100,
+		P50:   0.05,
+		P95:   0.095,
+		P99:   0.099,
+	}, result.Methods["getblock"])
+
+	mockClient.AssertExpectations(t)
+	mockIndexer.AssertExpectations(t)
+}
+
+func TestCallEndpoints_FeeRateConfirmation(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode:   configuration.Online,
+		Params: whive.MainnetParams,
+	}
+
+	// Fee estimates (in BTC/kB) by confirmation target. whived
+	// does not have enough data to estimate the 4 block target.
+	estimates := map[int64]float64{
+		1:    0.0005,
+		2:    0.0003,
+		3:    0.0002,
+		4:    0,
+		6:    0.0001,
+		12:   0.00005,
+		24:   0.00003,
+		48:   0.00002,
+		144:  0.00001,
+		504:  0.00001,
+		1008: 0.00001,
+	}
+
+	tests := map[string]struct {
+		parameters map[string]interface{}
+		estimates  map[int64]float64
+		whivedErr  error
+
+		expectedResult *feeRateConfirmationResult
+		expectedError  *types.Error
+	}{
+		"above all estimates": {
+			parameters: map[string]interface{}{"fee_rate": 100},
+			estimates:  estimates,
+			expectedResult: &feeRateConfirmationResult{
+				Confirmable:      true,
+				EstimatedBlocks:  1,
+				EstimatedSeconds: 600,
+			},
+		},
+		"between estimates": {
+			parameters: map[string]interface{}{"fee_rate": 25},
+			estimates:  estimates,
+			expectedResult: &feeRateConfirmationResult{
+				Confirmable:      true,
+				EstimatedBlocks:  3,
+				EstimatedSeconds: 1800,
+			},
+		},
+		"skips missing estimates": {
+			parameters: map[string]interface{}{"fee_rate": 15},
+			estimates:  estimates,
+			expectedResult: &feeRateConfirmationResult{
+				Confirmable:      true,
+				EstimatedBlocks:  6,
+				EstimatedSeconds: 3600,
+			},
+		},
+		"below all estimates": {
+			parameters: map[string]interface{}{"fee_rate": 0.5},
+			estimates:  estimates,
+			expectedResult: &feeRateConfirmationResult{
+				MinimumFeeRate: 1,
+			},
+		},
+		"no estimates": {
+			parameters:    map[string]interface{}{"fee_rate": 10},
+			estimates:     map[int64]float64{},
+			expectedError: ErrCouldNotGetFeeRate,
+		},
+		"whived error": {
+			parameters:    map[string]interface{}{"fee_rate": 10},
+			whivedErr:     errors.New("connection refused"),
+			expectedError: ErrCouldNotGetFeeRate,
+		},
+		"missing fee rate": {
+			parameters:    map[string]interface{}{},
+			expectedError: ErrCallParametersInvalid,
+		},
+		"negative fee rate": {
+			parameters:    map[string]interface{}{"fee_rate": -1},
+			expectedError: ErrCallParametersInvalid,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockClient := &mocks.Client{}
+			mockIndexer := &mocks.Indexer{}
+			servicer := NewCallAPIService(cfg, mockClient, mockIndexer)
+			ctx := context.Background()
+
+			for _, target := range confirmationTargets {
+				mockClient.On(
+					"SuggestedFeeRate",
+					ctx,
+					target,
+				).Return(
+					test.estimates[target],
+					test.whivedErr,
+				).Maybe()
+			}
+
+			resp, err := servicer.Call(ctx, &types.CallRequest{
+				Method:     CallMethodFeeRateConfirmation,
+				Parameters: test.parameters,
+			})
+			if test.expectedError != nil {
+				assert.Nil(t, resp)
+				assert.Equal(t, test.expectedError.Code, err.Code)
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.False(t, resp.Idempotent)
+
+			var result feeRateConfirmationResult
+			assert.NoError(t, types.UnmarshalMap(resp.Result, &result))
+			assert.InDelta(t, test.expectedResult.MinimumFeeRate, result.MinimumFeeRate, 0.0001)
+			result.MinimumFeeRate = test.expectedResult.MinimumFeeRate
+			assert.Equal(t, test.expectedResult, &result)
+
+			mockIndexer.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCallEndpoints_ScriptBalance(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode:     configuration.Online,
+		Params:   whive.TestnetParams,
+		Currency: whive.TestnetCurrency,
+	}
+
+	mockClient := &mocks.Client{}
+	mockIndexer := &mocks.Indexer{}
+	servicer := NewCallAPIService(cfg, mockClient, mockIndexer)
+	ctx := context.Background()
+
+	block := &types.BlockIdentifier{
+		Index: 1000,
+		Hash:  "block 1000",
+	}
+
+	// Nonstandard scripts are keyed by their hex encoding
+	mockIndexer.On(
+		"GetCoins",
+		ctx,
+		&types.AccountIdentifier{Address: "5187"},
+	).Return(
+		[]*types.Coin{
+			{
+				CoinIdentifier: &types.CoinIdentifier{Identifier: "coin 1"},
+				Amount: &types.Amount{
+					Value:    "1000",
+					Currency: whive.TestnetCurrency,
+				},
+			},
+			{
+				CoinIdentifier: &types.CoinIdentifier{Identifier: "coin 2"},
+				Amount: &types.Amount{
+					Value:    "2500",
+					Currency: whive.TestnetCurrency,
+				},
+			},
+		},
+		block,
+		nil,
+	).Once()
+	resp, err := servicer.Call(ctx, &types.CallRequest{
+		Method: CallMethodScriptBalance,
+		Parameters: map[string]interface{}{
+			"script_pub_key": "5187",
+		},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, &types.CallResponse{
+		Result: forceMarshalMap(t, &scriptBalanceResult{
+			AccountIdentifier: &types.AccountIdentifier{Address: "5187"},
+			Balance: &types.Amount{
+				Value:    "3500",
+				Currency: whive.TestnetCurrency,
+			},
+			BlockIdentifier: block,
+		}),
+		Idempotent: false,
+	}, resp)
+
+	// Standard scripts are keyed by their address
+	account := &types.AccountIdentifier{
+		Address: "tb1qcqzmqzkswhfshzd8kedhmtvgnxax48z4fklhvm",
+	}
+	mockIndexer.On(
+		"GetCoins",
+		ctx,
+		account,
+	).Return(
+		[]*types.Coin{},
+		block,
+		nil,
+	).Once()
+	resp, err = servicer.Call(ctx, &types.CallRequest{
+		Method: CallMethodScriptBalance,
+		Parameters: map[string]interface{}{
+			"script_pub_key": "0014c005b00ad075d30b89a7b65b7dad8899ba6a9c55",
+		},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, &types.CallResponse{
+		Result: forceMarshalMap(t, &scriptBalanceResult{
+			AccountIdentifier: account,
+			Balance: &types.Amount{
+				Value:    "0",
+				Currency: whive.TestnetCurrency,
+			},
+			BlockIdentifier: block,
+		}),
+		Idempotent: false,
+	}, resp)
+
+	// Invalid script
+	resp, err = servicer.Call(ctx, &types.CallRequest{
+		Method: CallMethodScriptBalance,
+		Parameters: map[string]interface{}{
+			"script_pub_key": "not hex",
+		},
+	})
+	assert.Nil(t, resp)
+	assert.Equal(t, ErrCallParametersInvalid.Code, err.Code)
+
+	mockClient.AssertExpectations(t)
+	mockIndexer.AssertExpectations(t)
+}
+
+func TestCallEndpoints_DifficultyHistory(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode:   configuration.Online,
+		Params: whive.TestnetParams,
+	}
+
+	mockClient := &mocks.Client{}
+	mockIndexer := &mocks.Indexer{}
+	servicer := NewCallAPIService(cfg, mockClient, mockIndexer)
+	ctx := context.Background()
+
+	headers := []*whive.BlockHeader{}
+	for _, index := range []int64{2016, 4032} {
+		header := &whive.BlockHeader{
+			Hash:       fmt.Sprintf("block %d", index),
+			Height:     index,
+			Bits:       "1d00ffff",
+			Difficulty: float64(index),
+		}
+		mockClient.On("BlockHeader", ctx, index).Return(header, nil).Once()
+		headers = append(headers, header)
+	}
+
+	resp, err := servicer.Call(ctx, &types.CallRequest{
+		Method: CallMethodDifficultyHistory,
+		Parameters: map[string]interface{}{
+			"start_index": 1,
+			"end_index":   5000,
+		},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, &types.CallResponse{
+		Result: forceMarshalMap(t, &difficultyHistoryResult{
+			RetargetInterval: 2016,
+			Headers:          headers,
+		}),
+		Idempotent: false,
+	}, resp)
+
+	// Too many retarget points
+	resp, err = servicer.Call(ctx, &types.CallRequest{
+		Method: CallMethodDifficultyHistory,
+		Parameters: map[string]interface{}{
+			"start_index": 0,
+			"end_index":   2016 * 100,
+		},
+	})
+	assert.Nil(t, resp)
+	assert.Equal(t, ErrCallParametersInvalid.Code, err.Code)
+
+	// Invalid range
+	resp, err = servicer.Call(ctx, &types.CallRequest{
+		Method: CallMethodDifficultyHistory,
+		Parameters: map[string]interface{}{
+			"start_index": 10,
+			"end_index":   5,
+		},
+	})
+	assert.Nil(t, resp)
+	assert.Equal(t, ErrCallParametersInvalid.Code, err.Code)
+
+	mockClient.AssertExpectations(t)
+	mockIndexer.AssertExpectations(t)
+}
+
+func TestCallEndpoints_MedianFeeRate(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode:     configuration.Online,
+		Params:   whive.TestnetParams,
+		Currency: whive.TestnetCurrency,
+	}
+
+	mockClient := &mocks.Client{}
+	mockIndexer := &mocks.Indexer{}
+	servicer := NewCallAPIService(cfg, mockClient, mockIndexer)
+	ctx := context.Background()
+
+	newTransaction := func(hash string, opType string, values []string, vsize int64) *types.Transaction {
+		ops := make([]*types.Operation, len(values))
+		for i, value := range values {
+			ops[i] = &types.Operation{
+				OperationIdentifier: &types.OperationIdentifier{Index: int64(i)},
+				Type:                opType,
+				Amount: &types.Amount{
+					Value:    value,
+					Currency: whive.TestnetCurrency,
+				},
+			}
+		}
+
+		return &types.Transaction{
+			TransactionIdentifier: &types.TransactionIdentifier{Hash: hash},
+			Operations:            ops,
+			Metadata: forceMarshalMap(t, &whive.TransactionMetadata{
+				Vsize: vsize,
+			}),
+		}
+	}
+
+	// Fee rates: tx a is 10, tx b is 2,
+	// tx c is 30, and tx d is 4.
+	blocks := map[int64]*types.BlockResponse{
+		102: {
+			Block: &types.Block{
+				BlockIdentifier: &types.BlockIdentifier{Index: 102, Hash: "block 102"},
+				Transactions: []*types.Transaction{
+					newTransaction("coinbase", whive.CoinbaseOpType, []string{"0"}, 100),
+					newTransaction("a", whive.InputOpType, []string{"-10000", "9000"}, 100),
+				},
+			},
+		},
+		101: {
+			Block: &types.Block{
+				BlockIdentifier: &types.BlockIdentifier{Index: 101, Hash: "block 101"},
+			},
+			OtherTransactions: []*types.TransactionIdentifier{{Hash: "b"}},
+		},
+		100: {
+			Block: &types.Block{
+				BlockIdentifier: &types.BlockIdentifier{Index: 100, Hash: "block 100"},
+				Transactions: []*types.Transaction{
+					newTransaction("c", whive.InputOpType, []string{"-5000", "2000"}, 100),
+					newTransaction("d", whive.InputOpType, []string{"-3000", "-1000", "3200"}, 200),
+				},
+			},
+		},
+	}
+
+	mockIndexer.On("GetBlockLazy", ctx, (*types.PartialBlockIdentifier)(nil)).Return(blocks[102], nil)
+	for index, block := range blocks {
+		blockIndex := index
+		mockIndexer.On(
+			"GetBlockLazy",
+			ctx,
+			&types.PartialBlockIdentifier{Index: &blockIndex},
+		).Return(block, nil)
+	}
+
+	// Transactions of a block are only read
+	// the first time its fee rates are needed.
+	mockIndexer.On(
+		"GetBlockTransaction",
+		ctx,
+		blocks[101].Block.BlockIdentifier,
+		&types.TransactionIdentifier{Hash: "b"},
+	).Return(
+		newTransaction("b", whive.InputOpType, []string{"-1500", "1000"}, 250),
+		nil,
+	).Once()
+
+	tests := map[string]struct {
+		blocks int64
+
+		expectedResult *medianFeeRateResult
+		expectedError  *types.Error
+	}{
+		"2 blocks": {
+			blocks: 2,
+			expectedResult: &medianFeeRateResult{
+				MedianFeeRate:   6,
+				Blocks:          2,
+				Transactions:    2,
+				BlockIdentifier: blocks[102].Block.BlockIdentifier,
+			},
+		},
+		"3 blocks": {
+			blocks: 3,
+			expectedResult: &medianFeeRateResult{
+				MedianFeeRate:   7,
+				Blocks:          3,
+				Transactions:    4,
+				BlockIdentifier: blocks[102].Block.BlockIdentifier,
+			},
+		},
+		"no blocks": {
+			blocks:        0,
+			expectedError: ErrCallParametersInvalid,
+		},
+		"too many blocks": {
+			blocks:        maxMedianFeeRateBlocks + 1,
+			expectedError: ErrCallParametersInvalid,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			resp, err := servicer.Call(ctx, &types.CallRequest{
+				Method: CallMethodMedianFeeRate,
+				Parameters: map[string]interface{}{
+					"blocks": test.blocks,
+				},
+			})
+			if test.expectedError != nil {
+				assert.Nil(t, resp)
+				assert.Equal(t, test.expectedError.Code, err.Code)
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Equal(t, &types.CallResponse{
+				Result:     forceMarshalMap(t, test.expectedResult),
+				Idempotent: false,
+			}, resp)
+		})
+	}
+
+	mockClient.AssertExpectations(t)
+	mockIndexer.AssertExpectations(t)
+}
@@ -0,0 +1,128 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strings"
+)
+
+const (
+	gzipEncoding = "gzip"
+)
+
+// GzipResponseWriter buffers a response until it
+// is at least minSize bytes long and then gzip
+// compresses the remainder of the response. Responses
+// smaller than minSize are written uncompressed.
+type GzipResponseWriter struct {
+	http.ResponseWriter
+
+	minSize int
+	code    int
+	buf     []byte
+	gz      *gzip.Writer
+}
+
+// NewGzipResponseWriter returns a new *GzipResponseWriter.
+func NewGzipResponseWriter(w http.ResponseWriter, minSize int) *GzipResponseWriter {
+	return &GzipResponseWriter{
+		ResponseWriter: w,
+		minSize:        minSize,
+		code:           http.StatusOK,
+	}
+}
+
+// WriteHeader stores the status code of a response. The
+// status code is written once we know if the response will
+// be compressed.
+func (w *GzipResponseWriter) WriteHeader(code int) {
+	w.code = code
+}
+
+// Write buffers or compresses the provided bytes.
+func (w *GzipResponseWriter) Write(b []byte) (int, error) {
+	if w.gz != nil {
+		return w.gz.Write(b)
+	}
+
+	w.buf = append(w.buf, b...)
+	if len(w.buf) < w.minSize {
+		return len(b), nil
+	}
+
+	w.Header().Set("Content-Encoding", gzipEncoding)
+	w.Header().Del("Content-Length")
+	w.ResponseWriter.WriteHeader(w.code)
+
+	w.gz = gzip.NewWriter(w.ResponseWriter)
+	if _, err := w.gz.Write(w.buf); err != nil {
+		return 0, err
+	}
+	w.buf = nil
+
+	return len(b), nil
+}
+
+// Close flushes any buffered or compressed bytes
+// to the underlying http.ResponseWriter.
+func (w *GzipResponseWriter) Close() error {
+	if w.gz != nil {
+		return w.gz.Close()
+	}
+
+	w.ResponseWriter.WriteHeader(w.code)
+	_, err := w.ResponseWriter.Write(w.buf)
+	return err
+}
+
+// acceptsGzip returns a boolean indicating if
+// a request accepts gzip encoded responses.
+func acceptsGzip(r *http.Request) bool {
+	for _, encoding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		parts := strings.Split(encoding, ";")
+		if strings.TrimSpace(parts[0]) != gzipEncoding {
+			continue
+		}
+
+		// Clients may explicitly refuse an encoding with q=0.
+		if len(parts) > 1 && strings.TrimSpace(parts[1]) == "q=0" {
+			return false
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// CompressionMiddleware gzip compresses responses of at least
+// minSize bytes when the client accepts gzip encoding.
+func CompressionMiddleware(minSize int, inner http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The response varies on Accept-Encoding regardless
+		// of whether it is compressed.
+		w.Header().Add("Vary", "Accept-Encoding")
+		if !acceptsGzip(r) {
+			inner.ServeHTTP(w, r)
+			return
+		}
+
+		gzipWriter := NewGzipResponseWriter(w, minSize)
+		inner.ServeHTTP(gzipWriter, r)
+		_ = gzipWriter.Close()
+	})
+}
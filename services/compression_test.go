@@ -0,0 +1,96 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompressionMiddleware(t *testing.T) {
+	minSize := 100
+	largeBody := strings.Repeat("a", minSize*2)
+	smallBody := "small"
+
+	tests := map[string]struct {
+		body           string
+		acceptEncoding string
+
+		compressed bool
+	}{
+		"large response": {
+			body:           largeBody,
+			acceptEncoding: "gzip, deflate",
+			compressed:     true,
+		},
+		"small response": {
+			body:           smallBody,
+			acceptEncoding: "gzip, deflate",
+			compressed:     false,
+		},
+		"gzip not accepted": {
+			body:           largeBody,
+			acceptEncoding: "deflate",
+			compressed:     false,
+		},
+		"gzip refused": {
+			body:           largeBody,
+			acceptEncoding: "gzip;q=0",
+			compressed:     false,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			handler := CompressionMiddleware(
+				minSize,
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+					w.WriteHeader(http.StatusAccepted)
+					_, _ = w.Write([]byte(test.body))
+				}),
+			)
+
+			req := httptest.NewRequest(http.MethodPost, "/block", nil)
+			req.Header.Set("Accept-Encoding", test.acceptEncoding)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusAccepted, rec.Code)
+			assert.Equal(t, "Accept-Encoding", rec.Header().Get("Vary"))
+
+			if !test.compressed {
+				assert.Empty(t, rec.Header().Get("Content-Encoding"))
+				assert.Equal(t, test.body, rec.Body.String())
+				return
+			}
+
+			assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+			assert.True(t, rec.Body.Len() < len(test.body))
+
+			reader, err := gzip.NewReader(rec.Body)
+			assert.NoError(t, err)
+			body, err := ioutil.ReadAll(reader)
+			assert.NoError(t, err)
+			assert.Equal(t, test.body, string(body))
+		})
+	}
+}
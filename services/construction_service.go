@@ -23,11 +23,15 @@ import (
 	"fmt"
 	"math/big"
 	"strconv"
+	"time"
 
 	"github.com/xyephy/rosetta-whive/configuration"
+	"github.com/xyephy/rosetta-whive/metrics"
+	"github.com/xyephy/rosetta-whive/utils"
 	"github.com/xyephy/rosetta-whive/whive"
 
 	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
@@ -44,6 +48,10 @@ const (
 	// defaultConfirmationTarget is the number of blocks we would
 	// like our transaction to be included by.
 	defaultConfirmationTarget = int64(2) // nolint:gomnd
+
+	// maxRBFSequence is the largest input sequence number
+	// that signals a transaction is replaceable (BIP125).
+	maxRBFSequence = wire.MaxTxInSequenceNum - 2
 )
 
 // ConstructionAPIService implements the server.ConstructionAPIServicer interface.
@@ -51,6 +59,8 @@ type ConstructionAPIService struct {
 	config *configuration.Configuration
 	client Client
 	i      Indexer
+
+	idempotencyKeys *idempotencyKeys
 }
 
 // NewConstructionAPIService creates a new instance of a ConstructionAPIService.
@@ -60,21 +70,131 @@ func NewConstructionAPIService(
 	i Indexer,
 ) server.ConstructionAPIServicer {
 	return &ConstructionAPIService{
-		config: config,
-		client: client,
-		i:      i,
+		config:          config,
+		client:          client,
+		i:               i,
+		idempotencyKeys: newIdempotencyKeys(),
+	}
+}
+
+// addressTypeDeployments are the soft forks that must be
+// deployed on a network before addresses of each type
+// can be derived for it.
+var addressTypeDeployments = map[string]int{
+	whive.WitnessV0PubKeyHash: chaincfg.DeploymentSegwit,
+	whive.ScriptHash:          chaincfg.DeploymentSegwit,
+}
+
+// signableScriptTypes are the types of scripts that
+// /construction/payloads can create signing payloads for
+// (and /construction/combine can sign).
+var signableScriptTypes = map[string]bool{
+	whive.PubKeyHash:          true,
+	whive.ScriptHash:          true,
+	whive.WitnessV0PubKeyHash: true,
+}
+
+// checkAddressType returns an error if addressType depends
+// on a soft fork that params doesn't define a deployment for.
+func checkAddressType(params *chaincfg.Params, addressType string) error {
+	deployment, ok := addressTypeDeployments[addressType]
+	if !ok {
+		return nil
+	}
+
+	if params.Deployments[deployment].ExpireTime == 0 {
+		return fmt.Errorf("address type %s is not active on %s", addressType, params.Name)
 	}
+
+	return nil
 }
 
 // ConstructionDerive implements the /construction/derive endpoint.
+// A P2WPKH address is derived unless another address type
+// (like the change address type suggested by
+// /construction/metadata) is provided in the metadata. The
+// "scripthash" address type derives a P2SH-wrapped P2WPKH
+// address.
 func (s *ConstructionAPIService) ConstructionDerive(
 	ctx context.Context,
 	request *types.ConstructionDeriveRequest,
 ) (*types.ConstructionDeriveResponse, *types.Error) {
-	addr, err := btcutil.NewAddressWitnessPubKeyHash(
-		btcutil.Hash160(request.PublicKey.Bytes),
-		s.config.Params,
-	)
+	var metadata deriveMetadata
+	if err := types.UnmarshalMap(request.Metadata, &metadata); err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+
+	if request.PublicKey.CurveType != types.Secp256k1 {
+		return nil, wrapErr(ErrUnableToDerive, fmt.Errorf(
+			"curve type %s is not supported, only %s public keys can be derived",
+			request.PublicKey.CurveType,
+			types.Secp256k1,
+		))
+	}
+
+	keyLength := len(request.PublicKey.Bytes)
+	if keyLength != btcec.PubKeyBytesLenCompressed && keyLength != btcec.PubKeyBytesLenUncompressed {
+		return nil, wrapErr(ErrUnableToDerive, fmt.Errorf(
+			"public key is %d bytes but must be %d (compressed) or %d (uncompressed) bytes",
+			keyLength,
+			btcec.PubKeyBytesLenCompressed,
+			btcec.PubKeyBytesLenUncompressed,
+		))
+	}
+
+	pubKey, err := btcec.ParsePubKey(request.PublicKey.Bytes, btcec.S256())
+	if err != nil {
+		return nil, wrapErr(ErrUnableToDerive, err)
+	}
+
+	compressed := metadata.Compressed == nil || *metadata.Compressed
+	pkBytes := pubKey.SerializeCompressed()
+	addressType := metadata.AddressType
+	if !compressed {
+		pkBytes = pubKey.SerializeUncompressed()
+		if len(addressType) == 0 {
+			addressType = whive.PubKeyHash
+		}
+	}
+
+	if len(addressType) == 0 {
+		addressType = whive.WitnessV0PubKeyHash
+	}
+	if err := checkAddressType(s.config.Params, addressType); err != nil {
+		return nil, wrapErr(ErrUnableToDerive, err)
+	}
+
+	var addr btcutil.Address
+	pkHash := btcutil.Hash160(pkBytes)
+	switch addressType {
+	case whive.WitnessV0PubKeyHash:
+		// Witness programs must commit to a compressed
+		// public key (BIP143).
+		if !compressed {
+			err = errors.New("uncompressed public keys cannot be derived as witness addresses")
+			break
+		}
+
+		addr, err = btcutil.NewAddressWitnessPubKeyHash(pkHash, s.config.Params)
+	case whive.PubKeyHash:
+		addr, err = btcutil.NewAddressPubKeyHash(pkHash, s.config.Params)
+	case whive.ScriptHash:
+		if !compressed {
+			err = errors.New("uncompressed public keys cannot be derived as witness addresses")
+			break
+		}
+
+		// The redeem script is the P2WPKH witness program.
+		var redeemScript []byte
+		redeemScript, err = p2wpkhScript(pkBytes)
+		if err != nil {
+			break
+		}
+
+		addr, err = btcutil.NewAddressScriptHash(redeemScript, s.config.Params)
+	default:
+		err = fmt.Errorf("address type %s is not supported", addressType)
+	}
 	if err != nil {
 		return nil, wrapErr(ErrUnableToDerive, err)
 	}
@@ -86,32 +206,128 @@ func (s *ConstructionAPIService) ConstructionDerive(
 	}, nil
 }
 
+// outputScriptSize returns the size of the script paying
+// to address (or the size of a P2PKH script if address
+// can't be decoded).
+func (s *ConstructionAPIService) outputScriptSize(address string) int {
+	addr, err := btcutil.DecodeAddress(address, s.config.Params)
+	if err != nil {
+		return whive.P2PKHScriptPubkeySize
+	}
+
+	script, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return whive.P2PKHScriptPubkeySize
+	}
+
+	return len(script)
+}
+
+// addressScriptClass returns the class of the script paying
+// to address (or P2WPKH if address can't be decoded).
+func (s *ConstructionAPIService) addressScriptClass(address string) txscript.ScriptClass {
+	addr, err := btcutil.DecodeAddress(address, s.config.Params)
+	if err != nil {
+		return txscript.WitnessV0PubKeyHashTy
+	}
+
+	script, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return txscript.WitnessV0PubKeyHashTy
+	}
+
+	return txscript.GetScriptClass(script)
+}
+
+// spendSize returns the maximum size of the scriptSig and
+// of the witness of an input spending an output of class
+// once it is signed. Outputs of classes that can't be
+// signed are assumed to be P2WPKH.
+func spendSize(class txscript.ScriptClass) (int, int) {
+	switch class {
+	case txscript.PubKeyHashTy:
+		return p2pkhScriptSigSize, 0
+	case txscript.ScriptHashTy:
+		return p2shP2WPKHScriptSigSize, p2wpkhWitnessSize
+	default:
+		return 0, p2wpkhWitnessSize
+	}
+}
+
 // estimateSize returns the estimated size of a transaction in vBytes.
 func (s *ConstructionAPIService) estimateSize(operations []*types.Operation) float64 {
 	size := whive.TransactionOverhead
 	for _, operation := range operations {
 		switch operation.Type {
 		case whive.InputOpType:
-			size += whive.InputSize
+			scriptSigSize, witnessSize := spendSize(
+				s.addressScriptClass(operation.Account.Address),
+			)
+			size += strippedInputSize + scriptSigSize + witnessSize/whive.WitnessScaleFactor
 		case whive.OutputOpType:
-			size += whive.OutputOverhead
-			addr, err := btcutil.DecodeAddress(operation.Account.Address, s.config.Params)
-			if err != nil {
-				size += whive.P2PKHScriptPubkeySize
-				continue
-			}
+			size += whive.OutputOverhead + s.outputScriptSize(operation.Account.Address)
+		}
+	}
 
-			script, err := txscript.PayToAddrScript(addr)
-			if err != nil {
-				size += whive.P2PKHScriptPubkeySize
-				continue
-			}
+	return float64(size)
+}
 
-			size += len(script)
+// estimateWeight returns the estimated weight of a transaction
+// in weight units. Witness data is discounted, so we estimate the
+// stripped size and witness size separately (assuming each input
+// is signed with a signature of the maximum size).
+func (s *ConstructionAPIService) estimateWeight(operations []*types.Operation) int64 {
+	strippedSize := strippedOverheadSize
+	witnessSize := segwitMarkerSize
+	for _, operation := range operations {
+		switch operation.Type {
+		case whive.InputOpType:
+			scriptSigSize, inputWitnessSize := spendSize(
+				s.addressScriptClass(operation.Account.Address),
+			)
+			strippedSize += strippedInputSize + scriptSigSize
+			witnessSize += inputWitnessSize
+		case whive.OutputOpType:
+			strippedSize += whive.OutputOverhead + s.outputScriptSize(operation.Account.Address)
 		}
 	}
 
-	return float64(size)
+	return int64(strippedSize*whive.WitnessScaleFactor + witnessSize)
+}
+
+// checkTransactionSize ensures a transaction constructed from operations
+// does not exceed the configured maximum number of outputs or the
+// maximum standard transaction weight. Oversized transactions would
+// otherwise only be rejected by whived at submission.
+func (s *ConstructionAPIService) checkTransactionSize(
+	operations []*types.Operation,
+	estimatedSize float64,
+) error {
+	outputs := 0
+	for _, operation := range operations {
+		if operation.Type == whive.OutputOpType {
+			outputs++
+		}
+	}
+
+	if s.config.MaxTxOutputs > 0 && outputs > s.config.MaxTxOutputs {
+		return fmt.Errorf(
+			"transaction has %d outputs but at most %d are allowed, split the batch into multiple transactions",
+			outputs,
+			s.config.MaxTxOutputs,
+		)
+	}
+
+	estimatedWeight := estimatedSize * whive.WitnessScaleFactor
+	if estimatedWeight > whive.MaxStandardTxWeight {
+		return fmt.Errorf(
+			"transaction has an estimated weight of %d but at most %d is standard, split the batch into multiple transactions",
+			int64(estimatedWeight),
+			whive.MaxStandardTxWeight,
+		)
+	}
+
+	return nil
 }
 
 // ConstructionPreprocess implements the /construction/preprocess
@@ -155,11 +371,51 @@ func (s *ConstructionAPIService) ConstructionPreprocess(
 		}
 	}
 
-	options, err := types.MarshalMap(&preprocessOptions{
-		Coins:         coins,
-		EstimatedSize: s.estimateSize(request.Operations),
-		FeeMultiplier: request.SuggestedFeeMultiplier,
-	})
+	estimatedSize := s.estimateSize(request.Operations)
+	if err := s.checkTransactionSize(request.Operations, estimatedSize); err != nil {
+		return nil, wrapErr(ErrTransactionTooLarge, err)
+	}
+
+	var metadata preprocessMetadata
+	if err := types.UnmarshalMap(request.Metadata, &metadata); err != nil {
+		return nil, wrapErr(ErrUnclearIntent, err)
+	}
+
+	if err := s.recordIdempotencyKey(ctx, metadata.IdempotencyKey, coins); err != nil {
+		return nil, err
+	}
+
+	preprocess := &preprocessOptions{
+		Coins:           coins,
+		EstimatedSize:   estimatedSize,
+		EstimatedWeight: s.estimateWeight(request.Operations),
+		FeeMultiplier:   request.SuggestedFeeMultiplier,
+		IdempotencyKey:  metadata.IdempotencyKey,
+
+		MatchChangeType: metadata.MatchChangeType,
+	}
+
+	// When the caller explicitly selects inputs, we ensure the
+	// operations spend exactly those coins and record what is
+	// needed to validate them in /construction/metadata.
+	if len(metadata.Inputs) > 0 {
+		if err := checkExplicitInputs(coins, metadata.Inputs); err != nil {
+			return nil, wrapErr(ErrUnclearIntent, err)
+		}
+
+		outputTotal, err := sumOutputs(request.Operations)
+		if err != nil {
+			return nil, wrapErr(ErrUnclearIntent, err)
+		}
+
+		preprocess.InputAccounts = make([]*types.AccountIdentifier, len(coins))
+		for i, input := range matches[0].Operations {
+			preprocess.InputAccounts[i] = input.Account
+		}
+		preprocess.OutputTotal = outputTotal
+	}
+
+	options, err := types.MarshalMap(preprocess)
 	if err != nil {
 		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
 	}
@@ -169,6 +425,172 @@ func (s *ConstructionAPIService) ConstructionPreprocess(
 	}, nil
 }
 
+// recordIdempotencyKey ensures a retried construction flow
+// with the same idempotency key spends the same inputs (so
+// that it can't result in a double-spend). Requests without
+// an idempotency key are not checked.
+func (s *ConstructionAPIService) recordIdempotencyKey(
+	ctx context.Context,
+	key string,
+	coins []*types.Coin,
+) *types.Error {
+	if len(key) == 0 {
+		return nil
+	}
+
+	if err := s.idempotencyKeys.record(key, coins); err != nil {
+		logger := utils.ExtractLogger(ctx, "construction")
+		logger.Warnw("idempotency key reused", "error", err)
+
+		return wrapErr(ErrIdempotencyKeyReused, err)
+	}
+
+	return nil
+}
+
+// checkExplicitInputs ensures the coins spent by the input
+// operations of a /construction/preprocess request are exactly
+// the outpoints selected by the caller.
+func checkExplicitInputs(coins []*types.Coin, inputs []string) error {
+	if len(coins) != len(inputs) {
+		return fmt.Errorf(
+			"operations spend %d coins but %d inputs were selected",
+			len(coins),
+			len(inputs),
+		)
+	}
+
+	selected := map[string]bool{}
+	for _, input := range inputs {
+		if selected[input] {
+			return fmt.Errorf("input %s selected more than once", input)
+		}
+		selected[input] = true
+	}
+
+	for _, coin := range coins {
+		if !selected[coin.CoinIdentifier.Identifier] {
+			return fmt.Errorf(
+				"operations spend coin %s that was not selected",
+				coin.CoinIdentifier.Identifier,
+			)
+		}
+	}
+
+	return nil
+}
+
+// sumOutputs returns the total value of the output
+// operations in operations.
+func sumOutputs(operations []*types.Operation) (string, error) {
+	total := "0"
+	for _, operation := range operations {
+		if operation.Type != whive.OutputOpType {
+			continue
+		}
+
+		if operation.Amount == nil {
+			return "", errors.New("output amount cannot be nil")
+		}
+
+		var err error
+		total, err = types.AddValues(total, operation.Amount.Value)
+		if err != nil {
+			return "", fmt.Errorf("%w: unable to add output amount", err)
+		}
+	}
+
+	return total, nil
+}
+
+// checkCoinsAvailable ensures the coins explicitly selected in
+// /construction/preprocess are unspent, owned by the accounts
+// spending them, and cover the outputs plus the suggested fee.
+func (s *ConstructionAPIService) checkCoinsAvailable(
+	ctx context.Context,
+	options *preprocessOptions,
+	fee *types.Amount,
+) *types.Error {
+	if len(options.InputAccounts) != len(options.Coins) {
+		return wrapErr(
+			ErrUnableToParseIntermediateResult,
+			errors.New("input accounts do not match coins"),
+		)
+	}
+
+	accountCoins := map[string]map[string]*types.Coin{}
+	inputTotal := "0"
+	for i, coin := range options.Coins {
+		account := options.InputAccounts[i]
+		key := types.Hash(account)
+		if _, ok := accountCoins[key]; !ok {
+			coins, _, err := s.i.GetCoins(ctx, account)
+			if err != nil {
+				return wrapErr(ErrUnableToGetCoins, err)
+			}
+
+			accountCoins[key] = map[string]*types.Coin{}
+			for _, owned := range coins {
+				accountCoins[key][owned.CoinIdentifier.Identifier] = owned
+			}
+		}
+
+		owned, ok := accountCoins[key][coin.CoinIdentifier.Identifier]
+		if !ok {
+			return wrapErr(ErrCoinUnavailable, fmt.Errorf(
+				"coin %s is not an unspent coin of %s",
+				coin.CoinIdentifier.Identifier,
+				account.Address,
+			))
+		}
+
+		// Input amounts are negative.
+		amount, err := types.NegateValue(coin.Amount.Value)
+		if err != nil {
+			return wrapErr(ErrUnclearIntent, err)
+		}
+
+		if amount != owned.Amount.Value {
+			return wrapErr(ErrCoinUnavailable, fmt.Errorf(
+				"coin %s has value %s but %s was provided",
+				coin.CoinIdentifier.Identifier,
+				owned.Amount.Value,
+				amount,
+			))
+		}
+
+		inputTotal, err = types.AddValues(inputTotal, amount)
+		if err != nil {
+			return wrapErr(ErrUnclearIntent, err)
+		}
+	}
+
+	required, err := types.AddValues(options.OutputTotal, fee.Value)
+	if err != nil {
+		return wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+
+	requiredValue, err := types.BigInt(required)
+	if err != nil {
+		return wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+
+	inputValue, err := types.BigInt(inputTotal)
+	if err != nil {
+		return wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+
+	if inputValue.Cmp(requiredValue) < 0 {
+		return wrapErr(ErrInsufficientInputs, fmt.Errorf(
+			"inputs total %s but outputs and fee require %s",
+			inputTotal,
+			required,
+		))
+	}
+
+	return nil
+}
+
 // ConstructionMetadata implements the /construction/metadata endpoint.
 func (s *ConstructionAPIService) ConstructionMetadata(
 	ctx context.Context,
@@ -183,33 +605,62 @@ func (s *ConstructionAPIService) ConstructionMetadata(
 		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
 	}
 
-	// Determine feePerKB and ensure it is not below the minimum fee
-	// relay rate.
-	feePerKB, err := s.client.SuggestedFeeRate(ctx, defaultConfirmationTarget)
-	if err != nil {
-		return nil, wrapErr(ErrCouldNotGetFeeRate, err)
-	}
-	if options.FeeMultiplier != nil {
-		feePerKB *= *options.FeeMultiplier
+	// /construction/preprocess may have been served
+	// offline, so we record the idempotency key again.
+	if err := s.recordIdempotencyKey(ctx, options.IdempotencyKey, options.Coins); err != nil {
+		return nil, err
 	}
-	if feePerKB < whive.MinFeeRate {
-		feePerKB = whive.MinFeeRate
+
+	satoshisPerB, rErr := s.suggestedFeeRate(ctx, options.FeeMultiplier)
+	if rErr != nil {
+		return nil, rErr
 	}
+	metrics.ConstructionFeeRate.Observe(satoshisPerB)
 
 	// Calculated the estimated fee in Satoshis
-	satoshisPerB := (feePerKB * float64(whive.SatoshisInBitcoin)) / bytesInKb
 	estimatedFee := satoshisPerB * options.EstimatedSize
 	suggestedFee := &types.Amount{
 		Value:    fmt.Sprintf("%d", int64(estimatedFee)),
 		Currency: s.config.Currency,
 	}
 
+	if len(options.InputAccounts) > 0 {
+		if err := s.checkCoinsAvailable(ctx, &options, suggestedFee); err != nil {
+			return nil, err
+		}
+	}
+
 	scripts, err := s.i.GetScriptPubKeys(ctx, options.Coins)
 	if err != nil {
 		return nil, wrapErr(ErrScriptPubKeysMissing, err)
 	}
 
-	metadata, err := types.MarshalMap(&constructionMetadata{ScriptPubKeys: scripts})
+	// When requested, we suggest the address type of change
+	// outputs so that they look like the inputs being spent.
+	var changeType string
+	if options.MatchChangeType {
+		changeType = changeAddressType(scripts)
+	}
+
+	// We only record when the fee was estimated if
+	// /construction/payloads checks the age of metadata.
+	var timestamp int64
+	if s.config.MaxMetadataAge > 0 {
+		timestamp = time.Now().UnixNano() / int64(time.Millisecond)
+	}
+
+	metadata, err := types.MarshalMap(&constructionMetadata{
+		ScriptPubKeys: scripts,
+
+		// We echo the coins selected in /construction/preprocess so that
+		// the caller can verify them before signing. /construction/payloads
+		// only accepts operations that spend exactly these coins.
+		Coins: options.Coins,
+
+		EstimatedWeight:   options.EstimatedWeight,
+		ChangeAddressType: changeType,
+		Timestamp:         timestamp,
+	})
 	if err != nil {
 		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
 	}
@@ -220,6 +671,155 @@ func (s *ConstructionAPIService) ConstructionMetadata(
 	}, nil
 }
 
+// suggestedFeeRate returns the fee rate (in satoshis per vbyte)
+// estimated by whived for the configured confirmation target
+// (scaled by multiplier, if provided). The fee rate is never below
+// the configured minimum fee rate or the minimum fee rate accepted
+// to whived's mempool (which rises above the relay rate when the
+// mempool is full).
+func (s *ConstructionAPIService) suggestedFeeRate(
+	ctx context.Context,
+	multiplier *float64,
+) (float64, *types.Error) {
+	confirmationTarget := defaultConfirmationTarget
+	if s.config.ConfirmationTarget > 0 {
+		confirmationTarget = s.config.ConfirmationTarget
+	}
+
+	minFeeRate := whive.MinFeeRate
+	if s.config.MinFeeRate > 0 {
+		minFeeRate = s.config.MinFeeRate
+	}
+
+	// whived returns no estimate (which we parse as a fee
+	// rate of 0) when it hasn't seen enough transactions,
+	// in which case the minimum fee rate is used.
+	feePerKB, err := s.client.SuggestedFeeRate(ctx, confirmationTarget)
+	if err != nil {
+		return -1, wrapErr(ErrCouldNotGetFeeRate, err)
+	}
+	if multiplier != nil {
+		feePerKB *= *multiplier
+	}
+	if feePerKB < minFeeRate {
+		feePerKB = minFeeRate
+	}
+
+	mempoolMinFeeRate, err := s.client.MempoolMinFeeRate(ctx)
+	if err != nil {
+		return -1, wrapErr(ErrCouldNotGetFeeRate, err)
+	}
+	if feePerKB < mempoolMinFeeRate {
+		feePerKB = mempoolMinFeeRate
+	}
+
+	return (feePerKB * float64(whive.SatoshisInBitcoin)) / bytesInKb, nil
+}
+
+// checkMetadataAge ensures that the fee of tx (which the caller
+// computed from the fee suggested by /construction/metadata) was
+// not estimated from a stale fee rate. If metadata is older than
+// the configured maximum age, we re-estimate the fee rate and
+// reject tx if its fee no longer covers it. Offline, we can't
+// re-estimate the fee rate, so stale metadata is rejected.
+func (s *ConstructionAPIService) checkMetadataAge(
+	ctx context.Context,
+	metadata *constructionMetadata,
+	tx *wire.MsgTx,
+	fee *big.Int,
+) *types.Error {
+	if s.config.MaxMetadataAge == 0 {
+		return nil
+	}
+
+	if metadata.Timestamp > 0 {
+		age := time.Since(time.Unix(0, metadata.Timestamp*int64(time.Millisecond)))
+		if age <= s.config.MaxMetadataAge {
+			return nil
+		}
+	}
+
+	if s.config.Mode != configuration.Online {
+		return wrapErr(ErrMetadataStale, fmt.Errorf(
+			"metadata is older than %s and the fee can't be re-estimated offline",
+			s.config.MaxMetadataAge,
+		))
+	}
+
+	satoshisPerB, rErr := s.suggestedFeeRate(ctx, nil)
+	if rErr != nil {
+		return rErr
+	}
+
+	vsize := estimatedVsize(tx, metadata.ScriptPubKeys, false)
+	requiredFee := int64(satoshisPerB * float64(vsize))
+	if fee.Cmp(big.NewInt(requiredFee)) < 0 {
+		return wrapErr(ErrMetadataStale, fmt.Errorf(
+			"fee %s is below the re-estimated fee %d for %d vbytes",
+			fee.String(),
+			requiredFee,
+			vsize,
+		))
+	}
+
+	return nil
+}
+
+// changeAddressType returns the address type (that can be
+// provided to /construction/derive) of change that matches
+// the inputs locked by scripts. When inputs are of mixed
+// types, we use the most common type (preferring P2WPKH on
+// a tie because it is cheaper to spend). Inputs that are not
+// locked to a single public key or that /construction/payloads
+// can't sign are not considered, and if there are none, we
+// return P2WPKH.
+func changeAddressType(scripts []*whive.ScriptPubKey) string {
+	var pubKeyHash, witnessPubKeyHash int
+	for _, script := range scripts {
+		if !signableScriptTypes[script.Type] {
+			continue
+		}
+
+		switch script.Type {
+		case whive.PubKeyHash:
+			pubKeyHash++
+		case whive.WitnessV0PubKeyHash:
+			witnessPubKeyHash++
+		}
+	}
+
+	if pubKeyHash > witnessPubKeyHash {
+		return whive.PubKeyHash
+	}
+
+	return whive.WitnessV0PubKeyHash
+}
+
+// supportedOperations returns the INPUT and OUTPUT operations
+// in operations. If strict operations are configured, an error
+// is returned if any other operation is provided.
+func (s *ConstructionAPIService) supportedOperations(
+	operations []*types.Operation,
+) ([]*types.Operation, error) {
+	supported := []*types.Operation{}
+	for _, op := range operations {
+		if op.Type == whive.InputOpType || op.Type == whive.OutputOpType {
+			supported = append(supported, op)
+			continue
+		}
+
+		if s.config.StrictOperations {
+			return nil, fmt.Errorf(
+				"operation %d has unsupported type %s",
+				op.OperationIdentifier.Index,
+				op.Type,
+			)
+		}
+	}
+
+	return supported, nil
+}
+
 // ConstructionPayloads implements the /construction/payloads endpoint.
 func (s *ConstructionAPIService) ConstructionPayloads(
 	ctx context.Context,
@@ -256,7 +856,12 @@ func (s *ConstructionAPIService) ConstructionPayloads(
 		ErrUnmatched: true,
 	}
 
-	matches, err := parser.MatchOperations(descriptions, request.Operations)
+	operations, err := s.supportedOperations(request.Operations)
+	if err != nil {
+		return nil, wrapErr(ErrUnclearIntent, err)
+	}
+
+	matches, err := parser.MatchOperations(descriptions, operations)
 	if err != nil {
 		return nil, wrapErr(ErrUnclearIntent, err)
 	}
@@ -282,7 +887,19 @@ func (s *ConstructionAPIService) ConstructionPayloads(
 		})
 	}
 
+	changeOutputs := []int64{}
 	for i, output := range matches[1].Operations {
+		var outputMetadata payloadsOutputMetadata
+		if err := types.UnmarshalMap(output.Metadata, &outputMetadata); err != nil {
+			return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+		}
+
+		// We track the index of change outputs so that
+		// they can be identified by /construction/parse.
+		if outputMetadata.Change {
+			changeOutputs = append(changeOutputs, int64(i))
+		}
+
 		addr, err := btcutil.DecodeAddress(output.Account.Address, s.config.Params)
 		if err != nil {
 			return nil, wrapErr(ErrUnableToDecodeAddress, fmt.Errorf(
@@ -317,6 +934,32 @@ func (s *ConstructionAPIService) ConstructionPayloads(
 		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
 	}
 
+	if err := checkSelectedCoins(matches[0].Operations, metadata.Coins); err != nil {
+		return nil, wrapErr(ErrUnclearIntent, err)
+	}
+
+	// Input amounts are negative, so the fee is
+	// the negated sum of all amounts.
+	fee := new(big.Int)
+	for _, amount := range matches[0].Amounts {
+		fee.Sub(fee, amount)
+	}
+	for _, amount := range matches[1].Amounts {
+		fee.Sub(fee, amount)
+	}
+
+	if rErr := s.checkMetadataAge(ctx, &metadata, tx, fee); rErr != nil {
+		return nil, rErr
+	}
+
+	if len(metadata.ScriptPubKeys) != len(tx.TxIn) {
+		return nil, wrapErr(ErrScriptPubKeysMissing, fmt.Errorf(
+			"%d ScriptPubKeys provided for %d inputs",
+			len(metadata.ScriptPubKeys),
+			len(tx.TxIn),
+		))
+	}
+
 	for i := range tx.TxIn {
 		address := matches[0].Operations[i].Account.Address
 		script, err := hex.DecodeString(metadata.ScriptPubKeys[i].Hex)
@@ -324,7 +967,7 @@ func (s *ConstructionAPIService) ConstructionPayloads(
 			return nil, wrapErr(ErrUnableToDecodeScriptPubKey, err)
 		}
 
-		class, _, err := whive.ParseSingleAddress(s.config.Params, script)
+		class, scriptAddress, err := whive.ParseSingleAddress(s.config.Params, script)
 		if err != nil {
 			return nil, wrapErr(
 				ErrUnableToDecodeAddress,
@@ -336,10 +979,41 @@ func (s *ConstructionAPIService) ConstructionPayloads(
 		inputAmounts[i] = matches[0].Amounts[i].String()
 		absAmount := new(big.Int).Abs(matches[0].Amounts[i]).Int64()
 
+		if !signableScriptTypes[class.String()] {
+			return nil, wrapErr(
+				ErrUnsupportedScriptType,
+				fmt.Errorf("unupported script type: %s", class),
+			)
+		}
+
+		// P2SH-wrapped P2WPKH inputs are signed like P2WPKH
+		// inputs (BIP143) with the redeem script of the
+		// public key the script commits to.
+		witnessScript := script
 		switch class {
+		case txscript.PubKeyHashTy:
+			hash, err := txscript.CalcSignatureHash(script, txscript.SigHashAll, tx, i)
+			if err != nil {
+				return nil, wrapErr(ErrUnableToCalculateSignatureHash, err)
+			}
+
+			payloads[i] = &types.SigningPayload{
+				AccountIdentifier: &types.AccountIdentifier{
+					Address: address,
+				},
+				Bytes:         hash,
+				SignatureType: types.Ecdsa,
+			}
+		case txscript.ScriptHashTy:
+			witnessScript, err = witnessRedeemScript(scriptAddress, request.PublicKeys)
+			if err != nil {
+				return nil, wrapErr(ErrPublicKeyMismatch, fmt.Errorf("%w: input %d", err, i))
+			}
+
+			fallthrough
 		case txscript.WitnessV0PubKeyHashTy:
 			hash, err := txscript.CalcWitnessSigHash(
-				script,
+				witnessScript,
 				txscript.NewTxSigHashes(tx),
 				txscript.SigHashAll,
 				tx,
@@ -357,11 +1031,6 @@ func (s *ConstructionAPIService) ConstructionPayloads(
 				Bytes:         hash,
 				SignatureType: types.Ecdsa,
 			}
-		default:
-			return nil, wrapErr(
-				ErrUnsupportedScriptType,
-				fmt.Errorf("unupported script type: %s", class),
-			)
 		}
 	}
 
@@ -375,17 +1044,56 @@ func (s *ConstructionAPIService) ConstructionPayloads(
 		ScriptPubKeys:  metadata.ScriptPubKeys,
 		InputAmounts:   inputAmounts,
 		InputAddresses: inputAddresses,
+		ChangeOutputs:  changeOutputs,
 	})
 	if err != nil {
 		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
 	}
 
+	metrics.ConstructionTransactions.WithLabelValues(metrics.OutcomeConstructed).Inc()
+
 	return &types.ConstructionPayloadsResponse{
 		UnsignedTransaction: hex.EncodeToString(rawTx),
 		Payloads:            payloads,
 	}, nil
 }
 
+// checkSelectedCoins ensures the inputs in a /construction/payloads
+// request spend exactly the coins returned by /construction/metadata
+// (in the same order).
+func checkSelectedCoins(inputs []*types.Operation, coins []*types.Coin) error {
+	if len(inputs) != len(coins) {
+		return fmt.Errorf("%d inputs provided but %d coins were selected", len(inputs), len(coins))
+	}
+
+	for i, input := range inputs {
+		coin := coins[i]
+		if coin == nil || coin.CoinIdentifier == nil || coin.Amount == nil {
+			return fmt.Errorf("selected coin %d is invalid", i)
+		}
+
+		if input.CoinChange.CoinIdentifier.Identifier != coin.CoinIdentifier.Identifier {
+			return fmt.Errorf(
+				"input %d spends %s but %s was selected",
+				i,
+				input.CoinChange.CoinIdentifier.Identifier,
+				coin.CoinIdentifier.Identifier,
+			)
+		}
+
+		if input.Amount.Value != coin.Amount.Value {
+			return fmt.Errorf(
+				"input %d has amount %s but selected coin has amount %s",
+				i,
+				input.Amount.Value,
+				coin.Amount.Value,
+			)
+		}
+	}
+
+	return nil
+}
+
 func normalizeSignature(signature []byte) []byte {
 	sig := btcec.Signature{ // signature is in form of R || S
 		R: new(big.Int).SetBytes(signature[:32]),
@@ -395,6 +1103,82 @@ func normalizeSignature(signature []byte) []byte {
 	return append(sig.Serialize(), byte(txscript.SigHashAll))
 }
 
+// checkPublicKeyHash ensures a public key hashes to the
+// pubkey hash paid to by address. Otherwise, the signed
+// transaction would only be rejected by whived on submission.
+func checkPublicKeyHash(address btcutil.Address, pkData []byte) error {
+	pkHash := btcutil.Hash160(pkData)
+	if !bytes.Equal(pkHash, address.ScriptAddress()) {
+		return fmt.Errorf(
+			"public key %x hashes to %x but input script expects %x",
+			pkData,
+			pkHash,
+			address.ScriptAddress(),
+		)
+	}
+
+	return nil
+}
+
+// pubKeyHashKey returns the serialization of the public key
+// pkData (compressed or uncompressed) that hashes to the pubkey
+// hash paid to by address. Signers may return the compressed
+// key of an address derived from an uncompressed key.
+func pubKeyHashKey(address btcutil.Address, pkData []byte) ([]byte, error) {
+	pubKey, err := btcec.ParsePubKey(pkData, btcec.S256())
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to parse public key %x", err, pkData)
+	}
+
+	for _, serialized := range [][]byte{
+		pubKey.SerializeCompressed(),
+		pubKey.SerializeUncompressed(),
+	} {
+		if checkPublicKeyHash(address, serialized) == nil {
+			return serialized, nil
+		}
+	}
+
+	return nil, checkPublicKeyHash(address, pkData)
+}
+
+// p2wpkhScript returns the P2WPKH witness program of
+// the (compressed) public key pkData.
+func p2wpkhScript(pkData []byte) ([]byte, error) {
+	return txscript.NewScriptBuilder().
+		AddOp(txscript.OP_0).
+		AddData(btcutil.Hash160(pkData)).
+		Script()
+}
+
+// witnessRedeemScript returns the redeem script of a
+// P2SH-wrapped P2WPKH address: the P2WPKH witness program
+// of the public key in publicKeys that address commits to.
+func witnessRedeemScript(
+	address btcutil.Address,
+	publicKeys []*types.PublicKey,
+) ([]byte, error) {
+	for _, publicKey := range publicKeys {
+		if publicKey == nil || len(publicKey.Bytes) != btcec.PubKeyBytesLenCompressed {
+			continue
+		}
+
+		redeemScript, err := p2wpkhScript(publicKey.Bytes)
+		if err != nil {
+			return nil, err
+		}
+
+		if bytes.Equal(btcutil.Hash160(redeemScript), address.ScriptAddress()) {
+			return redeemScript, nil
+		}
+	}
+
+	return nil, fmt.Errorf(
+		"no compressed public key provided hashes to the redeem script of %s",
+		address.EncodeAddress(),
+	)
+}
+
 // ConstructionCombine implements the /construction/combine
 // endpoint.
 func (s *ConstructionAPIService) ConstructionCombine(
@@ -439,7 +1223,7 @@ func (s *ConstructionAPIService) ConstructionCombine(
 			return nil, wrapErr(ErrUnableToDecodeScriptPubKey, err)
 		}
 
-		class, _, err := whive.ParseSingleAddress(s.config.Params, decodedScript)
+		class, address, err := whive.ParseSingleAddress(s.config.Params, decodedScript)
 		if err != nil {
 			return nil, wrapErr(
 				ErrUnableToDecodeAddress,
@@ -451,7 +1235,51 @@ func (s *ConstructionAPIService) ConstructionCombine(
 		fullsig := normalizeSignature(request.Signatures[i].Bytes)
 
 		switch class {
+		case txscript.PubKeyHashTy:
+			pubKey, err := pubKeyHashKey(address, pkData)
+			if err != nil {
+				return nil, wrapErr(
+					ErrPublicKeyMismatch,
+					fmt.Errorf("%w: input %d", err, i),
+				)
+			}
+
+			sigScript, err := txscript.NewScriptBuilder().
+				AddData(fullsig).
+				AddData(pubKey).
+				Script()
+			if err != nil {
+				return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+			}
+
+			tx.TxIn[i].SignatureScript = sigScript
 		case txscript.WitnessV0PubKeyHashTy:
+			if err := checkPublicKeyHash(address, pkData); err != nil {
+				return nil, wrapErr(
+					ErrPublicKeyMismatch,
+					fmt.Errorf("%w: input %d", err, i),
+				)
+			}
+
+			tx.TxIn[i].Witness = wire.TxWitness{fullsig, pkData}
+		case txscript.ScriptHashTy:
+			redeemScript, err := witnessRedeemScript(
+				address,
+				[]*types.PublicKey{request.Signatures[i].PublicKey},
+			)
+			if err != nil {
+				return nil, wrapErr(
+					ErrPublicKeyMismatch,
+					fmt.Errorf("%w: input %d", err, i),
+				)
+			}
+
+			sigScript, err := txscript.NewScriptBuilder().AddData(redeemScript).Script()
+			if err != nil {
+				return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+			}
+
+			tx.TxIn[i].SignatureScript = sigScript
 			tx.TxIn[i].Witness = wire.TxWitness{fullsig, pkData}
 		default:
 			return nil, wrapErr(
@@ -467,8 +1295,9 @@ func (s *ConstructionAPIService) ConstructionCombine(
 	}
 
 	rawTx, err := json.Marshal(&signedTransaction{
-		Transaction:  hex.EncodeToString(buf.Bytes()),
-		InputAmounts: unsigned.InputAmounts,
+		Transaction:   hex.EncodeToString(buf.Bytes()),
+		InputAmounts:  unsigned.InputAmounts,
+		ChangeOutputs: unsigned.ChangeOutputs,
 	})
 	if err != nil {
 		return nil, wrapErr(
@@ -511,17 +1340,21 @@ func (s *ConstructionAPIService) ConstructionHash(
 		)
 	}
 
-	tx, err := btcutil.NewTxFromBytes(bytesTx)
-	if err != nil {
+	var tx wire.MsgTx
+	if err := tx.Deserialize(bytes.NewReader(bytesTx)); err != nil {
 		return nil, wrapErr(
 			ErrUnableToParseIntermediateResult,
 			fmt.Errorf("%w unable to parse transaction", err),
 		)
 	}
 
+	// The transaction identifier is the txid, which is the hash
+	// of the legacy serialization (without witness data). This
+	// is the same whether or not the transaction has witness
+	// data, unlike the wtxid.
 	return &types.TransactionIdentifierResponse{
 		TransactionIdentifier: &types.TransactionIdentifier{
-			Hash: tx.Hash().String(),
+			Hash: tx.TxHash().String(),
 		},
 	}, nil
 }
@@ -590,35 +1423,21 @@ func (s *ConstructionAPIService) parseUnsignedTransaction(
 		})
 	}
 
-	for i, output := range tx.TxOut {
-		networkIndex := int64(i)
-		_, addr, err := whive.ParseSingleAddress(s.config.Params, output.PkScript)
-		if err != nil {
-			return nil, wrapErr(
-				ErrUnableToDecodeAddress,
-				fmt.Errorf("%w unable to parse output address", err),
-			)
-		}
+	outputOps, parseErr := s.parseOutputs(tx.TxOut, int64(len(ops)), unsigned.ChangeOutputs)
+	if parseErr != nil {
+		return nil, parseErr
+	}
+	ops = append(ops, outputOps...)
 
-		ops = append(ops, &types.Operation{
-			OperationIdentifier: &types.OperationIdentifier{
-				Index:        int64(len(ops)),
-				NetworkIndex: &networkIndex,
-			},
-			Type: whive.OutputOpType,
-			Account: &types.AccountIdentifier{
-				Address: addr.String(),
-			},
-			Amount: &types.Amount{
-				Value:    strconv.FormatInt(output.Value, 10),
-				Currency: s.config.Currency,
-			},
-		})
+	metadata, err := parseTxMetadata(&tx, unsigned.InputAmounts, unsigned.ScriptPubKeys, false)
+	if err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
 	}
 
 	return &types.ConstructionParseResponse{
 		Operations:               ops,
 		AccountIdentifierSigners: []*types.AccountIdentifier{},
+		Metadata:                 metadata,
 	}, nil
 }
 
@@ -660,7 +1479,7 @@ func (s *ConstructionAPIService) parseSignedTransaction(
 	ops := []*types.Operation{}
 	signers := []*types.AccountIdentifier{}
 	for i, input := range tx.TxIn {
-		pkScript, err := txscript.ComputePkScript(input.SignatureScript, input.Witness)
+		pkScript, err := s.inputPkScript(input)
 		if err != nil {
 			return nil, wrapErr(
 				ErrUnableToComputePkScript,
@@ -668,7 +1487,7 @@ func (s *ConstructionAPIService) parseSignedTransaction(
 			)
 		}
 
-		_, addr, err := whive.ParseSingleAddress(s.config.Params, pkScript.Script())
+		_, addr, err := whive.ParseSingleAddress(s.config.Params, pkScript)
 		if err != nil {
 			return nil, wrapErr(
 				ErrUnableToDecodeAddress,
@@ -706,7 +1525,67 @@ func (s *ConstructionAPIService) parseSignedTransaction(
 		})
 	}
 
-	for i, output := range tx.TxOut {
+	outputOps, parseErr := s.parseOutputs(tx.TxOut, int64(len(ops)), signed.ChangeOutputs)
+	if parseErr != nil {
+		return nil, parseErr
+	}
+	ops = append(ops, outputOps...)
+
+	metadata, err := parseTxMetadata(&tx, signed.InputAmounts, nil, true)
+	if err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+
+	return &types.ConstructionParseResponse{
+		Operations:               ops,
+		AccountIdentifierSigners: signers,
+		Metadata:                 metadata,
+	}, nil
+}
+
+// inputPkScript returns the script spent by the signed input.
+// btcd only recognizes P2PKH scriptSigs with a compressed public
+// key, so scriptSigs (without a witness) that push a signature
+// and an uncompressed public key are handled here.
+func (s *ConstructionAPIService) inputPkScript(input *wire.TxIn) ([]byte, error) {
+	if len(input.Witness) == 0 {
+		pushes, err := txscript.PushedData(input.SignatureScript)
+		if err == nil && len(pushes) == 2 && // nolint:gomnd
+			len(pushes[1]) == btcec.PubKeyBytesLenUncompressed {
+			if _, err := btcec.ParsePubKey(pushes[1], btcec.S256()); err == nil {
+				addr, err := btcutil.NewAddressPubKeyHash(btcutil.Hash160(pushes[1]), s.config.Params)
+				if err != nil {
+					return nil, err
+				}
+
+				return txscript.PayToAddrScript(addr)
+			}
+		}
+	}
+
+	pkScript, err := txscript.ComputePkScript(input.SignatureScript, input.Witness)
+	if err != nil {
+		return nil, err
+	}
+
+	return pkScript.Script(), nil
+}
+
+// parseOutputs returns the OUTPUT operations of outputs (starting
+// at operation index startIndex). Outputs at the indices in
+// changeOutputs are marked as change in the operation metadata.
+func (s *ConstructionAPIService) parseOutputs(
+	outputs []*wire.TxOut,
+	startIndex int64,
+	changeOutputs []int64,
+) ([]*types.Operation, *types.Error) {
+	change := map[int64]struct{}{}
+	for _, index := range changeOutputs {
+		change[index] = struct{}{}
+	}
+
+	ops := make([]*types.Operation, len(outputs))
+	for i, output := range outputs {
 		networkIndex := int64(i)
 		_, addr, err := whive.ParseSingleAddress(s.config.Params, output.PkScript)
 		if err != nil {
@@ -716,9 +1595,9 @@ func (s *ConstructionAPIService) parseSignedTransaction(
 			)
 		}
 
-		ops = append(ops, &types.Operation{
+		ops[i] = &types.Operation{
 			OperationIdentifier: &types.OperationIdentifier{
-				Index:        int64(len(ops)),
+				Index:        startIndex + networkIndex,
 				NetworkIndex: &networkIndex,
 			},
 			Type: whive.OutputOpType,
@@ -729,13 +1608,128 @@ func (s *ConstructionAPIService) parseSignedTransaction(
 				Value:    strconv.FormatInt(output.Value, 10),
 				Currency: s.config.Currency,
 			},
-		})
+		}
+
+		if _, ok := change[networkIndex]; !ok {
+			continue
+		}
+
+		metadata, err := types.MarshalMap(&ParseOperationMetadata{Change: true})
+		if err != nil {
+			return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+		}
+		ops[i].Metadata = metadata
 	}
 
-	return &types.ConstructionParseResponse{
-		Operations:               ops,
-		AccountIdentifierSigners: signers,
-	}, nil
+	return ops, nil
+}
+
+// parseTxMetadata returns the fee, virtual size, and effective
+// fee rate (in satoshis per vbyte) of tx. The effective fee rate
+// may differ slightly from the suggested fee rate because of
+// rounding when inputs and change are selected. If tx is not
+// signed, we assume each input will be signed with a signature
+// of the maximum size for the script it spends (in scripts).
+//
+// We also return the sequence number of each input and the
+// lock time so that callers can verify RBF signaling (BIP125)
+// and lock time enforcement. If tx is signed, we return the
+// hex-encoded scriptSig and witness of each input to help
+// diagnose problems assembling signatures.
+func parseTxMetadata(
+	tx *wire.MsgTx,
+	inputAmounts []string,
+	scripts []*whive.ScriptPubKey,
+	signed bool,
+) (map[string]interface{}, error) {
+	fee := new(big.Int)
+	for _, amount := range inputAmounts {
+		value, ok := new(big.Int).SetString(amount, 10)
+		if !ok {
+			return nil, fmt.Errorf("unable to parse input amount %s", amount)
+		}
+
+		fee.Add(fee, new(big.Int).Abs(value))
+	}
+
+	for _, output := range tx.TxOut {
+		fee.Sub(fee, big.NewInt(output.Value))
+	}
+
+	vsize := estimatedVsize(tx, scripts, signed)
+
+	sequences := make([]uint32, len(tx.TxIn))
+	signalsRBF := false
+	for i, input := range tx.TxIn {
+		sequences[i] = input.Sequence
+		if input.Sequence <= maxRBFSequence {
+			signalsRBF = true
+		}
+	}
+
+	metadata := &parseMetadata{
+		Fee:              fee.String(),
+		Vsize:            vsize,
+		EffectiveFeeRate: float64(fee.Int64()) / float64(vsize),
+		InputSequences:   sequences,
+		LockTime:         tx.LockTime,
+		SignalsRBF:       signalsRBF,
+	}
+
+	if signed {
+		metadata.InputScriptSigs = make([]string, len(tx.TxIn))
+		for i, input := range tx.TxIn {
+			metadata.InputScriptSigs[i] = hex.EncodeToString(input.SignatureScript)
+		}
+
+		if tx.HasWitness() {
+			metadata.InputWitnesses = make([][]string, len(tx.TxIn))
+			for i, input := range tx.TxIn {
+				metadata.InputWitnesses[i] = make([]string, len(input.Witness))
+				for j, item := range input.Witness {
+					metadata.InputWitnesses[i][j] = hex.EncodeToString(item)
+				}
+			}
+		}
+	}
+
+	return types.MarshalMap(metadata)
+}
+
+// estimatedVsize returns the virtual size of tx. If tx is not
+// signed, we assume each input will be signed with a signature
+// of the maximum size for the script it spends (in scripts).
+func estimatedVsize(tx *wire.MsgTx, scripts []*whive.ScriptPubKey, signed bool) int64 {
+	weight := int64(tx.SerializeSizeStripped()*(whive.WitnessScaleFactor-1) + tx.SerializeSize())
+	if !signed {
+		hasWitness := false
+		for i := range tx.TxIn {
+			scriptSigSize, witnessSize := spendSize(inputScriptClass(scripts, i))
+			weight += int64(scriptSigSize*whive.WitnessScaleFactor + witnessSize)
+			hasWitness = hasWitness || witnessSize > 0
+		}
+
+		if hasWitness {
+			weight += segwitMarkerSize
+		}
+	}
+
+	return (weight + whive.WitnessScaleFactor - 1) / whive.WitnessScaleFactor
+}
+
+// inputScriptClass returns the class of the script spent by
+// input i (in scripts) or P2WPKH if it isn't known.
+func inputScriptClass(scripts []*whive.ScriptPubKey, i int) txscript.ScriptClass {
+	if i >= len(scripts) || scripts[i] == nil {
+		return txscript.WitnessV0PubKeyHashTy
+	}
+
+	script, err := hex.DecodeString(scripts[i].Hex)
+	if err != nil {
+		return txscript.WitnessV0PubKeyHashTy
+	}
+
+	return txscript.GetScriptClass(script)
 }
 
 // ConstructionParse implements the /construction/parse endpoint.
@@ -750,6 +1744,23 @@ func (s *ConstructionAPIService) ConstructionParse(
 	return s.parseUnsignedTransaction(request)
 }
 
+// rejectReason returns the metrics reject reason
+// for an error returned when submitting a transaction.
+func rejectReason(err error) string {
+	switch {
+	case errors.Is(err, whive.ErrTransactionVerify):
+		return metrics.RejectReasonVerify
+	case errors.Is(err, whive.ErrTransactionRejected):
+		return metrics.RejectReasonPolicy
+	case errors.Is(err, whive.ErrTransactionAlreadyInChain):
+		return metrics.RejectReasonAlreadyInChain
+	case errors.Is(err, whive.ErrMempoolChainTooLong):
+		return metrics.RejectReasonMempoolChain
+	default:
+		return metrics.RejectReasonWhived
+	}
+}
+
 // ConstructionSubmit implements the /construction/submit endpoint.
 func (s *ConstructionAPIService) ConstructionSubmit(
 	ctx context.Context,
@@ -761,6 +1772,7 @@ func (s *ConstructionAPIService) ConstructionSubmit(
 
 	decodedTx, err := hex.DecodeString(request.SignedTransaction)
 	if err != nil {
+		metrics.TransactionRejected(metrics.RejectReasonInvalid)
 		return nil, wrapErr(
 			ErrUnableToParseIntermediateResult,
 			fmt.Errorf("%w signed transaction cannot be decoded", err),
@@ -769,6 +1781,7 @@ func (s *ConstructionAPIService) ConstructionSubmit(
 
 	var signed signedTransaction
 	if err := json.Unmarshal(decodedTx, &signed); err != nil {
+		metrics.TransactionRejected(metrics.RejectReasonInvalid)
 		return nil, wrapErr(
 			ErrUnableToParseIntermediateResult,
 			fmt.Errorf("%w unable to unmarshal signed whive transaction", err),
@@ -777,8 +1790,14 @@ func (s *ConstructionAPIService) ConstructionSubmit(
 
 	txHash, err := s.client.SendRawTransaction(ctx, signed.Transaction)
 	if err != nil {
+		metrics.TransactionRejected(rejectReason(err))
+		if errors.Is(err, whive.ErrMempoolChainTooLong) {
+			return nil, wrapErr(ErrMempoolChainTooLong, err)
+		}
+
 		return nil, wrapErr(ErrWhived, fmt.Errorf("%w unable to submit transaction", err))
 	}
+	metrics.ConstructionTransactions.WithLabelValues(metrics.OutcomeSubmitted).Inc()
 
 	return &types.TransactionIdentifierResponse{
 		TransactionIdentifier: &types.TransactionIdentifier{
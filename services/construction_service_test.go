@@ -15,15 +15,29 @@
 package services
 
 import (
+	"bytes"
 	"context"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/xyephy/rosetta-whive/configuration"
-	"github.com/xyephy/rosetta-whive/whive"
+	"github.com/xyephy/rosetta-whive/metrics"
 	mocks "github.com/xyephy/rosetta-whive/mocks/services"
+	"github.com/xyephy/rosetta-whive/whive"
 
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
 	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -152,8 +166,9 @@ func TestConstructionService(t *testing.T) {
 				},
 			},
 		},
-		EstimatedSize: 142,
-		FeeMultiplier: &feeMultiplier,
+		EstimatedSize:   142,
+		EstimatedWeight: 562,
+		FeeMultiplier:   &feeMultiplier,
 	}
 	assert.Equal(t, &types.ConstructionPreprocessResponse{
 		Options: forceMarshalMap(t, options),
@@ -172,6 +187,8 @@ func TestConstructionService(t *testing.T) {
 				},
 			},
 		},
+		Coins:           options.Coins,
+		EstimatedWeight: 562,
 	}
 
 	// Normal Fee
@@ -191,6 +208,7 @@ func TestConstructionService(t *testing.T) {
 		whive.MinFeeRate*10,
 		nil,
 	).Once()
+	mockClient.On("MempoolMinFeeRate", ctx).Return(whive.MinFeeRate, nil).Once()
 	metadataResponse, err := servicer.ConstructionMetadata(ctx, &types.ConstructionMetadataRequest{
 		NetworkIdentifier: networkIdentifier,
 		Options:           forceMarshalMap(t, options),
@@ -223,6 +241,7 @@ func TestConstructionService(t *testing.T) {
 		whive.MinFeeRate,
 		nil,
 	).Once()
+	mockClient.On("MempoolMinFeeRate", ctx).Return(whive.MinFeeRate, nil).Once()
 	metadataResponse, err = servicer.ConstructionMetadata(ctx, &types.ConstructionMetadataRequest{
 		NetworkIdentifier: networkIdentifier,
 		Options:           forceMarshalMap(t, options),
@@ -314,6 +333,29 @@ func TestConstructionService(t *testing.T) {
 		Payloads:            []*types.SigningPayload{signingPayload},
 	}, payloadsResponse)
 
+	// Payloads must spend exactly the coins selected in Metadata
+	mismatchedMetadata := &constructionMetadata{
+		ScriptPubKeys: metadata.ScriptPubKeys,
+		Coins: []*types.Coin{
+			{
+				CoinIdentifier: &types.CoinIdentifier{
+					Identifier: "b14157a5c50503c8cd202a173613dd27e0027343c3d50cf85852dd020bf59c7f:0",
+				},
+				Amount: &types.Amount{
+					Value:    "-1000000",
+					Currency: whive.TestnetCurrency,
+				},
+			},
+		},
+	}
+	payloadsResponse, err = servicer.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+		NetworkIdentifier: networkIdentifier,
+		Operations:        ops,
+		Metadata:          forceMarshalMap(t, mismatchedMetadata),
+	})
+	assert.Nil(t, payloadsResponse)
+	assert.Equal(t, ErrUnclearIntent.Code, err.Code)
+
 	// Test Parse Unsigned
 	parseUnsignedResponse, err := servicer.ConstructionParse(ctx, &types.ConstructionParseRequest{
 		NetworkIdentifier: networkIdentifier,
@@ -324,6 +366,12 @@ func TestConstructionService(t *testing.T) {
 	assert.Equal(t, &types.ConstructionParseResponse{
 		Operations:               parseOps,
 		AccountIdentifierSigners: []*types.AccountIdentifier{},
+		Metadata: forceMarshalMap(t, &parseMetadata{
+			Fee:              "500",
+			Vsize:            141,
+			EffectiveFeeRate: 500.0 / 141,
+			InputSequences:   []uint32{wire.MaxTxInSequenceNum},
+		}),
 	}, parseUnsignedResponse)
 
 	// Test Combine
@@ -348,6 +396,31 @@ func TestConstructionService(t *testing.T) {
 		SignedTransaction: signedRaw,
 	}, combineResponse)
 
+	// Combine rejects a public key that doesn't match the input
+	combineResponse, err = servicer.ConstructionCombine(ctx, &types.ConstructionCombineRequest{
+		NetworkIdentifier:   networkIdentifier,
+		UnsignedTransaction: unsignedRaw,
+		Signatures: []*types.Signature{
+			{
+				Bytes: forceHexDecode(
+					t,
+					"25876ec8b9f51d343a5a56ac549c0c828005ef45ebe9da166db645c09157223f4cd08b7278a8889a81135915bce10d1ef3bb92b217f81a0de7e79ffb3dfd6ac5", // nolint
+				),
+				SigningPayload: signingPayload,
+				PublicKey: &types.PublicKey{
+					Bytes: forceHexDecode(
+						t,
+						"0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798",
+					),
+					CurveType: types.Secp256k1,
+				},
+				SignatureType: types.Ecdsa,
+			},
+		},
+	})
+	assert.Nil(t, combineResponse)
+	assert.Equal(t, ErrPublicKeyMismatch.Code, err.Code)
+
 	// Test Parse Signed
 	parseSignedResponse, err := servicer.ConstructionParse(ctx, &types.ConstructionParseRequest{
 		NetworkIdentifier: networkIdentifier,
@@ -360,8 +433,39 @@ func TestConstructionService(t *testing.T) {
 		AccountIdentifierSigners: []*types.AccountIdentifier{
 			{Address: "tb1qcqzmqzkswhfshzd8kedhmtvgnxax48z4fklhvm"},
 		},
+		Metadata: forceMarshalMap(t, &parseMetadata{
+			Fee:              "500",
+			Vsize:            141,
+			EffectiveFeeRate: 500.0 / 141,
+			InputSequences:   []uint32{wire.MaxTxInSequenceNum},
+			InputScriptSigs:  []string{""},
+			InputWitnesses: [][]string{
+				{
+					"3044022025876ec8b9f51d343a5a56ac549c0c828005ef45ebe9da166db645c09157223f02204cd08b7278a8889a81135915bce10d1ef3bb92b217f81a0de7e79ffb3dfd6ac501", // nolint
+					"0325c9a4252789b31dbb3454ec647e9516e7c596bcde2bd5da71a60fab8644e438",
+				},
+			},
+		}),
 	}, parseSignedResponse)
 
+	// The effective fee rate is computed from the
+	// virtual size of the final transaction
+	var signed signedTransaction
+	assert.NoError(t, json.Unmarshal(forceHexDecode(t, signedRaw), &signed))
+	var finalTx wire.MsgTx
+	assert.NoError(t, finalTx.Deserialize(bytes.NewReader(forceHexDecode(t, signed.Transaction))))
+	finalWeight := blockchain.GetTransactionWeight(btcutil.NewTx(&finalTx))
+	assert.Equal(
+		t,
+		int64(141),
+		(finalWeight+blockchain.WitnessScaleFactor-1)/blockchain.WitnessScaleFactor,
+	)
+
+	// The weight estimated in Metadata is an upper bound
+	// of the final weight (signatures are at most 72 bytes).
+	assert.GreaterOrEqual(t, metadata.EstimatedWeight, finalWeight)
+	assert.InDelta(t, finalWeight, metadata.EstimatedWeight, 3)
+
 	// Test Hash
 	transactionIdentifier := &types.TransactionIdentifier{
 		Hash: "6d87ad0e26025128f5a8357fa423b340cbcffb9703f79f432f5520fca59cd20b",
@@ -397,3 +501,1980 @@ func TestConstructionService(t *testing.T) {
 	mockClient.AssertExpectations(t)
 	mockIndexer.AssertExpectations(t)
 }
+
+func TestConstructionService_ParseSequences(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode:     configuration.Offline,
+		Network:  networkIdentifier,
+		Params:   whive.TestnetParams,
+		Currency: whive.TestnetCurrency,
+	}
+
+	servicer := NewConstructionAPIService(cfg, &mocks.Client{}, &mocks.Indexer{})
+	ctx := context.Background()
+
+	// The first input signals RBF (BIP125) and the
+	// second input is final.
+	outputScript := forceHexDecode(t, "001488ce6925f8513a234c05c922ee933f2213230520")
+	hash, err := chainhash.NewHashFromStr(
+		"b14157a5c50503c8cd202a173613dd27e0027343c3d50cf85852dd020bf59c7f",
+	)
+	assert.NoError(t, err)
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.LockTime = 1000
+	tx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Hash: *hash, Index: 0},
+		Sequence:         wire.MaxTxInSequenceNum - 2,
+	})
+	tx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Hash: *hash, Index: 1},
+		Sequence:         wire.MaxTxInSequenceNum,
+	})
+	tx.AddTxOut(wire.NewTxOut(1999000, outputScript))
+	inputAmounts := []string{"-1000000", "-1000000"}
+	expectedSequences := []uint32{wire.MaxTxInSequenceNum - 2, wire.MaxTxInSequenceNum}
+
+	var unsignedTx bytes.Buffer
+	assert.NoError(t, tx.Serialize(&unsignedTx))
+	unsignedRaw, err := json.Marshal(&unsignedTransaction{
+		Transaction:  hex.EncodeToString(unsignedTx.Bytes()),
+		InputAmounts: inputAmounts,
+		InputAddresses: []string{
+			"tb1qcqzmqzkswhfshzd8kedhmtvgnxax48z4fklhvm",
+			"tb1qcqzmqzkswhfshzd8kedhmtvgnxax48z4fklhvm",
+		},
+	})
+	assert.NoError(t, err)
+
+	parseResponse, parseErr := servicer.ConstructionParse(ctx, &types.ConstructionParseRequest{
+		NetworkIdentifier: networkIdentifier,
+		Signed:            false,
+		Transaction:       hex.EncodeToString(unsignedRaw),
+	})
+	assert.Nil(t, parseErr)
+	var metadata parseMetadata
+	assert.NoError(t, types.UnmarshalMap(parseResponse.Metadata, &metadata))
+	assert.Equal(t, expectedSequences, metadata.InputSequences)
+	assert.Equal(t, uint32(1000), metadata.LockTime)
+	assert.True(t, metadata.SignalsRBF)
+
+	// Signing does not change the sequence numbers.
+	publicKey := forceHexDecode(
+		t,
+		"0325c9a4252789b31dbb3454ec647e9516e7c596bcde2bd5da71a60fab8644e438",
+	)
+	for _, input := range tx.TxIn {
+		input.Witness = wire.TxWitness{forceHexDecode(t, "3044"), publicKey}
+	}
+
+	var signedTx bytes.Buffer
+	assert.NoError(t, tx.Serialize(&signedTx))
+	signedRaw, err := json.Marshal(&signedTransaction{
+		Transaction:  hex.EncodeToString(signedTx.Bytes()),
+		InputAmounts: inputAmounts,
+	})
+	assert.NoError(t, err)
+
+	parseResponse, parseErr = servicer.ConstructionParse(ctx, &types.ConstructionParseRequest{
+		NetworkIdentifier: networkIdentifier,
+		Signed:            true,
+		Transaction:       hex.EncodeToString(signedRaw),
+	})
+	assert.Nil(t, parseErr)
+	metadata = parseMetadata{}
+	assert.NoError(t, types.UnmarshalMap(parseResponse.Metadata, &metadata))
+	assert.Equal(t, expectedSequences, metadata.InputSequences)
+	assert.Equal(t, uint32(1000), metadata.LockTime)
+	assert.True(t, metadata.SignalsRBF)
+}
+
+func TestConstructionService_ParseScriptSigs(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode:     configuration.Offline,
+		Network:  networkIdentifier,
+		Params:   whive.TestnetParams,
+		Currency: whive.TestnetCurrency,
+	}
+
+	servicer := NewConstructionAPIService(cfg, &mocks.Client{}, &mocks.Indexer{})
+	ctx := context.Background()
+
+	// A legacy (P2PKH) input is signed with a scriptSig
+	// pushing the signature and public key.
+	signature := forceHexDecode(
+		t,
+		"3044022025876ec8b9f51d343a5a56ac549c0c828005ef45ebe9da166db645c09157223f02204cd08b7278a8889a81135915bce10d1ef3bb92b217f81a0de7e79ffb3dfd6ac501", // nolint
+	)
+	publicKey := forceHexDecode(
+		t,
+		"0325c9a4252789b31dbb3454ec647e9516e7c596bcde2bd5da71a60fab8644e438",
+	)
+	scriptSig, err := txscript.NewScriptBuilder().AddData(signature).AddData(publicKey).Script()
+	assert.NoError(t, err)
+
+	hash, err := chainhash.NewHashFromStr(
+		"b14157a5c50503c8cd202a173613dd27e0027343c3d50cf85852dd020bf59c7f",
+	)
+	assert.NoError(t, err)
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Hash: *hash, Index: 0},
+		SignatureScript:  scriptSig,
+		Sequence:         wire.MaxTxInSequenceNum,
+	})
+	tx.AddTxOut(wire.NewTxOut(
+		999000,
+		forceHexDecode(t, "001488ce6925f8513a234c05c922ee933f2213230520"),
+	))
+
+	var signedTx bytes.Buffer
+	assert.NoError(t, tx.Serialize(&signedTx))
+	signedRaw, err := json.Marshal(&signedTransaction{
+		Transaction:  hex.EncodeToString(signedTx.Bytes()),
+		InputAmounts: []string{"-1000000"},
+	})
+	assert.NoError(t, err)
+
+	parseResponse, parseErr := servicer.ConstructionParse(ctx, &types.ConstructionParseRequest{
+		NetworkIdentifier: networkIdentifier,
+		Signed:            true,
+		Transaction:       hex.EncodeToString(signedRaw),
+	})
+	assert.Nil(t, parseErr)
+
+	address, err := btcutil.NewAddressPubKeyHash(
+		btcutil.Hash160(publicKey),
+		whive.TestnetParams,
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, []*types.AccountIdentifier{
+		{Address: address.EncodeAddress()},
+	}, parseResponse.AccountIdentifierSigners)
+
+	var metadata parseMetadata
+	assert.NoError(t, types.UnmarshalMap(parseResponse.Metadata, &metadata))
+	assert.Equal(t, []string{hex.EncodeToString(scriptSig)}, metadata.InputScriptSigs)
+	assert.Nil(t, metadata.InputWitnesses)
+
+	// The unsigned transaction does not include scriptSigs.
+	tx.TxIn[0].SignatureScript = nil
+	var unsignedTx bytes.Buffer
+	assert.NoError(t, tx.Serialize(&unsignedTx))
+	unsignedRaw, err := json.Marshal(&unsignedTransaction{
+		Transaction:    hex.EncodeToString(unsignedTx.Bytes()),
+		InputAmounts:   []string{"-1000000"},
+		InputAddresses: []string{address.EncodeAddress()},
+	})
+	assert.NoError(t, err)
+
+	parseResponse, parseErr = servicer.ConstructionParse(ctx, &types.ConstructionParseRequest{
+		NetworkIdentifier: networkIdentifier,
+		Signed:            false,
+		Transaction:       hex.EncodeToString(unsignedRaw),
+	})
+	assert.Nil(t, parseErr)
+	assert.NotContains(t, parseResponse.Metadata, "input_script_sigs")
+	assert.NotContains(t, parseResponse.Metadata, "input_witnesses")
+}
+
+func TestConstructionService_ChangeOutput(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode:     configuration.Offline,
+		Network:  networkIdentifier,
+		Params:   whive.TestnetParams,
+		Currency: whive.TestnetCurrency,
+	}
+
+	servicer := NewConstructionAPIService(cfg, &mocks.Client{}, &mocks.Indexer{})
+	ctx := context.Background()
+
+	// The second output returns change to the sender.
+	ops := []*types.Operation{
+		{
+			OperationIdentifier: &types.OperationIdentifier{
+				Index: 0,
+			},
+			Type: whive.InputOpType,
+			Account: &types.AccountIdentifier{
+				Address: "tb1qcqzmqzkswhfshzd8kedhmtvgnxax48z4fklhvm",
+			},
+			Amount: &types.Amount{
+				Value:    "-1000000",
+				Currency: whive.TestnetCurrency,
+			},
+			CoinChange: &types.CoinChange{
+				CoinIdentifier: &types.CoinIdentifier{
+					Identifier: "b14157a5c50503c8cd202a173613dd27e0027343c3d50cf85852dd020bf59c7f:1",
+				},
+				CoinAction: types.CoinSpent,
+			},
+		},
+		{
+			OperationIdentifier: &types.OperationIdentifier{
+				Index: 1,
+			},
+			Type: whive.OutputOpType,
+			Account: &types.AccountIdentifier{
+				Address: "tb1q3r8xjf0c2yazxnq9ey3wayelygfjxpfqjvj5v7",
+			},
+			Amount: &types.Amount{
+				Value:    "954843",
+				Currency: whive.TestnetCurrency,
+			},
+		},
+		{
+			OperationIdentifier: &types.OperationIdentifier{
+				Index: 2,
+			},
+			Type: whive.OutputOpType,
+			Account: &types.AccountIdentifier{
+				Address: "tb1qcqzmqzkswhfshzd8kedhmtvgnxax48z4fklhvm",
+			},
+			Amount: &types.Amount{
+				Value:    "44657",
+				Currency: whive.TestnetCurrency,
+			},
+			Metadata: map[string]interface{}{
+				"change": true,
+			},
+		},
+	}
+	metadata := &constructionMetadata{
+		ScriptPubKeys: []*whive.ScriptPubKey{
+			{
+				ASM:          "0 c005b00ad075d30b89a7b65b7dad8899ba6a9c55",
+				Hex:          "0014c005b00ad075d30b89a7b65b7dad8899ba6a9c55",
+				RequiredSigs: 1,
+				Type:         "witness_v0_keyhash",
+				Addresses: []string{
+					"tb1qcqzmqzkswhfshzd8kedhmtvgnxax48z4fklhvm",
+				},
+			},
+		},
+		Coins: []*types.Coin{
+			{
+				CoinIdentifier: ops[0].CoinChange.CoinIdentifier,
+				Amount:         ops[0].Amount,
+			},
+		},
+	}
+
+	payloadsResponse, err := servicer.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+		NetworkIdentifier: networkIdentifier,
+		Operations:        ops,
+		Metadata:          forceMarshalMap(t, metadata),
+	})
+	assert.Nil(t, err)
+
+	// assertChange asserts that only the
+	// second output is marked as change.
+	assertChange := func(parsedOps []*types.Operation) {
+		assert.Len(t, parsedOps, 3)
+		assert.Nil(t, parsedOps[0].Metadata)
+		assert.Nil(t, parsedOps[1].Metadata)
+		assert.Equal(t, forceMarshalMap(t, &ParseOperationMetadata{
+			Change: true,
+		}), parsedOps[2].Metadata)
+		assert.Equal(t, ops[2].Account, parsedOps[2].Account)
+		assert.Equal(t, int64(1), *parsedOps[2].OperationIdentifier.NetworkIndex)
+	}
+
+	parseResponse, err := servicer.ConstructionParse(ctx, &types.ConstructionParseRequest{
+		NetworkIdentifier: networkIdentifier,
+		Signed:            false,
+		Transaction:       payloadsResponse.UnsignedTransaction,
+	})
+	assert.Nil(t, err)
+	assertChange(parseResponse.Operations)
+
+	// The change output is still identified
+	// once the transaction is signed.
+	combineResponse, err := servicer.ConstructionCombine(ctx, &types.ConstructionCombineRequest{
+		NetworkIdentifier:   networkIdentifier,
+		UnsignedTransaction: payloadsResponse.UnsignedTransaction,
+		Signatures: []*types.Signature{
+			{
+				Bytes: forceHexDecode(
+					t,
+					"25876ec8b9f51d343a5a56ac549c0c828005ef45ebe9da166db645c09157223f4cd08b7278a8889a81135915bce10d1ef3bb92b217f81a0de7e79ffb3dfd6ac5", // nolint
+				),
+				SigningPayload: payloadsResponse.Payloads[0],
+				PublicKey: &types.PublicKey{
+					Bytes: forceHexDecode(
+						t,
+						"0325c9a4252789b31dbb3454ec647e9516e7c596bcde2bd5da71a60fab8644e438",
+					),
+					CurveType: types.Secp256k1,
+				},
+				SignatureType: types.Ecdsa,
+			},
+		},
+	})
+	assert.Nil(t, err)
+
+	parseResponse, err = servicer.ConstructionParse(ctx, &types.ConstructionParseRequest{
+		NetworkIdentifier: networkIdentifier,
+		Signed:            true,
+		Transaction:       combineResponse.SignedTransaction,
+	})
+	assert.Nil(t, err)
+	assertChange(parseResponse.Operations)
+}
+
+func TestConstructionService_StrictOperations(t *testing.T) {
+	ops := []*types.Operation{
+		{
+			OperationIdentifier: &types.OperationIdentifier{
+				Index: 0,
+			},
+			Type: whive.InputOpType,
+			Account: &types.AccountIdentifier{
+				Address: "tb1qcqzmqzkswhfshzd8kedhmtvgnxax48z4fklhvm",
+			},
+			Amount: &types.Amount{
+				Value:    "-1000000",
+				Currency: whive.TestnetCurrency,
+			},
+			CoinChange: &types.CoinChange{
+				CoinIdentifier: &types.CoinIdentifier{
+					Identifier: "b14157a5c50503c8cd202a173613dd27e0027343c3d50cf85852dd020bf59c7f:1",
+				},
+				CoinAction: types.CoinSpent,
+			},
+		},
+		{
+			OperationIdentifier: &types.OperationIdentifier{
+				Index: 1,
+			},
+			Type: whive.OutputOpType,
+			Account: &types.AccountIdentifier{
+				Address: "tb1q3r8xjf0c2yazxnq9ey3wayelygfjxpfqjvj5v7",
+			},
+			Amount: &types.Amount{
+				Value:    "954843",
+				Currency: whive.TestnetCurrency,
+			},
+		},
+		{
+			OperationIdentifier: &types.OperationIdentifier{
+				Index: 2,
+			},
+			Type: "FEE",
+			Account: &types.AccountIdentifier{
+				Address: "tb1qcqzmqzkswhfshzd8kedhmtvgnxax48z4fklhvm",
+			},
+			Amount: &types.Amount{
+				Value:    "-45157",
+				Currency: whive.TestnetCurrency,
+			},
+		},
+	}
+	metadata := &constructionMetadata{
+		ScriptPubKeys: []*whive.ScriptPubKey{
+			{
+				ASM:          "0 c005b00ad075d30b89a7b65b7dad8899ba6a9c55",
+				Hex:          "0014c005b00ad075d30b89a7b65b7dad8899ba6a9c55",
+				RequiredSigs: 1,
+				Type:         "witness_v0_keyhash",
+				Addresses: []string{
+					"tb1qcqzmqzkswhfshzd8kedhmtvgnxax48z4fklhvm",
+				},
+			},
+		},
+		Coins: []*types.Coin{
+			{
+				CoinIdentifier: ops[0].CoinChange.CoinIdentifier,
+				Amount:         ops[0].Amount,
+			},
+		},
+	}
+
+	tests := map[string]struct {
+		strict bool
+
+		expectedError string
+	}{
+		"lenient": {},
+		"strict": {
+			strict:        true,
+			expectedError: "operation 2 has unsupported type FEE",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := &configuration.Configuration{
+				Mode:             configuration.Offline,
+				Network:          networkIdentifier,
+				Params:           whive.TestnetParams,
+				Currency:         whive.TestnetCurrency,
+				StrictOperations: test.strict,
+			}
+			servicer := NewConstructionAPIService(cfg, &mocks.Client{}, &mocks.Indexer{})
+			ctx := context.Background()
+
+			payloadsResponse, err := servicer.ConstructionPayloads(
+				ctx,
+				&types.ConstructionPayloadsRequest{
+					NetworkIdentifier: networkIdentifier,
+					Operations:        ops,
+					Metadata:          forceMarshalMap(t, metadata),
+				},
+			)
+			if len(test.expectedError) > 0 {
+				assert.Nil(t, payloadsResponse)
+				assert.Equal(t, ErrUnclearIntent.Code, err.Code)
+				assert.Contains(t, err.Details["context"], test.expectedError)
+				return
+			}
+
+			// The unsupported operation is ignored.
+			assert.Nil(t, err)
+			parseResponse, err := servicer.ConstructionParse(ctx, &types.ConstructionParseRequest{
+				NetworkIdentifier: networkIdentifier,
+				Signed:            false,
+				Transaction:       payloadsResponse.UnsignedTransaction,
+			})
+			assert.Nil(t, err)
+			assert.Len(t, parseResponse.Operations, 2)
+		})
+	}
+}
+
+func TestConstructionService_MaxMetadataAge(t *testing.T) {
+	ops := []*types.Operation{
+		{
+			OperationIdentifier: &types.OperationIdentifier{
+				Index: 0,
+			},
+			Type: whive.InputOpType,
+			Account: &types.AccountIdentifier{
+				Address: "tb1qcqzmqzkswhfshzd8kedhmtvgnxax48z4fklhvm",
+			},
+			Amount: &types.Amount{
+				Value:    "-1000000",
+				Currency: whive.TestnetCurrency,
+			},
+			CoinChange: &types.CoinChange{
+				CoinIdentifier: &types.CoinIdentifier{
+					Identifier: "b14157a5c50503c8cd202a173613dd27e0027343c3d50cf85852dd020bf59c7f:1",
+				},
+				CoinAction: types.CoinSpent,
+			},
+		},
+		{
+			OperationIdentifier: &types.OperationIdentifier{
+				Index: 1,
+			},
+			Type: whive.OutputOpType,
+			Account: &types.AccountIdentifier{
+				Address: "tb1q3r8xjf0c2yazxnq9ey3wayelygfjxpfqjvj5v7",
+			},
+			Amount: &types.Amount{
+				Value:    "999000",
+				Currency: whive.TestnetCurrency,
+			},
+		},
+	}
+	scripts := []*whive.ScriptPubKey{
+		{
+			ASM:          "0 c005b00ad075d30b89a7b65b7dad8899ba6a9c55",
+			Hex:          "0014c005b00ad075d30b89a7b65b7dad8899ba6a9c55",
+			RequiredSigs: 1,
+			Type:         "witness_v0_keyhash",
+			Addresses: []string{
+				"tb1qcqzmqzkswhfshzd8kedhmtvgnxax48z4fklhvm",
+			},
+		},
+	}
+	coins := []*types.Coin{
+		{
+			CoinIdentifier: ops[0].CoinChange.CoinIdentifier,
+			Amount:         ops[0].Amount,
+		},
+	}
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	stale := now - (10 * time.Minute).Milliseconds()
+
+	// The transaction pays a fee of 1000 satoshis, which covers
+	// 1 satoshi per vbyte (0.00001 BTC/kB) but not 20 satoshis
+	// per vbyte (0.0002 BTC/kB).
+	tests := map[string]struct {
+		mode           configuration.Mode
+		maxMetadataAge time.Duration
+		timestamp      int64
+		feeRate        float64
+
+		expectedError *types.Error
+	}{
+		"age not checked": {
+			mode:      configuration.Offline,
+			timestamp: stale,
+		},
+		"fresh": {
+			mode:           configuration.Offline,
+			maxMetadataAge: 5 * time.Minute,
+			timestamp:      now,
+		},
+		"stale (offline)": {
+			mode:           configuration.Offline,
+			maxMetadataAge: 5 * time.Minute,
+			timestamp:      stale,
+			expectedError:  ErrMetadataStale,
+		},
+		"no timestamp (offline)": {
+			mode:           configuration.Offline,
+			maxMetadataAge: 5 * time.Minute,
+			expectedError:  ErrMetadataStale,
+		},
+		"stale (fee covers re-estimate)": {
+			mode:           configuration.Online,
+			maxMetadataAge: 5 * time.Minute,
+			timestamp:      stale,
+			feeRate:        0.00001,
+		},
+		"stale (fee below re-estimate)": {
+			mode:           configuration.Online,
+			maxMetadataAge: 5 * time.Minute,
+			timestamp:      stale,
+			feeRate:        0.0002,
+			expectedError:  ErrMetadataStale,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := &configuration.Configuration{
+				Mode:           test.mode,
+				Network:        networkIdentifier,
+				Params:         whive.TestnetParams,
+				Currency:       whive.TestnetCurrency,
+				MaxMetadataAge: test.maxMetadataAge,
+			}
+			mockClient := &mocks.Client{}
+			servicer := NewConstructionAPIService(cfg, mockClient, &mocks.Indexer{})
+			ctx := context.Background()
+
+			if test.feeRate > 0 {
+				mockClient.On(
+					"SuggestedFeeRate",
+					ctx,
+					defaultConfirmationTarget,
+				).Return(
+					test.feeRate,
+					nil,
+				).Once()
+				mockClient.On("MempoolMinFeeRate", ctx).Return(whive.MinFeeRate, nil).Once()
+			}
+
+			payloadsResponse, err := servicer.ConstructionPayloads(
+				ctx,
+				&types.ConstructionPayloadsRequest{
+					NetworkIdentifier: networkIdentifier,
+					Operations:        ops,
+					Metadata: forceMarshalMap(t, &constructionMetadata{
+						ScriptPubKeys: scripts,
+						Coins:         coins,
+						Timestamp:     test.timestamp,
+					}),
+				},
+			)
+			if test.expectedError != nil {
+				assert.Nil(t, payloadsResponse)
+				assert.Equal(t, test.expectedError.Code, err.Code)
+			} else {
+				assert.Nil(t, err)
+				assert.NotNil(t, payloadsResponse)
+			}
+
+			mockClient.AssertExpectations(t)
+		})
+	}
+
+	// /construction/metadata records when the fee was
+	// estimated if the age of metadata is checked.
+	cfg := &configuration.Configuration{
+		Mode:           configuration.Online,
+		Network:        networkIdentifier,
+		Params:         whive.TestnetParams,
+		Currency:       whive.TestnetCurrency,
+		MaxMetadataAge: 5 * time.Minute,
+	}
+	mockClient := &mocks.Client{}
+	mockIndexer := &mocks.Indexer{}
+	servicer := NewConstructionAPIService(cfg, mockClient, mockIndexer)
+	ctx := context.Background()
+
+	mockClient.On(
+		"SuggestedFeeRate",
+		ctx,
+		defaultConfirmationTarget,
+	).Return(
+		0.00001,
+		nil,
+	).Once()
+	mockClient.On("MempoolMinFeeRate", ctx).Return(whive.MinFeeRate, nil).Once()
+	mockIndexer.On("GetScriptPubKeys", ctx, coins).Return(scripts, nil).Once()
+
+	metadataResponse, err := servicer.ConstructionMetadata(ctx, &types.ConstructionMetadataRequest{
+		NetworkIdentifier: networkIdentifier,
+		Options: forceMarshalMap(t, &preprocessOptions{
+			Coins:         coins,
+			EstimatedSize: 142,
+		}),
+	})
+	assert.Nil(t, err)
+
+	var metadata constructionMetadata
+	assert.NoError(t, types.UnmarshalMap(metadataResponse.Metadata, &metadata))
+	assert.True(t, metadata.Timestamp >= now)
+	assert.True(t, metadata.Timestamp <= time.Now().UnixNano()/int64(time.Millisecond))
+
+	mockClient.AssertExpectations(t)
+	mockIndexer.AssertExpectations(t)
+}
+
+func TestConstructionService_MatchChangeType(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode:     configuration.Online,
+		Network:  networkIdentifier,
+		Params:   whive.TestnetParams,
+		Currency: whive.TestnetCurrency,
+	}
+
+	publicKey := &types.PublicKey{
+		Bytes: forceHexDecode(
+			t,
+			"0325c9a4252789b31dbb3454ec647e9516e7c596bcde2bd5da71a60fab8644e438",
+		),
+		CurveType: types.Secp256k1,
+	}
+	legacy := &whive.ScriptPubKey{
+		Hex:  "76a914c005b00ad075d30b89a7b65b7dad8899ba6a9c5588ac",
+		Type: whive.PubKeyHash,
+	}
+	segwit := &whive.ScriptPubKey{
+		Hex:  "0014c005b00ad075d30b89a7b65b7dad8899ba6a9c55",
+		Type: whive.WitnessV0PubKeyHash,
+	}
+	scriptHash := &whive.ScriptPubKey{
+		Hex:  "a914c005b00ad075d30b89a7b65b7dad8899ba6a9c5587",
+		Type: "scripthash",
+	}
+
+	tests := map[string]struct {
+		scripts []*whive.ScriptPubKey
+
+		expectedType    string
+		expectedAddress string
+	}{
+		"all legacy": {
+			scripts:         []*whive.ScriptPubKey{legacy, legacy},
+			expectedType:    whive.PubKeyHash,
+			expectedAddress: "my2Gr56HqNx2Z7QGtpw474g28ZS8rxB7Hj",
+		},
+		"all segwit": {
+			scripts:         []*whive.ScriptPubKey{segwit, segwit},
+			expectedType:    whive.WitnessV0PubKeyHash,
+			expectedAddress: "tb1qcqzmqzkswhfshzd8kedhmtvgnxax48z4fklhvm",
+		},
+		"mixed (mostly legacy)": {
+			scripts:         []*whive.ScriptPubKey{legacy, segwit, legacy},
+			expectedType:    whive.PubKeyHash,
+			expectedAddress: "my2Gr56HqNx2Z7QGtpw474g28ZS8rxB7Hj",
+		},
+		"mixed (mostly segwit)": {
+			scripts:         []*whive.ScriptPubKey{segwit, legacy, segwit},
+			expectedType:    whive.WitnessV0PubKeyHash,
+			expectedAddress: "tb1qcqzmqzkswhfshzd8kedhmtvgnxax48z4fklhvm",
+		},
+		"mixed (tie)": {
+			scripts:         []*whive.ScriptPubKey{legacy, segwit},
+			expectedType:    whive.WitnessV0PubKeyHash,
+			expectedAddress: "tb1qcqzmqzkswhfshzd8kedhmtvgnxax48z4fklhvm",
+		},
+		"script hash inputs ignored": {
+			scripts:         []*whive.ScriptPubKey{scriptHash, scriptHash, legacy},
+			expectedType:    whive.PubKeyHash,
+			expectedAddress: "my2Gr56HqNx2Z7QGtpw474g28ZS8rxB7Hj",
+		},
+		"only script hash inputs": {
+			scripts:         []*whive.ScriptPubKey{scriptHash},
+			expectedType:    whive.WitnessV0PubKeyHash,
+			expectedAddress: "tb1qcqzmqzkswhfshzd8kedhmtvgnxax48z4fklhvm",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockIndexer := &mocks.Indexer{}
+			mockClient := &mocks.Client{}
+			servicer := NewConstructionAPIService(cfg, mockClient, mockIndexer)
+			ctx := context.Background()
+
+			coins := make([]*types.Coin, len(test.scripts))
+			for i := range test.scripts {
+				coins[i] = &types.Coin{
+					CoinIdentifier: &types.CoinIdentifier{
+						Identifier: fmt.Sprintf(
+							"b14157a5c50503c8cd202a173613dd27e0027343c3d50cf85852dd020bf59c7f:%d",
+							i,
+						),
+					},
+					Amount: &types.Amount{
+						Value:    "-1000000",
+						Currency: whive.TestnetCurrency,
+					},
+				}
+			}
+
+			mockIndexer.On("GetScriptPubKeys", ctx, coins).Return(test.scripts, nil).Once()
+			mockClient.On(
+				"SuggestedFeeRate",
+				ctx,
+				defaultConfirmationTarget,
+			).Return(
+				whive.MinFeeRate,
+				nil,
+			).Once()
+			mockClient.On("MempoolMinFeeRate", ctx).Return(whive.MinFeeRate, nil).Once()
+			metadataResponse, err := servicer.ConstructionMetadata(
+				ctx,
+				&types.ConstructionMetadataRequest{
+					NetworkIdentifier: networkIdentifier,
+					Options: forceMarshalMap(t, &preprocessOptions{
+						Coins:           coins,
+						EstimatedSize:   142,
+						MatchChangeType: true,
+					}),
+				},
+			)
+			assert.Nil(t, err)
+
+			var metadata constructionMetadata
+			assert.NoError(t, types.UnmarshalMap(metadataResponse.Metadata, &metadata))
+			assert.Equal(t, test.expectedType, metadata.ChangeAddressType)
+
+			// The change address type can be used to
+			// derive the change address.
+			deriveResponse, err := servicer.ConstructionDerive(
+				ctx,
+				&types.ConstructionDeriveRequest{
+					NetworkIdentifier: networkIdentifier,
+					PublicKey:         publicKey,
+					Metadata: map[string]interface{}{
+						"address_type": metadata.ChangeAddressType,
+					},
+				},
+			)
+			assert.Nil(t, err)
+			assert.Equal(t, test.expectedAddress, deriveResponse.AccountIdentifier.Address)
+
+			// The change can be spent with /construction/payloads.
+			assert.True(t, signableScriptTypes[metadata.ChangeAddressType])
+
+			mockIndexer.AssertExpectations(t)
+			mockClient.AssertExpectations(t)
+		})
+	}
+
+	// The option is passed from /construction/preprocess.
+	servicer := NewConstructionAPIService(cfg, &mocks.Client{}, &mocks.Indexer{})
+	ctx := context.Background()
+	preprocessResponse, err := servicer.ConstructionPreprocess(
+		ctx,
+		&types.ConstructionPreprocessRequest{
+			NetworkIdentifier: networkIdentifier,
+			Operations: []*types.Operation{
+				{
+					OperationIdentifier: &types.OperationIdentifier{
+						Index: 0,
+					},
+					Type: whive.InputOpType,
+					Account: &types.AccountIdentifier{
+						Address: "tb1qcqzmqzkswhfshzd8kedhmtvgnxax48z4fklhvm",
+					},
+					Amount: &types.Amount{
+						Value:    "-1000000",
+						Currency: whive.TestnetCurrency,
+					},
+					CoinChange: &types.CoinChange{
+						CoinIdentifier: &types.CoinIdentifier{
+							Identifier: "b14157a5c50503c8cd202a173613dd27e0027343c3d50cf85852dd020bf59c7f:0",
+						},
+						CoinAction: types.CoinSpent,
+					},
+				},
+			},
+			Metadata: map[string]interface{}{
+				"match_change_type": true,
+			},
+		},
+	)
+	assert.Nil(t, err)
+	var options preprocessOptions
+	assert.NoError(t, types.UnmarshalMap(preprocessResponse.Options, &options))
+	assert.True(t, options.MatchChangeType)
+
+	// Unsupported address types cannot be derived.
+	deriveResponse, err := servicer.ConstructionDerive(ctx, &types.ConstructionDeriveRequest{
+		NetworkIdentifier: networkIdentifier,
+		PublicKey:         publicKey,
+		Metadata: map[string]interface{}{
+			"address_type": whive.WitnessV0ScriptHash,
+		},
+	})
+	assert.Nil(t, deriveResponse)
+	assert.Equal(t, ErrUnableToDerive.Code, err.Code)
+}
+
+func TestConstructionService_MempoolMinFeeRate(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode:     configuration.Online,
+		Network:  networkIdentifier,
+		Params:   whive.TestnetParams,
+		Currency: whive.TestnetCurrency,
+	}
+
+	coins := []*types.Coin{
+		{
+			CoinIdentifier: &types.CoinIdentifier{
+				Identifier: "b14157a5c50503c8cd202a173613dd27e0027343c3d50cf85852dd020bf59c7f:0",
+			},
+			Amount: &types.Amount{
+				Value:    "-1000000",
+				Currency: whive.TestnetCurrency,
+			},
+		},
+	}
+	scripts := []*whive.ScriptPubKey{
+		{
+			Hex:  "0014c005b00ad075d30b89a7b65b7dad8899ba6a9c55",
+			Type: whive.WitnessV0PubKeyHash,
+		},
+	}
+
+	tests := map[string]struct {
+		suggestedFeeRate  float64
+		mempoolMinFeeRate float64
+		mempoolErr        error
+
+		expectedFee   string
+		expectedError *types.Error
+	}{
+		"mempool minimum below estimate": {
+			suggestedFeeRate:  whive.MinFeeRate * 10,
+			mempoolMinFeeRate: whive.MinFeeRate,
+			expectedFee:       "1420",
+		},
+		"elevated mempool minimum": {
+			suggestedFeeRate:  whive.MinFeeRate,
+			mempoolMinFeeRate: whive.MinFeeRate * 4,
+			expectedFee:       "568",
+		},
+		"mempool minimum unavailable": {
+			suggestedFeeRate: whive.MinFeeRate,
+			mempoolErr:       errors.New("unable to get mempool info"),
+			expectedError:    ErrCouldNotGetFeeRate,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockIndexer := &mocks.Indexer{}
+			mockClient := &mocks.Client{}
+			servicer := NewConstructionAPIService(cfg, mockClient, mockIndexer)
+			ctx := context.Background()
+
+			mockClient.On(
+				"SuggestedFeeRate",
+				ctx,
+				defaultConfirmationTarget,
+			).Return(
+				test.suggestedFeeRate,
+				nil,
+			).Once()
+			mockClient.On(
+				"MempoolMinFeeRate",
+				ctx,
+			).Return(
+				test.mempoolMinFeeRate,
+				test.mempoolErr,
+			).Once()
+			if test.expectedError == nil {
+				mockIndexer.On("GetScriptPubKeys", ctx, coins).Return(scripts, nil).Once()
+			}
+
+			metadataResponse, err := servicer.ConstructionMetadata(
+				ctx,
+				&types.ConstructionMetadataRequest{
+					NetworkIdentifier: networkIdentifier,
+					Options: forceMarshalMap(t, &preprocessOptions{
+						Coins:         coins,
+						EstimatedSize: 142,
+					}),
+				},
+			)
+			if test.expectedError != nil {
+				assert.Equal(t, test.expectedError.Code, err.Code)
+				assert.Nil(t, metadataResponse)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, []*types.Amount{
+					{
+						Value:    test.expectedFee,
+						Currency: whive.TestnetCurrency,
+					},
+				}, metadataResponse.SuggestedFee)
+			}
+
+			mockIndexer.AssertExpectations(t)
+			mockClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestConstructionService_FeeEstimation(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode:               configuration.Online,
+		Network:            networkIdentifier,
+		Params:             whive.TestnetParams,
+		Currency:           whive.TestnetCurrency,
+		ConfirmationTarget: 6,
+		MinFeeRate:         whive.MinFeeRate * 3,
+	}
+
+	coins := []*types.Coin{
+		{
+			CoinIdentifier: &types.CoinIdentifier{
+				Identifier: "b14157a5c50503c8cd202a173613dd27e0027343c3d50cf85852dd020bf59c7f:0",
+			},
+			Amount: &types.Amount{
+				Value:    "-1000000",
+				Currency: whive.TestnetCurrency,
+			},
+		},
+	}
+	scripts := []*whive.ScriptPubKey{
+		{
+			Hex:  "0014c005b00ad075d30b89a7b65b7dad8899ba6a9c55",
+			Type: whive.WitnessV0PubKeyHash,
+		},
+	}
+
+	tests := map[string]struct {
+		suggestedFeeRate float64
+
+		expectedFee string
+	}{
+		"estimate above minimum": {
+			suggestedFeeRate: whive.MinFeeRate * 10,
+			expectedFee:      "1420",
+		},
+		"estimate below minimum": {
+			suggestedFeeRate: whive.MinFeeRate,
+			expectedFee:      "426",
+		},
+		"no estimate": {
+			suggestedFeeRate: 0,
+			expectedFee:      "426",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockIndexer := &mocks.Indexer{}
+			mockClient := &mocks.Client{}
+			servicer := NewConstructionAPIService(cfg, mockClient, mockIndexer)
+			ctx := context.Background()
+
+			mockClient.On(
+				"SuggestedFeeRate",
+				ctx,
+				int64(6),
+			).Return(
+				test.suggestedFeeRate,
+				nil,
+			).Once()
+			mockClient.On("MempoolMinFeeRate", ctx).Return(whive.MinFeeRate, nil).Once()
+			mockIndexer.On("GetScriptPubKeys", ctx, coins).Return(scripts, nil).Once()
+
+			metadataResponse, err := servicer.ConstructionMetadata(
+				ctx,
+				&types.ConstructionMetadataRequest{
+					NetworkIdentifier: networkIdentifier,
+					Options: forceMarshalMap(t, &preprocessOptions{
+						Coins:         coins,
+						EstimatedSize: 142,
+					}),
+				},
+			)
+			assert.Nil(t, err)
+			assert.Equal(t, []*types.Amount{
+				{
+					Value:    test.expectedFee,
+					Currency: whive.TestnetCurrency,
+				},
+			}, metadataResponse.SuggestedFee)
+
+			mockIndexer.AssertExpectations(t)
+			mockClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestConstructionService_DeriveCompression(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode:     configuration.Offline,
+		Network:  networkIdentifier,
+		Params:   whive.TestnetParams,
+		Currency: whive.TestnetCurrency,
+	}
+	servicer := NewConstructionAPIService(cfg, nil, nil)
+	ctx := context.Background()
+
+	// The compressed and uncompressed encodings of the same key.
+	compressedKey := forceHexDecode(
+		t,
+		"0325c9a4252789b31dbb3454ec647e9516e7c596bcde2bd5da71a60fab8644e438",
+	)
+	uncompressedKey := forceHexDecode(
+		t,
+		"0425c9a4252789b31dbb3454ec647e9516e7c596bcde2bd5da71a60fab8644e438"+
+			"9712f478916e548ffe06e805339c5f72d225b6e838791175c57500ab0dd0c8f1",
+	)
+
+	tests := map[string]struct {
+		key       []byte
+		curveType types.CurveType
+		metadata  map[string]interface{}
+
+		expectedAddress string
+		expectedError   *types.Error
+	}{
+		"compressed (default)": {
+			key:             compressedKey,
+			expectedAddress: "tb1qcqzmqzkswhfshzd8kedhmtvgnxax48z4fklhvm",
+		},
+		"compressed pubkeyhash": {
+			key: compressedKey,
+			metadata: map[string]interface{}{
+				"address_type": whive.PubKeyHash,
+				"compressed":   true,
+			},
+			expectedAddress: "my2Gr56HqNx2Z7QGtpw474g28ZS8rxB7Hj",
+		},
+		"uncompressed": {
+			key: compressedKey,
+			metadata: map[string]interface{}{
+				"compressed": false,
+			},
+			expectedAddress: "mx2W3xzxyqLdSFt2SaqFNHTAUq7yGdCYn1",
+		},
+		"uncompressed key bytes": {
+			key: uncompressedKey,
+			metadata: map[string]interface{}{
+				"compressed": false,
+			},
+			expectedAddress: "mx2W3xzxyqLdSFt2SaqFNHTAUq7yGdCYn1",
+		},
+		"uncompressed key bytes (compressed)": {
+			key: uncompressedKey,
+			metadata: map[string]interface{}{
+				"address_type": whive.PubKeyHash,
+			},
+			expectedAddress: "my2Gr56HqNx2Z7QGtpw474g28ZS8rxB7Hj",
+		},
+		"uncompressed witness": {
+			key: compressedKey,
+			metadata: map[string]interface{}{
+				"address_type": whive.WitnessV0PubKeyHash,
+				"compressed":   false,
+			},
+			expectedError: ErrUnableToDerive,
+		},
+		"p2sh-wrapped witness": {
+			key: compressedKey,
+			metadata: map[string]interface{}{
+				"address_type": whive.ScriptHash,
+			},
+			expectedAddress: "2NCPp7bYWZmApY1kz79VqnA9hhMQBJs3stJ",
+		},
+		"uncompressed p2sh-wrapped witness": {
+			key: uncompressedKey,
+			metadata: map[string]interface{}{
+				"address_type": whive.ScriptHash,
+				"compressed":   false,
+			},
+			expectedError: ErrUnableToDerive,
+		},
+		"invalid key": {
+			key:           []byte("not a key"),
+			expectedError: ErrUnableToDerive,
+		},
+		"invalid key length": {
+			key:           compressedKey[:32],
+			expectedError: ErrUnableToDerive,
+		},
+		"unsupported curve": {
+			key:           compressedKey,
+			curveType:     types.Edwards25519,
+			expectedError: ErrUnableToDerive,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			curveType := test.curveType
+			if len(curveType) == 0 {
+				curveType = types.Secp256k1
+			}
+
+			deriveResponse, err := servicer.ConstructionDerive(ctx, &types.ConstructionDeriveRequest{
+				NetworkIdentifier: networkIdentifier,
+				PublicKey: &types.PublicKey{
+					Bytes:     test.key,
+					CurveType: curveType,
+				},
+				Metadata: test.metadata,
+			})
+			if test.expectedError != nil {
+				assert.Nil(t, deriveResponse)
+				assert.Equal(t, test.expectedError.Code, err.Code)
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Equal(t, test.expectedAddress, deriveResponse.AccountIdentifier.Address)
+		})
+	}
+}
+
+// signPayload signs payload with privateKey and returns
+// the signature in the R || S form Rosetta signers return.
+func signPayload(t *testing.T, privateKey *btcec.PrivateKey, payload []byte) []byte {
+	signature, err := privateKey.Sign(payload)
+	assert.NoError(t, err)
+
+	r := signature.R.Bytes()
+	s := signature.S.Bytes()
+	signatureBytes := make([]byte, 64)
+	copy(signatureBytes[32-len(r):32], r)
+	copy(signatureBytes[64-len(s):], s)
+
+	return signatureBytes
+}
+
+func TestConstructionService_SpendDerivedAddresses(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode:     configuration.Offline,
+		Network:  networkIdentifier,
+		Params:   whive.TestnetParams,
+		Currency: whive.TestnetCurrency,
+	}
+	servicer := NewConstructionAPIService(cfg, nil, nil)
+	ctx := context.Background()
+
+	privateKey, publicKey := btcec.PrivKeyFromBytes(
+		btcec.S256(),
+		bytes.Repeat([]byte{0x01}, 32),
+	)
+	coinIdentifier := &types.CoinIdentifier{
+		Identifier: "b14157a5c50503c8cd202a173613dd27e0027343c3d50cf85852dd020bf59c7f:0",
+	}
+	inputAmount := &types.Amount{
+		Value:    "-1000000",
+		Currency: whive.TestnetCurrency,
+	}
+
+	tests := map[string]struct {
+		key      []byte
+		metadata map[string]interface{}
+
+		// signerKey is the key returned with the signature
+		// (if it differs from the derived key).
+		signerKey []byte
+
+		// The unsigned size assumes a signature of the
+		// maximum size (and an uncompressed public key
+		// for P2PKH inputs).
+		maxOverestimate int64
+
+		expectedScriptType string
+	}{
+		"witness pubkeyhash": {
+			key:                publicKey.SerializeCompressed(),
+			maxOverestimate:    1,
+			expectedScriptType: whive.WitnessV0PubKeyHash,
+		},
+		"p2sh-wrapped witness": {
+			key: publicKey.SerializeCompressed(),
+			metadata: map[string]interface{}{
+				"address_type": whive.ScriptHash,
+			},
+			maxOverestimate:    1,
+			expectedScriptType: whive.ScriptHash,
+		},
+		"compressed pubkeyhash": {
+			key: publicKey.SerializeCompressed(),
+			metadata: map[string]interface{}{
+				"address_type": whive.PubKeyHash,
+			},
+			maxOverestimate:    33,
+			expectedScriptType: whive.PubKeyHash,
+		},
+		"uncompressed pubkeyhash": {
+			key:                publicKey.SerializeUncompressed(),
+			metadata:           map[string]interface{}{"compressed": false},
+			maxOverestimate:    1,
+			expectedScriptType: whive.PubKeyHash,
+		},
+		"uncompressed pubkeyhash (compressed signer key)": {
+			key:                publicKey.SerializeUncompressed(),
+			metadata:           map[string]interface{}{"compressed": false},
+			signerKey:          publicKey.SerializeCompressed(),
+			maxOverestimate:    1,
+			expectedScriptType: whive.PubKeyHash,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			derivedKey := &types.PublicKey{
+				Bytes:     test.key,
+				CurveType: types.Secp256k1,
+			}
+			signerKey := derivedKey
+			if test.signerKey != nil {
+				signerKey = &types.PublicKey{
+					Bytes:     test.signerKey,
+					CurveType: types.Secp256k1,
+				}
+			}
+
+			deriveResponse, rErr := servicer.ConstructionDerive(ctx, &types.ConstructionDeriveRequest{
+				NetworkIdentifier: networkIdentifier,
+				PublicKey:         derivedKey,
+				Metadata:          test.metadata,
+			})
+			assert.Nil(t, rErr)
+			address := deriveResponse.AccountIdentifier.Address
+
+			addr, err := btcutil.DecodeAddress(address, cfg.Params)
+			assert.NoError(t, err)
+			pkScript, err := txscript.PayToAddrScript(addr)
+			assert.NoError(t, err)
+
+			payloadsRequest := &types.ConstructionPayloadsRequest{
+				NetworkIdentifier: networkIdentifier,
+				Operations: []*types.Operation{
+					{
+						OperationIdentifier: &types.OperationIdentifier{
+							Index: 0,
+						},
+						Type: whive.InputOpType,
+						Account: &types.AccountIdentifier{
+							Address: address,
+						},
+						Amount: inputAmount,
+						CoinChange: &types.CoinChange{
+							CoinIdentifier: coinIdentifier,
+							CoinAction:     types.CoinSpent,
+						},
+					},
+					{
+						OperationIdentifier: &types.OperationIdentifier{
+							Index: 1,
+						},
+						Type: whive.OutputOpType,
+						Account: &types.AccountIdentifier{
+							Address: "tb1qcqzmqzkswhfshzd8kedhmtvgnxax48z4fklhvm",
+						},
+						Amount: &types.Amount{
+							Value:    "990000",
+							Currency: whive.TestnetCurrency,
+						},
+					},
+				},
+				Metadata: forceMarshalMap(t, &constructionMetadata{
+					ScriptPubKeys: []*whive.ScriptPubKey{
+						{
+							Hex:  hex.EncodeToString(pkScript),
+							Type: test.expectedScriptType,
+						},
+					},
+					Coins: []*types.Coin{
+						{
+							CoinIdentifier: coinIdentifier,
+							Amount:         inputAmount,
+						},
+					},
+				}),
+				PublicKeys: []*types.PublicKey{signerKey},
+			}
+			payloadsResponse, rErr := servicer.ConstructionPayloads(ctx, payloadsRequest)
+			assert.Nil(t, rErr)
+			assert.Len(t, payloadsResponse.Payloads, 1)
+			payload := payloadsResponse.Payloads[0]
+			assert.Equal(t, address, payload.AccountIdentifier.Address)
+
+			combineResponse, rErr := servicer.ConstructionCombine(ctx, &types.ConstructionCombineRequest{
+				NetworkIdentifier:   networkIdentifier,
+				UnsignedTransaction: payloadsResponse.UnsignedTransaction,
+				Signatures: []*types.Signature{
+					{
+						SigningPayload: payload,
+						PublicKey:      signerKey,
+						SignatureType:  types.Ecdsa,
+						Bytes:          signPayload(t, privateKey, payload.Bytes),
+					},
+				},
+			})
+			assert.Nil(t, rErr)
+
+			// The signed transaction spends the coin.
+			var signed signedTransaction
+			assert.NoError(t, json.Unmarshal(
+				forceHexDecode(t, combineResponse.SignedTransaction),
+				&signed,
+			))
+			var tx wire.MsgTx
+			assert.NoError(t, tx.Deserialize(bytes.NewReader(forceHexDecode(t, signed.Transaction))))
+			vm, err := txscript.NewEngine(
+				pkScript,
+				&tx,
+				0,
+				txscript.StandardVerifyFlags,
+				nil,
+				txscript.NewTxSigHashes(&tx),
+				1000000,
+			)
+			assert.NoError(t, err)
+			assert.NoError(t, vm.Execute())
+
+			// The signer is the derived address and the
+			// size estimated before signing is not below
+			// the signed size.
+			parseSigned, rErr := servicer.ConstructionParse(ctx, &types.ConstructionParseRequest{
+				NetworkIdentifier: networkIdentifier,
+				Signed:            true,
+				Transaction:       combineResponse.SignedTransaction,
+			})
+			assert.Nil(t, rErr)
+			assert.Equal(t, []*types.AccountIdentifier{
+				{Address: address},
+			}, parseSigned.AccountIdentifierSigners)
+
+			parseUnsigned, rErr := servicer.ConstructionParse(ctx, &types.ConstructionParseRequest{
+				NetworkIdentifier: networkIdentifier,
+				Signed:            false,
+				Transaction:       payloadsResponse.UnsignedTransaction,
+			})
+			assert.Nil(t, rErr)
+
+			var signedMetadata, unsignedMetadata parseMetadata
+			assert.NoError(t, types.UnmarshalMap(parseSigned.Metadata, &signedMetadata))
+			assert.NoError(t, types.UnmarshalMap(parseUnsigned.Metadata, &unsignedMetadata))
+			assert.GreaterOrEqual(t, unsignedMetadata.Vsize, signedMetadata.Vsize)
+			assert.LessOrEqual(t, unsignedMetadata.Vsize-signedMetadata.Vsize, test.maxOverestimate)
+
+			// The redeem script of a P2SH input can't be
+			// computed without the signer's public key.
+			if test.expectedScriptType == whive.ScriptHash {
+				payloadsRequest.PublicKeys = nil
+				_, rErr = servicer.ConstructionPayloads(ctx, payloadsRequest)
+				assert.Equal(t, ErrPublicKeyMismatch.Code, rErr.Code)
+			}
+		})
+	}
+}
+
+func TestConstructionService_DeriveInactiveAddressType(t *testing.T) {
+	ctx := context.Background()
+	publicKey := &types.PublicKey{
+		Bytes: forceHexDecode(
+			t,
+			"0325c9a4252789b31dbb3454ec647e9516e7c596bcde2bd5da71a60fab8644e438",
+		),
+		CurveType: types.Secp256k1,
+	}
+
+	// Segwit is deployed on every network.
+	for _, params := range []*chaincfg.Params{
+		whive.MainnetParams,
+		whive.TestnetParams,
+		whive.RegtestParams,
+	} {
+		assert.NoError(t, checkAddressType(params, whive.WitnessV0PubKeyHash))
+		assert.NoError(t, checkAddressType(params, whive.ScriptHash))
+		assert.NoError(t, checkAddressType(params, whive.PubKeyHash))
+	}
+
+	// Witness addresses can't be derived on
+	// a network without a segwit deployment.
+	noSegwit := *whive.RegtestParams
+	noSegwit.Deployments[chaincfg.DeploymentSegwit] = chaincfg.ConsensusDeployment{}
+	assert.EqualError(
+		t,
+		checkAddressType(&noSegwit, whive.ScriptHash),
+		"address type scripthash is not active on regtest",
+	)
+	assert.NoError(t, checkAddressType(&noSegwit, whive.PubKeyHash))
+
+	// Deriving taproot addresses is not supported
+	// (whether or not taproot is deployed).
+	for _, params := range []*chaincfg.Params{
+		whive.TestnetParams,
+		&chaincfg.SigNetParams,
+	} {
+		servicer := NewConstructionAPIService(&configuration.Configuration{
+			Mode:     configuration.Offline,
+			Network:  networkIdentifier,
+			Params:   params,
+			Currency: whive.TestnetCurrency,
+		}, nil, nil)
+		deriveResponse, err := servicer.ConstructionDerive(ctx, &types.ConstructionDeriveRequest{
+			NetworkIdentifier: networkIdentifier,
+			PublicKey:         publicKey,
+			Metadata: map[string]interface{}{
+				"address_type": whive.WitnessV1Taproot,
+			},
+		})
+		assert.Nil(t, deriveResponse)
+		assert.Equal(t, ErrUnableToDerive.Code, err.Code)
+		assert.Equal(t, map[string]interface{}{
+			"context": "address type witness_v1_taproot is not supported",
+		}, err.Details)
+	}
+}
+
+func TestConstructionService_HashSegwit(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode:     configuration.Offline,
+		Network:  networkIdentifier,
+		Params:   whive.TestnetParams,
+		Currency: whive.TestnetCurrency,
+	}
+
+	mockIndexer := &mocks.Indexer{}
+	mockClient := &mocks.Client{}
+	servicer := NewConstructionAPIService(cfg, mockClient, mockIndexer)
+	ctx := context.Background()
+
+	txid := "6d87ad0e26025128f5a8357fa423b340cbcffb9703f79f432f5520fca59cd20b"
+	segwitTx := "010000000001017f9cf50b02dd5258f80cd5c3437302e027dd1336172a20cdc80305c5a55741b10100000000ffffffff02db910e000000000016001488ce6925f8513a234c05c922ee933f221323052071ae000000000000160014940726595c41fca0b4810c62991ad9d289eeb82802473044022025876ec8b9f51d343a5a56ac549c0c828005ef45ebe9da166db645c09157223f02204cd08b7278a8889a81135915bce10d1ef3bb92b217f81a0de7e79ffb3dfd6ac501210325c9a4252789b31dbb3454ec647e9516e7c596bcde2bd5da71a60fab8644e43800000000" // nolint
+
+	var tx wire.MsgTx
+	assert.NoError(t, tx.Deserialize(bytes.NewReader(forceHexDecode(t, segwitTx))))
+	assert.NotEqual(t, txid, tx.WitnessHash().String())
+
+	var legacyTx bytes.Buffer
+	assert.NoError(t, tx.SerializeNoWitness(&legacyTx))
+
+	signed := func(rawTx string) string {
+		signedTx, err := json.Marshal(&signedTransaction{
+			Transaction:  rawTx,
+			InputAmounts: []string{"-1000000"},
+		})
+		assert.NoError(t, err)
+		return hex.EncodeToString(signedTx)
+	}
+
+	// The txid (not the wtxid) is returned for
+	// serializations with and without witness data.
+	for _, rawTx := range []string{segwitTx, hex.EncodeToString(legacyTx.Bytes())} {
+		hashResponse, err := servicer.ConstructionHash(ctx, &types.ConstructionHashRequest{
+			NetworkIdentifier: networkIdentifier,
+			SignedTransaction: signed(rawTx),
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, &types.TransactionIdentifierResponse{
+			TransactionIdentifier: &types.TransactionIdentifier{Hash: txid},
+		}, hashResponse)
+	}
+
+	mockIndexer.AssertExpectations(t)
+	mockClient.AssertExpectations(t)
+}
+
+func TestConstructionService_TransactionSize(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode:         configuration.Offline,
+		Network:      networkIdentifier,
+		Params:       whive.TestnetParams,
+		Currency:     whive.TestnetCurrency,
+		MaxTxOutputs: 2,
+	}
+
+	mockIndexer := &mocks.Indexer{}
+	mockClient := &mocks.Client{}
+	servicer := NewConstructionAPIService(cfg, mockClient, mockIndexer)
+	ctx := context.Background()
+
+	operations := func(outputs int) []*types.Operation {
+		ops := []*types.Operation{
+			{
+				OperationIdentifier: &types.OperationIdentifier{
+					Index: 0,
+				},
+				Type: whive.InputOpType,
+				Account: &types.AccountIdentifier{
+					Address: "tb1qcqzmqzkswhfshzd8kedhmtvgnxax48z4fklhvm",
+				},
+				Amount: &types.Amount{
+					Value:    "-1000000",
+					Currency: whive.TestnetCurrency,
+				},
+				CoinChange: &types.CoinChange{
+					CoinIdentifier: &types.CoinIdentifier{
+						Identifier: "b14157a5c50503c8cd202a173613dd27e0027343c3d50cf85852dd020bf59c7f:1",
+					},
+					CoinAction: types.CoinSpent,
+				},
+			},
+		}
+
+		for i := 0; i < outputs; i++ {
+			ops = append(ops, &types.Operation{
+				OperationIdentifier: &types.OperationIdentifier{
+					Index: int64(i + 1),
+				},
+				Type: whive.OutputOpType,
+				Account: &types.AccountIdentifier{
+					Address: "tb1q3r8xjf0c2yazxnq9ey3wayelygfjxpfqjvj5v7",
+				},
+				Amount: &types.Amount{
+					Value:    "1000",
+					Currency: whive.TestnetCurrency,
+				},
+			})
+		}
+
+		return ops
+	}
+
+	// At the output limit
+	preprocessResponse, err := servicer.ConstructionPreprocess(
+		ctx,
+		&types.ConstructionPreprocessRequest{
+			NetworkIdentifier: networkIdentifier,
+			Operations:        operations(2),
+		},
+	)
+	assert.Nil(t, err)
+	assert.NotNil(t, preprocessResponse)
+
+	// Over the output limit
+	preprocessResponse, err = servicer.ConstructionPreprocess(
+		ctx,
+		&types.ConstructionPreprocessRequest{
+			NetworkIdentifier: networkIdentifier,
+			Operations:        operations(3),
+		},
+	)
+	assert.Nil(t, preprocessResponse)
+	assert.Equal(t, ErrTransactionTooLarge.Code, err.Code)
+	assert.Contains(t, err.Details["context"], "3 outputs")
+
+	// Over the standard weight (without an output limit)
+	cfg.MaxTxOutputs = 0
+	preprocessResponse, err = servicer.ConstructionPreprocess(
+		ctx,
+		&types.ConstructionPreprocessRequest{
+			NetworkIdentifier: networkIdentifier,
+			Operations:        operations(5000),
+		},
+	)
+	assert.Nil(t, preprocessResponse)
+	assert.Equal(t, ErrTransactionTooLarge.Code, err.Code)
+	assert.Contains(t, err.Details["context"], "estimated weight")
+
+	mockIndexer.AssertExpectations(t)
+	mockClient.AssertExpectations(t)
+}
+
+func TestConstructionService_ExplicitInputs(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode:     configuration.Online,
+		Network:  networkIdentifier,
+		Params:   whive.TestnetParams,
+		Currency: whive.TestnetCurrency,
+	}
+
+	mockIndexer := &mocks.Indexer{}
+	mockClient := &mocks.Client{}
+	servicer := NewConstructionAPIService(cfg, mockClient, mockIndexer)
+	ctx := context.Background()
+
+	account := &types.AccountIdentifier{
+		Address: "tb1qcqzmqzkswhfshzd8kedhmtvgnxax48z4fklhvm",
+	}
+	coinIdentifier := "b14157a5c50503c8cd202a173613dd27e0027343c3d50cf85852dd020bf59c7f:1"
+	operations := func(output string) []*types.Operation {
+		return []*types.Operation{
+			{
+				OperationIdentifier: &types.OperationIdentifier{
+					Index: 0,
+				},
+				Type:    whive.InputOpType,
+				Account: account,
+				Amount: &types.Amount{
+					Value:    "-1000000",
+					Currency: whive.TestnetCurrency,
+				},
+				CoinChange: &types.CoinChange{
+					CoinIdentifier: &types.CoinIdentifier{
+						Identifier: coinIdentifier,
+					},
+					CoinAction: types.CoinSpent,
+				},
+			},
+			{
+				OperationIdentifier: &types.OperationIdentifier{
+					Index: 1,
+				},
+				Type: whive.OutputOpType,
+				Account: &types.AccountIdentifier{
+					Address: "tb1q3r8xjf0c2yazxnq9ey3wayelygfjxpfqjvj5v7",
+				},
+				Amount: &types.Amount{
+					Value:    output,
+					Currency: whive.TestnetCurrency,
+				},
+			},
+		}
+	}
+	ownedCoins := []*types.Coin{
+		{
+			CoinIdentifier: &types.CoinIdentifier{
+				Identifier: coinIdentifier,
+			},
+			Amount: &types.Amount{
+				Value:    "1000000",
+				Currency: whive.TestnetCurrency,
+			},
+		},
+	}
+
+	// Inputs that don't match the operations are rejected
+	preprocessResponse, err := servicer.ConstructionPreprocess(
+		ctx,
+		&types.ConstructionPreprocessRequest{
+			NetworkIdentifier: networkIdentifier,
+			Operations:        operations("954843"),
+			Metadata: map[string]interface{}{
+				"inputs": []interface{}{"b14157a5c50503c8cd202a173613dd27e0027343c3d50cf85852dd020bf59c7f:0"},
+			},
+		},
+	)
+	assert.Nil(t, preprocessResponse)
+	assert.Equal(t, ErrUnclearIntent.Code, err.Code)
+
+	// Explicit inputs that cover outputs and fee
+	preprocessResponse, err = servicer.ConstructionPreprocess(
+		ctx,
+		&types.ConstructionPreprocessRequest{
+			NetworkIdentifier: networkIdentifier,
+			Operations:        operations("954843"),
+			Metadata: map[string]interface{}{
+				"inputs": []interface{}{coinIdentifier},
+			},
+		},
+	)
+	assert.Nil(t, err)
+	var options preprocessOptions
+	assert.NoError(t, types.UnmarshalMap(preprocessResponse.Options, &options))
+	assert.Equal(t, []*types.AccountIdentifier{account}, options.InputAccounts)
+	assert.Equal(t, "954843", options.OutputTotal)
+
+	mockClient.On(
+		"SuggestedFeeRate",
+		ctx,
+		defaultConfirmationTarget,
+	).Return(whive.MinFeeRate*10, nil).Times(3)
+	mockClient.On("MempoolMinFeeRate", ctx).Return(whive.MinFeeRate, nil).Times(3)
+	mockIndexer.On("GetCoins", ctx, account).Return(ownedCoins, nil, nil).Once()
+	mockIndexer.On(
+		"GetScriptPubKeys",
+		ctx,
+		options.Coins,
+	).Return([]*whive.ScriptPubKey{}, nil).Once()
+	metadataResponse, err := servicer.ConstructionMetadata(
+		ctx,
+		&types.ConstructionMetadataRequest{
+			NetworkIdentifier: networkIdentifier,
+			Options:           preprocessResponse.Options,
+		},
+	)
+	assert.Nil(t, err)
+	assert.NotNil(t, metadataResponse)
+
+	// Explicit inputs that are spent (or not owned)
+	mockIndexer.On("GetCoins", ctx, account).Return([]*types.Coin{}, nil, nil).Once()
+	metadataResponse, err = servicer.ConstructionMetadata(
+		ctx,
+		&types.ConstructionMetadataRequest{
+			NetworkIdentifier: networkIdentifier,
+			Options:           preprocessResponse.Options,
+		},
+	)
+	assert.Nil(t, metadataResponse)
+	assert.Equal(t, ErrCoinUnavailable.Code, err.Code)
+
+	// Explicit inputs that don't cover outputs and fee
+	preprocessResponse, err = servicer.ConstructionPreprocess(
+		ctx,
+		&types.ConstructionPreprocessRequest{
+			NetworkIdentifier: networkIdentifier,
+			Operations:        operations("999999"),
+			Metadata: map[string]interface{}{
+				"inputs": []interface{}{coinIdentifier},
+			},
+		},
+	)
+	assert.Nil(t, err)
+
+	mockIndexer.On("GetCoins", ctx, account).Return(ownedCoins, nil, nil).Once()
+	metadataResponse, err = servicer.ConstructionMetadata(
+		ctx,
+		&types.ConstructionMetadataRequest{
+			NetworkIdentifier: networkIdentifier,
+			Options:           preprocessResponse.Options,
+		},
+	)
+	assert.Nil(t, metadataResponse)
+	assert.Equal(t, ErrInsufficientInputs.Code, err.Code)
+
+	mockIndexer.AssertExpectations(t)
+	mockClient.AssertExpectations(t)
+}
+
+func TestConstructionService_IdempotencyKey(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode:     configuration.Online,
+		Network:  networkIdentifier,
+		Params:   whive.TestnetParams,
+		Currency: whive.TestnetCurrency,
+	}
+
+	mockIndexer := &mocks.Indexer{}
+	mockClient := &mocks.Client{}
+	servicer := NewConstructionAPIService(cfg, mockClient, mockIndexer)
+	ctx := context.Background()
+
+	operations := func(coinIdentifier string) []*types.Operation {
+		return []*types.Operation{
+			{
+				OperationIdentifier: &types.OperationIdentifier{
+					Index: 0,
+				},
+				Type: whive.InputOpType,
+				Account: &types.AccountIdentifier{
+					Address: "tb1qcqzmqzkswhfshzd8kedhmtvgnxax48z4fklhvm",
+				},
+				Amount: &types.Amount{
+					Value:    "-1000000",
+					Currency: whive.TestnetCurrency,
+				},
+				CoinChange: &types.CoinChange{
+					CoinIdentifier: &types.CoinIdentifier{
+						Identifier: coinIdentifier,
+					},
+					CoinAction: types.CoinSpent,
+				},
+			},
+			{
+				OperationIdentifier: &types.OperationIdentifier{
+					Index: 1,
+				},
+				Type: whive.OutputOpType,
+				Account: &types.AccountIdentifier{
+					Address: "tb1q3r8xjf0c2yazxnq9ey3wayelygfjxpfqjvj5v7",
+				},
+				Amount: &types.Amount{
+					Value:    "954843",
+					Currency: whive.TestnetCurrency,
+				},
+			},
+		}
+	}
+	preprocess := func(key string, coinIdentifier string) (
+		*types.ConstructionPreprocessResponse,
+		*types.Error,
+	) {
+		return servicer.ConstructionPreprocess(
+			ctx,
+			&types.ConstructionPreprocessRequest{
+				NetworkIdentifier: networkIdentifier,
+				Operations:        operations(coinIdentifier),
+				Metadata: map[string]interface{}{
+					"idempotency_key": key,
+				},
+			},
+		)
+	}
+	coin0 := "b14157a5c50503c8cd202a173613dd27e0027343c3d50cf85852dd020bf59c7f:0"
+	coin1 := "b14157a5c50503c8cd202a173613dd27e0027343c3d50cf85852dd020bf59c7f:1"
+
+	// Retrying preprocess with the same key
+	// returns the same input selection.
+	first, err := preprocess("key 1", coin1)
+	assert.Nil(t, err)
+	retried, err := preprocess("key 1", coin1)
+	assert.Nil(t, err)
+	assert.Equal(t, first, retried)
+
+	var options preprocessOptions
+	assert.NoError(t, types.UnmarshalMap(first.Options, &options))
+	assert.Equal(t, "key 1", options.IdempotencyKey)
+	assert.Equal(t, coin1, options.Coins[0].CoinIdentifier.Identifier)
+
+	// Spending different inputs with the same key
+	// is rejected.
+	conflicting, err := preprocess("key 1", coin0)
+	assert.Nil(t, conflicting)
+	assert.Equal(t, ErrIdempotencyKeyReused.Code, err.Code)
+
+	// Metadata for the original selection succeeds.
+	mockClient.On(
+		"SuggestedFeeRate",
+		ctx,
+		defaultConfirmationTarget,
+	).Return(whive.MinFeeRate, nil).Once()
+	mockClient.On("MempoolMinFeeRate", ctx).Return(whive.MinFeeRate, nil).Once()
+	mockIndexer.On(
+		"GetScriptPubKeys",
+		ctx,
+		options.Coins,
+	).Return([]*whive.ScriptPubKey{}, nil).Once()
+	metadataResponse, err := servicer.ConstructionMetadata(
+		ctx,
+		&types.ConstructionMetadataRequest{
+			NetworkIdentifier: networkIdentifier,
+			Options:           first.Options,
+		},
+	)
+	assert.Nil(t, err)
+	assert.NotNil(t, metadataResponse)
+
+	// Metadata for a different selection under
+	// the same key is rejected (even if preprocess
+	// was served by a different instance).
+	other, err := NewConstructionAPIService(
+		cfg,
+		mockClient,
+		mockIndexer,
+	).ConstructionPreprocess(
+		ctx,
+		&types.ConstructionPreprocessRequest{
+			NetworkIdentifier: networkIdentifier,
+			Operations:        operations(coin0),
+			Metadata: map[string]interface{}{
+				"idempotency_key": "key 1",
+			},
+		},
+	)
+	assert.Nil(t, err)
+	metadataResponse, err = servicer.ConstructionMetadata(
+		ctx,
+		&types.ConstructionMetadataRequest{
+			NetworkIdentifier: networkIdentifier,
+			Options:           other.Options,
+		},
+	)
+	assert.Nil(t, metadataResponse)
+	assert.Equal(t, ErrIdempotencyKeyReused.Code, err.Code)
+
+	// Other keys are independent.
+	_, err = preprocess("key 2", coin0)
+	assert.Nil(t, err)
+
+	mockIndexer.AssertExpectations(t)
+	mockClient.AssertExpectations(t)
+}
+
+func TestConstructionService_SubmitMetrics(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode:     configuration.Online,
+		Network:  networkIdentifier,
+		Params:   whive.TestnetParams,
+		Currency: whive.TestnetCurrency,
+	}
+
+	mockIndexer := &mocks.Indexer{}
+	mockClient := &mocks.Client{}
+	servicer := NewConstructionAPIService(cfg, mockClient, mockIndexer)
+	ctx := context.Background()
+
+	signedRaw := hex.EncodeToString([]byte(`{"transaction":"deadbeef","input_amounts":[]}`))
+	counter := func(outcome string) float64 {
+		return testutil.ToFloat64(metrics.ConstructionTransactions.WithLabelValues(outcome))
+	}
+	rejections := func(reason string) float64 {
+		return testutil.ToFloat64(metrics.ConstructionRejections.WithLabelValues(reason))
+	}
+
+	submitted := counter(metrics.OutcomeSubmitted)
+	rejected := counter(metrics.OutcomeRejected)
+	policyRejections := rejections(metrics.RejectReasonPolicy)
+	invalidRejections := rejections(metrics.RejectReasonInvalid)
+
+	// Submitted
+	mockClient.On("SendRawTransaction", ctx, "deadbeef").Return("tx hash", nil).Once()
+	submitResponse, err := servicer.ConstructionSubmit(ctx, &types.ConstructionSubmitRequest{
+		NetworkIdentifier: networkIdentifier,
+		SignedTransaction: signedRaw,
+	})
+	assert.Nil(t, err)
+	assert.NotNil(t, submitResponse)
+	assert.Equal(t, submitted+1, counter(metrics.OutcomeSubmitted))
+	assert.Equal(t, rejected, counter(metrics.OutcomeRejected))
+
+	// Rejected by whived
+	mockClient.On("SendRawTransaction", ctx, "deadbeef").Return(
+		"",
+		fmt.Errorf("%w: min relay fee not met", whive.ErrTransactionRejected),
+	).Once()
+	submitResponse, err = servicer.ConstructionSubmit(ctx, &types.ConstructionSubmitRequest{
+		NetworkIdentifier: networkIdentifier,
+		SignedTransaction: signedRaw,
+	})
+	assert.Nil(t, submitResponse)
+	assert.Equal(t, ErrWhived.Code, err.Code)
+	assert.Equal(t, submitted+1, counter(metrics.OutcomeSubmitted))
+	assert.Equal(t, rejected+1, counter(metrics.OutcomeRejected))
+	assert.Equal(t, policyRejections+1, rejections(metrics.RejectReasonPolicy))
+
+	// Rejected for exceeding mempool chain limits
+	mempoolChainRejections := rejections(metrics.RejectReasonMempoolChain)
+	mockClient.On("SendRawTransaction", ctx, "deadbeef").Return(
+		"",
+		fmt.Errorf(
+			"%w: too-long-mempool-chain, too many unconfirmed ancestors [limit: 25]",
+			whive.ErrMempoolChainTooLong,
+		),
+	).Once()
+	submitResponse, err = servicer.ConstructionSubmit(ctx, &types.ConstructionSubmitRequest{
+		NetworkIdentifier: networkIdentifier,
+		SignedTransaction: signedRaw,
+	})
+	assert.Nil(t, submitResponse)
+	assert.Equal(t, ErrMempoolChainTooLong.Code, err.Code)
+	assert.True(t, err.Retriable)
+	assert.Contains(t, err.Details["context"], "too many unconfirmed ancestors")
+	assert.Equal(t, rejected+2, counter(metrics.OutcomeRejected))
+	assert.Equal(t, mempoolChainRejections+1, rejections(metrics.RejectReasonMempoolChain))
+
+	// Rejected before submission
+	submitResponse, err = servicer.ConstructionSubmit(ctx, &types.ConstructionSubmitRequest{
+		NetworkIdentifier: networkIdentifier,
+		SignedTransaction: "not hex",
+	})
+	assert.Nil(t, submitResponse)
+	assert.Equal(t, ErrUnableToParseIntermediateResult.Code, err.Code)
+	assert.Equal(t, rejected+3, counter(metrics.OutcomeRejected))
+	assert.Equal(t, invalidRejections+1, rejections(metrics.RejectReasonInvalid))
+
+	mockClient.AssertExpectations(t)
+	mockIndexer.AssertExpectations(t)
+}
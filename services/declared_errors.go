@@ -0,0 +1,93 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/coinbase/rosetta-sdk-go/server"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+// errorResponseWriter passes successful responses through
+// and buffers error responses so that they can be checked
+// before they are written.
+type errorResponseWriter struct {
+	http.ResponseWriter
+
+	code int
+	buf  bytes.Buffer
+}
+
+// WriteHeader writes the status code of a successful
+// response and stores the status code of an error.
+func (w *errorResponseWriter) WriteHeader(code int) {
+	w.code = code
+	if code == http.StatusOK {
+		w.ResponseWriter.WriteHeader(code)
+	}
+}
+
+// Write writes the bytes of a successful response
+// and buffers the bytes of an error.
+func (w *errorResponseWriter) Write(b []byte) (int, error) {
+	if w.code == http.StatusOK {
+		return w.ResponseWriter.Write(b)
+	}
+
+	return w.buf.Write(b)
+}
+
+// isDeclared returns true if err is in Errors.
+func isDeclared(err *types.Error) bool {
+	for _, declared := range Errors {
+		if declared.Code == err.Code && declared.Message == err.Message {
+			return true
+		}
+	}
+
+	return false
+}
+
+// declaredErrorsMiddleware replaces any error returned by inner
+// that is not in Errors with ErrInvalidRequest. The only such
+// errors are returned by the rosetta-sdk-go controllers when a
+// request can't be decoded or fails validation, which have a
+// code of 0 and the validation error as their message.
+func declaredErrorsMiddleware(inner http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ew := &errorResponseWriter{ResponseWriter: w, code: http.StatusOK}
+		inner.ServeHTTP(ew, r)
+		if ew.code == http.StatusOK {
+			return
+		}
+
+		var rosettaErr types.Error
+		if err := json.Unmarshal(ew.buf.Bytes(), &rosettaErr); err != nil || isDeclared(&rosettaErr) {
+			w.WriteHeader(ew.code)
+			_, _ = w.Write(ew.buf.Bytes())
+			return
+		}
+
+		server.EncodeJSONResponse(
+			wrapErr(ErrInvalidRequest, errors.New(rosettaErr.Message)),
+			ew.code,
+			w,
+		)
+	})
+}
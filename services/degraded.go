@@ -0,0 +1,59 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/coinbase/rosetta-sdk-go/server"
+)
+
+var (
+	// offlineRoutes are the endpoints that are served
+	// in offline mode (and don't depend on whived).
+	offlineRoutes = map[string]struct{}{
+		"/network/list":            {},
+		"/network/options":         {},
+		"/construction/derive":     {},
+		"/construction/preprocess": {},
+		"/construction/payloads":   {},
+		"/construction/combine":    {},
+		"/construction/parse":      {},
+		"/construction/hash":       {},
+	}
+
+	errDegraded = errors.New("whived is unreachable, only offline endpoints are served")
+)
+
+// DegradedMiddleware rejects requests to endpoints that are
+// not served in offline mode with ErrDegraded while degraded
+// returns true (i.e. while whived is unreachable). This includes
+// /network/status, so readiness checks fail until whived
+// recovers.
+func DegradedMiddleware(degraded func() bool, inner http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := offlineRoutes[r.URL.Path]; ok || !degraded() {
+			inner.ServeHTTP(w, r)
+			return
+		}
+
+		server.EncodeJSONResponse(
+			wrapErr(ErrDegraded, errDegraded),
+			http.StatusInternalServerError,
+			w,
+		)
+	})
+}
@@ -0,0 +1,66 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDegradedMiddleware(t *testing.T) {
+	degraded := false
+	handler := DegradedMiddleware(
+		func() bool { return degraded },
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}),
+	)
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	// All requests are served while whived is reachable
+	assert.Equal(t, http.StatusOK, serve("/network/status").Code)
+	assert.Equal(t, http.StatusOK, serve("/construction/derive").Code)
+
+	// Online requests are rejected while degraded
+	degraded = true
+	for _, path := range []string{"/network/status", "/account/balance", "/construction/submit"} {
+		rec := serve(path)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		var rosettaErr types.Error
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &rosettaErr))
+		assert.Equal(t, ErrDegraded.Code, rosettaErr.Code)
+		assert.True(t, rosettaErr.Retriable)
+	}
+
+	// Offline requests are still served
+	assert.Equal(t, http.StatusOK, serve("/network/options").Code)
+	assert.Equal(t, http.StatusOK, serve("/construction/payloads").Code)
+
+	// Online requests are served again once whived recovers
+	degraded = false
+	assert.Equal(t, http.StatusOK, serve("/network/status").Code)
+}
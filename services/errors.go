@@ -41,6 +41,20 @@ var (
 		ErrTransactionNotFound,
 		ErrCouldNotGetFeeRate,
 		ErrUnableToGetBalance,
+		ErrCallMethodInvalid,
+		ErrCallParametersInvalid,
+		ErrTxIndexDisabled,
+		ErrTransactionTooLarge,
+		ErrCoinUnavailable,
+		ErrInsufficientInputs,
+		ErrPublicKeyMismatch,
+		ErrMempoolChainTooLong,
+		ErrConstructionBusy,
+		ErrIdempotencyKeyReused,
+		ErrBlockPruned,
+		ErrMetadataStale,
+		ErrDegraded,
+		ErrInvalidRequest,
 	}
 
 	// ErrUnimplemented is returned when an endpoint
@@ -48,6 +62,9 @@ var (
 	ErrUnimplemented = &types.Error{
 		Code:    0, //nolint
 		Message: "Endpoint not implemented",
+		Description: types.String(
+			"The endpoint is not implemented by this implementation.",
+		),
 	}
 
 	// ErrUnavailableOffline is returned when an endpoint
@@ -55,13 +72,19 @@ var (
 	ErrUnavailableOffline = &types.Error{
 		Code:    1, //nolint
 		Message: "Endpoint unavailable offline",
+		Description: types.String(
+			"The endpoint depends on whived or the indexer and is unavailable when running in offline mode.",
+		),
 	}
 
 	// ErrNotReady is returned when bitcoind is not
 	// yet ready to serve queries.
 	ErrNotReady = &types.Error{
-		Code:      2, //nolint
-		Message:   "Bitcoind is not ready",
+		Code:    2, //nolint
+		Message: "Bitcoind is not ready",
+		Description: types.String(
+			"whived is still starting (e.g. loading its block index) and is not yet serving queries.",
+		),
 		Retriable: true,
 	}
 
@@ -70,6 +93,9 @@ var (
 	ErrWhived = &types.Error{
 		Code:    3, //nolint
 		Message: "Whived error",
+		Description: types.String(
+			"whived returned an error for a request made while serving this request.",
+		),
 	}
 
 	// ErrBlockNotFound is returned when a block
@@ -77,6 +103,9 @@ var (
 	ErrBlockNotFound = &types.Error{
 		Code:    4, //nolint
 		Message: "Block not found",
+		Description: types.String(
+			"The requested block has not been indexed yet or is not on the best chain.",
+		),
 	}
 
 	// ErrUnableToDerive is returned when an address
@@ -84,6 +113,9 @@ var (
 	ErrUnableToDerive = &types.Error{
 		Code:    5, //nolint
 		Message: "Unable to derive address",
+		Description: types.String(
+			"An address could not be derived from the provided public key and metadata.",
+		),
 	}
 
 	// ErrUnclearIntent is returned when operations
@@ -92,6 +124,9 @@ var (
 	ErrUnclearIntent = &types.Error{
 		Code:    6, //nolint
 		Message: "Unable to parse intent",
+		Description: types.String(
+			"The operations provided do not describe a supported transaction.",
+		),
 	}
 
 	// ErrUnableToParseIntermediateResult is returned
@@ -100,6 +135,10 @@ var (
 	ErrUnableToParseIntermediateResult = &types.Error{
 		Code:    7, //nolint
 		Message: "Unable to parse intermediate result",
+		Description: types.String(
+			"The options, metadata, or unsigned transaction passed between construction " +
+				"endpoints could not be parsed.",
+		),
 	}
 
 	// ErrScriptPubKeysMissing is returned when
@@ -108,6 +147,9 @@ var (
 	ErrScriptPubKeysMissing = &types.Error{
 		Code:    8, //nolint
 		Message: "Missing ScriptPubKeys",
+		Description: types.String(
+			"The ScriptPubKeys of the coins being spent could not be found in the indexer.",
+		),
 	}
 
 	// ErrInvalidCoin is returned when a *types.Coin
@@ -115,6 +157,9 @@ var (
 	ErrInvalidCoin = &types.Error{
 		Code:    9, //nolint
 		Message: "Coin is invalid",
+		Description: types.String(
+			"A coin provided to a construction endpoint could not be parsed.",
+		),
 	}
 
 	// ErrUnableToDecodeAddress is returned when an address
@@ -122,6 +167,9 @@ var (
 	ErrUnableToDecodeAddress = &types.Error{
 		Code:    10, //nolint
 		Message: "Unable to decode address",
+		Description: types.String(
+			"An address provided to a construction endpoint could not be decoded for this network.",
+		),
 	}
 
 	// ErrUnableToDecodeScriptPubKey is returned when a
@@ -129,6 +177,9 @@ var (
 	ErrUnableToDecodeScriptPubKey = &types.Error{
 		Code:    11, //nolint
 		Message: "Unable to decode ScriptPubKey",
+		Description: types.String(
+			"A ScriptPubKey provided to a construction endpoint could not be decoded.",
+		),
 	}
 
 	// ErrUnableToCalculateSignatureHash is returned
@@ -136,6 +187,9 @@ var (
 	ErrUnableToCalculateSignatureHash = &types.Error{
 		Code:    12, //nolint
 		Message: "Unable to calculate signature hash",
+		Description: types.String(
+			"The signature hash (payload to sign) of an input could not be calculated.",
+		),
 	}
 
 	// ErrUnsupportedScriptType is returned when
@@ -144,6 +198,9 @@ var (
 	ErrUnsupportedScriptType = &types.Error{
 		Code:    13, //nolint
 		Message: "Script type is not supported",
+		Description: types.String(
+			"Inputs locked by this script type cannot be signed.",
+		),
 	}
 
 	// ErrUnableToComputePkScript is returned
@@ -152,6 +209,9 @@ var (
 	ErrUnableToComputePkScript = &types.Error{
 		Code:    14, //nolint
 		Message: "Unable to compute PK script",
+		Description: types.String(
+			"The PkScript of an input could not be computed while parsing a transaction.",
+		),
 	}
 
 	// ErrUnableToGetCoins is returned by the indexer
@@ -160,6 +220,9 @@ var (
 	ErrUnableToGetCoins = &types.Error{
 		Code:    15, //nolint
 		Message: "Unable to get coins",
+		Description: types.String(
+			"The coins owned by the account could not be read from the indexer.",
+		),
 	}
 
 	// ErrTransactionNotFound is returned by the indexer
@@ -167,6 +230,9 @@ var (
 	ErrTransactionNotFound = &types.Error{
 		Code:    16, // nolint
 		Message: "Transaction not found",
+		Description: types.String(
+			"The requested transaction could not be found.",
+		),
 	}
 
 	// ErrCouldNotGetFeeRate is returned when the fetch
@@ -174,6 +240,9 @@ var (
 	ErrCouldNotGetFeeRate = &types.Error{
 		Code:    17, // nolint
 		Message: "Could not get suggested fee rate",
+		Description: types.String(
+			"whived could not estimate a fee rate for the requested confirmation target.",
+		),
 	}
 
 	// ErrUnableToGetBalance is returned by the indexer
@@ -182,6 +251,176 @@ var (
 	ErrUnableToGetBalance = &types.Error{
 		Code:    18, //nolint
 		Message: "Unable to get balance",
+		Description: types.String(
+			"The balance of the account could not be read from the indexer.",
+		),
+	}
+
+	// ErrCallMethodInvalid is returned when /call
+	// is invoked with an unsupported method.
+	ErrCallMethodInvalid = &types.Error{
+		Code:    19, //nolint
+		Message: "Call method is not supported",
+		Description: types.String(
+			"The method provided to /call is not supported (see /network/options).",
+		),
+	}
+
+	// ErrCallParametersInvalid is returned when
+	// the parameters provided to /call cannot be parsed.
+	ErrCallParametersInvalid = &types.Error{
+		Code:    20, //nolint
+		Message: "Call parameters are invalid",
+		Description: types.String(
+			"The parameters provided to /call could not be parsed for the requested method.",
+		),
+	}
+
+	// ErrTxIndexDisabled is returned when a lookup
+	// requires whived to be running with -txindex.
+	ErrTxIndexDisabled = &types.Error{
+		Code:    21, //nolint
+		Message: "Whived transaction index is disabled",
+		Description: types.String(
+			"The request requires whived to be running with -txindex.",
+		),
+	}
+
+	// ErrTransactionTooLarge is returned when the
+	// operations provided to /construction/preprocess
+	// would construct a non-standard transaction.
+	ErrTransactionTooLarge = &types.Error{
+		Code:    22, //nolint
+		Message: "Transaction is too large",
+		Description: types.String(
+			"The transaction described by the operations would exceed the maximum standard " +
+				"transaction size.",
+		),
+	}
+
+	// ErrCoinUnavailable is returned when a coin
+	// explicitly selected for a transaction is spent
+	// or is not owned by the account spending it.
+	ErrCoinUnavailable = &types.Error{
+		Code:    23, //nolint
+		Message: "Coin is spent or not owned by account",
+		Description: types.String(
+			"A coin explicitly selected for the transaction is already spent or is not owned " +
+				"by the account spending it.",
+		),
+	}
+
+	// ErrInsufficientInputs is returned when the coins
+	// explicitly selected for a transaction do not cover
+	// its outputs and the suggested fee.
+	ErrInsufficientInputs = &types.Error{
+		Code:    24, //nolint
+		Message: "Inputs do not cover outputs and fee",
+		Description: types.String(
+			"The coins explicitly selected for the transaction do not cover its outputs and " +
+				"the suggested fee.",
+		),
+	}
+
+	// ErrPublicKeyMismatch is returned when the public
+	// key provided with a signature to /construction/combine
+	// does not match the script of the input it signs.
+	ErrPublicKeyMismatch = &types.Error{
+		Code:    25, //nolint
+		Message: "Public key does not match input script",
+		Description: types.String(
+			"The public key provided with a signature does not match the script of the input it signs.",
+		),
+	}
+
+	// ErrMempoolChainTooLong is returned when a transaction
+	// submitted to /construction/submit spends too long a
+	// chain of unconfirmed transactions. It can be retried
+	// once some of its unconfirmed ancestors are confirmed.
+	ErrMempoolChainTooLong = &types.Error{
+		Code:    26, //nolint
+		Message: "Transaction exceeds mempool ancestor or descendant limits",
+		Description: types.String(
+			"The transaction spends too long a chain of unconfirmed transactions. It can be " +
+				"retried once some of its unconfirmed ancestors are confirmed.",
+		),
+		Retriable: true,
+	}
+
+	// ErrConstructionBusy is returned when the maximum
+	// number of concurrent /construction/* requests
+	// are already being served.
+	ErrConstructionBusy = &types.Error{
+		Code:    27, //nolint
+		Message: "Too many concurrent construction requests",
+		Description: types.String(
+			"The maximum number of concurrent construction requests are already being served.",
+		),
+		Retriable: true,
+	}
+
+	// ErrIdempotencyKeyReused is returned when the idempotency
+	// key provided to /construction/preprocess or
+	// /construction/metadata was previously used to
+	// construct a transaction spending different inputs.
+	ErrIdempotencyKeyReused = &types.Error{
+		Code:    28, //nolint
+		Message: "Idempotency key was used for a different transaction",
+		Description: types.String(
+			"The idempotency key was previously used to construct a transaction spending different inputs.",
+		),
+	}
+
+	// ErrBlockPruned is returned when the requested block
+	// is no longer available because whived has pruned it.
+	// The minimum available height is included in the
+	// error details.
+	ErrBlockPruned = &types.Error{
+		Code:    29, //nolint
+		Message: "Block data is unavailable because it was pruned",
+		Description: types.String(
+			"whived has pruned the requested block. The minimum available height is included " +
+				"in the error details.",
+		),
+	}
+
+	// ErrMetadataStale is returned when the metadata provided
+	// to /construction/payloads is older than the configured
+	// maximum age and the fee of the transaction no longer
+	// covers the re-estimated fee (or can't be re-estimated
+	// offline). /construction/metadata should be called again.
+	ErrMetadataStale = &types.Error{
+		Code:    30, //nolint
+		Message: "Construction metadata is stale",
+		Description: types.String(
+			"The construction metadata is older than the maximum age and the fee of the " +
+				"transaction no longer covers the re-estimated fee. /construction/metadata " +
+				"should be called again.",
+		),
+	}
+
+	// ErrDegraded is returned when an endpoint that
+	// depends on whived is called while whived is
+	// unreachable (see DEGRADE_TO_OFFLINE).
+	ErrDegraded = &types.Error{
+		Code:    31, //nolint
+		Message: "Endpoint unavailable while whived is unreachable",
+		Description: types.String(
+			"The endpoint depends on whived, which is currently unreachable. Only offline " +
+				"endpoints are served until it recovers.",
+		),
+		Retriable: true,
+	}
+
+	// ErrInvalidRequest is returned when a request
+	// cannot be decoded or fails validation before
+	// it reaches a handler.
+	ErrInvalidRequest = &types.Error{
+		Code:    32, //nolint
+		Message: "Request is invalid",
+		Description: types.String(
+			"The request could not be decoded or is not a valid Rosetta request for this network.",
+		),
 	}
 )
 
@@ -190,9 +429,10 @@ var (
 // errors.
 func wrapErr(rErr *types.Error, err error) *types.Error {
 	newErr := &types.Error{
-		Code:      rErr.Code,
-		Message:   rErr.Message,
-		Retriable: rErr.Retriable,
+		Code:        rErr.Code,
+		Message:     rErr.Message,
+		Description: rErr.Description,
+		Retriable:   rErr.Retriable,
 	}
 	if err != nil {
 		newErr.Details = map[string]interface{}{
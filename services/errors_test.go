@@ -16,14 +16,87 @@ package services
 
 import (
 	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 )
 
 func TestErrors(t *testing.T) {
+	messages := map[string]struct{}{}
 	for i := 0; i < len(Errors); i++ {
 		assert.Equal(t, int32(i), Errors[i].Code)
+		assert.NotNil(t, Errors[i].Description)
+
+		_, ok := messages[Errors[i].Message]
+		assert.False(t, ok, "duplicate message %s", Errors[i].Message)
+		messages[Errors[i].Message] = struct{}{}
+	}
+}
+
+// TestErrors_Declared ensures every error defined or
+// returned (with wrapErr) by this package is in Errors,
+// so that /network/options declares every error we
+// may return.
+func TestErrors_Declared(t *testing.T) {
+	files, err := filepath.Glob("*.go")
+	assert.NoError(t, err)
+
+	fset := token.NewFileSet()
+	declared := map[string]struct{}{}
+	defined := []string{}
+	wrapped := []string{}
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+
+		f, err := parser.ParseFile(fset, file, nil, 0)
+		assert.NoError(t, err)
+
+		ast.Inspect(f, func(n ast.Node) bool {
+			switch node := n.(type) {
+			case *ast.ValueSpec:
+				if len(node.Names) != 1 || len(node.Values) != 1 {
+					return true
+				}
+
+				name := node.Names[0].Name
+				if name == "Errors" {
+					for _, elt := range node.Values[0].(*ast.CompositeLit).Elts {
+						declared[elt.(*ast.Ident).Name] = struct{}{}
+					}
+				}
+
+				if unary, ok := node.Values[0].(*ast.UnaryExpr); ok {
+					if lit, ok := unary.X.(*ast.CompositeLit); ok {
+						if sel, ok := lit.Type.(*ast.SelectorExpr); ok && sel.Sel.Name == "Error" {
+							defined = append(defined, name)
+						}
+					}
+				}
+			case *ast.CallExpr:
+				if fun, ok := node.Fun.(*ast.Ident); ok && fun.Name == "wrapErr" {
+					ident, ok := node.Args[0].(*ast.Ident)
+					if assert.True(t, ok, "wrapErr called with %T at %s", node.Args[0], fset.Position(node.Pos())) {
+						wrapped = append(wrapped, ident.Name)
+					}
+				}
+			}
+
+			return true
+		})
+	}
+
+	assert.Len(t, declared, len(Errors))
+	assert.NotEmpty(t, wrapped)
+	for _, name := range append(defined, wrapped...) {
+		_, ok := declared[name]
+		assert.True(t, ok, "%s is not in Errors", name)
 	}
 }
 
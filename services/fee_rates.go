@@ -0,0 +1,138 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/xyephy/rosetta-whive/whive"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+const (
+	// maxCachedFeeRateBlocks is the number of blocks we
+	// remember the fee rates of. Once exceeded, the oldest
+	// block is forgotten.
+	maxCachedFeeRateBlocks = maxMedianFeeRateBlocks
+)
+
+// blockFeeRates caches the fee rates (in satoshis per vbyte)
+// of the transactions in each block by block hash, so that
+// the median fee rate of recent blocks only requires reading
+// the transactions of blocks added since the last call.
+// Blocks are keyed by hash, so an orphaned block is never
+// returned for the block that replaced it.
+type blockFeeRates struct {
+	rates map[string][]float64
+	order []string
+
+	mutex sync.Mutex
+}
+
+// newBlockFeeRates returns a new *blockFeeRates.
+func newBlockFeeRates() *blockFeeRates {
+	return &blockFeeRates{
+		rates: map[string][]float64{},
+	}
+}
+
+// get returns the fee rates of the block with hash
+// (if they have been cached).
+func (b *blockFeeRates) get(hash string) ([]float64, bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	rates, ok := b.rates[hash]
+	return rates, ok
+}
+
+// set caches the fee rates of the block with hash.
+func (b *blockFeeRates) set(hash string, rates []float64) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if _, ok := b.rates[hash]; ok {
+		return
+	}
+
+	if len(b.order) >= maxCachedFeeRateBlocks {
+		delete(b.rates, b.order[0])
+		b.order = b.order[1:]
+	}
+
+	b.rates[hash] = rates
+	b.order = append(b.order, hash)
+}
+
+// transactionFeeRate returns the fee rate (in satoshis per
+// vbyte) paid by tx: the total of its inputs less the total
+// of its outputs divided by its vsize. Coinbase transactions
+// (and transactions without a vsize) have no fee rate.
+func transactionFeeRate(tx *types.Transaction) (float64, bool, error) {
+	var metadata whive.TransactionMetadata
+	if err := types.UnmarshalMap(tx.Metadata, &metadata); err != nil {
+		return 0, false, fmt.Errorf("%w: unable to parse metadata of %s", err, tx.TransactionIdentifier.Hash)
+	}
+
+	if metadata.Vsize <= 0 {
+		return 0, false, nil
+	}
+
+	// Inputs are negative amounts and outputs
+	// are positive amounts, so the fee is the
+	// negation of their total.
+	var total int64
+	for _, op := range tx.Operations {
+		if op.Type == whive.CoinbaseOpType {
+			return 0, false, nil
+		}
+
+		if op.Amount == nil {
+			continue
+		}
+
+		value, err := strconv.ParseInt(op.Amount.Value, 10, 64)
+		if err != nil {
+			return 0, false, fmt.Errorf(
+				"%w: unable to parse amount %s of %s",
+				err,
+				op.Amount.Value,
+				tx.TransactionIdentifier.Hash,
+			)
+		}
+		total += value
+	}
+
+	return float64(-total) / float64(metadata.Vsize), true, nil
+}
+
+// median returns the median of rates
+// (which must not be empty).
+func median(rates []float64) float64 {
+	sorted := make([]float64, len(rates))
+	copy(sorted, rates)
+	sort.Float64s(sorted)
+
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+
+	return sorted[middle]
+}
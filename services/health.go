@@ -0,0 +1,105 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+const (
+	// LivenessRoute is the path the liveness
+	// check is served on.
+	LivenessRoute = "/health"
+
+	// ReadinessRoute is the path the readiness
+	// check is served on.
+	ReadinessRoute = "/health/ready"
+)
+
+// healthResponse is returned by the liveness
+// and readiness checks.
+type healthResponse struct {
+	Ready           bool   `json:"ready"`
+	Degraded        bool   `json:"degraded,omitempty"`
+	Reason          string `json:"reason,omitempty"`
+	BlocksBehind    *int64 `json:"blocks_behind,omitempty"`
+	MaxBlocksBehind *int64 `json:"max_blocks_behind,omitempty"`
+}
+
+// writeHealth writes response with a 200 status if
+// ready and a 503 status otherwise.
+func writeHealth(w http.ResponseWriter, response *healthResponse) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	if response.Ready {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// LivenessHandler reports that the process is up. It
+// doesn't make any requests to whived or read storage,
+// so it is cheap enough to probe frequently.
+func LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeHealth(w, &healthResponse{Ready: true})
+	})
+}
+
+// ReadinessHandler reports that we are ready to serve
+// requests once the indexer is at most maxBlocksBehind
+// whived's tip. syncLag returns the number of blocks the
+// indexer was behind when whived was last polled (and
+// false if it hasn't been polled yet). While degraded
+// returns true (whived is unreachable) or ready returns
+// an error (e.g. indexing is paused because free disk
+// space is low), we are not ready. If syncLag, ready, and
+// degraded are nil (in offline mode), we are always ready.
+func ReadinessHandler(
+	maxBlocksBehind int64,
+	syncLag func() (int64, bool),
+	ready func() error,
+	degraded func() bool,
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if degraded != nil && degraded() {
+			writeHealth(w, &healthResponse{Degraded: true})
+			return
+		}
+
+		if ready != nil {
+			if err := ready(); err != nil {
+				writeHealth(w, &healthResponse{Reason: err.Error()})
+				return
+			}
+		}
+
+		if syncLag == nil {
+			writeHealth(w, &healthResponse{Ready: true})
+			return
+		}
+
+		response := &healthResponse{MaxBlocksBehind: &maxBlocksBehind}
+		if behind, ok := syncLag(); ok {
+			response.BlocksBehind = &behind
+			response.Ready = behind <= maxBlocksBehind
+		}
+
+		writeHealth(w, response)
+	})
+}
@@ -0,0 +1,132 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func serveHealth(t *testing.T, handler http.Handler) (int, *healthResponse) {
+	req := httptest.NewRequest(http.MethodGet, ReadinessRoute, nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	var response healthResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+
+	return rec.Code, &response
+}
+
+func TestLivenessHandler(t *testing.T) {
+	code, response := serveHealth(t, LivenessHandler())
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, response.Ready)
+}
+
+func TestReadinessHandler(t *testing.T) {
+	// Offline mode is always ready
+	code, response := serveHealth(t, ReadinessHandler(2, nil, nil, nil))
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, response.Ready)
+
+	tests := map[string]struct {
+		behind   int64
+		known    bool
+		ready    error
+		degraded bool
+
+		expectedCode  int
+		expectedReady bool
+	}{
+		"not polled": {
+			expectedCode: http.StatusServiceUnavailable,
+		},
+		"synced": {
+			behind:        0,
+			known:         true,
+			expectedCode:  http.StatusOK,
+			expectedReady: true,
+		},
+		"within max blocks behind": {
+			behind:        2,
+			known:         true,
+			expectedCode:  http.StatusOK,
+			expectedReady: true,
+		},
+		"too far behind": {
+			behind:       3,
+			known:        true,
+			expectedCode: http.StatusServiceUnavailable,
+		},
+		"degraded": {
+			behind:       0,
+			known:        true,
+			degraded:     true,
+			expectedCode: http.StatusServiceUnavailable,
+		},
+		"disk space low": {
+			behind:       0,
+			known:        true,
+			ready:        errors.New("free disk space is below minimum"),
+			expectedCode: http.StatusServiceUnavailable,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			handler := ReadinessHandler(
+				2,
+				func() (int64, bool) {
+					return test.behind, test.known
+				},
+				func() error {
+					return test.ready
+				},
+				func() bool {
+					return test.degraded
+				},
+			)
+
+			code, response := serveHealth(t, handler)
+			assert.Equal(t, test.expectedCode, code)
+			assert.Equal(t, test.expectedReady, response.Ready)
+			assert.Equal(t, test.degraded, response.Degraded)
+			if test.degraded || test.ready != nil {
+				// Sync progress isn't reported while
+				// whived is unreachable or indexing
+				// is paused.
+				assert.Nil(t, response.MaxBlocksBehind)
+				assert.Nil(t, response.BlocksBehind)
+				if test.ready != nil {
+					assert.Equal(t, test.ready.Error(), response.Reason)
+				}
+				return
+			}
+
+			assert.Equal(t, int64(2), *response.MaxBlocksBehind)
+			if test.known {
+				assert.Equal(t, test.behind, *response.BlocksBehind)
+			} else {
+				assert.Nil(t, response.BlocksBehind)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+const (
+	// maxIdempotencyKeys is the number of idempotency keys
+	// we remember. Once exceeded, the oldest key is forgotten.
+	maxIdempotencyKeys = 10000
+)
+
+// idempotencyKeys records the inputs selected for each
+// idempotency key provided to /construction/preprocess and
+// /construction/metadata, so that a retried construction flow
+// can't be used to spend different inputs under the same key.
+type idempotencyKeys struct {
+	selections map[string]string
+	order      []string
+
+	mutex sync.Mutex
+}
+
+// newIdempotencyKeys returns a new *idempotencyKeys.
+func newIdempotencyKeys() *idempotencyKeys {
+	return &idempotencyKeys{
+		selections: map[string]string{},
+	}
+}
+
+// inputSelection returns a canonical representation of
+// the coins spent by a transaction.
+func inputSelection(coins []*types.Coin) string {
+	identifiers := make([]string, len(coins))
+	for i, coin := range coins {
+		identifiers[i] = coin.CoinIdentifier.Identifier
+	}
+	sort.Strings(identifiers)
+
+	return strings.Join(identifiers, ",")
+}
+
+// record associates the input selection of coins with key
+// if key has not been seen before. If key was previously
+// associated with a different input selection, an error
+// is returned.
+func (k *idempotencyKeys) record(key string, coins []*types.Coin) error {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	selection := inputSelection(coins)
+	existing, ok := k.selections[key]
+	if ok {
+		if existing != selection {
+			return fmt.Errorf(
+				"idempotency key %s was used to spend [%s] but now spends [%s]",
+				key,
+				existing,
+				selection,
+			)
+		}
+
+		return nil
+	}
+
+	if len(k.order) >= maxIdempotencyKeys {
+		delete(k.selections, k.order[0])
+		k.order = k.order[1:]
+	}
+
+	k.selections[key] = selection
+	k.order = append(k.order, key)
+
+	return nil
+}
@@ -0,0 +1,57 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/coinbase/rosetta-sdk-go/server"
+	"golang.org/x/sync/semaphore"
+)
+
+const (
+	constructionPathPrefix = "/construction/"
+)
+
+// ConstructionLimiterMiddleware limits the number of /construction/*
+// requests served concurrently to limit. Requests beyond the limit are
+// rejected (instead of queued) with ErrConstructionBusy, so a flood of
+// construction requests cannot starve other endpoints.
+func ConstructionLimiterMiddleware(limit int64, inner http.Handler) http.Handler {
+	sem := semaphore.NewWeighted(limit)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, constructionPathPrefix) {
+			inner.ServeHTTP(w, r)
+			return
+		}
+
+		if !sem.TryAcquire(1) {
+			server.EncodeJSONResponse(
+				wrapErr(
+					ErrConstructionBusy,
+					fmt.Errorf("already serving %d construction requests", limit),
+				),
+				http.StatusInternalServerError,
+				w,
+			)
+			return
+		}
+		defer sem.Release(1)
+
+		inner.ServeHTTP(w, r)
+	})
+}
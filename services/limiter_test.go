@@ -0,0 +1,78 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConstructionLimiterMiddleware(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := ConstructionLimiterMiddleware(
+		1,
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Block the first construction request
+			// until it is released.
+			if r.URL.Path == "/construction/payloads" {
+				close(started)
+				<-release
+			}
+
+			w.WriteHeader(http.StatusOK)
+		}),
+	)
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	done := make(chan struct{})
+	go func() {
+		rec := serve("/construction/payloads")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		close(done)
+	}()
+	<-started
+
+	// Construction requests beyond the limit are rejected
+	rec := serve("/construction/combine")
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	var rosettaErr types.Error
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &rosettaErr))
+	assert.Equal(t, ErrConstructionBusy.Code, rosettaErr.Code)
+	assert.True(t, rosettaErr.Retriable)
+
+	// Data requests are not limited
+	rec = serve("/block")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// Construction requests are accepted once
+	// a slot is available
+	close(release)
+	<-done
+	rec = serve("/construction/combine")
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
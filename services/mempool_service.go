@@ -16,10 +16,14 @@ package services
 
 import (
 	"context"
+	"errors"
+	"fmt"
 
 	"github.com/xyephy/rosetta-whive/configuration"
+	"github.com/xyephy/rosetta-whive/whive"
 
 	"github.com/coinbase/rosetta-sdk-go/server"
+	storageErrs "github.com/coinbase/rosetta-sdk-go/storage/errors"
 	"github.com/coinbase/rosetta-sdk-go/types"
 )
 
@@ -27,16 +31,19 @@ import (
 type MempoolAPIService struct {
 	config *configuration.Configuration
 	client Client
+	i      Indexer
 }
 
 // NewMempoolAPIService creates a new instance of a MempoolAPIService.
 func NewMempoolAPIService(
 	config *configuration.Configuration,
 	client Client,
+	i Indexer,
 ) server.MempoolAPIServicer {
 	return &MempoolAPIService{
 		config: config,
 		client: client,
+		i:      i,
 	}
 }
 
@@ -73,5 +80,109 @@ func (s *MempoolAPIService) MempoolTransaction(
 		return nil, wrapErr(ErrUnavailableOffline, nil)
 	}
 
-	return nil, wrapErr(ErrUnimplemented, nil)
+	transaction, err := s.client.MempoolTransaction(ctx, request.TransactionIdentifier.Hash)
+	switch {
+	case errors.Is(err, whive.ErrTransactionNotFound):
+		return nil, wrapErr(ErrTransactionNotFound, err)
+	case err != nil:
+		return nil, wrapErr(ErrWhived, err)
+	}
+
+	if err := s.resolveInputs(ctx, transaction); err != nil {
+		return nil, err
+	}
+
+	return &types.MempoolTransactionResponse{
+		Transaction: transaction,
+	}, nil
+}
+
+// resolveInputs populates the account and amount of each input
+// in transaction (which aren't included in a raw transaction).
+// Inputs spending confirmed coins are resolved from the indexer.
+// Inputs spending the outputs of another transaction in the
+// mempool are resolved from that transaction's outputs.
+func (s *MempoolAPIService) resolveInputs(
+	ctx context.Context,
+	transaction *types.Transaction,
+) *types.Error {
+	parents := map[string]*types.Transaction{}
+	for _, op := range transaction.Operations {
+		if op.Type != whive.InputOpType || op.CoinChange == nil {
+			continue
+		}
+
+		coinIdentifier := op.CoinChange.CoinIdentifier
+		coin, owner, err := s.i.GetCoin(ctx, coinIdentifier)
+		if errors.Is(err, storageErrs.ErrCoinNotFound) {
+			coin, owner, err = s.mempoolCoin(ctx, coinIdentifier, parents)
+		}
+		if err != nil {
+			return wrapErr(ErrUnableToGetCoins, err)
+		}
+
+		value, err := types.NegateValue(coin.Amount.Value)
+		if err != nil {
+			return wrapErr(ErrUnableToGetCoins, err)
+		}
+
+		op.Account = owner
+		op.Amount = &types.Amount{
+			Value:    value,
+			Currency: coin.Amount.Currency,
+		}
+	}
+
+	return nil
+}
+
+// mempoolCoin returns the coin with coinIdentifier (and the
+// account that owns it) created by a transaction in the mempool.
+// Fetched transactions are cached in parents so that inputs
+// spending multiple outputs of the same transaction only fetch
+// it once.
+func (s *MempoolAPIService) mempoolCoin(
+	ctx context.Context,
+	coinIdentifier *types.CoinIdentifier,
+	parents map[string]*types.Transaction,
+) (*types.Coin, *types.AccountIdentifier, error) {
+	hash, vout, err := whive.ParseCoinIdentifier(coinIdentifier)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: unable to parse coin identifier", err)
+	}
+
+	parent, ok := parents[hash.String()]
+	if !ok {
+		parent, err = s.client.MempoolTransaction(ctx, hash.String())
+		if err != nil {
+			return nil, nil, fmt.Errorf(
+				"%w: coin %s is not indexed or in the mempool",
+				err,
+				coinIdentifier.Identifier,
+			)
+		}
+		parents[hash.String()] = parent
+	}
+
+	for _, op := range parent.Operations {
+		if op.Type != whive.OutputOpType || op.OperationIdentifier.NetworkIndex == nil {
+			continue
+		}
+
+		if *op.OperationIdentifier.NetworkIndex != int64(vout) {
+			continue
+		}
+
+		return &types.Coin{
+			CoinIdentifier: coinIdentifier,
+			Amount:         op.Amount,
+		}, op.Account, nil
+	}
+
+	return nil, nil, fmt.Errorf(
+		"%w: output %d of %s",
+		storageErrs.ErrCoinNotFound,
+		vout,
+		hash.String(),
+	)
 }
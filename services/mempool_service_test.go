@@ -16,11 +16,14 @@ package services
 
 import (
 	"context"
+	"fmt"
 	"testing"
 
 	"github.com/xyephy/rosetta-whive/configuration"
 	mocks "github.com/xyephy/rosetta-whive/mocks/services"
+	"github.com/xyephy/rosetta-whive/whive"
 
+	storageErrs "github.com/coinbase/rosetta-sdk-go/storage/errors"
 	"github.com/coinbase/rosetta-sdk-go/types"
 	"github.com/stretchr/testify/assert"
 )
@@ -30,7 +33,8 @@ func TestMempoolEndpoints_Offline(t *testing.T) {
 		Mode: configuration.Offline,
 	}
 	mockClient := &mocks.Client{}
-	servicer := NewMempoolAPIService(cfg, mockClient)
+	mockIndexer := &mocks.Indexer{}
+	servicer := NewMempoolAPIService(cfg, mockClient, mockIndexer)
 	ctx := context.Background()
 	mem, err := servicer.Mempool(ctx, nil)
 	assert.Nil(t, mem)
@@ -50,7 +54,8 @@ func TestMempoolEndpoints_Online(t *testing.T) {
 	}
 
 	mockClient := &mocks.Client{}
-	servicer := NewMempoolAPIService(cfg, mockClient)
+	mockIndexer := &mocks.Indexer{}
+	servicer := NewMempoolAPIService(cfg, mockClient, mockIndexer)
 	ctx := context.Background()
 
 	mockClient.On("RawMempool", ctx).Return([]string{
@@ -70,9 +75,179 @@ func TestMempoolEndpoints_Online(t *testing.T) {
 		},
 	}, mem)
 
-	memTransaction, err := servicer.MempoolTransaction(ctx, nil)
+	// Signals RBF
+	rbfTransaction := &types.Transaction{
+		TransactionIdentifier: &types.TransactionIdentifier{Hash: "tx1"},
+		Metadata: map[string]interface{}{
+			"signals_rbf": true,
+		},
+	}
+	mockClient.On("MempoolTransaction", ctx, "tx1").Return(rbfTransaction, nil).Once()
+	memTransaction, err := servicer.MempoolTransaction(ctx, &types.MempoolTransactionRequest{
+		TransactionIdentifier: &types.TransactionIdentifier{Hash: "tx1"},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, &types.MempoolTransactionResponse{
+		Transaction: rbfTransaction,
+	}, memTransaction)
+
+	// Final
+	finalTransaction := &types.Transaction{
+		TransactionIdentifier: &types.TransactionIdentifier{Hash: "tx2"},
+		Metadata:              map[string]interface{}{},
+	}
+	mockClient.On("MempoolTransaction", ctx, "tx2").Return(finalTransaction, nil).Once()
+	memTransaction, err = servicer.MempoolTransaction(ctx, &types.MempoolTransactionRequest{
+		TransactionIdentifier: &types.TransactionIdentifier{Hash: "tx2"},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, &types.MempoolTransactionResponse{
+		Transaction: finalTransaction,
+	}, memTransaction)
+
+	// Not found
+	mockClient.On("MempoolTransaction", ctx, "tx3").Return(
+		nil,
+		fmt.Errorf("%w: error getting mempool transaction tx3", whive.ErrTransactionNotFound),
+	).Once()
+	memTransaction, err = servicer.MempoolTransaction(ctx, &types.MempoolTransactionRequest{
+		TransactionIdentifier: &types.TransactionIdentifier{Hash: "tx3"},
+	})
+	assert.Nil(t, memTransaction)
+	assert.Equal(t, ErrTransactionNotFound.Code, err.Code)
+	mockClient.AssertExpectations(t)
+}
+
+func TestMempoolTransaction_ResolveInputs(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode: configuration.Online,
+	}
+
+	mockClient := &mocks.Client{}
+	mockIndexer := &mocks.Indexer{}
+	servicer := NewMempoolAPIService(cfg, mockClient, mockIndexer)
+	ctx := context.Background()
+
+	parentHash := "b14157a5c50503c8cd202a173613dd27e0027343c3d50cf85852dd020bf59c7f"
+	confirmedHash := "6d87ad0e26025128f5a8357fa423b340cbcffb9703f79f432f5520fca59cd20b"
+	networkIndex := int64(0)
+	childIndex := int64(1)
+	input := func(index int64, identifier string) *types.Operation {
+		return &types.Operation{
+			OperationIdentifier: &types.OperationIdentifier{
+				Index:        index,
+				NetworkIndex: &index,
+			},
+			Type: whive.InputOpType,
+			CoinChange: &types.CoinChange{
+				CoinIdentifier: &types.CoinIdentifier{Identifier: identifier},
+				CoinAction:     types.CoinSpent,
+			},
+		}
+	}
+
+	// The child spends a confirmed coin and the
+	// second output of a transaction in the mempool.
+	child := &types.Transaction{
+		TransactionIdentifier: &types.TransactionIdentifier{Hash: "child"},
+		Operations: []*types.Operation{
+			input(0, confirmedHash+":0"),
+			input(1, parentHash+":1"),
+		},
+	}
+	parent := &types.Transaction{
+		TransactionIdentifier: &types.TransactionIdentifier{Hash: parentHash},
+		Operations: []*types.Operation{
+			{
+				OperationIdentifier: &types.OperationIdentifier{
+					Index:        0,
+					NetworkIndex: &networkIndex,
+				},
+				Type:    whive.OutputOpType,
+				Account: &types.AccountIdentifier{Address: "addr1"},
+				Amount: &types.Amount{
+					Value:    "100",
+					Currency: whive.MainnetCurrency,
+				},
+			},
+			{
+				OperationIdentifier: &types.OperationIdentifier{
+					Index:        1,
+					NetworkIndex: &childIndex,
+				},
+				Type:    whive.OutputOpType,
+				Account: &types.AccountIdentifier{Address: "addr2"},
+				Amount: &types.Amount{
+					Value:    "200",
+					Currency: whive.MainnetCurrency,
+				},
+			},
+		},
+	}
+
+	mockClient.On("MempoolTransaction", ctx, "child").Return(child, nil).Once()
+	mockIndexer.On(
+		"GetCoin",
+		ctx,
+		&types.CoinIdentifier{Identifier: confirmedHash + ":0"},
+	).Return(
+		&types.Coin{
+			CoinIdentifier: &types.CoinIdentifier{Identifier: confirmedHash + ":0"},
+			Amount: &types.Amount{
+				Value:    "300",
+				Currency: whive.MainnetCurrency,
+			},
+		},
+		&types.AccountIdentifier{Address: "addr3"},
+		nil,
+	).Once()
+	mockIndexer.On(
+		"GetCoin",
+		ctx,
+		&types.CoinIdentifier{Identifier: parentHash + ":1"},
+	).Return(nil, nil, storageErrs.ErrCoinNotFound).Once()
+	mockClient.On("MempoolTransaction", ctx, parentHash).Return(parent, nil).Once()
+
+	memTransaction, err := servicer.MempoolTransaction(ctx, &types.MempoolTransactionRequest{
+		TransactionIdentifier: &types.TransactionIdentifier{Hash: "child"},
+	})
+	assert.Nil(t, err)
+	confirmedInput := input(0, confirmedHash+":0")
+	confirmedInput.Account = &types.AccountIdentifier{Address: "addr3"}
+	confirmedInput.Amount = &types.Amount{Value: "-300", Currency: whive.MainnetCurrency}
+	mempoolInput := input(1, parentHash+":1")
+	mempoolInput.Account = &types.AccountIdentifier{Address: "addr2"}
+	mempoolInput.Amount = &types.Amount{Value: "-200", Currency: whive.MainnetCurrency}
+	assert.Equal(
+		t,
+		[]*types.Operation{confirmedInput, mempoolInput},
+		memTransaction.Transaction.Operations,
+	)
+
+	// Inputs spending coins that are neither
+	// indexed nor in the mempool can't be resolved.
+	orphan := &types.Transaction{
+		TransactionIdentifier: &types.TransactionIdentifier{Hash: "orphan"},
+		Operations: []*types.Operation{
+			input(0, parentHash+":0"),
+		},
+	}
+	mockClient.On("MempoolTransaction", ctx, "orphan").Return(orphan, nil).Once()
+	mockIndexer.On(
+		"GetCoin",
+		ctx,
+		&types.CoinIdentifier{Identifier: parentHash + ":0"},
+	).Return(nil, nil, storageErrs.ErrCoinNotFound).Once()
+	mockClient.On("MempoolTransaction", ctx, parentHash).Return(
+		nil,
+		fmt.Errorf("%w: error getting mempool transaction", whive.ErrTransactionNotFound),
+	).Once()
+	memTransaction, err = servicer.MempoolTransaction(ctx, &types.MempoolTransactionRequest{
+		TransactionIdentifier: &types.TransactionIdentifier{Hash: "orphan"},
+	})
 	assert.Nil(t, memTransaction)
-	assert.Equal(t, ErrUnimplemented.Code, err.Code)
-	assert.Equal(t, ErrUnimplemented.Message, err.Message)
+	assert.Equal(t, ErrUnableToGetCoins.Code, err.Code)
+
 	mockClient.AssertExpectations(t)
+	mockIndexer.AssertExpectations(t)
 }
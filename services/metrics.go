@@ -0,0 +1,44 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/xyephy/rosetta-whive/metrics"
+)
+
+// MetricsMiddleware records the duration of each request
+// to inner in metrics.APIRequestDuration by endpoint.
+// Requests to paths that are not served are recorded
+// under metrics.UnknownEndpoint.
+func MetricsMiddleware(inner http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := NewStatusRecorder(w)
+
+		inner.ServeHTTP(recorder, r)
+
+		endpoint := r.URL.Path
+		if recorder.Code == http.StatusNotFound || recorder.Code == http.StatusMethodNotAllowed {
+			endpoint = metrics.UnknownEndpoint
+		}
+
+		metrics.APIRequestDuration.WithLabelValues(endpoint).Observe(
+			time.Since(start).Seconds(),
+		)
+	})
+}
@@ -0,0 +1,67 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/xyephy/rosetta-whive/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsMiddleware(t *testing.T) {
+	handler := MetricsMiddleware(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/block" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+
+			w.WriteHeader(http.StatusOK)
+		}),
+	)
+
+	count := func(endpoint string) uint64 {
+		m := &dto.Metric{}
+		observer := metrics.APIRequestDuration.WithLabelValues(endpoint)
+		assert.NoError(t, observer.(prometheus.Metric).Write(m))
+
+		return m.GetHistogram().GetSampleCount()
+	}
+
+	serve := func(path string) {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	blocks := count("/block")
+	unknown := count(metrics.UnknownEndpoint)
+
+	serve("/block")
+	serve("/block")
+	assert.Equal(t, blocks+2, count("/block"))
+	assert.Equal(t, unknown, count(metrics.UnknownEndpoint))
+
+	// Paths that are not served are not
+	// recorded under their own endpoint.
+	serve("/not/a/route")
+	assert.Equal(t, unknown+1, count(metrics.UnknownEndpoint))
+	assert.Equal(t, uint64(0), count("/not/a/route"))
+}
@@ -24,6 +24,31 @@ import (
 	"github.com/coinbase/rosetta-sdk-go/types"
 )
 
+const (
+	// syncStageHeaders is reported while whived is still
+	// catching up to the network (so blocks are not yet
+	// available to index).
+	syncStageHeaders = "headers"
+
+	// syncStageBlocks is reported once whived has caught
+	// up to the network but the indexer is still indexing
+	// blocks.
+	syncStageBlocks = "blocks"
+
+	// syncStageDone is reported once the indexer is at
+	// whived's tip.
+	syncStageDone = "done"
+
+	// syncStageUnknown is reported until the indexer first
+	// polls whived's tip (so its progress is not known).
+	syncStageUnknown = "unknown"
+
+	// syncedVerificationProgress is the verification
+	// progress at which we consider whived caught up to
+	// the network. whived's estimate never quite reaches 1.
+	syncedVerificationProgress = 0.9999
+)
+
 // NetworkAPIService implements the server.NetworkAPIServicer interface.
 type NetworkAPIService struct {
 	config *configuration.Configuration
@@ -65,6 +90,10 @@ func (s *NetworkAPIService) NetworkStatus(
 		return nil, wrapErr(ErrUnavailableOffline, nil)
 	}
 
+	if err := s.i.Ready(); err != nil {
+		return nil, wrapErr(ErrNotReady, err)
+	}
+
 	peers, err := s.client.GetPeers(ctx)
 	if err != nil {
 		return nil, wrapErr(ErrWhived, err)
@@ -75,24 +104,99 @@ func (s *NetworkAPIService) NetworkStatus(
 		return nil, wrapErr(ErrNotReady, nil)
 	}
 
+	info, err := s.client.BlockchainInfo(ctx)
+	if err != nil {
+		return nil, wrapErr(ErrWhived, err)
+	}
+
+	blocksBehind, known := s.i.SyncLag()
 	return &types.NetworkStatusResponse{
 		CurrentBlockIdentifier: cachedBlockResponse.Block.BlockIdentifier,
 		CurrentBlockTimestamp:  cachedBlockResponse.Block.Timestamp,
 		GenesisBlockIdentifier: s.config.GenesisBlockIdentifier,
-		Peers:                  peers,
+		SyncStatus: syncStatus(
+			cachedBlockResponse.Block.BlockIdentifier.Index,
+			blocksBehind,
+			known,
+			info,
+		),
+		Peers: peers,
 	}, nil
 }
 
+// syncStatus returns the *types.SyncStatus of an indexer
+// at currentIndex that is blocksBehind whived (with info).
+// While whived is still catching up to the network, we
+// report its progress instead of the indexer's. If the
+// indexer hasn't polled whived yet (known is false), we
+// don't know the target and never report being synced.
+func syncStatus(
+	currentIndex int64,
+	blocksBehind int64,
+	known bool,
+	info *whive.BlockchainInfo,
+) *types.SyncStatus {
+	if info.VerificationProgress < syncedVerificationProgress {
+		return &types.SyncStatus{
+			CurrentIndex: types.Int64(info.Blocks),
+			TargetIndex:  types.Int64(info.Headers),
+			Stage:        types.String(syncStageHeaders),
+			Synced:       types.Bool(false),
+		}
+	}
+
+	if !known {
+		return &types.SyncStatus{
+			CurrentIndex: types.Int64(currentIndex),
+			Stage:        types.String(syncStageUnknown),
+			Synced:       types.Bool(false),
+		}
+	}
+
+	stage := syncStageDone
+	if blocksBehind > 0 {
+		stage = syncStageBlocks
+	}
+
+	return &types.SyncStatus{
+		CurrentIndex: types.Int64(currentIndex),
+		TargetIndex:  types.Int64(currentIndex + blocksBehind),
+		Stage:        types.String(stage),
+		Synced:       types.Bool(blocksBehind == 0),
+	}
+}
+
 // NetworkOptions implements the /network/options endpoint.
 func (s *NetworkAPIService) NetworkOptions(
 	ctx context.Context,
 	request *types.NetworkRequest,
 ) (*types.NetworkOptionsResponse, *types.Error) {
+	// Methods that require -txindex are not
+	// advertised if whived is running without it.
+	callMethods := CallMethods
+	if s.config.Mode == configuration.Online {
+		callMethods = availableCallMethods(s.i.TxIndexEnabled())
+	}
+
+	// Dust thresholds are reported so that clients
+	// agree with /account/balance on what is dust.
+	var metadata map[string]interface{}
+	if s.config.DustRelayFee > 0 {
+		var err error
+		metadata, err = types.MarshalMap(&versionMetadata{
+			DustThresholds: whive.DustThresholds(s.config.DustRelayFee),
+		})
+		if err != nil {
+			return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+		}
+	}
+
 	return &types.NetworkOptionsResponse{
 		Version: &types.Version{
 			RosettaVersion:    types.RosettaAPIVersion,
 			NodeVersion:       NodeVersion,
 			MiddlewareVersion: types.String(MiddlewareVersion),
+			Metadata:          metadata,
 		},
 		Allow: &types.Allow{
 			OperationStatuses:       whive.OperationStatuses,
@@ -100,6 +204,7 @@ func (s *NetworkAPIService) NetworkOptions(
 			Errors:                  Errors,
 			HistoricalBalanceLookup: HistoricalBalanceLookup,
 			MempoolCoins:            MempoolCoins,
+			CallMethods:             callMethods,
 		},
 	}, nil
 }
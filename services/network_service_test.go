@@ -16,12 +16,15 @@ package services
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	"github.com/xyephy/rosetta-whive/configuration"
-	"github.com/xyephy/rosetta-whive/whive"
 	mocks "github.com/xyephy/rosetta-whive/mocks/services"
+	"github.com/xyephy/rosetta-whive/whive"
 
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcutil"
 	"github.com/coinbase/rosetta-sdk-go/types"
 	"github.com/stretchr/testify/assert"
 )
@@ -31,7 +34,7 @@ var (
 	defaultNetworkOptions = &types.NetworkOptionsResponse{
 		Version: &types.Version{
 			RosettaVersion:    types.RosettaAPIVersion,
-			NodeVersion:       "0.20.1",
+			NodeVersion:       "2.0.0",
 			MiddlewareVersion: &middlewareVersion,
 		},
 		Allow: &types.Allow{
@@ -39,6 +42,8 @@ var (
 			OperationTypes:          whive.OperationTypes,
 			Errors:                  Errors,
 			HistoricalBalanceLookup: HistoricalBalanceLookup,
+			CallMethods:             CallMethods,
+			MempoolCoins:            MempoolCoins,
 		},
 	}
 
@@ -102,6 +107,7 @@ func TestNetworkEndpoints_Online(t *testing.T) {
 			},
 		},
 	}
+	mockIndexer.On("Ready").Return(nil)
 	mockClient.On("GetPeers", ctx).Return([]*types.Peer{
 		{
 			PeerID: "77.93.223.9:8333",
@@ -115,11 +121,23 @@ func TestNetworkEndpoints_Online(t *testing.T) {
 		blockResponse,
 		nil,
 	)
+	mockIndexer.On("SyncLag").Return(int64(5), true).Once()
+	mockClient.On("BlockchainInfo", ctx).Return(&whive.BlockchainInfo{
+		Blocks:               105,
+		Headers:              105,
+		VerificationProgress: 0.99999,
+	}, nil).Once()
 	networkStatus, err := servicer.NetworkStatus(ctx, nil)
 	assert.Nil(t, err)
 	assert.Equal(t, &types.NetworkStatusResponse{
 		GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
 		CurrentBlockIdentifier: blockResponse.Block.BlockIdentifier,
+		SyncStatus: &types.SyncStatus{
+			CurrentIndex: types.Int64(100),
+			TargetIndex:  types.Int64(105),
+			Stage:        types.String(syncStageBlocks),
+			Synced:       types.Bool(false),
+		},
 		Peers: []*types.Peer{
 			{
 				PeerID: "77.93.223.9:8333",
@@ -127,6 +145,7 @@ func TestNetworkEndpoints_Online(t *testing.T) {
 		},
 	}, networkStatus)
 
+	mockIndexer.On("TxIndexEnabled").Return(true).Once()
 	networkOptions, err := servicer.NetworkOptions(ctx, nil)
 	assert.Nil(t, err)
 	assert.Equal(t, defaultNetworkOptions, networkOptions)
@@ -134,3 +153,216 @@ func TestNetworkEndpoints_Online(t *testing.T) {
 	mockIndexer.AssertExpectations(t)
 	mockClient.AssertExpectations(t)
 }
+
+func TestNetworkEndpoints_SyncStatus(t *testing.T) {
+	tests := map[string]struct {
+		blocksBehind int64
+		unknown      bool
+		info         *whive.BlockchainInfo
+
+		expected *types.SyncStatus
+	}{
+		"downloading headers": {
+			blocksBehind: 0,
+			info: &whive.BlockchainInfo{
+				Blocks:               50,
+				Headers:              1000,
+				VerificationProgress: 0.2,
+			},
+			expected: &types.SyncStatus{
+				CurrentIndex: types.Int64(50),
+				TargetIndex:  types.Int64(1000),
+				Stage:        types.String(syncStageHeaders),
+				Synced:       types.Bool(false),
+			},
+		},
+		"not yet polled": {
+			unknown: true,
+			info: &whive.BlockchainInfo{
+				Blocks:               1000,
+				Headers:              1000,
+				VerificationProgress: 0.99999,
+			},
+			expected: &types.SyncStatus{
+				CurrentIndex: types.Int64(100),
+				Stage:        types.String(syncStageUnknown),
+				Synced:       types.Bool(false),
+			},
+		},
+		"indexing blocks": {
+			blocksBehind: 900,
+			info: &whive.BlockchainInfo{
+				Blocks:               1000,
+				Headers:              1000,
+				VerificationProgress: 0.99999,
+			},
+			expected: &types.SyncStatus{
+				CurrentIndex: types.Int64(100),
+				TargetIndex:  types.Int64(1000),
+				Stage:        types.String(syncStageBlocks),
+				Synced:       types.Bool(false),
+			},
+		},
+		"done": {
+			blocksBehind: 0,
+			info: &whive.BlockchainInfo{
+				Blocks:               100,
+				Headers:              100,
+				VerificationProgress: 0.99999,
+			},
+			expected: &types.SyncStatus{
+				CurrentIndex: types.Int64(100),
+				TargetIndex:  types.Int64(100),
+				Stage:        types.String(syncStageDone),
+				Synced:       types.Bool(true),
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := &configuration.Configuration{
+				Mode:                   configuration.Online,
+				Network:                networkIdentifier,
+				GenesisBlockIdentifier: whive.MainnetGenesisBlockIdentifier,
+			}
+			mockIndexer := &mocks.Indexer{}
+			mockClient := &mocks.Client{}
+			servicer := NewNetworkAPIService(cfg, mockClient, mockIndexer)
+			ctx := context.Background()
+
+			mockIndexer.On("Ready").Return(nil).Once()
+			mockClient.On("GetPeers", ctx).Return([]*types.Peer{}, nil).Once()
+			mockIndexer.On(
+				"GetBlockLazy",
+				ctx,
+				(*types.PartialBlockIdentifier)(nil),
+			).Return(
+				&types.BlockResponse{
+					Block: &types.Block{
+						BlockIdentifier: &types.BlockIdentifier{
+							Index: 100,
+							Hash:  "block 100",
+						},
+					},
+				},
+				nil,
+			).Once()
+			mockIndexer.On("SyncLag").Return(test.blocksBehind, !test.unknown).Once()
+			mockClient.On("BlockchainInfo", ctx).Return(test.info, nil).Once()
+
+			networkStatus, err := servicer.NetworkStatus(ctx, nil)
+			assert.Nil(t, err)
+			assert.Equal(t, test.expected, networkStatus.SyncStatus)
+
+			mockIndexer.AssertExpectations(t)
+			mockClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestNetworkEndpoints_TxIndexDisabled(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode:    configuration.Online,
+		Network: networkIdentifier,
+	}
+	mockIndexer := &mocks.Indexer{}
+	mockClient := &mocks.Client{}
+	servicer := NewNetworkAPIService(cfg, mockClient, mockIndexer)
+	ctx := context.Background()
+
+	mockIndexer.On("TxIndexEnabled").Return(false).Once()
+	networkOptions, err := servicer.NetworkOptions(ctx, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		CallMethodStorageSize,
+		CallMethodScriptBalance,
+		CallMethodDifficultyHistory,
+		CallMethodRPCLatency,
+		CallMethodFeeRateConfirmation,
+		CallMethodCheckpoint,
+		CallMethodFirstSeen,
+		CallMethodMedianFeeRate,
+	}, networkOptions.Allow.CallMethods)
+
+	mockIndexer.AssertExpectations(t)
+	mockClient.AssertExpectations(t)
+}
+
+func TestNetworkEndpoints_DustThresholds(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode:         configuration.Offline,
+		Network:      networkIdentifier,
+		Params:       whive.TestnetParams,
+		DustRelayFee: 3000,
+	}
+	servicer := NewNetworkAPIService(cfg, nil, nil)
+	ctx := context.Background()
+
+	networkOptions, rErr := servicer.NetworkOptions(ctx, nil)
+	assert.Nil(t, rErr)
+
+	var metadata versionMetadata
+	assert.NoError(t, types.UnmarshalMap(networkOptions.Version.Metadata, &metadata))
+
+	// These match whived's thresholds at the default
+	// dust relay fee of 3000 satoshis per kvB.
+	assert.Equal(t, map[string]int64{
+		whive.PubKeyHash:          546,
+		whive.ScriptHash:          540,
+		whive.WitnessV0PubKeyHash: 294,
+		whive.WitnessV0ScriptHash: 330,
+		whive.WitnessV1Taproot:    330,
+	}, metadata.DustThresholds)
+
+	// The thresholds match those computed
+	// for addresses in /account/balance.
+	p2pkh, err := btcutil.NewAddressPubKeyHash(make([]byte, 20), cfg.Params)
+	assert.NoError(t, err)
+	p2sh, err := btcutil.NewAddressScriptHashFromHash(make([]byte, 20), cfg.Params)
+	assert.NoError(t, err)
+	p2wpkh, err := btcutil.NewAddressWitnessPubKeyHash(make([]byte, 20), cfg.Params)
+	assert.NoError(t, err)
+	p2wsh, err := btcutil.NewAddressWitnessScriptHash(make([]byte, 32), cfg.Params)
+	assert.NoError(t, err)
+	for scriptType, address := range map[string]btcutil.Address{
+		whive.PubKeyHash:          p2pkh,
+		whive.ScriptHash:          p2sh,
+		whive.WitnessV0PubKeyHash: p2wpkh,
+		whive.WitnessV0ScriptHash: p2wsh,
+	} {
+		script, err := txscript.PayToAddrScript(address)
+		assert.NoError(t, err)
+		assert.Equal(
+			t,
+			whive.DustThreshold(script, cfg.DustRelayFee),
+			metadata.DustThresholds[scriptType],
+		)
+	}
+
+	// Thresholds are not reported without a dust relay fee.
+	cfg.DustRelayFee = 0
+	networkOptions, rErr = servicer.NetworkOptions(ctx, nil)
+	assert.Nil(t, rErr)
+	assert.Nil(t, networkOptions.Version.Metadata)
+}
+
+func TestNetworkEndpoints_NotReady(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode:    configuration.Online,
+		Network: networkIdentifier,
+	}
+	mockIndexer := &mocks.Indexer{}
+	mockClient := &mocks.Client{}
+	servicer := NewNetworkAPIService(cfg, mockClient, mockIndexer)
+	ctx := context.Background()
+
+	mockIndexer.On("Ready").Return(errors.New("free disk space is below minimum"))
+	networkStatus, err := servicer.NetworkStatus(ctx, nil)
+	assert.Nil(t, networkStatus)
+	assert.Equal(t, ErrNotReady.Code, err.Code)
+	assert.True(t, err.Retriable)
+
+	mockIndexer.AssertExpectations(t)
+	mockClient.AssertExpectations(t)
+}
@@ -43,7 +43,7 @@ func NewBlockchainRouter(
 		asserter,
 	)
 
-	accountAPIService := NewAccountAPIService(config, i)
+	accountAPIService := NewAccountAPIService(config, client, i)
 	accountAPIController := server.NewAccountAPIController(
 		accountAPIService,
 		asserter,
@@ -55,17 +55,26 @@ func NewBlockchainRouter(
 		asserter,
 	)
 
-	mempoolAPIService := NewMempoolAPIService(config, client)
+	mempoolAPIService := NewMempoolAPIService(config, client, i)
 	mempoolAPIController := server.NewMempoolAPIController(
 		mempoolAPIService,
 		asserter,
 	)
 
-	return server.NewRouter(
+	callAPIService := NewCallAPIService(config, client, i)
+	callAPIController := server.NewCallAPIController(
+		callAPIService,
+		asserter,
+	)
+
+	router := server.NewRouter(
 		networkAPIController,
 		blockAPIController,
 		accountAPIController,
 		constructionAPIController,
 		mempoolAPIController,
+		callAPIController,
 	)
+
+	return declaredErrorsMiddleware(router)
 }
@@ -0,0 +1,193 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/xyephy/rosetta-whive/configuration"
+	"github.com/xyephy/rosetta-whive/whive"
+
+	"github.com/coinbase/rosetta-sdk-go/asserter"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlockchainRouter_Offline(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode:     configuration.Offline,
+		Network:  networkIdentifier,
+		Params:   whive.MainnetParams,
+		Currency: whive.MainnetCurrency,
+	}
+	serverAsserter, err := asserter.NewServer(
+		whive.OperationTypes,
+		HistoricalBalanceLookup,
+		[]*types.NetworkIdentifier{networkIdentifier},
+		CallMethods,
+		MempoolCoins,
+		"",
+	)
+	assert.NoError(t, err)
+
+	// There is no client or indexer in offline mode, so
+	// endpoints that depend on them must not use them.
+	router := NewBlockchainRouter(cfg, nil, nil, serverAsserter)
+	serve := func(path string, request interface{}) *httptest.ResponseRecorder {
+		body, err := json.Marshal(request)
+		assert.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	account := &types.AccountIdentifier{Address: "1Q7WZSUYmpUHqxqbzbsEfCvpu2gkJuxEXT"}
+	blockIdentifier := &types.BlockIdentifier{Index: 1, Hash: "block 1"}
+	transactionIdentifier := &types.TransactionIdentifier{Hash: "tx"}
+	onlineRequests := map[string]interface{}{
+		"/network/status": &types.NetworkRequest{
+			NetworkIdentifier: networkIdentifier,
+		},
+		"/block": &types.BlockRequest{
+			NetworkIdentifier: networkIdentifier,
+			BlockIdentifier:   types.ConstructPartialBlockIdentifier(blockIdentifier),
+		},
+		"/block/transaction": &types.BlockTransactionRequest{
+			NetworkIdentifier:     networkIdentifier,
+			BlockIdentifier:       blockIdentifier,
+			TransactionIdentifier: transactionIdentifier,
+		},
+		"/account/balance": &types.AccountBalanceRequest{
+			NetworkIdentifier: networkIdentifier,
+			AccountIdentifier: account,
+		},
+		"/account/coins": &types.AccountCoinsRequest{
+			NetworkIdentifier: networkIdentifier,
+			AccountIdentifier: account,
+		},
+		"/mempool": &types.NetworkRequest{
+			NetworkIdentifier: networkIdentifier,
+		},
+		"/mempool/transaction": &types.MempoolTransactionRequest{
+			NetworkIdentifier:     networkIdentifier,
+			TransactionIdentifier: transactionIdentifier,
+		},
+		"/construction/metadata": &types.ConstructionMetadataRequest{
+			NetworkIdentifier: networkIdentifier,
+			Options:           map[string]interface{}{},
+		},
+		"/construction/submit": &types.ConstructionSubmitRequest{
+			NetworkIdentifier: networkIdentifier,
+			SignedTransaction: "signed transaction",
+		},
+		"/call": &types.CallRequest{
+			NetworkIdentifier: networkIdentifier,
+			Method:            CallMethods[0],
+			Parameters:        map[string]interface{}{},
+		},
+	}
+
+	for path, request := range onlineRequests {
+		t.Run(path, func(t *testing.T) {
+			rec := serve(path, request)
+			assert.Equal(t, http.StatusInternalServerError, rec.Code)
+
+			var rosettaErr types.Error
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &rosettaErr))
+			assert.Equal(t, ErrUnavailableOffline.Code, rosettaErr.Code)
+			assert.Equal(t, ErrUnavailableOffline.Message, rosettaErr.Message)
+		})
+	}
+
+	// Endpoints that don't depend on whived are still
+	// served (even if the request itself is invalid).
+	offlineRequests := map[string]interface{}{
+		"/network/list": &types.MetadataRequest{},
+		"/network/options": &types.NetworkRequest{
+			NetworkIdentifier: networkIdentifier,
+		},
+		"/construction/hash": &types.ConstructionHashRequest{
+			NetworkIdentifier: networkIdentifier,
+			SignedTransaction: "signed transaction",
+		},
+		"/construction/parse": &types.ConstructionParseRequest{
+			NetworkIdentifier: networkIdentifier,
+			Signed:            true,
+			Transaction:       "signed transaction",
+		},
+	}
+
+	for path, request := range offlineRequests {
+		t.Run(path, func(t *testing.T) {
+			rec := serve(path, request)
+			if rec.Code == http.StatusOK {
+				return
+			}
+
+			var rosettaErr types.Error
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &rosettaErr))
+			assert.NotEqual(t, ErrUnavailableOffline.Code, rosettaErr.Code)
+		})
+	}
+}
+
+func TestBlockchainRouter_InvalidRequest(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode:    configuration.Offline,
+		Network: networkIdentifier,
+	}
+	serverAsserter, err := asserter.NewServer(
+		whive.OperationTypes,
+		HistoricalBalanceLookup,
+		[]*types.NetworkIdentifier{networkIdentifier},
+		CallMethods,
+		MempoolCoins,
+		"",
+	)
+	assert.NoError(t, err)
+	router := NewBlockchainRouter(cfg, nil, nil, serverAsserter)
+
+	tests := map[string]string{
+		"malformed":       `{"network_identifier":`,
+		"unknown network": `{"network_identifier":{"blockchain":"Whive","network":"Unknown"}}`,
+	}
+
+	for name, body := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/network/options", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			assert.Equal(t, http.StatusInternalServerError, rec.Code)
+
+			// Errors returned by the rosetta-sdk-go controllers
+			// are replaced with an error in Errors.
+			var rosettaErr types.Error
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &rosettaErr))
+			assert.Equal(t, ErrInvalidRequest.Code, rosettaErr.Code)
+			assert.Equal(t, ErrInvalidRequest.Message, rosettaErr.Message)
+			assert.NotEmpty(t, rosettaErr.Details["context"])
+		})
+	}
+}
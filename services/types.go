@@ -17,6 +17,7 @@ package services
 import (
 	"context"
 
+	"github.com/xyephy/rosetta-whive/metrics"
 	"github.com/xyephy/rosetta-whive/whive"
 
 	"github.com/coinbase/rosetta-sdk-go/types"
@@ -33,13 +34,44 @@ const (
 
 	// MempoolCoins indicates that
 	// including mempool coins in the /account/coins
-	// response is not supported.
-	MempoolCoins = false
+	// response is supported.
+	MempoolCoins = true
 
-	// inlineFetchLimit is the maximum number
-	// of transactions to fetch inline.
+	// inlineFetchLimit is the default maximum
+	// number of transactions to fetch inline.
 	inlineFetchLimit = 100
 
+	// segwitMarkerSize is the size of the marker and
+	// flag bytes of a serialized segwit transaction.
+	segwitMarkerSize = 2
+
+	// p2wpkhWitnessSize is the maximum size of a P2WPKH
+	// witness: the item count, a 72 byte signature (including
+	// the sighash type), and a 33 byte compressed public key
+	// (each prefixed with its length).
+	p2wpkhWitnessSize = 1 + 1 + 72 + 1 + 33
+
+	// p2shP2WPKHScriptSigSize is the size of the scriptSig
+	// of an input spending a P2SH-wrapped P2WPKH output: a
+	// push of the 22 byte P2WPKH witness program.
+	p2shP2WPKHScriptSigSize = 1 + 22
+
+	// p2pkhScriptSigSize is the maximum size of the scriptSig
+	// of an input spending a P2PKH output: a 72 byte signature
+	// (including the sighash type) and a 65 byte uncompressed
+	// public key (each prefixed with its length).
+	p2pkhScriptSigSize = 1 + 72 + 1 + 65
+
+	// strippedOverheadSize is the size of the version, input
+	// count, output count, and lock time of a transaction
+	// (excluding the witness).
+	strippedOverheadSize = 4 + 1 + 1 + 4
+
+	// strippedInputSize is the size of an input spending a
+	// P2WPKH output (excluding the witness): the previous
+	// outpoint, an empty script, and the sequence.
+	strippedInputSize = 32 + 4 + 1 + 4
+
 	// MiddlewareVersion is the version
 	// of rosetta-whive. We set this as a
 	// variable instead of a constant because
@@ -54,7 +86,22 @@ type Client interface {
 	GetPeers(context.Context) ([]*types.Peer, error)
 	SendRawTransaction(context.Context, string) (string, error)
 	SuggestedFeeRate(context.Context, int64) (float64, error)
+	MempoolMinFeeRate(context.Context) (float64, error)
 	RawMempool(context.Context) ([]string, error)
+	MempoolTransaction(context.Context, string) (*types.Transaction, error)
+	TransactionBlock(context.Context, string) (*types.BlockIdentifier, error)
+	BlockHeader(context.Context, int64) (*whive.BlockHeader, error)
+	BlockchainInfo(context.Context) (*whive.BlockchainInfo, error)
+	MempoolBalance(
+		context.Context,
+		*types.AccountIdentifier,
+		[]*types.Coin,
+	) (*types.Amount, error)
+	MempoolCoins(
+		context.Context,
+		*types.AccountIdentifier,
+		[]*types.Coin,
+	) ([]*types.Coin, error)
 }
 
 // Indexer is used by the servicers to get block and account data.
@@ -76,38 +123,245 @@ type Indexer interface {
 		context.Context,
 		[]*types.Coin,
 	) ([]*whive.ScriptPubKey, error)
+	GetCoin(
+		context.Context,
+		*types.CoinIdentifier,
+	) (*types.Coin, *types.AccountIdentifier, error)
+	GetCoinBlocks(
+		context.Context,
+		[]*types.Coin,
+	) (map[string]*types.BlockIdentifier, error)
 	GetBalance(
 		context.Context,
 		*types.AccountIdentifier,
 		*types.Currency,
 		*types.PartialBlockIdentifier,
 	) (*types.Amount, *types.BlockIdentifier, error)
+	GetFirstSeen(
+		context.Context,
+		*types.AccountIdentifier,
+	) (*types.BlockIdentifier, int64, error)
+	Ready() error
+	StorageSize() uint64
+	SyncLag() (int64, bool)
+	TxIndexEnabled() bool
 }
 
 type unsignedTransaction struct {
-	Transaction    string                          `json:"transaction"`
+	Transaction    string                `json:"transaction"`
 	ScriptPubKeys  []*whive.ScriptPubKey `json:"scriptPubKeys"`
-	InputAmounts   []string                        `json:"input_amounts"`
-	InputAddresses []string                        `json:"input_addresses"`
+	InputAmounts   []string              `json:"input_amounts"`
+	InputAddresses []string              `json:"input_addresses"`
+	ChangeOutputs  []int64               `json:"change_outputs,omitempty"`
+}
+
+type preprocessMetadata struct {
+	Inputs          []string `json:"inputs,omitempty"`
+	IdempotencyKey  string   `json:"idempotency_key,omitempty"`
+	MatchChangeType bool     `json:"match_change_type,omitempty"`
 }
 
 type preprocessOptions struct {
 	Coins         []*types.Coin `json:"coins"`
 	EstimatedSize float64       `json:"estimated_size"`
 	FeeMultiplier *float64      `json:"fee_multiplier,omitempty"`
+
+	// EstimatedWeight is in weight units.
+	EstimatedWeight int64 `json:"estimated_weight,omitempty"`
+
+	// Only populated when inputs are explicitly selected.
+	InputAccounts []*types.AccountIdentifier `json:"input_accounts,omitempty"`
+	OutputTotal   string                     `json:"output_total,omitempty"`
+
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+
+	MatchChangeType bool `json:"match_change_type,omitempty"`
 }
 
 type constructionMetadata struct {
 	ScriptPubKeys []*whive.ScriptPubKey `json:"script_pub_keys"`
+	Coins         []*types.Coin         `json:"coins"`
+
+	// EstimatedWeight (in weight units) assumes each input
+	// is signed with a signature of the maximum size.
+	EstimatedWeight int64 `json:"estimated_weight,omitempty"`
+
+	// Only populated when match_change_type is provided
+	// to /construction/preprocess.
+	ChangeAddressType string `json:"change_address_type,omitempty"`
+
+	// Timestamp (in milliseconds) is when the fee was
+	// estimated. Only populated when a maximum metadata
+	// age is configured.
+	Timestamp int64 `json:"timestamp,omitempty"`
+}
+
+type deriveMetadata struct {
+	AddressType string `json:"address_type,omitempty"`
+
+	// Compressed defaults to true. Legacy wallets may
+	// have used uncompressed public keys, which can
+	// only be derived as pubkeyhash addresses.
+	Compressed *bool `json:"compressed,omitempty"`
+}
+
+type versionMetadata struct {
+	// DustThresholds (in satoshis) are keyed by
+	// ScriptPubKey.Type. Only populated when a
+	// dust relay fee is configured.
+	DustThresholds map[string]int64 `json:"dust_thresholds,omitempty"`
+}
+
+type balanceMetadata struct {
+	UnconfirmedBalance *types.Amount `json:"unconfirmed_balance,omitempty"`
+
+	// Only populated when a dust relay fee is configured.
+	DustBalance *types.Amount `json:"dust_balance,omitempty"`
+	DustOnly    *bool         `json:"dust_only,omitempty"`
+}
+
+type coinMetadata struct {
+	CreatedHeight *int64 `json:"created_height,omitempty"`
+	Confirmations int64  `json:"confirmations"`
+
+	// The outpoint of the coin (parsed from
+	// the coin identifier).
+	TransactionIdentifier *types.TransactionIdentifier `json:"transaction_identifier,omitempty"`
+	OutputIndex           *int64                       `json:"output_index,omitempty"`
+}
+
+type accountCoinsMetadata struct {
+	Coins map[string]*coinMetadata `json:"coins"`
+}
+
+type parseMetadata struct {
+	Fee              string  `json:"fee"`
+	Vsize            int64   `json:"vsize"`
+	EffectiveFeeRate float64 `json:"effective_fee_rate"`
+
+	InputSequences []uint32 `json:"input_sequences"`
+	LockTime       uint32   `json:"lock_time"`
+	SignalsRBF     bool     `json:"signals_rbf"`
+
+	// Only populated for signed transactions. Witnesses
+	// are omitted if no input has a witness.
+	InputScriptSigs []string   `json:"input_script_sigs,omitempty"`
+	InputWitnesses  [][]string `json:"input_witnesses,omitempty"`
 }
 
 type signedTransaction struct {
-	Transaction  string   `json:"transaction"`
-	InputAmounts []string `json:"input_amounts"`
+	Transaction   string   `json:"transaction"`
+	InputAmounts  []string `json:"input_amounts"`
+	ChangeOutputs []int64  `json:"change_outputs,omitempty"`
+}
+
+// payloadsOutputMetadata is the metadata of
+// OUTPUT operations in /construction/payloads.
+type payloadsOutputMetadata struct {
+	Change bool `json:"change"`
+}
+
+type transactionBlockParameters struct {
+	TransactionIdentifier *types.TransactionIdentifier `json:"transaction_identifier"`
+}
+
+type transactionBlockResult struct {
+	Status          string                 `json:"status"`
+	BlockIdentifier *types.BlockIdentifier `json:"block_identifier,omitempty"`
+
+	// Only populated for unconfirmed transactions.
+	SignalsRBF *bool `json:"signals_rbf,omitempty"`
+}
+
+type transactionFinalityResult struct {
+	Status          string                 `json:"status"`
+	BlockIdentifier *types.BlockIdentifier `json:"block_identifier,omitempty"`
+	SignalsRBF      *bool                  `json:"signals_rbf,omitempty"`
+	Confirmations   int64                  `json:"confirmations"`
+	FinalityDepth   int64                  `json:"finality_depth"`
+	Final           bool                   `json:"final"`
 }
 
 // ParseOperationMetadata is returned from
 // ConstructionParse.
 type ParseOperationMetadata struct {
-	ScriptPubKey *whive.ScriptPubKey `json:"scriptPubKey"`
+	ScriptPubKey *whive.ScriptPubKey `json:"scriptPubKey,omitempty"`
+
+	// Change is true for outputs that were marked
+	// as change in /construction/payloads.
+	Change bool `json:"change,omitempty"`
+}
+
+type storageSizeResult struct {
+	Bytes uint64 `json:"bytes"`
+}
+
+type rpcLatencyResult struct {
+	Methods map[string]*metrics.LatencySummary `json:"methods"`
+}
+
+type firstSeenParameters struct {
+	AccountIdentifier *types.AccountIdentifier `json:"account_identifier"`
+}
+
+type firstSeenResult struct {
+	// BlockIdentifier is the first block in which the
+	// account received a coin. It is omitted if the
+	// account has not received a coin.
+	BlockIdentifier *types.BlockIdentifier `json:"block_identifier,omitempty"`
+
+	// TrackedSinceIndex is the index of the first block
+	// for which first seen blocks were recorded. Accounts
+	// funded before this block may be reported as first
+	// seen later (or not at all).
+	TrackedSinceIndex int64 `json:"tracked_since_index"`
+}
+
+type checkpointResult struct {
+	BlockIdentifier *types.BlockIdentifier `json:"block_identifier,omitempty"`
+}
+
+type feeRateConfirmationParameters struct {
+	FeeRate *float64 `json:"fee_rate"`
+}
+
+type feeRateConfirmationResult struct {
+	Confirmable      bool  `json:"confirmable"`
+	EstimatedBlocks  int64 `json:"estimated_blocks,omitempty"`
+	EstimatedSeconds int64 `json:"estimated_seconds,omitempty"`
+
+	// Only populated when the fee rate
+	// is below all estimates.
+	MinimumFeeRate float64 `json:"minimum_fee_rate,omitempty"`
+}
+
+type medianFeeRateParameters struct {
+	Blocks *int64 `json:"blocks"`
+}
+
+type medianFeeRateResult struct {
+	MedianFeeRate   float64                `json:"median_fee_rate"`
+	Blocks          int64                  `json:"blocks"`
+	Transactions    int                    `json:"transactions"`
+	BlockIdentifier *types.BlockIdentifier `json:"block_identifier"`
+}
+
+type difficultyHistoryParameters struct {
+	StartIndex *int64 `json:"start_index"`
+	EndIndex   *int64 `json:"end_index"`
+}
+
+type difficultyHistoryResult struct {
+	RetargetInterval int64                `json:"retarget_interval"`
+	Headers          []*whive.BlockHeader `json:"headers"`
+}
+
+type scriptBalanceParameters struct {
+	ScriptPubKey string `json:"script_pub_key"`
+}
+
+type scriptBalanceResult struct {
+	AccountIdentifier *types.AccountIdentifier `json:"account_identifier"`
+	Balance           *types.Amount            `json:"balance"`
+	BlockIdentifier   *types.BlockIdentifier   `json:"block_identifier"`
 }
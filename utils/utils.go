@@ -16,6 +16,10 @@ package utils
 
 import (
 	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
 	"time"
 
 	sdkUtils "github.com/coinbase/rosetta-sdk-go/utils"
@@ -76,3 +80,37 @@ func MonitorMemoryUsage(
 
 	return ctx.Err()
 }
+
+// DiskFree returns the number of bytes available
+// to an unprivileged user on the filesystem
+// containing path.
+func DiskFree(path string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, fmt.Errorf("%w: unable to stat filesystem %s", err, path)
+	}
+
+	return stat.Bavail * uint64(stat.Bsize), nil
+}
+
+// DirSize returns the total number of bytes used
+// by all regular files in the directory at path.
+func DirSize(path string) (uint64, error) {
+	var size uint64
+	err := filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.Mode().IsRegular() {
+			size += uint64(info.Size())
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("%w: unable to walk directory %s", err, path)
+	}
+
+	return size, nil
+}
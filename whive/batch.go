@@ -0,0 +1,215 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package whive
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// batchableMethods are the read requests that
+// may be sent to whived in a JSON-RPC batch.
+var batchableMethods = map[requestMethod]bool{
+	requestMethodGetBlock:          true,
+	requestMethodGetBlockHash:      true,
+	requestMethodGetBlockHeader:    true,
+	requestMethodGetRawTransaction: true,
+}
+
+// batchCall is a JSON-RPC call waiting
+// to be sent in a batch.
+type batchCall struct {
+	request  *request
+	response jSONRPCResponse
+	done     chan error
+}
+
+// batchResponse is used to match a response
+// in a JSON-RPC batch to its request.
+type batchResponse struct {
+	ID int `json:"id"`
+}
+
+// batcher coalesces requests made while other
+// requests are in flight into JSON-RPC batches.
+//
+// To avoid adding latency to requests that are
+// not made concurrently, a request is sent
+// immediately when no other requests are in flight.
+type batcher struct {
+	client *Client
+	window time.Duration
+
+	mutex    sync.Mutex
+	inFlight int
+	pending  []*batchCall
+}
+
+func newBatcher(client *Client, window time.Duration) *batcher {
+	return &batcher{
+		client: client,
+		window: window,
+	}
+}
+
+// call makes a JSON-RPC call, adding it to the
+// current batch if other requests are in flight.
+func (b *batcher) call(
+	ctx context.Context,
+	method requestMethod,
+	params []interface{},
+	response jSONRPCResponse,
+) error {
+	b.mutex.Lock()
+	if b.inFlight == 0 && len(b.pending) == 0 {
+		b.inFlight++
+		b.mutex.Unlock()
+
+		err := b.client.postSingle(ctx, method, params, response)
+
+		b.mutex.Lock()
+		b.inFlight--
+		b.mutex.Unlock()
+
+		return err
+	}
+
+	call := &batchCall{
+		request: &request{
+			JSONRPC: jSONRPCVersion,
+			ID:      len(b.pending),
+			Method:  string(method),
+			Params:  params,
+		},
+		response: response,
+		done:     make(chan error, 1),
+	}
+	b.pending = append(b.pending, call)
+
+	// The first call in a batch schedules
+	// the batch to be sent.
+	if len(b.pending) == 1 {
+		time.AfterFunc(b.window, b.flush)
+	}
+	b.mutex.Unlock()
+
+	select {
+	case err := <-call.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// flush sends all pending calls in a single
+// JSON-RPC batch request.
+func (b *batcher) flush() {
+	b.mutex.Lock()
+	calls := b.pending
+	b.pending = nil
+	b.inFlight++
+	b.mutex.Unlock()
+
+	defer func() {
+		b.mutex.Lock()
+		b.inFlight--
+		b.mutex.Unlock()
+	}()
+
+	requests := make([]*request, len(calls))
+	for i, call := range calls {
+		requests[i] = call.request
+	}
+
+	// Calls wait for their own context, so we don't
+	// cancel a batch when a single call is canceled.
+	var raw []json.RawMessage
+	if err := b.client.postJSON(context.Background(), requests, &raw, true); err != nil {
+		for _, call := range calls {
+			call.done <- fmt.Errorf("%w: error posting batch", err)
+		}
+
+		return
+	}
+
+	responses := make([]jSONRPCResponse, len(calls))
+	for i, call := range calls {
+		responses[i] = call.response
+	}
+
+	for i, err := range decodeBatch(raw, requests, responses) {
+		calls[i].done <- err
+	}
+}
+
+// postBatch sends requests in a single JSON-RPC batch
+// and decodes each response into the element of responses
+// with the same index. The returned errors correspond to
+// each request (unlike the returned error, which is only
+// populated if the batch itself could not be posted).
+func (b *Client) postBatch(
+	ctx context.Context,
+	requests []*request,
+	responses []jSONRPCResponse,
+) ([]error, error) {
+	var raw []json.RawMessage
+	if err := b.postJSON(ctx, requests, &raw, true); err != nil {
+		return nil, fmt.Errorf("%w: error posting batch", err)
+	}
+
+	return decodeBatch(raw, requests, responses), nil
+}
+
+// decodeBatch matches each raw response in a JSON-RPC batch
+// to its request (by id, as whived may respond in any order)
+// and decodes it into the element of responses with the
+// same index, returning the error of each request.
+func decodeBatch(
+	raw []json.RawMessage,
+	requests []*request,
+	responses []jSONRPCResponse,
+) []error {
+	errs := make([]error, len(requests))
+	received := make([]bool, len(requests))
+	for _, rawResponse := range raw {
+		var id batchResponse
+		if err := json.Unmarshal(rawResponse, &id); err != nil || id.ID < 0 ||
+			id.ID >= len(requests) {
+			continue
+		}
+
+		if received[id.ID] {
+			continue
+		}
+
+		if err := json.Unmarshal(rawResponse, responses[id.ID]); err != nil {
+			errs[id.ID] = fmt.Errorf("%w: error decoding response body", err)
+		} else {
+			errs[id.ID] = responses[id.ID].Err()
+		}
+		received[id.ID] = true
+	}
+
+	for i, r := range requests {
+		if !received[i] {
+			errs[i] = fmt.Errorf("no response to %s in batch", r.Method)
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,124 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package whive
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatcher(t *testing.T) {
+	transactionResult := func(r *request) json.RawMessage {
+		raw, err := json.Marshal(map[string]interface{}{
+			"result": map[string]interface{}{"txid": r.Params[0]},
+			"error":  nil,
+			"id":     r.ID,
+		})
+		assert.NoError(t, err)
+		return raw
+	}
+
+	singleReceived := make(chan struct{})
+	releaseSingle := make(chan struct{})
+	var batchesMutex sync.Mutex
+	batches := [][]*request{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		assert.NoError(t, err)
+
+		if body[0] != '[' {
+			var single request
+			assert.NoError(t, json.Unmarshal(body, &single))
+
+			// Hold the single request in flight so
+			// that later requests are batched.
+			close(singleReceived)
+			<-releaseSingle
+			fmt.Fprintln(w, string(transactionResult(&single)))
+			return
+		}
+
+		var batch []*request
+		assert.NoError(t, json.Unmarshal(body, &batch))
+		batchesMutex.Lock()
+		batches = append(batches, batch)
+		batchesMutex.Unlock()
+
+		// Respond out of order to ensure responses
+		// are matched to requests by id.
+		responses := []json.RawMessage{}
+		for i := len(batch) - 1; i >= 0; i-- {
+			responses = append(responses, transactionResult(batch[i]))
+		}
+		assert.NoError(t, json.NewEncoder(w).Encode(responses))
+	}))
+	defer ts.Close()
+
+	client := NewClient(
+		ts.URL,
+		MainnetGenesisBlockIdentifier,
+		MainnetCurrency,
+		WithBatchWindow(50*time.Millisecond),
+	)
+	ctx := context.Background()
+
+	// A request made when no others are in flight
+	// is sent immediately.
+	singleDone := make(chan error)
+	go func() {
+		tx, err := client.getMempoolTransaction(ctx, "tx 0")
+		if err == nil && tx.Hash != "tx 0" {
+			err = fmt.Errorf("unexpected transaction %s", tx.Hash)
+		}
+		singleDone <- err
+	}()
+	<-singleReceived
+
+	// Concurrent requests made within the window
+	// are sent in a single batch.
+	var g sync.WaitGroup
+	for i := 1; i <= 3; i++ {
+		g.Add(1)
+		go func(i int) {
+			defer g.Done()
+
+			hash := fmt.Sprintf("tx %d", i)
+			tx, err := client.getMempoolTransaction(ctx, hash)
+			assert.NoError(t, err)
+			assert.Equal(t, hash, tx.Hash)
+		}(i)
+	}
+	g.Wait()
+
+	batchesMutex.Lock()
+	assert.Len(t, batches, 1)
+	assert.Len(t, batches[0], 3)
+	for _, r := range batches[0] {
+		assert.Equal(t, string(requestMethodGetRawTransaction), r.Method)
+	}
+	batchesMutex.Unlock()
+
+	close(releaseSingle)
+	assert.NoError(t, <-singleDone)
+}
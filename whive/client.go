@@ -17,20 +17,32 @@ package whive
 import (
 	"bytes"
 	"context"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"math/big"
+	"math/rand"
 	"net"
 	"net/http"
 	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
+	"github.com/xyephy/rosetta-whive/metrics"
 	bitcoinUtils "github.com/xyephy/rosetta-whive/utils"
 
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcutil"
 	"github.com/coinbase/rosetta-sdk-go/types"
 	"github.com/coinbase/rosetta-sdk-go/utils"
+	"golang.org/x/sync/semaphore"
 )
 
 const (
@@ -51,6 +63,21 @@ const (
 	// * 1 returns the JSON representation
 	// * 2 returns the JSON representation with included Transaction data
 	blockVerbosity = 2
+
+	// witnessCommitmentPrefix is the hex encoded prefix of the coinbase
+	// output that commits to the witness data in a block
+	// (OP_RETURN OP_PUSHBYTES_36 0xaa21a9ed).
+	// https://github.com/bitcoin/bips/blob/master/bip-0141.mediawiki#commitment-structure
+	witnessCommitmentPrefix = "6a24aa21a9ed"
+
+	// witnessCommitmentSize is the minimum size (in bytes) of
+	// a witness commitment script.
+	witnessCommitmentSize = 38
+
+	// rawTransactionsBatchSize is the maximum number of
+	// transactions we request in a single JSON-RPC batch
+	// when fetching the mempool.
+	rawTransactionsBatchSize = 100
 )
 
 type requestMethod string
@@ -80,14 +107,96 @@ const (
 	// https://developer.bitcoin.org/reference/rpc/getrawmempool.html
 	requestMethodRawMempool requestMethod = "getrawmempool"
 
+	// https://developer.bitcoin.org/reference/rpc/getmempoolinfo.html
+	requestMethodGetMempoolInfo requestMethod = "getmempoolinfo"
+
+	// https://developer.bitcoin.org/reference/rpc/getrawtransaction.html
+	requestMethodGetRawTransaction requestMethod = "getrawtransaction"
+
+	// https://developer.bitcoin.org/reference/rpc/getblockheader.html
+	requestMethodGetBlockHeader requestMethod = "getblockheader"
+
 	// blockNotFoundErrCode is the RPC error code when a block cannot be found
 	blockNotFoundErrCode = -5
+
+	// miscErrCode is the RPC error code returned (with
+	// blockPrunedErrMessage) when a block has been pruned
+	miscErrCode = -1
+
+	// invalidAddressOrKeyErrCode is the RPC error code when
+	// a transaction cannot be found
+	invalidAddressOrKeyErrCode = -5
+
+	// warmupErrCode is the RPC error code returned while
+	// whived is starting up (e.g. loading, verifying, or
+	// rebuilding its block index)
+	warmupErrCode = -28
+
+	// RPC error codes returned when a transaction
+	// submitted with `sendrawtransaction` is rejected.
+	// https://github.com/bitcoin/bitcoin/blob/v0.20.1/src/rpc/protocol.h#L47-L49
+	verifyErrCode         = -25
+	verifyRejectedErrCode = -26
+	alreadyInChainErrCode = -27
+
+	// mempoolChainTooLongReason is included in the
+	// `sendrawtransaction` error message when a transaction
+	// would exceed the mempool ancestor or descendant limits.
+	mempoolChainTooLongReason = "too-long-mempool-chain"
+
+	// txIndexHint is included in the `getrawtransaction` error
+	// message when a transaction is not in the mempool and
+	// -txindex is not enabled.
+	txIndexHint = "-txindex"
+
+	// txIndexProbeHash is the hash of a transaction that
+	// does not exist. We look it up to determine if whived
+	// is running with -txindex.
+	txIndexProbeHash = "0000000000000000000000000000000000000000000000000000000000000000"
+
+	// workQueueExceeded is included in the body of `503`
+	// responses when whived's RPC work queue is full.
+	workQueueExceeded = "Work queue depth exceeded"
+
+	// blockPrunedErrMessage is included in the message of
+	// errors returned by `getblock` for pruned blocks.
+	blockPrunedErrMessage = "pruned data"
 )
 
+// nonIdempotentMethods are the requests that whived may
+// have acted on even if we never received its response.
+var nonIdempotentMethods = map[requestMethod]bool{
+	requestMethodSendRawTransaction: true,
+}
+
 const (
 	defaultTimeout = 100 * time.Second
 	dialTimeout    = 5 * time.Second
 
+	// unixSocketPrefix is the prefix of the URL of a
+	// whived RPC server listening on a Unix domain socket
+	// (e.g. "unix:///run/whived/rpc.sock").
+	unixSocketPrefix = "unix://"
+
+	// unixSocketBaseURL is the URL requests are made to
+	// when connecting over a Unix domain socket. The host
+	// is only used in the request's Host header.
+	unixSocketBaseURL = "http://localhost"
+
+	// maxRetries is the default number of times we retry
+	// a request that failed because whived was unavailable
+	// (its RPC work queue was full or it refused the
+	// connection). The delay between retries starts at
+	// retryDelay and doubles after each retry (with jitter).
+	maxRetries = 5
+	retryDelay = 100 * time.Millisecond
+
+	// maxResponseBytes is the default maximum size
+	// of a response from whived. The JSON representation
+	// of the largest legitimate blocks (with transaction
+	// data) is well below this.
+	maxResponseBytes = int64(256 * 1024 * 1024)
+
 	// timeMultiplier is used to multiply the time
 	// returned in Bitcoin blocks to be milliseconds.
 	timeMultiplier = 1000
@@ -95,7 +204,8 @@ const (
 	// rpc credentials are fixed in rosetta-whive
 	// because we never expose access to the raw bitcoind
 	// endpoints (that could be used perform an attack, like
-	// changing our peers).
+	// changing our peers). They are not used if a cookie
+	// file is configured (see WithCookiePath).
 	rpcUsername = "rosetta"
 	rpcPassword = "rosetta"
 )
@@ -105,8 +215,65 @@ var (
 	// cannot be found by the node
 	ErrBlockNotFound = errors.New("unable to find block")
 
+	// ErrBlockPruned is returned when the requested block
+	// has been pruned by the node (see PrunedBlockError)
+	ErrBlockPruned = errors.New("block data was pruned")
+
 	// ErrJSONRPCError is returned when receiving an error from a JSON-RPC response
 	ErrJSONRPCError = errors.New("JSON-RPC error")
+
+	// ErrNetworkMismatch is returned when whived is running
+	// on a different network than the one configured
+	ErrNetworkMismatch = errors.New("whived network does not match configured network")
+
+	// ErrNodeWarmingUp is returned when whived is not yet
+	// ready to serve requests because it is loading or
+	// rebuilding its block index.
+	ErrNodeWarmingUp = errors.New("whived is warming up")
+
+	// ErrTransactionVerify is returned when a submitted
+	// transaction fails verification (e.g. missing inputs)
+	ErrTransactionVerify = errors.New("transaction verification failed")
+
+	// ErrTransactionRejected is returned when a submitted
+	// transaction is rejected by whived's mempool policy
+	ErrTransactionRejected = errors.New("transaction rejected")
+
+	// ErrMempoolChainTooLong is returned when a submitted
+	// transaction would exceed the mempool ancestor or
+	// descendant limits (i.e. it spends too long a chain
+	// of unconfirmed transactions).
+	ErrMempoolChainTooLong = errors.New("transaction exceeds mempool chain limits")
+
+	// ErrTransactionAlreadyInChain is returned when a submitted
+	// transaction has already been confirmed
+	ErrTransactionAlreadyInChain = errors.New("transaction already in chain")
+
+	// ErrTransactionNotFound is returned when the requested
+	// transaction cannot be found by the node
+	ErrTransactionNotFound = errors.New("unable to find transaction")
+
+	// ErrTxIndexDisabled is returned when a transaction lookup
+	// requires the node to be running with -txindex
+	ErrTxIndexDisabled = errors.New("transaction index is disabled, restart whived with -txindex")
+
+	// ErrWorkQueueFull is returned when whived rejects a
+	// request because its RPC work queue is full (and
+	// retrying the request did not succeed).
+	ErrWorkQueueFull = errors.New("whived RPC work queue is full")
+
+	// ErrResponseTooLarge is returned when a response
+	// from whived exceeds the maximum response size.
+	ErrResponseTooLarge = errors.New("whived response exceeds maximum size")
+
+	// ErrNoCredentials is returned when a cookie file is
+	// configured (instead of static credentials) but it
+	// cannot be read.
+	ErrNoCredentials = errors.New("no RPC credentials or readable cookie file")
+
+	// ErrUnauthorized is returned when whived
+	// rejects the credentials of a request.
+	ErrUnauthorized = errors.New("whived rejected RPC credentials")
 )
 
 // Client is used to fetch blocks from bitcoind and
@@ -122,6 +289,144 @@ type Client struct {
 	currency               *types.Currency
 
 	httpClient *http.Client
+
+	// batcher is nil unless batching is enabled.
+	batcher *batcher
+
+	// params are used to derive the address of
+	// scripts that bitcoind does not return an
+	// address for. This is nil unless configured.
+	params *chaincfg.Params
+
+	// requestLimiter is nil unless the number of
+	// concurrent requests to whived is limited.
+	requestLimiter *semaphore.Weighted
+
+	maxRetries int
+	retryDelay time.Duration
+
+	maxResponseBytes int64
+
+	// If cookiePath is set, requests are authenticated with
+	// the credentials in whived's cookie file instead of the
+	// static credentials. whived writes a new cookie each time
+	// it starts, so the cached cookie is re-read when whived
+	// rejects it.
+	cookiePath  string
+	cookie      *credentials
+	cookieMutex sync.Mutex
+
+	// If degradeThreshold is non-zero, we are degraded
+	// once degradeThreshold consecutive requests fail to
+	// reach whived (and recover after the next request
+	// that does).
+	degradeThreshold int
+	failures         int
+	degraded         bool
+	degradedMutex    sync.Mutex
+
+	// If scriptTypes is true, the metadata of each
+	// OUTPUT operation includes its script type.
+	scriptTypes bool
+
+	// mempool is the last snapshot of the transactions in
+	// whived's mempool (keyed by hash). It is shared by
+	// account queries so that each query only fetches the
+	// transactions that entered the mempool since the last.
+	mempool      map[string]*Transaction
+	mempoolMutex sync.Mutex
+}
+
+// ClientOption is used to configure optional
+// behavior of a *Client.
+type ClientOption func(*Client)
+
+// WithBatchWindow enables batching of compatible read
+// requests that are made within window of each other
+// into a single JSON-RPC batch request.
+func WithBatchWindow(window time.Duration) ClientOption {
+	return func(b *Client) {
+		if window > 0 {
+			b.batcher = newBatcher(b, window)
+		}
+	}
+}
+
+// WithMaxConcurrentRequests limits the number of requests
+// made to whived concurrently. This should be set below
+// whived's -rpcworkqueue to avoid requests being rejected.
+func WithMaxConcurrentRequests(limit int64) ClientOption {
+	return func(b *Client) {
+		if limit > 0 {
+			b.requestLimiter = semaphore.NewWeighted(limit)
+		}
+	}
+}
+
+// WithParams enables deriving the address of P2WSH
+// outputs from their script when bitcoind does not
+// return it, so that they are tracked by address.
+func WithParams(params *chaincfg.Params) ClientOption {
+	return func(b *Client) {
+		b.params = params
+	}
+}
+
+// WithMaxResponseBytes limits the size of responses read
+// from whived to limit bytes. Larger responses (including
+// JSON-RPC batches) are rejected with ErrResponseTooLarge.
+func WithMaxResponseBytes(limit int64) ClientOption {
+	return func(b *Client) {
+		if limit > 0 {
+			b.maxResponseBytes = limit
+		}
+	}
+}
+
+// WithRetries configures the number of times a request is
+// retried when whived is unavailable and the delay before the
+// first retry (which doubles after each retry). Setting
+// retries to 0 disables retries.
+func WithRetries(retries int, delay time.Duration) ClientOption {
+	return func(b *Client) {
+		if retries >= 0 {
+			b.maxRetries = retries
+		}
+
+		if delay > 0 {
+			b.retryDelay = delay
+		}
+	}
+}
+
+// WithCookiePath authenticates requests with the credentials
+// in the cookie file whived writes to path (when rpcuser and
+// rpcpassword are not configured) instead of the static
+// credentials.
+func WithCookiePath(path string) ClientOption {
+	return func(b *Client) {
+		b.cookiePath = path
+	}
+}
+
+// WithDegradeThreshold marks the client as degraded (see
+// Degraded) after threshold consecutive requests fail to
+// reach whived.
+func WithDegradeThreshold(threshold int) ClientOption {
+	return func(b *Client) {
+		if threshold > 0 {
+			b.degradeThreshold = threshold
+		}
+	}
+}
+
+// WithScriptTypes includes the script type of each
+// output (e.g. P2WPKHScriptType) in the metadata of
+// its OUTPUT operation.
+func WithScriptTypes() ClientOption {
+	return func(b *Client) {
+		b.scriptTypes = true
+	}
 }
 
 // LocalhostURL returns the URL to use
@@ -130,26 +435,60 @@ func LocalhostURL(rpcPort int) string {
 	return fmt.Sprintf("http://localhost:%d", rpcPort)
 }
 
+// UnixSocketURL returns the URL to use for a client
+// that connects to whived over the Unix domain socket
+// at path.
+func UnixSocketURL(path string) string {
+	return unixSocketPrefix + path
+}
+
 // NewClient creates a new Bitcoin client.
 func NewClient(
 	baseURL string,
 	genesisBlockIdentifier *types.BlockIdentifier,
 	currency *types.Currency,
+	options ...ClientOption,
 ) *Client {
-	return &Client{
+	// Requests to a Unix domain socket are made over HTTP
+	// with a transport that dials the socket.
+	socketPath := ""
+	if strings.HasPrefix(baseURL, unixSocketPrefix) {
+		socketPath = strings.TrimPrefix(baseURL, unixSocketPrefix)
+		baseURL = unixSocketBaseURL
+	}
+
+	client := &Client{
 		baseURL:                baseURL,
 		genesisBlockIdentifier: genesisBlockIdentifier,
 		currency:               currency,
-		httpClient:             newHTTPClient(defaultTimeout),
+		httpClient:             newHTTPClient(defaultTimeout, socketPath),
+		maxRetries:             maxRetries,
+		retryDelay:             retryDelay,
+		maxResponseBytes:       maxResponseBytes,
+	}
+
+	for _, opt := range options {
+		opt(client)
 	}
+
+	return client
 }
 
-// newHTTPClient returns a new HTTP client
-func newHTTPClient(timeout time.Duration) *http.Client {
+// newHTTPClient returns a new HTTP client. If socketPath
+// is set, all connections are made to the Unix domain
+// socket at socketPath instead of over TCP.
+func newHTTPClient(timeout time.Duration, socketPath string) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: dialTimeout,
+	}
+
 	var netTransport = &http.Transport{
-		Dial: (&net.Dialer{
-			Timeout: dialTimeout,
-		}).Dial,
+		DialContext: dialer.DialContext,
+	}
+	if len(socketPath) > 0 {
+		netTransport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", socketPath)
+		}
 	}
 
 	httpClient := &http.Client{
@@ -186,15 +525,22 @@ func (b *Client) NetworkStatus(ctx context.Context) (*types.NetworkStatusRespons
 	}, nil
 }
 
-// GetPeers fetches the list of peer nodes
+// GetPeers fetches the list of peer nodes and records
+// the number of inbound and outbound peers in
+// metrics.WhivedPeers.
 func (b *Client) GetPeers(ctx context.Context) ([]*types.Peer, error) {
 	info, err := b.getPeerInfo(ctx)
 	if err != nil {
 		return nil, err
 	}
 
+	var inbound int
 	peers := make([]*types.Peer, len(info))
 	for i, peerInfo := range info {
+		if peerInfo.Inbound {
+			inbound++
+		}
+
 		metadata, err := types.MarshalMap(peerInfo)
 		if err != nil {
 			return nil, fmt.Errorf("%w: unable to marshal peer info", err)
@@ -206,6 +552,11 @@ func (b *Client) GetPeers(ctx context.Context) ([]*types.Peer, error) {
 		}
 	}
 
+	metrics.WhivedPeers.WithLabelValues(metrics.PeerDirectionInbound).Set(float64(inbound))
+	metrics.WhivedPeers.WithLabelValues(metrics.PeerDirectionOutbound).Set(
+		float64(len(info) - inbound),
+	)
+
 	return peers, nil
 }
 
@@ -259,9 +610,73 @@ func (b *Client) ParseBlock(
 
 	rblock.Transactions = txs
 
+	if b.params != nil {
+		metadata, err := b.rewardMetadata(ctx, block, rblock)
+		if err != nil {
+			return nil, err
+		}
+		rblock.Metadata = metadata
+	}
+
 	return rblock, nil
 }
 
+// rewardMetadata returns the metadata of block with the
+// block subsidy at its height and the total fees paid by its
+// (non-coinbase) transactions. The outputs of the coinbase
+// transaction should not exceed their sum.
+func (b *Client) rewardMetadata(
+	ctx context.Context,
+	block *Block,
+	rblock *types.Block,
+) (map[string]interface{}, error) {
+	fees := new(big.Int)
+	coinbaseTotal := new(big.Int)
+	for index, tx := range rblock.Transactions {
+		for _, op := range tx.Operations {
+			if op.Type != InputOpType && op.Type != OutputOpType {
+				continue
+			}
+
+			value, err := types.AmountValue(op.Amount)
+			if err != nil {
+				return nil, fmt.Errorf("%w: unable to parse operation amount", err)
+			}
+
+			switch {
+			case index == 0:
+				coinbaseTotal.Add(coinbaseTotal, value)
+			default:
+				// Inputs are negative, so the fee is
+				// the negated sum of all amounts.
+				fees.Sub(fees, value)
+			}
+		}
+	}
+
+	subsidy := big.NewInt(blockchain.CalcBlockSubsidy(int32(block.Height), b.params))
+	reward := new(big.Int).Add(subsidy, fees)
+	if coinbaseTotal.Cmp(reward) > 0 {
+		logger := bitcoinUtils.ExtractLogger(ctx, "client")
+		logger.Warnw(
+			"coinbase outputs exceed block subsidy and fees",
+			"block hash", block.Hash,
+			"coinbase total", coinbaseTotal.String(),
+			"subsidy", subsidy.String(),
+			"fees", fees.String(),
+		)
+	}
+
+	var metadata BlockMetadata
+	if err := types.UnmarshalMap(rblock.Metadata, &metadata); err != nil {
+		return nil, fmt.Errorf("%w: unable to parse block metadata", err)
+	}
+	metadata.Subsidy = subsidy.String()
+	metadata.Fees = fees.String()
+
+	return types.MarshalMap(&metadata)
+}
+
 // SendRawTransaction submits a serialized transaction
 // to bitcoind.
 func (b *Client) SendRawTransaction(
@@ -282,7 +697,8 @@ func (b *Client) SendRawTransaction(
 }
 
 // SuggestedFeeRate estimates the approximate fee per vKB needed
-// to get a transaction in a block within conf_target.
+// to get a transaction in a block within conf_target. If whived
+// doesn't have enough data to estimate a fee, 0 is returned.
 func (b *Client) SuggestedFeeRate(
 	ctx context.Context,
 	confTarget int64,
@@ -299,6 +715,19 @@ func (b *Client) SuggestedFeeRate(
 	return response.Result.FeeRate, nil
 }
 
+// MempoolMinFeeRate returns the minimum fee rate (in BTC/kB)
+// for a transaction to be accepted to whived's mempool. This
+// rises above the minimum relay fee rate when the mempool
+// is full.
+func (b *Client) MempoolMinFeeRate(ctx context.Context) (float64, error) {
+	response := &mempoolInfoResponse{}
+	if err := b.post(ctx, requestMethodGetMempoolInfo, nil, response); err != nil {
+		return -1, fmt.Errorf("%w: error getting mempool info", err)
+	}
+
+	return response.Result.MempoolMinFee, nil
+}
+
 // PruneBlockchain prunes up to the provided height.
 // https://bitcoincore.org/en/doc/0.20.0/rpc/blockchain/pruneblockchain
 func (b *Client) PruneBlockchain(
@@ -335,6 +764,472 @@ func (b *Client) RawMempool(
 	return response.Result, nil
 }
 
+// MempoolTransaction returns the transaction with hash
+// from the mempool. whived does not return the previous
+// outputs spent by a transaction, so INPUT operations only
+// identify the coin spent (without an account or amount).
+func (b *Client) MempoolTransaction(
+	ctx context.Context,
+	hash string,
+) (*types.Transaction, error) {
+	transaction, err := b.getMempoolTransaction(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	txOps := []*types.Operation{}
+	for networkIndex, input := range transaction.Inputs {
+		metadata, err := input.Metadata()
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to get input metadata", err)
+		}
+
+		index := int64(networkIndex)
+		txOps = append(txOps, &types.Operation{
+			OperationIdentifier: &types.OperationIdentifier{
+				Index:        int64(len(txOps)),
+				NetworkIndex: &index,
+			},
+			Type: InputOpType,
+			CoinChange: &types.CoinChange{
+				CoinIdentifier: &types.CoinIdentifier{
+					Identifier: CoinIdentifier(input.TxHash, input.Vout),
+				},
+				CoinAction: types.CoinSpent,
+			},
+			Metadata: metadata,
+		})
+	}
+
+	for networkIndex, output := range transaction.Outputs {
+		txOp, err := b.parseOutputTransactionOperation(
+			output,
+			transaction.Hash,
+			int64(len(txOps)),
+			int64(networkIndex),
+			false,
+		)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"%w: error parsing tx output, hash: %s, index: %d",
+				err,
+				transaction.Hash,
+				networkIndex,
+			)
+		}
+
+		// Operations in the mempool have not
+		// been applied yet, so they have no status.
+		txOp.Status = nil
+		txOps = append(txOps, txOp)
+	}
+
+	metadata, err := transaction.MempoolMetadata(txOps)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to get metadata for transaction", err)
+	}
+
+	return &types.Transaction{
+		TransactionIdentifier: &types.TransactionIdentifier{
+			Hash: transaction.Hash,
+		},
+		Operations: txOps,
+		Metadata:   metadata,
+	}, nil
+}
+
+// MempoolBalance returns the net change to the balance of
+// account from transactions in the mempool (coins received
+// less coins spent). coins are the confirmed coins owned
+// by account, which mempool transactions may spend.
+func (b *Client) MempoolBalance(
+	ctx context.Context,
+	account *types.AccountIdentifier,
+	coins []*types.Coin,
+) (*types.Amount, error) {
+	transactions, err := b.mempoolTransactions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	owned := map[string]*big.Int{}
+	for _, coin := range coins {
+		value, err := types.AmountValue(coin.Amount)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"%w: unable to parse amount of coin %s",
+				err,
+				coin.CoinIdentifier.Identifier,
+			)
+		}
+
+		owned[coin.CoinIdentifier.Identifier] = value
+	}
+
+	// Outputs must be collected before inputs are
+	// processed because mempool transactions may
+	// spend the outputs of other mempool transactions.
+	balance := new(big.Int)
+	for _, transaction := range transactions {
+		for _, output := range transaction.Outputs {
+			if b.parseOutputAccount(output.ScriptPubKey).Address != account.Address {
+				continue
+			}
+
+			amount, err := b.parseAmount(output.Value)
+			if err != nil {
+				return nil, fmt.Errorf(
+					"%w: error parsing output value, hash: %s, index: %d",
+					err,
+					transaction.Hash,
+					output.Index,
+				)
+			}
+
+			value := new(big.Int).SetUint64(amount)
+			owned[fmt.Sprintf("%s:%d", transaction.Hash, output.Index)] = value
+			balance.Add(balance, value)
+		}
+	}
+
+	for _, transaction := range transactions {
+		for _, input := range transaction.Inputs {
+			value, ok := owned[fmt.Sprintf("%s:%d", input.TxHash, input.Vout)]
+			if !ok {
+				continue
+			}
+
+			balance.Sub(balance, value)
+		}
+	}
+
+	return &types.Amount{
+		Value:    balance.String(),
+		Currency: b.currency,
+	}, nil
+}
+
+// MempoolCoins returns the unspent coins owned by account
+// once transactions in the mempool are applied to coins (the
+// confirmed coins owned by account). Coins spent by mempool
+// transactions are removed and outputs of mempool transactions
+// paying to account (that are not spent in the mempool) are
+// added.
+func (b *Client) MempoolCoins(
+	ctx context.Context,
+	account *types.AccountIdentifier,
+	coins []*types.Coin,
+) ([]*types.Coin, error) {
+	transactions, err := b.mempoolTransactions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	spent := map[string]struct{}{}
+	for _, transaction := range transactions {
+		for _, input := range transaction.Inputs {
+			spent[fmt.Sprintf("%s:%d", input.TxHash, input.Vout)] = struct{}{}
+		}
+	}
+
+	unspent := []*types.Coin{}
+	for _, coin := range coins {
+		if _, ok := spent[coin.CoinIdentifier.Identifier]; ok {
+			continue
+		}
+
+		unspent = append(unspent, coin)
+	}
+
+	for _, transaction := range transactions {
+		for _, output := range transaction.Outputs {
+			if b.parseOutputAccount(output.ScriptPubKey).Address != account.Address {
+				continue
+			}
+
+			identifier := fmt.Sprintf("%s:%d", transaction.Hash, output.Index)
+			if _, ok := spent[identifier]; ok {
+				continue
+			}
+
+			amount, err := b.parseAmount(output.Value)
+			if err != nil {
+				return nil, fmt.Errorf(
+					"%w: error parsing output value, hash: %s, index: %d",
+					err,
+					transaction.Hash,
+					output.Index,
+				)
+			}
+
+			unspent = append(unspent, &types.Coin{
+				CoinIdentifier: &types.CoinIdentifier{
+					Identifier: identifier,
+				},
+				Amount: &types.Amount{
+					Value:    strconv.FormatUint(amount, 10),
+					Currency: b.currency,
+				},
+			})
+		}
+	}
+
+	return unspent, nil
+}
+
+// GetRawTransactions fetches the transactions with hashes
+// in a single JSON-RPC batch. The returned transactions and
+// errors are in the same order as hashes: if a transaction
+// could not be fetched (e.g. it has left the mempool), its
+// transaction is nil and its error is populated. The returned
+// error is only populated if the batch could not be sent.
+func (b *Client) GetRawTransactions(
+	ctx context.Context,
+	hashes []string,
+) ([]*Transaction, []error, error) {
+	if len(hashes) == 0 {
+		return []*Transaction{}, []error{}, nil
+	}
+
+	requests := make([]*request, len(hashes))
+	responses := make([]jSONRPCResponse, len(hashes))
+	for i, hash := range hashes {
+		// Parameters:
+		//   1. txid
+		//   2. verbose
+		requests[i] = &request{
+			JSONRPC: jSONRPCVersion,
+			ID:      i,
+			Method:  string(requestMethodGetRawTransaction),
+			Params:  []interface{}{hash, true},
+		}
+		responses[i] = &transactionResponse{}
+	}
+
+	errs, err := b.postBatch(ctx, requests, responses)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: error getting raw transactions", err)
+	}
+
+	transactions := make([]*Transaction, len(hashes))
+	for i, hash := range hashes {
+		if errs[i] != nil {
+			errs[i] = fmt.Errorf("%w: error getting raw transaction %s", errs[i], hash)
+			continue
+		}
+
+		transactions[i] = responses[i].(*transactionResponse).Result
+	}
+
+	return transactions, errs, nil
+}
+
+// mempoolTransactions returns all transactions in the
+// mempool. Transactions in the last snapshot of the mempool
+// are not fetched again, so only transactions that entered
+// the mempool since the last call are requested from whived.
+func (b *Client) mempoolTransactions(ctx context.Context) ([]*Transaction, error) {
+	hashes, err := b.RawMempool(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Concurrent queries wait for each other's updates
+	// instead of fetching the same transactions.
+	b.mempoolMutex.Lock()
+	defer b.mempoolMutex.Unlock()
+
+	snapshot := make(map[string]*Transaction, len(hashes))
+	missing := []string{}
+	for _, hash := range hashes {
+		if transaction, ok := b.mempool[hash]; ok {
+			snapshot[hash] = transaction
+			continue
+		}
+
+		missing = append(missing, hash)
+	}
+
+	for start := 0; start < len(missing); start += rawTransactionsBatchSize {
+		end := start + rawTransactionsBatchSize
+		if end > len(missing) {
+			end = len(missing)
+		}
+
+		batch, errs, err := b.GetRawTransactions(ctx, missing[start:end])
+		if err != nil {
+			return nil, err
+		}
+
+		for j, transaction := range batch {
+			if errors.Is(errs[j], ErrTransactionNotFound) {
+				// The transaction was confirmed or evicted
+				// after we fetched the mempool.
+				continue
+			}
+			if errs[j] != nil {
+				return nil, errs[j]
+			}
+
+			snapshot[missing[start+j]] = transaction
+		}
+	}
+
+	// Transactions that left the mempool are
+	// dropped from the snapshot.
+	b.mempool = snapshot
+
+	transactions := make([]*Transaction, 0, len(snapshot))
+	for _, hash := range hashes {
+		if transaction, ok := snapshot[hash]; ok {
+			transactions = append(transactions, transaction)
+		}
+	}
+
+	return transactions, nil
+}
+
+// ValidateNetwork returns an error if the chain reported
+// by whived does not match the network magic of params.
+func (b *Client) ValidateNetwork(ctx context.Context, params *chaincfg.Params) error {
+	expected, ok := chainNames[params.Net]
+	if !ok {
+		return fmt.Errorf("no chain known for network magic %s", params.Net)
+	}
+
+	info, err := b.getBlockchainInfo(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: unable to get blockchain info", err)
+	}
+
+	if info.Chain != expected {
+		return fmt.Errorf(
+			"%w: whived is running on %s but %s (magic %s) is configured",
+			ErrNetworkMismatch,
+			info.Chain,
+			expected,
+			params.Net,
+		)
+	}
+
+	return nil
+}
+
+// TransactionBlock returns the *types.BlockIdentifier of the
+// block that confirmed the transaction with the provided hash.
+// If the transaction is in the mempool, nil is returned. Looking
+// up confirmed transactions requires whived to be running
+// with -txindex.
+func (b *Client) TransactionBlock(
+	ctx context.Context,
+	hash string,
+) (*types.BlockIdentifier, error) {
+	// Parameters:
+	//   1. txid
+	//   2. verbose
+	params := []interface{}{hash, true}
+
+	response := &rawTransactionResponse{}
+	if err := b.post(ctx, requestMethodGetRawTransaction, params, response); err != nil {
+		return nil, fmt.Errorf("%w: error getting raw transaction %s", err, hash)
+	}
+
+	// Transactions in the mempool (or in a block that
+	// is no longer part of the main chain) have
+	// no confirmations.
+	if len(response.Result.BlockHash) == 0 || response.Result.Confirmations <= 0 {
+		return nil, nil
+	}
+
+	header, err := b.getBlockHeader(ctx, response.Result.BlockHash)
+	if err != nil {
+		return nil, fmt.Errorf("%w: error getting block header %s", err, response.Result.BlockHash)
+	}
+
+	return &types.BlockIdentifier{
+		Hash:  header.Hash,
+		Index: header.Height,
+	}, nil
+}
+
+// TxIndexEnabled returns true if whived is running
+// with -txindex (and can look up arbitrary transactions).
+func (b *Client) TxIndexEnabled(ctx context.Context) (bool, error) {
+	_, err := b.TransactionBlock(ctx, txIndexProbeHash)
+	switch {
+	case errors.Is(err, ErrTxIndexDisabled):
+		return false, nil
+	case errors.Is(err, ErrTransactionNotFound):
+		return true, nil
+	case err != nil:
+		return false, fmt.Errorf("%w: unable to determine if -txindex is enabled", err)
+	}
+
+	return true, nil
+}
+
+// BlockHeader returns the *BlockHeader of the block
+// at index on whived's best chain.
+func (b *Client) BlockHeader(
+	ctx context.Context,
+	index int64,
+) (*BlockHeader, error) {
+	hash, err := b.getHashFromIndex(ctx, index)
+	if err != nil {
+		return nil, err
+	}
+
+	header, err := b.getBlockHeader(ctx, hash)
+	if err != nil {
+		return nil, fmt.Errorf("%w: error getting block header %s", err, hash)
+	}
+
+	return header, nil
+}
+
+// BlockHash returns the hash of the block
+// at index on whived's best chain.
+func (b *Client) BlockHash(ctx context.Context, index int64) (string, error) {
+	return b.getHashFromIndex(ctx, index)
+}
+
+// getMempoolTransaction performs the `getrawtransaction`
+// JSON-RPC request for a transaction in the mempool.
+func (b *Client) getMempoolTransaction(
+	ctx context.Context,
+	hash string,
+) (*Transaction, error) {
+	// Parameters:
+	//   1. txid
+	//   2. verbose
+	params := []interface{}{hash, true}
+
+	response := &transactionResponse{}
+	if err := b.post(ctx, requestMethodGetRawTransaction, params, response); err != nil {
+		return nil, fmt.Errorf("%w: error getting mempool transaction %s", err, hash)
+	}
+
+	return response.Result, nil
+}
+
+// getBlockHeader performs the `getblockheader` JSON-RPC request
+func (b *Client) getBlockHeader(
+	ctx context.Context,
+	hash string,
+) (*BlockHeader, error) {
+	// Parameters:
+	//   1. Block hash (string, required)
+	//   2. Verbose (boolean, optional, default=true)
+	params := []interface{}{hash, true}
+
+	response := &blockHeaderResponse{}
+	if err := b.post(ctx, requestMethodGetBlockHeader, params, response); err != nil {
+		return nil, fmt.Errorf("%w: error posting to JSON-RPC", err)
+	}
+
+	return response.Result, nil
+}
+
 // getPeerInfo performs the `getpeerinfo` JSON-RPC request
 func (b *Client) getPeerInfo(
 	ctx context.Context,
@@ -366,12 +1261,37 @@ func (b *Client) getBlock(
 
 	response := &blockResponse{}
 	if err := b.post(ctx, requestMethodGetBlock, params, response); err != nil {
+		if errors.Is(err, ErrBlockPruned) {
+			return nil, b.prunedBlockError(ctx, hash)
+		}
+
 		return nil, fmt.Errorf("%w: error fetching block by hash %s", err, hash)
 	}
 
 	return response.Result, nil
 }
 
+// prunedBlockError returns a *PrunedBlockError for the
+// block with hash, including the minimum height of blocks
+// that whived has not pruned.
+func (b *Client) prunedBlockError(ctx context.Context, hash string) error {
+	info, err := b.getBlockchainInfo(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: block %s was pruned and %s", ErrBlockPruned, hash, err.Error())
+	}
+
+	return &PrunedBlockError{
+		Hash:               hash,
+		MinAvailableHeight: info.PruneHeight,
+	}
+}
+
+// BlockchainInfo returns whived's view of the
+// chain (including its sync progress).
+func (b *Client) BlockchainInfo(ctx context.Context) (*BlockchainInfo, error) {
+	return b.getBlockchainInfo(ctx)
+}
+
 // getBlockchainInfo performs the `getblockchaininfo` JSON-RPC request
 func (b *Client) getBlockchainInfo(
 	ctx context.Context,
@@ -488,6 +1408,16 @@ func skipTransactionOperations(blockNumber int64, blockHash string, transactionH
 	return false
 }
 
+// isGenesisCoinbase returns a boolean indicating if a transaction
+// is the coinbase of the genesis block. The genesis coinbase output
+// is never added to the UTXO set by whived, so it cannot be spent and
+// must not count towards any balance.
+func (b *Client) isGenesisCoinbase(block *Block, txIndex int) bool {
+	return txIndex == 0 &&
+		block.Height == genesisBlockIndex &&
+		block.Hash == b.genesisBlockIdentifier.Hash
+}
+
 // parseTransactions returns the transactions for a specified `Block`
 func (b *Client) parseTransactions(
 	ctx context.Context,
@@ -520,7 +1450,18 @@ func (b *Client) parseTransactions(
 			}
 		}
 
-		metadata, err := transaction.Metadata()
+		if b.isGenesisCoinbase(block, index) {
+			logger.Debugw(
+				"skipping genesis coinbase",
+				"block hash", block.Hash,
+				"transaction hash", transaction.Hash,
+			)
+			for _, op := range txOps {
+				op.Status = types.String(SkippedStatus)
+			}
+		}
+
+		metadata, err := transaction.Metadata(txOps)
 		if err != nil {
 			return nil, fmt.Errorf("%w: unable to get metadata for transaction", err)
 		}
@@ -604,12 +1545,14 @@ func (b *Client) parseTxOperations(
 		txOps = append(txOps, txOp)
 	}
 
+	coinbase := len(tx.Inputs) > 0 && bitcoinIsCoinbaseInput(tx.Inputs[0], txIndex, 0)
 	for networkIndex, output := range tx.Outputs {
 		txOp, err := b.parseOutputTransactionOperation(
 			output,
 			tx.Hash,
 			int64(len(txOps)),
 			int64(networkIndex),
+			coinbase,
 		)
 		if err != nil {
 			return nil, fmt.Errorf(
@@ -627,12 +1570,14 @@ func (b *Client) parseTxOperations(
 }
 
 // parseOutputTransactionOperation returns the types.Operation for the specified
-// `bitcoinOutput` transaction output.
+// `bitcoinOutput` transaction output. The coinbase flag indicates
+// whether the output belongs to a coinbase transaction.
 func (b *Client) parseOutputTransactionOperation(
 	output *Output,
 	txHash string,
 	index int64,
 	networkIndex int64,
+	coinbase bool,
 ) (*types.Operation, error) {
 	amount, err := b.parseAmount(output.Value)
 	if err != nil {
@@ -644,7 +1589,16 @@ func (b *Client) parseOutputTransactionOperation(
 		)
 	}
 
-	metadata, err := output.Metadata()
+	witnessCommitment := coinbase && isWitnessCommitment(output.ScriptPubKey)
+	outputMetadata := &OperationMetadata{
+		ScriptPubKey:      output.ScriptPubKey,
+		WitnessCommitment: witnessCommitment,
+	}
+	if b.scriptTypes {
+		outputMetadata.ScriptType = scriptType(output.ScriptPubKey)
+	}
+
+	metadata, err := types.MarshalMap(outputMetadata)
 	if err != nil {
 		return nil, fmt.Errorf("%w: unable to get output metadata", err)
 	}
@@ -667,9 +1621,10 @@ func (b *Client) parseOutputTransactionOperation(
 		account.Address = fmt.Sprintf("%s:%d", txHash, networkIndex)
 	}
 
-	// If this is an OP_RETURN locking script,
-	// we don't create a coin because it is provably unspendable.
-	if output.ScriptPubKey.Type == NullData {
+	// If this is an OP_RETURN locking script (including the
+	// witness commitment in a coinbase), we don't create a coin
+	// because it is provably unspendable.
+	if output.ScriptPubKey.Type == NullData || witnessCommitment {
 		coinChange = nil
 	}
 
@@ -690,6 +1645,44 @@ func (b *Client) parseOutputTransactionOperation(
 	}, nil
 }
 
+// scriptType classifies scriptPubKey by the
+// ScriptPubKey.Type returned by bitcoind. Types
+// we don't classify are nonstandard.
+func scriptType(scriptPubKey *ScriptPubKey) string {
+	switch scriptPubKey.Type {
+	case PubKeyHash:
+		return P2PKHScriptType
+	case ScriptHash:
+		return P2SHScriptType
+	case WitnessV0PubKeyHash:
+		return P2WPKHScriptType
+	case WitnessV0ScriptHash:
+		return P2WSHScriptType
+	case WitnessV1Taproot:
+		return P2TRScriptType
+	case PubKey:
+		return P2PKScriptType
+	case MultiSig:
+		return MultiSigScriptType
+	case NullData:
+		return OpReturnScriptType
+	default:
+		return NonStandardScriptType
+	}
+}
+
+// isWitnessCommitment returns true if the provided ScriptPubKey
+// is a BIP141 witness commitment. It should only be considered
+// for outputs of a coinbase transaction.
+func isWitnessCommitment(scriptPubKey *ScriptPubKey) bool {
+	if scriptPubKey == nil {
+		return false
+	}
+
+	return len(scriptPubKey.Hex) >= witnessCommitmentSize*2 &&
+		strings.HasPrefix(scriptPubKey.Hex, witnessCommitmentPrefix)
+}
+
 // getInputTxHash returns the transaction hash corresponding to an inputs previous
 // output. If the input is a coinbase input, then no previous transaction is associated
 // with the input.
@@ -773,11 +1766,35 @@ func (b *Client) parseAmount(amount float64) (uint64, error) {
 func (b *Client) parseOutputAccount(
 	scriptPubKey *ScriptPubKey,
 ) *types.AccountIdentifier {
-	if len(scriptPubKey.Addresses) != 1 {
-		return &types.AccountIdentifier{Address: scriptPubKey.Hex}
+	if len(scriptPubKey.Addresses) == 1 {
+		return &types.AccountIdentifier{Address: scriptPubKey.Addresses[0]}
+	}
+
+	if address, ok := b.witnessScriptHashAddress(scriptPubKey); ok {
+		return &types.AccountIdentifier{Address: address}
+	}
+
+	return &types.AccountIdentifier{Address: scriptPubKey.Hex}
+}
+
+// witnessScriptHashAddress derives the bech32 address
+// of a P2WSH ScriptPubKey (if params are configured).
+func (b *Client) witnessScriptHashAddress(scriptPubKey *ScriptPubKey) (string, bool) {
+	if b.params == nil || scriptPubKey.Type != WitnessV0ScriptHash {
+		return "", false
 	}
 
-	return &types.AccountIdentifier{Address: scriptPubKey.Addresses[0]}
+	script, err := hex.DecodeString(scriptPubKey.Hex)
+	if err != nil {
+		return "", false
+	}
+
+	_, addresses, _, err := txscript.ExtractPkScriptAddrs(script, b.params)
+	if err != nil || len(addresses) != 1 {
+		return "", false
+	}
+
+	return addresses[0].EncodeAddress(), true
 }
 
 // coinbaseTxOperation constructs a transaction operation for the coinbase input.
@@ -803,12 +1820,41 @@ func (b *Client) coinbaseTxOperation(
 	}, nil
 }
 
-// post makes a HTTP request to a Bitcoin node
+// post makes a HTTP request to a Bitcoin node. If batching
+// is enabled, compatible read requests may be sent to
+// the node in a JSON-RPC batch with other requests. The
+// latency of each call is recorded in metrics.WhivedRPCLatency
+// and failed calls are counted in metrics.WhivedRPCErrors.
 func (b *Client) post(
 	ctx context.Context,
 	method requestMethod,
 	params []interface{},
 	response jSONRPCResponse,
+) (err error) {
+	start := time.Now()
+	defer func() {
+		metrics.WhivedRPCLatency.WithLabelValues(string(method)).Observe(
+			time.Since(start).Seconds(),
+		)
+		if err != nil {
+			metrics.WhivedRPCErrors.WithLabelValues(string(method)).Inc()
+		}
+	}()
+
+	if b.batcher != nil && batchableMethods[method] {
+		return b.batcher.call(ctx, method, params, response)
+	}
+
+	return b.postSingle(ctx, method, params, response)
+}
+
+// postSingle makes a HTTP request for a single
+// JSON-RPC call to a Bitcoin node.
+func (b *Client) postSingle(
+	ctx context.Context,
+	method requestMethod,
+	params []interface{},
+	response jSONRPCResponse,
 ) error {
 	rpcRequest := &request{
 		JSONRPC: jSONRPCVersion,
@@ -817,7 +1863,156 @@ func (b *Client) post(
 		Params:  params,
 	}
 
-	requestBody, err := json.Marshal(rpcRequest)
+	if err := b.postJSON(ctx, rpcRequest, response, !nonIdempotentMethods[method]); err != nil {
+		return err
+	}
+
+	// Handle errors that are returned in JSON-RPC responses with `200 OK` statuses
+	return response.Err()
+}
+
+// postJSON posts body to a Bitcoin node and
+// decodes the response body into response. If whived
+// is unavailable, we back off and retry. Requests that
+// are not idempotent are only retried if they were never
+// received by whived.
+func (b *Client) postJSON(
+	ctx context.Context,
+	body interface{},
+	response interface{},
+	idempotent bool,
+) error {
+	err := b.postJSONWithRetries(ctx, body, response, idempotent)
+	b.recordResult(ctx, err)
+
+	return err
+}
+
+// postJSONWithRetries performs post requests
+// for postJSON until one succeeds or is not
+// retriable.
+func (b *Client) postJSONWithRetries(
+	ctx context.Context,
+	body interface{},
+	response interface{},
+	idempotent bool,
+) error {
+	backoff := b.retryDelay
+	reauthenticated := false
+	for retries := 0; ; retries++ {
+		err := b.postJSONOnce(ctx, body, response)
+
+		// whived writes a new cookie when it restarts, so
+		// we re-read the cookie once if it is rejected.
+		if errors.Is(err, ErrUnauthorized) && !reauthenticated && b.resetCookie() {
+			reauthenticated = true
+			continue
+		}
+
+		if !retriable(err, idempotent) || retries >= b.maxRetries {
+			return err
+		}
+
+		if err := utils.ContextSleep(ctx, withJitter(backoff)); err != nil {
+			return err
+		}
+		backoff *= 2
+	}
+}
+
+// recordResult updates the number of consecutive requests
+// that failed to reach whived with the result (err) of a
+// request and enters (or leaves) degraded mode when the
+// degrade threshold is crossed.
+func (b *Client) recordResult(ctx context.Context, err error) {
+	if b.degradeThreshold == 0 {
+		return
+	}
+
+	// Requests canceled by the caller (and responses
+	// we refused to read) don't tell us whether whived
+	// is reachable.
+	if ctx.Err() != nil || errors.Is(err, ErrResponseTooLarge) {
+		return
+	}
+
+	b.degradedMutex.Lock()
+	defer b.degradedMutex.Unlock()
+
+	logger := bitcoinUtils.ExtractLogger(ctx, "client")
+	if err == nil {
+		b.failures = 0
+		if b.degraded {
+			b.degraded = false
+			metrics.WhivedDegraded.Set(0)
+			logger.Infow("whived is reachable, serving online endpoints")
+		}
+
+		return
+	}
+
+	b.failures++
+	if !b.degraded && b.failures >= b.degradeThreshold {
+		b.degraded = true
+		metrics.WhivedDegraded.Set(1)
+		logger.Errorw(
+			"whived is unreachable, serving offline endpoints only",
+			"consecutive failures", b.failures,
+			"error", err,
+		)
+	}
+}
+
+// Degraded returns true once the degrade threshold of
+// consecutive requests have failed to reach whived (until
+// a request succeeds). While degraded, only endpoints that
+// don't depend on whived should be served.
+func (b *Client) Degraded() bool {
+	b.degradedMutex.Lock()
+	defer b.degradedMutex.Unlock()
+
+	return b.degraded
+}
+
+// retriable returns true if a request that failed with
+// err may succeed if retried. JSON-RPC errors (like
+// invalid params) are returned in successful responses,
+// so they are never retried. A missing Unix domain socket
+// (ENOENT) is retried like a refused connection because
+// whived removes its socket while restarting.
+//
+// A reset connection (ECONNRESET) may have been reset after
+// whived processed the request, so it is only retried if
+// the request is idempotent.
+func retriable(err error, idempotent bool) bool {
+	if errors.Is(err, syscall.ECONNRESET) {
+		return idempotent
+	}
+
+	return errors.Is(err, ErrWorkQueueFull) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ENOENT)
+}
+
+// withJitter returns delay plus a random duration of
+// up to half of delay, so that concurrent requests
+// that fail together don't all retry together.
+func withJitter(delay time.Duration) time.Duration {
+	if delay <= 0 {
+		return delay
+	}
+
+	return delay + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// postJSONOnce performs a single post request
+// for postJSON.
+func (b *Client) postJSONOnce(
+	ctx context.Context,
+	body interface{},
+	response interface{},
+) error {
+	requestBody, err := json.Marshal(body)
 	if err != nil {
 		return fmt.Errorf("%w: error marshalling RPC request", err)
 	}
@@ -827,8 +2022,20 @@ func (b *Client) post(
 		return fmt.Errorf("%w: error constructing request", err)
 	}
 
+	creds, err := b.credentials()
+	if err != nil {
+		return err
+	}
+
 	req.Header.Set("Content-Type", "application/json")
-	req.SetBasicAuth(rpcUsername, rpcPassword)
+	req.SetBasicAuth(creds.user, creds.password)
+
+	if b.requestLimiter != nil {
+		if err := b.requestLimiter.Acquire(ctx, 1); err != nil {
+			return err
+		}
+		defer b.requestLimiter.Release(1)
+	}
 
 	// Perform the post request
 	res, err := b.httpClient.Do(req.WithContext(ctx))
@@ -837,16 +2044,56 @@ func (b *Client) post(
 	}
 	defer res.Body.Close()
 
+	resBody := &limitedReader{r: res.Body, remaining: b.maxResponseBytes}
+
+	if res.StatusCode == http.StatusUnauthorized {
+		return fmt.Errorf("%w: %s", ErrUnauthorized, res.Status)
+	}
+
 	// We expect JSON-RPC responses to return `200 OK` statuses
 	if res.StatusCode != http.StatusOK {
-		val, _ := ioutil.ReadAll(res.Body)
+		val, _ := ioutil.ReadAll(resBody)
+		if res.StatusCode == http.StatusServiceUnavailable &&
+			strings.Contains(string(val), workQueueExceeded) {
+			return fmt.Errorf("%w: %s", ErrWorkQueueFull, string(val))
+		}
+
 		return fmt.Errorf("invalid response: %s %s", res.Status, string(val))
 	}
 
-	if err = json.NewDecoder(res.Body).Decode(response); err != nil {
+	if err = json.NewDecoder(resBody).Decode(response); err != nil {
 		return fmt.Errorf("%w: error decoding response body", err)
 	}
 
-	// Handle errors that are returned in JSON-RPC responses with `200 OK` statuses
-	return response.Err()
+	return nil
+}
+
+// limitedReader reads from r until more than remaining
+// bytes have been read, at which point ErrResponseTooLarge
+// is returned (unlike io.LimitReader, which returns io.EOF
+// and would result in a confusing decoding error).
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+// Read reads from the underlying io.Reader.
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, ErrResponseTooLarge
+	}
+
+	// We read one byte past the limit to
+	// detect responses that exceed it.
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n, ErrResponseTooLarge
+	}
+
+	return n, err
 }
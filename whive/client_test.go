@@ -16,15 +16,28 @@ package whive
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math/big"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"syscall"
 	"testing"
+	"time"
 
+	"github.com/xyephy/rosetta-whive/metrics"
+
+	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -33,16 +46,56 @@ const (
 )
 
 func forceMarshalMap(t *testing.T, i interface{}) map[string]interface{} {
-	m, err := MarshalMap(i)
+	m, err := types.MarshalMap(i)
 	if err != nil {
-		t.Fatalf("could not marshal map %s", PrintStruct(i))
+		t.Fatalf("could not marshal map %s", types.PrintStruct(i))
 	}
 
 	return m
 }
 
 var (
-	blockIdentifier1000 = &BlockIdentifier{
+	genesisBlock = &Block{
+		Hash:       MainnetGenesisBlockIdentifier.Hash,
+		Height:     0,
+		Time:       1231006505,
+		Size:       285,
+		Weight:     1140,
+		Version:    1,
+		MerkleRoot: "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b",
+		MedianTime: 1231006505,
+		Nonce:      2083236893,
+		Bits:       "1d00ffff",
+		Difficulty: 1,
+		Txs: []*Transaction{
+			{
+				Hash:    "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b",
+				Size:    204,
+				Vsize:   204,
+				Version: 1,
+				Weight:  816,
+				Inputs: []*Input{
+					{
+						Coinbase: "04ffff001d0104",
+						Sequence: 4294967295,
+					},
+				},
+				Outputs: []*Output{
+					{
+						Value: 50,
+						Index: 0,
+						ScriptPubKey: &ScriptPubKey{
+							ASM:  "04678afdb0fe OP_CHECKSIG",
+							Hex:  "4104678afdb0feac",
+							Type: "pubkey",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	blockIdentifier1000 = &types.BlockIdentifier{
 		Hash:  "00000000c937983704a73af28acdec37b049d214adbda81d7e2a3dd146f6ed09",
 		Index: 1000,
 	}
@@ -124,7 +177,7 @@ var (
 		},
 	}
 
-	blockIdentifier100000 = &BlockIdentifier{
+	blockIdentifier100000 = &types.BlockIdentifier{
 		Hash:  "000000000003ba27aa200b1cecaad478d2b00432346c3f1f3986da1afd33e506",
 		Index: 100000,
 	}
@@ -296,7 +349,7 @@ func TestNetworkStatus(t *testing.T) {
 	tests := map[string]struct {
 		responses []responseFixture
 
-		expectedStatus *NetworkStatusResponse
+		expectedStatus *types.NetworkStatusResponse
 		expectedError  error
 	}{
 		"successful": {
@@ -317,11 +370,11 @@ func TestNetworkStatus(t *testing.T) {
 					url:    url,
 				},
 			},
-			expectedStatus: &NetworkStatusResponse{
+			expectedStatus: &types.NetworkStatusResponse{
 				CurrentBlockIdentifier: blockIdentifier1000,
 				CurrentBlockTimestamp:  block1000.Time * 1000,
 				GenesisBlockIdentifier: MainnetGenesisBlockIdentifier,
-				Peers: []*Peer{
+				Peers: []*types.Peer{
 					{
 						PeerID: "77.93.223.9:8333",
 						Metadata: forceMarshalMap(t, &PeerInfo{
@@ -342,6 +395,7 @@ func TestNetworkStatus(t *testing.T) {
 						Metadata: forceMarshalMap(t, &PeerInfo{
 							Addr:           "172.105.93.179:8333",
 							RelayTxes:      true,
+							Inbound:        true,
 							LastSend:       1597606678,
 							LastRecv:       1597606676,
 							Version:        70015,
@@ -363,7 +417,7 @@ func TestNetworkStatus(t *testing.T) {
 					url:    url,
 				},
 			},
-			expectedError: errors.New("rpc in warmup"),
+			expectedError: ErrNodeWarmingUp,
 		},
 		"blockchain info error": {
 			responses: []responseFixture{
@@ -434,8 +488,10 @@ func TestGetPeers(t *testing.T) {
 	tests := map[string]struct {
 		responses []responseFixture
 
-		expectedPeers []*Peer
-		expectedError error
+		expectedPeers    []*types.Peer
+		expectedInbound  float64
+		expectedOutbound float64
+		expectedError    error
 	}{
 		"successful": {
 			responses: []responseFixture{
@@ -445,7 +501,7 @@ func TestGetPeers(t *testing.T) {
 					url:    url,
 				},
 			},
-			expectedPeers: []*Peer{
+			expectedPeers: []*types.Peer{
 				{
 					PeerID: "77.93.223.9:8333",
 					Metadata: forceMarshalMap(t, &PeerInfo{
@@ -466,6 +522,7 @@ func TestGetPeers(t *testing.T) {
 					Metadata: forceMarshalMap(t, &PeerInfo{
 						Addr:           "172.105.93.179:8333",
 						RelayTxes:      true,
+						Inbound:        true,
 						LastSend:       1597606678,
 						LastRecv:       1597606676,
 						Version:        70015,
@@ -477,6 +534,8 @@ func TestGetPeers(t *testing.T) {
 					}),
 				},
 			},
+			expectedInbound:  1,
+			expectedOutbound: 1,
 		},
 		"blockchain warming up error": {
 			responses: []responseFixture{
@@ -528,6 +587,18 @@ func TestGetPeers(t *testing.T) {
 			} else {
 				assert.NoError(err)
 				assert.Equal(test.expectedPeers, peers)
+				assert.Equal(
+					test.expectedInbound,
+					testutil.ToFloat64(
+						metrics.WhivedPeers.WithLabelValues(metrics.PeerDirectionInbound),
+					),
+				)
+				assert.Equal(
+					test.expectedOutbound,
+					testutil.ToFloat64(
+						metrics.WhivedPeers.WithLabelValues(metrics.PeerDirectionOutbound),
+					),
+				)
 			}
 		})
 	}
@@ -535,7 +606,7 @@ func TestGetPeers(t *testing.T) {
 
 func TestGetRawBlock(t *testing.T) {
 	tests := map[string]struct {
-		blockIdentifier *PartialBlockIdentifier
+		blockIdentifier *types.PartialBlockIdentifier
 		responses       []responseFixture
 
 		expectedBlock *Block
@@ -543,7 +614,7 @@ func TestGetRawBlock(t *testing.T) {
 		expectedError error
 	}{
 		"lookup by hash": {
-			blockIdentifier: &PartialBlockIdentifier{
+			blockIdentifier: &types.PartialBlockIdentifier{
 				Hash: &blockIdentifier1000.Hash,
 			},
 			responses: []responseFixture{
@@ -557,7 +628,7 @@ func TestGetRawBlock(t *testing.T) {
 			expectedCoins: []string{},
 		},
 		"lookup by hash 2": {
-			blockIdentifier: &PartialBlockIdentifier{
+			blockIdentifier: &types.PartialBlockIdentifier{
 				Hash: &blockIdentifier100000.Hash,
 			},
 			responses: []responseFixture{
@@ -575,7 +646,7 @@ func TestGetRawBlock(t *testing.T) {
 			},
 		},
 		"lookup by hash (get block api error)": {
-			blockIdentifier: &PartialBlockIdentifier{
+			blockIdentifier: &types.PartialBlockIdentifier{
 				Hash: &blockIdentifier1000.Hash,
 			},
 			responses: []responseFixture{
@@ -587,8 +658,29 @@ func TestGetRawBlock(t *testing.T) {
 			},
 			expectedError: ErrBlockNotFound,
 		},
+		"lookup by hash (pruned)": {
+			blockIdentifier: &types.PartialBlockIdentifier{
+				Hash: &blockIdentifier1000.Hash,
+			},
+			responses: []responseFixture{
+				{
+					status: http.StatusOK,
+					body:   loadFixture("get_block_pruned_response.json"),
+					url:    url,
+				},
+				{
+					status: http.StatusOK,
+					body:   loadFixture("get_blockchain_info_pruned_response.json"),
+					url:    url,
+				},
+			},
+			expectedError: &PrunedBlockError{
+				Hash:               blockIdentifier1000.Hash,
+				MinAvailableHeight: 900,
+			},
+		},
 		"lookup by hash (get block internal error)": {
-			blockIdentifier: &PartialBlockIdentifier{
+			blockIdentifier: &types.PartialBlockIdentifier{
 				Hash: &blockIdentifier1000.Hash,
 			},
 			responses: []responseFixture{
@@ -602,7 +694,7 @@ func TestGetRawBlock(t *testing.T) {
 			expectedError: errors.New("invalid response: 500 Internal Server Error"),
 		},
 		"lookup by index": {
-			blockIdentifier: &PartialBlockIdentifier{
+			blockIdentifier: &types.PartialBlockIdentifier{
 				Index: &blockIdentifier1000.Index,
 			},
 			responses: []responseFixture{
@@ -621,7 +713,7 @@ func TestGetRawBlock(t *testing.T) {
 			expectedCoins: []string{},
 		},
 		"lookup by index (out of range)": {
-			blockIdentifier: &PartialBlockIdentifier{
+			blockIdentifier: &types.PartialBlockIdentifier{
 				Index: &blockIdentifier1000.Index,
 			},
 			responses: []responseFixture{
@@ -686,6 +778,9 @@ func TestGetRawBlock(t *testing.T) {
 			block, coins, err := client.GetRawBlock(context.Background(), test.blockIdentifier)
 			if test.expectedError != nil {
 				assert.Contains(err.Error(), test.expectedError.Error())
+				if errors.Is(test.expectedError, ErrBlockPruned) {
+					assert.True(errors.Is(err, ErrBlockPruned))
+				}
 			} else {
 				assert.NoError(err)
 				assert.Equal(test.expectedBlock, block)
@@ -700,63 +795,63 @@ func int64Pointer(v int64) *int64 {
 }
 
 func mustMarshalMap(v interface{}) map[string]interface{} {
-	m, _ := MarshalMap(v)
+	m, _ := types.MarshalMap(v)
 	return m
 }
 
 func TestParseBlock(t *testing.T) {
 	tests := map[string]struct {
 		block *Block
-		coins map[string]*AccountCoin
+		coins map[string]*types.AccountCoin
 
-		expectedBlock *Block
+		expectedBlock *types.Block
 		expectedError error
 	}{
 		"no fetched transactions": {
 			block: block1000,
-			coins: map[string]*AccountCoin{},
-			expectedBlock: &Block{
+			coins: map[string]*types.AccountCoin{},
+			expectedBlock: &types.Block{
 				BlockIdentifier: blockIdentifier1000,
-				ParentBlockIdentifier: &BlockIdentifier{
+				ParentBlockIdentifier: &types.BlockIdentifier{
 					Hash:  "0000000008e647742775a230787d66fdf92c46a48c896bfbc85cdc8acc67e87d",
 					Index: 999,
 				},
 				Timestamp: 1232346882000,
-				Transactions: []*Transaction{
+				Transactions: []*types.Transaction{
 					{
-						TransactionIdentifier: &TransactionIdentifier{
+						TransactionIdentifier: &types.TransactionIdentifier{
 							Hash: "fe28050b93faea61fa88c4c630f0e1f0a1c24d0082dd0e10d369e13212128f33",
 						},
-						Operations: []*Operation{
+						Operations: []*types.Operation{
 							{
-								OperationIdentifier: &OperationIdentifier{
+								OperationIdentifier: &types.OperationIdentifier{
 									Index:        0,
 									NetworkIndex: int64Pointer(0),
 								},
 								Type:   CoinbaseOpType,
-								Status: String(SuccessStatus),
+								Status: types.String(SuccessStatus),
 								Metadata: mustMarshalMap(&OperationMetadata{
 									Coinbase: "04ffff001d02fd04",
 									Sequence: 4294967295,
 								}),
 							},
 							{
-								OperationIdentifier: &OperationIdentifier{
+								OperationIdentifier: &types.OperationIdentifier{
 									Index:        1,
 									NetworkIndex: int64Pointer(0),
 								},
 								Type:   OutputOpType,
-								Status: String(SuccessStatus),
-								Account: &AccountIdentifier{
+								Status: types.String(SuccessStatus),
+								Account: &types.AccountIdentifier{
 									Address: "4104f5eeb2b10c944c6b9fbcfff94c35bdeecd93df977882babc7f3a2cf7f5c81d3b09a68db7f0e04f21de5d4230e75e6dbe7ad16eefe0d4325a62067dc6f369446aac", // nolint
 								},
-								Amount: &Amount{
+								Amount: &types.Amount{
 									Value:    "5000000000",
 									Currency: MainnetCurrency,
 								},
-								CoinChange: &CoinChange{
-									CoinAction: CoinCreated,
-									CoinIdentifier: &CoinIdentifier{
+								CoinChange: &types.CoinChange{
+									CoinAction: types.CoinCreated,
+									CoinIdentifier: &types.CoinIdentifier{
 										Identifier: "fe28050b93faea61fa88c4c630f0e1f0a1c24d0082dd0e10d369e13212128f33:0",
 									},
 								},
@@ -770,34 +865,35 @@ func TestParseBlock(t *testing.T) {
 							},
 						},
 						Metadata: mustMarshalMap(&TransactionMetadata{
-							Size:    135,
-							Version: 1,
-							Vsize:   135,
-							Weight:  540,
+							Size:        135,
+							Version:     1,
+							Vsize:       135,
+							Weight:      540,
+							OutputCount: 1,
 						}),
 					},
 					{
-						TransactionIdentifier: &TransactionIdentifier{
+						TransactionIdentifier: &types.TransactionIdentifier{
 							Hash: "4852fe372ff7534c16713b3146bbc1e86379c70bea4d5c02fb1fa0112980a081",
 						},
-						Operations: []*Operation{
+						Operations: []*types.Operation{
 							{
-								OperationIdentifier: &OperationIdentifier{
+								OperationIdentifier: &types.OperationIdentifier{
 									Index:        0,
 									NetworkIndex: int64Pointer(0),
 								},
 								Type:   OutputOpType,
-								Status: String(SuccessStatus),
-								Account: &AccountIdentifier{
+								Status: types.String(SuccessStatus),
+								Account: &types.AccountIdentifier{
 									Address: "mmtKKnjqTPdkBnBMbNt5Yu2SCwpMaEshEL", // nolint
 								},
-								Amount: &Amount{
+								Amount: &types.Amount{
 									Value:    "3810000",
 									Currency: MainnetCurrency,
 								},
-								CoinChange: &CoinChange{
-									CoinAction: CoinCreated,
-									CoinIdentifier: &CoinIdentifier{
+								CoinChange: &types.CoinChange{
+									CoinAction: types.CoinCreated,
+									CoinIdentifier: &types.CoinIdentifier{
 										Identifier: "4852fe372ff7534c16713b3146bbc1e86379c70bea4d5c02fb1fa0112980a081:0",
 									},
 								},
@@ -814,22 +910,22 @@ func TestParseBlock(t *testing.T) {
 								}),
 							},
 							{
-								OperationIdentifier: &OperationIdentifier{
+								OperationIdentifier: &types.OperationIdentifier{
 									Index:        1,
 									NetworkIndex: int64Pointer(1),
 								},
 								Type:   OutputOpType,
-								Status: String(SuccessStatus),
-								Account: &AccountIdentifier{
+								Status: types.String(SuccessStatus),
+								Account: &types.AccountIdentifier{
 									Address: "4852fe372ff7534c16713b3146bbc1e86379c70bea4d5c02fb1fa0112980a081:1",
 								},
-								Amount: &Amount{
+								Amount: &types.Amount{
 									Value:    "50000000",
 									Currency: MainnetCurrency,
 								},
-								CoinChange: &CoinChange{
-									CoinAction: CoinCreated,
-									CoinIdentifier: &CoinIdentifier{
+								CoinChange: &types.CoinChange{
+									CoinAction: types.CoinCreated,
+									CoinIdentifier: &types.CoinIdentifier{
 										Identifier: "4852fe372ff7534c16713b3146bbc1e86379c70bea4d5c02fb1fa0112980a081:1",
 									},
 								},
@@ -843,10 +939,11 @@ func TestParseBlock(t *testing.T) {
 							},
 						},
 						Metadata: mustMarshalMap(&TransactionMetadata{
-							Size:    1408,
-							Version: 1,
-							Vsize:   1408,
-							Weight:  5632,
+							Size:        1408,
+							Version:     1,
+							Vsize:       1408,
+							Weight:      5632,
+							OutputCount: 2,
 						}),
 					},
 				},
@@ -864,92 +961,92 @@ func TestParseBlock(t *testing.T) {
 		},
 		"block 100000": {
 			block: block100000,
-			coins: map[string]*AccountCoin{
+			coins: map[string]*types.AccountCoin{
 				"87a157f3fd88ac7907c05fc55e271dc4acdc5605d187d646604ca8c0e9382e03:0": {
-					Account: &AccountIdentifier{
+					Account: &types.AccountIdentifier{
 						Address: "1BNwxHGaFbeUBitpjy2AsKpJ29Ybxntqvb",
 					},
-					Coin: &Coin{
-						CoinIdentifier: &CoinIdentifier{
+					Coin: &types.Coin{
+						CoinIdentifier: &types.CoinIdentifier{
 							Identifier: "87a157f3fd88ac7907c05fc55e271dc4acdc5605d187d646604ca8c0e9382e03:0",
 						},
-						Amount: &Amount{
+						Amount: &types.Amount{
 							Value:    "5000000000",
 							Currency: MainnetCurrency,
 						},
 					},
 				},
 				"503e4e9824282eb06f1a328484e2b367b5f4f93a405d6e7b97261bafabfb53d5:0": {
-					Account: &AccountIdentifier{
+					Account: &types.AccountIdentifier{
 						Address: "3FfQGY7jqsADC7uTVqF3vKQzeNPiBPTqt4",
 					},
-					Coin: &Coin{
-						CoinIdentifier: &CoinIdentifier{
+					Coin: &types.Coin{
+						CoinIdentifier: &types.CoinIdentifier{
 							Identifier: "503e4e9824282eb06f1a328484e2b367b5f4f93a405d6e7b97261bafabfb53d5:0",
 						},
-						Amount: &Amount{
+						Amount: &types.Amount{
 							Value:    "3467607",
 							Currency: MainnetCurrency,
 						},
 					},
 				},
 				"503e4e9824282eb06f1a328484e2b367b5f4f93a405d6e7b97261bafabfb53d5:1": {
-					Account: &AccountIdentifier{
+					Account: &types.AccountIdentifier{
 						Address: "1NdvAyRJLdK5EXs7DV3ebYb5wffdCZk1pD",
 					},
-					Coin: &Coin{
-						CoinIdentifier: &CoinIdentifier{
+					Coin: &types.Coin{
+						CoinIdentifier: &types.CoinIdentifier{
 							Identifier: "503e4e9824282eb06f1a328484e2b367b5f4f93a405d6e7b97261bafabfb53d5:1",
 						},
-						Amount: &Amount{
+						Amount: &types.Amount{
 							Value:    "0",
 							Currency: MainnetCurrency,
 						},
 					},
 				},
 			},
-			expectedBlock: &Block{
+			expectedBlock: &types.Block{
 				BlockIdentifier: blockIdentifier100000,
-				ParentBlockIdentifier: &BlockIdentifier{
+				ParentBlockIdentifier: &types.BlockIdentifier{
 					Hash:  "000000000002d01c1fccc21636b607dfd930d31d01c3a62104612a1719011250",
 					Index: 99999,
 				},
 				Timestamp: 1293623863000,
-				Transactions: []*Transaction{
+				Transactions: []*types.Transaction{
 					{
-						TransactionIdentifier: &TransactionIdentifier{
+						TransactionIdentifier: &types.TransactionIdentifier{
 							Hash: "8c14f0db3df150123e6f3dbbf30f8b955a8249b62ac1d1ff16284aefa3d06d87",
 						},
-						Operations: []*Operation{
+						Operations: []*types.Operation{
 							{
-								OperationIdentifier: &OperationIdentifier{
+								OperationIdentifier: &types.OperationIdentifier{
 									Index:        0,
 									NetworkIndex: int64Pointer(0),
 								},
 								Type:   CoinbaseOpType,
-								Status: String(SuccessStatus),
+								Status: types.String(SuccessStatus),
 								Metadata: mustMarshalMap(&OperationMetadata{
 									Coinbase: "044c86041b020602",
 									Sequence: 4294967295,
 								}),
 							},
 							{
-								OperationIdentifier: &OperationIdentifier{
+								OperationIdentifier: &types.OperationIdentifier{
 									Index:        1,
 									NetworkIndex: int64Pointer(0),
 								},
 								Type:   OutputOpType,
-								Status: String(SuccessStatus),
-								Account: &AccountIdentifier{
+								Status: types.String(SuccessStatus),
+								Account: &types.AccountIdentifier{
 									Address: "34qkc2iac6RsyxZVfyE2S5U5WcRsbg2dpK",
 								},
-								Amount: &Amount{
+								Amount: &types.Amount{
 									Value:    "1589351625",
 									Currency: MainnetCurrency,
 								},
-								CoinChange: &CoinChange{
-									CoinAction: CoinCreated,
-									CoinIdentifier: &CoinIdentifier{
+								CoinChange: &types.CoinChange{
+									CoinAction: types.CoinCreated,
+									CoinIdentifier: &types.CoinIdentifier{
 										Identifier: "8c14f0db3df150123e6f3dbbf30f8b955a8249b62ac1d1ff16284aefa3d06d87:0",
 									},
 								},
@@ -966,16 +1063,16 @@ func TestParseBlock(t *testing.T) {
 								}),
 							},
 							{
-								OperationIdentifier: &OperationIdentifier{
+								OperationIdentifier: &types.OperationIdentifier{
 									Index:        2,
 									NetworkIndex: int64Pointer(1),
 								},
 								Type:   OutputOpType,
-								Status: String(SuccessStatus),
-								Account: &AccountIdentifier{
+								Status: types.String(SuccessStatus),
+								Account: &types.AccountIdentifier{
 									Address: "6a24aa21a9ed10109f4b82aa3ed7ec9d02a2a90246478b3308c8b85daf62fe501d58d05727a4",
 								},
-								Amount: &Amount{
+								Amount: &types.Amount{
 									Value:    "0",
 									Currency: MainnetCurrency,
 								},
@@ -985,38 +1082,40 @@ func TestParseBlock(t *testing.T) {
 										Hex:  "6a24aa21a9ed10109f4b82aa3ed7ec9d02a2a90246478b3308c8b85daf62fe501d58d05727a4",
 										Type: "nulldata",
 									},
+									WitnessCommitment: true,
 								}),
 							},
 						},
 						Metadata: mustMarshalMap(&TransactionMetadata{
-							Size:    135,
-							Version: 1,
-							Vsize:   135,
-							Weight:  540,
+							Size:        135,
+							Version:     1,
+							Vsize:       135,
+							Weight:      540,
+							OutputCount: 2,
 						}),
 					},
 					{
-						TransactionIdentifier: &TransactionIdentifier{
+						TransactionIdentifier: &types.TransactionIdentifier{
 							Hash: "fff2525b8931402dd09222c50775608f75787bd2b87e56995a7bdd30f79702c4",
 						},
-						Operations: []*Operation{
+						Operations: []*types.Operation{
 							{
-								OperationIdentifier: &OperationIdentifier{
+								OperationIdentifier: &types.OperationIdentifier{
 									Index:        0,
 									NetworkIndex: int64Pointer(0),
 								},
 								Type:   InputOpType,
-								Status: String(SuccessStatus),
-								Amount: &Amount{
+								Status: types.String(SuccessStatus),
+								Amount: &types.Amount{
 									Value:    "-5000000000",
 									Currency: MainnetCurrency,
 								},
-								Account: &AccountIdentifier{
+								Account: &types.AccountIdentifier{
 									Address: "1BNwxHGaFbeUBitpjy2AsKpJ29Ybxntqvb",
 								},
-								CoinChange: &CoinChange{
-									CoinAction: CoinSpent,
-									CoinIdentifier: &CoinIdentifier{
+								CoinChange: &types.CoinChange{
+									CoinAction: types.CoinSpent,
+									CoinIdentifier: &types.CoinIdentifier{
 										Identifier: "87a157f3fd88ac7907c05fc55e271dc4acdc5605d187d646604ca8c0e9382e03:0",
 									},
 								},
@@ -1029,22 +1128,22 @@ func TestParseBlock(t *testing.T) {
 								}),
 							},
 							{
-								OperationIdentifier: &OperationIdentifier{
+								OperationIdentifier: &types.OperationIdentifier{
 									Index:        1,
 									NetworkIndex: int64Pointer(0),
 								},
 								Type:   OutputOpType,
-								Status: String(SuccessStatus),
-								Account: &AccountIdentifier{
+								Status: types.String(SuccessStatus),
+								Account: &types.AccountIdentifier{
 									Address: "1JqDybm2nWTENrHvMyafbSXXtTk5Uv5QAn",
 								},
-								Amount: &Amount{
+								Amount: &types.Amount{
 									Value:    "556000000",
 									Currency: MainnetCurrency,
 								},
-								CoinChange: &CoinChange{
-									CoinAction: CoinCreated,
-									CoinIdentifier: &CoinIdentifier{
+								CoinChange: &types.CoinChange{
+									CoinAction: types.CoinCreated,
+									CoinIdentifier: &types.CoinIdentifier{
 										Identifier: "fff2525b8931402dd09222c50775608f75787bd2b87e56995a7bdd30f79702c4:0",
 									},
 								},
@@ -1061,22 +1160,22 @@ func TestParseBlock(t *testing.T) {
 								}),
 							},
 							{
-								OperationIdentifier: &OperationIdentifier{
+								OperationIdentifier: &types.OperationIdentifier{
 									Index:        2,
 									NetworkIndex: int64Pointer(1),
 								},
 								Type:   OutputOpType,
-								Status: String(SuccessStatus),
-								Account: &AccountIdentifier{
+								Status: types.String(SuccessStatus),
+								Account: &types.AccountIdentifier{
 									Address: "1EYTGtG4LnFfiMvjJdsU7GMGCQvsRSjYhx",
 								},
-								Amount: &Amount{
+								Amount: &types.Amount{
 									Value:    "4444000000",
 									Currency: MainnetCurrency,
 								},
-								CoinChange: &CoinChange{
-									CoinAction: CoinCreated,
-									CoinIdentifier: &CoinIdentifier{
+								CoinChange: &types.CoinChange{
+									CoinAction: types.CoinCreated,
+									CoinIdentifier: &types.CoinIdentifier{
 										Identifier: "fff2525b8931402dd09222c50775608f75787bd2b87e56995a7bdd30f79702c4:1",
 									},
 								},
@@ -1094,34 +1193,36 @@ func TestParseBlock(t *testing.T) {
 							},
 						},
 						Metadata: mustMarshalMap(&TransactionMetadata{
-							Size:    259,
-							Version: 1,
-							Vsize:   259,
-							Weight:  1036,
+							Size:        259,
+							Version:     1,
+							Vsize:       259,
+							Weight:      1036,
+							InputCount:  1,
+							OutputCount: 2,
 						}),
 					},
 					{
-						TransactionIdentifier: &TransactionIdentifier{
+						TransactionIdentifier: &types.TransactionIdentifier{
 							Hash: "fake",
 						},
-						Operations: []*Operation{
+						Operations: []*types.Operation{
 							{
-								OperationIdentifier: &OperationIdentifier{
+								OperationIdentifier: &types.OperationIdentifier{
 									Index:        0,
 									NetworkIndex: int64Pointer(0),
 								},
 								Type:   InputOpType,
-								Status: String(SuccessStatus),
-								Amount: &Amount{
+								Status: types.String(SuccessStatus),
+								Amount: &types.Amount{
 									Value:    "-3467607",
 									Currency: MainnetCurrency,
 								},
-								Account: &AccountIdentifier{
+								Account: &types.AccountIdentifier{
 									Address: "3FfQGY7jqsADC7uTVqF3vKQzeNPiBPTqt4",
 								},
-								CoinChange: &CoinChange{
-									CoinAction: CoinSpent,
-									CoinIdentifier: &CoinIdentifier{
+								CoinChange: &types.CoinChange{
+									CoinAction: types.CoinSpent,
+									CoinIdentifier: &types.CoinIdentifier{
 										Identifier: "503e4e9824282eb06f1a328484e2b367b5f4f93a405d6e7b97261bafabfb53d5:0",
 									},
 								},
@@ -1138,22 +1239,22 @@ func TestParseBlock(t *testing.T) {
 								}),
 							},
 							{
-								OperationIdentifier: &OperationIdentifier{
+								OperationIdentifier: &types.OperationIdentifier{
 									Index:        1,
 									NetworkIndex: int64Pointer(1),
 								},
 								Type:   InputOpType,
-								Status: String(SuccessStatus),
-								Amount: &Amount{
+								Status: types.String(SuccessStatus),
+								Amount: &types.Amount{
 									Value:    "0",
 									Currency: MainnetCurrency,
 								},
-								Account: &AccountIdentifier{
+								Account: &types.AccountIdentifier{
 									Address: "1NdvAyRJLdK5EXs7DV3ebYb5wffdCZk1pD",
 								},
-								CoinChange: &CoinChange{
-									CoinAction: CoinSpent,
-									CoinIdentifier: &CoinIdentifier{
+								CoinChange: &types.CoinChange{
+									CoinAction: types.CoinSpent,
+									CoinIdentifier: &types.CoinIdentifier{
 										Identifier: "503e4e9824282eb06f1a328484e2b367b5f4f93a405d6e7b97261bafabfb53d5:1",
 									},
 								},
@@ -1166,22 +1267,22 @@ func TestParseBlock(t *testing.T) {
 								}),
 							},
 							{
-								OperationIdentifier: &OperationIdentifier{
+								OperationIdentifier: &types.OperationIdentifier{
 									Index:        2,
 									NetworkIndex: int64Pointer(2),
 								},
 								Type:   InputOpType,
-								Status: String(SuccessStatus),
-								Amount: &Amount{
+								Status: types.String(SuccessStatus),
+								Amount: &types.Amount{
 									Value:    "-556000000",
 									Currency: MainnetCurrency,
 								},
-								Account: &AccountIdentifier{
+								Account: &types.AccountIdentifier{
 									Address: "1JqDybm2nWTENrHvMyafbSXXtTk5Uv5QAn",
 								},
-								CoinChange: &CoinChange{
-									CoinAction: CoinSpent,
-									CoinIdentifier: &CoinIdentifier{
+								CoinChange: &types.CoinChange{
+									CoinAction: types.CoinSpent,
+									CoinIdentifier: &types.CoinIdentifier{
 										Identifier: "fff2525b8931402dd09222c50775608f75787bd2b87e56995a7bdd30f79702c4:0",
 									},
 								},
@@ -1194,22 +1295,22 @@ func TestParseBlock(t *testing.T) {
 								}),
 							},
 							{
-								OperationIdentifier: &OperationIdentifier{
+								OperationIdentifier: &types.OperationIdentifier{
 									Index:        3,
 									NetworkIndex: int64Pointer(0),
 								},
 								Type:   OutputOpType,
-								Status: String(SuccessStatus),
-								Account: &AccountIdentifier{
+								Status: types.String(SuccessStatus),
+								Account: &types.AccountIdentifier{
 									Address: "76a914c398efa9c392ba6013c5e04ee729755ef7f58b3288ac",
 								},
-								Amount: &Amount{
+								Amount: &types.Amount{
 									Value:    "20056000000",
 									Currency: MainnetCurrency,
 								},
-								CoinChange: &CoinChange{
-									CoinAction: CoinCreated,
-									CoinIdentifier: &CoinIdentifier{
+								CoinChange: &types.CoinChange{
+									CoinAction: types.CoinCreated,
+									CoinIdentifier: &types.CoinIdentifier{
 										Identifier: "fake:0",
 									},
 								},
@@ -1228,11 +1329,13 @@ func TestParseBlock(t *testing.T) {
 							},
 						},
 						Metadata: mustMarshalMap(&TransactionMetadata{
-							Size:     421,
-							Version:  2,
-							Vsize:    612,
-							Weight:   129992,
-							Locktime: 10,
+							Size:        421,
+							Version:     2,
+							Vsize:       612,
+							Weight:      129992,
+							Locktime:    10,
+							InputCount:  3,
+							OutputCount: 1,
 						}),
 					},
 				},
@@ -1248,9 +1351,84 @@ func TestParseBlock(t *testing.T) {
 				}),
 			},
 		},
+		"genesis coinbase": {
+			block: genesisBlock,
+			coins: map[string]*types.AccountCoin{},
+			expectedBlock: &types.Block{
+				BlockIdentifier:       MainnetGenesisBlockIdentifier,
+				ParentBlockIdentifier: MainnetGenesisBlockIdentifier,
+				Timestamp:             1231006505000,
+				Transactions: []*types.Transaction{
+					{
+						TransactionIdentifier: &types.TransactionIdentifier{
+							Hash: "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b",
+						},
+						Operations: []*types.Operation{
+							{
+								OperationIdentifier: &types.OperationIdentifier{
+									Index:        0,
+									NetworkIndex: int64Pointer(0),
+								},
+								Type:   CoinbaseOpType,
+								Status: types.String(SkippedStatus),
+								Metadata: mustMarshalMap(&OperationMetadata{
+									Coinbase: "04ffff001d0104",
+									Sequence: 4294967295,
+								}),
+							},
+							{
+								OperationIdentifier: &types.OperationIdentifier{
+									Index:        1,
+									NetworkIndex: int64Pointer(0),
+								},
+								Type:   OutputOpType,
+								Status: types.String(SkippedStatus),
+								Account: &types.AccountIdentifier{
+									Address: "4104678afdb0feac",
+								},
+								Amount: &types.Amount{
+									Value:    "5000000000",
+									Currency: MainnetCurrency,
+								},
+								CoinChange: &types.CoinChange{
+									CoinAction: types.CoinCreated,
+									CoinIdentifier: &types.CoinIdentifier{
+										Identifier: "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b:0",
+									},
+								},
+								Metadata: mustMarshalMap(&OperationMetadata{
+									ScriptPubKey: &ScriptPubKey{
+										ASM:  "04678afdb0fe OP_CHECKSIG",
+										Hex:  "4104678afdb0feac",
+										Type: "pubkey",
+									},
+								}),
+							},
+						},
+						Metadata: mustMarshalMap(&TransactionMetadata{
+							Size:        204,
+							Version:     1,
+							Vsize:       204,
+							Weight:      816,
+							OutputCount: 1,
+						}),
+					},
+				},
+				Metadata: mustMarshalMap(&BlockMetadata{
+					Size:       285,
+					Weight:     1140,
+					Version:    1,
+					MerkleRoot: "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b",
+					MedianTime: 1231006505,
+					Nonce:      2083236893,
+					Bits:       "1d00ffff",
+					Difficulty: 1,
+				}),
+			},
+		},
 		"missing transactions": {
 			block:         block100000,
-			coins:         map[string]*AccountCoin{},
+			coins:         map[string]*types.AccountCoin{},
 			expectedError: errors.New("error finding previous tx"),
 		},
 	}
@@ -1268,11 +1446,361 @@ func TestParseBlock(t *testing.T) {
 			} else {
 				assert.NoError(err)
 				assert.Equal(test.expectedBlock, block)
+
+				// The input and output counts in transaction
+				// metadata match the parsed operations.
+				for _, tx := range block.Transactions {
+					counts := map[string]int{}
+					for _, op := range tx.Operations {
+						counts[op.Type]++
+					}
+
+					var metadata TransactionMetadata
+					assert.NoError(types.UnmarshalMap(tx.Metadata, &metadata))
+					assert.Equal(counts[InputOpType], metadata.InputCount)
+					assert.Equal(counts[OutputOpType], metadata.OutputCount)
+				}
+			}
+		})
+	}
+}
+
+func TestParseBlock_WitnessScriptHash(t *testing.T) {
+	p2wshAddress := "tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sl5k7"
+	p2wshScript := "00201863143c14c5166804bd19203356da136c985678cd4d27a1b8c6329604903262"
+
+	// The first transaction funds a P2WSH output
+	// and the second spends it. bitcoind does not
+	// return addresses for the P2WSH output.
+	block := &Block{
+		Hash:              "block hash",
+		Height:            1000,
+		PreviousBlockHash: "parent hash",
+		Txs: []*Transaction{
+			{
+				Hash: "fund",
+				Inputs: []*Input{
+					{TxHash: "previous", Vout: 0},
+				},
+				Outputs: []*Output{
+					{
+						Value: 0.0009,
+						Index: 0,
+						ScriptPubKey: &ScriptPubKey{
+							Hex:  p2wshScript,
+							Type: WitnessV0ScriptHash,
+						},
+					},
+				},
+			},
+			{
+				Hash: "spend",
+				Inputs: []*Input{
+					{
+						TxHash:      "fund",
+						Vout:        0,
+						TxInWitness: []string{"", "signature", "witness script"},
+					},
+				},
+				Outputs: []*Output{
+					{
+						Value: 0.0008,
+						Index: 0,
+						ScriptPubKey: &ScriptPubKey{
+							Hex:          "76a91445db0b779c0b9fa207f12a8218c94fc77aff504588ac",
+							Type:         "pubkeyhash",
+							RequiredSigs: 1,
+							Addresses:    []string{"mmtKKnjqTPdkBnBMbNt5Yu2SCwpMaEshEL"},
+						},
+					},
+				},
+			},
+		},
+	}
+	coins := func() map[string]*types.AccountCoin {
+		return map[string]*types.AccountCoin{
+			"previous:0": {
+				Account: &types.AccountIdentifier{Address: "mmtKKnjqTPdkBnBMbNt5Yu2SCwpMaEshEL"},
+				Coin: &types.Coin{
+					CoinIdentifier: &types.CoinIdentifier{Identifier: "previous:0"},
+					Amount:         &types.Amount{Value: "100000", Currency: TestnetCurrency},
+				},
+			},
+		}
+	}
+
+	tests := map[string]struct {
+		options []ClientOption
+
+		expectedAddress string
+	}{
+		"params": {
+			options:         []ClientOption{WithParams(TestnetParams)},
+			expectedAddress: p2wshAddress,
+		},
+		"no params": {
+			expectedAddress: p2wshScript,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := NewClient(
+				"",
+				TestnetGenesisBlockIdentifier,
+				TestnetCurrency,
+				test.options...,
+			)
+			parsed, err := client.ParseBlock(context.Background(), block, coins())
+			assert.NoError(t, err)
+
+			funding := parsed.Transactions[0].Operations[1]
+			assert.Equal(t, OutputOpType, funding.Type)
+			assert.Equal(t, test.expectedAddress, funding.Account.Address)
+			assert.Equal(t, "fund:0", funding.CoinChange.CoinIdentifier.Identifier)
+
+			spending := parsed.Transactions[1].Operations[0]
+			assert.Equal(t, InputOpType, spending.Type)
+			assert.Equal(t, test.expectedAddress, spending.Account.Address)
+			assert.Equal(t, types.CoinSpent, spending.CoinChange.CoinAction)
+			assert.Equal(t, "fund:0", spending.CoinChange.CoinIdentifier.Identifier)
+
+			// The balance of the P2WSH address reconciles
+			// to 0 after it is funded and spent.
+			balance := big.NewInt(0)
+			for _, tx := range parsed.Transactions {
+				for _, op := range tx.Operations {
+					if op.Account == nil || op.Account.Address != test.expectedAddress {
+						continue
+					}
+
+					value, err := types.AmountValue(op.Amount)
+					assert.NoError(t, err)
+					balance.Add(balance, value)
+				}
+			}
+			assert.Equal(t, "0", balance.String())
+		})
+	}
+}
+
+func TestParseBlock_ScriptTypes(t *testing.T) {
+	tests := map[string]struct {
+		scriptPubKey *ScriptPubKey
+
+		expectedScriptType string
+	}{
+		"p2pkh": {
+			scriptPubKey: &ScriptPubKey{
+				Hex:  "76a91445db0b779c0b9fa207f12a8218c94fc77aff504588ac",
+				Type: PubKeyHash,
+			},
+			expectedScriptType: P2PKHScriptType,
+		},
+		"p2sh": {
+			scriptPubKey: &ScriptPubKey{
+				Hex:  "a914f9b1b8b5a6a2c0b7e1f0a6c3d4e5f60718293a4b87",
+				Type: ScriptHash,
+			},
+			expectedScriptType: P2SHScriptType,
+		},
+		"p2wpkh": {
+			scriptPubKey: &ScriptPubKey{
+				Hex:  "0014751e76e8199196d454941c45d1b3a323f1433bd6",
+				Type: WitnessV0PubKeyHash,
+			},
+			expectedScriptType: P2WPKHScriptType,
+		},
+		"p2wsh": {
+			scriptPubKey: &ScriptPubKey{
+				Hex:  "00201863143c14c5166804bd19203356da136c985678cd4d27a1b8c6329604903262",
+				Type: WitnessV0ScriptHash,
+			},
+			expectedScriptType: P2WSHScriptType,
+		},
+		"p2tr": {
+			scriptPubKey: &ScriptPubKey{
+				Hex:  "5120a60869f0dbcf1dc659c9cecbaf8050135ea9e8cdc487053f1dc6880949dc684c",
+				Type: WitnessV1Taproot,
+			},
+			expectedScriptType: P2TRScriptType,
+		},
+		"p2pk": {
+			scriptPubKey: &ScriptPubKey{
+				Hex:  "2102a1633cafcc01ebfb6d78e39f687a1f0995c62fc95f51ead10a02ee0be551b5dcac",
+				Type: PubKey,
+			},
+			expectedScriptType: P2PKScriptType,
+		},
+		"multisig": {
+			scriptPubKey: &ScriptPubKey{
+				Hex:  "512102a1633cafcc01ebfb6d78e39f687a1f0995c62fc95f51ead10a02ee0be551b5dc51ae",
+				Type: MultiSig,
+			},
+			expectedScriptType: MultiSigScriptType,
+		},
+		"op_return": {
+			scriptPubKey: &ScriptPubKey{
+				Hex:  "6a0568656c6c6f",
+				Type: NullData,
+			},
+			expectedScriptType: OpReturnScriptType,
+		},
+		"nonstandard": {
+			scriptPubKey: &ScriptPubKey{
+				Hex:  "51",
+				Type: "nonstandard",
+			},
+			expectedScriptType: NonStandardScriptType,
+		},
+		"unknown witness version": {
+			scriptPubKey: &ScriptPubKey{
+				Hex:  "52020000",
+				Type: "witness_unknown",
+			},
+			expectedScriptType: NonStandardScriptType,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			block := &Block{
+				Hash:              "block hash",
+				Height:            1000,
+				PreviousBlockHash: "parent hash",
+				Txs: []*Transaction{
+					{
+						Hash: "tx",
+						Inputs: []*Input{
+							{TxHash: "previous", Vout: 0},
+						},
+						Outputs: []*Output{
+							{Value: 0.0009, Index: 0, ScriptPubKey: test.scriptPubKey},
+						},
+					},
+				},
 			}
+			coins := map[string]*types.AccountCoin{
+				"previous:0": {
+					Account: &types.AccountIdentifier{Address: "mmtKKnjqTPdkBnBMbNt5Yu2SCwpMaEshEL"},
+					Coin: &types.Coin{
+						CoinIdentifier: &types.CoinIdentifier{Identifier: "previous:0"},
+						Amount:         &types.Amount{Value: "100000", Currency: TestnetCurrency},
+					},
+				},
+			}
+
+			// Script types are only included if enabled
+			client := NewClient("", TestnetGenesisBlockIdentifier, TestnetCurrency)
+			parsed, err := client.ParseBlock(context.Background(), block, coins)
+			assert.NoError(t, err)
+			output := parsed.Transactions[0].Operations[1]
+			assert.Equal(t, OutputOpType, output.Type)
+			assert.NotContains(t, output.Metadata, "script_type")
+
+			client = NewClient(
+				"",
+				TestnetGenesisBlockIdentifier,
+				TestnetCurrency,
+				WithScriptTypes(),
+			)
+			parsed, err = client.ParseBlock(context.Background(), block, coins)
+			assert.NoError(t, err)
+			output = parsed.Transactions[0].Operations[1]
+			assert.Equal(t, OutputOpType, output.Type)
+			assert.Equal(t, test.expectedScriptType, output.Metadata["script_type"])
 		})
 	}
 }
 
+func TestParseBlock_RewardMetadata(t *testing.T) {
+	pubKeyHash := &ScriptPubKey{
+		Hex:          "76a91445db0b779c0b9fa207f12a8218c94fc77aff504588ac",
+		Type:         "pubkeyhash",
+		RequiredSigs: 1,
+		Addresses:    []string{"mmtKKnjqTPdkBnBMbNt5Yu2SCwpMaEshEL"},
+	}
+
+	// The coinbase claims the subsidy at height 210000
+	// (25 WHIVE) and the fee paid by the second transaction.
+	block := &Block{
+		Hash:              "block hash",
+		Height:            210000,
+		PreviousBlockHash: "parent hash",
+		Txs: []*Transaction{
+			{
+				Hash: "coinbase",
+				Inputs: []*Input{
+					{Coinbase: "03501a03"},
+				},
+				Outputs: []*Output{
+					{Value: 25.001, Index: 0, ScriptPubKey: pubKeyHash},
+				},
+			},
+			{
+				Hash: "spend",
+				Inputs: []*Input{
+					{TxHash: "previous", Vout: 0},
+				},
+				Outputs: []*Output{
+					{Value: 0.6, Index: 0, ScriptPubKey: pubKeyHash},
+					{Value: 0.399, Index: 1, ScriptPubKey: pubKeyHash},
+				},
+			},
+		},
+	}
+	coins := func() map[string]*types.AccountCoin {
+		return map[string]*types.AccountCoin{
+			"previous:0": {
+				Account: &types.AccountIdentifier{Address: "mmtKKnjqTPdkBnBMbNt5Yu2SCwpMaEshEL"},
+				Coin: &types.Coin{
+					CoinIdentifier: &types.CoinIdentifier{Identifier: "previous:0"},
+					Amount:         &types.Amount{Value: "100000000", Currency: MainnetCurrency},
+				},
+			},
+		}
+	}
+
+	client := NewClient(
+		"",
+		MainnetGenesisBlockIdentifier,
+		MainnetCurrency,
+		WithParams(MainnetParams),
+	)
+	parsed, err := client.ParseBlock(context.Background(), block, coins())
+	assert.NoError(t, err)
+
+	var metadata BlockMetadata
+	assert.NoError(t, types.UnmarshalMap(parsed.Metadata, &metadata))
+	assert.Equal(t, "2500000000", metadata.Subsidy)
+	assert.Equal(t, "100000", metadata.Fees)
+
+	// The subsidy and fees reconcile with the
+	// coinbase outputs.
+	coinbaseTotal := "0"
+	for _, op := range parsed.Transactions[0].Operations {
+		if op.Type != OutputOpType {
+			continue
+		}
+
+		coinbaseTotal, err = types.AddValues(coinbaseTotal, op.Amount.Value)
+		assert.NoError(t, err)
+	}
+	reward, err := types.AddValues(metadata.Subsidy, metadata.Fees)
+	assert.NoError(t, err)
+	assert.Equal(t, coinbaseTotal, reward)
+
+	// Without params, the breakdown is not reported.
+	client = NewClient("", MainnetGenesisBlockIdentifier, MainnetCurrency)
+	parsed, err = client.ParseBlock(context.Background(), block, coins())
+	assert.NoError(t, err)
+
+	metadata = BlockMetadata{}
+	assert.NoError(t, types.UnmarshalMap(parsed.Metadata, &metadata))
+	assert.Empty(t, metadata.Subsidy)
+	assert.Empty(t, metadata.Fees)
+}
+
 func TestSuggestedFeeRate(t *testing.T) {
 	tests := map[string]struct {
 		responses []responseFixture
@@ -1290,6 +1818,16 @@ func TestSuggestedFeeRate(t *testing.T) {
 			},
 			expectedRate: float64(0.00001),
 		},
+		"no estimate": {
+			responses: []responseFixture{
+				{
+					status: http.StatusOK,
+					body:   loadFixture("no_fee_rate.json"),
+					url:    url,
+				},
+			},
+			expectedRate: float64(0),
+		},
 		"invalid range error": {
 			responses: []responseFixture{
 				{
@@ -1345,26 +1883,22 @@ func TestSuggestedFeeRate(t *testing.T) {
 	}
 }
 
-func TestRawMempool(t *testing.T) {
+func TestMempoolMinFeeRate(t *testing.T) {
 	tests := map[string]struct {
 		responses []responseFixture
 
-		expectedTransactions []string
-		expectedError        error
+		expectedRate  float64
+		expectedError error
 	}{
 		"successful": {
 			responses: []responseFixture{
 				{
 					status: http.StatusOK,
-					body:   loadFixture("raw_mempool.json"),
+					body:   loadFixture("get_mempool_info_response.json"),
 					url:    url,
 				},
 			},
-			expectedTransactions: []string{
-				"9cec12d170e97e21a876fa2789e6bfc25aa22b8a5e05f3f276650844da0c33ab",
-				"37b4fcc8e0b229412faeab8baad45d3eb8e4eec41840d6ac2103987163459e75",
-				"7bbb29ae32117597fcdf21b464441abd571dad52d053b9c2f7204f8ea8c4762e",
-			},
+			expectedRate: float64(0.00004),
 		},
 		"500 error": {
 			responses: []responseFixture{
@@ -1400,28 +1934,1495 @@ func TestRawMempool(t *testing.T) {
 			}))
 
 			client := NewClient(ts.URL, MainnetGenesisBlockIdentifier, MainnetCurrency)
-			txs, err := client.RawMempool(context.Background())
+			rate, err := client.MempoolMinFeeRate(context.Background())
 			if test.expectedError != nil {
 				assert.Contains(err.Error(), test.expectedError.Error())
 			} else {
 				assert.NoError(err)
-				assert.Equal(test.expectedTransactions, txs)
+				assert.Equal(test.expectedRate, rate)
 			}
 		})
 	}
 }
 
-// loadFixture takes a file name and returns the response fixture.
-func loadFixture(fileName string) string {
-	content, err := ioutil.ReadFile(fmt.Sprintf("client_fixtures/%s", fileName))
-	if err != nil {
-		log.Fatal(err)
-	}
-	return string(content)
-}
+func TestBlockchainInfo(t *testing.T) {
+	tests := map[string]struct {
+		responses []responseFixture
 
-type responseFixture struct {
-	status int
-	body   string
-	url    string
+		expectedInfo  *BlockchainInfo
+		expectedError error
+	}{
+		"successful": {
+			responses: []responseFixture{
+				{
+					status: http.StatusOK,
+					body:   loadFixture("get_blockchain_info_response.json"),
+					url:    url,
+				},
+			},
+			expectedInfo: &BlockchainInfo{
+				Chain:                "main",
+				Blocks:               1000,
+				Headers:              1000,
+				BestBlockHash:        "00000000c937983704a73af28acdec37b049d214adbda81d7e2a3dd146f6ed09",
+				VerificationProgress: 0.9999978065942465,
+			},
+		},
+		"500 error": {
+			responses: []responseFixture{
+				{
+					status: http.StatusInternalServerError,
+					body:   "{}",
+					url:    url,
+				},
+			},
+			expectedError: errors.New("invalid response: 500 Internal Server Error"),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var (
+				assert = assert.New(t)
+			)
+
+			responses := make(chan responseFixture, len(test.responses))
+			for _, response := range test.responses {
+				responses <- response
+			}
+
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				response := <-responses
+				assert.Equal("application/json", r.Header.Get("Content-Type"))
+				assert.Equal("POST", r.Method)
+				assert.Equal(response.url, r.URL.RequestURI())
+
+				w.WriteHeader(response.status)
+				fmt.Fprintln(w, response.body)
+			}))
+
+			client := NewClient(ts.URL, MainnetGenesisBlockIdentifier, MainnetCurrency)
+			info, err := client.BlockchainInfo(context.Background())
+			if test.expectedError != nil {
+				assert.Contains(err.Error(), test.expectedError.Error())
+			} else {
+				assert.NoError(err)
+				assert.Equal(test.expectedInfo, info)
+			}
+		})
+	}
+}
+
+func TestRawMempool(t *testing.T) {
+	tests := map[string]struct {
+		responses []responseFixture
+
+		expectedTransactions []string
+		expectedError        error
+	}{
+		"successful": {
+			responses: []responseFixture{
+				{
+					status: http.StatusOK,
+					body:   loadFixture("raw_mempool.json"),
+					url:    url,
+				},
+			},
+			expectedTransactions: []string{
+				"9cec12d170e97e21a876fa2789e6bfc25aa22b8a5e05f3f276650844da0c33ab",
+				"37b4fcc8e0b229412faeab8baad45d3eb8e4eec41840d6ac2103987163459e75",
+				"7bbb29ae32117597fcdf21b464441abd571dad52d053b9c2f7204f8ea8c4762e",
+			},
+		},
+		"500 error": {
+			responses: []responseFixture{
+				{
+					status: http.StatusInternalServerError,
+					body:   "{}",
+					url:    url,
+				},
+			},
+			expectedError: errors.New("invalid response: 500 Internal Server Error"),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var (
+				assert = assert.New(t)
+			)
+
+			responses := make(chan responseFixture, len(test.responses))
+			for _, response := range test.responses {
+				responses <- response
+			}
+
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				response := <-responses
+				assert.Equal("application/json", r.Header.Get("Content-Type"))
+				assert.Equal("POST", r.Method)
+				assert.Equal(response.url, r.URL.RequestURI())
+
+				w.WriteHeader(response.status)
+				fmt.Fprintln(w, response.body)
+			}))
+
+			client := NewClient(ts.URL, MainnetGenesisBlockIdentifier, MainnetCurrency)
+			txs, err := client.RawMempool(context.Background())
+			if test.expectedError != nil {
+				assert.Contains(err.Error(), test.expectedError.Error())
+			} else {
+				assert.NoError(err)
+				assert.Equal(test.expectedTransactions, txs)
+			}
+		})
+	}
+}
+
+func TestMempoolBalance(t *testing.T) {
+	mempoolResponses := []responseFixture{
+		{
+			status: http.StatusOK,
+			body:   loadFixture("raw_mempool.json"),
+			url:    url,
+		},
+		{
+			status: http.StatusOK,
+			body:   loadFixture("mempool_transactions_batch.json"),
+			url:    url,
+		},
+	}
+
+	tests := map[string]struct {
+		responses []responseFixture
+		account   *types.AccountIdentifier
+		coins     []*types.Coin
+
+		expectedBalance *types.Amount
+		expectedError   error
+	}{
+		"incoming only": {
+			responses: mempoolResponses,
+			account: &types.AccountIdentifier{
+				Address: "mzBc4XEFSdzCDcTxAgf6EZXgsZWpztRhef",
+			},
+			expectedBalance: &types.Amount{
+				Value:    "12000000",
+				Currency: MainnetCurrency,
+			},
+		},
+		"incoming and spent": {
+			responses: mempoolResponses,
+			account: &types.AccountIdentifier{
+				Address: "mmtKKnjqTPdkBnBMbNt5Yu2SCwpMaEshEL",
+			},
+			coins: []*types.Coin{
+				{
+					CoinIdentifier: &types.CoinIdentifier{
+						Identifier: "4852fe372ff7534c16713b3146bbc1e86379c70bea4d5c02fb1fa0112980a081:0",
+					},
+					Amount: &types.Amount{
+						Value:    "3810000",
+						Currency: MainnetCurrency,
+					},
+				},
+			},
+			expectedBalance: &types.Amount{
+				Value:    "-1510000",
+				Currency: MainnetCurrency,
+			},
+		},
+		"no mempool activity": {
+			responses: mempoolResponses,
+			account: &types.AccountIdentifier{
+				Address: "mgnQ3FBNm2Tqf9XzDSHWtrVZDWcNYg4CVe",
+			},
+			expectedBalance: &types.Amount{
+				Value:    "0",
+				Currency: MainnetCurrency,
+			},
+		},
+		"500 error": {
+			responses: []responseFixture{
+				{
+					status: http.StatusInternalServerError,
+					body:   "{}",
+					url:    url,
+				},
+			},
+			account: &types.AccountIdentifier{
+				Address: "mmtKKnjqTPdkBnBMbNt5Yu2SCwpMaEshEL",
+			},
+			expectedError: errors.New("invalid response: 500 Internal Server Error"),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var (
+				assert = assert.New(t)
+			)
+
+			responses := make(chan responseFixture, len(test.responses))
+			for _, response := range test.responses {
+				responses <- response
+			}
+
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				response := <-responses
+				assert.Equal("application/json", r.Header.Get("Content-Type"))
+				assert.Equal("POST", r.Method)
+				assert.Equal(response.url, r.URL.RequestURI())
+
+				w.WriteHeader(response.status)
+				fmt.Fprintln(w, response.body)
+			}))
+
+			client := NewClient(ts.URL, MainnetGenesisBlockIdentifier, MainnetCurrency)
+			balance, err := client.MempoolBalance(context.Background(), test.account, test.coins)
+			if test.expectedError != nil {
+				assert.Contains(err.Error(), test.expectedError.Error())
+			} else {
+				assert.NoError(err)
+				assert.Equal(test.expectedBalance, balance)
+			}
+		})
+	}
+}
+
+func TestMempoolCoins(t *testing.T) {
+	mempoolResponses := []responseFixture{
+		{
+			status: http.StatusOK,
+			body:   loadFixture("raw_mempool.json"),
+			url:    url,
+		},
+		{
+			status: http.StatusOK,
+			body:   loadFixture("mempool_transactions_batch.json"),
+			url:    url,
+		},
+	}
+	confirmedCoin := &types.Coin{
+		CoinIdentifier: &types.CoinIdentifier{
+			Identifier: "4852fe372ff7534c16713b3146bbc1e86379c70bea4d5c02fb1fa0112980a081:0",
+		},
+		Amount: &types.Amount{
+			Value:    "3810000",
+			Currency: MainnetCurrency,
+		},
+	}
+	unspentCoin := &types.Coin{
+		CoinIdentifier: &types.CoinIdentifier{
+			Identifier: "4852fe372ff7534c16713b3146bbc1e86379c70bea4d5c02fb1fa0112980a081:1",
+		},
+		Amount: &types.Amount{
+			Value:    "100000",
+			Currency: MainnetCurrency,
+		},
+	}
+
+	tests := map[string]struct {
+		responses []responseFixture
+		account   *types.AccountIdentifier
+		coins     []*types.Coin
+
+		expectedCoins []*types.Coin
+		expectedError error
+	}{
+		"incoming only": {
+			responses: mempoolResponses,
+			account: &types.AccountIdentifier{
+				Address: "mzBc4XEFSdzCDcTxAgf6EZXgsZWpztRhef",
+			},
+			expectedCoins: []*types.Coin{
+				{
+					CoinIdentifier: &types.CoinIdentifier{
+						Identifier: "9cec12d170e97e21a876fa2789e6bfc25aa22b8a5e05f3f276650844da0c33ab:1",
+					},
+					Amount: &types.Amount{
+						Value:    "10000000",
+						Currency: MainnetCurrency,
+					},
+				},
+				{
+					CoinIdentifier: &types.CoinIdentifier{
+						Identifier: "37b4fcc8e0b229412faeab8baad45d3eb8e4eec41840d6ac2103987163459e75:0",
+					},
+					Amount: &types.Amount{
+						Value:    "2000000",
+						Currency: MainnetCurrency,
+					},
+				},
+			},
+		},
+		"incoming and spent": {
+			responses: mempoolResponses,
+			account: &types.AccountIdentifier{
+				Address: "mmtKKnjqTPdkBnBMbNt5Yu2SCwpMaEshEL",
+			},
+			coins: []*types.Coin{confirmedCoin, unspentCoin},
+			expectedCoins: []*types.Coin{
+				unspentCoin,
+				{
+					CoinIdentifier: &types.CoinIdentifier{
+						Identifier: "9cec12d170e97e21a876fa2789e6bfc25aa22b8a5e05f3f276650844da0c33ab:0",
+					},
+					Amount: &types.Amount{
+						Value:    "500000",
+						Currency: MainnetCurrency,
+					},
+				},
+				{
+					CoinIdentifier: &types.CoinIdentifier{
+						Identifier: "37b4fcc8e0b229412faeab8baad45d3eb8e4eec41840d6ac2103987163459e75:1",
+					},
+					Amount: &types.Amount{
+						Value:    "1800000",
+						Currency: MainnetCurrency,
+					},
+				},
+			},
+		},
+		"no mempool activity": {
+			responses: mempoolResponses,
+			account: &types.AccountIdentifier{
+				Address: "mgnQ3FBNm2Tqf9XzDSHWtrVZDWcNYg4CVe",
+			},
+			coins:         []*types.Coin{unspentCoin},
+			expectedCoins: []*types.Coin{unspentCoin},
+		},
+		"500 error": {
+			responses: []responseFixture{
+				{
+					status: http.StatusInternalServerError,
+					body:   "{}",
+					url:    url,
+				},
+			},
+			account: &types.AccountIdentifier{
+				Address: "mmtKKnjqTPdkBnBMbNt5Yu2SCwpMaEshEL",
+			},
+			expectedError: errors.New("invalid response: 500 Internal Server Error"),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var (
+				assert = assert.New(t)
+			)
+
+			responses := make(chan responseFixture, len(test.responses))
+			for _, response := range test.responses {
+				responses <- response
+			}
+
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				response := <-responses
+				assert.Equal("application/json", r.Header.Get("Content-Type"))
+				assert.Equal("POST", r.Method)
+				assert.Equal(response.url, r.URL.RequestURI())
+
+				w.WriteHeader(response.status)
+				fmt.Fprintln(w, response.body)
+			}))
+
+			client := NewClient(ts.URL, MainnetGenesisBlockIdentifier, MainnetCurrency)
+			coins, err := client.MempoolCoins(context.Background(), test.account, test.coins)
+			if test.expectedError != nil {
+				assert.Contains(err.Error(), test.expectedError.Error())
+			} else {
+				assert.NoError(err)
+				assert.Equal(test.expectedCoins, coins)
+			}
+		})
+	}
+}
+
+func TestMempoolSnapshot(t *testing.T) {
+	// The second query only fetches the transaction missing
+	// from the snapshot (it could not be found by the first
+	// query) and drops the transaction that left the mempool.
+	responses := []responseFixture{
+		{
+			status: http.StatusOK,
+			body:   loadFixture("raw_mempool.json"),
+			url:    url,
+		},
+		{
+			status: http.StatusOK,
+			body:   loadFixture("mempool_transactions_batch.json"),
+			url:    url,
+		},
+		{
+			status: http.StatusOK,
+			body: `{"result": [
+				"37b4fcc8e0b229412faeab8baad45d3eb8e4eec41840d6ac2103987163459e75",
+				"7bbb29ae32117597fcdf21b464441abd571dad52d053b9c2f7204f8ea8c4762e"
+			]}`,
+			url: url,
+		},
+		{
+			status: http.StatusOK,
+			body: `[{"result": null, "error": {"code": -5, "message": "No such mempool ` +
+				`or blockchain transaction."}, "id": 0}]`,
+			url: url,
+		},
+	}
+
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := atomic.AddInt32(&requests, 1) - 1
+		if !assert.Less(t, int(i), len(responses)) {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		body, err := ioutil.ReadAll(r.Body)
+		assert.NoError(t, err)
+		if i == 3 {
+			// Only the new transaction is requested.
+			assert.Contains(t, string(body), "7bbb29ae")
+			assert.NotContains(t, string(body), "37b4fcc8")
+		}
+
+		w.WriteHeader(responses[i].status)
+		fmt.Fprintln(w, responses[i].body)
+	}))
+	defer ts.Close()
+
+	client := NewClient(ts.URL, MainnetGenesisBlockIdentifier, MainnetCurrency)
+	account := &types.AccountIdentifier{
+		Address: "mzBc4XEFSdzCDcTxAgf6EZXgsZWpztRhef",
+	}
+
+	balance, err := client.MempoolBalance(context.Background(), account, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "12000000", balance.Value)
+	assert.Len(t, client.mempool, 2)
+
+	_, err = client.MempoolCoins(context.Background(), account, nil)
+	assert.NoError(t, err)
+	assert.Len(t, client.mempool, 1)
+	assert.Contains(
+		t,
+		client.mempool,
+		"37b4fcc8e0b229412faeab8baad45d3eb8e4eec41840d6ac2103987163459e75",
+	)
+	assert.Equal(t, int32(len(responses)), atomic.LoadInt32(&requests))
+}
+
+func TestGetRawTransactions(t *testing.T) {
+	hashes := []string{
+		"9cec12d170e97e21a876fa2789e6bfc25aa22b8a5e05f3f276650844da0c33ab",
+		"37b4fcc8e0b229412faeab8baad45d3eb8e4eec41840d6ac2103987163459e75",
+		"7bbb29ae32117597fcdf21b464441abd571dad52d053b9c2f7204f8ea8c4762e",
+	}
+
+	tests := map[string]struct {
+		responses []responseFixture
+		hashes    []string
+
+		expectedHashes []string
+		expectedErrors []error
+		expectedError  error
+	}{
+		"batch": {
+			responses: []responseFixture{
+				{
+					status: http.StatusOK,
+					body:   loadFixture("mempool_transactions_batch.json"),
+					url:    url,
+				},
+			},
+			hashes:         hashes,
+			expectedHashes: []string{hashes[0], hashes[1], ""},
+			expectedErrors: []error{nil, nil, ErrTransactionNotFound},
+		},
+		"missing response": {
+			responses: []responseFixture{
+				{
+					status: http.StatusOK,
+					body:   "[]",
+					url:    url,
+				},
+			},
+			hashes:         hashes[:1],
+			expectedHashes: []string{""},
+			expectedErrors: []error{errors.New("no response to getrawtransaction in batch")},
+		},
+		"no hashes": {
+			hashes:         []string{},
+			expectedHashes: []string{},
+			expectedErrors: []error{},
+		},
+		"500 error": {
+			responses: []responseFixture{
+				{
+					status: http.StatusInternalServerError,
+					body:   "{}",
+					url:    url,
+				},
+			},
+			hashes:        hashes,
+			expectedError: errors.New("invalid response: 500 Internal Server Error"),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var (
+				assert = assert.New(t)
+			)
+
+			responses := make(chan responseFixture, len(test.responses))
+			for _, response := range test.responses {
+				responses <- response
+			}
+
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				response := <-responses
+				assert.Equal("application/json", r.Header.Get("Content-Type"))
+				assert.Equal("POST", r.Method)
+				assert.Equal(response.url, r.URL.RequestURI())
+
+				var batch []*request
+				assert.NoError(json.NewDecoder(r.Body).Decode(&batch))
+				assert.Len(batch, len(test.hashes))
+				for i, req := range batch {
+					assert.Equal(i, req.ID)
+					assert.Equal(string(requestMethodGetRawTransaction), req.Method)
+					assert.Equal([]interface{}{test.hashes[i], true}, req.Params)
+				}
+
+				w.WriteHeader(response.status)
+				fmt.Fprintln(w, response.body)
+			}))
+			defer ts.Close()
+
+			client := NewClient(ts.URL, MainnetGenesisBlockIdentifier, MainnetCurrency)
+			txs, errs, err := client.GetRawTransactions(context.Background(), test.hashes)
+			if test.expectedError != nil {
+				assert.Contains(err.Error(), test.expectedError.Error())
+				return
+			}
+
+			assert.NoError(err)
+			assert.Len(txs, len(test.expectedHashes))
+			assert.Len(errs, len(test.expectedErrors))
+			for i, expectedHash := range test.expectedHashes {
+				if test.expectedErrors[i] != nil {
+					assert.Nil(txs[i])
+					assert.Contains(errs[i].Error(), test.expectedErrors[i].Error())
+					assert.Contains(errs[i].Error(), test.hashes[i])
+					continue
+				}
+
+				assert.NoError(errs[i])
+				assert.Equal(expectedHash, txs[i].Hash)
+			}
+		})
+	}
+}
+
+func TestMempoolTransaction(t *testing.T) {
+	tests := map[string]struct {
+		responses []responseFixture
+		hash      string
+
+		expectedOps        int
+		expectedSignalsRBF bool
+		expectedError      error
+	}{
+		"final": {
+			responses: []responseFixture{
+				{
+					status: http.StatusOK,
+					body:   loadFixture("mempool_transaction_1.json"),
+					url:    url,
+				},
+			},
+			hash:        "9cec12d170e97e21a876fa2789e6bfc25aa22b8a5e05f3f276650844da0c33ab",
+			expectedOps: 3,
+		},
+		"signals rbf": {
+			responses: []responseFixture{
+				{
+					status: http.StatusOK,
+					body:   loadFixture("mempool_transaction_rbf.json"),
+					url:    url,
+				},
+			},
+			hash:               "37b4fcc8e0b229412faeab8baad45d3eb8e4eec41840d6ac2103987163459e75",
+			expectedOps:        3,
+			expectedSignalsRBF: true,
+		},
+		"not found": {
+			responses: []responseFixture{
+				{
+					status: http.StatusOK,
+					body:   loadFixture("get_raw_transaction_not_found_response.json"),
+					url:    url,
+				},
+			},
+			hash:          "37b4fcc8e0b229412faeab8baad45d3eb8e4eec41840d6ac2103987163459e75",
+			expectedError: ErrTransactionNotFound,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var (
+				assert = assert.New(t)
+			)
+
+			responses := make(chan responseFixture, len(test.responses))
+			for _, response := range test.responses {
+				responses <- response
+			}
+
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				response := <-responses
+				assert.Equal("application/json", r.Header.Get("Content-Type"))
+				assert.Equal("POST", r.Method)
+				assert.Equal(response.url, r.URL.RequestURI())
+
+				w.WriteHeader(response.status)
+				fmt.Fprintln(w, response.body)
+			}))
+
+			client := NewClient(ts.URL, MainnetGenesisBlockIdentifier, MainnetCurrency)
+			tx, err := client.MempoolTransaction(context.Background(), test.hash)
+			if test.expectedError != nil {
+				assert.True(errors.Is(err, test.expectedError))
+				return
+			}
+
+			assert.NoError(err)
+			assert.Equal(test.hash, tx.TransactionIdentifier.Hash)
+			assert.Len(tx.Operations, test.expectedOps)
+			assert.Equal(InputOpType, tx.Operations[0].Type)
+			assert.Nil(tx.Operations[0].Account)
+
+			var metadata TransactionMetadata
+			assert.NoError(types.UnmarshalMap(tx.Metadata, &metadata))
+			assert.Equal(test.expectedSignalsRBF, metadata.SignalsRBF)
+			assert.Equal(1, metadata.InputCount)
+			assert.Equal(2, metadata.OutputCount)
+		})
+	}
+}
+
+func TestTransactionMetadata_SignalsRBF(t *testing.T) {
+	transaction := Transaction{
+		Hash:    "37b4fcc8e0b229412faeab8baad45d3eb8e4eec41840d6ac2103987163459e75",
+		Version: 2,
+		Inputs: []*Input{
+			{
+				TxHash:   "4852fe372ff7534c16713b3146bbc1e86379c70bea4d5c02fb1fa0112980a081",
+				Sequence: 0,
+			},
+		},
+	}
+	assert.True(t, transaction.SignalsRBF())
+
+	// Replaceability is irrelevant once a
+	// transaction is confirmed.
+	metadata, err := transaction.Metadata(nil)
+	assert.NoError(t, err)
+	assert.NotContains(t, metadata, "signals_rbf")
+
+	metadata, err = transaction.MempoolMetadata(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, true, metadata["signals_rbf"])
+}
+
+func TestSendRawTransaction(t *testing.T) {
+	tests := map[string]struct {
+		responses []responseFixture
+
+		expectedHash  string
+		expectedError error
+	}{
+		"successful": {
+			responses: []responseFixture{
+				{
+					status: http.StatusOK,
+					body:   loadFixture("send_raw_transaction_response.json"),
+					url:    url,
+				},
+			},
+			expectedHash: "6d87ad0e26025128f5a8357fa423b340cbcffb9703f79f432f5520fca59cd20b",
+		},
+		"rejected": {
+			responses: []responseFixture{
+				{
+					status: http.StatusOK,
+					body:   loadFixture("send_raw_transaction_rejected_response.json"),
+					url:    url,
+				},
+			},
+			expectedError: ErrTransactionRejected,
+		},
+		"mempool chain too long": {
+			responses: []responseFixture{
+				{
+					status: http.StatusOK,
+					body:   loadFixture("send_raw_transaction_chain_too_long_response.json"),
+					url:    url,
+				},
+			},
+			expectedError: ErrMempoolChainTooLong,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var (
+				assert = assert.New(t)
+			)
+
+			responses := make(chan responseFixture, len(test.responses))
+			for _, response := range test.responses {
+				responses <- response
+			}
+
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				response := <-responses
+				assert.Equal("application/json", r.Header.Get("Content-Type"))
+				assert.Equal("POST", r.Method)
+				assert.Equal(response.url, r.URL.RequestURI())
+
+				w.WriteHeader(response.status)
+				fmt.Fprintln(w, response.body)
+			}))
+
+			client := NewClient(ts.URL, MainnetGenesisBlockIdentifier, MainnetCurrency)
+			hash, err := client.SendRawTransaction(context.Background(), "deadbeef")
+			if test.expectedError != nil {
+				assert.True(errors.Is(err, test.expectedError))
+			} else {
+				assert.NoError(err)
+				assert.Equal(test.expectedHash, hash)
+			}
+		})
+	}
+}
+
+func TestValidateNetwork(t *testing.T) {
+	tests := map[string]struct {
+		params    *chaincfg.Params
+		responses []responseFixture
+
+		expectedError error
+	}{
+		"matching network": {
+			params: MainnetParams,
+			responses: []responseFixture{
+				{
+					status: http.StatusOK,
+					body:   loadFixture("get_blockchain_info_response.json"),
+					url:    url,
+				},
+			},
+		},
+		"mismatched network magic": {
+			params: TestnetParams,
+			responses: []responseFixture{
+				{
+					status: http.StatusOK,
+					body:   loadFixture("get_blockchain_info_response.json"),
+					url:    url,
+				},
+			},
+			expectedError: ErrNetworkMismatch,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var (
+				assert = assert.New(t)
+			)
+
+			responses := make(chan responseFixture, len(test.responses))
+			for _, response := range test.responses {
+				responses <- response
+			}
+
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				response := <-responses
+				assert.Equal("application/json", r.Header.Get("Content-Type"))
+				assert.Equal("POST", r.Method)
+				assert.Equal(response.url, r.URL.RequestURI())
+
+				w.WriteHeader(response.status)
+				fmt.Fprintln(w, response.body)
+			}))
+
+			client := NewClient(ts.URL, MainnetGenesisBlockIdentifier, MainnetCurrency)
+			err := client.ValidateNetwork(context.Background(), test.params)
+			if test.expectedError != nil {
+				assert.True(errors.Is(err, test.expectedError))
+			} else {
+				assert.NoError(err)
+			}
+		})
+	}
+}
+
+func TestTransactionBlock(t *testing.T) {
+	tests := map[string]struct {
+		hash      string
+		responses []responseFixture
+
+		expectedBlock *types.BlockIdentifier
+		expectedError error
+	}{
+		"confirmed": {
+			hash: "fe28050b93faea61fa88c4c630f0e1f0a1c24d0082dd0e10d369e13212128f33",
+			responses: []responseFixture{
+				{
+					status: http.StatusOK,
+					body:   loadFixture("get_raw_transaction_response.json"),
+					url:    url,
+				},
+				{
+					status: http.StatusOK,
+					body:   loadFixture("get_block_header_response.json"),
+					url:    url,
+				},
+			},
+			expectedBlock: &types.BlockIdentifier{
+				Hash:  "00000000c937983704a73af28acdec37b049d214adbda81d7e2a3dd146f6ed09",
+				Index: 1000,
+			},
+		},
+		"unconfirmed": {
+			hash: "9cec12d170e97e21a876fa2789e6bfc25aa22b8a5e05f3f276650844da0c33ab",
+			responses: []responseFixture{
+				{
+					status: http.StatusOK,
+					body:   loadFixture("get_raw_transaction_mempool_response.json"),
+					url:    url,
+				},
+			},
+		},
+		"not found": {
+			hash: "fe28050b93faea61fa88c4c630f0e1f0a1c24d0082dd0e10d369e13212128f33",
+			responses: []responseFixture{
+				{
+					status: http.StatusOK,
+					body:   loadFixture("get_raw_transaction_not_found_response.json"),
+					url:    url,
+				},
+			},
+			expectedError: ErrTransactionNotFound,
+		},
+		"txindex disabled": {
+			hash: "fe28050b93faea61fa88c4c630f0e1f0a1c24d0082dd0e10d369e13212128f33",
+			responses: []responseFixture{
+				{
+					status: http.StatusOK,
+					body:   loadFixture("get_raw_transaction_txindex_disabled_response.json"),
+					url:    url,
+				},
+			},
+			expectedError: ErrTxIndexDisabled,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var (
+				assert = assert.New(t)
+			)
+
+			responses := make(chan responseFixture, len(test.responses))
+			for _, response := range test.responses {
+				responses <- response
+			}
+
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				response := <-responses
+				assert.Equal("application/json", r.Header.Get("Content-Type"))
+				assert.Equal("POST", r.Method)
+				assert.Equal(response.url, r.URL.RequestURI())
+
+				w.WriteHeader(response.status)
+				fmt.Fprintln(w, response.body)
+			}))
+
+			client := NewClient(ts.URL, MainnetGenesisBlockIdentifier, MainnetCurrency)
+			block, err := client.TransactionBlock(context.Background(), test.hash)
+			if test.expectedError != nil {
+				assert.True(errors.Is(err, test.expectedError))
+			} else {
+				assert.NoError(err)
+				assert.Equal(test.expectedBlock, block)
+			}
+		})
+	}
+}
+
+func TestTxIndexEnabled(t *testing.T) {
+	tests := map[string]struct {
+		responses []responseFixture
+
+		expectedEnabled bool
+		expectedError   error
+	}{
+		"enabled": {
+			responses: []responseFixture{
+				{
+					status: http.StatusOK,
+					body:   loadFixture("get_raw_transaction_not_found_response.json"),
+					url:    url,
+				},
+			},
+			expectedEnabled: true,
+		},
+		"disabled": {
+			responses: []responseFixture{
+				{
+					status: http.StatusOK,
+					body:   loadFixture("get_raw_transaction_txindex_disabled_response.json"),
+					url:    url,
+				},
+			},
+			expectedEnabled: false,
+		},
+		"error": {
+			responses: []responseFixture{
+				{
+					status: http.StatusInternalServerError,
+					body:   "{}",
+					url:    url,
+				},
+			},
+			expectedError: errors.New("invalid response: 500 Internal Server Error"),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var (
+				assert = assert.New(t)
+			)
+
+			responses := make(chan responseFixture, len(test.responses))
+			for _, response := range test.responses {
+				responses <- response
+			}
+
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				response := <-responses
+				assert.Equal("application/json", r.Header.Get("Content-Type"))
+				assert.Equal("POST", r.Method)
+				assert.Equal(response.url, r.URL.RequestURI())
+
+				w.WriteHeader(response.status)
+				fmt.Fprintln(w, response.body)
+			}))
+
+			client := NewClient(ts.URL, MainnetGenesisBlockIdentifier, MainnetCurrency)
+			enabled, err := client.TxIndexEnabled(context.Background())
+			if test.expectedError != nil {
+				assert.Contains(err.Error(), test.expectedError.Error())
+			} else {
+				assert.NoError(err)
+				assert.Equal(test.expectedEnabled, enabled)
+			}
+		})
+	}
+}
+
+func TestBlockHeader(t *testing.T) {
+	tests := map[string]struct {
+		index     int64
+		responses []responseFixture
+
+		expectedHeader *BlockHeader
+		expectedError  error
+	}{
+		"successful": {
+			index: 1000,
+			responses: []responseFixture{
+				{
+					status: http.StatusOK,
+					body:   loadFixture("get_block_hash_response.json"),
+					url:    url,
+				},
+				{
+					status: http.StatusOK,
+					body:   loadFixture("get_block_header_response.json"),
+					url:    url,
+				},
+			},
+			expectedHeader: &BlockHeader{
+				Hash:       "00000000c937983704a73af28acdec37b049d214adbda81d7e2a3dd146f6ed09",
+				Height:     1000,
+				Time:       1232346882,
+				Bits:       "1d00ffff",
+				Difficulty: 1,
+			},
+		},
+		"out of range": {
+			index: 1000000,
+			responses: []responseFixture{
+				{
+					status: http.StatusOK,
+					body:   loadFixture("get_block_hash_out_of_range_response.json"),
+					url:    url,
+				},
+			},
+			expectedError: ErrJSONRPCError,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var (
+				assert = assert.New(t)
+			)
+
+			responses := make(chan responseFixture, len(test.responses))
+			for _, response := range test.responses {
+				responses <- response
+			}
+
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				response := <-responses
+				assert.Equal("application/json", r.Header.Get("Content-Type"))
+				assert.Equal("POST", r.Method)
+				assert.Equal(response.url, r.URL.RequestURI())
+
+				w.WriteHeader(response.status)
+				fmt.Fprintln(w, response.body)
+			}))
+
+			client := NewClient(ts.URL, MainnetGenesisBlockIdentifier, MainnetCurrency)
+			header, err := client.BlockHeader(context.Background(), test.index)
+			if test.expectedError != nil {
+				assert.True(errors.Is(err, test.expectedError))
+			} else {
+				assert.NoError(err)
+				assert.Equal(test.expectedHeader, header)
+			}
+		})
+	}
+}
+
+// loadFixture takes a file name and returns the response fixture.
+func loadFixture(fileName string) string {
+	content, err := ioutil.ReadFile(fmt.Sprintf("client_fixtures/%s", fileName))
+	if err != nil {
+		log.Fatal(err)
+	}
+	return string(content)
+}
+
+type responseFixture struct {
+	status int
+	body   string
+	url    string
+}
+
+func TestWorkQueueFull(t *testing.T) {
+	workQueueFull := responseFixture{
+		status: http.StatusServiceUnavailable,
+		body:   "Work queue depth exceeded",
+		url:    url,
+	}
+	success := responseFixture{
+		status: http.StatusOK,
+		body:   loadFixture("get_block_hash_response.json"),
+		url:    url,
+	}
+
+	tests := map[string]struct {
+		responses []responseFixture
+
+		expectedHash  string
+		expectedError error
+	}{
+		"retry succeeds": {
+			responses:    []responseFixture{workQueueFull, workQueueFull, success},
+			expectedHash: "00000000c937983704a73af28acdec37b049d214adbda81d7e2a3dd146f6ed09",
+		},
+		"retries exhausted": {
+			responses:     []responseFixture{workQueueFull, workQueueFull, workQueueFull},
+			expectedError: ErrWorkQueueFull,
+		},
+		"other 503": {
+			responses: []responseFixture{
+				{
+					status: http.StatusServiceUnavailable,
+					body:   "{}",
+					url:    url,
+				},
+			},
+			expectedError: errors.New("invalid response: 503 Service Unavailable"),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var (
+				assert = assert.New(t)
+			)
+
+			responses := make(chan responseFixture, len(test.responses))
+			for _, response := range test.responses {
+				responses <- response
+			}
+
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				response := <-responses
+				assert.Equal("application/json", r.Header.Get("Content-Type"))
+				assert.Equal("POST", r.Method)
+				assert.Equal(response.url, r.URL.RequestURI())
+
+				w.WriteHeader(response.status)
+				fmt.Fprintln(w, response.body)
+			}))
+
+			client := NewClient(
+				ts.URL,
+				MainnetGenesisBlockIdentifier,
+				MainnetCurrency,
+				WithMaxConcurrentRequests(1),
+			)
+			client.maxRetries = 2
+			client.retryDelay = time.Millisecond
+
+			hash, err := client.getHashFromIndex(context.Background(), 1000)
+			if test.expectedError != nil {
+				assert.Contains(err.Error(), test.expectedError.Error())
+			} else {
+				assert.NoError(err)
+				assert.Equal(test.expectedHash, hash)
+			}
+
+			// All responses are consumed
+			assert.Len(responses, 0)
+		})
+	}
+}
+
+func TestConnectionRefused(t *testing.T) {
+	// Reserve an address that refuses connections
+	// until whived "starts".
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	address := listener.Addr().String()
+	assert.NoError(t, listener.Close())
+
+	t.Run("retries exhausted", func(t *testing.T) {
+		client := NewClient(
+			"http://"+address,
+			MainnetGenesisBlockIdentifier,
+			MainnetCurrency,
+			WithRetries(2, time.Millisecond),
+		)
+
+		_, err := client.getHashFromIndex(context.Background(), 1000)
+		assert.True(t, errors.Is(err, syscall.ECONNREFUSED))
+	})
+
+	t.Run("context canceled", func(t *testing.T) {
+		client := NewClient(
+			"http://"+address,
+			MainnetGenesisBlockIdentifier,
+			MainnetCurrency,
+			WithRetries(100, time.Hour),
+		)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		_, err := client.getHashFromIndex(ctx, 1000)
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	})
+
+	t.Run("retry succeeds", func(t *testing.T) {
+		client := NewClient(
+			"http://"+address,
+			MainnetGenesisBlockIdentifier,
+			MainnetCurrency,
+			WithRetries(10, 10*time.Millisecond),
+		)
+
+		ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprintln(w, loadFixture("get_block_hash_response.json"))
+		}))
+		defer ts.Close()
+
+		started := make(chan struct{})
+		go func() {
+			defer close(started)
+			time.Sleep(20 * time.Millisecond)
+
+			listener, err := net.Listen("tcp", address)
+			if !assert.NoError(t, err) {
+				return
+			}
+			ts.Listener.Close()
+			ts.Listener = listener
+			ts.Start()
+		}()
+
+		hash, err := client.getHashFromIndex(context.Background(), 1000)
+		<-started
+		assert.NoError(t, err)
+		assert.Equal(t, "00000000c937983704a73af28acdec37b049d214adbda81d7e2a3dd146f6ed09", hash)
+	})
+}
+
+func TestConnectionReset(t *testing.T) {
+	// whived resets every connection after reading the
+	// request (e.g. because it crashed while handling it).
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		_, _ = ioutil.ReadAll(r.Body)
+
+		conn, _, err := w.(http.Hijacker).Hijack()
+		assert.NoError(t, err)
+		assert.NoError(t, conn.(*net.TCPConn).SetLinger(0))
+		assert.NoError(t, conn.Close())
+	}))
+	defer ts.Close()
+
+	client := NewClient(
+		ts.URL,
+		MainnetGenesisBlockIdentifier,
+		MainnetCurrency,
+		WithRetries(2, time.Millisecond),
+	)
+
+	t.Run("idempotent", func(t *testing.T) {
+		atomic.StoreInt32(&requests, 0)
+		_, err := client.getHashFromIndex(context.Background(), 1000)
+		assert.True(t, errors.Is(err, syscall.ECONNRESET))
+		assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+	})
+
+	t.Run("not idempotent", func(t *testing.T) {
+		// whived may have accepted the transaction before
+		// resetting the connection, so we don't resubmit it.
+		atomic.StoreInt32(&requests, 0)
+		_, err := client.SendRawTransaction(context.Background(), "00")
+		assert.True(t, errors.Is(err, syscall.ECONNRESET))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+	})
+}
+
+func TestUnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "whived-socket")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// whived creates its socket once it "starts"
+	socketPath := filepath.Join(dir, "rpc.sock")
+	client := NewClient(
+		UnixSocketURL(socketPath),
+		MainnetGenesisBlockIdentifier,
+		MainnetCurrency,
+		WithRetries(10, 10*time.Millisecond),
+	)
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, url, r.URL.RequestURI())
+
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintln(w, loadFixture("get_block_hash_response.json"))
+	}))
+	defer ts.Close()
+
+	started := make(chan struct{})
+	go func() {
+		defer close(started)
+		time.Sleep(20 * time.Millisecond)
+
+		listener, err := net.Listen("unix", socketPath)
+		if !assert.NoError(t, err) {
+			return
+		}
+		ts.Listener.Close()
+		ts.Listener = listener
+		ts.Start()
+	}()
+
+	hash, err := client.getHashFromIndex(context.Background(), 1000)
+	<-started
+	assert.NoError(t, err)
+	assert.Equal(t, "00000000c937983704a73af28acdec37b049d214adbda81d7e2a3dd146f6ed09", hash)
+}
+
+func TestMaxResponseBytes(t *testing.T) {
+	blockHash := loadFixture("get_block_hash_response.json")
+
+	tests := map[string]struct {
+		body             string
+		maxResponseBytes int64
+
+		expectedHash  string
+		expectedError error
+	}{
+		"within limit": {
+			body:             blockHash,
+			maxResponseBytes: int64(len(blockHash) + 1),
+			expectedHash:     "00000000c937983704a73af28acdec37b049d214adbda81d7e2a3dd146f6ed09",
+		},
+		"exceeds limit": {
+			body:             blockHash,
+			maxResponseBytes: 16,
+			expectedError:    ErrResponseTooLarge,
+		},
+		"oversized response": {
+			body:             strings.Repeat(" ", 1024*1024) + blockHash,
+			maxResponseBytes: 64 * 1024,
+			expectedError:    ErrResponseTooLarge,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var (
+				assert = assert.New(t)
+			)
+
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				fmt.Fprintln(w, test.body)
+			}))
+			defer ts.Close()
+
+			client := NewClient(
+				ts.URL,
+				MainnetGenesisBlockIdentifier,
+				MainnetCurrency,
+				WithMaxResponseBytes(test.maxResponseBytes),
+			)
+
+			hash, err := client.getHashFromIndex(context.Background(), 1000)
+			if test.expectedError != nil {
+				assert.True(errors.Is(err, test.expectedError))
+			} else {
+				assert.NoError(err)
+				assert.Equal(test.expectedHash, hash)
+			}
+		})
+	}
+}
+
+func TestRPCLatency(t *testing.T) {
+	blockHash := loadFixture("get_block_hash_response.json")
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintln(w, blockHash)
+	}))
+	defer ts.Close()
+
+	// We use a method no other test calls so
+	// that the quantiles only include these calls.
+	method := requestMethod("testrpclatency")
+	client := NewClient(ts.URL, MainnetGenesisBlockIdentifier, MainnetCurrency)
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, client.post(context.Background(), method, nil, &blockHashResponse{}))
+	}
+
+	latencies, err := metrics.RPCLatencies()
+	assert.NoError(t, err)
+	summary := latencies[string(method)]
+	assert.Equal(t, uint64(3), summary.Count)
+	assert.GreaterOrEqual(t, summary.P50, 0.02)
+	assert.GreaterOrEqual(t, summary.P95, summary.P50)
+	assert.GreaterOrEqual(t, summary.P99, summary.P95)
+}
+
+func TestDegraded(t *testing.T) {
+	blockHash := loadFixture("get_block_hash_response.json")
+	rpcError := `{"result":null,"error":{"code":-8,"message":"Block height out of range"},"id":1}`
+
+	var response atomic.Value
+	response.Store(blockHash)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := response.Load().(string)
+		if len(body) == 0 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintln(w, body)
+	}))
+	defer ts.Close()
+
+	client := NewClient(
+		ts.URL,
+		MainnetGenesisBlockIdentifier,
+		MainnetCurrency,
+		WithRetries(0, time.Millisecond),
+		WithDegradeThreshold(3),
+	)
+	ctx := context.Background()
+
+	_, err := client.getHashFromIndex(ctx, 1000)
+	assert.NoError(t, err)
+	assert.False(t, client.Degraded())
+
+	// JSON-RPC errors are returned by a reachable whived
+	response.Store(rpcError)
+	for i := 0; i < 5; i++ {
+		_, err = client.getHashFromIndex(ctx, 1000)
+		assert.Error(t, err)
+	}
+	assert.False(t, client.Degraded())
+
+	// whived is unreachable
+	response.Store("")
+	for i := 0; i < 2; i++ {
+		_, err = client.getHashFromIndex(ctx, 1000)
+		assert.Error(t, err)
+		assert.False(t, client.Degraded())
+	}
+
+	// Canceled requests are not counted
+	canceledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = client.getHashFromIndex(canceledCtx, 1000)
+	assert.Error(t, err)
+	assert.False(t, client.Degraded())
+
+	_, err = client.getHashFromIndex(ctx, 1000)
+	assert.Error(t, err)
+	assert.True(t, client.Degraded())
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.WhivedDegraded))
+
+	// Requests continue to fail while whived is unreachable
+	_, err = client.getHashFromIndex(ctx, 1000)
+	assert.Error(t, err)
+	assert.True(t, client.Degraded())
+
+	// whived recovers
+	response.Store(blockHash)
+	_, err = client.getHashFromIndex(ctx, 1000)
+	assert.NoError(t, err)
+	assert.False(t, client.Degraded())
+	assert.Equal(t, float64(0), testutil.ToFloat64(metrics.WhivedDegraded))
+
+	// Clients without a threshold are never degraded
+	response.Store("")
+	client = NewClient(ts.URL, MainnetGenesisBlockIdentifier, MainnetCurrency, WithRetries(0, 0))
+	for i := 0; i < 5; i++ {
+		_, err = client.getHashFromIndex(ctx, 1000)
+		assert.Error(t, err)
+	}
+	assert.False(t, client.Degraded())
 }
@@ -0,0 +1,83 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package whive
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// credentials are used to authenticate
+// JSON-RPC requests to whived.
+type credentials struct {
+	user     string
+	password string
+}
+
+// readCookie returns the credentials in the cookie file
+// at path, which whived writes as "user:password".
+func readCookie(path string) (*credentials, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := strings.SplitN(strings.TrimSpace(string(contents)), ":", 2)
+	if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+		return nil, fmt.Errorf("cookie file %s is malformed", path)
+	}
+
+	return &credentials{user: parts[0], password: parts[1]}, nil
+}
+
+// credentials returns the credentials used to authenticate
+// requests. If a cookie file is configured, the cookie is
+// read the first time it is needed and cached.
+func (b *Client) credentials() (*credentials, error) {
+	if len(b.cookiePath) == 0 {
+		return &credentials{user: rpcUsername, password: rpcPassword}, nil
+	}
+
+	b.cookieMutex.Lock()
+	defer b.cookieMutex.Unlock()
+
+	if b.cookie != nil {
+		return b.cookie, nil
+	}
+
+	cookie, err := readCookie(b.cookiePath)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoCredentials, err.Error())
+	}
+	b.cookie = cookie
+
+	return cookie, nil
+}
+
+// resetCookie forgets the cached cookie so that it is
+// re-read before the next request. It returns false
+// if a cookie file is not configured.
+func (b *Client) resetCookie() bool {
+	if len(b.cookiePath) == 0 {
+		return false
+	}
+
+	b.cookieMutex.Lock()
+	b.cookie = nil
+	b.cookieMutex.Unlock()
+
+	return true
+}
@@ -0,0 +1,122 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package whive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCookieAuthentication(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cookie")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cookiePath := path.Join(dir, ".cookie")
+	writeCookie := func(password string) {
+		contents := fmt.Sprintf("__cookie__:%s\n", password)
+		assert.NoError(t, ioutil.WriteFile(cookiePath, []byte(contents), 0600))
+	}
+
+	var mutex sync.Mutex
+	expectedUser := rpcUsername
+	expectedPassword := rpcPassword
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		requests++
+		user, password, ok := r.BasicAuth()
+		if !ok || user != expectedUser || password != expectedPassword {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		fmt.Fprintln(w, loadFixture("raw_mempool.json"))
+	}))
+	defer ts.Close()
+
+	expect := func(user string, password string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		expectedUser = user
+		expectedPassword = password
+		requests = 0
+	}
+
+	requestCount := func() int {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		return requests
+	}
+
+	ctx := context.Background()
+
+	// Static credentials are used by default
+	client := NewClient(ts.URL, MainnetGenesisBlockIdentifier, MainnetCurrency)
+	_, err = client.RawMempool(ctx)
+	assert.NoError(t, err)
+
+	// A missing cookie file is reported without
+	// making a request
+	client = NewClient(
+		ts.URL,
+		MainnetGenesisBlockIdentifier,
+		MainnetCurrency,
+		WithCookiePath(cookiePath),
+	)
+	expect("__cookie__", "first")
+	_, err = client.RawMempool(ctx)
+	assert.True(t, errors.Is(err, ErrNoCredentials))
+	assert.Equal(t, 0, requestCount())
+
+	// The cookie is read once it exists
+	writeCookie("first")
+	_, err = client.RawMempool(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, requestCount())
+
+	// When whived restarts with a new cookie, the
+	// rejected cookie is re-read
+	writeCookie("second")
+	expect("__cookie__", "second")
+	_, err = client.RawMempool(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, requestCount())
+
+	// The cookie is only re-read once per request
+	expect("__cookie__", "third")
+	_, err = client.RawMempool(ctx)
+	assert.True(t, errors.Is(err, ErrUnauthorized))
+	assert.Equal(t, 2, requestCount())
+
+	// A malformed cookie is rejected
+	assert.NoError(t, ioutil.WriteFile(cookiePath, []byte("malformed"), 0600))
+	_, err = client.RawMempool(ctx)
+	assert.True(t, errors.Is(err, ErrNoCredentials))
+}
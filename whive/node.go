@@ -61,13 +61,22 @@ func logPipe(ctx context.Context, pipe io.ReadCloser, identifier string) error {
 	}
 }
 
-// StartBitcoind starts a bitcoind daemon in another goroutine
-// and logs the results to the console.
-func StartBitcoind(ctx context.Context, configPath string, g *errgroup.Group) error {
+// StartBitcoind starts a bitcoind daemon (the executable
+// at binaryPath) in another goroutine and logs the results
+// to the console. dataPath overrides the datadir in the
+// configuration file at configPath.
+func StartBitcoind(
+	ctx context.Context,
+	binaryPath string,
+	configPath string,
+	dataPath string,
+	g *errgroup.Group,
+) error {
 	logger := utils.ExtractLogger(ctx, "whived")
 	cmd := exec.Command(
-		"/app/whived",
+		binaryPath,
 		fmt.Sprintf("--conf=%s", configPath),
+		fmt.Sprintf("--datadir=%s", dataPath),
 	) // #nosec G204
 
 	stdout, err := cmd.StdoutPipe()
@@ -91,6 +100,7 @@ func StartBitcoind(ctx context.Context, configPath string, g *errgroup.Group) er
 	if err := cmd.Start(); err != nil {
 		return fmt.Errorf("%w: unable to start bitcoind", err)
 	}
+	logger.Infow("started whived", "binary", binaryPath, "pid", cmd.Process.Pid)
 
 	g.Go(func() error {
 		<-ctx.Done()
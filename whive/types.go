@@ -19,6 +19,7 @@ import (
 	"strings"
 
 	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
 	"github.com/coinbase/rosetta-sdk-go/types"
 )
 
@@ -34,6 +35,10 @@ const (
 	// in TestnetNetworkIdentifier.
 	TestnetNetwork string = "Testnet3"
 
+	// RegtestNetwork is the value of the network
+	// in RegtestNetworkIdentifier.
+	RegtestNetwork string = "Regtest"
+
 	// Decimals is the decimals value
 	// used in Currency.
 	Decimals = 8
@@ -73,6 +78,57 @@ const (
 	// as the ScriptPubKey.Type for OP_RETURN
 	// locking scripts.
 	NullData = "nulldata"
+
+	// WitnessV0ScriptHash is returned by bitcoind
+	// as the ScriptPubKey.Type for P2WSH locking
+	// scripts.
+	WitnessV0ScriptHash = "witness_v0_scripthash"
+
+	// PubKeyHash is returned by bitcoind
+	// as the ScriptPubKey.Type for P2PKH locking
+	// scripts.
+	PubKeyHash = "pubkeyhash"
+
+	// ScriptHash is returned by bitcoind
+	// as the ScriptPubKey.Type for P2SH locking
+	// scripts.
+	ScriptHash = "scripthash"
+
+	// WitnessV0PubKeyHash is returned by bitcoind
+	// as the ScriptPubKey.Type for P2WPKH locking
+	// scripts.
+	WitnessV0PubKeyHash = "witness_v0_keyhash"
+
+	// WitnessV1Taproot is returned by bitcoind
+	// as the ScriptPubKey.Type for P2TR locking
+	// scripts.
+	WitnessV1Taproot = "witness_v1_taproot"
+
+	// PubKey is returned by bitcoind
+	// as the ScriptPubKey.Type for P2PK locking
+	// scripts.
+	PubKey = "pubkey"
+
+	// MultiSig is returned by bitcoind
+	// as the ScriptPubKey.Type for bare multisig
+	// locking scripts.
+	MultiSig = "multisig"
+)
+
+// Script types are included in the metadata of OUTPUT
+// operations (if enabled) to classify their locking
+// scripts. Unlike the ScriptPubKey.Type returned by
+// bitcoind, these don't change between versions.
+const (
+	P2PKHScriptType       = "p2pkh"
+	P2SHScriptType        = "p2sh"
+	P2WPKHScriptType      = "p2wpkh"
+	P2WSHScriptType       = "p2wsh"
+	P2TRScriptType        = "p2tr"
+	P2PKScriptType        = "p2pk"
+	MultiSigScriptType    = "multisig"
+	OpReturnScriptType    = "op_return"
+	NonStandardScriptType = "nonstandard"
 )
 
 // Fee estimate constants
@@ -85,6 +141,21 @@ const (
 	P2PKHScriptPubkeySize = 25               // P2PKH size
 )
 
+// Standardness constants
+// Source: https://github.com/bitcoin/bitcoin/blob/v0.20.1/src/policy/policy.h#L24
+const (
+	MaxStandardTxWeight = 400000 // nolint:gomnd
+	WitnessScaleFactor  = 4      // nolint:gomnd
+)
+
+// Dust constants are the estimated size of an input
+// spending an output (with and without a witness).
+// Source: https://github.com/bitcoin/bitcoin/blob/v0.20.1/src/policy/policy.cpp#L14
+const (
+	dustInputSize        = 32 + 4 + 1 + 107 + 4                        // nolint:gomnd
+	dustWitnessInputSize = 32 + 4 + 1 + (107 / WitnessScaleFactor) + 4 // nolint:gomnd
+)
+
 var (
 	// MainnetGenesisBlockIdentifier is the genesis block for mainnet.
 	MainnetGenesisBlockIdentifier = &types.BlockIdentifier{
@@ -114,6 +185,28 @@ var (
 		Decimals: Decimals,
 	}
 
+	// RegtestGenesisBlockIdentifier is the genesis block for regtest.
+	RegtestGenesisBlockIdentifier = &types.BlockIdentifier{
+		Hash: "0f9188f13cb7b2c71f2a335e3a4fc328bf5beb436012afca590b1a11466e2206",
+	}
+
+	// RegtestParams are the params for regtest.
+	RegtestParams = &chaincfg.RegressionNetParams
+
+	// RegtestCurrency is the *types.Currency for regtest.
+	RegtestCurrency = &types.Currency{
+		Symbol:   "rWHIVE",
+		Decimals: Decimals,
+	}
+
+	// chainNames maps network magic to the chain
+	// reported by `getblockchaininfo`.
+	chainNames = map[wire.BitcoinNet]string{
+		wire.MainNet:  "main",
+		wire.TestNet3: "test",
+		wire.TestNet:  "regtest",
+	}
+
 	// OperationTypes are all supported operation.Types.
 	OperationTypes = []string{
 		InputOpType,
@@ -159,7 +252,62 @@ type ScriptSig struct {
 type BlockchainInfo struct {
 	Chain         string `json:"chain"`
 	Blocks        int64  `json:"blocks"`
+	Headers       int64  `json:"headers"`
 	BestBlockHash string `json:"bestblockhash"`
+
+	// VerificationProgress is whived's estimate
+	// (between 0 and 1) of how much of the chain
+	// it has verified.
+	VerificationProgress float64 `json:"verificationprogress"`
+
+	// PruneHeight is the height of the first block
+	// whived has not pruned (only set when Pruned).
+	Pruned      bool  `json:"pruned"`
+	PruneHeight int64 `json:"pruneheight,omitempty"`
+}
+
+// PrunedBlockError is returned when the requested
+// block has been pruned by whived. Blocks at or
+// above MinAvailableHeight are still available.
+type PrunedBlockError struct {
+	Hash               string
+	MinAvailableHeight int64
+}
+
+// Error returns the error message.
+func (e *PrunedBlockError) Error() string {
+	return fmt.Sprintf(
+		"%s: block %s is below the minimum available height %d",
+		ErrBlockPruned.Error(),
+		e.Hash,
+		e.MinAvailableHeight,
+	)
+}
+
+// Unwrap returns ErrBlockPruned.
+func (e *PrunedBlockError) Unwrap() error {
+	return ErrBlockPruned
+}
+
+// RawTransactionInfo is the location of a transaction
+// returned by `getrawtransaction` (with verbose == true).
+// This struct only contains the information necessary for
+// this implementation.
+type RawTransactionInfo struct {
+	TxID          string `json:"txid"`
+	BlockHash     string `json:"blockhash"`
+	Confirmations int64  `json:"confirmations"`
+}
+
+// BlockHeader is a raw Bitcoin block header (with verbose == true).
+// This struct only contains the information necessary for
+// this implementation.
+type BlockHeader struct {
+	Hash       string  `json:"hash"`
+	Height     int64   `json:"height"`
+	Time       int64   `json:"time"`
+	Bits       string  `json:"bits"`
+	Difficulty float64 `json:"difficulty"`
 }
 
 // PeerInfo is a collection of relevant info about a particular peer.
@@ -169,6 +317,7 @@ type PeerInfo struct {
 	SubVer         string `json:"subver"`
 	StartingHeight int64  `json:"startingheight"`
 	RelayTxes      bool   `json:"relaytxes"`
+	Inbound        bool   `json:"inbound"`
 	LastSend       int64  `json:"lastsend"`
 	LastRecv       int64  `json:"lastrecv"`
 	BanScore       int64  `json:"banscore"`
@@ -221,6 +370,11 @@ type BlockMetadata struct {
 	MedianTime int64   `json:"mediantime,omitempty"`
 	Bits       string  `json:"bits,omitempty"`
 	Difficulty float64 `json:"difficulty,omitempty"`
+
+	// Only populated when the client is configured with
+	// params (see WithParams).
+	Subsidy string `json:"subsidy,omitempty"`
+	Fees    string `json:"fees,omitempty"`
 }
 
 // Transaction is a raw Bitcoin transaction.
@@ -237,8 +391,26 @@ type Transaction struct {
 	Outputs []*Output `json:"vout"`
 }
 
-// Metadata returns the metadata for a transaction.
-func (t Transaction) Metadata() (map[string]interface{}, error) {
+// Metadata returns the metadata for a transaction
+// given the operations parsed from it.
+func (t Transaction) Metadata(ops []*types.Operation) (map[string]interface{}, error) {
+	return types.MarshalMap(t.metadata(ops))
+}
+
+// MempoolMetadata returns the Metadata of an unconfirmed
+// transaction, which also reports whether it signals
+// opt-in replaceability (this is only meaningful while
+// the transaction is in the mempool).
+func (t Transaction) MempoolMetadata(ops []*types.Operation) (map[string]interface{}, error) {
+	m := t.metadata(ops)
+	m.SignalsRBF = t.SignalsRBF()
+
+	return types.MarshalMap(m)
+}
+
+// metadata returns the *TransactionMetadata for a
+// transaction given the operations parsed from it.
+func (t Transaction) metadata(ops []*types.Operation) *TransactionMetadata {
 	m := &TransactionMetadata{
 		Size:     t.Size,
 		Vsize:    t.Vsize,
@@ -247,7 +419,33 @@ func (t Transaction) Metadata() (map[string]interface{}, error) {
 		Weight:   t.Weight,
 	}
 
-	return types.MarshalMap(m)
+	for _, op := range ops {
+		switch op.Type {
+		case InputOpType:
+			m.InputCount++
+		case OutputOpType:
+			m.OutputCount++
+		}
+	}
+
+	return m
+}
+
+// SignalsRBF returns true if any input of the transaction
+// signals opt-in replaceability (BIP125) with a sequence
+// number below 0xfffffffe. Coinbase inputs are ignored.
+func (t Transaction) SignalsRBF() bool {
+	for _, input := range t.Inputs {
+		if len(input.Coinbase) > 0 {
+			continue
+		}
+
+		if input.Sequence < int64(wire.MaxTxInSequenceNum-1) {
+			return true
+		}
+	}
+
+	return false
 }
 
 // TransactionMetadata is a collection of useful
@@ -258,6 +456,13 @@ type TransactionMetadata struct {
 	Version  int32 `json:"version,omitempty"`
 	Locktime int64 `json:"locktime,omitempty"`
 	Weight   int64 `json:"weight,omitempty"`
+
+	InputCount  int `json:"input_count,omitempty"`
+	OutputCount int `json:"output_count,omitempty"`
+
+	// SignalsRBF is only populated for
+	// transactions in the mempool.
+	SignalsRBF bool `json:"signals_rbf,omitempty"`
 }
 
 // Input is a raw input in a Bitcoin transaction.
@@ -312,7 +517,9 @@ type OperationMetadata struct {
 	TxInWitness []string   `json:"txinwitness,omitempty"`
 
 	// Output Metadata
-	ScriptPubKey *ScriptPubKey `json:"scriptPubKey,omitempty"`
+	ScriptPubKey      *ScriptPubKey `json:"scriptPubKey,omitempty"`
+	ScriptType        string        `json:"script_type,omitempty"`
+	WitnessCommitment bool          `json:"witness_commitment,omitempty"`
 }
 
 // request represents the JSON-RPC request body
@@ -355,6 +562,10 @@ func (b blockResponse) Err() error {
 		return ErrBlockNotFound
 	}
 
+	if b.Error.Code == miscErrCode && strings.Contains(b.Error.Message, blockPrunedErrMessage) {
+		return fmt.Errorf("%w: %s", ErrBlockPruned, b.Error.Message)
+	}
+
 	return fmt.Errorf(
 		"%w: error JSON RPC response, code: %d, message: %s",
 		ErrJSONRPCError,
@@ -391,6 +602,10 @@ func (b blockchainInfoResponse) Err() error {
 		return nil
 	}
 
+	if b.Error.Code == warmupErrCode {
+		return fmt.Errorf("%w: %s", ErrNodeWarmingUp, b.Error.Message)
+	}
+
 	return fmt.Errorf(
 		"%w: error JSON RPC response, code: %d, message: %s",
 		ErrJSONRPCError,
@@ -447,6 +662,19 @@ func (s sendRawTransactionResponse) Err() error {
 		return nil
 	}
 
+	switch s.Error.Code {
+	case verifyErrCode:
+		return fmt.Errorf("%w: %s", ErrTransactionVerify, s.Error.Message)
+	case verifyRejectedErrCode:
+		if strings.Contains(s.Error.Message, mempoolChainTooLongReason) {
+			return fmt.Errorf("%w: %s", ErrMempoolChainTooLong, s.Error.Message)
+		}
+
+		return fmt.Errorf("%w: %s", ErrTransactionRejected, s.Error.Message)
+	case alreadyInChainErrCode:
+		return fmt.Errorf("%w: %s", ErrTransactionAlreadyInChain, s.Error.Message)
+	}
+
 	return fmt.Errorf(
 		"%w: error JSON RPC response, code: %d, message: %s",
 		ErrJSONRPCError,
@@ -478,6 +706,32 @@ func (s suggestedFeeRateResponse) Err() error {
 	)
 }
 
+// mempoolInfo is the result of `getmempoolinfo` requests.
+type mempoolInfo struct {
+	Size          int64   `json:"size"`
+	MempoolMinFee float64 `json:"mempoolminfee"`
+	MinRelayTxFee float64 `json:"minrelaytxfee"`
+}
+
+// mempoolInfoResponse is the response body for `getmempoolinfo` requests.
+type mempoolInfoResponse struct {
+	Result *mempoolInfo   `json:"result"`
+	Error  *responseError `json:"error"`
+}
+
+func (m mempoolInfoResponse) Err() error {
+	if m.Error == nil {
+		return nil
+	}
+
+	return fmt.Errorf(
+		"%w: error JSON RPC response, code: %d, message: %s",
+		ErrJSONRPCError,
+		m.Error.Code,
+		m.Error.Message,
+	)
+}
+
 // rawMempoolResponse is the response body for `getrawmempool` requests.
 type rawMempoolResponse struct {
 	Result []string       `json:"result"`
@@ -497,6 +751,82 @@ func (r rawMempoolResponse) Err() error {
 	)
 }
 
+// rawTransactionResponse is the response body for `getrawtransaction` requests.
+type rawTransactionResponse struct {
+	Result *RawTransactionInfo `json:"result"`
+	Error  *responseError      `json:"error"`
+}
+
+func (r rawTransactionResponse) Err() error {
+	if r.Error == nil {
+		return nil
+	}
+
+	if r.Error.Code == invalidAddressOrKeyErrCode {
+		// bitcoind only searches the mempool when -txindex
+		// is disabled and suggests enabling it in the error.
+		if strings.Contains(r.Error.Message, txIndexHint) {
+			return ErrTxIndexDisabled
+		}
+
+		return ErrTransactionNotFound
+	}
+
+	return fmt.Errorf(
+		"%w: error JSON RPC response, code: %d, message: %s",
+		ErrJSONRPCError,
+		r.Error.Code,
+		r.Error.Message,
+	)
+}
+
+// transactionResponse is the response body for verbose
+// `getrawtransaction` requests of mempool transactions.
+type transactionResponse struct {
+	Result *Transaction   `json:"result"`
+	Error  *responseError `json:"error"`
+}
+
+func (t transactionResponse) Err() error {
+	if t.Error == nil {
+		return nil
+	}
+
+	if t.Error.Code == invalidAddressOrKeyErrCode {
+		return ErrTransactionNotFound
+	}
+
+	return fmt.Errorf(
+		"%w: error JSON RPC response, code: %d, message: %s",
+		ErrJSONRPCError,
+		t.Error.Code,
+		t.Error.Message,
+	)
+}
+
+// blockHeaderResponse is the response body for `getblockheader` requests.
+type blockHeaderResponse struct {
+	Result *BlockHeader   `json:"result"`
+	Error  *responseError `json:"error"`
+}
+
+func (b blockHeaderResponse) Err() error {
+	if b.Error == nil {
+		return nil
+	}
+
+	if b.Error.Code == blockNotFoundErrCode {
+		return ErrBlockNotFound
+	}
+
+	return fmt.Errorf(
+		"%w: error JSON RPC response, code: %d, message: %s",
+		ErrJSONRPCError,
+		b.Error.Code,
+		b.Error.Message,
+	)
+}
+
 // CoinIdentifier converts a tx hash and vout into
 // the canonical CoinIdentifier.Identifier used in
 // rosetta-whive.
@@ -22,6 +22,7 @@ import (
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
 	"github.com/coinbase/rosetta-sdk-go/types"
 )
@@ -49,6 +50,70 @@ func ParseCoinIdentifier(coinIdentifier *types.CoinIdentifier) (*chainhash.Hash,
 	return hash, uint32(outpointIndex), nil
 }
 
+// DustThreshold returns the minimum value (in satoshis) of
+// an output locked by script that is not considered dust when
+// the dust relay fee is dustRelayFee (in satoshis per kvB).
+// Outputs that can never be spent have no threshold.
+func DustThreshold(script []byte, dustRelayFee int64) int64 {
+	if txscript.GetScriptClass(script) == txscript.NullDataTy {
+		return 0
+	}
+
+	size := int64(wire.NewTxOut(0, script).SerializeSize())
+	if txscript.IsWitnessProgram(script) {
+		size += dustWitnessInputSize
+	} else {
+		size += dustInputSize
+	}
+
+	return size * dustRelayFee / 1000 // nolint:gomnd
+}
+
+// standardScript returns a locking script of scriptType
+// (committing to an empty hash or key of the correct size)
+// for computing the dust threshold of that type.
+func standardScript(scriptType string) []byte {
+	builder := txscript.NewScriptBuilder()
+	switch scriptType {
+	case PubKeyHash:
+		builder.AddOp(txscript.OP_DUP).AddOp(txscript.OP_HASH160).
+			AddData(make([]byte, 20)). // nolint:gomnd
+			AddOp(txscript.OP_EQUALVERIFY).AddOp(txscript.OP_CHECKSIG)
+	case ScriptHash:
+		builder.AddOp(txscript.OP_HASH160).
+			AddData(make([]byte, 20)). // nolint:gomnd
+			AddOp(txscript.OP_EQUAL)
+	case WitnessV0PubKeyHash:
+		builder.AddOp(txscript.OP_0).AddData(make([]byte, 20)) // nolint:gomnd
+	case WitnessV0ScriptHash:
+		builder.AddOp(txscript.OP_0).AddData(make([]byte, 32)) // nolint:gomnd
+	case WitnessV1Taproot:
+		builder.AddOp(txscript.OP_1).AddData(make([]byte, 32)) // nolint:gomnd
+	}
+
+	script, _ := builder.Script()
+	return script
+}
+
+// DustThresholds returns the DustThreshold of each
+// standard output type (keyed by the ScriptPubKey.Type
+// bitcoind reports for it) when the dust relay fee is
+// dustRelayFee (in satoshis per kvB).
+func DustThresholds(dustRelayFee int64) map[string]int64 {
+	thresholds := map[string]int64{}
+	for _, scriptType := range []string{
+		PubKeyHash,
+		ScriptHash,
+		WitnessV0PubKeyHash,
+		WitnessV0ScriptHash,
+		WitnessV1Taproot,
+	} {
+		thresholds[scriptType] = DustThreshold(standardScript(scriptType), dustRelayFee)
+	}
+
+	return thresholds
+}
+
 // ParseSingleAddress extracts a single address from a pkscript or
 // throws an error.
 func ParseSingleAddress(